@@ -0,0 +1,100 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+// Nagios plugin exit codes
+const (
+	checkOK       = 0
+	checkWarning  = 1
+	checkCritical = 2
+	checkUnknown  = 3
+)
+
+var checkStates = map[int]string{
+	checkOK:       "OK",
+	checkWarning:  "WARNING",
+	checkCritical: "CRITICAL",
+	checkUnknown:  "UNKNOWN",
+}
+
+// checkResult is the outcome of a Nagios check
+type checkResult struct {
+	Code   int
+	Output string // status line with perfdata, followed by one line per problem
+}
+
+// evaluateCheck grades every managed domain against the warning and critical
+// thresholds, in days until expiry. A domain without a certificate is critical.
+func evaluateCheck(health map[string]certmanager.CertificateHealth, domains []string, warningDays, criticalDays int) checkResult {
+	sorted := append([]string(nil), domains...)
+	sort.Strings(sorted)
+
+	code := checkOK
+	counts := make(map[int]int)
+	var details, perfdata []string
+
+	for _, domain := range sorted {
+		status, exists := health[domain]
+
+		state := checkOK
+		switch {
+		case !exists:
+			state = checkCritical
+			details = append(details, fmt.Sprintf("CRITICAL: %s has no certificate", domain))
+		case status.IsExpired:
+			state = checkCritical
+			details = append(details, fmt.Sprintf("CRITICAL: %s expired on %s", domain, status.ExpiresAt.Format("2006-01-02")))
+		case status.DaysUntilExpiry <= criticalDays:
+			state = checkCritical
+			details = append(details, fmt.Sprintf("CRITICAL: %s expires in %d days", domain, status.DaysUntilExpiry))
+		case status.DaysUntilExpiry <= warningDays:
+			state = checkWarning
+			details = append(details, fmt.Sprintf("WARNING: %s expires in %d days", domain, status.DaysUntilExpiry))
+		}
+
+		if exists {
+			perfdata = append(perfdata, fmt.Sprintf("'%s'=%d;%d;%d;0;", domain, status.DaysUntilExpiry, warningDays, criticalDays))
+		}
+
+		counts[state]++
+		if state > code {
+			code = state
+		}
+	}
+
+	summary := fmt.Sprintf("%d certificates OK", counts[checkOK])
+	if len(sorted) == 0 {
+		code = checkUnknown
+		summary = "no managed domains"
+	} else if code != checkOK {
+		summary = fmt.Sprintf("%d critical, %d warning, %d ok", counts[checkCritical], counts[checkWarning], counts[checkOK])
+	}
+
+	output := fmt.Sprintf("CERTIFICATES %s - %s", checkStates[code], summary)
+	if len(perfdata) > 0 {
+		output += " | " + strings.Join(perfdata, " ")
+	}
+	for _, detail := range details {
+		output += "\n" + detail
+	}
+
+	return checkResult{Code: code, Output: output}
+}
+
+// runCheck prints the result of a Nagios check and returns its exit code
+func runCheck(certManager *certmanager.CertificateManager, warningDays, criticalDays int) int {
+	if criticalDays > warningDays {
+		fmt.Printf("CERTIFICATES UNKNOWN - -critical-days (%d) must not exceed -warning-days (%d)\n", criticalDays, warningDays)
+		return checkUnknown
+	}
+
+	result := evaluateCheck(certManager.CheckCertificateHealth(), certManager.GetManagedDomains(), warningDays, criticalDays)
+	fmt.Println(result.Output)
+	return result.Code
+}
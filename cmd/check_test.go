@@ -0,0 +1,53 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestEvaluateCheck(t *testing.T) {
+	health := map[string]certmanager.CertificateHealth{
+		"example.com":     {Domain: "example.com", DaysUntilExpiry: 60},
+		"www.example.com": {Domain: "www.example.com", DaysUntilExpiry: 10},
+		"api.example.com": {Domain: "api.example.com", DaysUntilExpiry: 3},
+	}
+
+	tests := []struct {
+		name    string
+		domains []string
+		code    int
+		status  string
+	}{
+		{"all ok", []string{"example.com"}, checkOK, "CERTIFICATES OK - 1 certificates OK | 'example.com'=60;14;7;0;"},
+		{"warning", []string{"example.com", "www.example.com"}, checkWarning, "CERTIFICATES WARNING - 0 critical, 1 warning, 1 ok"},
+		{"critical", []string{"api.example.com", "www.example.com"}, checkCritical, "CERTIFICATES CRITICAL - 1 critical, 1 warning, 0 ok"},
+		{"missing certificate", []string{"shop.example.com"}, checkCritical, "CERTIFICATES CRITICAL - 1 critical, 0 warning, 0 ok"},
+		{"no domains", nil, checkUnknown, "CERTIFICATES UNKNOWN - no managed domains"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result := evaluateCheck(health, tt.domains, 14, 7)
+			if result.Code != tt.code {
+				t.Errorf("code = %d, want %d", result.Code, tt.code)
+			}
+			if !strings.HasPrefix(result.Output, tt.status) {
+				t.Errorf("output = %q, want prefix %q", result.Output, tt.status)
+			}
+		})
+	}
+}
+
+func TestEvaluateCheck_Expired(t *testing.T) {
+	health := map[string]certmanager.CertificateHealth{
+		"example.com": {Domain: "example.com", IsExpired: true, ExpiresAt: time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)},
+	}
+
+	result := evaluateCheck(health, []string{"example.com"}, 14, 7)
+	if result.Code != checkCritical || !strings.Contains(result.Output, "\nCRITICAL: example.com expired on 2024-05-01") {
+		t.Errorf("result = %d %q", result.Code, result.Output)
+	}
+}
@@ -0,0 +1,597 @@
+package main
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/traefik"
+	"github.com/spf13/cobra"
+)
+
+// options are the flags shared by every command
+type options struct {
+	configPath string
+	verbose    bool
+	noMigrate  bool
+	acmeCA     string    // CA replacing the configured one, e.g. pebble
+	output     string    // report format of health, once and list
+	strict     bool      // once fails on any failure, as app.strict
+	summary    string    // file once writes its JSON report to, whatever the output format
+	logOutput  io.Writer // replaces standard output and error for logs, e.g. the Windows event log
+	server     string    // management API of a running daemon that remote-capable commands go through
+	token      string    // bearer token for the server
+}
+
+func newRootCommand() *cobra.Command {
+	opts := &options{}
+
+	root := &cobra.Command{
+		Use:           "traefik-cert-manager",
+		Short:         "Issue and renew ACME certificates for services behind Traefik",
+		Long:          "Issue and renew ACME certificates for services behind Traefik. Without a command the daemon runs.",
+		Version:       version,
+		Args:          cobra.NoArgs,
+		SilenceUsage:  true,
+		SilenceErrors: true,
+		PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+			return checkRemote(cmd, opts)
+		},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDaemon(cmd.Context(), opts)
+		},
+	}
+	root.SetVersionTemplate("Traefik Certificate Manager v{{.Version}}\n")
+
+	flags := root.PersistentFlags()
+	flags.StringVar(&opts.configPath, "config", defaultConfigPath, "Path to configuration file")
+	flags.BoolVar(&opts.verbose, "verbose", false, "Enable verbose logging")
+	flags.BoolVar(&opts.noMigrate, "no-migrate", false, "Refuse to start instead of migrating an outdated storage layout")
+	flags.StringVar(&opts.acmeCA, "acme-ca", "", "Order certificates from a test CA instead of the configured one: pebble")
+	flags.StringVar(&opts.server, "server", "", "Run list, renew, revoke and health through the management API of the daemon at this URL, e.g. https://certmgr:9000")
+	flags.StringVar(&opts.token, "token", "", "Bearer token of the management API with --server (default $"+tokenEnv+")")
+
+	root.AddCommand(
+		newRunCommand(opts),
+		newOnceCommand(opts),
+		remoteCapable(newHealthCommand(opts)),
+		newCheckCommand(opts),
+		newRequestCommand(opts),
+		remoteCapable(newRenewCommand(opts)),
+		newRetryFailedCommand(opts),
+		remoteCapable(newRevokeCommand(opts)),
+		newRollbackCommand(opts),
+		remoteCapable(newListCommand(opts)),
+		newTopCommand(opts),
+		newInspectCommand(opts),
+		newUsageCommand(opts),
+		newReportCommand(opts),
+		newImportCommand(opts),
+		newAdoptCommand(opts),
+		newMaintenanceCommand(opts),
+		newPauseCommand(opts),
+		newResumeCommand(opts),
+		newACMEDebugCommand(opts),
+		newRefreshChainsCommand(opts),
+		newDriftCommand(opts),
+		newSelfTestCommand(opts),
+		newMigrateStorageCommand(opts),
+		newInternalCACommand(opts),
+		newConfigCommand(opts),
+		newNotifyCommand(opts),
+		newPebbleCommand(),
+		newVersionCommand(),
+	)
+	root.AddCommand(platformCommands(opts)...)
+	registerCompletions(root, opts)
+	return root
+}
+
+// addOutputFlag registers --output on commands that print reports
+func addOutputFlag(cmd *cobra.Command, opts *options) {
+	cmd.Flags().StringVar(&opts.output, "output", "table", "Output format: table, json or yaml")
+	cmd.PreRunE = func(cmd *cobra.Command, args []string) error {
+		if !validOutputFormat(opts.output) {
+			return fmt.Errorf("unknown output format %q, expected table, json or yaml", opts.output)
+		}
+		return nil
+	}
+}
+
+// managerCommand loads the configuration and certificate manager, then runs fn
+func managerCommand(opts *options, logToStderr bool, fn func(*certmanager.CertificateManager, *config.Config, *log.Logger) error) error {
+	cfg, logger, err := setup(opts, logToStderr)
+	if err != nil {
+		return err
+	}
+	defer startTracing(cfg.Tracing, logger)()
+
+	certManager, err := newCertificateManager(cfg, logger)
+	if err != nil {
+		return err
+	}
+	return fn(certManager, cfg, logger)
+}
+
+func newRunCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "run",
+		Short: "Run the daemon, renewing certificates on schedule",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return runDaemon(cmd.Context(), opts)
+		},
+	}
+}
+
+func newOnceCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "once",
+		Short: "Issue and renew certificates once, report their health and exit",
+		Long: "Issue and renew certificates once, report their health and exit with 0 when all are valid, 1 when some need renewal or expired, " +
+			"2 when the run partly failed and 3 when it failed without issuing any certificate.\n\n" +
+			"With --strict, or app.strict in the configuration, any failure, including a failed deployment, fails the run.\n\n" +
+			"With --summary-file, the report, including the domains issued and failed, is also written there as JSON for " +
+			"cron jobs and CI pipelines to pick up.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				reportUnmanagedCertificates(certManager, logger)
+
+				// Only reports router domains; renewal works from storage without Traefik
+				timeout, _ := cfg.GetTraefikTimeout()
+				connectTraefik(cmd.Context(), traefik.NewAPIClient(cfg.TraefikAPI, timeout), cfg, logger)
+
+				ctx, cancel := runContext(cmd, cfg)
+				defer cancel()
+				return exitCode(runOnceMode(ctx, certManager, opts.output, opts.summary, opts.strict || cfg.App.Strict, logger))
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().BoolVar(&opts.strict, "strict", false, "Exit 2 on any failure, including failed deployments")
+	cmd.Flags().StringVar(&opts.summary, "summary-file", "", "Also write the report as JSON to this file")
+	return cmd
+}
+
+func newHealthCommand(opts *options) *cobra.Command {
+	var group string
+
+	cmd := &cobra.Command{
+		Use:   "health",
+		Short: "Report certificate health",
+		Long: "Report certificate health and exit with 0 when all certificates are valid and 1 when some need renewal or expired. " +
+			"With --group, only the domains of that group are reported and counted.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if opts.server != "" {
+				return runRemoteHealthCheck(cmd.Context(), remoteClient(opts), group, opts.output)
+			}
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				return exitCode(runHealthCheck(certManager, group, opts.output, logger))
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().StringVar(&group, "group", "", "Only report the domains of this group")
+	return cmd
+}
+
+func newCheckCommand(opts *options) *cobra.Command {
+	var warningDays, criticalDays int
+
+	cmd := &cobra.Command{
+		Use:   "check",
+		Short: "Run a Nagios-compatible certificate check",
+		Long:  "Run a Nagios-compatible certificate check. It exits 0 (OK), 1 (WARNING), 2 (CRITICAL) or 3 (UNKNOWN) and prints days until expiry as perfdata.",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			err := managerCommand(opts, true, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				return exitCode(runCheck(certManager, warningDays, criticalDays))
+			})
+			var code exitCode
+			if err != nil && !errors.As(err, &code) {
+				// Monitoring treats anything but 0-2 as a failure of the check itself
+				fmt.Fprintf(cmd.OutOrStdout(), "CERTIFICATES UNKNOWN - %v\n", err)
+				return exitCode(checkUnknown)
+			}
+			return err
+		},
+	}
+	cmd.Flags().IntVar(&warningDays, "warning-days", 14, "Days until expiry at which the check reports WARNING")
+	cmd.Flags().IntVar(&criticalDays, "critical-days", 7, "Days until expiry at which the check reports CRITICAL")
+	return cmd
+}
+
+func newRequestCommand(opts *options) *cobra.Command {
+	var force, revoke bool
+	var reason, group string
+
+	cmd := &cobra.Command{
+		Use:   "request [DOMAIN...]",
+		Short: "Request certificates for managed domains that have none or need renewal",
+		Long: "Request certificates for managed domains, or those of a --group, that have none or need renewal.\n\n" +
+			"With --force, a certificate with a new key is issued even though the current one is valid, e.g. after a " +
+			"key compromise or to pick up changed SANs or a new key type. --revoke then revokes the replaced certificate.",
+		Example: "  cert-manager request example.com\n" +
+			"  cert-manager request --group staging --force",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if revoke && !force {
+				return fmt.Errorf("--revoke requires --force")
+			}
+			code, err := certmanager.ParseRevocationReason(reason)
+			if err != nil {
+				return err
+			}
+			if err := checkDomainArgs(args, group); err != nil {
+				return err
+			}
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				domains, err := selectDomains(cfg, args, group)
+				if err != nil {
+					return err
+				}
+				ctx, cancel := runContext(cmd, cfg)
+				defer cancel()
+				if !force {
+					return forEachManagedDomain(ctx, certManager, domains, certManager.RequestCertificate)
+				}
+				return forEachManagedDomain(ctx, certManager, domains, func(ctx context.Context, domain string) error {
+					_, err := certManager.ReissueCertificate(ctx, domain, revoke, code)
+					return err
+				})
+			})
+		},
+	}
+	cmd.Flags().StringVar(&group, "group", "", "Request certificates for the domains of this group instead of the given ones")
+	cmd.Flags().BoolVar(&force, "force", false, "Issue new certificates with new keys even if the current ones are valid")
+	cmd.Flags().BoolVar(&revoke, "revoke", false, "With --force, revoke the replaced certificates")
+	cmd.Flags().StringVar(&reason, "revoke-reason", "superseded", "Revocation reason with --revoke: unspecified, keyCompromise, affiliationChanged, superseded or cessationOfOperation")
+	return cmd
+}
+
+func newRenewCommand(opts *options) *cobra.Command {
+	var group string
+
+	cmd := &cobra.Command{
+		Use:     "renew [DOMAIN...]",
+		Short:   "Renew the certificates of managed domains now",
+		Long:    "Renew the certificates of managed domains, or those of a --group, now.",
+		Example: "  cert-manager renew example.com api.example.com\n  cert-manager renew --group production",
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if err := checkDomainArgs(args, group); err != nil {
+				return err
+			}
+			if opts.server != "" {
+				client := remoteClient(opts)
+				domains, err := remoteDomains(cmd.Context(), client, args, group)
+				if err != nil {
+					return err
+				}
+				return forEachDomain(cmd.Context(), domains, func(ctx context.Context, domain string) error {
+					if err := client.Renew(ctx, domain); err != nil {
+						return err
+					}
+					fmt.Fprintf(cmd.OutOrStdout(), "Renewed certificate for %s\n", domain)
+					return nil
+				})
+			}
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				domains, err := selectDomains(cfg, args, group)
+				if err != nil {
+					return err
+				}
+				ctx, cancel := runContext(cmd, cfg)
+				defer cancel()
+				return forEachManagedDomain(ctx, certManager, domains, certManager.RenewCertificate)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&group, "group", "", "Renew the certificates of the domains of this group instead of the given ones")
+	return cmd
+}
+
+func newRevokeCommand(opts *options) *cobra.Command {
+	var reason string
+
+	cmd := &cobra.Command{
+		Use:   "revoke DOMAIN",
+		Short: "Revoke the current certificate of a domain at its CA",
+		Long:  "Revoke the current certificate of a domain at its CA. The revoked certificate stays in storage until it is renewed.",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			code, err := certmanager.ParseRevocationReason(reason)
+			if err != nil {
+				return err
+			}
+			if opts.server != "" {
+				if err := remoteClient(opts).Revoke(cmd.Context(), args[0], reason); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Revoked certificate for %s\n", args[0])
+				return nil
+			}
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				ctx, cancel := runContext(cmd, cfg)
+				defer cancel()
+				return certManager.RevokeCertificate(ctx, args[0], code)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "unspecified", "Revocation reason: unspecified, keyCompromise, affiliationChanged, superseded or cessationOfOperation")
+	return cmd
+}
+
+func newRollbackCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "rollback DOMAIN",
+		Short: "Reinstate the previous certificate of a domain and deploy it",
+		Long: "Reinstate the newest archived certificate issued before the current one and deploy it, e.g. when a " +
+			"newly issued certificate causes problems for clients. The current certificate is archived in turn. " +
+			"Requires certificates.archive.retention; the next renewal replaces the reinstated certificate as usual.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				cert, err := certManager.RollbackCertificate(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Rolled back %s to the certificate valid until %s\n", args[0], cert.ExpiresAt.Format(time.RFC3339))
+				return nil
+			})
+		},
+	}
+}
+
+func newImportCommand(opts *options) *cobra.Command {
+	var certPath, keyPath string
+
+	cmd := &cobra.Command{
+		Use:   "import",
+		Short: "Import a PEM certificate and key, completing its chain via AIA",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				return importCertificate(certManager, certPath, keyPath, logger)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&certPath, "cert", "", "PEM certificate, optionally followed by intermediates")
+	cmd.Flags().StringVar(&keyPath, "key", "", "PEM private key")
+	cmd.MarkFlagRequired("cert")
+	cmd.MarkFlagRequired("key")
+	return cmd
+}
+
+func newAdoptCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "adopt DOMAIN...|all",
+		Short: "Bring unmanaged on-disk certificates under management",
+		Args:  cobra.MinimumNArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				return adoptCertificates(certManager, strings.Join(args, ","), logger)
+			})
+		},
+	}
+}
+
+func newMaintenanceCommand(opts *options) *cobra.Command {
+	var reason string
+
+	cmd := &cobra.Command{
+		Use:       "maintenance on|off|status",
+		Short:     "Pause or resume issuance, renewal and deployment",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: []string{"on", "off", "status"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Maintenance is switched through the storage path, without a manager
+			cfg, logger, err := setup(opts, false)
+			if err != nil {
+				return err
+			}
+			return setMaintenance(cfg, args[0], reason, logger)
+		},
+	}
+	cmd.Flags().StringVar(&reason, "reason", "", "Reason recorded with maintenance on")
+	return cmd
+}
+
+func newACMEDebugCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "acme-debug on|off|status [DOMAIN]",
+		Short: "Log the ACME exchanges of a domain to the wire log",
+		Long: "Switch logging of the HTTP exchanges with the CA on or off for a domain, or for all domains " +
+			"with \"*\". JWS payloads and signatures are redacted. A running daemon picks the change up with " +
+			"the next certificate operation.",
+		Args: func(cmd *cobra.Command, args []string) error {
+			if len(args) == 0 || len(args) > 2 {
+				return fmt.Errorf("expected on, off or status and a domain")
+			}
+			if args[0] != "status" && len(args) != 2 {
+				return fmt.Errorf("acme-debug %s requires a domain", args[0])
+			}
+			return nil
+		},
+		ValidArgs: []string{"on", "off", "status"},
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Like maintenance, switched through the storage path without a manager
+			cfg, _, err := setup(opts, false)
+			if err != nil {
+				return err
+			}
+			domain := ""
+			if len(args) == 2 {
+				domain = args[1]
+			}
+			return setACMEDebug(cmd.OutOrStdout(), cfg, args[0], domain)
+		},
+	}
+}
+
+func newRefreshChainsCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "refresh-chains",
+		Short: "Re-download issuer chains for all certificates without re-keying",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				return runRefreshChains(certManager, logger)
+			})
+		},
+	}
+}
+
+func newVersionCommand() *cobra.Command {
+	return &cobra.Command{
+		Use:   "version",
+		Short: "Show version information",
+		Args:  cobra.NoArgs,
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Fprintf(cmd.OutOrStdout(), "Traefik Certificate Manager v%s\n", version)
+		},
+	}
+}
+
+// runContext bounds a command that orders certificates by scheduler.run_timeout. It
+// is cancelled when the command is interrupted.
+func runContext(cmd *cobra.Command, cfg *config.Config) (context.Context, context.CancelFunc) {
+	timeout, err := cfg.GetRunTimeout()
+	if err != nil {
+		timeout = time.Hour
+	}
+	return context.WithTimeout(cmd.Context(), timeout)
+}
+
+// checkDomainArgs requires either domains or a group to operate on
+func checkDomainArgs(args []string, group string) error {
+	switch {
+	case group != "" && len(args) > 0:
+		return fmt.Errorf("domains and --group are mutually exclusive")
+	case group == "" && len(args) == 0:
+		return fmt.Errorf("requires at least one domain or --group")
+	}
+	return nil
+}
+
+// selectDomains returns the domains given as arguments, or those of group
+func selectDomains(cfg *config.Config, args []string, group string) ([]string, error) {
+	if group == "" {
+		return args, nil
+	}
+	domains := cfg.DomainsInGroup(group)
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("no domains in group %q", group)
+	}
+	return domains, nil
+}
+
+// forEachManagedDomain applies fn to each domain, refusing domains the manager
+// does not know so a typo doesn't order a certificate for the wrong name
+func forEachManagedDomain(ctx context.Context, certManager *certmanager.CertificateManager, domains []string, fn func(context.Context, string) error) error {
+	managed := make(map[string]bool)
+	for _, domain := range certManager.GetManagedDomains() {
+		managed[domain] = true
+	}
+
+	for _, domain := range domains {
+		if !managed[domain] {
+			return fmt.Errorf("%s is not a managed domain; add it to the configuration or adopt its certificate first", domain)
+		}
+	}
+
+	return forEachDomain(ctx, domains, fn)
+}
+
+// forEachDomain applies fn to each domain, carrying on after failures
+func forEachDomain(ctx context.Context, domains []string, fn func(context.Context, string) error) error {
+	var failed []string
+	for _, domain := range domains {
+		if err := fn(ctx, domain); err != nil {
+			failed = append(failed, fmt.Sprintf("%s: %v", domain, err))
+		}
+	}
+	if len(failed) > 0 {
+		return fmt.Errorf("%d of %d domains failed: %s", len(failed), len(domains), strings.Join(failed, "; "))
+	}
+	return nil
+}
+
+// translateLegacyArgs maps the flags of the former flag-based binary, such as
+// "-config c.yaml -once", onto commands, so existing deployments keep working
+func translateLegacyArgs(args []string, warnings io.Writer) []string {
+	if len(args) == 0 || !strings.HasPrefix(args[0], "-") {
+		return args
+	}
+
+	// Legacy mode flags and the command plus flag names they become
+	modes := map[string][]string{
+		"once":           {"once"},
+		"health":         {"health"},
+		"check":          {"check"},
+		"refresh-chains": {"refresh-chains"},
+		"import-cert":    {"import", "--cert"},
+		"adopt":          {"adopt", ""},
+		"maintenance":    {"maintenance", ""},
+	}
+	renamed := map[string]string{
+		"import-key":         "--key",
+		"maintenance-reason": "--reason",
+	}
+
+	var command string
+	var rest []string
+	for i := 0; i < len(args); i++ {
+		arg := args[i]
+		if arg == "--" {
+			rest = append(rest, args[i:]...)
+			break
+		}
+		if strings.HasPrefix(arg, "-") && !strings.HasPrefix(arg, "--") && len(arg) > 2 {
+			arg = "-" + arg
+		}
+
+		name, value, hasValue := strings.Cut(strings.TrimPrefix(arg, "--"), "=")
+		if !strings.HasPrefix(arg, "--") {
+			rest = append(rest, arg)
+			continue
+		}
+
+		if mode, ok := modes[name]; ok {
+			command = mode[0]
+			fmt.Fprintf(warnings, "Warning: -%s is deprecated, use the %s command\n", name, command)
+			if len(mode) == 1 {
+				continue
+			}
+			if !hasValue && i+1 < len(args) {
+				i++
+				value = args[i]
+			}
+			if mode[1] == "" {
+				rest = append(rest, value)
+			} else {
+				rest = append(rest, mode[1], value)
+			}
+			continue
+		}
+		if flag, ok := renamed[name]; ok {
+			if hasValue {
+				arg = flag + "=" + value
+			} else {
+				arg = flag
+			}
+		}
+		rest = append(rest, arg)
+	}
+
+	if command == "" {
+		return rest
+	}
+	return append([]string{command}, rest...)
+}
@@ -0,0 +1,83 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/api"
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestTranslateLegacyArgs(t *testing.T) {
+	tests := []struct {
+		args []string
+		want []string
+	}{
+		{nil, nil},
+		{[]string{"health", "--output", "json"}, []string{"health", "--output", "json"}},
+		{[]string{"-config", "c.yaml"}, []string{"--config", "c.yaml"}},
+		{[]string{"-config", "c.yaml", "-once", "-output=json"}, []string{"once", "--config", "c.yaml", "--output=json"}},
+		{[]string{"-check", "-warning-days", "30"}, []string{"check", "--warning-days", "30"}},
+		{[]string{"-import-cert", "a.pem", "-import-key", "a.key"}, []string{"import", "--cert", "a.pem", "--key", "a.key"}},
+		{[]string{"-adopt=all"}, []string{"adopt", "all"}},
+		{[]string{"-maintenance", "on", "-maintenance-reason", "freeze"}, []string{"maintenance", "on", "--reason", "freeze"}},
+		{[]string{"-version"}, []string{"--version"}},
+	}
+
+	for _, tt := range tests {
+		var warnings bytes.Buffer
+		if got := translateLegacyArgs(tt.args, &warnings); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("translateLegacyArgs(%q) = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+}
+
+func TestRootCommand_Version(t *testing.T) {
+	for _, args := range [][]string{{"version"}, {"--version"}} {
+		root := newRootCommand()
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs(args)
+
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		if !strings.Contains(out.String(), "Traefik Certificate Manager v"+version) {
+			t.Errorf("%v printed %q", args, out.String())
+		}
+	}
+}
+
+func TestRootCommand_RejectsUnknownOutput(t *testing.T) {
+	root := newRootCommand()
+	root.SetArgs([]string{"health", "--output", "xml"})
+
+	err := root.Execute()
+	if err == nil || !strings.Contains(err.Error(), "unknown output format") {
+		t.Errorf("err = %v, want an unknown output format error", err)
+	}
+}
+
+func TestFailure(t *testing.T) {
+	tests := []struct {
+		err     error
+		message string
+		status  int
+	}{
+		{errors.New("boom"), "Error: boom", 1},
+		{fmt.Errorf("failed to save certificate: %w", certmanager.ErrStorage), "Error [storage]: failed to save certificate: storage failure", 12},
+		// A class reported by a running daemon
+		{&api.ResponseError{StatusCode: 429, Message: "too many new orders", Code: certmanager.CodeRateLimited},
+			"Error [rate_limited]: management API returned 429 Too Many Requests: too many new orders", 10},
+	}
+	for _, tt := range tests {
+		message, status := failure(tt.err)
+		if message != tt.message || status != tt.status {
+			t.Errorf("failure(%v) = %q, %d; want %q, %d", tt.err, message, status, tt.message, tt.status)
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package main
+
+import (
+	"sort"
+	"strings"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// flagCompletions are the values offered for flags with a fixed set of values,
+// wherever a command defines them
+var flagCompletions = map[string][]string{
+	"output":  {"table", "json", "yaml"},
+	"status":  {"valid", "needs_renewal", "expired"},
+	"sort":    {"domain", "expiry"},
+	"acme-ca": {"pebble"},
+}
+
+// registerCompletions completes domains, groups and flag values in the shell
+// completions generated by the completion command. Domains and groups are read
+// from the configuration given with --config, or the default one.
+func registerCompletions(root *cobra.Command, opts *options) {
+	root.MarkPersistentFlagFilename("config", "yaml", "yml", "json", "toml")
+
+	var walk func(cmd *cobra.Command)
+	walk = func(cmd *cobra.Command) {
+		if strings.Contains(cmd.Use, "DOMAIN") && len(cmd.ValidArgs) == 0 && cmd.ValidArgsFunction == nil {
+			cmd.ValidArgsFunction = completeDomains(opts)
+		}
+		for name, values := range flagCompletions {
+			if cmd.LocalNonPersistentFlags().Lookup(name) != nil || cmd.PersistentFlags().Lookup(name) != nil {
+				cmd.RegisterFlagCompletionFunc(name, cobra.FixedCompletions(values, cobra.ShellCompDirectiveNoFileComp))
+			}
+		}
+		if cmd.LocalNonPersistentFlags().Lookup("group") != nil {
+			cmd.RegisterFlagCompletionFunc("group", completeGroups(opts))
+		}
+		for _, sub := range cmd.Commands() {
+			walk(sub)
+		}
+	}
+	walk(root)
+}
+
+// completeDomains offers the configured domains not already given
+func completeDomains(opts *options) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg, err := config.LoadConfig(opts.configPath)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		given := make(map[string]bool)
+		for _, arg := range args {
+			given[arg] = true
+		}
+
+		var domains []string
+		for _, d := range cfg.Domains {
+			if !given[d.Domain] && strings.HasPrefix(d.Domain, toComplete) {
+				domains = append(domains, d.Domain)
+			}
+		}
+		return domains, cobra.ShellCompDirectiveNoFileComp
+	}
+}
+
+// completeGroups offers the groups of configured domains
+func completeGroups(opts *options) cobra.CompletionFunc {
+	return func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		cfg, err := config.LoadConfig(opts.configPath)
+		if err != nil {
+			return nil, cobra.ShellCompDirectiveNoFileComp
+		}
+		seen := make(map[string]bool)
+		var groups []string
+		for _, d := range cfg.Domains {
+			if d.Group != "" && !seen[d.Group] {
+				seen[d.Group] = true
+				groups = append(groups, d.Group)
+			}
+		}
+		sort.Strings(groups)
+		return groups, cobra.ShellCompDirectiveNoFileComp
+	}
+}
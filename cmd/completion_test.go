@@ -0,0 +1,71 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCompletions(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+traefik_api: "http://localhost:8080/api"
+email: "admin@example.com"
+notification:
+  smtp_host: "smtp.test.com"
+  smtp_port: 587
+domains:
+  - service: "web"
+    domain: "example.com"
+    group: "production"
+  - service: "api"
+    domain: "api.example.com"
+    group: "staging"
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	complete := func(args ...string) []string {
+		root := newRootCommand()
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs(append([]string{"__complete", "--config", path}, args...))
+		if err := root.Execute(); err != nil {
+			t.Fatalf("%v: %v", args, err)
+		}
+		// The last line is the directive
+		lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+		return lines[:len(lines)-1]
+	}
+
+	tests := []struct {
+		args []string
+		want string
+	}{
+		{[]string{"renew", ""}, "example.com api.example.com"},
+		{[]string{"renew", "example.com", ""}, "api.example.com"},
+		{[]string{"inspect", "api"}, "api.example.com"},
+		{[]string{"list", "--group", ""}, "production staging"},
+		{[]string{"list", "--sort", ""}, "domain expiry"},
+		{[]string{"top", "--group", ""}, "production staging"},
+		{[]string{"health", "--output", ""}, "table json yaml"},
+	}
+	for _, tt := range tests {
+		if got := strings.Join(complete(tt.args...), " "); got != tt.want {
+			t.Errorf("completing %q = %q, want %q", tt.args, got, tt.want)
+		}
+	}
+
+	for _, shell := range []string{"bash", "zsh", "fish"} {
+		root := newRootCommand()
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs([]string{"completion", shell})
+		if err := root.Execute(); err != nil || !strings.Contains(out.String(), "traefik-cert-manager") {
+			t.Errorf("completion %s = %v, printed %d bytes", shell, err, out.Len())
+		}
+	}
+}
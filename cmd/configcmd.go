@@ -0,0 +1,119 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// onlineCheckTimeout bounds each reachability check of config validate --online
+const onlineCheckTimeout = 10 * time.Second
+
+func newConfigCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with the configuration file",
+	}
+	cmd.AddCommand(newConfigValidateCommand(opts))
+	return cmd
+}
+
+func newConfigValidateCommand(opts *options) *cobra.Command {
+	var online bool
+
+	cmd := &cobra.Command{
+		Use:   "validate",
+		Short: "Report every problem in the configuration file",
+		Long: "Validate the configuration file and print all problems at once. With --online the " +
+			"Traefik API, ACME directory, HTTP-01 prober, DoH resolvers and SMTP server are also contacted.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, problems, err := config.Validate(opts.configPath)
+			if err != nil {
+				return err
+			}
+			if online {
+				problems = append(problems, checkReachability(cmd.Context(), cfg)...)
+			}
+			return reportProblems(cmd.OutOrStdout(), opts.configPath, problems)
+		},
+	}
+	cmd.Flags().BoolVar(&online, "online", false, "Also check that configured endpoints are reachable")
+	return cmd
+}
+
+// reportProblems prints the problems found in a configuration file and fails
+// with exit status 1 if there are any
+func reportProblems(w io.Writer, path string, problems []error) error {
+	if len(problems) == 0 {
+		fmt.Fprintf(w, "%s: configuration is valid\n", path)
+		return nil
+	}
+
+	fmt.Fprintf(w, "%s: %d problems\n", path, len(problems))
+	for _, problem := range problems {
+		fmt.Fprintf(w, "  - %v\n", problem)
+	}
+	return exitCode(1)
+}
+
+// checkReachability contacts the endpoints the configuration refers to. Any HTTP
+// response counts as reachable, except for the ACME directory which must load.
+func checkReachability(ctx context.Context, cfg *config.Config) []error {
+	client := &http.Client{Timeout: onlineCheckTimeout}
+
+	var problems []error
+	get := func(field, url string, wantOK bool) {
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s: %w", field, err))
+			return
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s is unreachable: %w", field, err))
+			return
+		}
+		resp.Body.Close()
+		if wantOK && resp.StatusCode != http.StatusOK {
+			problems = append(problems, fmt.Errorf("%s returned status %d", field, resp.StatusCode))
+		}
+	}
+
+	get("traefik_api", cfg.TraefikAPI, false)
+	get("acme.ca_dir_url", cfg.ACME.CADirURL, true)
+	for i, domain := range cfg.Domains {
+		if domain.CADirURL != "" {
+			get(fmt.Sprintf("domain[%d].ca_dir_url", i), domain.CADirURL, true)
+		}
+	}
+	if cfg.ACME.HTTP01Prober != "" {
+		get("acme.http01_prober", cfg.ACME.HTTP01Prober, false)
+	}
+	for i, resolver := range cfg.DNS.DoHResolvers {
+		get(fmt.Sprintf("dns.doh_resolvers[%d]", i), resolver, false)
+	}
+	if cfg.Inventory.Endpoint != "" {
+		get("inventory.endpoint", cfg.Inventory.Endpoint, false)
+	}
+
+	if cfg.Notification.SMTPHost != "" {
+		address := net.JoinHostPort(cfg.Notification.SMTPHost, strconv.Itoa(cfg.Notification.SMTPPort))
+		dialer := net.Dialer{Timeout: onlineCheckTimeout}
+		conn, err := dialer.DialContext(ctx, "tcp", address)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("notification.smtp_host %s is unreachable: %w", address, err))
+		} else {
+			conn.Close()
+		}
+	}
+
+	return problems
+}
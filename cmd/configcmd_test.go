@@ -0,0 +1,74 @@
+package main
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func TestConfigValidate_ReportsAllProblems(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	data := `
+traefik_api: "http://localhost:8080/api"
+notification:
+  smtp_host: "smtp.test.com"
+  smtp_port: 587
+certificates:
+  lock_ttl: "forever"
+domains:
+  - service: "web"
+    domain: "example.com"
+  - service: "web2"
+    domain: "example.com"
+`
+	if err := os.WriteFile(path, []byte(data), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	root := newRootCommand()
+	var out bytes.Buffer
+	root.SetOut(&out)
+	root.SetArgs([]string{"config", "validate", "--config", path})
+
+	err := root.Execute()
+	var code exitCode
+	if !errors.As(err, &code) || code != 1 {
+		t.Fatalf("err = %v, want exit status 1", err)
+	}
+	for _, want := range []string{"3 problems", "email is required", "certificates.lock_ttl is invalid", "domain[1].domain example.com duplicates domain[0]"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("output lacks %q:\n%s", want, out.String())
+		}
+	}
+}
+
+func TestCheckReachability(t *testing.T) {
+	up := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/missing" {
+			http.NotFound(w, r)
+		}
+	}))
+	defer up.Close()
+
+	cfg := &config.Config{
+		TraefikAPI: up.URL + "/api",
+		ACME:       config.ACME{CADirURL: up.URL + "/missing"},
+		DNS:        config.DNS{DoHResolvers: []string{"http://127.0.0.1:1/dns-query"}},
+	}
+
+	var got []string
+	for _, problem := range checkReachability(context.Background(), cfg) {
+		got = append(got, problem.Error())
+	}
+	if len(got) != 2 || got[0] != "acme.ca_dir_url returned status 404" || !strings.HasPrefix(got[1], "dns.doh_resolvers[0] is unreachable") {
+		t.Errorf("problems = %q", got)
+	}
+}
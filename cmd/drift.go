@@ -0,0 +1,147 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"slices"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/traefik"
+	"github.com/spf13/cobra"
+)
+
+// exitDrift is the exit code of the drift command when drift remains
+const exitDrift = 1
+
+// DriftReport is the output of the drift command
+type DriftReport struct {
+	certmanager.Drift `yaml:",inline"`
+	Routers           map[string][]string `json:"routers" yaml:"routers"`                   // routers serving each uncovered hostname
+	Pruned            []string            `json:"pruned,omitempty" yaml:"pruned,omitempty"` // orphaned certificates deleted by --prune
+}
+
+func newDriftCommand(opts *options) *cobra.Command {
+	var prune bool
+	cmd := &cobra.Command{
+		Use:   "drift",
+		Short: "Compare the hostnames Traefik routes with the managed certificates",
+		Long: "List hostnames Traefik routes over TLS that no certificate covers, and certificates kept for domains " +
+			"no router serves. With --prune, orphaned certificates that are neither configured nor discovered are deleted. " +
+			"Exits 1 when drift remains.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				timeout, _ := cfg.GetTraefikTimeout()
+				ctx, cancel := context.WithTimeout(cmd.Context(), timeout)
+				defer cancel()
+
+				discovered, err := traefik.NewAPIClient(cfg.TraefikAPI, timeout).DiscoverDomains(ctx)
+				if err != nil {
+					return err
+				}
+
+				report := DriftReport{Drift: certManager.CompareRoutes(routesOf(discovered)), Routers: make(map[string][]string)}
+				for _, d := range discovered {
+					report.Routers[d.Domain] = append(report.Routers[d.Domain], d.Router)
+				}
+				for host := range report.Routers {
+					if !slices.Contains(report.Uncovered, host) {
+						delete(report.Routers, host)
+					}
+				}
+
+				if prune {
+					report.Pruned, err = certManager.PruneOrphans(report.Drift)
+					if err != nil {
+						logger.Printf("Warning: %v", err)
+					}
+				}
+				if err := writeDrift(os.Stdout, opts.output, report); err != nil {
+					return err
+				}
+				if len(report.Uncovered) > 0 || len(report.Orphaned) > len(report.Pruned) {
+					return exitCode(exitDrift)
+				}
+				return nil
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().BoolVar(&prune, "prune", false, "Delete orphaned certificates that are neither configured nor discovered")
+	return cmd
+}
+
+// routesOf returns the hostnames of Traefik's routers as routes
+func routesOf(discovered []traefik.RouterDomain) []certmanager.Route {
+	routes := make([]certmanager.Route, 0, len(discovered))
+	for _, d := range discovered {
+		routes = append(routes, certmanager.Route{Host: d.Domain, TLS: d.TLS})
+	}
+	return routes
+}
+
+// writeDrift prints uncovered hostnames and orphaned certificates
+func writeDrift(w io.Writer, format string, report DriftReport) error {
+	if format != "table" {
+		return writeStructured(w, format, report)
+	}
+	if report.Empty() {
+		fmt.Fprintln(w, "Traefik routes and certificates match")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DRIFT\tDOMAIN\tDETAIL")
+	for _, host := range report.Uncovered {
+		routers := append([]string(nil), report.Routers[host]...)
+		sort.Strings(routers)
+		fmt.Fprintf(tw, "uncovered\t%s\trouted by %s\n", host, strings.Join(routers, ", "))
+	}
+	for _, orphan := range report.Orphaned {
+		detail := "prune with --prune"
+		switch {
+		case slices.Contains(report.Pruned, orphan.Domain):
+			detail = "pruned"
+		case orphan.Configured:
+			detail = "configured; remove it from the configuration to prune it"
+		}
+		fmt.Fprintf(tw, "orphaned\t%s\t%s\n", orphan.Domain, detail)
+	}
+	return tw.Flush()
+}
+
+// watchDrift compares Traefik's routers with the managed certificates every
+// interval and alerts operators about drift, until ctx is done
+func watchDrift(ctx context.Context, client *traefik.APIClient, certManager *certmanager.CertificateManager, interval, timeout time.Duration, logger *log.Logger) {
+	check := func() {
+		checkCtx, cancel := context.WithTimeout(ctx, timeout)
+		defer cancel()
+
+		discovered, err := client.DiscoverDomains(checkCtx)
+		if err != nil {
+			logger.Printf("Warning: failed to check Traefik configuration drift: %v", err)
+			return
+		}
+		certManager.CheckDrift(routesOf(discovered))
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	check()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			check()
+		}
+	}
+}
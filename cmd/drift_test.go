@@ -0,0 +1,53 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestWriteDrift(t *testing.T) {
+	report := DriftReport{
+		Drift: certmanager.Drift{
+			Uncovered: []string{"shop.example.com"},
+			Orphaned: []certmanager.OrphanedCertificate{
+				{Domain: "example.com", Configured: true},
+				{Domain: "old.example.com"},
+			},
+		},
+		Routers: map[string][]string{"shop.example.com": {"shop@docker", "shop-tls@file"}},
+		Pruned:  []string{"old.example.com"},
+	}
+
+	var out bytes.Buffer
+	if err := writeDrift(&out, "table", report); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"uncovered  shop.example.com  routed by shop-tls@file, shop@docker",
+		"orphaned   example.com       configured; remove it from the configuration to prune it",
+		"orphaned   old.example.com   pruned",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("table output does not contain %q:\n%s", want, out.String())
+		}
+	}
+
+	out.Reset()
+	if err := writeDrift(&out, "table", DriftReport{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "Traefik routes and certificates match" {
+		t.Errorf("unexpected output without drift:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := writeDrift(&out, "yaml", report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "uncovered:\n- shop.example.com") {
+		t.Errorf("drift is not inlined in YAML:\n%s", out.String())
+	}
+}
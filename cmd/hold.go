@@ -0,0 +1,76 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newPauseCommand(opts *options) *cobra.Command {
+	var until, reason string
+
+	cmd := &cobra.Command{
+		Use:   "pause DOMAIN",
+		Short: "Hold a domain, pausing its issuance, renewal and notifications",
+		Long: "Hold a domain, e.g. while it migrates to another host: its certificate is neither issued nor renewed " +
+			"and no notifications are sent for it until it is resumed or --until passes. Health and status output " +
+			"show the hold. A running daemon picks it up with its next check.",
+		Args: cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			end, err := parseHoldEnd(until, time.Now())
+			if err != nil {
+				return err
+			}
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				hold, err := certManager.HoldDomain(args[0], end, reason)
+				if err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "%s is %s\n", args[0], hold)
+				return nil
+			})
+		},
+	}
+	cmd.Flags().StringVar(&until, "until", "", "End of the hold: a date (2025-02-01), an RFC 3339 time or a duration (72h); held until resumed when empty")
+	cmd.Flags().StringVar(&reason, "reason", "", "Reason recorded with the hold")
+	return cmd
+}
+
+func newResumeCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "resume DOMAIN",
+		Short: "Release the hold of a domain, resuming its automation",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, false, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				if err := certManager.ReleaseDomain(args[0]); err != nil {
+					return err
+				}
+				fmt.Fprintf(cmd.OutOrStdout(), "Resumed %s\n", args[0])
+				return nil
+			})
+		},
+	}
+}
+
+// parseHoldEnd parses --until as a local date, an RFC 3339 time or a duration
+// from now. An empty value holds the domain until it is resumed.
+func parseHoldEnd(value string, now time.Time) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation("2006-01-02", value, time.Local); err == nil {
+		return t, nil
+	}
+	if t, err := time.Parse(time.RFC3339, value); err == nil {
+		return t, nil
+	}
+	if d, err := time.ParseDuration(value); err == nil && d > 0 {
+		return now.Add(d), nil
+	}
+	return time.Time{}, fmt.Errorf("invalid --until %q, expected a date like 2025-02-01, an RFC 3339 time or a duration like 72h", value)
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseHoldEnd(t *testing.T) {
+	now := time.Date(2025, 1, 10, 12, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		value   string
+		want    time.Time
+		wantErr bool
+	}{
+		{value: "", want: time.Time{}},
+		{value: "2025-02-01", want: time.Date(2025, 2, 1, 0, 0, 0, 0, time.Local)},
+		{value: "2025-02-01T08:00:00Z", want: time.Date(2025, 2, 1, 8, 0, 0, 0, time.UTC)},
+		{value: "72h", want: now.Add(72 * time.Hour)},
+		{value: "-1h", wantErr: true},
+		{value: "next week", wantErr: true},
+	}
+	for _, tt := range tests {
+		got, err := parseHoldEnd(tt.value, now)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseHoldEnd(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			continue
+		}
+		if !got.Equal(tt.want) {
+			t.Errorf("parseHoldEnd(%q) = %v, want %v", tt.value, got, tt.want)
+		}
+	}
+}
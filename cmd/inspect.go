@@ -0,0 +1,78 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/spf13/cobra"
+)
+
+func newInspectCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect DOMAIN",
+		Short: "Show the details of a stored certificate and its chain",
+		Args:  cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				cert, err := certManager.GetCertificate(args[0])
+				if err != nil {
+					return err
+				}
+				inspection, err := cert.Inspect()
+				if err != nil {
+					return fmt.Errorf("failed to inspect certificate for %s: %w", args[0], err)
+				}
+				return writeInspection(os.Stdout, opts.output, inspection)
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	return cmd
+}
+
+// writeInspection prints a certificate inspection as a table, JSON or YAML
+func writeInspection(w io.Writer, format string, inspection *certmanager.CertificateInspection) error {
+	if format != "table" {
+		return writeStructured(w, format, inspection)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	row := func(name, value string) {
+		if value != "" {
+			fmt.Fprintf(tw, "%s:\t%s\n", name, value)
+		}
+	}
+
+	row("Domain", inspection.Domain)
+	row("Subject", inspection.Subject)
+	row("Issuer", inspection.Issuer)
+	row("SANs", strings.Join(inspection.SANs, ", "))
+	row("Serial", inspection.Serial)
+	row("Not before", inspection.NotBefore.UTC().Format(time.RFC3339))
+	row("Not after", inspection.NotAfter.UTC().Format(time.RFC3339))
+	row("Key", fmt.Sprintf("%s %d bits", inspection.KeyAlgorithm, inspection.KeySize))
+	row("Signature", inspection.SignatureAlgorithm)
+	row("SHA-1", inspection.FingerprintSHA1)
+	row("SHA-256", inspection.FingerprintSHA256)
+	row("OCSP", strings.Join(inspection.OCSPServers, ", "))
+	row("CRL", strings.Join(inspection.CRLDistribution, ", "))
+	row("CA issuers", strings.Join(inspection.IssuingCertURL, ", "))
+	for _, sct := range inspection.SCTs {
+		row("SCT", fmt.Sprintf("v%d %s %s", sct.Version, sct.LogID, sct.Timestamp.Format(time.RFC3339)))
+	}
+	for i, issuer := range inspection.Chain {
+		row(fmt.Sprintf("Chain[%d]", i), fmt.Sprintf("%s (expires %s)", issuer.Subject, issuer.NotAfter.UTC().Format(time.RFC3339)))
+	}
+	row("Chain root", inspection.ChainRoot)
+	row("CA", inspection.CA)
+	row("Order", inspection.OrderURL)
+	row("Certificate URL", inspection.CertificateURL)
+	return tw.Flush()
+}
@@ -0,0 +1,33 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestWriteInspection(t *testing.T) {
+	inspection := &certmanager.CertificateInspection{
+		Domain:            "example.com",
+		SANs:              []string{"example.com", "www.example.com"},
+		KeyAlgorithm:      "ECDSA",
+		KeySize:           256,
+		FingerprintSHA256: "AB:CD",
+		Chain:             []certmanager.ChainCertificate{{Subject: "CN=R11"}},
+	}
+
+	var out bytes.Buffer
+	if err := writeInspection(&out, "table", inspection); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"example.com, www.example.com", "ECDSA 256 bits", "SHA-256:", "Chain[0]:", "CN=R11"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("inspection lacks %q:\n%s", want, out.String())
+		}
+	}
+	if strings.Contains(out.String(), "OCSP") {
+		t.Errorf("empty fields should be omitted:\n%s", out.String())
+	}
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/encryption"
+	"github.com/spf13/cobra"
+)
+
+func newInternalCACommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "internal-ca",
+		Short: "Work with the internal CA that signs certificates of domains with issuer internal-ca",
+	}
+	cmd.AddCommand(newInternalCAExportCommand(opts))
+	return cmd
+}
+
+func newInternalCAExportCommand(opts *options) *cobra.Command {
+	var out string
+
+	cmd := &cobra.Command{
+		Use:   "export",
+		Short: "Print the internal CA certificate for client trust stores",
+		Long: "Print the PEM certificate of the internal CA, or write it to --out, so clients can add it to " +
+			"their trust stores. The CA is generated if it doesn't exist yet.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Read from the storage path, without a manager, like maintenance
+			cfg, logger, err := setup(opts, true)
+			if err != nil {
+				return err
+			}
+			envelope, err := encryption.New(cfg.Certificates.Encryption)
+			if err != nil {
+				return fmt.Errorf("failed to set up key encryption: %w", err)
+			}
+			validity, caValidity, err := cfg.GetInternalCAValidity()
+			if err != nil {
+				return fmt.Errorf("invalid internal CA validity: %w", err)
+			}
+
+			ca := certmanager.NewInternalCA(cfg.Certificates.StoragePath, cfg.Certificates.InternalCA.CommonName,
+				validity, caValidity, envelope, logger)
+			certPEM, err := ca.CertificatePEM()
+			if err != nil {
+				return err
+			}
+
+			if out == "" {
+				_, err = cmd.OutOrStdout().Write(certPEM)
+				return err
+			}
+			if err := os.WriteFile(out, certPEM, 0644); err != nil {
+				return fmt.Errorf("failed to write %s: %w", out, err)
+			}
+			logger.Printf("Wrote internal CA certificate to %s", out)
+			return nil
+		},
+	}
+	cmd.Flags().StringVar(&out, "out", "", "File to write the certificate to instead of standard output")
+	return cmd
+}
@@ -0,0 +1,162 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+	"github.com/spf13/cobra"
+)
+
+// CertificateListEntry is one certificate in the output of the list command
+type CertificateListEntry struct {
+	status.CertificateReport `yaml:",inline"`
+	KeyType                  string `json:"key_type" yaml:"key_type"`
+	CertPath                 string `json:"cert_path" yaml:"cert_path"`
+	KeyPath                  string `json:"key_path" yaml:"key_path"`
+}
+
+// listFilter selects and orders the certificates printed by the list command
+type listFilter struct {
+	expiringWithin time.Duration // zero lists certificates regardless of expiry
+	status         string        // empty lists every status
+	sortBy         string        // domain or expiry
+	group          string        // empty lists every group
+	groupFor       func(domain string) string
+}
+
+func newListCommand(opts *options) *cobra.Command {
+	var expiringWithin, status, sortBy, group string
+
+	cmd := &cobra.Command{
+		Use:   "list",
+		Short: "List managed certificates",
+		Example: "  cert-manager list --expiring-within 14d --status needs_renewal --sort expiry\n" +
+			"  cert-manager list --group production --output json",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			filter := listFilter{status: status, sortBy: sortBy, group: group}
+			if expiringWithin != "" {
+				within, err := parseWindow(expiringWithin)
+				if err != nil {
+					return fmt.Errorf("invalid --expiring-within: %w", err)
+				}
+				filter.expiringWithin = within
+			}
+			switch status {
+			case "", "valid", "needs_renewal", "expired":
+			default:
+				return fmt.Errorf("invalid --status %q, expected valid, needs_renewal or expired", status)
+			}
+			switch sortBy {
+			case "domain", "expiry":
+			default:
+				return fmt.Errorf("invalid --sort %q, expected domain or expiry", sortBy)
+			}
+
+			if opts.server != "" {
+				ctx, cancel := context.WithTimeout(cmd.Context(), remoteQueryTimeout)
+				defer cancel()
+				details, err := remoteClient(opts).Certificates(ctx, group)
+				if err != nil {
+					return err
+				}
+				// The daemon already selected the certificates of the group
+				filter.group = ""
+				return writeCertificateList(os.Stdout, opts.output, filterCertificateList(details, filter, time.Now()))
+			}
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				filter.groupFor = cfg.GroupFor
+				entries := filterCertificateList(certManager.CertificateDetails(), filter, time.Now())
+				return writeCertificateList(os.Stdout, opts.output, entries)
+			})
+		},
+	}
+	cmd.Flags().StringVar(&expiringWithin, "expiring-within", "", "Only list certificates expiring within this period, e.g. 14d or 36h")
+	cmd.Flags().StringVar(&status, "status", "", "Only list certificates with this status: valid, needs_renewal or expired")
+	cmd.Flags().StringVar(&sortBy, "sort", "domain", "Sort by domain or expiry")
+	cmd.Flags().StringVar(&group, "group", "", "Only list certificates of the domains of this group")
+	addOutputFlag(cmd, opts)
+	return cmd
+}
+
+// parseWindow accepts a number of days such as "14d" or a Go duration
+func parseWindow(value string) (time.Duration, error) {
+	if days, ok := strings.CutSuffix(value, "d"); ok {
+		n, err := strconv.Atoi(days)
+		if err != nil || n < 0 {
+			return 0, fmt.Errorf("%q is not a number of days", value)
+		}
+		return time.Duration(n) * 24 * time.Hour, nil
+	}
+
+	window, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, err
+	}
+	if window < 0 {
+		return 0, fmt.Errorf("%q must not be negative", value)
+	}
+	return window, nil
+}
+
+// filterCertificateList applies the filter to the stored certificates and orders them
+func filterCertificateList(details []certmanager.CertificateDetails, filter listFilter, now time.Time) []CertificateListEntry {
+	entries := make([]CertificateListEntry, 0, len(details))
+	for _, detail := range details {
+		if filter.status != "" && detail.Status != filter.status {
+			continue
+		}
+		if filter.expiringWithin > 0 && detail.ExpiresAt.After(now.Add(filter.expiringWithin)) {
+			continue
+		}
+		if filter.group != "" && filter.groupFor(detail.Domain) != filter.group {
+			continue
+		}
+		entries = append(entries, CertificateListEntry{
+			CertificateReport: status.NewCertificateReport(detail.CertificateHealth),
+			KeyType:           detail.KeyType,
+			CertPath:          detail.CertPath,
+			KeyPath:           detail.KeyPath,
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool {
+		if filter.sortBy == "expiry" && !entries[i].ExpiresAt.Equal(entries[j].ExpiresAt) {
+			return entries[i].ExpiresAt.Before(entries[j].ExpiresAt)
+		}
+		return entries[i].Domain < entries[j].Domain
+	})
+	return entries
+}
+
+// writeCertificateList prints one line or entry per listed certificate
+func writeCertificateList(w io.Writer, format string, entries []CertificateListEntry) error {
+	if format != "table" {
+		return writeStructured(w, format, entries)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tSTATUS\tSANS\tISSUER\tKEY\tISSUED\tEXPIRES\tDAYS\tLOCATION")
+	for _, entry := range entries {
+		status := entry.Status
+		if entry.Hold != nil {
+			status += " (held)"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\t%s\t%s\t%d\t%s\n", entry.Domain, status,
+			strings.Join(entry.SANs, ","), entry.Issuer, entry.KeyType,
+			entry.IssuedAt.Format(time.RFC3339), entry.ExpiresAt.Format(time.RFC3339),
+			entry.DaysUntilExpiry, entry.CertPath)
+	}
+	return tw.Flush()
+}
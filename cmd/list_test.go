@@ -0,0 +1,114 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func testCertificateDetails(now time.Time) []certmanager.CertificateDetails {
+	detail := func(domain, status string, days int) certmanager.CertificateDetails {
+		return certmanager.CertificateDetails{
+			CertificateHealth: certmanager.CertificateHealth{
+				Domain:    domain,
+				Status:    status,
+				ExpiresAt: now.Add(time.Duration(days) * 24 * time.Hour),
+				SANs:      []string{domain},
+				Issuer:    "R11",
+			},
+			KeyType:  "EC256",
+			CertPath: "/certs/" + domain + ".crt",
+		}
+	}
+	return []certmanager.CertificateDetails{
+		detail("c.example.com", "needs_renewal", 10),
+		detail("a.example.com", "valid", 60),
+		detail("b.example.com", "needs_renewal", 5),
+	}
+}
+
+func TestFilterCertificateList(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	tests := []struct {
+		name   string
+		filter listFilter
+		want   []string
+	}{
+		{"all by domain", listFilter{sortBy: "domain"}, []string{"a.example.com", "b.example.com", "c.example.com"}},
+		{"all by expiry", listFilter{sortBy: "expiry"}, []string{"b.example.com", "c.example.com", "a.example.com"}},
+		{"expiring within 14 days", listFilter{expiringWithin: 14 * 24 * time.Hour, sortBy: "domain"}, []string{"b.example.com", "c.example.com"}},
+		{"status", listFilter{status: "valid", sortBy: "domain"}, []string{"a.example.com"}},
+		{"group", listFilter{group: "staging", groupFor: func(domain string) string {
+			if domain == "a.example.com" {
+				return ""
+			}
+			return "staging"
+		}, sortBy: "domain"}, []string{"b.example.com", "c.example.com"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			entries := filterCertificateList(testCertificateDetails(now), tt.filter, now)
+			var got []string
+			for _, entry := range entries {
+				got = append(got, entry.Domain)
+			}
+			if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+				t.Errorf("listed %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestParseExpiryWindow(t *testing.T) {
+	tests := []struct {
+		value   string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"14d", 14 * 24 * time.Hour, false},
+		{"36h", 36 * time.Hour, false},
+		{"xd", 0, true},
+		{"-1h", 0, true},
+		{"soon", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseWindow(tt.value)
+		if (err != nil) != tt.wantErr || got != tt.want {
+			t.Errorf("parseWindow(%q) = %v, %v", tt.value, got, err)
+		}
+	}
+}
+
+func TestWriteCertificateList(t *testing.T) {
+	now := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	entries := filterCertificateList(testCertificateDetails(now), listFilter{sortBy: "domain"}, now)
+
+	var table bytes.Buffer
+	if err := writeCertificateList(&table, "table", entries); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"LOCATION", "R11", "EC256", "/certs/a.example.com.crt"} {
+		if !strings.Contains(table.String(), want) {
+			t.Errorf("table output lacks %q:\n%s", want, table.String())
+		}
+	}
+
+	var out bytes.Buffer
+	if err := writeCertificateList(&out, "json", entries); err != nil {
+		t.Fatal(err)
+	}
+	var decoded []map[string]interface{}
+	if err := json.Unmarshal(out.Bytes(), &decoded); err != nil {
+		t.Fatal(err)
+	}
+	if len(decoded) != 3 || decoded[0]["domain"] != "a.example.com" || decoded[0]["key_type"] != "EC256" {
+		t.Errorf("unexpected JSON output: %s", out.String())
+	}
+}
@@ -1,17 +1,32 @@
 package main
 
 import (
+	"bytes"
 	"context"
-	"flag"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 	"os"
 	"os/signal"
+	"strings"
+	"sync/atomic"
 	"syscall"
 	"time"
 
+	"github.com/O-tero/traefik-cert-manager/internal/api"
 	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
 	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/debug"
+	"github.com/O-tero/traefik-cert-manager/internal/discovery"
+	"github.com/O-tero/traefik-cert-manager/internal/health"
+	"github.com/O-tero/traefik-cert-manager/internal/logfile"
+	"github.com/O-tero/traefik-cert-manager/internal/metrics"
+	"github.com/O-tero/traefik-cert-manager/internal/sds"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+	"github.com/O-tero/traefik-cert-manager/internal/systemd"
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
 	"github.com/O-tero/traefik-cert-manager/internal/traefik"
 )
 
@@ -20,176 +35,815 @@ const (
 	version           = "1.0.0"
 )
 
+var (
+	configInfo = metrics.NewGauge("certmanager_config_info",
+		"Hash of the effective configuration, for drift detection across instances.", "hash")
+	traefikUp = metrics.NewGauge("certmanager_traefik_up",
+		"Whether the Traefik API answered the last connection attempt.")
+)
+
+// exitCode ends a command with a status code without printing an error
+type exitCode int
+
+func (c exitCode) Error() string {
+	return fmt.Sprintf("exit status %d", int(c))
+}
+
 func main() {
-	var (
-		configPath  = flag.String("config", defaultConfigPath, "Path to configuration file")
-		showVersion = flag.Bool("version", false, "Show version information")
-		runOnce     = flag.Bool("once", false, "Run certificate check once and exit")
-		verbose     = flag.Bool("verbose", false, "Enable verbose logging")
-		checkHealth = flag.Bool("health", false, "Check certificate health and exit")
-	)
-	flag.Parse()
-
-	if *showVersion {
-		fmt.Printf("Traefik Certificate Manager v%s\n", version)
-		return
+	root := newRootCommand()
+	root.SetArgs(translateLegacyArgs(os.Args[1:], os.Stderr))
+
+	// Interrupting a command cancels its pending ACME orders, which are resumed
+	// by the next run
+	ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+	err := root.ExecuteContext(ctx)
+	stop()
+
+	var code exitCode
+	switch {
+	case err == nil:
+	case errors.As(err, &code):
+		os.Exit(int(code))
+	default:
+		message, status := failure(err)
+		fmt.Fprintln(os.Stderr, message)
+		os.Exit(status)
+	}
+}
+
+// classExitCodes end commands failing with an error of a known class with a
+// status of their own, so scripts can branch on the class. Other errors exit
+// with 1.
+var classExitCodes = map[string]int{
+	certmanager.CodeRateLimited:        10,
+	certmanager.CodeChallengeFailed:    11,
+	certmanager.CodeStorage:            12,
+	certmanager.CodeTraefikUnreachable: 13,
+	certmanager.CodeMaintenance:        14,
+	certmanager.CodeLocked:             15,
+	certmanager.CodeNotFound:           16,
+}
+
+// failure returns the message printed for a command that failed with err, and
+// its exit status
+func failure(err error) (string, int) {
+	code := certmanager.ErrorCode(err)
+	if status, ok := classExitCodes[code]; ok {
+		return fmt.Sprintf("Error [%s]: %v", code, err), status
 	}
+	return fmt.Sprintf("Error: %v", err), 1
+}
 
-	// Setup logging
+// setup creates the logger, loads the configuration and prepares the storage
+// path. Commands that print reports for scripts log to stderr instead of stdout.
+func setup(opts *options, logToStderr bool) (*config.Config, *log.Logger, error) {
 	logLevel := log.LstdFlags
-	if *verbose {
+	if opts.verbose {
 		logLevel = log.LstdFlags | log.Lshortfile
 	}
-	logger := log.New(os.Stdout, "[CertManager] ", logLevel)
-
-	logger.Printf("Starting Traefik Certificate Manager v%s", version)
+	var logOutput io.Writer = os.Stdout
+	if opts.logOutput != nil {
+		logOutput = opts.logOutput
+	} else if logToStderr {
+		logOutput = os.Stderr
+	}
+	logger := log.New(logOutput, "[CertManager] ", logLevel)
 
 	// Load configuration
-	cfg, err := config.LoadConfig(*configPath)
+	cfg, err := config.LoadConfig(opts.configPath)
 	if err != nil {
-		logger.Fatalf("Failed to load configuration: %v", err)
+		return nil, nil, fmt.Errorf("failed to load configuration: %w", err)
+	}
+	if err := useACMECA(cfg, opts.acmeCA); err != nil {
+		return nil, nil, err
 	}
 
-	logger.Printf("Configuration loaded from: %s", *configPath)
+	if cfg.App.LogFile.Path != "" {
+		out, err := newLogFile(cfg.App.LogFile)
+		if err != nil {
+			return nil, nil, err
+		}
+		logger.SetOutput(out)
+	}
+
+	logger.Printf("Starting Traefik Certificate Manager v%s", version)
+	logger.Printf("Configuration loaded from: %s", opts.configPath)
+	logger.Printf("Configuration hash: %s", cfg.Hash())
+	configInfo.Set(1, cfg.Hash())
 	logger.Printf("ACME CA: %s", cfg.ACME.CADirURL)
+	if opts.acmeCA != "" {
+		logger.Printf("Warning: ordering from the %s test CA, whose certificates no client trusts", opts.acmeCA)
+	}
 	logger.Printf("Storage path: %s", cfg.Certificates.StoragePath)
 	logger.Printf("Renewal threshold: %d days", cfg.Certificates.RenewalDays)
 
 	// Ensure storage directory exists
 	if err := os.MkdirAll(cfg.Certificates.StoragePath, 0755); err != nil {
-		logger.Fatalf("Failed to create storage directory: %v", err)
+		return nil, nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	// Upgrade the storage layout before anything reads it
+	if err := migrateStorage(cfg.Certificates.StoragePath, opts.noMigrate, logger); err != nil {
+		return nil, nil, fmt.Errorf("storage migration failed: %w", err)
+	}
+
+	return cfg, logger, nil
+}
+
+// newLogFile returns the rotated log file configured in app.log_file
+func newLogFile(cfg config.LogFile) (*logfile.Writer, error) {
+	// Durations were checked when the configuration was loaded
+	interval, _ := time.ParseDuration(cfg.RotateInterval)
+	maxAge, _ := time.ParseDuration(cfg.MaxAge)
+	out := logfile.New(cfg.Path, logfile.Options{
+		MaxSize:    int64(cfg.MaxSizeMB) << 20,
+		Interval:   interval,
+		MaxBackups: cfg.MaxBackups,
+		MaxAge:     maxAge,
+	})
+	if err := out.Open(); err != nil {
+		return nil, fmt.Errorf("failed to set up log file: %w", err)
 	}
+	return out, nil
+}
+
+// tracingShutdownTimeout bounds the export of the last spans on exit
+const tracingShutdownTimeout = 10 * time.Second
 
-	// Create certificate manager
-	certManager, err := certmanager.NewCertificateManager(cfg, logger)
+// startTracing exports spans to the configured OTLP endpoint, if any. The
+// returned function exports the spans still queued and stops tracing.
+func startTracing(cfg config.Tracing, logger *log.Logger) func() {
+	if !cfg.Enabled() {
+		return func() {}
+	}
+	tracer, err := tracing.NewTracer(cfg, logger)
 	if err != nil {
-		logger.Fatalf("Failed to create certificate manager: %v", err)
+		logger.Printf("Warning: tracing disabled: %v", err)
+		return func() {}
 	}
+	tracing.SetTracer(tracer)
+	logger.Printf("Exporting traces to %s", cfg.Endpoint)
+
+	return func() {
+		tracing.SetTracer(nil)
+		ctx, cancel := context.WithTimeout(context.Background(), tracingShutdownTimeout)
+		defer cancel()
+		if err := tracer.Shutdown(ctx); err != nil {
+			logger.Printf("Warning: %v", err)
+		}
+	}
+}
 
-	// Create Traefik API client
-	timeout, _ := cfg.GetTimeout()
-	traefikClient := traefik.NewAPIClient(cfg.TraefikAPI, timeout)
+// newCertificateManager creates the certificate manager and reports storage and chain problems
+func newCertificateManager(cfg *config.Config, logger *log.Logger) (*certmanager.CertificateManager, error) {
+	certManager, err := certmanager.NewCertificateManager(cfg, metrics.DefaultRegistry, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create certificate manager: %w", err)
+	}
 
-	ctx, cancel := context.WithTimeout(context.Background(), timeout)
-	if err := traefikClient.IsHealthy(ctx); err != nil {
-		logger.Fatalf("Failed to connect to Traefik API: %v", err)
+	certManager.CheckStorage()
+	certManager.CheckChains()
+	return certManager, nil
+}
+
+// runDaemon manages certificates continuously until ctx is done, on SIGINT or
+// SIGTERM or when the Windows service is stopped
+func runDaemon(ctx context.Context, opts *options) error {
+	cfg, logger, err := setup(opts, false)
+	if err != nil {
+		return err
 	}
-	cancel()
-	logger.Printf("Connected to Traefik API: %s", cfg.TraefikAPI)
+	defer startTracing(cfg.Tracing, logger)()
 
-	if *checkHealth {
-		runHealthCheck(certManager, logger)
-		return
+	certManager, err := newCertificateManager(cfg, logger)
+	if err != nil {
+		return err
 	}
+	reportUnmanagedCertificates(certManager, logger)
 
-	if *runOnce {
-		runOnceMode(certManager, logger)
-		return
+	apiSocket, err := apiListener(cfg.API.Enabled, logger)
+	if err != nil {
+		return err
 	}
 
+	// Create Traefik API client
+	timeout, _ := cfg.GetTraefikTimeout()
+	traefikClient := traefik.NewAPIClient(cfg.TraefikAPI, timeout)
+
+	// Renewal works from storage without Traefik, so an unreachable API degrades
+	// the daemon instead of stopping it
+	traefikConnected := connectTraefik(ctx, traefikClient, cfg, logger)
+
 	// Create and start scheduler for continuous operation
 	scheduler, err := certmanager.NewScheduler(cfg, certManager, logger)
 	if err != nil {
-		logger.Fatalf("Failed to create scheduler: %v", err)
+		return fmt.Errorf("failed to create scheduler: %w", err)
 	}
 
-	logger.Printf("Processing initial certificates...")
-	ctx, cancel = context.WithTimeout(context.Background(), 5*time.Minute)
-	if err := certManager.ProcessAllDomains(ctx); err != nil {
-		logger.Printf("Warning: Failed to process some domains: %v", err)
+	// Serve health checks before the initial run so orchestrators see the daemon alive
+	var healthServer *health.Server
+	if cfg.Health.Enabled {
+		healthServer = startHealthServer(cfg.Health.ListenAddress, certManager, traefikClient, logger)
+	}
+
+	// Keep systemd from restarting the daemon while it works, and from timing
+	// out its start during the initial run
+	var schedulerStarted atomic.Bool
+	watchdogCtx, stopWatchdog := context.WithCancel(context.Background())
+	defer stopWatchdog()
+	go systemd.Watchdog(watchdogCtx, func() error {
+		if schedulerStarted.Load() && !scheduler.IsRunning() {
+			return fmt.Errorf("scheduler is not running")
+		}
+		return nil
+	}, logger.Printf)
+
+	if cfg.ACME.HTTP01.CheckReachability {
+		checkHTTP01Reachability(ctx, certManager, logger)
+	}
+
+	if cfg.ACME.BulkIssuance.Enabled {
+		bulk, err := certmanager.NewBulkIssuer(certManager, cfg.ACME.BulkIssuance, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create bulk issuer: %w", err)
+		}
+		logger.Printf("Issuing initial certificates in the background at up to %d orders per hour", cfg.ACME.BulkIssuance.OrdersPerHour)
+		go func() {
+			if err := bulk.Run(ctx); err != nil && !errors.Is(err, context.Canceled) {
+				logger.Printf("Bulk issuance stopped: %v", err)
+			}
+		}()
+	} else {
+		logger.Printf("Processing initial certificates...")
+		runTimeout, _ := cfg.GetRunTimeout()
+		notifySystemd(logger, fmt.Sprintf("EXTEND_TIMEOUT_USEC=%d", runTimeout.Microseconds()))
+		notifySystemd(logger, "STATUS=Processing initial certificates")
+		runCtx, cancel := context.WithTimeout(ctx, runTimeout)
+		if err := certManager.ProcessAllDomains(runCtx); err != nil {
+			logger.Printf("Warning: Failed to process some domains: %v", err)
+		}
+		cancel()
+	}
+
+	// Expose metrics for scraping
+	var metricsServer *http.Server
+	if cfg.Metrics.Enabled {
+		metricsServer = startMetricsServer(cfg.Metrics.ListenAddress, logger)
+	}
+
+	var debugServer *debug.Server
+	if cfg.Debug.Enabled {
+		debugServer = debug.NewServer(cfg.Debug.ListenAddress, logger)
+		debugServer.Publish(certManager.DebugVars())
+		debugServer.Publish(scheduler.DebugVars())
+		debugServer.Start()
+	}
+
+	var apiServer *api.Server
+	if cfg.API.Enabled {
+		apiServer = api.NewServer(cfg.API, certManager, scheduler, logger)
+		apiServer.UseConfigHash(cfg.Hash())
+		if apiSocket != nil {
+			apiServer.UseListener(apiSocket)
+		}
+		if history := certManager.History(); history != nil {
+			apiServer.UseHistory(history)
+		}
+		apiServer.Start()
+	}
+
+	// Serve certificates to Envoy and other proxies that can't read Traefik's
+	var sdsServer *sds.Server
+	if cfg.SDS.Enabled {
+		sdsServer, err = sds.NewServer(cfg.SDS, sdsSecrets(certManager), logger)
+		if err != nil {
+			return fmt.Errorf("failed to create SDS server: %w", err)
+		}
+		if err := sdsServer.Start(); err != nil {
+			return err
+		}
 	}
-	cancel()
 
 	// Start the scheduler
+	scheduler.OnRun(func(summary *certmanager.RunSummary) {
+		notifySystemd(logger, runStatus(summary, scheduler.GetNextRunTime()))
+	})
 	if err := scheduler.Start(); err != nil {
-		logger.Fatalf("Failed to start scheduler: %v", err)
+		return fmt.Errorf("failed to start scheduler: %w", err)
+	}
+	schedulerStarted.Store(true)
+	if healthServer != nil {
+		healthServer.AddLivenessCheck("scheduler", func(ctx context.Context) error {
+			if !scheduler.IsRunning() {
+				return fmt.Errorf("scheduler is not running")
+			}
+			return nil
+		})
+		if cfg.App.Strict {
+			healthServer.AddReadinessCheck("last_run", scheduler.CheckLastRun)
+		}
 	}
 
-	// Setup graceful shutdown
-	sigChan := make(chan os.Signal, 1)
-	signal.Notify(sigChan, syscall.SIGINT, syscall.SIGTERM)
+	// Watch dynamic domain sources
+	discoveryCtx, stopDiscovery := context.WithCancel(context.Background())
+	defer stopDiscovery()
+
+	if !traefikConnected {
+		interval, _ := cfg.GetReconnectInterval()
+		go traefikClient.Reconnect(discoveryCtx, interval, func() {
+			logger.Printf("Reconnected to Traefik API: %s", cfg.TraefikAPI)
+			traefikUp.Set(1)
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+			reportDiscoveredDomains(ctx, traefikClient, cfg, logger)
+		})
+	}
+	if cfg.Traefik.Drift.Enabled {
+		interval, _ := cfg.GetDriftInterval()
+		go watchDrift(discoveryCtx, traefikClient, certManager, interval, timeout, logger)
+	}
+	if cfg.Discovery.Docker.Enabled {
+		provider, err := discovery.NewDockerProvider(cfg.Discovery.Docker, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create Docker discovery provider: %w", err)
+		}
+		startDiscovery(discoveryCtx, provider, certManager, logger)
+	}
+	if cfg.Discovery.Vhosts.Enabled {
+		provider, err := discovery.NewVhostProvider(cfg.Discovery.Vhosts, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create vhost discovery provider: %w", err)
+		}
+		startDiscovery(discoveryCtx, provider, certManager, logger)
+	}
+	if cfg.Discovery.DNSZones.Enabled {
+		provider, err := discovery.NewDNSZoneProvider(cfg.Discovery.DNSZones, logger)
+		if err != nil {
+			return fmt.Errorf("failed to create DNS zone discovery provider: %w", err)
+		}
+		if cfg.Discovery.DNSZones.Issue {
+			startDiscovery(discoveryCtx, provider, certManager, logger)
+		} else {
+			proposeDiscoveredDomains(discoveryCtx, provider, certManager, logger)
+		}
+	}
+
+	if !cfg.Certificates.DisableWatch {
+		go func() {
+			if err := certManager.WatchStorage(discoveryCtx); err != nil {
+				logger.Printf("Warning: certificates replaced in storage won't be reloaded: %v", err)
+			}
+		}()
+	}
 
 	logger.Printf("Certificate manager started successfully")
 	logger.Printf("Next check scheduled for: %s", scheduler.GetNextRunTime().Format(time.RFC3339))
+	notifySystemd(logger, "READY=1\nSTATUS=Next check at "+scheduler.GetNextRunTime().Format(time.RFC3339))
 
-	// Wait for shutdown signal
-	<-sigChan
+	// Wait for shutdown
+	<-ctx.Done()
 	logger.Printf("Shutdown signal received, stopping...")
+	notifySystemd(logger, "STOPPING=1")
+	stopWatchdog()
 
 	// Graceful shutdown
+	stopDiscovery()
 	if err := scheduler.Stop(); err != nil {
 		logger.Printf("Error stopping scheduler: %v", err)
 	}
 
+	if metricsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := metricsServer.Shutdown(ctx); err != nil {
+			logger.Printf("Error stopping metrics server: %v", err)
+		}
+		cancel()
+	}
+
+	if debugServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := debugServer.Shutdown(ctx); err != nil {
+			logger.Printf("Error stopping debug server: %v", err)
+		}
+		cancel()
+	}
+
+	if healthServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := healthServer.Shutdown(ctx); err != nil {
+			logger.Printf("Error stopping health server: %v", err)
+		}
+		cancel()
+	}
+
+	if apiServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := apiServer.Shutdown(ctx); err != nil {
+			logger.Printf("Error stopping management API: %v", err)
+		}
+		cancel()
+	}
+
+	if sdsServer != nil {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		if err := sdsServer.Shutdown(ctx); err != nil {
+			logger.Printf("Error stopping SDS server: %v", err)
+		}
+		cancel()
+	}
+
 	logger.Printf("Certificate manager stopped")
+	return nil
 }
 
-// runHealthCheck performs a health check and displays certificate status
-func runHealthCheck(certManager *certmanager.CertificateManager, logger *log.Logger) {
-	logger.Printf("Running certificate health check...")
+// sdsSecrets serves every managed certificate and its key as a secret named
+// after its domain
+func sdsSecrets(certManager *certmanager.CertificateManager) sds.Source {
+	return func() []sds.Secret {
+		pairs := certManager.KeyPairs()
+		secrets := make([]sds.Secret, 0, len(pairs))
+		for domain, pair := range pairs {
+			secrets = append(secrets, sds.Secret{Name: domain, CertificateChain: pair.Certificate, PrivateKey: pair.PrivateKey})
+		}
+		return secrets
+	}
+}
+
+// migrateStorage applies pending storage migrations unless disabled
+func migrateStorage(storagePath string, noMigrate bool, logger *log.Logger) error {
+	pending, err := certmanager.PendingStorageMigrations(storagePath)
+	if err != nil {
+		return err
+	}
 
-	health := certManager.CheckCertificateHealth()
-	if len(health) == 0 {
-		logger.Printf("No certificates found")
+	if len(pending) > 0 && noMigrate {
+		for _, migration := range pending {
+			logger.Printf("Pending storage migration %d: %s", migration.Version, migration.Description)
+		}
+		return fmt.Errorf("storage at %s needs %d migrations and --no-migrate is set", storagePath, len(pending))
+	}
+
+	return certmanager.MigrateStorage(storagePath, logger)
+}
+
+// setMaintenance switches maintenance mode through the storage path, which a
+// running daemon picks up before its next certificate operation
+func setMaintenance(cfg *config.Config, mode, reason string, logger *log.Logger) error {
+	// The daemon exports the state as a metric once it reads the file
+	m := certmanager.NewMaintenance(cfg.Certificates.StoragePath, cfg.App.Maintenance, nil)
+
+	switch mode {
+	case "on":
+		if err := m.Enable(reason); err != nil {
+			return err
+		}
+		logger.Printf("Maintenance mode enabled; issuance, renewal and deployment are paused")
+	case "off":
+		if err := m.Disable(); err != nil {
+			return err
+		}
+		logger.Printf("Maintenance mode disabled; certificate automation resumes")
+	case "status":
+	default:
+		return fmt.Errorf("unknown mode %q, expected on, off or status", mode)
+	}
+
+	state := m.State()
+	if !state.Enabled {
+		logger.Printf("Maintenance mode: off")
+		return nil
+	}
+	logger.Printf("Maintenance mode: on (source: %s, reason: %s)", state.Source, state.Reason)
+	return nil
+}
+
+// setACMEDebug switches ACME wire logging of a domain through the storage path
+func setACMEDebug(w io.Writer, cfg *config.Config, mode, domain string) error {
+	debug := certmanager.NewWireDebug(cfg.Certificates.StoragePath, cfg.ACME.WireLog.Domains)
+
+	switch mode {
+	case "on":
+		if err := debug.Enable(domain); err != nil {
+			return err
+		}
+	case "off":
+		if err := debug.Disable(domain); err != nil {
+			return err
+		}
+	case "status":
+	default:
+		return fmt.Errorf("unknown mode %q, expected on, off or status", mode)
+	}
+
+	domains := debug.Domains()
+	if len(domains) == 0 {
+		fmt.Fprintln(w, "ACME wire logging: off")
+		return nil
+	}
+	fmt.Fprintf(w, "ACME wire logging: %s (%s)\n", strings.Join(domains, ", "), cfg.WireLogPath())
+	return nil
+}
+
+// importCertificate brings an externally issued certificate under management
+func importCertificate(certManager *certmanager.CertificateManager, certPath, keyPath string, logger *log.Logger) error {
+	if keyPath == "" {
+		return fmt.Errorf("--key is required with --cert")
+	}
+
+	certPEM, err := os.ReadFile(certPath)
+	if err != nil {
+		return fmt.Errorf("failed to read certificate: %w", err)
+	}
+	keyPEM, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read private key: %w", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), time.Minute)
+	defer cancel()
+
+	domain, err := certManager.ImportCertificate(ctx, certPEM, keyPEM)
+	if err != nil {
+		return err
+	}
+
+	logger.Printf("Imported certificate for %s; it will be renewed from the next run", domain)
+	return nil
+}
+
+// adoptCertificates brings the named unmanaged certificates, or all of them, under management
+func adoptCertificates(certManager *certmanager.CertificateManager, names string, logger *log.Logger) error {
+	var domains []string
+	if names == "all" {
+		for domain := range certManager.UnmanagedCertificates() {
+			domains = append(domains, domain)
+		}
+	} else {
+		for _, domain := range strings.Split(names, ",") {
+			if domain = strings.TrimSpace(domain); domain != "" {
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	if len(domains) == 0 {
+		logger.Printf("No unmanaged certificates to adopt")
+		return nil
+	}
+
+	for _, domain := range domains {
+		if err := certManager.AdoptCertificate(domain); err != nil {
+			return err
+		}
+	}
+
+	logger.Printf("Adopted %d certificates; they will be renewed from the next run", len(domains))
+	return nil
+}
+
+// runRefreshChains replaces stored issuer chains with those currently published by the CAs
+func runRefreshChains(certManager *certmanager.CertificateManager, logger *log.Logger) error {
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Minute)
+	defer cancel()
+
+	var domains []string
+	for domain := range certManager.ListCertificates() {
+		domains = append(domains, domain)
+	}
+
+	refreshed, err := certManager.RefreshChains(ctx, domains)
+	logger.Printf("Refreshed issuer chains for %d of %d certificates", len(refreshed), len(domains))
+	if err != nil {
+		return fmt.Errorf("chain refresh failed: %w", err)
+	}
+	return nil
+}
+
+// reportUnmanagedCertificates offers to adopt certificates that would otherwise expire unnoticed
+func reportUnmanagedCertificates(certManager *certmanager.CertificateManager, logger *log.Logger) {
+	for domain, cert := range certManager.UnmanagedCertificates() {
+		logger.Printf("Certificate for %s is on disk but not managed and will not be renewed (expires: %s); run \"traefik-cert-manager adopt %s\" to manage it",
+			domain, cert.ExpiresAt.Format(time.RFC3339), domain)
+	}
+}
+
+// checkHTTP01Reachability warns about domains whose challenge path the CA
+// can't reach, before their orders fail validation
+func checkHTTP01Reachability(ctx context.Context, certManager *certmanager.CertificateManager, logger *log.Logger) {
+	logger.Printf("Checking that the HTTP-01 challenge path is reachable...")
+	unreachable, err := certManager.CheckHTTP01Reachability(ctx)
+	if err != nil {
+		logger.Printf("Warning: Failed to check HTTP-01 reachability: %v", err)
+	}
+	for _, diagnosis := range unreachable {
+		logger.Printf("Warning: HTTP-01 validation of %s will fail: %s", diagnosis.Domain, diagnosis)
+	}
+}
+
+// connectTraefik waits for the Traefik API with backoff, until ctx is done, and
+// reports whether it answered. Without it the daemon runs degraded, renewing
+// from storage.
+func connectTraefik(ctx context.Context, client *traefik.APIClient, cfg *config.Config, logger *log.Logger) bool {
+	backoff, _ := cfg.GetStartupBackoff()
+	if err := client.WaitHealthy(ctx, cfg.App.StartupRetries, backoff, logger); err != nil {
+		logger.Printf("Warning: Traefik API is unreachable, running in degraded mode: %v", err)
+		traefikUp.Set(0)
+		return false
+	}
+
+	logger.Printf("Connected to Traefik API: %s", cfg.TraefikAPI)
+	traefikUp.Set(1)
+
+	timeout, _ := cfg.GetTraefikTimeout()
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	reportDiscoveredDomains(ctx, client, cfg, logger)
+
+	return true
+}
+
+// reportDiscoveredDomains logs HTTP and TCP router hostnames that have no configured certificate
+func reportDiscoveredDomains(ctx context.Context, client *traefik.APIClient, cfg *config.Config, logger *log.Logger) {
+	discovered, err := client.DiscoverDomains(ctx)
+	if err != nil {
+		logger.Printf("Warning: failed to discover router domains: %v", err)
 		return
 	}
 
-	logger.Printf("Certificate Health Report:")
-	logger.Printf("========================")
+	managed := make(map[string]bool)
+	for _, domain := range cfg.GetAllDomains() {
+		managed[strings.ToLower(domain)] = true
+	}
 
-	var validCount, renewalCount, expiredCount int
+	for _, d := range discovered {
+		if !managed[d.Domain] {
+			logger.Printf("Discovered unmanaged domain %s on %s router %s (service: %s)",
+				d.Domain, d.Protocol, d.Router, d.Service)
+		}
+	}
+}
+
+// startDiscovery keeps the certificate manager in sync with a discovery provider in the background
+func startDiscovery(ctx context.Context, provider discovery.Provider, certManager *certmanager.CertificateManager, logger *log.Logger) {
+	logger.Printf("Starting %s domain discovery", provider.Name())
 
-	for domain, status := range health {
-		logger.Printf("Domain: %s", domain)
-		logger.Printf("  Status: %s", status.Status)
-		logger.Printf("  Issued: %s", status.IssuedAt.Format(time.RFC3339))
-		logger.Printf("  Expires: %s", status.ExpiresAt.Format(time.RFC3339))
-		logger.Printf("  Days until expiry: %d", status.DaysUntilExpiry)
-		logger.Printf("  Needs renewal: %t", status.NeedsRenewal)
-		logger.Printf("  Is expired: %t", status.IsExpired)
-		logger.Printf("")
+	go func() {
+		err := provider.Watch(ctx, func(domains []config.Domain) {
+			certManager.SyncDiscoveredDomains(ctx, provider.Name(), domains)
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Printf("%s discovery stopped: %v", provider.Name(), err)
+		}
+	}()
+}
 
-		switch status.Status {
-		case "valid":
-			validCount++
-		case "needs_renewal":
-			renewalCount++
-		case "expired":
-			expiredCount++
+// proposeDiscoveredDomains logs domains found by a provider that have no
+// certificate yet, without issuing any
+func proposeDiscoveredDomains(ctx context.Context, provider discovery.Provider, certManager *certmanager.CertificateManager, logger *log.Logger) {
+	logger.Printf("Starting %s domain discovery in propose-only mode", provider.Name())
+
+	go func() {
+		proposed := make(map[string]bool)
+		err := provider.Watch(ctx, func(domains []config.Domain) {
+			managed := certManager.ListCertificates()
+			for _, d := range domains {
+				if _, exists := managed[d.Domain]; exists || proposed[d.Domain] {
+					continue
+				}
+				proposed[d.Domain] = true
+				logger.Printf("Proposed domain %s from %s (service: %s); enable issuing for %s discovery or add it to the configuration to manage it",
+					d.Domain, provider.Name(), d.Service, provider.Name())
+			}
+		})
+		if err != nil && ctx.Err() == nil {
+			logger.Printf("%s discovery stopped: %v", provider.Name(), err)
 		}
+	}()
+}
+
+// startMetricsServer serves Prometheus metrics in the background
+func startMetricsServer(addr string, logger *log.Logger) *http.Server {
+	mux := http.NewServeMux()
+	mux.Handle("/metrics", metrics.Handler())
+
+	server := &http.Server{
+		Addr:    addr,
+		Handler: mux,
+	}
+
+	go func() {
+		logger.Printf("Serving metrics on %s/metrics", addr)
+		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			logger.Printf("Metrics server failed: %v", err)
+		}
+	}()
+
+	return server
+}
+
+// startHealthServer serves /healthz, /readyz and /livez in the background
+func startHealthServer(addr string, certManager *certmanager.CertificateManager, traefikClient *traefik.APIClient, logger *log.Logger) *health.Server {
+	server := health.NewServer(addr, logger)
+
+	// The configuration was loaded and validated before the server started
+	server.AddReadinessCheck("config", func(ctx context.Context) error { return nil })
+	server.AddReadinessCheck("storage", func(ctx context.Context) error {
+		return certManager.CheckStorageAccess()
+	})
+	server.AddReadinessCheck("traefik", traefikClient.IsHealthy)
+
+	server.Start()
+	return server
+}
+
+// runHealthCheck reports certificate health, of the domains of group when it
+// is set, in the requested format and returns the exit code
+func runHealthCheck(certManager *certmanager.CertificateManager, group, format string, logger *log.Logger) int {
+	logger.Printf("Running certificate health check...")
+
+	services := certManager.CheckServiceHealth()
+	if group != "" {
+		services = certmanager.ServicesInGroup(services, group)
 	}
+	report := status.NewReport("health", services, nil)
+	if err := writeReport(os.Stdout, format, report); err != nil {
+		logger.Printf("Failed to write report: %v", err)
+		return status.ExitRunFailed
+	}
+	return report.ExitCode
+}
 
-	logger.Printf("Summary:")
-	logger.Printf("  Total certificates: %d", len(health))
-	logger.Printf("  Valid: %d", validCount)
-	logger.Printf("  Need renewal: %d", renewalCount)
-	logger.Printf("  Expired: %d", expiredCount)
+// runRemoteHealthCheck reports the health of a running daemon's certificates
+// with the exit codes of runHealthCheck
+func runRemoteHealthCheck(ctx context.Context, client *api.Client, group, format string) error {
+	ctx, cancel := context.WithTimeout(ctx, remoteQueryTimeout)
+	defer cancel()
 
-	if renewalCount > 0 || expiredCount > 0 {
-		os.Exit(1)
+	report, err := client.Status(ctx, group)
+	if err != nil {
+		return err
 	}
+	if err := writeReport(os.Stdout, format, report); err != nil {
+		return fmt.Errorf("failed to write report: %w", err)
+	}
+	return exitCode(report.ExitCode)
 }
 
-// runOnceMode runs the certificate manager once and exits
-func runOnceMode(certManager *certmanager.CertificateManager, logger *log.Logger) {
+// runOnceMode runs the certificate manager once, reports the resulting
+// certificate health and returns the exit code. In strict mode any failure
+// fails the run, including those that only get reported, like deployments.
+// With a summary file, the report is also written there as JSON.
+func runOnceMode(ctx context.Context, certManager *certmanager.CertificateManager, format, summaryFile string, strict bool, logger *log.Logger) int {
 	logger.Printf("Running in single-execution mode...")
 
-	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Minute)
-	defer cancel()
+	var errs []error
 
 	// Process all configured domains
 	if err := certManager.ProcessAllDomains(ctx); err != nil {
 		logger.Printf("Error processing domains: %v", err)
+		errs = append(errs, fmt.Errorf("failed to process domains: %w", err))
 	}
 
 	// Check for and renew certificates that need it
 	if err := certManager.RenewExpiredCertificates(ctx); err != nil {
 		logger.Printf("Error renewing certificates: %v", err)
+		errs = append(errs, fmt.Errorf("failed to renew certificates: %w", err))
 	}
 
-	// Display final health status
-	logger.Println("Final certificate health status after single run:")
-	runHealthCheck(certManager, logger)
+	certManager.RenewCompanions(ctx)
+	certManager.RefreshStaples(ctx)
+	certManager.PruneOrphanedCertificates(ctx)
+	certManager.ReconcileInventory(ctx)
+	certManager.NotifyExpiring()
+	certManager.FlushNotifications()
+
+	report := status.NewReport("once", certManager.CheckServiceHealth(), errs)
+	report.AddFailures(certManager.TakeFailures(), strict)
+	report.AddRun(certManager.TakeIssued())
+	if err := writeReport(os.Stdout, format, report); err != nil {
+		logger.Printf("Failed to write report: %v", err)
+		return status.ExitRunFailed
+	}
+	if summaryFile != "" {
+		if err := writeSummaryFile(summaryFile, report); err != nil {
+			logger.Printf("Failed to write summary: %v", err)
+			return status.ExitRunFailed
+		}
+	}
 
 	logger.Println("Single-execution mode finished.")
+	return report.ExitCode
+}
+
+// writeSummaryFile writes the report of a once run as JSON to path
+func writeSummaryFile(path string, report *status.Report) error {
+	var buf bytes.Buffer
+	if err := writeStructured(&buf, "json", report); err != nil {
+		return err
+	}
+	if err := os.WriteFile(path, buf.Bytes(), 0644); err != nil {
+		return fmt.Errorf("failed to write summary file: %w", err)
+	}
+	return nil
 }
@@ -0,0 +1,64 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/spf13/cobra"
+)
+
+func newMigrateStorageCommand(opts *options) *cobra.Command {
+	var from, to string
+
+	cmd := &cobra.Command{
+		Use:   "migrate-storage",
+		Short: "Copy the certificate storage to a new location and verify the copy",
+		Long: "Copy certificates, keys, the ACME account, order journals, state and metadata from one storage " +
+			"location to another and compare every copied file with its source. Locations are given as " +
+			"file://PATH or a plain path; storage is only kept on the filesystem, so other schemes are " +
+			"rejected. The target must not exist or be empty.\n\n" +
+			"Stop cert-manager first, and point certificates.storage_path at the target before starting it again. " +
+			"The source is left in place.",
+		Example: "  cert-manager migrate-storage --from file://./certs --to file:///var/lib/cert-manager",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			fromPath, err := certmanager.StoragePathFromURL(from)
+			if err != nil {
+				return err
+			}
+			toPath, err := certmanager.StoragePathFromURL(to)
+			if err != nil {
+				return err
+			}
+
+			// Works on the storage alone, so no configuration is needed
+			logger := log.New(os.Stderr, "[CertManager] ", log.LstdFlags)
+			copied, err := certmanager.CopyStorage(fromPath, toPath, logger)
+			if err != nil {
+				return err
+			}
+			return writeStorageCopy(os.Stdout, opts.output, copied)
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().StringVar(&from, "from", "", "Storage to copy, e.g. file://./certs")
+	cmd.Flags().StringVar(&to, "to", "", "Storage to copy to, e.g. file:///var/lib/cert-manager")
+	cmd.MarkFlagRequired("from")
+	cmd.MarkFlagRequired("to")
+	return cmd
+}
+
+// writeStorageCopy prints what was copied and the next step
+func writeStorageCopy(w io.Writer, format string, copied *certmanager.StorageCopy) error {
+	if format != "table" {
+		return writeStructured(w, format, copied)
+	}
+
+	fmt.Fprintf(w, "Copied and verified %d files (%d bytes, %d certificates) from %s to %s\n",
+		copied.Files, copied.Bytes, len(copied.Certificates), copied.From, copied.To)
+	fmt.Fprintf(w, "Set certificates.storage_path to %s before starting cert-manager again\n", copied.To)
+	return nil
+}
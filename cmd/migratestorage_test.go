@@ -0,0 +1,23 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestWriteStorageCopy(t *testing.T) {
+	copied := &certmanager.StorageCopy{From: "./certs", To: "/var/lib/cert-manager", Files: 4, Bytes: 8192,
+		Certificates: []string{"example.com"}}
+
+	var out bytes.Buffer
+	if err := writeStorageCopy(&out, "table", copied); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "Copied and verified 4 files (8192 bytes, 1 certificates) from ./certs to /var/lib/cert-manager") ||
+		!strings.Contains(out.String(), "Set certificates.storage_path to /var/lib/cert-manager") {
+		t.Errorf("unexpected output:\n%s", out.String())
+	}
+}
@@ -0,0 +1,215 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/spf13/cobra"
+)
+
+func newNotifyCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "notify",
+		Short: "Work with notifications",
+	}
+	cmd.AddCommand(newNotifyTestCommand(opts))
+	cmd.AddCommand(newNotifyPreviewCommand(opts))
+	return cmd
+}
+
+func newNotifyTestCommand(opts *options) *cobra.Command {
+	var to []string
+
+	cmd := &cobra.Command{
+		Use:   "test",
+		Short: "Send a test email to verify the notification settings",
+		Long: "Send a test email with the configured SMTP server, TLS mode, authentication and " +
+			"templates. It goes to email, or to the addresses given with --to, without deduplication or digest.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, logger, err := setup(opts, true)
+			if err != nil {
+				return err
+			}
+			notifier, err := notify.NewEmailNotifier(cfg.Notification, cfg.Email, logger)
+			if err != nil {
+				return err
+			}
+			return sendTestNotification(cmd.OutOrStdout(), notifier, cfg, to)
+		},
+	}
+	cmd.Flags().StringSliceVar(&to, "to", nil, "Recipients of the test email instead of email")
+	return cmd
+}
+
+// sendTestNotification sends a test message and reports where it went
+func sendTestNotification(w io.Writer, notifier notify.Notifier, cfg *config.Config, to []string) error {
+	if len(to) == 0 {
+		to = []string{cfg.Email}
+	}
+
+	msg := notify.Message{
+		Level:      notify.LevelInfo,
+		Event:      notify.EventTest,
+		Subject:    "Test notification",
+		Recipients: to,
+		Body: fmt.Sprintf("This is a test notification sent at %s.\n\n"+
+			"SMTP server: %s:%d\nTLS: %s\nFrom: %s\n\n"+
+			"Certificate notices will be delivered the same way.",
+			time.Now().Format(time.RFC3339), cfg.Notification.SMTPHost, cfg.Notification.SMTPPort,
+			cfg.Notification.TLS, cfg.Notification.From),
+	}
+	if err := notifier.Send(msg); err != nil {
+		return fmt.Errorf("failed to send test notification: %w", err)
+	}
+
+	fmt.Fprintf(w, "Sent test notification to %s\n", strings.Join(to, ", "))
+	return nil
+}
+
+// previewSamples are example notices of each event, worded like the ones the
+// daemon sends
+var previewSamples = map[string]notify.Message{
+	notify.EventExpiring: {
+		Level:   notify.LevelWarning,
+		Domain:  "www.example.com",
+		Subject: "Certificate for www.example.com expires in 10 days",
+		Body: "Expires: 2025-03-11T12:00:00Z\nRenewal scheduled for: 2025-02-09T12:00:00Z\n\n" +
+			"The certificate has not been renewed yet. Check the renewal log for errors.",
+	},
+	notify.EventExpired: {
+		Level:   notify.LevelPage,
+		Domain:  "www.example.com",
+		Subject: "Certificate for www.example.com has expired",
+		Body: "Expires: 2025-03-01T12:00:00Z\nRenewal scheduled for: 2025-01-30T12:00:00Z\n\n" +
+			"The certificate has not been renewed yet. Check the renewal log for errors.",
+	},
+	notify.EventFailure: {
+		Level:   notify.LevelCritical,
+		Domain:  "www.example.com",
+		Subject: "Failed to renew certificate for www.example.com",
+		Body: "Error: acme: error: 403 :: urn:ietf:params:acme:error:unauthorized :: invalid key authorization\n\n" +
+			"The order is retried on the next check.",
+	},
+	notify.EventDeployFailure: {
+		Level:   notify.LevelCritical,
+		Domain:  "www.example.com",
+		Subject: "Failed to deploy certificate for www.example.com",
+		Body: "A new certificate for www.example.com was issued but could not be deployed to all remote targets.\n\n" +
+			"Error: edge-1: connection refused\n\nThe remote hosts may still serve the previous certificate.",
+	},
+	notify.EventSwitchFailure: {
+		Level:   notify.LevelCritical,
+		Domain:  "www.example.com",
+		Subject: "Post-switch webhook failed for www.example.com",
+		Body: "Traefik serves the new certificate for www.example.com, but its post_switch webhook failed.\n\n" +
+			"Error: webhook returned 500\n\nRemote deployments and post hooks were skipped.",
+	},
+	notify.EventChainExpiring: {
+		Level:   notify.LevelWarning,
+		Subject: `Intermediate certificate "CN=R11,O=Let's Encrypt,C=US" expires in 20 days`,
+		Body: "Issuer: CN=ISRG Root X1,O=Internet Security Research Group,C=US\nExpires: 2025-03-21T00:00:00Z\n" +
+			"Affected domains: www.example.com, api.example.com\n\n" +
+			"The leaf certificates may still be valid, but their stored chain should be refreshed from the CA.",
+	},
+	notify.EventStorageLow: {
+		Level:   notify.LevelCritical,
+		Subject: "Certificate storage low on /var/lib/traefik-cert-manager",
+		Body: "Free space: 12 MB (minimum 50 MB)\nFree inodes: 9000 (minimum 1000)\n" +
+			"New certificate issuance and renewal are refused until space is freed.",
+	},
+	notify.EventStorageRecovered: {
+		Level:   notify.LevelInfo,
+		Subject: "Certificate storage recovered on /var/lib/traefik-cert-manager",
+		Body:    "Free space: 800 MB\nFree inodes: 9000\nCertificate issuance has resumed.",
+	},
+	notify.EventDrift: {
+		Level:   notify.LevelWarning,
+		Subject: "Traefik routes 2 hostnames without a certificate",
+		Body: "Hostnames: shop.example.com, blog.example.com\n\n" +
+			"Add them to the configuration, or check why their certificates weren't issued.",
+	},
+	notify.EventOrphaned: {
+		Level:   notify.LevelWarning,
+		Domain:  "old.example.com",
+		Subject: "Certificate for old.example.com is orphaned",
+		Body: "old.example.com is no longer configured or discovered, so its certificate isn't renewed.\n\n" +
+			"It will be revoked and deleted on 2025-04-01T12:00:00Z unless the domain returns or the certificate is adopted.",
+	},
+	notify.EventPruned: {
+		Level:   notify.LevelInfo,
+		Domain:  "old.example.com",
+		Subject: "Pruned orphaned certificate for old.example.com",
+		Body:    "The certificate was revoked where its CA allows it and deleted from storage and the Traefik TLS configuration.",
+	},
+	notify.EventDigest: {
+		Level:   notify.LevelWarning,
+		Subject: "Certificate digest for 2025-03-01: 2 notices",
+		Body: "[WARNING] Certificate for www.example.com expires in 10 days\n" +
+			"[INFO] Pruned orphaned certificate for old.example.com\n",
+	},
+	notify.EventReport: {
+		Level:   notify.LevelInfo,
+		Subject: "Weekly certificate report: 1 renewed, 2 expiring, 0 failures",
+		Body:    "# Weekly certificate report\n\n## Renewed\n\n- www.example.com\n",
+	},
+	notify.EventTest: {
+		Level:   notify.LevelInfo,
+		Subject: "Test notification",
+		Body:    "This is a test notification.\n\nCertificate notices will be delivered the same way.",
+	},
+}
+
+// previewEvents returns the events that have a sample, sorted
+func previewEvents() []string {
+	events := make([]string, 0, len(previewSamples))
+	for event := range previewSamples {
+		events = append(events, event)
+	}
+	sort.Strings(events)
+	return events
+}
+
+func newNotifyPreviewCommand(opts *options) *cobra.Command {
+	return &cobra.Command{
+		Use:   "preview EVENT",
+		Short: "Render a sample notification with the configured templates",
+		Long: "Render a sample notice of EVENT with the configured subject, text and HTML templates and " +
+			"print it instead of sending it. Events: " + strings.Join(previewEvents(), ", ") + ".",
+		Args:      cobra.ExactArgs(1),
+		ValidArgs: previewEvents(),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			cfg, logger, err := setup(opts, true)
+			if err != nil {
+				return err
+			}
+			notifier, err := notify.NewEmailNotifier(cfg.Notification, cfg.Email, logger)
+			if err != nil {
+				return err
+			}
+			return previewNotification(cmd.OutOrStdout(), notifier, args[0], time.Now())
+		},
+	}
+}
+
+// previewNotification prints the sample notice of event as the templates
+// render it
+func previewNotification(w io.Writer, notifier *notify.EmailNotifier, event string, now time.Time) error {
+	msg, ok := previewSamples[event]
+	if !ok {
+		return fmt.Errorf("unknown event %q, expected one of %s", event, strings.Join(previewEvents(), ", "))
+	}
+	msg.Event = event
+
+	email, err := notifier.Render(msg, now)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "Subject: %s\n\n--- text ---\n%s\n--- html ---\n%s", email.Subject, email.Text, email.HTML)
+	return nil
+}
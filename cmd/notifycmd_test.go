@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (r *recordingNotifier) Send(msg notify.Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func TestSendTestNotification(t *testing.T) {
+	cfg := &config.Config{Email: "ops@example.com"}
+	notifier := &recordingNotifier{}
+
+	var out bytes.Buffer
+	if err := sendTestNotification(&out, notifier, cfg, nil); err != nil {
+		t.Fatal(err)
+	}
+	if len(notifier.messages) != 1 || notifier.messages[0].Recipients[0] != "ops@example.com" {
+		t.Fatalf("messages = %+v, want one to email", notifier.messages)
+	}
+
+	out.Reset()
+	if err := sendTestNotification(&out, notifier, cfg, []string{"a@example.com", "b@example.com"}); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "a@example.com, b@example.com") {
+		t.Errorf("output = %q, want both recipients", out.String())
+	}
+}
+
+func TestPreviewNotification(t *testing.T) {
+	notifier, err := notify.NewEmailNotifier(config.Notification{}, "ops@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+
+	for _, event := range previewEvents() {
+		var out bytes.Buffer
+		if err := previewNotification(&out, notifier, event, now); err != nil {
+			t.Errorf("previewNotification(%s) = %v", event, err)
+			continue
+		}
+		if !strings.HasPrefix(out.String(), "Subject: ["+strings.ToUpper(string(previewSamples[event].Level))+"] ") ||
+			!strings.Contains(out.String(), "--- html ---\n<!DOCTYPE html>") {
+			t.Errorf("unexpected preview of %s:\n%s", event, out.String())
+		}
+	}
+
+	if err := previewNotification(&bytes.Buffer{}, notifier, "unknown", now); err == nil {
+		t.Error("expected an error for an unknown event")
+	}
+}
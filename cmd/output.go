@@ -0,0 +1,138 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"text/tabwriter"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+	"gopkg.in/yaml.v2"
+)
+
+// validOutputFormat reports whether -output names a supported format
+func validOutputFormat(format string) bool {
+	switch format {
+	case "table", "json", "yaml":
+		return true
+	}
+	return false
+}
+
+// writeReport renders the report as a table, JSON or YAML
+func writeReport(w io.Writer, format string, report *status.Report) error {
+	if format == "table" {
+		return writeReportTable(w, report)
+	}
+	return writeStructured(w, format, report)
+}
+
+// writeStructured encodes v as JSON or YAML
+func writeStructured(w io.Writer, format string, v interface{}) error {
+	switch format {
+	case "json":
+		encoder := json.NewEncoder(w)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(v)
+	case "yaml":
+		data, err := yaml.Marshal(v)
+		if err != nil {
+			return fmt.Errorf("failed to encode output: %w", err)
+		}
+		_, err = w.Write(data)
+		return err
+	default:
+		return fmt.Errorf("unknown output format %q, expected table, json or yaml", format)
+	}
+}
+
+// holdSummary describes when and why a hold ends, e.g. " until 2025-02-01T00:00:00Z (migration)"
+func holdSummary(hold *status.HoldReport) string {
+	s := " until resumed"
+	if hold.Until != nil {
+		s = " until " + hold.Until.Format(time.RFC3339)
+	}
+	if hold.Reason != "" {
+		s += " (" + hold.Reason + ")"
+	}
+	return s
+}
+
+// stapleSummary describes an OCSP staple that isn't fresh, e.g. "missing" or
+// "good, expired 2025-02-01T00:00:00Z"
+func stapleSummary(staple *status.StapleReport) string {
+	if staple.NextUpdate == nil {
+		return staple.Status
+	}
+	return staple.Status + ", expired " + staple.NextUpdate.Format(time.RFC3339)
+}
+
+func writeReportTable(w io.Writer, report *status.Report) error {
+	if len(report.Services) == 0 {
+		fmt.Fprintln(w, "No certificates found")
+	} else {
+		tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(tw, "SERVICE\tDOMAIN\tSTATUS\tEXPIRES\tDAYS\tRENEW AT\tCHAIN EXPIRES")
+
+		row := func(service string, cert status.CertificateReport) {
+			chainExpires := "-"
+			if cert.ChainExpiresAt != nil {
+				chainExpires = cert.ChainExpiresAt.Format(time.RFC3339)
+			}
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n", service, cert.Domain, cert.Status,
+				cert.ExpiresAt.Format(time.RFC3339), cert.DaysUntilExpiry, cert.RenewAt.Format(time.RFC3339), chainExpires)
+		}
+
+		for _, service := range report.Services {
+			name := service.Service
+			if name == "" {
+				name = "(none)"
+			}
+			if service.Primary != nil {
+				row(name, *service.Primary)
+			} else {
+				fmt.Fprintf(tw, "%s\t%s\t%s\t-\t-\t-\t-\n", name, service.Domain, "missing")
+			}
+			for _, alias := range service.Aliases {
+				row(name, alias)
+			}
+		}
+		if err := tw.Flush(); err != nil {
+			return err
+		}
+	}
+
+	fmt.Fprintf(w, "\nServices: %d (%d certificates), valid: %d, need renewal: %d, expired: %d\n",
+		report.Summary.Services, report.Summary.Certificates,
+		report.Summary.Valid, report.Summary.NeedsRenewal, report.Summary.Expired)
+	for _, service := range report.Services {
+		certs := service.Aliases
+		if service.Primary != nil {
+			certs = append([]status.CertificateReport{*service.Primary}, certs...)
+		}
+		for _, cert := range certs {
+			if cert.Hold != nil {
+				fmt.Fprintf(w, "Held: %s%s\n", cert.Domain, holdSummary(cert.Hold))
+			}
+			if limit := cert.DuplicateLimit; limit != nil {
+				fmt.Fprintf(w, "Duplicate limit: %s issued %d times this week (limit %d), next issuance after %s\n",
+					cert.Domain, limit.Issued, limit.Limit, limit.RetryAfter.Format(time.RFC3339))
+			}
+			if staple := cert.Staple; staple != nil && staple.Status != certmanager.StapleUnsupported && !staple.Fresh {
+				fmt.Fprintf(w, "OCSP staple: %s %s\n", cert.Domain, stapleSummary(staple))
+			}
+		}
+	}
+	for _, err := range report.Errors {
+		fmt.Fprintf(w, "Error: %s\n", err)
+	}
+	for _, failure := range report.Failures {
+		fmt.Fprintf(w, "Failure: %s %s: %s\n", failure.Operation, failure.Domain, failure.Error)
+	}
+	if report.Run != nil {
+		fmt.Fprintf(w, "Run %s: %d issued, %d failed\n", report.Run.Outcome, len(report.Run.Issued), len(report.Run.Failed))
+	}
+	return nil
+}
@@ -0,0 +1,115 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+	"gopkg.in/yaml.v2"
+)
+
+func testServices() []certmanager.ServiceHealth {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []certmanager.ServiceHealth{
+		{
+			Service: "web",
+			Domain:  "www.example.com",
+			Status:  "needs_renewal",
+			Primary: &certmanager.CertificateHealth{Domain: "www.example.com", Status: "valid", ExpiresAt: expires},
+			Aliases: []certmanager.CertificateHealth{
+				{Domain: "example.com", Status: "needs_renewal", NeedsRenewal: true, ExpiresAt: expires},
+			},
+		},
+		{
+			Service: "api",
+			Domain:  "api.example.com",
+			Status:  "valid",
+			Primary: &certmanager.CertificateHealth{Domain: "api.example.com", Status: "valid", ExpiresAt: expires},
+		},
+	}
+}
+
+func TestWriteReport_ListsFailures(t *testing.T) {
+	report := status.NewReport("once", testServices(), nil)
+	report.AddFailures([]certmanager.Failure{
+		{Domain: "www.example.com", Operation: "deploy", Error: "connection refused"},
+	}, true)
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "table", report); err != nil {
+		t.Fatalf("table: %v", err)
+	}
+	if !strings.Contains(buf.String(), "Failure: deploy www.example.com: connection refused") {
+		t.Errorf("table output does not list the failure:\n%s", buf.String())
+	}
+}
+
+func TestWriteReport_ListsStaleStaples(t *testing.T) {
+	services := testServices()
+	nextUpdate := time.Date(2029, 6, 1, 0, 0, 0, 0, time.UTC)
+	services[0].Primary.Staple = &certmanager.Staple{Status: "good", NextUpdate: nextUpdate}
+	services[0].Aliases[0].Staple = &certmanager.Staple{Status: certmanager.StapleMissing}
+	services[1].Primary.Staple = &certmanager.Staple{Status: "good", NextUpdate: nextUpdate, Fresh: true}
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "table", status.NewReport("health", services, nil)); err != nil {
+		t.Fatalf("table: %v", err)
+	}
+	for _, want := range []string{
+		"OCSP staple: www.example.com good, expired 2029-06-01T00:00:00Z",
+		"OCSP staple: example.com missing",
+	} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("table output does not contain %q:\n%s", want, buf.String())
+		}
+	}
+	if strings.Contains(buf.String(), "OCSP staple: api.example.com") {
+		t.Errorf("table output lists a fresh staple:\n%s", buf.String())
+	}
+}
+
+func TestWriteReport_Formats(t *testing.T) {
+	report := status.NewReport("health", testServices(), nil)
+
+	var buf bytes.Buffer
+	if err := writeReport(&buf, "json", report); err != nil {
+		t.Fatalf("json: %v", err)
+	}
+	var decoded map[string]interface{}
+	if err := json.Unmarshal(buf.Bytes(), &decoded); err != nil {
+		t.Fatalf("json output does not parse: %v", err)
+	}
+	for _, key := range []string{"schema_version", "mode", "generated_at", "exit_code", "summary", "services"} {
+		if _, ok := decoded[key]; !ok {
+			t.Errorf("json output is missing %q", key)
+		}
+	}
+
+	buf.Reset()
+	if err := writeReport(&buf, "yaml", report); err != nil {
+		t.Fatalf("yaml: %v", err)
+	}
+	var fromYAML status.Report
+	if err := yaml.Unmarshal(buf.Bytes(), &fromYAML); err != nil {
+		t.Fatalf("yaml output does not parse: %v", err)
+	}
+	if fromYAML.Summary != report.Summary || fromYAML.Services[1].Aliases[0].Domain != "example.com" {
+		t.Errorf("yaml round trip = %+v", fromYAML)
+	}
+
+	buf.Reset()
+	if err := writeReport(&buf, "table", report); err != nil {
+		t.Fatalf("table: %v", err)
+	}
+	if !strings.Contains(buf.String(), "SERVICE") || !strings.Contains(buf.String(), "need renewal: 1") {
+		t.Errorf("unexpected table output:\n%s", buf.String())
+	}
+
+	if err := writeReport(&buf, "xml", report); err == nil {
+		t.Error("expected an error for an unknown format")
+	}
+}
@@ -0,0 +1,72 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/pebble"
+	"github.com/spf13/cobra"
+)
+
+// useACMECA points the configuration at the CA named by --acme-ca
+func useACMECA(cfg *config.Config, name string) error {
+	switch name {
+	case "":
+		return nil
+	case "pebble":
+		dirURL, caCert, ok := pebble.FromEnvironment()
+		if !ok {
+			dirURL = pebble.DefaultDirURL
+		}
+		if caCert == "" {
+			caCert = cfg.ACME.CACert
+		}
+		if caCert == "" {
+			return fmt.Errorf("--acme-ca pebble needs the root of Pebble's HTTPS certificate in %s or acme.ca_cert, "+
+				"e.g. test/certs/pebble.minica.pem of the Pebble repository", pebble.EnvCACert)
+		}
+		cfg.UsePebble(dirURL, caCert)
+		return nil
+	default:
+		return fmt.Errorf("unknown --acme-ca %q, expected pebble", name)
+	}
+}
+
+func newPebbleCommand() *cobra.Command {
+	var opts pebble.Options
+	cmd := &cobra.Command{
+		Use:   "pebble",
+		Short: "Run a local Pebble ACME test server for development",
+		Long: "Run Pebble, Let's Encrypt's ACME test server, until interrupted, and print how to point the manager " +
+			"at it with --acme-ca pebble. Pebble must be installed, e.g. with " +
+			"\"go install github.com/letsencrypt/pebble/v2/cmd/pebble@latest\".",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			instance, err := pebble.Start(cmd.Context(), opts)
+			if err != nil {
+				return err
+			}
+			defer instance.Close()
+
+			fmt.Printf("Pebble is serving %s\n", instance.DirURL)
+			fmt.Printf("Point the manager at it with:\n\n  %s=%s %s=%s %s --acme-ca pebble once\n\n",
+				pebble.EnvDirURL, instance.DirURL, pebble.EnvCACert, instance.CACert, os.Args[0])
+			if opts.NonceReject > 0 {
+				fmt.Printf("%d%% of requests fail with badNonce\n", opts.NonceReject)
+			}
+			fmt.Println("Press Ctrl+C to stop")
+
+			<-cmd.Context().Done()
+			return nil
+		},
+	}
+	flags := cmd.Flags()
+	flags.StringVar(&opts.Binary, "binary", "", "Pebble executable; empty looks it up in PATH")
+	flags.StringVar(&opts.Listen, "listen", "localhost:14000", "Address of the ACME API")
+	flags.StringVar(&opts.ManagementListen, "management-listen", "localhost:15000", "Address of the management API")
+	flags.BoolVar(&opts.AlwaysValid, "always-valid", true, "Accept every challenge without validating it")
+	flags.IntVar(&opts.NonceReject, "nonce-reject", 0, "Percentage of requests failing with badNonce, to exercise retries")
+	flags.StringVar(&opts.DNSServer, "dns-server", "", "Resolver challenges are validated with, e.g. pebble-challtestsrv's 127.0.0.1:8053")
+	return cmd
+}
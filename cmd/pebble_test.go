@@ -0,0 +1,48 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/pebble"
+)
+
+func TestUseACMECA(t *testing.T) {
+	newConfig := func() *config.Config {
+		return &config.Config{
+			ACME:         config.ACME{CADirURL: "https://acme-v02.api.letsencrypt.org/directory"},
+			Certificates: config.Certificates{StoragePath: "/var/lib/certs"},
+			Domains:      []config.Domain{{Domain: "example.com", CADirURL: "https://ca.internal/acme/directory"}},
+		}
+	}
+
+	cfg := newConfig()
+	if err := useACMECA(cfg, ""); err != nil || cfg.ACME.CADirURL != "https://acme-v02.api.letsencrypt.org/directory" {
+		t.Errorf("no --acme-ca changed the CA to %s (%v)", cfg.ACME.CADirURL, err)
+	}
+
+	t.Setenv(pebble.EnvDirURL, "")
+	t.Setenv(pebble.EnvCACert, "")
+	if err := useACMECA(newConfig(), "pebble"); err == nil || !strings.Contains(err.Error(), pebble.EnvCACert) {
+		t.Errorf("useACMECA() without a CA certificate error = %v", err)
+	}
+
+	t.Setenv(pebble.EnvDirURL, "https://pebble:14000/dir")
+	t.Setenv(pebble.EnvCACert, "/tmp/pebble.minica.pem")
+	cfg = newConfig()
+	if err := useACMECA(cfg, "pebble"); err != nil {
+		t.Fatal(err)
+	}
+	if cfg.CADirURLFor("example.com") != "https://pebble:14000/dir" || cfg.ACME.CACert != "/tmp/pebble.minica.pem" {
+		t.Errorf("example.com is ordered from %s trusting %s", cfg.CADirURLFor("example.com"), cfg.ACME.CACert)
+	}
+	if cfg.Certificates.StoragePath != filepath.Join("/var/lib/certs", "pebble") {
+		t.Errorf("storage path = %s, want Pebble's certificates kept apart", cfg.Certificates.StoragePath)
+	}
+
+	if err := useACMECA(newConfig(), "boulder"); err == nil {
+		t.Error("unknown CA accepted")
+	}
+}
@@ -0,0 +1,6 @@
+//go:build postgres
+
+package main
+
+// Registers the Postgres driver of the history database (database.driver: postgres)
+import _ "github.com/jackc/pgx/v5/stdlib"
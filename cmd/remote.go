@@ -0,0 +1,77 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/api"
+	"github.com/spf13/cobra"
+)
+
+const (
+	// remoteAnnotation marks the commands that can run against a daemon with --server
+	remoteAnnotation = "remote"
+	// tokenEnv holds the API token when --token isn't given, keeping it out of
+	// the process list
+	tokenEnv = "CERT_MANAGER_TOKEN"
+	// remoteQueryTimeout bounds requests that only read from the daemon
+	remoteQueryTimeout = 30 * time.Second
+)
+
+// remoteCapable marks cmd as able to run against a daemon with --server
+func remoteCapable(cmd *cobra.Command) *cobra.Command {
+	if cmd.Annotations == nil {
+		cmd.Annotations = make(map[string]string)
+	}
+	cmd.Annotations[remoteAnnotation] = "true"
+	return cmd
+}
+
+// checkRemote refuses --server for commands that only work on the storage path
+func checkRemote(cmd *cobra.Command, opts *options) error {
+	if opts.server == "" {
+		return nil
+	}
+	if cmd.Annotations[remoteAnnotation] == "" {
+		return fmt.Errorf("%s doesn't support --server; list, renew, revoke and health do", cmd.CommandPath())
+	}
+	if u, err := url.Parse(opts.server); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("--server must be an http or https URL, got %q", opts.server)
+	}
+	return nil
+}
+
+// remoteClient returns a client of the daemon given with --server
+func remoteClient(opts *options) *api.Client {
+	token := opts.token
+	if token == "" {
+		token = os.Getenv(tokenEnv)
+	}
+	return api.NewClient(opts.server, token)
+}
+
+// remoteDomains returns the given domains, or the primary domains of a group
+// as the daemon knows them
+func remoteDomains(ctx context.Context, client *api.Client, args []string, group string) ([]string, error) {
+	if group == "" {
+		return args, nil
+	}
+
+	queryCtx, cancel := context.WithTimeout(ctx, remoteQueryTimeout)
+	defer cancel()
+	report, err := client.Status(queryCtx, group)
+	if err != nil {
+		return nil, err
+	}
+	var domains []string
+	for _, service := range report.Services {
+		domains = append(domains, service.Domain)
+	}
+	if len(domains) == 0 {
+		return nil, fmt.Errorf("no domains in group %q", group)
+	}
+	return domains, nil
+}
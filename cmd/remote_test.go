@@ -0,0 +1,80 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/api"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+)
+
+func TestRemoteCommands(t *testing.T) {
+	var requests []string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "missing or invalid bearer token"})
+			return
+		}
+		requests = append(requests, r.Method+" "+r.URL.RequestURI())
+		switch {
+		case r.URL.Path == "/api/v1/status":
+			json.NewEncoder(w).Encode(status.Report{ExitCode: status.ExitNeedsRenewal, Services: []status.ServiceReport{
+				{Service: "web", Domain: "www.example.com", Group: "production"},
+				{Service: "shop", Domain: "shop.example.com", Group: "production"},
+			}})
+		case strings.HasSuffix(r.URL.Path, "/renew") && strings.Contains(r.URL.Path, "shop"):
+			w.WriteHeader(http.StatusConflict)
+			json.NewEncoder(w).Encode(api.ErrorResponse{Error: "domain is held"})
+		default:
+			json.NewEncoder(w).Encode(api.CertificateResult{})
+		}
+	}))
+	defer server.Close()
+
+	run := func(args ...string) (string, error) {
+		root := newRootCommand()
+		var out bytes.Buffer
+		root.SetOut(&out)
+		root.SetArgs(append(args, "--server", server.URL, "--token", "secret"))
+		err := root.Execute()
+		return out.String(), err
+	}
+
+	out, err := run("renew", "--group", "production")
+	if err == nil || !strings.Contains(err.Error(), "1 of 2 domains failed: shop.example.com") || !strings.Contains(err.Error(), "domain is held") {
+		t.Errorf("renew --group = %v", err)
+	}
+	if out != "Renewed certificate for www.example.com\n" {
+		t.Errorf("renew printed %q", out)
+	}
+
+	if _, err := run("revoke", "www.example.com", "--reason", "keyCompromise"); err != nil {
+		t.Errorf("revoke = %v", err)
+	}
+
+	var code exitCode
+	if _, err := run("health", "--group", "production", "--output", "json"); !errors.As(err, &code) || int(code) != status.ExitNeedsRenewal {
+		t.Errorf("health = %v, want exit status %d", err, status.ExitNeedsRenewal)
+	}
+
+	want := []string{
+		"GET /api/v1/status?group=production",
+		"POST /api/v1/certificates/www.example.com/renew",
+		"POST /api/v1/certificates/shop.example.com/renew",
+		"POST /api/v1/certificates/www.example.com/revoke",
+		"GET /api/v1/status?group=production",
+	}
+	if strings.Join(requests, "\n") != strings.Join(want, "\n") {
+		t.Errorf("requests:\n%s\nwant:\n%s", strings.Join(requests, "\n"), strings.Join(want, "\n"))
+	}
+
+	if _, err := run("check"); err == nil || !strings.Contains(err.Error(), "doesn't support --server") {
+		t.Errorf("check --server = %v, want it refused", err)
+	}
+}
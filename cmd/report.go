@@ -0,0 +1,49 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/report"
+	"github.com/spf13/cobra"
+)
+
+func newReportCommand(opts *options) *cobra.Command {
+	var send bool
+	cmd := &cobra.Command{
+		Use:   "report",
+		Short: "Show the weekly certificate report, or send it with --send",
+		Long: "Show the summary of the last seven days: certificates renewed, upcoming expiries, failures and the " +
+			"average renewal lead time. With --send it is delivered to the destinations in the report section now.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				if send {
+					if err := certManager.SendWeeklyReport(cmd.Context()); err != nil {
+						return err
+					}
+					fmt.Println("Weekly report sent")
+					return nil
+				}
+				return writeWeeklyReport(os.Stdout, opts.output, certManager.WeeklyReport(time.Now()))
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().BoolVar(&send, "send", false, "Deliver the report to its configured destinations")
+	return cmd
+}
+
+// writeWeeklyReport prints the weekly report, as markdown for the table format
+func writeWeeklyReport(w io.Writer, format string, weekly *report.Weekly) error {
+	if format != "table" {
+		return writeStructured(w, format, weekly)
+	}
+	_, err := io.WriteString(w, weekly.Markdown())
+	return err
+}
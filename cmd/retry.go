@@ -0,0 +1,83 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+	"github.com/spf13/cobra"
+)
+
+func newRetryFailedCommand(opts *options) *cobra.Command {
+	var now bool
+	cmd := &cobra.Command{
+		Use:   "retry-failed",
+		Short: "Retry only the domains whose last renewal failed",
+		Long: "Retry only the domains whose last renewal failed, instead of checking every certificate. " +
+			"Domains still backing off after failures are skipped unless --now is given. " +
+			"Exits 2 when a retry fails. While the daemon runs, use its API instead, so its retry timers stay in step.",
+		Args: cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				scheduler, err := certmanager.NewScheduler(cfg, certManager, logger)
+				if err != nil {
+					return fmt.Errorf("failed to create scheduler: %w", err)
+				}
+
+				ctx, cancel := runContext(cmd, cfg)
+				defer cancel()
+
+				summary, err := scheduler.RetryFailed(ctx, now)
+				if err != nil {
+					return err
+				}
+				if err := writeRetrySummary(os.Stdout, opts.output, summary); err != nil {
+					return err
+				}
+				if summary.Status == "failed" {
+					return exitCode(status.ExitRunFailed)
+				}
+				return nil
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().BoolVar(&now, "now", false, "Also retry domains that are still backing off")
+	return cmd
+}
+
+// writeRetrySummary prints the outcome of every retried domain
+func writeRetrySummary(w io.Writer, format string, summary *certmanager.RunSummary) error {
+	if format != "table" {
+		return writeStructured(w, format, summary)
+	}
+	if len(summary.Domains) == 0 {
+		fmt.Fprintln(w, "No failed domains to retry")
+		return nil
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tOUTCOME\tDETAIL")
+	for _, domain := range summary.Domains {
+		detail := domain.Reason
+		if domain.Error != "" {
+			detail = domain.Error
+		}
+		if detail == "" {
+			detail = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", domain.Domain, domain.Outcome, detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "\nRetried: %d, renewed: %d, failed: %d, skipped: %d\n",
+		summary.Checked, summary.Renewed, summary.Failed, summary.Skipped)
+	return nil
+}
@@ -0,0 +1,36 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestWriteRetrySummary(t *testing.T) {
+	summary := &certmanager.RunSummary{Checked: 2, Renewed: 1, Skipped: 1, Domains: []certmanager.DomainRun{
+		{Domain: "api.example.com", Outcome: "renewed"},
+		{Domain: "example.com", Outcome: "skipped", Reason: "no longer managed"},
+	}}
+
+	var out bytes.Buffer
+	if err := writeRetrySummary(&out, "table", summary); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 5 || strings.Join(strings.Fields(lines[2]), " ") != "example.com skipped no longer managed" {
+		t.Errorf("unexpected table:\n%s", out.String())
+	}
+	if !strings.Contains(out.String(), "Retried: 2, renewed: 1, failed: 0, skipped: 1") {
+		t.Errorf("table is missing the totals:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := writeRetrySummary(&out, "table", &certmanager.RunSummary{}); err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(out.String()) != "No failed domains to retry" {
+		t.Errorf("unexpected output without failed domains:\n%s", out.String())
+	}
+}
@@ -0,0 +1,71 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// exitSelfTestFailed is the exit code of self-test when a hop fails
+const exitSelfTestFailed = 1
+
+func newSelfTestCommand(opts *options) *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "self-test DOMAIN",
+		Short: "Check that the CA can reach the HTTP-01 challenge path of a domain",
+		Long: "Serve a dummy token on the challenge listener and request it through the domain, reporting which hop " +
+			"fails: DNS, port 80, the Traefik rule for /.well-known/acme-challenge/, the challenge server or, with " +
+			"acme.http01_prober configured, the way in from outside. No order is placed, so no validation attempt " +
+			"is spent. Exits 1 when a hop fails.",
+		Example: "  cert-manager self-test example.com",
+		Args:    cobra.ExactArgs(1),
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				test, err := certManager.SelfTest(cmd.Context(), args[0])
+				if err != nil {
+					return err
+				}
+				if err := writeSelfTest(os.Stdout, opts.output, test); err != nil {
+					return err
+				}
+				if test.Failed != "" {
+					return exitCode(exitSelfTestFailed)
+				}
+				return nil
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	return cmd
+}
+
+// writeSelfTest prints the outcome of each hop
+func writeSelfTest(w io.Writer, format string, test *certmanager.SelfTest) error {
+	if format != "table" {
+		return writeStructured(w, format, test)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "HOP\tRESULT\tDETAIL")
+	for _, step := range test.Steps {
+		result := "ok"
+		switch {
+		case step.Skipped:
+			result = "skipped"
+		case !step.OK:
+			result = "FAILED"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\n", step.Hop, result, step.Detail)
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+	fmt.Fprintf(w, "\n%s: %s\n", test.Domain, test.Summary())
+	return nil
+}
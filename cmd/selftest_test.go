@@ -0,0 +1,37 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestWriteSelfTest(t *testing.T) {
+	test := &certmanager.SelfTest{
+		Domain: "example.com",
+		Steps: []certmanager.SelfTestStep{
+			{Hop: certmanager.HopDNS, OK: true, Detail: "example.com resolves to 203.0.113.10"},
+			{Hop: certmanager.HopPort80, OK: true, Detail: "port 80 accepts connections"},
+			{Hop: certmanager.HopTraefikRule, Detail: "unexpected response (status 404)"},
+			{Hop: certmanager.HopChallengeServer, Skipped: true, Detail: "not checked, traefik_rule failed"},
+		},
+		Failed: certmanager.HopTraefikRule,
+	}
+
+	var out bytes.Buffer
+	if err := writeSelfTest(&out, "table", test); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{
+		"dns               ok       example.com resolves to 203.0.113.10",
+		"traefik_rule      FAILED   unexpected response (status 404)",
+		"challenge_server  skipped  not checked, traefik_rule failed",
+		"example.com: traefik_rule failed",
+	} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("table output does not contain %q:\n%s", want, out.String())
+		}
+	}
+}
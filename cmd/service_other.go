@@ -0,0 +1,11 @@
+//go:build !windows
+
+package main
+
+import "github.com/spf13/cobra"
+
+// platformCommands returns the commands only available on some platforms; the
+// service command is Windows only
+func platformCommands(opts *options) []*cobra.Command {
+	return nil
+}
@@ -0,0 +1,244 @@
+//go:build windows
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/spf13/cobra"
+	"golang.org/x/sys/windows/svc"
+	"golang.org/x/sys/windows/svc/eventlog"
+	"golang.org/x/sys/windows/svc/mgr"
+)
+
+const (
+	defaultServiceName = "traefik-cert-manager"
+	// serviceStopWait is how long the Service Control Manager is told to wait
+	// for the daemon to stop its servers and scheduler
+	serviceStopWait = 30 * time.Second
+)
+
+// platformCommands returns the commands only available on Windows
+func platformCommands(opts *options) []*cobra.Command {
+	return []*cobra.Command{newServiceCommand(opts)}
+}
+
+func newServiceCommand(opts *options) *cobra.Command {
+	var name string
+
+	cmd := &cobra.Command{
+		Use:   "service",
+		Short: "Run the daemon as a Windows service",
+		Long: "Register the daemon with the Service Control Manager, which then starts it with the system. " +
+			"Logs go to the Application event log unless app.log_file is set.",
+	}
+	cmd.PersistentFlags().StringVar(&name, "name", defaultServiceName, "Service name, to run several instances")
+
+	install := &cobra.Command{
+		Use:   "install",
+		Short: "Register the service, started automatically with the configuration given by --config",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return installService(name, opts.configPath)
+		},
+	}
+	uninstall := &cobra.Command{
+		Use:   "uninstall",
+		Short: "Remove the service",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			return uninstallService(name)
+		},
+	}
+
+	var workDir string
+	run := &cobra.Command{
+		Use:    "run",
+		Short:  "Run as the service; started by the Service Control Manager",
+		Args:   cobra.NoArgs,
+		Hidden: true,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			// Services start in the system directory; relative paths in the
+			// configuration are resolved from where the service was installed
+			if workDir != "" {
+				if err := os.Chdir(workDir); err != nil {
+					return fmt.Errorf("failed to change to %s: %w", workDir, err)
+				}
+			}
+
+			isService, err := svc.IsWindowsService()
+			if err != nil {
+				return fmt.Errorf("failed to detect the service environment: %w", err)
+			}
+			if !isService {
+				return runDaemon(cmd.Context(), opts)
+			}
+			return runService(name, opts)
+		},
+	}
+	run.Flags().StringVar(&workDir, "workdir", "", "Directory relative paths in the configuration are resolved from")
+
+	cmd.AddCommand(install, uninstall, run)
+	return cmd
+}
+
+// installService registers the running executable as an automatically started
+// service, with the event log source its messages are reported under
+func installService(name, configPath string) error {
+	exe, err := os.Executable()
+	if err != nil {
+		return fmt.Errorf("failed to locate the executable: %w", err)
+	}
+	configPath, err = filepath.Abs(configPath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve the configuration path: %w", err)
+	}
+	workDir, err := os.Getwd()
+	if err != nil {
+		return fmt.Errorf("failed to get the working directory: %w", err)
+	}
+
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the Service Control Manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	if s, err := m.OpenService(name); err == nil {
+		s.Close()
+		return fmt.Errorf("service %s already exists", name)
+	}
+
+	s, err := m.CreateService(name, exe, mgr.Config{
+		DisplayName: "Traefik Certificate Manager",
+		Description: "Issues and renews ACME certificates for services behind Traefik",
+		StartType:   mgr.StartAutomatic,
+	}, "service", "run", "--name", name, "--config", configPath, "--workdir", workDir)
+	if err != nil {
+		return fmt.Errorf("failed to create service %s: %w", name, err)
+	}
+	defer s.Close()
+
+	// Restart a crashed daemon, backing off, and forget failures after a day
+	err = s.SetRecoveryActions([]mgr.RecoveryAction{
+		{Type: mgr.ServiceRestart, Delay: 10 * time.Second},
+		{Type: mgr.ServiceRestart, Delay: time.Minute},
+		{Type: mgr.ServiceRestart, Delay: 5 * time.Minute},
+	}, uint32((24 * time.Hour).Seconds()))
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to set recovery actions of %s: %v\n", name, err)
+	}
+
+	if err := eventlog.InstallAsEventCreate(name, eventlog.Error|eventlog.Warning|eventlog.Info); err != nil {
+		s.Delete()
+		return fmt.Errorf("failed to register event log source %s: %w", name, err)
+	}
+
+	fmt.Printf("Installed service %s using %s; start it with: sc start %s\n", name, configPath, name)
+	return nil
+}
+
+// uninstallService removes the service and its event log source
+func uninstallService(name string) error {
+	m, err := mgr.Connect()
+	if err != nil {
+		return fmt.Errorf("failed to connect to the Service Control Manager: %w", err)
+	}
+	defer m.Disconnect()
+
+	s, err := m.OpenService(name)
+	if err != nil {
+		return fmt.Errorf("service %s is not installed", name)
+	}
+	defer s.Close()
+
+	if err := s.Delete(); err != nil {
+		return fmt.Errorf("failed to remove service %s: %w", name, err)
+	}
+	if err := eventlog.Remove(name); err != nil {
+		fmt.Fprintf(os.Stderr, "Warning: failed to remove event log source %s: %v\n", name, err)
+	}
+
+	fmt.Printf("Removed service %s\n", name)
+	return nil
+}
+
+// runService runs the daemon under the Service Control Manager, logging to the event log
+func runService(name string, opts *options) error {
+	elog, err := eventlog.Open(name)
+	if err != nil {
+		return fmt.Errorf("failed to open event log source %s: %w", name, err)
+	}
+	defer elog.Close()
+
+	opts.logOutput = eventLogWriter{elog}
+	return svc.Run(name, &service{opts: opts, elog: elog})
+}
+
+// service adapts the daemon to the Service Control Manager
+type service struct {
+	opts *options
+	elog *eventlog.Log
+}
+
+func (s *service) Execute(args []string, requests <-chan svc.ChangeRequest, changes chan<- svc.Status) (bool, uint32) {
+	changes <- svc.Status{State: svc.StartPending}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	done := make(chan error, 1)
+	go func() { done <- runDaemon(ctx, s.opts) }()
+
+	changes <- svc.Status{State: svc.Running, Accepts: svc.AcceptStop | svc.AcceptShutdown}
+	for {
+		select {
+		case err := <-done:
+			// The daemon stopped by itself, e.g. on an invalid configuration
+			if err != nil {
+				s.elog.Error(1, fmt.Sprintf("Certificate manager failed: %v", err))
+				return true, 1
+			}
+			return false, 0
+		case request := <-requests:
+			switch request.Cmd {
+			case svc.Interrogate:
+				changes <- request.CurrentStatus
+			case svc.Stop, svc.Shutdown:
+				changes <- svc.Status{State: svc.StopPending, WaitHint: uint32(serviceStopWait.Milliseconds())}
+				cancel()
+				if err := <-done; err != nil {
+					s.elog.Error(1, fmt.Sprintf("Certificate manager failed: %v", err))
+				}
+				return false, 0
+			}
+		}
+	}
+}
+
+// eventLogWriter reports each log line as an event, as an error or warning
+// when the line says so
+type eventLogWriter struct {
+	elog *eventlog.Log
+}
+
+func (w eventLogWriter) Write(p []byte) (int, error) {
+	line := strings.TrimRight(string(p), "\r\n")
+	var err error
+	switch {
+	case strings.Contains(line, "Error") || strings.Contains(line, "Failed") || strings.Contains(line, "failed"):
+		err = w.elog.Error(1, line)
+	case strings.Contains(line, "Warning"):
+		err = w.elog.Warning(1, line)
+	default:
+		err = w.elog.Info(1, line)
+	}
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
@@ -0,0 +1,4 @@
+package main
+
+// Registers the embedded SQLite driver of the history database (database.driver: sqlite)
+import _ "modernc.org/sqlite"
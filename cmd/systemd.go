@@ -0,0 +1,63 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/systemd"
+)
+
+// apiSocketName is the FileDescriptorName= of the socket unit passing the
+// management API socket; a single unnamed socket is taken as well
+const apiSocketName = "api"
+
+// notifySystemd reports state to systemd when running as a Type=notify service
+func notifySystemd(logger *log.Logger, state string) {
+	if _, err := systemd.Notify(state); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+}
+
+// runStatus describes a finished scheduler run for systemctl status
+func runStatus(summary *certmanager.RunSummary, next time.Time) string {
+	return fmt.Sprintf("STATUS=Last %s run %s at %s: %d checked, %d renewed, %d failed; next check at %s",
+		summary.Trigger, summary.Status, summary.FinishedAt.Format(time.RFC3339),
+		summary.Checked, summary.Renewed, summary.Failed, next.Format(time.RFC3339))
+}
+
+// apiListener returns the management API socket passed by socket activation,
+// nil when systemd passed none
+func apiListener(enabled bool, logger *log.Logger) (net.Listener, error) {
+	listeners, err := systemd.Listeners()
+	if err != nil {
+		return nil, err
+	}
+	if len(listeners) == 0 {
+		return nil, nil
+	}
+
+	l, ok := listeners[apiSocketName]
+	if !ok && len(listeners) == 1 {
+		for _, only := range listeners {
+			l, ok = only, true
+		}
+	}
+	for name, other := range listeners {
+		if other != l {
+			logger.Printf("Warning: ignoring socket %s passed by systemd, the management API uses FileDescriptorName=%s", name, apiSocketName)
+			other.Close()
+		}
+	}
+	if !ok {
+		return nil, nil
+	}
+	if !enabled {
+		logger.Printf("Warning: systemd passed a management API socket but api.enabled is false")
+		l.Close()
+		return nil, nil
+	}
+	return l, nil
+}
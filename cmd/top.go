@@ -0,0 +1,268 @@
+package main
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/metadata"
+	"github.com/spf13/cobra"
+)
+
+// clearScreen moves the cursor home and clears the terminal
+const clearScreen = "\033[H\033[2J"
+
+// topView is one screen of the top command
+type topView struct {
+	At            time.Time
+	Certificates  []CertificateListEntry
+	Scheduler     *certmanager.SchedulerStats // nil before the daemon first saved its state
+	BackingOff    int
+	CheckInterval time.Duration
+	LastRun       *certmanager.RunSummary
+	Events        []topEvent
+}
+
+// topEvent is a recent renewal or failure
+type topEvent struct {
+	At     time.Time
+	Domain string
+	Event  string
+	Detail string
+}
+
+func newTopCommand(opts *options) *cobra.Command {
+	var interval time.Duration
+	var group string
+	var events int
+	var once bool
+
+	cmd := &cobra.Command{
+		Use:   "top",
+		Short: "Show certificate expiry, the next scheduled check and recent events, refreshed live",
+		Long: "Show certificate expiry, the countdown to the daemon's next check and recent renewals and failures, " +
+			"refreshed every --interval until interrupted. It reads the storage path, so it follows a daemon " +
+			"running in another process without contacting it. Recent events come from the history database " +
+			"when one is configured and from run summaries otherwise.\n\n" +
+			"When standard output isn't a terminal, or with --once, a single screen is printed.",
+		Example: "  cert-manager top\n  cert-manager top --group production --interval 10s",
+		Args:    cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			if interval <= 0 {
+				return fmt.Errorf("--interval must be positive")
+			}
+			return managerCommand(opts, true, func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				checkInterval, _ := cfg.GetCheckInterval()
+				collect := func(ctx context.Context, now time.Time) topView {
+					certManager.RefreshCertificates(ctx)
+					view := topView{At: now, CheckInterval: checkInterval}
+					view.Certificates = filterCertificateList(certManager.CertificateDetails(),
+						listFilter{sortBy: "expiry", group: group, groupFor: cfg.GroupFor}, now)
+					stats, retries, err := certmanager.SavedSchedulerState(cfg.Certificates.StoragePath)
+					if err != nil {
+						logger.Printf("Warning: %v", err)
+					}
+					view.Scheduler, view.BackingOff = stats, len(retries)
+					if summary, err := certManager.LatestRunSummary(); err == nil {
+						view.LastRun = summary
+					}
+					view.Events = recentEvents(ctx, certManager, events)
+					return view
+				}
+
+				if once || !isTerminal(os.Stdout) {
+					return writeTopView(cmd.OutOrStdout(), collect(cmd.Context(), time.Now()))
+				}
+
+				// Log lines would scroll the screen away between refreshes
+				logger.SetOutput(io.Discard)
+				return runTop(cmd.Context(), cmd.OutOrStdout(), interval, collect)
+			})
+		},
+	}
+	cmd.Flags().DurationVar(&interval, "interval", 5*time.Second, "How often to refresh the screen")
+	cmd.Flags().StringVar(&group, "group", "", "Only show the certificates of the domains of this group")
+	cmd.Flags().IntVar(&events, "events", 10, "Number of recent events to show")
+	cmd.Flags().BoolVar(&once, "once", false, "Print a single screen and exit")
+	return cmd
+}
+
+// runTop redraws the screen every interval until ctx is done
+func runTop(ctx context.Context, w io.Writer, interval time.Duration, collect func(context.Context, time.Time) topView) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		var screen strings.Builder
+		screen.WriteString(clearScreen)
+		if err := writeTopView(&screen, collect(ctx, time.Now())); err != nil {
+			return err
+		}
+		fmt.Fprintf(&screen, "\nRefreshing every %v, Ctrl-C to quit\n", interval)
+		if _, err := io.WriteString(w, screen.String()); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// isTerminal reports whether f is a terminal rather than a pipe or file
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// recentEvents returns up to limit recent events, newest first
+func recentEvents(ctx context.Context, certManager *certmanager.CertificateManager, limit int) []topEvent {
+	if limit <= 0 {
+		return nil
+	}
+	if history := certManager.History(); history != nil {
+		entries, err := history.Events(ctx, metadata.Filter{Limit: limit})
+		if err == nil {
+			return historyEvents(entries)
+		}
+	}
+
+	summaries, _ := certManager.RecentRunSummaries(limit)
+	return runSummaryEvents(summaries, limit)
+}
+
+func historyEvents(entries []metadata.Event) []topEvent {
+	events := make([]topEvent, 0, len(entries))
+	for _, entry := range entries {
+		detail := entry.Error
+		if detail == "" && entry.Serial != "" {
+			detail = "serial " + entry.Serial
+		}
+		events = append(events, topEvent{At: entry.At, Domain: entry.Domain, Event: entry.Event, Detail: detail})
+	}
+	return events
+}
+
+// runSummaryEvents lists the renewals and failures of scheduler cycles
+func runSummaryEvents(summaries []*certmanager.RunSummary, limit int) []topEvent {
+	var events []topEvent
+	for _, summary := range summaries {
+		for _, domain := range summary.Domains {
+			switch domain.Outcome {
+			case "renewed":
+				events = append(events, topEvent{At: summary.FinishedAt, Domain: domain.Domain, Event: "renewed", Detail: domain.Duration})
+			case "failed":
+				events = append(events, topEvent{At: summary.FinishedAt, Domain: domain.Domain, Event: "failed", Detail: domain.Error})
+			}
+		}
+		// Failed renewals are listed above; these are deployments and the like
+		for _, failure := range summary.Failures {
+			if failure.Operation == "obtain" || failure.Operation == "renew" {
+				continue
+			}
+			events = append(events, topEvent{At: failure.Time, Domain: failure.Domain, Event: failure.Operation + " failed", Detail: failure.Error})
+		}
+	}
+
+	sort.SliceStable(events, func(i, j int) bool { return events[i].At.After(events[j].At) })
+	if len(events) > limit {
+		events = events[:limit]
+	}
+	return events
+}
+
+// writeTopView prints the scheduler, certificate and event sections of a screen
+func writeTopView(w io.Writer, view topView) error {
+	fmt.Fprintf(w, "Traefik Certificate Manager v%s - %s\n\n", version, view.At.Format("2006-01-02 15:04:05"))
+	fmt.Fprintln(w, schedulerLine(view))
+	if stats := view.Scheduler; stats != nil {
+		fmt.Fprintf(w, "Runs: %d (%d succeeded, %d failed), %d certificates renewed, %d domains backing off\n",
+			stats.TotalRuns, stats.SuccessfulRuns, stats.FailedRuns, stats.CertificatesRenewed, view.BackingOff)
+	}
+	if run := view.LastRun; run != nil {
+		fmt.Fprintf(w, "Last run: #%d (%s) %s %s ago in %s: %d renewed, %d failed, %d skipped\n",
+			run.Run, run.Trigger, run.Status, formatAge(view.At.Sub(run.FinishedAt)), run.Duration,
+			run.Renewed, run.Failed, run.Skipped)
+	}
+
+	fmt.Fprintln(w)
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tSTATUS\tEXPIRES\tIN\tRENEW AT")
+	for _, cert := range view.Certificates {
+		status := cert.Status
+		if cert.Hold != nil {
+			status += " (held)"
+		}
+		renewAt := "-"
+		if !cert.RenewAt.IsZero() {
+			renewAt = cert.RenewAt.Format("2006-01-02 15:04")
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", cert.Domain, status, cert.ExpiresAt.Format("2006-01-02 15:04"),
+			formatAge(cert.ExpiresAt.Sub(view.At)), renewAt)
+	}
+	if len(view.Certificates) == 0 {
+		fmt.Fprintln(tw, "no certificates\t\t\t\t")
+	}
+	if err := tw.Flush(); err != nil {
+		return err
+	}
+
+	fmt.Fprintln(w, "\nRECENT EVENTS")
+	if len(view.Events) == 0 {
+		fmt.Fprintln(w, "none recorded")
+		return nil
+	}
+	tw = tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "TIME\tDOMAIN\tEVENT\tDETAIL")
+	for _, event := range view.Events {
+		detail := event.Detail
+		if detail == "" {
+			detail = "-"
+		}
+		fmt.Fprintf(tw, "%s\t%s\t%s\t%s\n", event.At.Local().Format("2006-01-02 15:04:05"), event.Domain, event.Event, detail)
+	}
+	return tw.Flush()
+}
+
+// schedulerLine describes when the daemon checks certificates next
+func schedulerLine(view topView) string {
+	stats := view.Scheduler
+	switch {
+	case stats == nil:
+		return "Scheduler: no saved state yet; the daemon hasn't completed a check"
+	case stats.NextRunTime.IsZero():
+		return fmt.Sprintf("Scheduler: not running, last check %s ago", formatAge(view.At.Sub(stats.LastRunTime)))
+	case stats.NextRunTime.After(view.At):
+		return fmt.Sprintf("Scheduler: next check in %s at %s (every %v)", formatAge(stats.NextRunTime.Sub(view.At)),
+			stats.NextRunTime.Local().Format("15:04:05"), view.CheckInterval)
+	default:
+		return fmt.Sprintf("Scheduler: next check due %s ago", formatAge(view.At.Sub(stats.NextRunTime)))
+	}
+}
+
+// formatAge renders a duration to the second below an hour and coarser above,
+// e.g. 4m05s, 3h12m or 12d4h
+func formatAge(d time.Duration) string {
+	sign := ""
+	if d < 0 {
+		sign, d = "-", -d
+	}
+	switch {
+	case d >= 24*time.Hour:
+		return fmt.Sprintf("%s%dd%dh", sign, d/(24*time.Hour), d%(24*time.Hour)/time.Hour)
+	case d >= time.Hour:
+		return fmt.Sprintf("%s%dh%02dm", sign, d/time.Hour, d%time.Hour/time.Minute)
+	default:
+		return fmt.Sprintf("%s%dm%02ds", sign, d/time.Minute, d%time.Minute/time.Second)
+	}
+}
@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+)
+
+func TestRunSummaryEvents(t *testing.T) {
+	now := time.Now()
+	summaries := []*certmanager.RunSummary{
+		{FinishedAt: now, Domains: []certmanager.DomainRun{
+			{Domain: "example.com", Outcome: "renewed", Duration: "2s"},
+			{Domain: "valid.example.com", Outcome: "valid"},
+		}},
+		{FinishedAt: now.Add(-time.Hour), Domains: []certmanager.DomainRun{
+			{Domain: "api.example.com", Outcome: "failed", Error: "CA unavailable"},
+		}, Failures: []certmanager.Failure{
+			{Domain: "api.example.com", Operation: "renew", Error: "CA unavailable", Time: now.Add(-time.Hour)},
+			{Domain: "example.com", Operation: "deploy", Error: "connection refused", Time: now.Add(-30 * time.Minute)},
+		}},
+	}
+
+	var got []string
+	for _, event := range runSummaryEvents(summaries, 10) {
+		got = append(got, event.Domain+" "+event.Event)
+	}
+	want := "example.com renewed, example.com deploy failed, api.example.com failed"
+	if strings.Join(got, ", ") != want {
+		t.Errorf("events = %q, want %q", got, want)
+	}
+	if events := runSummaryEvents(summaries, 1); len(events) != 1 {
+		t.Errorf("got %d events with a limit of 1", len(events))
+	}
+}
+
+func TestWriteTopView(t *testing.T) {
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	view := topView{
+		At:            now,
+		CheckInterval: time.Hour,
+		Scheduler:     &certmanager.SchedulerStats{TotalRuns: 3, SuccessfulRuns: 3, NextRunTime: now.Add(42*time.Minute + 5*time.Second)},
+		Certificates: []CertificateListEntry{{CertificateReport: status.CertificateReport{
+			Domain: "example.com", Status: "needs_renewal", ExpiresAt: now.Add(12*24*time.Hour + 4*time.Hour),
+		}}},
+		Events: []topEvent{{At: now.Add(-time.Minute), Domain: "example.com", Event: "renewed"}},
+	}
+
+	var out bytes.Buffer
+	if err := writeTopView(&out, view); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"next check in 42m05s", "Runs: 3 (3 succeeded, 0 failed)", "needs_renewal", "12d4h", "example.com  renewed"} {
+		if !strings.Contains(out.String(), want) {
+			t.Errorf("screen lacks %q:\n%s", want, out.String())
+		}
+	}
+
+	view.Scheduler.NextRunTime = time.Time{}
+	view.Scheduler.LastRunTime = now.Add(-3 * time.Hour)
+	if line := schedulerLine(view); line != "Scheduler: not running, last check 3h00m ago" {
+		t.Errorf("schedulerLine() = %q", line)
+	}
+}
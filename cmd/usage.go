@@ -0,0 +1,56 @@
+package main
+
+import (
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"text/tabwriter"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/spf13/cobra"
+)
+
+// UsageReport is the output of the usage command
+type UsageReport struct {
+	Since   time.Time                 `json:"since" yaml:"since"`
+	Domains []certmanager.DomainUsage `json:"domains" yaml:"domains"`
+}
+
+func newUsageCommand(opts *options) *cobra.Command {
+	var since string
+	cmd := &cobra.Command{
+		Use:   "usage",
+		Short: "Show ACME orders, DNS queries and notifications consumed per domain",
+		Args:  cobra.NoArgs,
+		RunE: func(cmd *cobra.Command, args []string) error {
+			window, err := parseWindow(since)
+			if err != nil {
+				return fmt.Errorf("invalid --since: %w", err)
+			}
+			return managerCommand(opts, opts.output != "table", func(certManager *certmanager.CertificateManager, cfg *config.Config, logger *log.Logger) error {
+				from := time.Now().Add(-window)
+				return writeUsage(os.Stdout, opts.output, UsageReport{Since: from, Domains: certManager.Usage(from)})
+			})
+		},
+	}
+	addOutputFlag(cmd, opts)
+	cmd.Flags().StringVar(&since, "since", "30d", "Period to report, as days (\"7d\") or a Go duration")
+	return cmd
+}
+
+// writeUsage prints the usage report, busiest domains first
+func writeUsage(w io.Writer, format string, report UsageReport) error {
+	if format != "table" {
+		return writeStructured(w, format, report)
+	}
+
+	tw := tabwriter.NewWriter(w, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(tw, "DOMAIN\tORDERS\tFAILED\tDNS QUERIES\tNOTIFICATIONS")
+	for _, usage := range report.Domains {
+		fmt.Fprintf(tw, "%s\t%d\t%d\t%d\t%d\n", usage.Domain, usage.Orders, usage.FailedOrders, usage.DNSQueries, usage.Notifications)
+	}
+	return tw.Flush()
+}
@@ -0,0 +1,32 @@
+package main
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestWriteUsage(t *testing.T) {
+	report := UsageReport{Domains: []certmanager.DomainUsage{
+		{Domain: "noisy.example.com", UsageCounts: certmanager.UsageCounts{Orders: 12, FailedOrders: 11, DNSQueries: 12}},
+	}}
+
+	var out bytes.Buffer
+	if err := writeUsage(&out, "table", report); err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(out.String()), "\n")
+	if len(lines) != 2 || strings.Join(strings.Fields(lines[1]), " ") != "noisy.example.com 12 11 12 0" {
+		t.Errorf("unexpected table:\n%s", out.String())
+	}
+
+	out.Reset()
+	if err := writeUsage(&out, "yaml", report); err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(out.String(), "failed_orders: 11") {
+		t.Errorf("usage counts are not inlined in YAML:\n%s", out.String())
+	}
+}
@@ -0,0 +1,643 @@
+package api
+
+import (
+	"context"
+	"crypto/subtle"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+)
+
+const (
+	// maxBodySize bounds request bodies accepted by the API
+	maxBodySize = 1 << 20
+	// importTimeout bounds fetching missing intermediates for an imported certificate
+	importTimeout = time.Minute
+	// forwardAuthCacheTTL is how long the status behind the ForwardAuth endpoint,
+	// which Traefik calls on every request, is reused
+	forwardAuthCacheTTL = 30 * time.Second
+)
+
+// Headers set by the ForwardAuth endpoint for the certificate of the requested host
+const (
+	HeaderCertStatus          = "X-Cert-Status" // valid, needs_renewal, expired or unknown
+	HeaderCertExpiresAt       = "X-Cert-Expires-At"
+	HeaderCertDaysUntilExpiry = "X-Cert-Days-Until-Expiry"
+	HeaderCertSchemaVersion   = "X-Cert-Schema-Version"
+)
+
+// Manager is the part of the certificate manager the API operates on
+type Manager interface {
+	MaintenanceState() certmanager.MaintenanceState
+	SetMaintenance(enabled bool, reason string) error
+	WireDebugDomains() []string
+	SetWireDebug(domain string, enabled bool) error
+	ImportCertificate(ctx context.Context, certPEM, keyPEM []byte) (string, error)
+	DeleteCertificate(domain string) error
+	CertificateDetails() []certmanager.CertificateDetails
+	RevokeCertificate(ctx context.Context, domain string, reason uint) error
+	ReissueCertificate(ctx context.Context, domain string, revoke bool, reason uint) (*certmanager.Certificate, error)
+	RollbackCertificate(ctx context.Context, domain string) (*certmanager.Certificate, error)
+	HoldDomain(domain string, until time.Time, reason string) (certmanager.Hold, error)
+	ReleaseDomain(domain string) error
+	Holds() (map[string]certmanager.Hold, error)
+	Usage(since time.Time) []certmanager.DomainUsage
+	LatestRunSummary() (*certmanager.RunSummary, error)
+	CheckServiceHealth() []certmanager.ServiceHealth
+	InternalCACertificate() ([]byte, error)
+	TraefikProviderConfiguration() certmanager.TraefikDynamicTLS
+}
+
+// Scheduler is the part of the renewal scheduler the API operates on
+type Scheduler interface {
+	RetryFailed(ctx context.Context, now bool) (*certmanager.RunSummary, error)
+	RenewNow(ctx context.Context, domain string) error
+}
+
+// ErrorResponse is the JSON body of every failed request
+type ErrorResponse struct {
+	Error string `json:"error"`
+	Code  string `json:"code,omitempty"` // class of the failure, see certmanager.ErrorCode
+}
+
+// CertificateResult is the JSON body of a successful certificate operation
+type CertificateResult struct {
+	Domain string `json:"domain"`
+	Result string `json:"result"` // renewed, reissued, revoked, imported, deleted, rolled_back or resumed
+}
+
+// ReissueRequest is the optional JSON body of a forced re-issuance
+type ReissueRequest struct {
+	Revoke bool   `json:"revoke,omitempty"` // revoke the replaced certificate
+	Reason string `json:"reason,omitempty"` // revocation reason, superseded when absent
+}
+
+// RevokeRequest is the optional JSON body of a revocation
+type RevokeRequest struct {
+	Reason string `json:"reason,omitempty"` // revocation reason, unspecified when absent
+}
+
+// HoldRequest is the JSON body holding a domain
+type HoldRequest struct {
+	Until  time.Time `json:"until,omitempty"` // RFC 3339; held until resumed when absent
+	Reason string    `json:"reason,omitempty"`
+}
+
+// ACMEDebugState is the JSON body listing the domains whose ACME exchanges are logged
+type ACMEDebugState struct {
+	Domains []string `json:"domains"` // "*" stands for all
+}
+
+// ImportRequest is the JSON body of a certificate import
+type ImportRequest struct {
+	Certificate string `json:"certificate"` // PEM leaf, optionally followed by intermediates
+	PrivateKey  string `json:"private_key"` // PEM private key
+}
+
+// Server is the management API of a running daemon
+type Server struct {
+	manager     Manager
+	scheduler   Scheduler
+	token       string
+	idempotency *idempotencyStore
+	forwardAuth bool
+	provider    bool // serve the Traefik HTTP provider configuration
+	logger      *log.Logger
+	server      *http.Server
+	listener    net.Listener // passed by socket activation, replaces the listen address
+	history     History      // nil when no history database is configured
+	configHash  string       // reported in the status
+
+	statusMu  sync.Mutex
+	status    *status.Report // cached for the ForwardAuth endpoint
+	statusAge time.Time
+}
+
+func NewServer(cfg config.API, manager Manager, scheduler Scheduler, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[API] ", log.LstdFlags)
+	}
+
+	ttl, err := time.ParseDuration(cfg.IdempotencyTTL)
+	if err != nil {
+		ttl = 24 * time.Hour
+	}
+
+	s := &Server{
+		manager:     manager,
+		scheduler:   scheduler,
+		token:       cfg.Token,
+		idempotency: newIdempotencyStore(ttl),
+		forwardAuth: cfg.ForwardAuth,
+		provider:    cfg.TraefikProvider,
+		logger:      logger,
+	}
+	s.server = &http.Server{
+		Addr:    cfg.ListenAddress,
+		Handler: s.Handler(),
+	}
+	return s
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("GET /api/v1/maintenance", s.getMaintenance)
+	mux.HandleFunc("PUT /api/v1/maintenance", s.enableMaintenance)
+	mux.HandleFunc("DELETE /api/v1/maintenance", s.disableMaintenance)
+	mux.HandleFunc("GET /api/v1/acme-debug", s.getACMEDebug)
+	mux.HandleFunc("PUT /api/v1/acme-debug/{domain}", s.enableACMEDebug)
+	mux.HandleFunc("DELETE /api/v1/acme-debug/{domain}", s.disableACMEDebug)
+	mux.HandleFunc("GET /api/v1/certificates", s.getCertificates)
+	mux.HandleFunc("POST /api/v1/certificates/{domain}/renew", s.idempotency.idempotent(s.renewCertificate))
+	mux.HandleFunc("POST /api/v1/certificates/{domain}/reissue", s.idempotency.idempotent(s.reissueCertificate))
+	mux.HandleFunc("POST /api/v1/certificates/{domain}/rollback", s.idempotency.idempotent(s.rollbackCertificate))
+	mux.HandleFunc("POST /api/v1/certificates/{domain}/revoke", s.idempotency.idempotent(s.revokeCertificate))
+	mux.HandleFunc("GET /api/v1/holds", s.getHolds)
+	mux.HandleFunc("PUT /api/v1/certificates/{domain}/hold", s.holdDomain)
+	mux.HandleFunc("DELETE /api/v1/certificates/{domain}/hold", s.releaseDomain)
+	mux.HandleFunc("POST /api/v1/certificates/import", s.idempotency.idempotent(s.importCertificate))
+	mux.HandleFunc("DELETE /api/v1/certificates/{domain}", s.idempotency.idempotent(s.deleteCertificate))
+	mux.HandleFunc("GET /api/v1/usage", s.getUsage)
+	mux.HandleFunc("GET /api/v1/runs/latest", s.getLatestRun)
+	mux.HandleFunc("GET /api/v1/history/certificates", s.getCertificateHistory())
+	mux.HandleFunc("GET /api/v1/history/events", s.getEventHistory())
+	mux.HandleFunc("GET /api/v1/history/notifications", s.getNotificationHistory())
+	mux.HandleFunc("GET /api/v1/history/runs", s.getRunHistory())
+	mux.HandleFunc("POST /api/v1/runs/retry-failed", s.idempotency.idempotent(s.retryFailed))
+	mux.HandleFunc("GET /api/v1/status", s.getStatus)
+	mux.HandleFunc("GET /api/v1/status/schema", s.getStatusSchema)
+	mux.HandleFunc("GET /api/v1/internal-ca/certificate", s.getInternalCACertificate)
+	if s.provider {
+		mux.HandleFunc("GET /api/v1/traefik/provider", s.getTraefikProvider)
+	}
+	if !s.forwardAuth {
+		return s.authenticate(mux)
+	}
+
+	// Traefik can't authenticate its ForwardAuth requests, and the certificate a
+	// host serves is no secret to anyone who connects to it
+	root := http.NewServeMux()
+	root.HandleFunc("GET /api/v1/status/forward-auth", s.forwardAuthStatus)
+	root.Handle("/", s.authenticate(mux))
+	return root
+}
+
+// UseConfigHash reports hash as the hash of the running configuration in the status
+func (s *Server) UseConfigHash(hash string) {
+	s.configHash = hash
+}
+
+// UseListener makes the API serve on l, such as a socket passed by systemd,
+// instead of listening on the configured address
+func (s *Server) UseListener(l net.Listener) {
+	s.listener = l
+}
+
+// Start serves the API in the background
+func (s *Server) Start() {
+	go func() {
+		addr := s.server.Addr
+		if s.listener != nil {
+			addr = s.listener.Addr().String()
+		}
+		s.logger.Printf("Serving management API on %s", addr)
+		if s.token == "" {
+			s.logger.Printf("Warning: management API has no token configured, anyone who can reach %s can use it", addr)
+		}
+
+		var err error
+		if s.listener != nil {
+			err = s.server.Serve(s.listener)
+		} else {
+			err = s.server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
+			s.logger.Printf("Management API failed: %v", err)
+		}
+	}()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+// authenticate requires the configured bearer token on every request
+func (s *Server) authenticate(next http.Handler) http.Handler {
+	if s.token == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer ")
+		if !ok || subtle.ConstantTimeCompare([]byte(token), []byte(s.token)) != 1 {
+			writeError(w, http.StatusUnauthorized, "missing or invalid bearer token")
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) getMaintenance(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, s.manager.MaintenanceState())
+}
+
+func (s *Server) enableMaintenance(w http.ResponseWriter, r *http.Request) {
+	var req struct {
+		Reason string `json:"reason"`
+	}
+	if !decodeBody(w, r, &req) {
+		return
+	}
+
+	if err := s.manager.SetMaintenance(true, req.Reason); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.manager.MaintenanceState())
+}
+
+func (s *Server) disableMaintenance(w http.ResponseWriter, r *http.Request) {
+	if err := s.manager.SetMaintenance(false, ""); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, s.manager.MaintenanceState())
+}
+
+func (s *Server) getACMEDebug(w http.ResponseWriter, r *http.Request) {
+	writeJSON(w, http.StatusOK, ACMEDebugState{Domains: s.manager.WireDebugDomains()})
+}
+
+func (s *Server) enableACMEDebug(w http.ResponseWriter, r *http.Request) {
+	if err := s.manager.SetWireDebug(r.PathValue("domain"), true); err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, ACMEDebugState{Domains: s.manager.WireDebugDomains()})
+}
+
+// disableACMEDebug conflicts with domains logged by the configuration
+func (s *Server) disableACMEDebug(w http.ResponseWriter, r *http.Request) {
+	if err := s.manager.SetWireDebug(r.PathValue("domain"), false); err != nil {
+		writeError(w, http.StatusConflict, err.Error())
+		return
+	}
+	writeJSON(w, http.StatusOK, ACMEDebugState{Domains: s.manager.WireDebugDomains()})
+}
+
+// getCertificates lists every stored certificate, or those of the domains of
+// the group in ?group=
+func (s *Server) getCertificates(w http.ResponseWriter, r *http.Request) {
+	details := s.manager.CertificateDetails()
+	if group := r.URL.Query().Get("group"); group != "" {
+		inGroup := make(map[string]bool)
+		for _, service := range certmanager.ServicesInGroup(s.manager.CheckServiceHealth(), group) {
+			inGroup[service.Domain] = true
+			for _, alias := range service.Aliases {
+				inGroup[alias.Domain] = true
+			}
+		}
+		filtered := details[:0]
+		for _, detail := range details {
+			if inGroup[detail.Domain] {
+				filtered = append(filtered, detail)
+			}
+		}
+		details = filtered
+	}
+	if details == nil {
+		details = []certmanager.CertificateDetails{}
+	}
+	writeJSON(w, http.StatusOK, details)
+}
+
+func (s *Server) renewCertificate(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	// A client that goes away cancels the order, which the next renewal resumes.
+	// During a scheduled run the request jumps the renewal queue.
+	if err := s.scheduler.RenewNow(r.Context(), domain); err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.logger.Printf("Renewed certificate for %s through the API", domain)
+	writeJSON(w, http.StatusOK, CertificateResult{Domain: domain, Result: "renewed"})
+}
+
+// reissueCertificate issues a certificate with a new key regardless of the
+// validity of the current one, optionally revoking the replaced certificate
+func (s *Server) reissueCertificate(w http.ResponseWriter, r *http.Request) {
+	var req ReissueRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "superseded"
+	}
+	code, err := certmanager.ParseRevocationReason(req.Reason)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain := r.PathValue("domain")
+	cert, err := s.manager.ReissueCertificate(r.Context(), domain, req.Revoke, code)
+	if err != nil && cert == nil {
+		writeFailure(w, err)
+		return
+	}
+	if err != nil {
+		// The new certificate is in place; only revoking the old one failed
+		writeError(w, http.StatusBadGateway, err.Error())
+		return
+	}
+
+	s.logger.Printf("Re-issued certificate for %s through the API (expires: %s)", domain, cert.ExpiresAt.Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, CertificateResult{Domain: domain, Result: "reissued"})
+}
+
+// revokeCertificate revokes the current certificate of a domain at its CA
+func (s *Server) revokeCertificate(w http.ResponseWriter, r *http.Request) {
+	var req RevokeRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Reason == "" {
+		req.Reason = "unspecified"
+	}
+	code, err := certmanager.ParseRevocationReason(req.Reason)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, err.Error())
+		return
+	}
+
+	domain := r.PathValue("domain")
+	if err := s.manager.RevokeCertificate(r.Context(), domain, code); err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.logger.Printf("Revoked certificate for %s through the API (reason: %s)", domain, req.Reason)
+	writeJSON(w, http.StatusOK, CertificateResult{Domain: domain, Result: "revoked"})
+}
+
+// rollbackCertificate reinstates the previous certificate of a domain
+func (s *Server) rollbackCertificate(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	cert, err := s.manager.RollbackCertificate(r.Context(), domain)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.logger.Printf("Rolled back certificate for %s through the API (expires: %s)", domain, cert.ExpiresAt.Format(time.RFC3339))
+	writeJSON(w, http.StatusOK, CertificateResult{Domain: domain, Result: "rolled_back"})
+}
+
+func (s *Server) getHolds(w http.ResponseWriter, r *http.Request) {
+	holds, err := s.manager.Holds()
+	if err != nil {
+		writeError(w, http.StatusInternalServerError, err.Error())
+		return
+	}
+
+	list := make([]certmanager.Hold, 0, len(holds))
+	for _, hold := range holds {
+		list = append(list, hold)
+	}
+	sort.Slice(list, func(i, j int) bool { return list[i].Domain < list[j].Domain })
+	writeJSON(w, http.StatusOK, list)
+}
+
+func (s *Server) holdDomain(w http.ResponseWriter, r *http.Request) {
+	var req HoldRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if !req.Until.IsZero() && !req.Until.After(time.Now()) {
+		writeError(w, http.StatusBadRequest, "until must be in the future")
+		return
+	}
+
+	hold, err := s.manager.HoldDomain(r.PathValue("domain"), req.Until, req.Reason)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, hold)
+}
+
+func (s *Server) releaseDomain(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	if err := s.manager.ReleaseDomain(domain); err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, CertificateResult{Domain: domain, Result: "resumed"})
+}
+
+func (s *Server) importCertificate(w http.ResponseWriter, r *http.Request) {
+	var req ImportRequest
+	if !decodeBody(w, r, &req) {
+		return
+	}
+	if req.Certificate == "" || req.PrivateKey == "" {
+		writeError(w, http.StatusBadRequest, "certificate and private_key are required")
+		return
+	}
+
+	ctx, cancel := context.WithTimeout(r.Context(), importTimeout)
+	defer cancel()
+
+	domain, err := s.manager.ImportCertificate(ctx, []byte(req.Certificate), []byte(req.PrivateKey))
+	if err != nil {
+		// Import failures are caused by the submitted certificate
+		writeError(w, http.StatusUnprocessableEntity, err.Error())
+		return
+	}
+
+	s.logger.Printf("Imported certificate for %s through the API", domain)
+	writeJSON(w, http.StatusCreated, CertificateResult{Domain: domain, Result: "imported"})
+}
+
+func (s *Server) deleteCertificate(w http.ResponseWriter, r *http.Request) {
+	domain := r.PathValue("domain")
+	if err := s.manager.DeleteCertificate(domain); err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.logger.Printf("Deleted certificate for %s through the API", domain)
+	writeJSON(w, http.StatusOK, CertificateResult{Domain: domain, Result: "deleted"})
+}
+
+// getUsage reports per-domain usage over the last ?days=N days, 30 by default
+func (s *Server) getUsage(w http.ResponseWriter, r *http.Request) {
+	days := 30
+	if value := r.URL.Query().Get("days"); value != "" {
+		n, err := strconv.Atoi(value)
+		if err != nil || n < 1 {
+			writeError(w, http.StatusBadRequest, "days must be a positive number")
+			return
+		}
+		days = n
+	}
+
+	writeJSON(w, http.StatusOK, s.manager.Usage(time.Now().AddDate(0, 0, -days)))
+}
+
+func (s *Server) getLatestRun(w http.ResponseWriter, r *http.Request) {
+	summary, err := s.manager.LatestRunSummary()
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// retryFailed re-attempts the domains whose last renewal failed and returns
+// the summary of the run; ?now=true also retries those still backing off
+func (s *Server) retryFailed(w http.ResponseWriter, r *http.Request) {
+	now := false
+	if value := r.URL.Query().Get("now"); value != "" {
+		var err error
+		if now, err = strconv.ParseBool(value); err != nil {
+			writeError(w, http.StatusBadRequest, "now must be true or false")
+			return
+		}
+	}
+
+	summary, err := s.scheduler.RetryFailed(r.Context(), now)
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+
+	s.logger.Printf("Retried %d failed domains through the API: %d renewed, %d failed", summary.Checked, summary.Renewed, summary.Failed)
+	writeJSON(w, http.StatusOK, summary)
+}
+
+// getStatus returns the certificate status in the versioned schema of the health
+// command, for every service or those of the group in ?group=, and the hash of
+// the running configuration
+func (s *Server) getStatus(w http.ResponseWriter, r *http.Request) {
+	services := s.manager.CheckServiceHealth()
+	if group := r.URL.Query().Get("group"); group != "" {
+		services = certmanager.ServicesInGroup(services, group)
+	}
+	report := status.NewReport("status", services, nil)
+	report.ConfigHash = s.configHash
+	writeJSON(w, http.StatusOK, report)
+}
+
+func (s *Server) getStatusSchema(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/schema+json")
+	w.Write(status.Schema)
+}
+
+// getInternalCACertificate serves the internal CA certificate for client trust stores
+func (s *Server) getInternalCACertificate(w http.ResponseWriter, r *http.Request) {
+	certPEM, err := s.manager.InternalCACertificate()
+	if err != nil {
+		writeFailure(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "application/x-pem-file")
+	w.Write(certPEM)
+}
+
+// forwardAuthStatus answers Traefik's ForwardAuth middleware with the status of
+// the certificate for the requested host in response headers. It never denies
+// the request; services behind the middleware decide what to do with the headers.
+func (s *Server) forwardAuthStatus(w http.ResponseWriter, r *http.Request) {
+	host := r.Header.Get("X-Forwarded-Host")
+	if host == "" {
+		host = r.Host
+	}
+	if h, _, err := net.SplitHostPort(host); err == nil {
+		host = h
+	}
+
+	w.Header().Set(HeaderCertSchemaVersion, strconv.Itoa(status.SchemaVersion))
+	cert, found := s.cachedStatus().Certificate(host)
+	if !found {
+		w.Header().Set(HeaderCertStatus, "unknown")
+		w.WriteHeader(http.StatusOK)
+		return
+	}
+	w.Header().Set(HeaderCertStatus, cert.Status)
+	w.Header().Set(HeaderCertExpiresAt, cert.ExpiresAt.Format(time.RFC3339))
+	w.Header().Set(HeaderCertDaysUntilExpiry, strconv.Itoa(cert.DaysUntilExpiry))
+	w.WriteHeader(http.StatusOK)
+}
+
+func (s *Server) cachedStatus() *status.Report {
+	s.statusMu.Lock()
+	defer s.statusMu.Unlock()
+
+	if s.status == nil || time.Since(s.statusAge) > forwardAuthCacheTTL {
+		s.status = status.NewReport("status", s.manager.CheckServiceHealth(), nil)
+		s.statusAge = time.Now()
+	}
+	return s.status
+}
+
+// errorStatus maps certificate manager errors to HTTP status codes
+func errorStatus(err error) int {
+	switch {
+	case errors.Is(err, certmanager.ErrCertificateNotFound), errors.Is(err, certmanager.ErrNoRunSummary),
+		errors.Is(err, certmanager.ErrNotHeld):
+		return http.StatusNotFound
+	case errors.Is(err, certmanager.ErrDomainLocked), errors.Is(err, certmanager.ErrDomainConfigured),
+		errors.Is(err, certmanager.ErrDomainUnmanaged), errors.Is(err, certmanager.ErrNoPreviousCertificate),
+		errors.Is(err, certmanager.ErrDomainHeld):
+		return http.StatusConflict
+	case errors.Is(err, certmanager.ErrMaintenance):
+		return http.StatusServiceUnavailable
+	case errors.Is(err, certmanager.ErrRateLimited):
+		return http.StatusTooManyRequests
+	case errors.Is(err, certmanager.ErrChallengeFailed), errors.Is(err, certmanager.ErrTraefikUnreachable):
+		return http.StatusBadGateway
+	default:
+		return http.StatusInternalServerError
+	}
+}
+
+// decodeBody reads an optional JSON body into v. It writes the error response
+// and returns false if the body is invalid.
+func decodeBody(w http.ResponseWriter, r *http.Request, v interface{}) bool {
+	err := json.NewDecoder(http.MaxBytesReader(w, r.Body, maxBodySize)).Decode(v)
+	if err != nil && err != io.EOF {
+		writeError(w, http.StatusBadRequest, "invalid request body: "+err.Error())
+		return false
+	}
+	return true
+}
+
+func writeJSON(w http.ResponseWriter, status int, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(v)
+}
+
+func writeError(w http.ResponseWriter, status int, msg string) {
+	writeJSON(w, status, ErrorResponse{Error: msg})
+}
+
+// writeFailure writes err with the status and code of its class
+func writeFailure(w http.ResponseWriter, err error) {
+	writeJSON(w, errorStatus(err), ErrorResponse{Error: err.Error(), Code: certmanager.ErrorCode(err)})
+}
@@ -0,0 +1,586 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+)
+
+type fakeManager struct {
+	maintenance certmanager.MaintenanceState
+	locked      bool // maintenance is held on by the configuration
+	renewals    int
+	deleted     map[string]bool
+	rollbacks   int    // generations left to roll back to
+	reissues    []uint // revocation reason of every re-issuance that revoked
+	usageSince  time.Time
+	wireDebug   []string
+	lastRun     *certmanager.RunSummary
+	retries     []bool // now of every retry of failed domains
+	services    []certmanager.ServiceHealth
+	holds       map[string]certmanager.Hold
+	provider    certmanager.TraefikDynamicTLS
+	details     []certmanager.CertificateDetails
+	revocations map[string]uint // reason of every revoked domain
+}
+
+func (f *fakeManager) MaintenanceState() certmanager.MaintenanceState {
+	return f.maintenance
+}
+
+func (f *fakeManager) SetMaintenance(enabled bool, reason string) error {
+	if !enabled && f.locked {
+		return errors.New("maintenance mode is enabled in the configuration")
+	}
+	f.maintenance = certmanager.MaintenanceState{Enabled: enabled, Reason: reason}
+	return nil
+}
+
+func (f *fakeManager) WireDebugDomains() []string {
+	return append([]string{}, f.wireDebug...)
+}
+
+func (f *fakeManager) SetWireDebug(domain string, enabled bool) error {
+	if !enabled {
+		return fmt.Errorf("ACME wire logging of %s is enabled in the configuration", domain)
+	}
+	f.wireDebug = append(f.wireDebug, domain)
+	return nil
+}
+
+func (f *fakeManager) RenewCertificate(ctx context.Context, domain string) error {
+	if f.maintenance.Enabled {
+		return certmanager.ErrMaintenance
+	}
+	f.renewals++
+	return nil
+}
+
+func (f *fakeManager) ImportCertificate(ctx context.Context, certPEM, keyPEM []byte) (string, error) {
+	return "imported.example.com", nil
+}
+
+func (f *fakeManager) DeleteCertificate(domain string) error {
+	if f.deleted[domain] {
+		return fmt.Errorf("%w: %s", certmanager.ErrCertificateNotFound, domain)
+	}
+	if f.deleted == nil {
+		f.deleted = make(map[string]bool)
+	}
+	f.deleted[domain] = true
+	return nil
+}
+
+func (f *fakeManager) CertificateDetails() []certmanager.CertificateDetails {
+	return append([]certmanager.CertificateDetails{}, f.details...)
+}
+
+func (f *fakeManager) RevokeCertificate(ctx context.Context, domain string, reason uint) error {
+	if f.revocations == nil {
+		f.revocations = make(map[string]uint)
+	}
+	f.revocations[domain] = reason
+	return nil
+}
+
+func (f *fakeManager) ReissueCertificate(ctx context.Context, domain string, revoke bool, reason uint) (*certmanager.Certificate, error) {
+	if f.maintenance.Enabled {
+		return nil, certmanager.ErrMaintenance
+	}
+	if revoke {
+		f.reissues = append(f.reissues, reason)
+	}
+	return &certmanager.Certificate{Domain: domain, ExpiresAt: time.Now().Add(90 * 24 * time.Hour)}, nil
+}
+
+func (f *fakeManager) RollbackCertificate(ctx context.Context, domain string) (*certmanager.Certificate, error) {
+	if f.rollbacks == 0 {
+		return nil, fmt.Errorf("%w: %s", certmanager.ErrNoPreviousCertificate, domain)
+	}
+	f.rollbacks--
+	return &certmanager.Certificate{Domain: domain, ExpiresAt: time.Now().Add(30 * 24 * time.Hour)}, nil
+}
+
+func (f *fakeManager) HoldDomain(domain string, until time.Time, reason string) (certmanager.Hold, error) {
+	if f.holds == nil {
+		f.holds = make(map[string]certmanager.Hold)
+	}
+	hold := certmanager.Hold{Domain: domain, Reason: reason, Since: time.Now(), Until: until}
+	f.holds[domain] = hold
+	return hold, nil
+}
+
+func (f *fakeManager) ReleaseDomain(domain string) error {
+	if _, ok := f.holds[domain]; !ok {
+		return fmt.Errorf("%w: %s", certmanager.ErrNotHeld, domain)
+	}
+	delete(f.holds, domain)
+	return nil
+}
+
+func (f *fakeManager) Holds() (map[string]certmanager.Hold, error) {
+	return f.holds, nil
+}
+
+func (f *fakeManager) Usage(since time.Time) []certmanager.DomainUsage {
+	f.usageSince = since
+	return []certmanager.DomainUsage{{Domain: "noisy.example.com", UsageCounts: certmanager.UsageCounts{Orders: 12, FailedOrders: 11}}}
+}
+
+func (f *fakeManager) LatestRunSummary() (*certmanager.RunSummary, error) {
+	if f.lastRun == nil {
+		return nil, certmanager.ErrNoRunSummary
+	}
+	return f.lastRun, nil
+}
+
+func (f *fakeManager) CheckServiceHealth() []certmanager.ServiceHealth {
+	return f.services
+}
+
+func (f *fakeManager) InternalCACertificate() ([]byte, error) {
+	return []byte("-----BEGIN CERTIFICATE-----\n"), nil
+}
+
+func (f *fakeManager) TraefikProviderConfiguration() certmanager.TraefikDynamicTLS {
+	return f.provider
+}
+
+func (f *fakeManager) RenewNow(ctx context.Context, domain string) error {
+	return f.RenewCertificate(ctx, domain)
+}
+
+func (f *fakeManager) RetryFailed(ctx context.Context, now bool) (*certmanager.RunSummary, error) {
+	if f.maintenance.Enabled {
+		return nil, certmanager.ErrMaintenance
+	}
+	f.retries = append(f.retries, now)
+	return &certmanager.RunSummary{Trigger: "retry", Status: "succeeded", Checked: 1, Renewed: 1}, nil
+}
+
+func newTestServer(token string, manager *fakeManager) *Server {
+	return NewServer(config.API{ListenAddress: ":0", Token: token, IdempotencyTTL: "1h"}, manager, manager, nil)
+}
+
+func do(t *testing.T, handler http.Handler, method, path, body, token string, headers ...string) *httptest.ResponseRecorder {
+	t.Helper()
+
+	req := httptest.NewRequest(method, path, strings.NewReader(body))
+	if token != "" {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	for i := 0; i+1 < len(headers); i += 2 {
+		req.Header.Set(headers[i], headers[i+1])
+	}
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestServer_Maintenance(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodPut, "/api/v1/maintenance", `{"reason":"change freeze"}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /maintenance = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var state certmanager.MaintenanceState
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !state.Enabled || state.Reason != "change freeze" {
+		t.Errorf("state = %+v, want enabled with reason", state)
+	}
+
+	rec = do(t, handler, http.MethodDelete, "/api/v1/maintenance", "", "")
+	if rec.Code != http.StatusOK || manager.maintenance.Enabled {
+		t.Errorf("DELETE /maintenance = %d, enabled = %v; want 200 and disabled", rec.Code, manager.maintenance.Enabled)
+	}
+
+	manager.locked = true
+	manager.maintenance.Enabled = true
+	rec = do(t, handler, http.MethodDelete, "/api/v1/maintenance", "", "")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("DELETE /maintenance with configured maintenance = %d, want 409", rec.Code)
+	}
+}
+
+func TestServer_ACMEDebug(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodPut, "/api/v1/acme-debug/example.com", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("PUT /acme-debug/example.com = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+
+	var state ACMEDebugState
+	if err := json.NewDecoder(rec.Body).Decode(&state); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(state.Domains) != 1 || state.Domains[0] != "example.com" {
+		t.Errorf("domains = %v, want [example.com]", state.Domains)
+	}
+
+	rec = do(t, handler, http.MethodDelete, "/api/v1/acme-debug/example.com", "", "")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("DELETE /acme-debug for a configured domain = %d, want 409", rec.Code)
+	}
+}
+
+func TestServer_LatestRun(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodGet, "/api/v1/runs/latest", "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /runs/latest before any run = %d, want 404", rec.Code)
+	}
+
+	manager.lastRun = &certmanager.RunSummary{Run: 3, Status: "failed", Checked: 2, Failed: 1}
+	rec = do(t, handler, http.MethodGet, "/api/v1/runs/latest", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /runs/latest = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var summary certmanager.RunSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if summary.Run != 3 || summary.Status != "failed" || summary.Failed != 1 {
+		t.Errorf("summary = %+v, want run 3 with one failure", summary)
+	}
+}
+
+func TestServer_Rollback(t *testing.T) {
+	handler := newTestServer("", &fakeManager{rollbacks: 1}).Handler()
+
+	rec := do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/rollback", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("rollback = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var result CertificateResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result != (CertificateResult{Domain: "example.com", Result: "rolled_back"}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	rec = do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/rollback", "", "")
+	if rec.Code != http.StatusConflict {
+		t.Errorf("rollback without a previous certificate = %d, want 409", rec.Code)
+	}
+}
+
+func TestServer_Reissue(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/reissue", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reissue = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var result CertificateResult
+	if err := json.NewDecoder(rec.Body).Decode(&result); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if result != (CertificateResult{Domain: "example.com", Result: "reissued"}) {
+		t.Errorf("result = %+v", result)
+	}
+
+	rec = do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/reissue", `{"revoke":true,"reason":"keyCompromise"}`, "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("reissue revoking = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if len(manager.reissues) != 1 || manager.reissues[0] != 1 {
+		t.Errorf("revocation reasons = %v, want [1] (keyCompromise)", manager.reissues)
+	}
+
+	rec = do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/reissue", `{"revoke":true,"reason":"bored"}`, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("reissue with an unknown reason = %d, want 400", rec.Code)
+	}
+}
+
+func TestServer_Hold(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodPut, "/api/v1/certificates/example.com/hold", `{"until":"2000-01-01T00:00:00Z"}`, "")
+	if rec.Code != http.StatusBadRequest {
+		t.Errorf("hold ending in the past = %d, want 400", rec.Code)
+	}
+
+	until := time.Now().Add(72 * time.Hour).UTC().Truncate(time.Second)
+	rec = do(t, handler, http.MethodPut, "/api/v1/certificates/example.com/hold",
+		fmt.Sprintf(`{"until":%q,"reason":"migration"}`, until.Format(time.RFC3339)), "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("hold = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	if hold := manager.holds["example.com"]; !hold.Until.Equal(until) || hold.Reason != "migration" {
+		t.Errorf("hold = %+v", hold)
+	}
+
+	rec = do(t, handler, http.MethodGet, "/api/v1/holds", "", "")
+	var holds []certmanager.Hold
+	if err := json.NewDecoder(rec.Body).Decode(&holds); err != nil {
+		t.Fatalf("failed to decode holds: %v", err)
+	}
+	if len(holds) != 1 || holds[0].Domain != "example.com" {
+		t.Errorf("holds = %+v", holds)
+	}
+
+	rec = do(t, handler, http.MethodDelete, "/api/v1/certificates/example.com/hold", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("release = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	rec = do(t, handler, http.MethodDelete, "/api/v1/certificates/example.com/hold", "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("release of a domain that is not held = %d, want 404", rec.Code)
+	}
+}
+
+func TestServer_InternalCACertificate(t *testing.T) {
+	handler := newTestServer("", &fakeManager{}).Handler()
+
+	rec := do(t, handler, http.MethodGet, "/api/v1/internal-ca/certificate", "", "")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/x-pem-file" {
+		t.Fatalf("GET /internal-ca/certificate = %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if !strings.HasPrefix(rec.Body.String(), "-----BEGIN CERTIFICATE-----") {
+		t.Errorf("body = %q, want a PEM certificate", rec.Body.String())
+	}
+}
+
+func TestServer_Status(t *testing.T) {
+	expires := time.Now().Add(10 * 24 * time.Hour).UTC().Truncate(time.Second)
+	manager := &fakeManager{services: []certmanager.ServiceHealth{{
+		Service: "web",
+		Domain:  "www.example.com",
+		Group:   "production",
+		Status:  "needs_renewal",
+		Primary: &certmanager.CertificateHealth{Domain: "www.example.com", Status: "needs_renewal", ExpiresAt: expires, DaysUntilExpiry: 10},
+	}}}
+	server := newTestServer("secret", manager)
+	server.UseConfigHash("0123abcd")
+	handler := server.Handler()
+
+	rec := do(t, handler, http.MethodGet, "/api/v1/status", "", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /status = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var report status.Report
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if report.SchemaVersion != status.SchemaVersion || report.Mode != "status" || report.Summary.NeedsRenewal != 1 {
+		t.Errorf("report = %+v, want the status of one certificate needing renewal", report)
+	}
+	if report.ConfigHash != "0123abcd" {
+		t.Errorf("config_hash = %q, want the hash of the running configuration", report.ConfigHash)
+	}
+
+	rec = do(t, handler, http.MethodGet, "/api/v1/status?group=staging", "", "secret")
+	report = status.Report{}
+	if err := json.NewDecoder(rec.Body).Decode(&report); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if rec.Code != http.StatusOK || len(report.Services) != 0 {
+		t.Errorf("GET /status?group=staging = %d with %d services, want none", rec.Code, len(report.Services))
+	}
+
+	rec = do(t, handler, http.MethodGet, "/api/v1/status/schema", "", "secret")
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "application/schema+json" {
+		t.Errorf("GET /status/schema = %d %s, want 200 application/schema+json", rec.Code, rec.Header().Get("Content-Type"))
+	}
+
+	// The ForwardAuth endpoint is only served when enabled
+	rec = do(t, handler, http.MethodGet, "/api/v1/status/forward-auth", "", "", "X-Forwarded-Host", "www.example.com")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /status/forward-auth while disabled = %d, want 401", rec.Code)
+	}
+}
+
+func TestServer_ForwardAuth(t *testing.T) {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	manager := &fakeManager{services: []certmanager.ServiceHealth{{
+		Service: "web",
+		Domain:  "www.example.com",
+		Status:  "valid",
+		Primary: &certmanager.CertificateHealth{Domain: "www.example.com", Status: "valid", ExpiresAt: expires, DaysUntilExpiry: 42},
+	}}}
+	handler := NewServer(config.API{ListenAddress: ":0", Token: "secret", ForwardAuth: true}, manager, manager, nil).Handler()
+
+	// Traefik sends no token, and the request must never be denied
+	rec := do(t, handler, http.MethodGet, "/api/v1/status/forward-auth", "", "", "X-Forwarded-Host", "www.example.com:443")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /status/forward-auth = %d, want 200", rec.Code)
+	}
+	want := map[string]string{
+		HeaderCertStatus:          "valid",
+		HeaderCertExpiresAt:       "2030-01-01T00:00:00Z",
+		HeaderCertDaysUntilExpiry: "42",
+		HeaderCertSchemaVersion:   "1",
+	}
+	for header, value := range want {
+		if got := rec.Header().Get(header); got != value {
+			t.Errorf("%s = %q, want %q", header, got, value)
+		}
+	}
+
+	rec = do(t, handler, http.MethodGet, "/api/v1/status/forward-auth", "", "", "X-Forwarded-Host", "other.example.org")
+	if rec.Code != http.StatusOK || rec.Header().Get(HeaderCertStatus) != "unknown" {
+		t.Errorf("unknown host = %d with status %q, want 200 unknown", rec.Code, rec.Header().Get(HeaderCertStatus))
+	}
+
+	// Everything else still requires the token
+	rec = do(t, handler, http.MethodGet, "/api/v1/status", "", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /status without token = %d, want 401", rec.Code)
+	}
+}
+
+func TestServer_RetryFailed(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodPost, "/api/v1/runs/retry-failed", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("POST /runs/retry-failed = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var summary certmanager.RunSummary
+	if err := json.NewDecoder(rec.Body).Decode(&summary); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if summary.Trigger != "retry" || summary.Renewed != 1 {
+		t.Errorf("summary = %+v, want a retry run with one renewal", summary)
+	}
+
+	do(t, handler, http.MethodPost, "/api/v1/runs/retry-failed?now=true", "", "")
+	if len(manager.retries) != 2 || manager.retries[0] || !manager.retries[1] {
+		t.Errorf("retries = %v, want [false true]", manager.retries)
+	}
+
+	if rec := do(t, handler, http.MethodPost, "/api/v1/runs/retry-failed?now=soon", "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("POST with an invalid now = %d, want 400", rec.Code)
+	}
+
+	manager.maintenance.Enabled = true
+	if rec := do(t, handler, http.MethodPost, "/api/v1/runs/retry-failed", "", ""); rec.Code != http.StatusServiceUnavailable {
+		t.Errorf("POST during maintenance = %d, want 503", rec.Code)
+	}
+}
+
+func TestServer_Usage(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodGet, "/api/v1/usage?days=7", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /usage = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var usage []certmanager.DomainUsage
+	if err := json.NewDecoder(rec.Body).Decode(&usage); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(usage) != 1 || usage[0].Domain != "noisy.example.com" || usage[0].FailedOrders != 11 {
+		t.Errorf("usage = %+v", usage)
+	}
+	if since := time.Since(manager.usageSince); since < 7*24*time.Hour-time.Minute || since > 7*24*time.Hour+time.Minute {
+		t.Errorf("usage requested since %v ago, want 7 days", since)
+	}
+
+	if rec := do(t, handler, http.MethodGet, "/api/v1/usage?days=zero", "", ""); rec.Code != http.StatusBadRequest {
+		t.Errorf("GET /usage?days=zero = %d, want 400", rec.Code)
+	}
+}
+
+func TestServer_RequiresToken(t *testing.T) {
+	handler := newTestServer("secret", &fakeManager{}).Handler()
+
+	if rec := do(t, handler, http.MethodGet, "/api/v1/maintenance", "", ""); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request without token = %d, want 401", rec.Code)
+	}
+	if rec := do(t, handler, http.MethodGet, "/api/v1/maintenance", "", "wrong"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("request with wrong token = %d, want 401", rec.Code)
+	}
+	if rec := do(t, handler, http.MethodGet, "/api/v1/maintenance", "", "secret"); rec.Code != http.StatusOK {
+		t.Errorf("request with token = %d, want 200", rec.Code)
+	}
+}
+
+func TestServer_IdempotentRenew(t *testing.T) {
+	manager := &fakeManager{}
+	handler := newTestServer("", manager).Handler()
+
+	first := do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/renew", "", "", "Idempotency-Key", "renew-1")
+	if first.Code != http.StatusOK {
+		t.Fatalf("renew = %d, want 200: %s", first.Code, first.Body.String())
+	}
+
+	retry := do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/renew", "", "", "Idempotency-Key", "renew-1")
+	if retry.Code != http.StatusOK || retry.Body.String() != first.Body.String() {
+		t.Errorf("retry = %d %q, want the original response", retry.Code, retry.Body.String())
+	}
+	if retry.Header().Get("Idempotent-Replayed") != "true" {
+		t.Error("expected the retry to be marked as replayed")
+	}
+	if manager.renewals != 1 {
+		t.Errorf("renewals = %d, want 1", manager.renewals)
+	}
+
+	// Reusing a key for a different request is rejected
+	other := do(t, handler, http.MethodPost, "/api/v1/certificates/api.example.com/renew", "", "", "Idempotency-Key", "renew-1")
+	if other.Code != http.StatusUnprocessableEntity {
+		t.Errorf("reused key = %d, want 422", other.Code)
+	}
+
+	// Requests without a key are not deduplicated
+	do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/renew", "", "")
+	if manager.renewals != 2 {
+		t.Errorf("renewals = %d, want 2", manager.renewals)
+	}
+}
+
+func TestServer_IdempotentDelete(t *testing.T) {
+	handler := newTestServer("", &fakeManager{}).Handler()
+
+	first := do(t, handler, http.MethodDelete, "/api/v1/certificates/legacy.example.com", "", "", "Idempotency-Key", "delete-1")
+	retry := do(t, handler, http.MethodDelete, "/api/v1/certificates/legacy.example.com", "", "", "Idempotency-Key", "delete-1")
+	if first.Code != http.StatusOK || retry.Code != http.StatusOK {
+		t.Errorf("delete = %d, retry = %d; want both 200", first.Code, retry.Code)
+	}
+
+	again := do(t, handler, http.MethodDelete, "/api/v1/certificates/legacy.example.com", "", "")
+	if again.Code != http.StatusNotFound {
+		t.Errorf("second delete without key = %d, want 404", again.Code)
+	}
+}
+
+func TestServer_ServerErrorsAreNotReplayed(t *testing.T) {
+	manager := &fakeManager{maintenance: certmanager.MaintenanceState{Enabled: true}}
+	handler := newTestServer("", manager).Handler()
+
+	rec := do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/renew", "", "", "Idempotency-Key", "renew-2")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("renew during maintenance = %d, want 503", rec.Code)
+	}
+
+	manager.maintenance.Enabled = false
+	rec = do(t, handler, http.MethodPost, "/api/v1/certificates/example.com/renew", "", "", "Idempotency-Key", "renew-2")
+	if rec.Code != http.StatusOK || manager.renewals != 1 {
+		t.Errorf("retry after maintenance = %d with %d renewals, want 200 and 1", rec.Code, manager.renewals)
+	}
+}
@@ -0,0 +1,131 @@
+package api
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/status"
+)
+
+// Client calls the management API of a running daemon, so CLI commands can act
+// through it instead of opening the daemon's storage path a second time
+type Client struct {
+	baseURL string
+	token   string
+	client  *http.Client
+}
+
+// ResponseError is a request the API answered with an error
+type ResponseError struct {
+	StatusCode int
+	Message    string
+	Code       string // class of the failure, see certmanager.ErrorCode
+}
+
+func (e *ResponseError) Error() string {
+	return fmt.Sprintf("management API returned %d %s: %s", e.StatusCode, http.StatusText(e.StatusCode), e.Message)
+}
+
+// Is matches the sentinel of the class the daemon reported, so a failure of
+// a remote operation can be told apart like a local one
+func (e *ResponseError) Is(target error) bool {
+	return e.Code != "" && certmanager.ErrorCode(target) == e.Code
+}
+
+// NewClient returns a client of the API served at baseURL, such as
+// https://certmgr:9000. The token is sent as bearer token when set.
+func NewClient(baseURL, token string) *Client {
+	return &Client{
+		baseURL: strings.TrimSuffix(baseURL, "/"),
+		token:   token,
+		client:  &http.Client{},
+	}
+}
+
+// Certificates lists the stored certificates, or those of the domains of a group
+func (c *Client) Certificates(ctx context.Context, group string) ([]certmanager.CertificateDetails, error) {
+	var details []certmanager.CertificateDetails
+	if err := c.do(ctx, http.MethodGet, "/api/v1/certificates"+groupQuery(group), nil, &details); err != nil {
+		return nil, err
+	}
+	return details, nil
+}
+
+// Status returns the status report of every service, or those of a group
+func (c *Client) Status(ctx context.Context, group string) (*status.Report, error) {
+	var report status.Report
+	if err := c.do(ctx, http.MethodGet, "/api/v1/status"+groupQuery(group), nil, &report); err != nil {
+		return nil, err
+	}
+	return &report, nil
+}
+
+// Renew renews the certificate of a domain now
+func (c *Client) Renew(ctx context.Context, domain string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/certificates/"+url.PathEscape(domain)+"/renew", nil, nil)
+}
+
+// Revoke revokes the current certificate of a domain with a reason such as
+// keyCompromise
+func (c *Client) Revoke(ctx context.Context, domain, reason string) error {
+	return c.do(ctx, http.MethodPost, "/api/v1/certificates/"+url.PathEscape(domain)+"/revoke", RevokeRequest{Reason: reason}, nil)
+}
+
+func groupQuery(group string) string {
+	if group == "" {
+		return ""
+	}
+	return "?group=" + url.QueryEscape(group)
+}
+
+// do sends body as JSON, if any, and decodes the response into out, if any
+func (c *Client) do(ctx context.Context, method, path string, body, out interface{}) error {
+	var reader io.Reader
+	if body != nil {
+		data, err := json.Marshal(body)
+		if err != nil {
+			return fmt.Errorf("failed to encode request: %w", err)
+		}
+		reader = bytes.NewReader(data)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, method, c.baseURL+path, reader)
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	if body != nil {
+		req.Header.Set("Content-Type", "application/json")
+	}
+	if c.token != "" {
+		req.Header.Set("Authorization", "Bearer "+c.token)
+	}
+
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to reach management API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		var apiErr ErrorResponse
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, maxBodySize))
+		if json.Unmarshal(data, &apiErr) != nil || apiErr.Error == "" {
+			apiErr.Error = strings.TrimSpace(string(data))
+		}
+		return &ResponseError{StatusCode: resp.StatusCode, Message: apiErr.Error, Code: apiErr.Code}
+	}
+	if out == nil {
+		return nil
+	}
+	if err := json.NewDecoder(resp.Body).Decode(out); err != nil {
+		return fmt.Errorf("failed to decode response of %s: %w", path, err)
+	}
+	return nil
+}
@@ -0,0 +1,72 @@
+package api
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+func TestClient(t *testing.T) {
+	expires := time.Now().Add(10 * 24 * time.Hour).UTC().Truncate(time.Second)
+	manager := &fakeManager{
+		services: []certmanager.ServiceHealth{
+			{Service: "web", Domain: "www.example.com", Group: "production", Status: "needs_renewal",
+				Primary: &certmanager.CertificateHealth{Domain: "www.example.com", Status: "needs_renewal", ExpiresAt: expires}},
+			{Service: "api", Domain: "api.example.com", Group: "staging", Status: "valid",
+				Primary: &certmanager.CertificateHealth{Domain: "api.example.com", Status: "valid"}},
+		},
+		details: []certmanager.CertificateDetails{
+			{CertificateHealth: certmanager.CertificateHealth{Domain: "www.example.com", ExpiresAt: expires}, KeyType: "EC256"},
+			{CertificateHealth: certmanager.CertificateHealth{Domain: "api.example.com"}, KeyType: "RSA2048"},
+		},
+	}
+	server := httptest.NewServer(newTestServer("secret", manager).Handler())
+	defer server.Close()
+	client := NewClient(server.URL+"/", "secret")
+	ctx := context.Background()
+
+	details, err := client.Certificates(ctx, "")
+	if err != nil || len(details) != 2 {
+		t.Fatalf("Certificates() = %d certificates, %v", len(details), err)
+	}
+	details, err = client.Certificates(ctx, "production")
+	if err != nil || len(details) != 1 || details[0].Domain != "www.example.com" || details[0].KeyType != "EC256" || !details[0].ExpiresAt.Equal(expires) {
+		t.Errorf("Certificates(production) = %+v, %v", details, err)
+	}
+
+	report, err := client.Status(ctx, "staging")
+	if err != nil || len(report.Services) != 1 || report.Services[0].Domain != "api.example.com" {
+		t.Errorf("Status(staging) = %+v, %v", report, err)
+	}
+
+	if err := client.Renew(ctx, "www.example.com"); err != nil || manager.renewals != 1 {
+		t.Errorf("Renew() = %v after %d renewals", err, manager.renewals)
+	}
+	if err := client.Revoke(ctx, "www.example.com", "keyCompromise"); err != nil || manager.revocations["www.example.com"] != 1 {
+		t.Errorf("Revoke() = %v, revocations %v", err, manager.revocations)
+	}
+
+	// Errors carry the status and message of the API
+	manager.maintenance.Enabled = true
+	var respErr *ResponseError
+	err = client.Renew(ctx, "www.example.com")
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusServiceUnavailable || respErr.Message == "" {
+		t.Errorf("Renew() in maintenance = %v, want a 503", err)
+	}
+	if !errors.Is(err, certmanager.ErrMaintenance) || errors.Is(err, certmanager.ErrRateLimited) {
+		t.Errorf("Renew() in maintenance = %v (code %q), want it to match ErrMaintenance only", err, respErr.Code)
+	}
+	err = client.Revoke(ctx, "www.example.com", "bored")
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("Revoke() with an unknown reason = %v, want a 400", err)
+	}
+	_, err = NewClient(server.URL, "wrong").Status(ctx, "")
+	if !errors.As(err, &respErr) || respErr.StatusCode != http.StatusUnauthorized {
+		t.Errorf("Status() with a wrong token = %v, want a 401", err)
+	}
+}
@@ -0,0 +1,87 @@
+package api
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/metadata"
+)
+
+// maxHistoryLimit bounds the entries one history request returns
+const maxHistoryLimit = 1000
+
+// History answers historical queries from the history database
+type History interface {
+	Certificates(ctx context.Context, f metadata.Filter) ([]metadata.Certificate, error)
+	Events(ctx context.Context, f metadata.Filter) ([]metadata.Event, error)
+	Notifications(ctx context.Context, f metadata.Filter) ([]metadata.Notification, error)
+	Runs(ctx context.Context, f metadata.Filter) ([]metadata.Run, error)
+}
+
+// UseHistory serves historical queries from h. Without it, the history
+// endpoints answer 404.
+func (s *Server) UseHistory(h History) {
+	s.history = h
+}
+
+// historyHandler serves a history query filtered by ?domain=, ?since= (RFC
+// 3339) and ?limit=
+func (s *Server) historyHandler(query func(History, context.Context, metadata.Filter) (interface{}, error)) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if s.history == nil {
+			writeError(w, http.StatusNotFound, "no history database is configured")
+			return
+		}
+
+		filter := metadata.Filter{Domain: r.URL.Query().Get("domain")}
+		if value := r.URL.Query().Get("since"); value != "" {
+			since, err := time.Parse(time.RFC3339, value)
+			if err != nil {
+				writeError(w, http.StatusBadRequest, "since must be an RFC 3339 time")
+				return
+			}
+			filter.Since = since
+		}
+		if value := r.URL.Query().Get("limit"); value != "" {
+			n, err := strconv.Atoi(value)
+			if err != nil || n < 1 || n > maxHistoryLimit {
+				writeError(w, http.StatusBadRequest, "limit must be a number from 1 to "+strconv.Itoa(maxHistoryLimit))
+				return
+			}
+			filter.Limit = n
+		}
+
+		result, err := query(s.history, r.Context(), filter)
+		if err != nil {
+			writeError(w, http.StatusInternalServerError, err.Error())
+			return
+		}
+		writeJSON(w, http.StatusOK, result)
+	}
+}
+
+func (s *Server) getCertificateHistory() http.HandlerFunc {
+	return s.historyHandler(func(h History, ctx context.Context, f metadata.Filter) (interface{}, error) {
+		return h.Certificates(ctx, f)
+	})
+}
+
+func (s *Server) getEventHistory() http.HandlerFunc {
+	return s.historyHandler(func(h History, ctx context.Context, f metadata.Filter) (interface{}, error) {
+		return h.Events(ctx, f)
+	})
+}
+
+func (s *Server) getNotificationHistory() http.HandlerFunc {
+	return s.historyHandler(func(h History, ctx context.Context, f metadata.Filter) (interface{}, error) {
+		return h.Notifications(ctx, f)
+	})
+}
+
+func (s *Server) getRunHistory() http.HandlerFunc {
+	return s.historyHandler(func(h History, ctx context.Context, f metadata.Filter) (interface{}, error) {
+		return h.Runs(ctx, f)
+	})
+}
@@ -0,0 +1,76 @@
+package api
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/metadata"
+)
+
+type fakeHistory struct {
+	filter metadata.Filter // of the last query
+}
+
+func (f *fakeHistory) Certificates(ctx context.Context, filter metadata.Filter) ([]metadata.Certificate, error) {
+	f.filter = filter
+	return []metadata.Certificate{{Domain: "example.com", Serial: "01"}}, nil
+}
+
+func (f *fakeHistory) Events(ctx context.Context, filter metadata.Filter) ([]metadata.Event, error) {
+	f.filter = filter
+	return []metadata.Event{{ID: 2, Domain: "example.com", Event: "post_renew"}, {ID: 1, Domain: "example.com", Event: "on_failure"}}, nil
+}
+
+func (f *fakeHistory) Notifications(ctx context.Context, filter metadata.Filter) ([]metadata.Notification, error) {
+	f.filter = filter
+	return []metadata.Notification{}, nil
+}
+
+func (f *fakeHistory) Runs(ctx context.Context, filter metadata.Filter) ([]metadata.Run, error) {
+	f.filter = filter
+	return []metadata.Run{{Run: 3, Status: "succeeded"}}, nil
+}
+
+func TestServer_History(t *testing.T) {
+	server := newTestServer("", &fakeManager{})
+
+	rec := do(t, server.Handler(), http.MethodGet, "/api/v1/history/events", "", "")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("history without a database = %d, want 404", rec.Code)
+	}
+
+	history := &fakeHistory{}
+	server.UseHistory(history)
+	handler := server.Handler()
+
+	rec = do(t, handler, http.MethodGet, "/api/v1/history/events?domain=example.com&since=2030-01-01T00:00:00Z&limit=10", "", "")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /history/events = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var events []metadata.Event
+	if err := json.NewDecoder(rec.Body).Decode(&events); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if len(events) != 2 || events[0].Event != "post_renew" {
+		t.Errorf("events = %+v", events)
+	}
+	want := metadata.Filter{Domain: "example.com", Since: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC), Limit: 10}
+	if !history.filter.Since.Equal(want.Since) || history.filter.Domain != want.Domain || history.filter.Limit != want.Limit {
+		t.Errorf("filter = %+v, want %+v", history.filter, want)
+	}
+
+	for _, path := range []string{"/api/v1/history/certificates", "/api/v1/history/notifications", "/api/v1/history/runs"} {
+		if rec := do(t, handler, http.MethodGet, path, "", ""); rec.Code != http.StatusOK {
+			t.Errorf("GET %s = %d, want 200", path, rec.Code)
+		}
+	}
+
+	for _, query := range []string{"since=yesterday", "limit=0", "limit=5000"} {
+		if rec := do(t, handler, http.MethodGet, "/api/v1/history/runs?"+query, "", ""); rec.Code != http.StatusBadRequest {
+			t.Errorf("GET /history/runs?%s = %d, want 400", query, rec.Code)
+		}
+	}
+}
@@ -0,0 +1,151 @@
+package api
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// idempotencyHeader carries the client-chosen key of a mutating request
+const idempotencyHeader = "Idempotency-Key"
+
+// maxIdempotencyKeyLength bounds the keys clients may send
+const maxIdempotencyKeyLength = 255
+
+// storedResponse is the outcome of a request, replayed for retries with the same key
+type storedResponse struct {
+	fingerprint string // method, path and body the key was first used with
+	done        bool   // false while the first request is still running
+	status      int
+	header      http.Header
+	body        []byte
+	expiresAt   time.Time
+}
+
+// idempotencyStore remembers responses to mutating requests by Idempotency-Key,
+// so automation retrying after a timeout gets the original result instead of
+// triggering a second ACME order or a second delete
+type idempotencyStore struct {
+	ttl     time.Duration
+	mu      sync.Mutex
+	entries map[string]*storedResponse
+}
+
+func newIdempotencyStore(ttl time.Duration) *idempotencyStore {
+	return &idempotencyStore{
+		ttl:     ttl,
+		entries: make(map[string]*storedResponse),
+	}
+}
+
+// idempotent wraps a mutating handler. Requests without the header run as usual.
+func (s *idempotencyStore) idempotent(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		key := r.Header.Get(idempotencyHeader)
+		if key == "" {
+			next(w, r)
+			return
+		}
+		if len(key) > maxIdempotencyKeyLength {
+			writeError(w, http.StatusBadRequest, "Idempotency-Key is too long")
+			return
+		}
+
+		body, err := io.ReadAll(http.MaxBytesReader(w, r.Body, maxBodySize))
+		if err != nil {
+			writeError(w, http.StatusBadRequest, "failed to read request body: "+err.Error())
+			return
+		}
+		r.Body = io.NopCloser(bytes.NewReader(body))
+
+		sum := sha256.Sum256(append([]byte(r.Method+" "+r.URL.Path+"\n"), body...))
+		fingerprint := hex.EncodeToString(sum[:])
+
+		entry, replay := s.begin(key, fingerprint)
+		switch {
+		case entry == nil:
+			writeError(w, http.StatusUnprocessableEntity, "Idempotency-Key was already used for a different request")
+			return
+		case replay && !entry.done:
+			writeError(w, http.StatusConflict, "a request with this Idempotency-Key is still in progress")
+			return
+		case replay:
+			for name, values := range entry.header {
+				w.Header()[name] = values
+			}
+			w.Header().Set("Idempotent-Replayed", "true")
+			w.WriteHeader(entry.status)
+			w.Write(entry.body)
+			return
+		}
+
+		rec := &responseRecorder{ResponseWriter: w, status: http.StatusOK}
+		next(rec, r)
+		s.finish(key, rec)
+	}
+}
+
+// begin returns the entry for key and whether it already existed. It returns nil
+// if the key was used with a different request.
+func (s *idempotencyStore) begin(key, fingerprint string) (*storedResponse, bool) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	now := time.Now()
+	for k, entry := range s.entries {
+		if entry.done && now.After(entry.expiresAt) {
+			delete(s.entries, k)
+		}
+	}
+
+	if entry, exists := s.entries[key]; exists {
+		if entry.fingerprint != fingerprint {
+			return nil, true
+		}
+		copied := *entry
+		return &copied, true
+	}
+
+	entry := &storedResponse{fingerprint: fingerprint}
+	s.entries[key] = entry
+	return entry, false
+}
+
+func (s *idempotencyStore) finish(key string, rec *responseRecorder) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	// Server errors are not stored, so a retry can succeed once the cause is fixed
+	if rec.status >= http.StatusInternalServerError {
+		delete(s.entries, key)
+		return
+	}
+
+	entry := s.entries[key]
+	entry.done = true
+	entry.status = rec.status
+	entry.header = rec.Header().Clone()
+	entry.body = rec.body.Bytes()
+	entry.expiresAt = time.Now().Add(s.ttl)
+}
+
+// responseRecorder copies a response while it is written to the client
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	body   bytes.Buffer
+}
+
+func (r *responseRecorder) WriteHeader(status int) {
+	r.status = status
+	r.ResponseWriter.WriteHeader(status)
+}
+
+func (r *responseRecorder) Write(b []byte) (int, error) {
+	r.body.Write(b)
+	return r.ResponseWriter.Write(b)
+}
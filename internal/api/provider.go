@@ -0,0 +1,14 @@
+package api
+
+import (
+	"net/http"
+)
+
+// getTraefikProvider serves every managed certificate with its key, the
+// default certificate and the TLS options for Traefik's HTTP provider
+// (--providers.http.endpoint), so Traefik polls the manager instead of
+// reading certificate files
+func (s *Server) getTraefikProvider(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Cache-Control", "no-store")
+	writeJSON(w, http.StatusOK, s.manager.TraefikProviderConfiguration())
+}
@@ -0,0 +1,41 @@
+package api
+
+import (
+	"encoding/json"
+	"net/http"
+	"reflect"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func TestServer_TraefikProvider(t *testing.T) {
+	manager := &fakeManager{}
+	manager.provider.TLS.Certificates = []certmanager.TraefikCertificate{{CertFile: "api cert", KeyFile: "api key"}}
+	manager.provider.TLS.Options = map[string]certmanager.TraefikTLSOptions{"default": {MinVersion: "VersionTLS12"}}
+
+	// Off unless enabled, since it serves private keys
+	rec := do(t, newTestServer("secret", manager).Handler(), http.MethodGet, "/api/v1/traefik/provider", "", "secret")
+	if rec.Code != http.StatusNotFound {
+		t.Errorf("GET /traefik/provider when disabled = %d, want 404", rec.Code)
+	}
+
+	handler := NewServer(config.API{Token: "secret", TraefikProvider: true}, manager, manager, nil).Handler()
+	rec = do(t, handler, http.MethodGet, "/api/v1/traefik/provider", "", "")
+	if rec.Code != http.StatusUnauthorized {
+		t.Errorf("GET /traefik/provider without token = %d, want 401", rec.Code)
+	}
+
+	rec = do(t, handler, http.MethodGet, "/api/v1/traefik/provider", "", "secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("GET /traefik/provider = %d, want 200: %s", rec.Code, rec.Body.String())
+	}
+	var dynamic certmanager.TraefikDynamicTLS
+	if err := json.NewDecoder(rec.Body).Decode(&dynamic); err != nil {
+		t.Fatalf("failed to decode response: %v", err)
+	}
+	if !reflect.DeepEqual(dynamic, manager.provider) {
+		t.Errorf("configuration = %+v, want %+v", dynamic, manager.provider)
+	}
+}
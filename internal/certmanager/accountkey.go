@@ -0,0 +1,112 @@
+package certmanager
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+)
+
+// An account key held by a KMS or PKCS#11 token can't be handed to lego, which
+// only signs with in-memory RSA and ECDSA keys. lego is given a stand-in key
+// carrying the public half of the external key instead, so the JWKs it embeds
+// and the key authorizations it computes are those of the real account, and
+// accountSigner replaces the signature of every request it sends.
+
+// standInKey returns the key lego is configured with for an external account key
+func standInKey(signer crypto.Signer) (*ecdsa.PrivateKey, error) {
+	public, ok := signer.Public().(*ecdsa.PublicKey)
+	if !ok || (public.Curve != elliptic.P256() && public.Curve != elliptic.P384()) {
+		return nil, fmt.Errorf("external account keys must be ECDSA P-256 or P-384 keys")
+	}
+	// Signatures made with the stand-in are always replaced, so any scalar will do
+	return &ecdsa.PrivateKey{PublicKey: *public, D: big.NewInt(1)}, nil
+}
+
+// accountSigner is an HTTP transport that signs the JWS body of every ACME
+// request with an external account key
+type accountSigner struct {
+	next   http.RoundTripper
+	signer crypto.Signer
+}
+
+// newAccountSigner wraps next, or returns it unchanged when signer is nil
+func newAccountSigner(next http.RoundTripper, signer crypto.Signer) http.RoundTripper {
+	if signer == nil {
+		return next
+	}
+	return &accountSigner{next: next, signer: signer}
+}
+
+func (t *accountSigner) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Body == nil || req.Header.Get("Content-Type") != "application/jose+json" {
+		return t.next.RoundTrip(req)
+	}
+
+	body, err := io.ReadAll(req.Body)
+	req.Body.Close()
+	if err != nil {
+		return nil, fmt.Errorf("failed to read ACME request: %w", err)
+	}
+	if body, err = t.sign(body); err != nil {
+		return nil, err
+	}
+
+	req = req.Clone(req.Context())
+	req.Body = io.NopCloser(bytes.NewReader(body))
+	req.GetBody = func() (io.ReadCloser, error) {
+		return io.NopCloser(bytes.NewReader(body)), nil
+	}
+	req.ContentLength = int64(len(body))
+	return t.next.RoundTrip(req)
+}
+
+// sign replaces the signature of a flattened JWS with one by the external key
+func (t *accountSigner) sign(body []byte) ([]byte, error) {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &jws); err != nil || jws.Protected == "" {
+		return body, nil
+	}
+
+	// standInKey made sure the key is P-256 or P-384, which lego signs as ES256 and ES384
+	size, hash := 32, crypto.SHA256
+	digest := sha256.Sum256([]byte(jws.Protected + "." + jws.Payload))
+	sum := digest[:]
+	if t.signer.Public().(*ecdsa.PublicKey).Curve == elliptic.P384() {
+		size, hash = 48, crypto.SHA384
+		digest := sha512.Sum384([]byte(jws.Protected + "." + jws.Payload))
+		sum = digest[:]
+	}
+
+	der, err := t.signer.Sign(rand.Reader, sum, hash)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign ACME request with the account key: %w", err)
+	}
+	var sig struct{ R, S *big.Int }
+	if rest, err := asn1.Unmarshal(der, &sig); err != nil || len(rest) > 0 ||
+		sig.R.Sign() <= 0 || sig.S.Sign() <= 0 || sig.R.BitLen() > size*8 || sig.S.BitLen() > size*8 {
+		return nil, fmt.Errorf("account key returned an invalid ECDSA signature")
+	}
+
+	// JWS carries ECDSA signatures as fixed-size R || S rather than DER
+	raw := make([]byte, 2*size)
+	sig.R.FillBytes(raw[:size])
+	sig.S.FillBytes(raw[size:])
+	jws.Signature = base64.RawURLEncoding.EncodeToString(raw)
+
+	return json.Marshal(jws)
+}
@@ -0,0 +1,81 @@
+package certmanager
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"io"
+	"math/big"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type roundTripFunc func(*http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+func TestStandInKey(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	standIn, err := standInKey(key)
+	require.NoError(t, err)
+	assert.True(t, standIn.PublicKey.Equal(&key.PublicKey))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, err = standInKey(rsaKey)
+	assert.ErrorContains(t, err, "must be ECDSA P-256 or P-384")
+}
+
+func TestAccountSigner_ReplacesSignature(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	require.NoError(t, err)
+
+	var sent []byte
+	transport := newAccountSigner(roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		if req.Body != nil {
+			sent, _ = io.ReadAll(req.Body)
+			assert.Equal(t, int64(len(sent)), req.ContentLength)
+		}
+		return &http.Response{StatusCode: http.StatusOK, Body: http.NoBody}, nil
+	}), key)
+
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES384","nonce":"abc","url":"https://ca.example/new-order"}`))
+	payload := base64.RawURLEncoding.EncodeToString([]byte(`{"identifiers":[{"type":"dns","value":"example.com"}]}`))
+	body, _ := json.Marshal(map[string]string{"protected": protected, "payload": payload, "signature": "c3RhbmQtaW4"})
+
+	req, _ := http.NewRequest(http.MethodPost, "https://ca.example/new-order", bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/jose+json")
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+
+	var jws map[string]string
+	require.NoError(t, json.Unmarshal(sent, &jws))
+	assert.Equal(t, protected, jws["protected"])
+	assert.Equal(t, payload, jws["payload"])
+
+	raw, err := base64.RawURLEncoding.DecodeString(jws["signature"])
+	require.NoError(t, err)
+	require.Len(t, raw, 96)
+	digest := sha512.Sum384([]byte(protected + "." + payload))
+	r, s := new(big.Int).SetBytes(raw[:48]), new(big.Int).SetBytes(raw[48:])
+	assert.True(t, ecdsa.Verify(&key.PublicKey, digest[:], r, s), "signature does not verify with the account key")
+
+	// Requests without a JWS body pass through unchanged
+	sent = nil
+	req, _ = http.NewRequest(http.MethodHead, "https://ca.example/new-nonce", nil)
+	_, err = transport.RoundTrip(req)
+	require.NoError(t, err)
+	assert.Empty(t, sent)
+}
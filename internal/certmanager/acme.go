@@ -1,28 +1,47 @@
 package certmanager
 
 import (
+	"context"
 	"crypto"
 	"crypto/rand"
 	"crypto/rsa"
+	"crypto/tls"
 	"crypto/x509"
 	"encoding/pem"
 	"fmt"
 	"log"
+	"math"
+	"net"
+	"net/http"
 	"os"
 	"path/filepath"
+	"sync"
 	"time"
 
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/encryption"
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
 	"github.com/go-acme/lego/v4/certcrypto"
 	"github.com/go-acme/lego/v4/certificate"
-	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/dns01"
 	"github.com/go-acme/lego/v4/lego"
+	"github.com/go-acme/lego/v4/providers/dns/exec"
 	"github.com/go-acme/lego/v4/registration"
 )
 
+// defaultHTTP01Address is where the HTTP-01 challenge server listens unless
+// configured otherwise; Traefik forwards /.well-known/acme-challenge/ requests to it
+const defaultHTTP01Address = ":5002"
+
 type ACMEUser struct {
 	Email        string
 	Registration *registration.Resource
 	key          crypto.PrivateKey
+
+	// mu guards Registration, which a registration deferred at startup sets
+	// while operations may be running
+	mu sync.Mutex
 }
 
 func (u *ACMEUser) GetEmail() string {
@@ -41,17 +60,76 @@ func (u *ACMEUser) GetPrivateKey() crypto.PrivateKey {
 type ACMEClient struct {
 	client      *lego.Client
 	user        *ACMEUser
+	httpClient  *http.Client
+	caDirURL    string
+	keyType     certcrypto.KeyType
+	orders      *orderJournal
 	storagePath string
+	archive     *CertificateArchive
+	encryption  *encryption.Envelope
+	combinedPEM bool
+	chain       string                                        // preferred chain; empty takes the CA's default
+	wire        *wireLogger                                   // nil logs no exchanges
+	operations  *runningOperations                            // domains with operations running
+	profileFor  func(domain string) string                    // nil requests the CA's default profile
+	keyTypeFor  func(domain string) string                    // nil orders every domain with keyType
+	stapleFor   func(domain string) bool                      // nil never requests Must-Staple
+	csrFor      func(domain string) config.CSRTemplate        // nil uses lego's CSR for every domain
+	externalFor func(domain string) (keyFile, csrFile string) // nil generates keys for every domain
+	sansFor     func(domain string) []string                  // nil orders every domain alone
+	metrics     *Metrics                                      // nil records no metrics
 	logger      *log.Logger
+
+	// Each operation creates a lego client from legoConfig, solving challenges
+	// with the dns-01 provider or solver, or on the shared HTTP-01 server
+	legoConfig    *lego.Config
+	challengeType string
+	dns01         challenge.Provider
+	dns01Solver   ChallengeSolver
+	http01        *challengeServer
+	accountless   bool // the lego client was created before the account was registered
+
+	retryAttempts int
+	retryBackoff  time.Duration
+	orderTimeout  time.Duration
+	beforeSwitch  func(ctx context.Context, cert *Certificate) error // nil stores new certificates right away
 }
 
 // ACMEConfig holds configuration for ACME client
 type ACMEConfig struct {
-	CADirURL    string
-	Email       string
-	KeyType     string
-	StoragePath string
-	Logger      *log.Logger
+	CADirURL         string
+	Email            string
+	KeyType          string
+	StoragePath      string
+	ArchiveRetention int
+	CompressArchives bool
+	Encryption       *encryption.Envelope // nil stores private keys in plaintext
+	AccountSigner    crypto.Signer        // signs with an account key held by a KMS or token; nil keeps the key in the storage path
+	CombinedPEM      bool                 // also write the full chain and key to one file
+	RetryAttempts    int                  // tries per operation on transient failures
+	RetryBackoff     time.Duration
+	OrderTimeout     time.Duration                                      // bounds each order, retries included; 0 leaves orders to the caller's context
+	ProfileFor       func(domain string) string                         // CA profile to request per domain; nil or empty uses the CA's default
+	KeyTypeFor       func(domain string) string                         // key type per domain; nil or empty uses KeyType
+	MustStapleFor    func(domain string) bool                           // request OCSP Must-Staple per domain; nil never does
+	CSRFor           func(domain string) config.CSRTemplate             // CSR template per domain; nil or empty uses lego's CSR
+	ExternalKeyFor   func(domain string) (keyFile, csrFile string)      // key material a domain brings; nil or empty generates keys
+	SANsFor          func(domain string) []string                       // further names in a domain's certificate; nil or empty orders the domain alone
+	Challenge        string                                             // http-01 (default) or dns-01
+	HTTP01Address    string                                             // host:port answering http-01 challenges; empty uses port 5002 on every interface
+	HTTP01Header     string                                             // header carrying the requested host behind a proxy; empty checks Host
+	DNS01Command     string                                             // program run to present and clean up dns-01 records
+	DNS01Solver      ChallengeSolver                                    // presents dns-01 records instead of DNS01Command
+	PreferredChain   string                                             // root common name of the chain to download; empty takes the CA's default
+	RootCAs          *x509.CertPool                                     // verifies the CA's TLS certificate; nil uses the system roots
+	InternalCA       *InternalCA                                        // signs the certificates of domains InternalCAFor selects
+	InternalCAFor    func(domain string) bool                           // nil orders every domain over ACME
+	BeforeSwitch     func(ctx context.Context, cert *Certificate) error // called before a new certificate replaces the stored one; an error keeps the stored one
+	Metrics          *Metrics                                           // records request latencies and retries; nil records none
+	Logger           *log.Logger
+
+	orders *orderJournal // shared by the clients of one storage path; nil creates one
+	wire   *wireLogger   // logs the exchanges of debugged domains; nil logs none
 }
 
 func NewACMEClient(config ACMEConfig) (*ACMEClient, error) {
@@ -59,10 +137,17 @@ func NewACMEClient(config ACMEConfig) (*ACMEClient, error) {
 		config.Logger = log.New(os.Stdout, "[ACME] ", log.LstdFlags)
 	}
 
-	// Create user with private key
-	privateKey, err := generatePrivateKey(config.KeyType)
+	// Reuse the account of earlier runs so their orders can be resumed
+	var privateKey crypto.PrivateKey
+	var err error
+	if config.AccountSigner != nil {
+		privateKey, err = standInKey(config.AccountSigner)
+	} else {
+		privateKey, err = loadAccountKey(filepath.Join(config.StoragePath, accountKeyName(config.CADirURL)),
+			config.KeyType, config.Encryption)
+	}
 	if err != nil {
-		return nil, fmt.Errorf("failed to generate private key: %w", err)
+		return nil, fmt.Errorf("failed to load account key: %w", err)
 	}
 
 	user := &ACMEUser{
@@ -74,28 +159,102 @@ func NewACMEClient(config ACMEConfig) (*ACMEClient, error) {
 	legoConfig := lego.NewConfig(user)
 	legoConfig.CADirURL = config.CADirURL
 	legoConfig.Certificate.KeyType = getKeyType(config.KeyType)
-
-	// Create client
-	client, err := lego.NewClient(legoConfig)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create lego client: %w", err)
+	if config.RootCAs != nil {
+		if err := trustRoots(legoConfig.HTTPClient.Transport, config.RootCAs); err != nil {
+			return nil, fmt.Errorf("failed to trust the CA certificates: %w", err)
+		}
 	}
-
-	// Set up HTTP challenge solver
-	err = client.Challenge.SetHTTP01Provider(http01.NewProviderServer("", "5002"))
-	if err != nil {
-		return nil, fmt.Errorf("failed to set HTTP01 provider: %w", err)
+	orders := config.orders
+	if orders == nil {
+		orders = newOrderJournal(config.StoragePath)
+	}
+	legoConfig.HTTPClient.Transport = tracing.Transport(newOrderRecorder(
+		newDirectoryCache(newLatencyTransport(config.wire.wrap(newAccountSigner(legoConfig.HTTPClient.Transport, config.AccountSigner)), config.CADirURL, config.Metrics),
+			config.CADirURL, config.StoragePath, config.Logger),
+		orders, config.Logger))
+
+	// Set up the challenge solver
+	var dns01Provider challenge.Provider
+	var http01Server *challengeServer
+	switch config.Challenge {
+	case "dns-01":
+		if config.DNS01Solver == nil {
+			dns01Provider, err = exec.NewDNSProviderConfig(&exec.Config{
+				Program:            config.DNS01Command,
+				PropagationTimeout: dns01.DefaultPropagationTimeout,
+				PollingInterval:    dns01.DefaultPollingInterval,
+				SequenceInterval:   dns01.DefaultPropagationTimeout,
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to set DNS01 provider: %w", err)
+			}
+		}
+	default:
+		address := config.HTTP01Address
+		if address == "" {
+			address = defaultHTTP01Address
+		}
+		host, port, err := net.SplitHostPort(address)
+		if err != nil {
+			return nil, fmt.Errorf("invalid HTTP01 listen address: %w", err)
+		}
+		http01Server = newChallengeServer(host, port, config.HTTP01Header)
 	}
 
+	archive := NewCertificateArchive(config.StoragePath, config.ArchiveRetention, config.CompressArchives, config.Logger)
+
 	acmeClient := &ACMEClient{
-		client:      client,
 		user:        user,
+		httpClient:  legoConfig.HTTPClient,
+		caDirURL:    config.CADirURL,
+		keyType:     legoConfig.Certificate.KeyType,
+		orders:      orders,
 		storagePath: config.StoragePath,
+		archive:     archive,
+		encryption:  config.Encryption,
+		combinedPEM: config.CombinedPEM,
+		chain:       config.PreferredChain,
+		wire:        config.wire,
+		operations:  newRunningOperations(),
+		profileFor:  config.ProfileFor,
+		keyTypeFor:  config.KeyTypeFor,
+		stapleFor:   config.MustStapleFor,
+		csrFor:      config.CSRFor,
+		externalFor: config.ExternalKeyFor,
+		sansFor:     config.SANsFor,
+		metrics:     config.Metrics,
 		logger:      config.Logger,
+
+		legoConfig:    legoConfig,
+		challengeType: config.Challenge,
+		dns01:         dns01Provider,
+		dns01Solver:   config.DNS01Solver,
+		http01:        http01Server,
+		accountless:   true,
+
+		retryAttempts: config.RetryAttempts,
+		retryBackoff:  config.RetryBackoff,
+		orderTimeout:  config.OrderTimeout,
+		beforeSwitch:  config.BeforeSwitch,
 	}
 
+	// Create client; it only registers the account, operations create their own
+	acmeClient.client, err = acmeClient.newLegoClient(context.Background(), legoConfig.HTTPClient)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create lego client: %w", err)
+	}
+
+	// A CA that is briefly unreachable shouldn't stop the daemon; registration is
+	// retried before the next certificate request
 	if err := acmeClient.registerUser(); err != nil {
-		return nil, fmt.Errorf("failed to register user: %w", err)
+		if !isTransientACMEError(err) {
+			return nil, fmt.Errorf("failed to register user: %w", err)
+		}
+		config.Logger.Printf("Warning: ACME registration deferred: %v", err)
+	}
+
+	if n := orders.Len(); n > 0 && config.orders == nil {
+		config.Logger.Printf("%d interrupted orders will be resumed when their domains are next processed", n)
 	}
 
 	return acmeClient, nil
@@ -109,27 +268,93 @@ func (c *ACMEClient) registerUser() error {
 	}
 
 	c.user.Registration = reg
+	c.accountless = false
 	c.logger.Printf("User registered successfully with URI: %s", reg.URI)
 
 	return nil
 }
 
-func (c *ACMEClient) RequestCertificate(domain string) (*Certificate, error) {
+// ensureRegistered completes a registration deferred by a CA outage at startup.
+// A lego client created before the account was registered signs no requests
+// with it, so it registers too, which returns the existing account.
+func (c *ACMEClient) ensureRegistered() error {
+	c.user.mu.Lock()
+	defer c.user.mu.Unlock()
+
+	if c.user.Registration != nil && !c.accountless {
+		return nil
+	}
+	return c.registerUser()
+}
+
+func (c *ACMEClient) RequestCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	ctx, span := c.startOrderSpan(ctx, domain, "issuance")
+	defer span.End()
+	ctx, cancel := c.orderContext(ctx)
+	defer cancel()
+	cert, err := c.requestCertificate(ctx, domain)
+	span.RecordError(err)
+	return cert, err
+}
+
+func (c *ACMEClient) requestCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	c, end, err := c.begin(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start issuance for %s: %w", domain, err)
+	}
+	defer end()
+
 	c.logger.Printf("Requesting certificate for domain: %s", domain)
+	defer c.wire.track(domain)()
 
 	// Ensure storage directory exists
 	if err := os.MkdirAll(c.storagePath, 0755); err != nil {
 		return nil, fmt.Errorf("failed to create storage directory: %w", err)
 	}
 
+	if _, csrFile := c.externalKey(domain); csrFile != "" {
+		return c.orderForCSR(ctx, domain, csrFile, "issuance")
+	}
+
+	// The key is generated and stored up front so an interrupted order can be
+	// finalized after a restart
+	key, err := c.orderKey(ctx, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := c.profile(domain)
+	if err := c.checkProfile(profile); err != nil {
+		return nil, err
+	}
+
 	// Request certificate
 	request := certificate.ObtainRequest{
-		Domains: []string{domain},
-		Bundle:  true,
+		Domains:        c.names(domain),
+		Bundle:         true,
+		PrivateKey:     key,
+		Profile:        profile,
+		PreferredChain: c.chain,
 	}
 
-	certificates, err := c.client.Certificate.Obtain(request)
+	var certificates *certificate.Resource
+	err = c.withRetry(ctx, "issuance", domain, func() error {
+		if err := c.ensureRegistered(); err != nil {
+			return fmt.Errorf("failed to register: %w", err)
+		}
+
+		var err error
+		if certificates, err = c.resumeOrder(domain, key); err != nil || certificates != nil {
+			return err
+		}
+		certificates, err = c.obtain(request)
+		return err
+	})
 	if err != nil {
+		// A transient failure or interrupted order is resumed on the next attempt
+		if !isTransientACMEError(err) && ctx.Err() == nil {
+			c.finishOrder(domain)
+		}
 		c.logger.Printf("Failed to obtain certificate for %s: %v", domain, err)
 		return nil, fmt.Errorf("failed to obtain certificate: %w", err)
 	}
@@ -144,6 +369,8 @@ func (c *ACMEClient) RequestCertificate(domain string) (*Certificate, error) {
 		IssuerCert:  certificates.IssuerCertificate,
 		URL:         certificates.CertURL,
 		IssuedAt:    time.Now(),
+		CA:          c.caDirURL,
+		OrderURL:    c.orderURL(domain),
 	}
 
 	// Parse certificate to get expiry
@@ -151,17 +378,53 @@ func (c *ACMEClient) RequestCertificate(domain string) (*Certificate, error) {
 		c.logger.Printf("Warning: failed to parse certificate: %v", err)
 	}
 
+	if err := c.checkSwitch(ctx, cert); err != nil {
+		return nil, err
+	}
+
 	// Save certificate to disk
-	if err := c.saveCertificate(cert); err != nil {
+	if err := c.saveCertificateTraced(ctx, cert); err != nil {
 		return nil, fmt.Errorf("failed to save certificate: %w", err)
 	}
+	c.finishOrder(domain)
 
 	c.logger.Printf("Certificate saved successfully for %s", domain)
 	return cert, nil
 }
 
-func (c *ACMEClient) RenewCertificate(cert *Certificate) (*Certificate, error) {
+// orderContext bounds an order by the order timeout, so one stuck order
+// doesn't use up the whole run. An order cut short is resumed on the next attempt.
+func (c *ACMEClient) orderContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if c.orderTimeout <= 0 {
+		return context.WithCancel(ctx)
+	}
+	return context.WithTimeout(ctx, c.orderTimeout)
+}
+
+func (c *ACMEClient) RenewCertificate(ctx context.Context, cert *Certificate) (*Certificate, error) {
+	ctx, span := c.startOrderSpan(ctx, cert.Domain, "renewal")
+	defer span.End()
+	ctx, cancel := c.orderContext(ctx)
+	defer cancel()
+	newCert, err := c.renewCertificate(ctx, cert)
+	span.RecordError(err)
+	return newCert, err
+}
+
+func (c *ACMEClient) renewCertificate(ctx context.Context, cert *Certificate) (*Certificate, error) {
+	c, end, err := c.begin(ctx, cert.Domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start renewal for %s: %w", cert.Domain, err)
+	}
+	defer end()
+
 	c.logger.Printf("Renewing certificate for domain: %s", cert.Domain)
+	defer c.wire.track(cert.Domain)()
+
+	keyFile, csrFile := c.externalKey(cert.Domain)
+	if csrFile != "" {
+		return c.orderForCSR(ctx, cert.Domain, csrFile, "renewal")
+	}
 
 	certResource := &certificate.Resource{
 		Domain:      cert.Domain,
@@ -170,9 +433,66 @@ func (c *ACMEClient) RenewCertificate(cert *Certificate) (*Certificate, error) {
 		CertURL:     cert.URL,
 	}
 
+	// Renewals reuse the current key, which an interrupted order is finalized with,
+	// unless the domain's key type has changed since
+	key, err := certcrypto.ParsePEMPrivateKey(cert.PrivateKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+	if keyFile != "" {
+		// The key file may have been replaced since the last order
+		if key, err = loadExternalKey(keyFile); err != nil {
+			return nil, err
+		}
+		certResource.PrivateKey = certcrypto.PEMEncode(key)
+	} else if keyType := c.domainKeyType(cert.Domain); keyTypeOf(key) != keyType {
+		c.logger.Printf("Key type of %s changed to %s, renewing with a new key", cert.Domain, keyType)
+		if key, err = c.orderKey(ctx, cert.Domain); err != nil {
+			return nil, err
+		}
+		certResource.PrivateKey = certcrypto.PEMEncode(key)
+	}
+
+	profile := c.profile(cert.Domain)
+	if err := c.checkProfile(profile); err != nil {
+		return nil, err
+	}
+
 	// Renew certificate
-	renewedCert, err := c.client.Certificate.Renew(*certResource, true, false, "")
+	var renewedCert *certificate.Resource
+	err = c.withRetry(ctx, "renewal", cert.Domain, func() error {
+		if err := c.ensureRegistered(); err != nil {
+			return fmt.Errorf("failed to register: %w", err)
+		}
+
+		var err error
+		if renewedCert, err = c.resumeOrder(cert.Domain, key); err != nil || renewedCert != nil {
+			return err
+		}
+		// A CSR template, and SANs that may have changed since the last order,
+		// are applied to a new order, which RenewWithOptions can't do
+		if !c.csrTemplate(cert.Domain).IsZero() || len(c.names(cert.Domain)) > 1 {
+			renewedCert, err = c.obtain(certificate.ObtainRequest{
+				Domains:        c.names(cert.Domain),
+				Bundle:         true,
+				PrivateKey:     key,
+				Profile:        profile,
+				PreferredChain: c.chain,
+			})
+			return err
+		}
+		renewedCert, err = c.client.Certificate.RenewWithOptions(*certResource, &certificate.RenewOptions{
+			Bundle:         true,
+			Profile:        profile,
+			PreferredChain: c.chain,
+			MustStaple:     c.mustStaple(cert.Domain),
+		})
+		return err
+	})
 	if err != nil {
+		if !isTransientACMEError(err) && ctx.Err() == nil {
+			c.finishOrder(cert.Domain)
+		}
 		c.logger.Printf("Failed to renew certificate for %s: %v", cert.Domain, err)
 		return nil, fmt.Errorf("failed to renew certificate: %w", err)
 	}
@@ -187,53 +507,181 @@ func (c *ACMEClient) RenewCertificate(cert *Certificate) (*Certificate, error) {
 		IssuerCert:  renewedCert.IssuerCertificate,
 		URL:         renewedCert.CertURL,
 		IssuedAt:    time.Now(),
+		CA:          c.caDirURL,
+		OrderURL:    c.orderURL(cert.Domain),
 	}
 
 	if err := newCert.parseCertificate(); err != nil {
 		c.logger.Printf("Warning: failed to parse renewed certificate: %v", err)
 	}
 
-	if err := c.saveCertificate(newCert); err != nil {
+	if err := c.checkSwitch(ctx, newCert); err != nil {
+		return nil, err
+	}
+	if err := c.saveCertificateTraced(ctx, newCert); err != nil {
 		return nil, fmt.Errorf("failed to save renewed certificate: %w", err)
 	}
+	c.finishOrder(cert.Domain)
 
 	c.logger.Printf("Renewed certificate saved successfully for %s", cert.Domain)
 	return newCert, nil
 }
 
-func (c *ACMEClient) saveCertificate(cert *Certificate) error {
-	// Save certificate
-	certPath := filepath.Join(c.storagePath, cert.Domain+".crt")
-	if err := os.WriteFile(certPath, cert.Certificate, 0644); err != nil {
-		return fmt.Errorf("failed to save certificate file: %w", err)
+// RevokeCertificate asks the CA to revoke a certificate with an RFC 5280 reason code
+func (c *ACMEClient) RevokeCertificate(ctx context.Context, cert *Certificate, reason uint) error {
+	c, end, err := c.begin(ctx, cert.Domain)
+	if err != nil {
+		return fmt.Errorf("failed to start revocation for %s: %w", cert.Domain, err)
+	}
+	defer end()
+
+	c.logger.Printf("Revoking certificate for domain: %s", cert.Domain)
+	defer c.wire.track(cert.Domain)()
+
+	err = c.withRetry(ctx, "revocation", cert.Domain, func() error {
+		if err := c.ensureRegistered(); err != nil {
+			return fmt.Errorf("failed to register: %w", err)
+		}
+		return c.client.Certificate.RevokeWithReason(cert.Certificate, &reason)
+	})
+	if err != nil {
+		return fmt.Errorf("failed to revoke certificate: %w", err)
+	}
+
+	c.logger.Printf("Successfully revoked certificate for %s", cert.Domain)
+	return nil
+}
+
+// SaveCertificate stores a certificate obtained outside of ACME, such as an imported one
+func (c *ACMEClient) SaveCertificate(ctx context.Context, cert *Certificate) error {
+	if err := os.MkdirAll(c.storagePath, 0755); err != nil {
+		return classify(ErrStorage, fmt.Errorf("failed to create storage directory: %w", err))
 	}
+	return c.saveCertificate(ctx, cert)
+}
 
-	// Save private key
-	keyPath := filepath.Join(c.storagePath, cert.Domain+".key")
-	if err := os.WriteFile(keyPath, cert.PrivateKey, 0600); err != nil {
-		return fmt.Errorf("failed to save private key file: %w", err)
+// checkSwitch lets the before-switch callback hold back a new certificate
+// before it replaces the stored one, which Traefik serves. The order of a
+// certificate held back stays journaled, so the next attempt downloads the
+// same certificate instead of ordering another.
+func (c *ACMEClient) checkSwitch(ctx context.Context, cert *Certificate) error {
+	if c.beforeSwitch == nil {
+		return nil
+	}
+	return c.beforeSwitch(ctx, cert)
+}
+
+func (c *ACMEClient) saveCertificate(ctx context.Context, cert *Certificate) error {
+	// A pair that doesn't match never replaces a working one
+	if len(cert.PrivateKey) > 0 {
+		if _, err := tls.X509KeyPair(cert.Certificate, cert.PrivateKey); err != nil {
+			return fmt.Errorf("refusing to save certificate for %s, it does not match its key: %w", cert.Domain, err)
+		}
+	}
+
+	// Keep the generation being replaced
+	if err := c.archive.Archive(cert.Domain); err != nil {
+		c.logger.Printf("Warning: failed to archive previous certificate for %s: %v", cert.Domain, err)
+	}
+	c.backupPair(ctx, cert.Domain)
+
+	certPath := filepath.Join(c.storagePath, storageName(cert.Domain)+".crt")
+	keyPath := filepath.Join(c.storagePath, storageName(cert.Domain)+".key")
+	files := []storedFile{{path: certPath, data: cert.Certificate, perm: 0644}}
+
+	if len(cert.PrivateKey) > 0 {
+		keyData := cert.PrivateKey
+		if c.encryption != nil {
+			sealed, err := c.sealPrivateKey(ctx, keyData)
+			if err != nil {
+				return fmt.Errorf("failed to encrypt private key: %w", err)
+			}
+			keyData = sealed
+		}
+		files = append(files, storedFile{path: keyPath, data: keyData, perm: 0600})
+	}
+
+	infoPath := filepath.Join(c.storagePath, storageName(cert.Domain)+orderInfoSuffix)
+	info, err := encodeOrderInfo(cert)
+	if err != nil {
+		return err
+	}
+	if info != nil {
+		files = append(files, storedFile{path: infoPath, data: info, perm: 0644})
+	}
+
+	// Certificate and key are renamed into place together
+	if err := writeFiles(files...); err != nil {
+		return classify(ErrStorage, fmt.Errorf("failed to save certificate files: %w", err))
+	}
+
+	// Certificates ordered for an external CSR have no key here; a key left
+	// from before the domain switched to its CSR doesn't belong to them
+	if len(cert.PrivateKey) == 0 {
+		if err := os.Remove(keyPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove private key file: %w", err)
+		}
+	}
+	// Nor does the order of a certificate replaced by an imported one
+	if info == nil {
+		if err := os.Remove(infoPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove order details: %w", err)
+		}
 	}
 
 	// Save issuer certificate if available
 	if cert.IssuerCert != nil {
-		issuerPath := filepath.Join(c.storagePath, cert.Domain+".issuer.crt")
-		if err := os.WriteFile(issuerPath, cert.IssuerCert, 0644); err != nil {
+		issuerPath := filepath.Join(c.storagePath, storageName(cert.Domain)+".issuer.crt")
+		if err := writeFiles(storedFile{path: issuerPath, data: cert.IssuerCert, perm: 0644}); err != nil {
 			c.logger.Printf("Warning: failed to save issuer certificate: %v", err)
 		}
 	}
 
-	return nil
+	return c.writeOutputs(cert)
 }
 
-func (c *ACMEClient) LoadCertificate(domain string) (*Certificate, error) {
-	certPath := filepath.Join(c.storagePath, domain+".crt")
-	keyPath := filepath.Join(c.storagePath, domain+".key")
+// SaveChain replaces the stored certificate bundle and issuer chain, leaving the private key untouched
+func (c *ACMEClient) SaveChain(ctx context.Context, cert *Certificate) error {
+	if err := c.archive.Archive(cert.Domain); err != nil {
+		c.logger.Printf("Warning: failed to archive previous certificate for %s: %v", cert.Domain, err)
+	}
+
+	c.backupPair(ctx, cert.Domain)
+
+	certPath := filepath.Join(c.storagePath, storageName(cert.Domain)+".crt")
+	issuerPath := filepath.Join(c.storagePath, storageName(cert.Domain)+".issuer.crt")
+	if err := writeFiles(
+		storedFile{path: certPath, data: cert.Certificate, perm: 0644},
+		storedFile{path: issuerPath, data: cert.IssuerCert, perm: 0644},
+	); err != nil {
+		return fmt.Errorf("failed to save certificate chain: %w", err)
+	}
+
+	return c.writeOutputs(cert)
+}
+
+// WriteOutputs rewrites the files derived from a stored certificate, like its full chain
+func (c *ACMEClient) WriteOutputs(cert *Certificate) error {
+	return c.writeOutputs(cert)
+}
+
+func (c *ACMEClient) LoadCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	return c.loadPair(ctx, domain, "")
+}
+
+// loadPair loads the certificate of domain stored with the given suffix after
+// each file name, e.g. backupSuffix for the previous generation
+func (c *ACMEClient) loadPair(ctx context.Context, domain, suffix string) (*Certificate, error) {
+	certPath := filepath.Join(c.storagePath, storageName(domain)+".crt"+suffix)
+	keyPath := filepath.Join(c.storagePath, storageName(domain)+".key"+suffix)
 
 	// Check if files exist
 	if _, err := os.Stat(certPath); os.IsNotExist(err) {
 		return nil, fmt.Errorf("certificate file not found: %s", certPath)
 	}
-	if _, err := os.Stat(keyPath); os.IsNotExist(err) {
+	// Certificates ordered for an external CSR are stored without a key
+	_, csrFile := c.externalKey(domain)
+	if _, err := os.Stat(keyPath); os.IsNotExist(err) && csrFile == "" {
 		return nil, fmt.Errorf("private key file not found: %s", keyPath)
 	}
 
@@ -245,13 +693,13 @@ func (c *ACMEClient) LoadCertificate(domain string) (*Certificate, error) {
 
 	// Read private key
 	keyData, err := os.ReadFile(keyPath)
-	if err != nil {
+	if err != nil && !(os.IsNotExist(err) && csrFile != "") {
 		return nil, fmt.Errorf("failed to read private key file: %w", err)
 	}
 
 	// Load issuer certificate if available
 	var issuerData []byte
-	issuerPath := filepath.Join(c.storagePath, domain+".issuer.crt")
+	issuerPath := filepath.Join(c.storagePath, storageName(domain)+".issuer.crt"+suffix)
 	if _, err := os.Stat(issuerPath); err == nil {
 		issuerData, _ = os.ReadFile(issuerPath)
 	}
@@ -261,22 +709,67 @@ func (c *ACMEClient) LoadCertificate(domain string) (*Certificate, error) {
 		return nil, fmt.Errorf("failed to get certificate file info: %w", err)
 	}
 
+	cert, err := c.decodePair(ctx, domain, certData, keyData, issuerData, info.ModTime())
+	if err != nil {
+		return nil, err
+	}
+	infoData, err := os.ReadFile(filepath.Join(c.storagePath, storageName(domain)+orderInfoSuffix+suffix))
+	if err == nil {
+		c.decodeOrderInfo(cert, infoData)
+	}
+	return cert, nil
+}
+
+// decodePair builds a certificate from its stored files, decrypting a sealed
+// key and checking that the certificate parses and matches its key
+func (c *ACMEClient) decodePair(ctx context.Context, domain string, certData, keyData, issuerData []byte, issuedAt time.Time) (*Certificate, error) {
+	if encryption.IsSealed(keyData) {
+		var err error
+		if keyData, err = c.openPrivateKey(ctx, keyData); err != nil {
+			return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+		}
+	}
+
 	cert := &Certificate{
 		Domain:      domain,
 		Certificate: certData,
 		PrivateKey:  keyData,
 		IssuerCert:  issuerData,
-		IssuedAt:    info.ModTime(),
+		IssuedAt:    issuedAt,
 	}
 
 	// Parse certificate to get expiry
 	if err := cert.parseCertificate(); err != nil {
-		return nil, fmt.Errorf("failed to parse certificate: %w", err)
+		return nil, fmt.Errorf("%w: failed to parse certificate: %w", ErrCorruptCertificate, err)
+	}
+	if len(keyData) > 0 {
+		if _, err := tls.X509KeyPair(certData, keyData); err != nil {
+			return nil, fmt.Errorf("%w: certificate does not match its key: %w", ErrCorruptCertificate, err)
+		}
 	}
 
 	return cert, nil
 }
 
+// keyEncryptionTimeout bounds calls to the external key custodian
+const keyEncryptionTimeout = 30 * time.Second
+
+func (c *ACMEClient) sealPrivateKey(ctx context.Context, key []byte) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, keyEncryptionTimeout)
+	defer cancel()
+	return c.encryption.Seal(ctx, key)
+}
+
+func (c *ACMEClient) openPrivateKey(ctx context.Context, data []byte) ([]byte, error) {
+	if c.encryption == nil {
+		return nil, fmt.Errorf("private key is encrypted but no encryption provider is configured")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, keyEncryptionTimeout)
+	defer cancel()
+	return c.encryption.Open(ctx, data)
+}
+
 // generatePrivateKey generates a private key based on the key type
 func generatePrivateKey(keyType string) (crypto.PrivateKey, error) {
 	switch keyType {
@@ -313,10 +806,20 @@ type Certificate struct {
 	IssuerCert  []byte
 	URL         string
 	IssuedAt    time.Time
+	NotBefore   time.Time
 	ExpiresAt   time.Time
+
+	// Read from the leaf
+	Issuer       string   // common name of the issuing CA
+	SerialNumber string   // colon-separated hex, as openssl prints it
+	SANs         []string // DNS names and IP addresses
+
+	// Where the certificate was ordered, empty for imported certificates
+	CA       string // directory URL of the ACME CA, or internal-ca
+	OrderURL string
 }
 
-// parseCertificate parses the certificate to extract expiry date
+// parseCertificate parses the certificate to extract its validity period
 func (c *Certificate) parseCertificate() error {
 	block, _ := pem.Decode(c.Certificate)
 	if block == nil {
@@ -328,29 +831,61 @@ func (c *Certificate) parseCertificate() error {
 		return fmt.Errorf("failed to parse certificate: %w", err)
 	}
 
+	c.NotBefore = cert.NotBefore
 	c.ExpiresAt = cert.NotAfter
+	c.Issuer = cert.Issuer.CommonName
+	if c.Issuer == "" {
+		c.Issuer = cert.Issuer.String()
+	}
+	c.SerialNumber = colonHex(cert.SerialNumber.Bytes())
+	c.SANs = leafSANs(cert)
 	return nil
 }
 
+// leafSANs returns the DNS names and IP addresses a certificate is valid for
+func leafSANs(leaf *x509.Certificate) []string {
+	sans := append([]string{}, leaf.DNSNames...)
+	for _, ip := range leaf.IPAddresses {
+		sans = append(sans, ip.String())
+	}
+	return sans
+}
+
 func (c *Certificate) IsExpired() bool {
 	return time.Now().After(c.ExpiresAt)
 }
 
+// NeedsRenewal reports whether fewer than renewalDays days remain, counted the
+// same way as DaysUntilExpiry so health reports never contradict themselves
 func (c *Certificate) NeedsRenewal(renewalDays int) bool {
-	renewalTime := c.ExpiresAt.AddDate(0, 0, -renewalDays)
-	return time.Now().After(renewalTime)
+	return c.DaysUntilExpiry() < renewalDays
 }
 
+// DaysUntilExpiry returns the remaining lifetime rounded to the nearest day
 func (c *Certificate) DaysUntilExpiry() int {
 	duration := time.Until(c.ExpiresAt)
-	return int(duration.Hours() / 24)
+	return int(math.Round(duration.Hours() / 24))
+}
+
+// lifetime returns how long the certificate is valid for in total, from its
+// NotBefore or, when that wasn't read from the leaf, its issuance; zero if
+// neither is known
+func (c *Certificate) lifetime() time.Duration {
+	start := c.NotBefore
+	if start.IsZero() {
+		start = c.IssuedAt
+	}
+	if start.IsZero() || !c.ExpiresAt.After(start) {
+		return 0
+	}
+	return c.ExpiresAt.Sub(start)
 }
 
 func (c *Certificate) GetCertPath(storagePath string) string {
-	return filepath.Join(storagePath, c.Domain+".crt")
+	return filepath.Join(storagePath, storageName(c.Domain)+".crt")
 }
 
 // GetKeyPath returns the path to the private key file
 func (c *Certificate) GetKeyPath(storagePath string) string {
-	return filepath.Join(storagePath, c.Domain+".key")
-}
\ No newline at end of file
+	return filepath.Join(storagePath, storageName(c.Domain)+".key")
+}
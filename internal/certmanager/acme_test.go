@@ -1,6 +1,7 @@
 package certmanager
 
 import (
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -8,8 +9,10 @@ import (
 	"encoding/pem"
 	"log"
 	"math/big"
+	"net"
 	"os"
 	"path/filepath"
+	"runtime"
 	"testing"
 	"time"
 	"fmt"
@@ -18,6 +21,10 @@ import (
 	"github.com/stretchr/testify/mock"
 	"github.com/stretchr/testify/require"
 	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/deploy"
+	"github.com/O-tero/traefik-cert-manager/internal/encryption"
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+	"golang.org/x/crypto/ssh"
 )
 
 // MockACMEClient implements a mock ACME client for testing
@@ -34,7 +41,7 @@ func NewMockACMEClient(storagePath string, logger *log.Logger) *MockACMEClient {
 	}
 }
 
-func (m *MockACMEClient) RequestCertificate(domain string) (*Certificate, error) {
+func (m *MockACMEClient) RequestCertificate(ctx context.Context, domain string) (*Certificate, error) {
 	args := m.Called(domain)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -42,7 +49,7 @@ func (m *MockACMEClient) RequestCertificate(domain string) (*Certificate, error)
 	return args.Get(0).(*Certificate), args.Error(1)
 }
 
-func (m *MockACMEClient) RenewCertificate(cert *Certificate) (*Certificate, error) {
+func (m *MockACMEClient) RenewCertificate(ctx context.Context, cert *Certificate) (*Certificate, error) {
 	args := m.Called(cert)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -50,7 +57,27 @@ func (m *MockACMEClient) RenewCertificate(cert *Certificate) (*Certificate, erro
 	return args.Get(0).(*Certificate), args.Error(1)
 }
 
-func (m *MockACMEClient) LoadCertificate(domain string) (*Certificate, error) {
+func (m *MockACMEClient) RevokeCertificate(ctx context.Context, cert *Certificate, reason uint) error {
+	args := m.Called(cert, reason)
+	return args.Error(0)
+}
+
+func (m *MockACMEClient) DeactivateStaleOrders(ctx context.Context, maxAge time.Duration) (int, error) {
+	args := m.Called(maxAge)
+	return args.Int(0), args.Error(1)
+}
+
+func (m *MockACMEClient) SaveChain(ctx context.Context, cert *Certificate) error {
+	args := m.Called(cert)
+	return args.Error(0)
+}
+
+func (m *MockACMEClient) SaveCertificate(ctx context.Context, cert *Certificate) error {
+	args := m.Called(cert)
+	return args.Error(0)
+}
+
+func (m *MockACMEClient) LoadCertificate(ctx context.Context, domain string) (*Certificate, error) {
 	args := m.Called(domain)
 	if args.Get(0) == nil {
 		return nil, args.Error(1)
@@ -105,6 +132,7 @@ func createTestCertificate(domain string, validDays int) *Certificate {
 		IssuedAt:    time.Now(),
 		ExpiresAt:   time.Now().Add(time.Duration(validDays) * 24 * time.Hour),
 	}
+	cert.parseCertificate()
 
 	return cert
 }
@@ -274,7 +302,7 @@ func TestCertificateManager_RequestCertificate_WithMock(t *testing.T) {
 	mockClient.On("RequestCertificate", "example.com").Return(testCert, nil)
 	
 	// Test certificate request
-	err := cm.RequestCertificate("example.com")
+	err := cm.RequestCertificate(context.Background(), "example.com")
 	require.NoError(t, err)
 	
 	// Verify certificate was stored
@@ -309,7 +337,7 @@ func TestCertificateManager_RequestCertificate_SkipValid(t *testing.T) {
 	cm.certs["example.com"] = validCert
 	
 	// Test certificate request (should skip)
-	err := cm.RequestCertificate("example.com")
+	err := cm.RequestCertificate(context.Background(), "example.com")
 	require.NoError(t, err)
 	
 	// Verify mock was not called (since certificate is valid)
@@ -343,7 +371,7 @@ func TestCertificateManager_RenewCertificate(t *testing.T) {
 	mockClient.On("RenewCertificate", oldCert).Return(newCert, nil)
 	
 	// Test certificate renewal
-	err := cm.RenewCertificate("example.com")
+	err := cm.RenewCertificate(context.Background(), "example.com")
 	require.NoError(t, err)
 	
 	// Verify certificate was updated
@@ -494,6 +522,221 @@ func TestCertificateManager_Cleanup(t *testing.T) {
 	assert.NotContains(t, cm.certs, "old.com")
 }
 
+func TestCertificateManager_SyncDiscoveredDomains(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+		discovered: make(map[string][]config.Domain),
+	}
+	
+	mockClient.On("RequestCertificate", "docker.example.com").Return(createTestCertificate("docker.example.com", 90), nil)
+	
+	// A new container appears
+	cm.SyncDiscoveredDomains(context.Background(), "docker", []config.Domain{
+		{Service: "web", Domain: "docker.example.com"},
+	})
+	
+	assert.Contains(t, cm.GetManagedDomains(), "docker.example.com")
+	assert.Contains(t, cm.certs, "docker.example.com")
+	mockClient.AssertExpectations(t)
+	
+	// The container goes away again
+	cm.SyncDiscoveredDomains(context.Background(), "docker", nil)
+	
+	assert.NotContains(t, cm.GetManagedDomains(), "docker.example.com")
+	assert.NotContains(t, cm.certs, "docker.example.com")
+	
+	// Statically configured domains are always managed
+	assert.Contains(t, cm.GetManagedDomains(), "example.com")
+}
+
+func TestCertificateManager_RunsHooks(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook commands use POSIX shell syntax")
+	}
+
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Domains[0].Hooks.PostIssue = []string{`echo "issued $CERT_MANAGER_DOMAIN $CERT_MANAGER_SERVICE" >> ` + filepath.Join(testDir, "events")}
+	cfg.Hooks.OnFailure = []string{`echo "failed $CERT_MANAGER_DOMAIN" >> ` + filepath.Join(testDir, "events")}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		hooks:      hooks.NewRunner(cfg.Hooks, 10*time.Second, logger),
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+	}
+
+	mockClient.On("RequestCertificate", "example.com").Return(createTestCertificate("example.com", 90), nil)
+	mockClient.On("RequestCertificate", "api.example.com").Return(nil, fmt.Errorf("rate limited"))
+
+	require.NoError(t, cm.RequestCertificate(context.Background(), "example.com"))
+	require.Error(t, cm.RequestCertificate(context.Background(), "api.example.com"))
+
+	// A still-valid certificate triggers no hooks
+	require.NoError(t, cm.RequestCertificate(context.Background(), "example.com"))
+
+	events, err := os.ReadFile(filepath.Join(testDir, "events"))
+	require.NoError(t, err)
+	assert.Equal(t, "issued example.com test-service\nfailed api.example.com\n", string(events))
+}
+
+func TestCertificateManager_DeployStopsWhenCancelled(t *testing.T) {
+	// An SSH host that accepts connections but never answers
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	defer listener.Close()
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			defer conn.Close()
+		}
+	}()
+
+	testDir := setupTestDir(t)
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	keyFile := filepath.Join(testDir, "deploy.key")
+	require.NoError(t, os.WriteFile(keyFile, pem.EncodeToMemory(&pem.Block{
+		Type: "RSA PRIVATE KEY", Bytes: x509.MarshalPKCS1PrivateKey(key)}), 0600))
+	signer, err := ssh.NewSignerFromKey(key)
+	require.NoError(t, err)
+
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Domains[0].Deploy = []config.DeployTarget{{
+		Host:           listener.Addr().String(),
+		User:           "deploy",
+		PrivateKeyFile: keyFile,
+		HostKey:        string(ssh.MarshalAuthorizedKey(signer.PublicKey())),
+		CertPath:       "/etc/ssl/example.com.crt",
+		KeyPath:        "/etc/ssl/example.com.key",
+	}}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	cm := &CertificateManager{
+		config:   cfg,
+		deployer: deploy.NewDeployer(logger),
+		logger:   logger,
+		certs:    make(map[string]*Certificate),
+	}
+
+	// Generated up front, so the cancellation lands during the SSH handshake
+	cert := createTestCertificate("example.com", 90)
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(100*time.Millisecond, cancel)
+	started := time.Now()
+	cm.deployCertificate(ctx, "example.com", cert)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	failures := cm.TakeFailures()
+	require.Len(t, failures, 1)
+	assert.Equal(t, "deploy", failures[0].Operation)
+	assert.Contains(t, failures[0].Error, context.Canceled.Error())
+}
+
+// staticResolver resolves only the names it was given
+type staticResolver map[string][]string
+
+func (r staticResolver) LookupHost(ctx context.Context, host string) ([]string, error) {
+	if addrs, ok := r[host]; ok {
+		return addrs, nil
+	}
+	return nil, &net.DNSError{Err: "no such host", Name: host, IsNotFound: true}
+}
+
+func (r staticResolver) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	return nil, &net.DNSError{Err: "no such host", Name: name, IsNotFound: true}
+}
+
+func TestCertificateManager_PrecheckRefusesUnresolvableDomain(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		resolver:   staticResolver{"example.com": {"192.0.2.10"}},
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+	}
+
+	mockClient.On("RequestCertificate", "example.com").Return(createTestCertificate("example.com", 90), nil)
+
+	require.NoError(t, cm.RequestCertificate(context.Background(), "example.com"))
+
+	err := cm.RequestCertificate(context.Background(), "api.example.com")
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "validation pre-check failed")
+	mockClient.AssertNotCalled(t, "RequestCertificate", "api.example.com")
+}
+
+// staticWrapper wraps data keys by XOR with a fixed byte, for tests only
+type staticWrapper struct{}
+
+func (staticWrapper) Name() string { return "static" }
+
+func (staticWrapper) WrapKey(_ context.Context, key []byte) ([]byte, error) {
+	out := make([]byte, len(key))
+	for i := range key {
+		out[i] = key[i] ^ 0x5a
+	}
+	return out, nil
+}
+
+func (w staticWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	return w.WrapKey(ctx, wrapped)
+}
+
+func TestACMEClient_EncryptedPrivateKey(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	client := &ACMEClient{
+		storagePath: testDir,
+		encryption:  encryption.NewEnvelope(staticWrapper{}),
+		logger:      logger,
+	}
+
+	cert := createTestCertificate("example.com", 90)
+	require.NoError(t, client.saveCertificate(context.Background(), cert))
+
+	onDisk, err := os.ReadFile(filepath.Join(testDir, "example.com.key"))
+	require.NoError(t, err)
+	assert.True(t, encryption.IsSealed(onDisk))
+	assert.NotContains(t, string(onDisk), string(cert.PrivateKey))
+
+	loaded, err := client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, cert.PrivateKey, loaded.PrivateKey)
+
+	// Without the provider the sealed key cannot be read
+	client.encryption = nil
+	_, err = client.LoadCertificate(context.Background(), "example.com")
+	assert.Error(t, err)
+}
+
 // Benchmark tests
 func BenchmarkCertificate_IsExpired(b *testing.B) {
 	cert := createTestCertificate("example.com", 30)
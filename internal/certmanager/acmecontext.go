@@ -0,0 +1,164 @@
+package certmanager
+
+import (
+	"context"
+	"net/http"
+	"sync"
+
+	"github.com/go-acme/lego/v4/challenge"
+	"github.com/go-acme/lego/v4/challenge/http01"
+	"github.com/go-acme/lego/v4/lego"
+)
+
+// lego takes no context, so an order can't be cancelled through its API.
+// Instead every ACME operation runs on a copy of the client with its own lego
+// client, whose HTTP requests are bound to the context of the operation: once
+// that is done, the pending request fails and lego gives up on the order,
+// which is journaled and resumed on the next attempt. Operations for different
+// domains thus run side by side; the manager keeps a domain from being
+// ordered twice at once.
+
+// boundTransport sends the requests of one operation under its context
+type boundTransport struct {
+	ctx  context.Context
+	next http.RoundTripper
+}
+
+func (t *boundTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	return t.next.RoundTrip(req.WithContext(t.ctx))
+}
+
+// begin returns a copy of the client for one operation on domain, whose
+// requests are bound to ctx until the returned function is called
+func (c *ACMEClient) begin(ctx context.Context, domain string) (*ACMEClient, func(), error) {
+	if err := ctx.Err(); err != nil {
+		return nil, nil, err
+	}
+
+	op := c.bind(ctx)
+	c.user.mu.Lock()
+	op.accountless = c.user.Registration == nil
+	client, err := c.newLegoClient(ctx, op.httpClient)
+	c.user.mu.Unlock()
+	if err != nil {
+		return nil, nil, err
+	}
+	op.client = client
+
+	return op, c.operations.start(domain), nil
+}
+
+// bind returns a copy of the client whose requests are bound to ctx
+func (c *ACMEClient) bind(ctx context.Context) *ACMEClient {
+	bound := *c
+	bound.httpClient = &http.Client{
+		Transport: &boundTransport{ctx: ctx, next: c.httpClient.Transport},
+		Timeout:   c.httpClient.Timeout,
+	}
+	return &bound
+}
+
+// newLegoClient creates a lego client sending its requests with httpClient and
+// solving challenges under ctx
+func (c *ACMEClient) newLegoClient(ctx context.Context, httpClient *http.Client) (*lego.Client, error) {
+	config := *c.legoConfig
+	config.HTTPClient = httpClient
+	client, err := lego.NewClient(&config)
+	if err != nil {
+		return nil, err
+	}
+
+	if c.challengeType == "dns-01" {
+		var provider challenge.Provider = c.dns01
+		if c.dns01Solver != nil {
+			provider = &solverProvider{solver: c.dns01Solver, ctx: ctx}
+		}
+		err = client.Challenge.SetDNS01Provider(provider)
+	} else {
+		err = client.Challenge.SetHTTP01Provider(&challengeSlot{server: c.http01, ctx: ctx})
+	}
+	if err != nil {
+		return nil, err
+	}
+	return client, nil
+}
+
+// runningOperations tracks the domains with operations running on a client
+type runningOperations struct {
+	mu      sync.Mutex
+	domains map[string]int
+}
+
+func newRunningOperations() *runningOperations {
+	return &runningOperations{domains: make(map[string]int)}
+}
+
+// start marks an operation on domain as running until the returned function is called
+func (o *runningOperations) start(domain string) func() {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	o.domains[domain]++
+
+	return func() {
+		o.mu.Lock()
+		defer o.mu.Unlock()
+		if o.domains[domain]--; o.domains[domain] == 0 {
+			delete(o.domains, domain)
+		}
+	}
+}
+
+// running reports whether an operation runs on any of domains
+func (o *runningOperations) running(domains []string) bool {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	for _, domain := range domains {
+		if o.domains[domain] > 0 {
+			return true
+		}
+	}
+	return false
+}
+
+// challengeServer answers the HTTP-01 challenges of a client's operations.
+// lego's server binds a fixed port for each challenge, so challenges take
+// turns: from being presented until their cleanup, which lego runs right after
+// validation. Operations only wait for each other in that window.
+type challengeServer struct {
+	server *http01.ProviderServer
+	slot   chan struct{} // held by the presented challenge
+}
+
+func newChallengeServer(host, port, proxyHeader string) *challengeServer {
+	server := http01.NewProviderServer(host, port)
+	server.SetProxyHeader(proxyHeader)
+	return &challengeServer{server: server, slot: make(chan struct{}, 1)}
+}
+
+// challengeSlot presents the HTTP-01 challenges of one operation on the
+// client's challengeServer, waiting for its turn until ctx is done
+type challengeSlot struct {
+	server *challengeServer
+	ctx    context.Context
+}
+
+func (p *challengeSlot) Present(domain, token, keyAuth string) error {
+	select {
+	case p.server.slot <- struct{}{}:
+	case <-p.ctx.Done():
+		return p.ctx.Err()
+	}
+
+	// lego only cleans up challenges presented successfully
+	if err := p.server.server.Present(domain, token, keyAuth); err != nil {
+		<-p.server.slot
+		return err
+	}
+	return nil
+}
+
+func (p *challengeSlot) CleanUp(domain, token, keyAuth string) error {
+	defer func() { <-p.server.slot }()
+	return p.server.server.CleanUp(domain, token, keyAuth)
+}
@@ -0,0 +1,111 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEClient_OperationsRunSideBySide(t *testing.T) {
+	server := fakeDirectoryServer(t, nil)
+	client, err := NewACMEClient(ACMEConfig{
+		CADirURL:    server.URL + "/directory",
+		Email:       "test@example.com",
+		KeyType:     "EC256",
+		StoragePath: setupTestDir(t),
+		Logger:      log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	})
+	require.NoError(t, err)
+
+	// An operation for another domain starts while the first one runs
+	first, cancel := context.WithCancel(context.Background())
+	op1, end1, err := client.begin(first, "example.com")
+	require.NoError(t, err)
+	op2, end2, err := client.begin(context.Background(), "example.org")
+	require.NoError(t, err)
+	assert.True(t, client.operations.running([]string{"example.com"}))
+	assert.True(t, client.operations.running([]string{"example.org"}))
+
+	// Each operation's requests are bound to its own context
+	cancel()
+	_, err = op1.httpClient.Get(server.URL + "/nonce")
+	assert.ErrorIs(t, err, context.Canceled)
+	resp, err := op2.httpClient.Get(server.URL + "/nonce")
+	require.NoError(t, err)
+	resp.Body.Close()
+
+	end1()
+	end2()
+	assert.False(t, client.operations.running([]string{"example.com", "example.org"}))
+
+	_, _, err = client.begin(first, "example.com")
+	assert.ErrorIs(t, err, context.Canceled)
+}
+
+func TestACMEClient_CancelledOrderIsKeptForResumption(t *testing.T) {
+	release := make(chan struct{})
+	defer close(release)
+	server := fakeDirectoryServer(t, func(w http.ResponseWriter, r *http.Request) {
+		// A CA that doesn't answer
+		<-release
+	})
+
+	testDir := setupTestDir(t)
+	client, err := NewACMEClient(ACMEConfig{
+		CADirURL:      server.URL + "/directory",
+		Email:         "test@example.com",
+		KeyType:       "EC256",
+		StoragePath:   testDir,
+		RetryAttempts: 3,
+		RetryBackoff:  time.Millisecond,
+		Logger:        log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	})
+	require.NoError(t, err)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	started := time.Now()
+	_, err = client.RequestCertificate(ctx, "example.com")
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+	assert.Less(t, time.Since(started), 5*time.Second)
+
+	// The key of the interrupted order is kept, so the next attempt resumes it
+	assert.FileExists(t, client.pendingKeyPath("example.com"))
+}
+
+// fakeDirectoryServer serves the directory, nonces and account of a CA,
+// passing new orders to order; nil orders nothing
+func fakeDirectoryServer(t *testing.T, order http.HandlerFunc) *httptest.Server {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch r.URL.Path {
+		case "/directory":
+			fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order"}`, server.URL)
+		case "/nonce":
+			w.WriteHeader(http.StatusOK)
+		case "/account":
+			w.Header().Set("Location", server.URL+"/account/1")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"status":"valid"}`)
+		case "/order":
+			if order == nil {
+				http.NotFound(w, r)
+				return
+			}
+			order(w, r)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
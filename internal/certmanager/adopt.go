@@ -0,0 +1,185 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// adoptedFileName lists on-disk certificates an operator has brought under management
+const adoptedFileName = ".adopted.json"
+
+var (
+	// ErrCertificateNotFound is returned for operations on a domain without a stored certificate
+	ErrCertificateNotFound = errors.New("certificate not found")
+	// ErrDomainConfigured is returned when deleting a certificate the configuration still requires
+	ErrDomainConfigured = errors.New("domain is configured")
+	// ErrDomainUnmanaged is returned when renewing a domain that is not, or is no
+	// longer, configured, discovered or adopted
+	ErrDomainUnmanaged = errors.New("domain is not managed")
+)
+
+// UnmanagedCertificates returns certificates found in storage that no configured,
+// discovered or adopted domain refers to. They are not renewed.
+func (cm *CertificateManager) UnmanagedCertificates() map[string]*Certificate {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	result := make(map[string]*Certificate)
+	for domain, cert := range cm.unmanaged {
+		result[domain] = cert
+	}
+	return result
+}
+
+// AdoptCertificate brings an unmanaged on-disk certificate under management so it
+// is renewed like a configured domain. The choice is persisted in the storage path.
+func (cm *CertificateManager) AdoptCertificate(domain string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	cert, exists := cm.unmanaged[domain]
+	if !exists {
+		return fmt.Errorf("no unmanaged certificate found for domain %s", domain)
+	}
+
+	if cm.adopted == nil {
+		cm.adopted = make(map[string]bool)
+	}
+	cm.adopted[domain] = true
+
+	if err := cm.saveAdopted(); err != nil {
+		delete(cm.adopted, domain)
+		return err
+	}
+
+	cm.certs[domain] = cert
+	delete(cm.unmanaged, domain)
+
+	cm.logger.Printf("Adopted certificate for %s (expires: %s)", domain, cert.ExpiresAt.Format("2006-01-02"))
+	return nil
+}
+
+// DeleteCertificate removes the stored certificate of a domain that is not
+// configured or discovered, and forgets an earlier adoption of it
+func (cm *CertificateManager) DeleteCertificate(domain string) error {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if cm.isConfigured(domain) {
+		return fmt.Errorf("%w: %s; remove it from the configuration first", ErrDomainConfigured, domain)
+	}
+
+	if err := cm.checkNotOrdering(domain); err != nil {
+		return err
+	}
+
+	_, managed := cm.certs[domain]
+	_, unmanaged := cm.unmanaged[domain]
+	if !managed && !unmanaged {
+		return fmt.Errorf("%w: %s", ErrCertificateNotFound, domain)
+	}
+
+	certPath, keyPath := cm.GetCertificatePaths(domain)
+	issuerPath := filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+".issuer.crt")
+	infoPath := filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+orderInfoSuffix)
+	ocspPath := filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+ocspSuffix)
+	for _, path := range []string{certPath, keyPath, issuerPath, infoPath, ocspPath,
+		certPath + backupSuffix, keyPath + backupSuffix, issuerPath + backupSuffix, infoPath + backupSuffix} {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to delete certificate for %s: %w", domain, err)
+		}
+	}
+
+	delete(cm.certs, domain)
+	delete(cm.unmanaged, domain)
+	cm.publishTraefikTLS()
+	if cm.adopted[domain] {
+		delete(cm.adopted, domain)
+		if err := cm.saveAdopted(); err != nil {
+			return err
+		}
+	}
+
+	cm.logger.Printf("Deleted certificate for %s", domain)
+	return nil
+}
+
+// releaseCertificate stops renewing a domain while keeping its certificate known as unmanaged.
+// Callers must hold cm.mu.
+func (cm *CertificateManager) releaseCertificate(domain string) {
+	if cert, exists := cm.certs[domain]; exists {
+		cm.keepUnmanaged(domain, cert)
+		delete(cm.certs, domain)
+	}
+}
+
+// keepUnmanaged records cert as the unmanaged certificate of domain. Callers must hold cm.mu.
+func (cm *CertificateManager) keepUnmanaged(domain string, cert *Certificate) {
+	if cm.unmanaged == nil {
+		cm.unmanaged = make(map[string]*Certificate)
+	}
+	cm.unmanaged[domain] = cert
+}
+
+// isManaged reports whether a domain is configured, discovered or adopted.
+// Callers must hold cm.mu.
+func (cm *CertificateManager) isManaged(domain string) bool {
+	return cm.isConfigured(domain) || cm.adopted[domain]
+}
+
+// claimUnmanaged moves an unmanaged certificate into the managed set once its domain
+// becomes managed, so an existing valid certificate is reused instead of re-issued.
+// Callers must hold cm.mu.
+func (cm *CertificateManager) claimUnmanaged(domain string) {
+	if cert, exists := cm.unmanaged[domain]; exists {
+		cm.certs[domain] = cert
+		delete(cm.unmanaged, domain)
+	}
+}
+
+func (cm *CertificateManager) loadAdopted() error {
+	data, err := os.ReadFile(cm.adoptedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil
+		}
+		return fmt.Errorf("failed to read adopted domains: %w", err)
+	}
+
+	var domains []string
+	if err := json.Unmarshal(data, &domains); err != nil {
+		return fmt.Errorf("failed to parse adopted domains: %w", err)
+	}
+
+	cm.adopted = make(map[string]bool)
+	for _, domain := range domains {
+		cm.adopted[domain] = true
+	}
+	return nil
+}
+
+func (cm *CertificateManager) saveAdopted() error {
+	domains := make([]string, 0, len(cm.adopted))
+	for domain := range cm.adopted {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	data, err := json.MarshalIndent(domains, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode adopted domains: %w", err)
+	}
+
+	if err := os.WriteFile(cm.adoptedPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save adopted domains: %w", err)
+	}
+	return nil
+}
+
+func (cm *CertificateManager) adoptedPath() string {
+	return filepath.Join(cm.config.Certificates.StoragePath, adoptedFileName)
+}
@@ -0,0 +1,146 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func newAdoptionTestManager(t *testing.T, testDir string, logger *log.Logger) (*CertificateManager, *MockACMEClient) {
+	t.Helper()
+
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	mockClient := NewMockACMEClient(testDir, logger)
+	for _, domain := range []string{"example.com", "legacy.example.com"} {
+		cert := createTestCertificate(domain, 60)
+		writeTestCertificateFiles(t, testDir, cert)
+		mockClient.On("LoadCertificate", domain).Return(cert, nil)
+	}
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+		unmanaged:  make(map[string]*Certificate),
+	}
+	require.NoError(t, cm.loadAdopted())
+	require.NoError(t, cm.loadExistingCertificates(context.Background()))
+
+	return cm, mockClient
+}
+
+func TestCertificateManager_AdoptCertificate(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, _ := newAdoptionTestManager(t, testDir, logger)
+
+	// Only configured domains are managed; the rest is reported as unmanaged
+	assert.Contains(t, cm.ListCertificates(), "example.com")
+	assert.NotContains(t, cm.ListCertificates(), "legacy.example.com")
+	assert.Contains(t, cm.UnmanagedCertificates(), "legacy.example.com")
+	assert.NotContains(t, cm.CheckCertificateHealth(), "legacy.example.com")
+
+	assert.Error(t, cm.AdoptCertificate("unknown.example.com"))
+
+	require.NoError(t, cm.AdoptCertificate("legacy.example.com"))
+	assert.Contains(t, cm.ListCertificates(), "legacy.example.com")
+	assert.Empty(t, cm.UnmanagedCertificates())
+	assert.Contains(t, cm.GetManagedDomains(), "legacy.example.com")
+
+	// Adoption survives a restart
+	reloaded, _ := newAdoptionTestManager(t, testDir, logger)
+	assert.Contains(t, reloaded.ListCertificates(), "legacy.example.com")
+	assert.Empty(t, reloaded.UnmanagedCertificates())
+}
+
+func TestCertificateManager_RequestCertificate_ClaimsUnmanaged(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, mockClient := newAdoptionTestManager(t, testDir, logger)
+
+	// A domain that becomes managed later reuses its valid on-disk certificate
+	require.NoError(t, cm.RequestCertificate(context.Background(), "legacy.example.com"))
+	assert.Contains(t, cm.ListCertificates(), "legacy.example.com")
+	assert.Empty(t, cm.UnmanagedCertificates())
+	mockClient.AssertNotCalled(t, "RequestCertificate", "legacy.example.com")
+}
+
+func TestCertificateManager_RenewCertificate_RefusesUnmanaged(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, mockClient := newAdoptionTestManager(t, testDir, logger)
+
+	// Renewing must not load the certificate back into the managed set
+	err := cm.RenewCertificate(context.Background(), "legacy.example.com")
+	assert.ErrorIs(t, err, ErrDomainUnmanaged)
+	assert.NotContains(t, cm.ListCertificates(), "legacy.example.com")
+	assert.Contains(t, cm.UnmanagedCertificates(), "legacy.example.com")
+	mockClient.AssertNotCalled(t, "RenewCertificate", mock.Anything)
+}
+
+func TestScheduler_DomainRemovedDuringRenewal(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      map[string]*Certificate{"app.example.com": createTestCertificate("app.example.com", 10)},
+		discovered: map[string][]config.Domain{"docker": {{Service: "app", Domain: "app.example.com"}}},
+	}
+
+	// Discovery drops the domain while the CA is issuing its certificate
+	renewed := createTestCertificate("app.example.com", 90)
+	mockClient.On("RenewCertificate", mock.Anything).Return(renewed, nil).Run(func(mock.Arguments) {
+		delete(cm.discovered, "docker")
+		cm.releaseCertificate("app.example.com")
+	})
+
+	scheduler, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+	summary := newRunSummary(1, "manual")
+	require.NoError(t, scheduler.performRenewalWithContext(context.Background(), summary))
+
+	assert.NotContains(t, cm.ListCertificates(), "app.example.com")
+	assert.Same(t, renewed, cm.UnmanagedCertificates()["app.example.com"])
+	assert.Empty(t, scheduler.GetRetryState())
+
+	require.Len(t, summary.Domains, 1)
+	assert.Equal(t, "skipped", summary.Domains[0].Outcome)
+	assert.Equal(t, "removed during this run", summary.Domains[0].Reason)
+}
+
+func TestCertificateManager_DeleteCertificate(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, _ := newAdoptionTestManager(t, testDir, logger)
+
+	assert.ErrorIs(t, cm.DeleteCertificate("example.com"), ErrDomainConfigured)
+	assert.ErrorIs(t, cm.DeleteCertificate("unknown.example.com"), ErrCertificateNotFound)
+
+	require.NoError(t, cm.AdoptCertificate("legacy.example.com"))
+	require.NoError(t, cm.DeleteCertificate("legacy.example.com"))
+	assert.NotContains(t, cm.ListCertificates(), "legacy.example.com")
+	assert.NotContains(t, cm.GetManagedDomains(), "legacy.example.com")
+	assert.NoFileExists(t, filepath.Join(testDir, "legacy.example.com.crt"))
+	assert.NoFileExists(t, filepath.Join(testDir, "legacy.example.com.key"))
+}
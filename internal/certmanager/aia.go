@@ -0,0 +1,144 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"net/http"
+	"time"
+)
+
+const (
+	// maxChainDepth bounds how many issuers are followed from a leaf
+	maxChainDepth = 5
+	// maxIssuerSize bounds the size of a downloaded issuer certificate
+	maxIssuerSize = 1 << 20
+)
+
+// ChainFetcher downloads issuer certificates from the Authority Information
+// Access (caIssuers) URLs embedded in certificates
+type ChainFetcher struct {
+	httpClient *http.Client
+}
+
+func NewChainFetcher(timeout time.Duration) *ChainFetcher {
+	return &ChainFetcher{
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// FetchChain follows AIA URLs from leaf up to a self-signed root or a certificate
+// without AIA information. The returned chain excludes the leaf and any self-signed
+// root, matching what servers are expected to send.
+func (f *ChainFetcher) FetchChain(ctx context.Context, leaf *x509.Certificate) ([]*x509.Certificate, error) {
+	var chain []*x509.Certificate
+
+	current := leaf
+	for depth := 0; depth < maxChainDepth; depth++ {
+		if isSelfSigned(current) || len(current.IssuingCertificateURL) == 0 {
+			break
+		}
+
+		issuer, err := f.fetchIssuer(ctx, current)
+		if err != nil {
+			return nil, err
+		}
+		if isSelfSigned(issuer) {
+			break
+		}
+
+		chain = append(chain, issuer)
+		current = issuer
+	}
+
+	if len(chain) == 0 && !isSelfSigned(leaf) && len(leaf.IssuingCertificateURL) == 0 {
+		return nil, fmt.Errorf("certificate for %s has no issuer URL", leaf.Subject.CommonName)
+	}
+
+	return chain, nil
+}
+
+// fetchIssuer tries each AIA URL of cert until one returns the certificate that signed it
+func (f *ChainFetcher) fetchIssuer(ctx context.Context, cert *x509.Certificate) (*x509.Certificate, error) {
+	var lastErr error
+	for _, url := range cert.IssuingCertificateURL {
+		issuers, err := f.download(ctx, url)
+		if err != nil {
+			lastErr = err
+			continue
+		}
+
+		for _, issuer := range issuers {
+			if cert.CheckSignatureFrom(issuer) == nil {
+				return issuer, nil
+			}
+		}
+		lastErr = fmt.Errorf("certificate from %s did not sign %q", url, cert.Subject.String())
+	}
+
+	return nil, fmt.Errorf("failed to fetch issuer of %q: %w", cert.Subject.String(), lastErr)
+}
+
+func (f *ChainFetcher) download(ctx context.Context, url string) ([]*x509.Certificate, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := f.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download %s: %w", url, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s returned status %d", url, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxIssuerSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read %s: %w", url, err)
+	}
+
+	// CAs publish issuers as DER, occasionally as PEM
+	if bytes.Contains(data, []byte("-----BEGIN CERTIFICATE-----")) {
+		return parsePEMCertificates(data)
+	}
+
+	cert, err := x509.ParseCertificate(data)
+	if err != nil {
+		return nil, fmt.Errorf("unsupported issuer certificate format at %s: %w", url, err)
+	}
+	return []*x509.Certificate{cert}, nil
+}
+
+// verifyChain checks that leaf chains to a trusted root through intermediates.
+// A nil roots pool uses the system trust store.
+func verifyChain(leaf *x509.Certificate, intermediates []*x509.Certificate, roots *x509.CertPool) error {
+	pool := x509.NewCertPool()
+	for _, cert := range intermediates {
+		pool.AddCert(cert)
+	}
+
+	_, err := leaf.Verify(x509.VerifyOptions{
+		Intermediates: pool,
+		Roots:         roots,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	})
+	return err
+}
+
+func isSelfSigned(cert *x509.Certificate) bool {
+	return bytes.Equal(cert.RawSubject, cert.RawIssuer) && cert.CheckSignatureFrom(cert) == nil
+}
+
+func encodePEMCertificates(certs []*x509.Certificate) []byte {
+	var buf bytes.Buffer
+	for _, cert := range certs {
+		pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,116 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+// serveIssuers publishes DER issuer certificates at /<name>.der
+func serveIssuers(t *testing.T, cas map[string]*testCA) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		for name, ca := range cas {
+			if r.URL.Path == "/"+name+".der" {
+				w.Write(ca.cert.Raw)
+				return
+			}
+		}
+		http.NotFound(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestChainFetcher_FetchChain(t *testing.T) {
+	cas := make(map[string]*testCA)
+	server := serveIssuers(t, cas)
+
+	root := newTestCA(t, "Test Root", 3650, nil)
+	root.url = server.URL + "/root.der"
+	intermediate := newTestCA(t, "Test Intermediate", 365, root)
+	intermediate.url = server.URL + "/intermediate.der"
+	cas["root"], cas["intermediate"] = root, intermediate
+
+	cert := intermediate.issue(t, "example.com", 60)
+	leaf, err := cert.leaf()
+	require.NoError(t, err)
+
+	chain, err := NewChainFetcher(5*time.Second).FetchChain(context.Background(), leaf)
+	require.NoError(t, err)
+
+	// The self-signed root is not part of the served chain
+	require.Len(t, chain, 1)
+	assert.Equal(t, intermediate.cert.Raw, chain[0].Raw)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(root.cert)
+	assert.NoError(t, verifyChain(leaf, chain, roots))
+	assert.Error(t, verifyChain(leaf, nil, roots))
+}
+
+func TestCertificateManager_RefreshChain(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	cas := make(map[string]*testCA)
+	server := serveIssuers(t, cas)
+
+	root := newTestCA(t, "Test Root", 3650, nil)
+	root.url = server.URL + "/root.der"
+	intermediate := newTestCA(t, "Test Intermediate", 365, root)
+	intermediate.url = server.URL + "/intermediate.der"
+	cas["root"], cas["intermediate"] = root, intermediate
+
+	// The stored bundle lacks its intermediate
+	cert := intermediate.issue(t, "example.com", 60)
+	leaf, err := cert.leaf()
+	require.NoError(t, err)
+	cert.Certificate = encodePEMCertificates([]*x509.Certificate{leaf})
+	cert.IssuerCert = nil
+	originalKey := cert.PrivateKey
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	mockClient.On("SaveChain", mock.AnythingOfType("*certmanager.Certificate")).Return(nil)
+
+	cm := &CertificateManager{
+		config:       cfg,
+		acmeClient:   mockClient,
+		chainFetcher: NewChainFetcher(5 * time.Second),
+		logger:       logger,
+		certs:        map[string]*Certificate{"example.com": cert},
+	}
+
+	changed, err := cm.RefreshChain(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.True(t, changed)
+	mockClient.AssertNumberOfCalls(t, "SaveChain", 1)
+
+	refreshed, err := cm.GetCertificate("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, intermediate.pem, refreshed.IssuerCert)
+	assert.Equal(t, originalKey, refreshed.PrivateKey)
+
+	chain, err := refreshed.ChainCertificates()
+	require.NoError(t, err)
+	require.Len(t, chain, 1)
+	assert.Equal(t, "CN=Test Intermediate", chain[0].Subject)
+
+	// A second refresh finds nothing to change
+	changed, err = cm.RefreshChain(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.False(t, changed)
+	mockClient.AssertNumberOfCalls(t, "SaveChain", 1)
+}
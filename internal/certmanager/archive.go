@@ -0,0 +1,301 @@
+package certmanager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	archiveDirName       = "archive"
+	archiveMetadataFile  = "metadata.json"
+	archiveCompressedExt = ".tar.gz"
+	archiveTimeFormat    = "20060102T150405.000000000Z"
+)
+
+// CertificateArchive keeps previous generations of certificates under StoragePath/archive
+type CertificateArchive struct {
+	storagePath string
+	retention   int
+	compress    bool
+	logger      *log.Logger
+}
+
+// archiveMetadata describes an archived generation
+type archiveMetadata struct {
+	Domain     string    `json:"domain"`
+	ArchivedAt time.Time `json:"archived_at"`
+	ExpiresAt  time.Time `json:"expires_at,omitempty"`
+	Files      []string  `json:"files"`
+}
+
+func NewCertificateArchive(storagePath string, retention int, compress bool, logger *log.Logger) *CertificateArchive {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Archive] ", log.LstdFlags)
+	}
+
+	return &CertificateArchive{
+		storagePath: storagePath,
+		retention:   retention,
+		compress:    compress,
+		logger:      logger,
+	}
+}
+
+// Enabled reports whether previous generations are retained
+func (a *CertificateArchive) Enabled() bool {
+	return a != nil && a.retention > 0
+}
+
+// Archive stores the current files of a domain as a new generation before they are overwritten
+func (a *CertificateArchive) Archive(domain string) error {
+	if !a.Enabled() {
+		return nil
+	}
+
+	name := storageName(domain)
+	files := make(map[string][]byte)
+	for _, name := range []string{name + ".crt", name + ".key", name + ".issuer.crt", name + orderInfoSuffix} {
+		data, err := os.ReadFile(filepath.Join(a.storagePath, name))
+		if os.IsNotExist(err) {
+			continue
+		}
+		if err != nil {
+			return fmt.Errorf("failed to read %s: %w", name, err)
+		}
+		files[name] = data
+	}
+
+	if _, ok := files[name+".crt"]; !ok {
+		// Nothing issued yet
+		return nil
+	}
+
+	metadata := archiveMetadata{
+		Domain:     domain,
+		ArchivedAt: time.Now().UTC(),
+	}
+	previous := &Certificate{Domain: domain, Certificate: files[name+".crt"]}
+	if err := previous.parseCertificate(); err == nil {
+		metadata.ExpiresAt = previous.ExpiresAt
+	}
+	for name := range files {
+		metadata.Files = append(metadata.Files, name)
+	}
+	sort.Strings(metadata.Files)
+
+	metadataJSON, err := json.MarshalIndent(metadata, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode archive metadata: %w", err)
+	}
+	files[archiveMetadataFile] = metadataJSON
+
+	domainDir := a.domainDir(domain)
+	if err := os.MkdirAll(domainDir, 0700); err != nil {
+		return fmt.Errorf("failed to create archive directory: %w", err)
+	}
+
+	generation := metadata.ArchivedAt.Format(archiveTimeFormat)
+	if a.compress {
+		err = writeCompressedGeneration(filepath.Join(domainDir, generation+archiveCompressedExt), files)
+	} else {
+		err = writeGeneration(filepath.Join(domainDir, generation), files)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to archive certificate for %s: %w", domain, err)
+	}
+
+	a.logger.Printf("Archived previous certificate generation for %s as %s", domain, generation)
+
+	return a.prune(domain)
+}
+
+// prune removes generations beyond the retention limit and compresses
+// generations that were archived before compression was enabled
+func (a *CertificateArchive) prune(domain string) error {
+	domainDir := a.domainDir(domain)
+
+	entries, err := a.generations(domain)
+	if err != nil {
+		return fmt.Errorf("failed to read archive directory: %w", err)
+	}
+
+	for i, entry := range entries {
+		path := filepath.Join(domainDir, entry.Name())
+
+		if i >= a.retention {
+			if err := os.RemoveAll(path); err != nil {
+				return fmt.Errorf("failed to remove archived generation %s: %w", entry.Name(), err)
+			}
+			continue
+		}
+
+		if a.compress && entry.IsDir() {
+			if err := compressGeneration(path); err != nil {
+				a.logger.Printf("Warning: failed to compress archived generation %s: %v", path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// generations returns the archived generations of a domain, newest first
+func (a *CertificateArchive) generations(domain string) ([]os.DirEntry, error) {
+	entries, err := os.ReadDir(a.domainDir(domain))
+	if err != nil {
+		return nil, err
+	}
+
+	// Generation names are timestamps, so reverse order puts the newest first
+	sort.Slice(entries, func(i, j int) bool {
+		return generationName(entries[i].Name()) > generationName(entries[j].Name())
+	})
+	return entries, nil
+}
+
+func (a *CertificateArchive) domainDir(domain string) string {
+	return filepath.Join(a.storagePath, archiveDirName, storageName(domain))
+}
+
+func generationName(name string) string {
+	return strings.TrimSuffix(name, archiveCompressedExt)
+}
+
+func writeGeneration(dir string, files map[string][]byte) error {
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	for name, data := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), data, 0600); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func writeCompressedGeneration(path string, files map[string][]byte) error {
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	names := make([]string, 0, len(files))
+	for name := range files {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	for _, name := range names {
+		header := &tar.Header{
+			Name:    name,
+			Mode:    0600,
+			Size:    int64(len(files[name])),
+			ModTime: time.Now(),
+		}
+		if err := tw.WriteHeader(header); err == nil {
+			_, err = tw.Write(files[name])
+		}
+		if err != nil {
+			f.Close()
+			os.Remove(path)
+			return err
+		}
+	}
+
+	for _, closer := range []io.Closer{tw, gz, f} {
+		if err := closer.Close(); err != nil {
+			os.Remove(path)
+			return err
+		}
+	}
+
+	return nil
+}
+
+// compressGeneration replaces an uncompressed generation directory with a tarball
+func compressGeneration(dir string) error {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return err
+	}
+
+	files := make(map[string][]byte)
+	for _, entry := range entries {
+		data, err := os.ReadFile(filepath.Join(dir, entry.Name()))
+		if err != nil {
+			return err
+		}
+		files[entry.Name()] = data
+	}
+
+	if err := writeCompressedGeneration(dir+archiveCompressedExt, files); err != nil {
+		return err
+	}
+
+	return os.RemoveAll(dir)
+}
+
+// readGeneration returns the files of an archived generation, compressed or not
+func readGeneration(path string) (map[string][]byte, error) {
+	files := make(map[string][]byte)
+
+	if !strings.HasSuffix(path, archiveCompressedExt) {
+		entries, err := os.ReadDir(path)
+		if err != nil {
+			return nil, err
+		}
+		for _, entry := range entries {
+			data, err := os.ReadFile(filepath.Join(path, entry.Name()))
+			if err != nil {
+				return nil, err
+			}
+			files[entry.Name()] = data
+		}
+		return files, nil
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	gz, err := gzip.NewReader(f)
+	if err != nil {
+		return nil, err
+	}
+	defer gz.Close()
+
+	tr := tar.NewReader(gz)
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		data, err := io.ReadAll(tr)
+		if err != nil {
+			return nil, err
+		}
+		files[filepath.Base(header.Name)] = data
+	}
+
+	return files, nil
+}
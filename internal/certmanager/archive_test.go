@@ -0,0 +1,72 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func writeTestCertificateFiles(t *testing.T, dir string, cert *Certificate) {
+	t.Helper()
+	require.NoError(t, os.WriteFile(filepath.Join(dir, cert.Domain+".crt"), cert.Certificate, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, cert.Domain+".key"), cert.PrivateKey, 0600))
+}
+
+func TestCertificateArchive_CompressAndPrune(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	archive := NewCertificateArchive(testDir, 2, true, logger)
+
+	for i := 0; i < 3; i++ {
+		writeTestCertificateFiles(t, testDir, createTestCertificate("example.com", 30+i))
+		require.NoError(t, archive.Archive("example.com"))
+	}
+
+	entries, err := os.ReadDir(filepath.Join(testDir, archiveDirName, "example.com"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+
+	for _, entry := range entries {
+		assert.True(t, strings.HasSuffix(entry.Name(), archiveCompressedExt))
+
+		files, err := readGeneration(filepath.Join(testDir, archiveDirName, "example.com", entry.Name()))
+		require.NoError(t, err)
+		assert.Contains(t, files, "example.com.crt")
+		assert.Contains(t, files, "example.com.key")
+		assert.Contains(t, files, archiveMetadataFile)
+	}
+}
+
+func TestCertificateArchive_CompressesExistingGenerations(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	writeTestCertificateFiles(t, testDir, createTestCertificate("example.com", 30))
+	require.NoError(t, NewCertificateArchive(testDir, 5, false, logger).Archive("example.com"))
+
+	writeTestCertificateFiles(t, testDir, createTestCertificate("example.com", 60))
+	require.NoError(t, NewCertificateArchive(testDir, 5, true, logger).Archive("example.com"))
+
+	entries, err := os.ReadDir(filepath.Join(testDir, archiveDirName, "example.com"))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	for _, entry := range entries {
+		assert.False(t, entry.IsDir(), "expected %s to be compressed", entry.Name())
+	}
+}
+
+func TestCertificateArchive_Disabled(t *testing.T) {
+	testDir := setupTestDir(t)
+	archive := NewCertificateArchive(testDir, 0, true, nil)
+
+	writeTestCertificateFiles(t, testDir, createTestCertificate("example.com", 30))
+	require.NoError(t, archive.Archive("example.com"))
+
+	_, err := os.Stat(filepath.Join(testDir, archiveDirName))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,106 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// backupSuffix marks the previous generation of a certificate's files, kept
+// next to them until the next write so a corrupt pair can be restored
+const backupSuffix = ".bak"
+
+// ErrCorruptCertificate is returned when a stored certificate can't be parsed
+// or doesn't match its key, e.g. after a crash while it was being replaced
+var ErrCorruptCertificate = errors.New("stored certificate is corrupt")
+
+// backupRestorer restores the previous generation of a corrupt stored certificate
+type backupRestorer interface {
+	RestoreBackup(ctx context.Context, domain string) (*Certificate, error)
+}
+
+// pairFiles returns the files making up the stored certificate of domain
+func (c *ACMEClient) pairFiles(domain string) []string {
+	name := filepath.Join(c.storagePath, storageName(domain))
+	return []string{name + ".crt", name + ".key", name + ".issuer.crt", name + orderInfoSuffix}
+}
+
+// copyPair replaces the files of domain stored with suffix to by those stored
+// with suffix from. Files missing from the source are removed from the target.
+func (c *ACMEClient) copyPair(domain, from, to string) error {
+	var files []storedFile
+	for _, path := range c.pairFiles(domain) {
+		data, err := os.ReadFile(path + from)
+		if os.IsNotExist(err) {
+			if err := os.Remove(path + to); err != nil && !os.IsNotExist(err) {
+				return err
+			}
+			continue
+		}
+		if err != nil {
+			return err
+		}
+		info, err := os.Stat(path + from)
+		if err != nil {
+			return err
+		}
+		files = append(files, storedFile{path: path + to, data: data, perm: info.Mode().Perm()})
+	}
+	return writeFiles(files...)
+}
+
+// backupPair keeps the stored files of domain as its backup before they are
+// replaced. A corrupt pair is not backed up, so it never replaces a good backup.
+func (c *ACMEClient) backupPair(ctx context.Context, domain string) {
+	if _, err := os.Stat(c.pairFiles(domain)[0]); os.IsNotExist(err) {
+		return
+	}
+	if _, err := c.loadPair(ctx, domain, ""); err != nil {
+		c.logger.Printf("Warning: not backing up the stored certificate of %s: %v", domain, err)
+		return
+	}
+	if err := c.copyPair(domain, "", backupSuffix); err != nil {
+		c.logger.Printf("Warning: failed to back up the stored certificate of %s: %v", domain, err)
+	}
+}
+
+// RestoreBackup puts the previous generation of domain's certificate back in
+// place of the stored one and returns it
+func (c *ACMEClient) RestoreBackup(ctx context.Context, domain string) (*Certificate, error) {
+	cert, err := c.loadPair(ctx, domain, backupSuffix)
+	if err != nil {
+		return nil, fmt.Errorf("no usable backup: %w", err)
+	}
+	if err := c.copyPair(domain, backupSuffix, ""); err != nil {
+		return nil, fmt.Errorf("failed to restore backup: %w", err)
+	}
+	if err := c.writeOutputs(cert); err != nil {
+		c.logger.Printf("Warning: %v", err)
+	}
+	return cert, nil
+}
+
+// loadCertificate loads the stored certificate of domain. A corrupt one is
+// replaced by the previous generation, which is returned instead.
+func (cm *CertificateManager) loadCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	cert, err := cm.acmeClient.LoadCertificate(ctx, domain)
+	if !errors.Is(err, ErrCorruptCertificate) {
+		return cert, err
+	}
+
+	restorer, ok := cm.acmeClient.(backupRestorer)
+	if !ok {
+		return nil, err
+	}
+	restored, restoreErr := restorer.RestoreBackup(ctx, domain)
+	if restoreErr != nil {
+		return nil, fmt.Errorf("%w; %v", err, restoreErr)
+	}
+
+	cm.logger.Printf("Warning: %s: %v; restored the previous certificate (expires: %s)",
+		domain, err, restored.ExpiresAt.Format(time.RFC3339))
+	return restored, nil
+}
@@ -0,0 +1,77 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEClient_SaveCertificateKeepsBackup(t *testing.T) {
+	testDir := setupTestDir(t)
+	client := &ACMEClient{storagePath: testDir, logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags)}
+
+	first := createTestCertificate("example.com", 30)
+	require.NoError(t, client.saveCertificate(context.Background(), first))
+	_, err := os.Stat(filepath.Join(testDir, "example.com.crt"+backupSuffix))
+	assert.True(t, os.IsNotExist(err), "first save has nothing to back up")
+
+	second := createTestCertificate("example.com", 90)
+	require.NoError(t, client.saveCertificate(context.Background(), second))
+
+	backup, err := client.loadPair(context.Background(), "example.com", backupSuffix)
+	require.NoError(t, err)
+	assert.Equal(t, first.Certificate, backup.Certificate)
+	assert.Equal(t, first.PrivateKey, backup.PrivateKey)
+
+	// A certificate with someone else's key is refused, leaving the stored pair
+	mismatched := createTestCertificate("example.com", 60)
+	mismatched.PrivateKey = first.PrivateKey
+	assert.Error(t, client.saveCertificate(context.Background(), mismatched))
+
+	stored, err := client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, second.Certificate, stored.Certificate)
+
+	entries, err := os.ReadDir(testDir)
+	require.NoError(t, err)
+	for _, entry := range entries {
+		assert.NotEqual(t, ".tmp", filepath.Ext(entry.Name()), "temporary file %s left behind", entry.Name())
+	}
+}
+
+func TestCertificateManager_LoadCertificateRestoresCorruptPair(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	client := &ACMEClient{storagePath: testDir, logger: logger}
+
+	first := createTestCertificate("example.com", 30)
+	require.NoError(t, client.saveCertificate(context.Background(), first))
+	require.NoError(t, client.saveCertificate(context.Background(), createTestCertificate("example.com", 90)))
+
+	// Only the certificate was replaced when the host went down
+	certPath := filepath.Join(testDir, "example.com.crt")
+	require.NoError(t, os.WriteFile(certPath, createTestCertificate("example.com", 60).Certificate, 0644))
+
+	_, err := client.LoadCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrCorruptCertificate)
+
+	cm := &CertificateManager{config: createTestConfig(), acmeClient: client, logger: logger}
+	restored, err := cm.loadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, first.Certificate, restored.Certificate)
+
+	stored, err := client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, first.Certificate, stored.Certificate)
+
+	// Without a usable backup the error is kept
+	require.NoError(t, os.WriteFile(certPath, []byte("truncated"), 0644))
+	require.NoError(t, os.Remove(certPath+backupSuffix))
+	_, err = cm.loadCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrCorruptCertificate)
+}
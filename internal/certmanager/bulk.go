@@ -0,0 +1,260 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// bulkCheckpointFileName keeps the progress of bulk issuance across restarts
+const bulkCheckpointFileName = ".bulk-issuance.json"
+
+// rateLimitedProblem is the ACME problem type of requests refused by a rate limit
+const rateLimitedProblem = "urn:ietf:params:acme:error:rateLimited"
+
+const (
+	bulkFailureBackoff    = 15 * time.Minute // after a domain's first failed order, doubled for each further one
+	maxBulkFailureBackoff = 24 * time.Hour
+	bulkMaintenanceCheck  = time.Minute // how often paused issuance checks whether maintenance ended
+)
+
+// retryAfterRe finds when a rate limit ends in the problem detail, as Let's
+// Encrypt words it: "retry after 2025-01-02 03:04:05 UTC"
+var retryAfterRe = regexp.MustCompile(`retry after (\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}) UTC`)
+
+// bulkCheckpoint is the progress of bulk issuance. Domains that got their
+// certificate are found in storage, so only what paces the remaining orders
+// is kept.
+type bulkCheckpoint struct {
+	Orders      []time.Time            `json:"orders,omitempty"`       // orders placed within the last hour
+	PausedUntil time.Time              `json:"paused_until,omitempty"` // the CA rate limited the account until then
+	Failed      map[string]bulkFailure `json:"failed,omitempty"`
+}
+
+// bulkFailure backs off a domain whose orders keep failing
+type bulkFailure struct {
+	Attempts    int       `json:"attempts"`
+	NextAttempt time.Time `json:"next_attempt"`
+	Error       string    `json:"error"`
+}
+
+// BulkIssuer orders certificates for every managed domain without one at a
+// bounded rate, pausing while the CA rate limits the account, until all
+// domains have a certificate
+type BulkIssuer struct {
+	manager       *CertificateManager
+	path          string
+	ordersPerHour int
+	backoff       time.Duration // pause after a rate limit that doesn't say when it ends
+	logger        *log.Logger
+	now           func() time.Time
+}
+
+func NewBulkIssuer(cm *CertificateManager, cfg config.BulkIssuance, logger *log.Logger) (*BulkIssuer, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[BulkIssuer] ", log.LstdFlags)
+	}
+
+	backoff, err := time.ParseDuration(cfg.RateLimitBackoff)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rate limit backoff: %w", err)
+	}
+
+	return &BulkIssuer{
+		manager:       cm,
+		path:          filepath.Join(cm.config.Certificates.StoragePath, bulkCheckpointFileName),
+		ordersPerHour: cfg.OrdersPerHour,
+		backoff:       backoff,
+		logger:        logger,
+		now:           time.Now,
+	}, nil
+}
+
+// Run places orders until every managed domain has a certificate or ctx is
+// done. Domains added while it runs are picked up too.
+func (b *BulkIssuer) Run(ctx context.Context) error {
+	checkpoint, err := b.load()
+	if err != nil {
+		return err
+	}
+
+	for {
+		pending := b.pending()
+		b.manager.metrics.setBulkPending(len(pending))
+		if len(pending) == 0 {
+			b.logger.Printf("Bulk issuance complete, every managed domain has a certificate")
+			if err := os.Remove(b.path); err != nil && !os.IsNotExist(err) {
+				return fmt.Errorf("failed to remove bulk issuance checkpoint: %w", err)
+			}
+			return nil
+		}
+
+		now := b.now()
+		domain, wait := b.next(checkpoint, pending, now)
+		if domain == "" {
+			b.save(checkpoint)
+			b.logger.Printf("Bulk issuance: %d domains pending, next order at %s", len(pending), now.Add(wait).Format(time.RFC3339))
+			select {
+			case <-ctx.Done():
+				return ctx.Err()
+			case <-time.After(wait):
+			}
+			continue
+		}
+
+		checkpoint.Orders = append(checkpoint.Orders, now)
+		err := b.manager.RequestCertificate(ctx, domain)
+		if ctx.Err() != nil {
+			b.save(checkpoint)
+			return ctx.Err()
+		}
+		b.record(checkpoint, domain, err, b.now())
+		b.save(checkpoint)
+	}
+}
+
+// pending returns the managed domains without a certificate, sorted
+func (b *BulkIssuer) pending() []string {
+	certs := b.manager.ListCertificates()
+
+	var pending []string
+	for _, domain := range b.manager.GetManagedDomains() {
+		if _, ok := certs[domain]; !ok {
+			pending = append(pending, domain)
+		}
+	}
+	sort.Strings(pending)
+	return pending
+}
+
+// next returns the pending domain to order at now, or how long to wait before
+// one may be ordered
+func (b *BulkIssuer) next(checkpoint *bulkCheckpoint, pending []string, now time.Time) (string, time.Duration) {
+	if now.Before(checkpoint.PausedUntil) {
+		return "", checkpoint.PausedUntil.Sub(now)
+	}
+	if b.manager.MaintenanceState().Enabled {
+		return "", bulkMaintenanceCheck
+	}
+
+	var recent []time.Time
+	for _, t := range checkpoint.Orders {
+		if now.Sub(t) < time.Hour {
+			recent = append(recent, t)
+		}
+	}
+	checkpoint.Orders = recent
+	if b.ordersPerHour > 0 && len(recent) >= b.ordersPerHour {
+		return "", recent[len(recent)-b.ordersPerHour].Add(time.Hour).Sub(now)
+	}
+
+	// Forget failures of domains that got a certificate or were removed
+	waiting := make(map[string]bulkFailure)
+	for _, domain := range pending {
+		if failure, ok := checkpoint.Failed[domain]; ok {
+			waiting[domain] = failure
+		}
+	}
+	checkpoint.Failed = waiting
+
+	var earliest time.Time
+	for _, domain := range pending {
+		failure, failed := waiting[domain]
+		if !failed || !now.Before(failure.NextAttempt) {
+			return domain, 0
+		}
+		if earliest.IsZero() || failure.NextAttempt.Before(earliest) {
+			earliest = failure.NextAttempt
+		}
+	}
+	return "", earliest.Sub(now)
+}
+
+// record updates the checkpoint with the outcome of an order for domain
+func (b *BulkIssuer) record(checkpoint *bulkCheckpoint, domain string, err error, now time.Time) {
+	if checkpoint.Failed == nil {
+		checkpoint.Failed = make(map[string]bulkFailure)
+	}
+
+	var problem *acme.ProblemDetails
+	var duplicate *DuplicateLimitError
+	switch {
+	case err == nil, errors.Is(err, ErrDomainUnmanaged):
+		delete(checkpoint.Failed, domain)
+	case errors.As(err, &problem) && problem.Type == rateLimitedProblem:
+		// The limit is the account's, so no domain is to blame
+		checkpoint.PausedUntil = rateLimitedUntil(problem, now.Add(b.backoff))
+		b.logger.Printf("CA rate limited bulk issuance, pausing until %s: %s",
+			checkpoint.PausedUntil.Format(time.RFC3339), problem.Detail)
+	case errors.As(err, &duplicate):
+		failure := checkpoint.Failed[domain]
+		failure.Attempts++
+		failure.NextAttempt = duplicate.RetryAfter
+		failure.Error = err.Error()
+		checkpoint.Failed[domain] = failure
+	default:
+		failure := checkpoint.Failed[domain]
+		failure.Attempts++
+		backoff := maxBulkFailureBackoff
+		if failure.Attempts < 8 {
+			backoff = min(bulkFailureBackoff<<(failure.Attempts-1), maxBulkFailureBackoff)
+		}
+		failure.NextAttempt = now.Add(backoff)
+		failure.Error = err.Error()
+		checkpoint.Failed[domain] = failure
+		b.logger.Printf("Bulk issuance: order %d for %s failed, retrying at %s",
+			failure.Attempts, domain, failure.NextAttempt.Format(time.RFC3339))
+	}
+}
+
+// rateLimitedUntil returns when the rate limit of problem ends, or fallback
+// when its detail doesn't say
+func rateLimitedUntil(problem *acme.ProblemDetails, fallback time.Time) time.Time {
+	m := retryAfterRe.FindStringSubmatch(problem.Detail)
+	if m == nil {
+		return fallback
+	}
+	until, err := time.Parse(time.DateTime, m[1])
+	if err != nil {
+		return fallback
+	}
+	return until
+}
+
+func (b *BulkIssuer) load() (*bulkCheckpoint, error) {
+	checkpoint := &bulkCheckpoint{}
+	data, err := os.ReadFile(b.path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return checkpoint, nil
+		}
+		return nil, fmt.Errorf("failed to read bulk issuance checkpoint: %w", err)
+	}
+	if err := json.Unmarshal(data, checkpoint); err != nil {
+		return nil, fmt.Errorf("failed to parse bulk issuance checkpoint: %w", err)
+	}
+	b.logger.Printf("Resuming bulk issuance from %s", b.path)
+	return checkpoint, nil
+}
+
+// save persists the checkpoint, logging failures: losing it only forgets how
+// orders were paced
+func (b *BulkIssuer) save(checkpoint *bulkCheckpoint) {
+	data, err := json.MarshalIndent(checkpoint, "", "  ")
+	if err == nil {
+		err = writeFileAtomic(b.path, data, 0644)
+	}
+	if err != nil {
+		b.logger.Printf("Warning: failed to save bulk issuance checkpoint: %v", err)
+	}
+}
@@ -0,0 +1,93 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBulkIssuer_Run(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	// example.com has a certificate, api.example.com is still pending
+	cm, mockClient := newAdoptionTestManager(t, testDir, logger)
+	bulk, err := NewBulkIssuer(cm, config.BulkIssuance{OrdersPerHour: 10, RateLimitBackoff: "10ms"}, logger)
+	require.NoError(t, err)
+
+	rateLimited := &acme.ProblemDetails{Type: rateLimitedProblem, HTTPStatus: 429, Detail: "too many new orders recently"}
+	mockClient.On("RequestCertificate", "api.example.com").Return(nil, rateLimited).Once()
+	mockClient.On("RequestCertificate", "api.example.com").Return(createTestCertificate("api.example.com", 90), nil).Once()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	require.NoError(t, bulk.Run(ctx))
+
+	assert.Contains(t, cm.ListCertificates(), "api.example.com")
+	assert.NoFileExists(t, filepath.Join(testDir, bulkCheckpointFileName))
+	mockClient.AssertExpectations(t)
+	mockClient.AssertNotCalled(t, "RequestCertificate", "example.com")
+}
+
+func TestBulkIssuer_ResumesFromCheckpoint(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, mockClient := newAdoptionTestManager(t, testDir, logger)
+	bulk, err := NewBulkIssuer(cm, config.BulkIssuance{OrdersPerHour: 10, RateLimitBackoff: "1h"}, logger)
+	require.NoError(t, err)
+
+	// A rate limit recorded before a restart still holds
+	bulk.save(&bulkCheckpoint{PausedUntil: time.Now().Add(time.Hour)})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	assert.ErrorIs(t, bulk.Run(ctx), context.DeadlineExceeded)
+	mockClient.AssertNotCalled(t, "RequestCertificate", "api.example.com")
+	assert.FileExists(t, filepath.Join(testDir, bulkCheckpointFileName))
+}
+
+func TestBulkIssuer_Next(t *testing.T) {
+	cm := &CertificateManager{config: createTestConfig()}
+	bulk, err := NewBulkIssuer(cm, config.BulkIssuance{OrdersPerHour: 2, RateLimitBackoff: "1h"}, nil)
+	require.NoError(t, err)
+
+	now := time.Date(2025, 3, 1, 12, 0, 0, 0, time.UTC)
+	pending := []string{"a.example.com", "b.example.com"}
+
+	// The oldest order within the hour has to leave it first
+	checkpoint := &bulkCheckpoint{Orders: []time.Time{now.Add(-2 * time.Hour), now.Add(-30 * time.Minute), now.Add(-10 * time.Minute)}}
+	domain, wait := bulk.next(checkpoint, pending, now)
+	assert.Equal(t, "", domain)
+	assert.Equal(t, 30*time.Minute, wait)
+	assert.Len(t, checkpoint.Orders, 2)
+
+	// Domains backing off are passed over
+	checkpoint = &bulkCheckpoint{Failed: map[string]bulkFailure{
+		"a.example.com":       {Attempts: 1, NextAttempt: now.Add(time.Minute)},
+		"removed.example.com": {Attempts: 3, NextAttempt: now.Add(time.Hour)},
+	}}
+	domain, _ = bulk.next(checkpoint, pending, now)
+	assert.Equal(t, "b.example.com", domain)
+	assert.NotContains(t, checkpoint.Failed, "removed.example.com")
+
+	bulk.record(checkpoint, "b.example.com", errors.New("validation failed"), now)
+	domain, wait = bulk.next(checkpoint, pending, now)
+	assert.Equal(t, "", domain)
+	assert.Equal(t, time.Minute, wait)
+
+	// A rate limit pauses every domain until the time the CA names
+	problem := &acme.ProblemDetails{Type: rateLimitedProblem, Detail: "too many certificates (50) already issued, retry after 2025-03-01 15:04:05 UTC"}
+	bulk.record(checkpoint, "a.example.com", problem, now)
+	assert.Equal(t, time.Date(2025, 3, 1, 15, 4, 5, 0, time.UTC), checkpoint.PausedUntil)
+	assert.Equal(t, 1, checkpoint.Failed["a.example.com"].Attempts)
+}
@@ -0,0 +1,40 @@
+package certmanager
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"net/http"
+	"os"
+)
+
+// LoadRootCAs returns the system roots plus the PEM certificates in path, for
+// CAs such as step-ca whose ACME directory is served under a private root
+func LoadRootCAs(path string) (*x509.CertPool, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CA certificates: %w", err)
+	}
+
+	pool, err := x509.SystemCertPool()
+	if err != nil {
+		pool = x509.NewCertPool()
+	}
+	if !pool.AppendCertsFromPEM(data) {
+		return nil, fmt.Errorf("no PEM certificates found in %s", path)
+	}
+	return pool, nil
+}
+
+// trustRoots makes transport verify the CA's TLS certificate against roots
+func trustRoots(transport http.RoundTripper, roots *x509.CertPool) error {
+	t, ok := transport.(*http.Transport)
+	if !ok {
+		return fmt.Errorf("unexpected HTTP transport %T", transport)
+	}
+	if t.TLSClientConfig == nil {
+		t.TLSClientConfig = &tls.Config{}
+	}
+	t.TLSClientConfig.RootCAs = roots
+	return nil
+}
@@ -0,0 +1,61 @@
+package certmanager
+
+import (
+	"encoding/pem"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestNewACMEClient_TrustsCACert(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch r.URL.Path {
+		case "/directory":
+			fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order"}`, server.URL)
+		case "/account":
+			w.Header().Set("Location", server.URL+"/account/1")
+			w.WriteHeader(http.StatusCreated)
+			fmt.Fprint(w, `{"status":"valid"}`)
+		}
+	}))
+	defer server.Close()
+
+	testDir := setupTestDir(t)
+	config := ACMEConfig{
+		CADirURL:    server.URL + "/directory",
+		Email:       "test@example.com",
+		KeyType:     "EC256",
+		StoragePath: testDir,
+		Logger:      log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+
+	// The test server's certificate is signed by a root only the bundle has
+	_, err := NewACMEClient(config)
+	require.ErrorContains(t, err, "certificate signed by unknown authority")
+
+	bundle := filepath.Join(testDir, "root_ca.crt")
+	rootPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: server.Certificate().Raw})
+	require.NoError(t, os.WriteFile(bundle, rootPEM, 0644))
+	config.RootCAs, err = LoadRootCAs(bundle)
+	require.NoError(t, err)
+
+	_, err = NewACMEClient(config)
+	assert.NoError(t, err)
+}
+
+func TestLoadRootCAs_RejectsFilesWithoutCertificates(t *testing.T) {
+	path := filepath.Join(setupTestDir(t), "root_ca.crt")
+	require.NoError(t, os.WriteFile(path, []byte("not a certificate"), 0644))
+
+	_, err := LoadRootCAs(path)
+	assert.ErrorContains(t, err, "no PEM certificates found")
+}
@@ -0,0 +1,272 @@
+package certmanager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+// ChainCertificate describes an issuer certificate stored alongside a leaf
+type ChainCertificate struct {
+	Subject     string    `json:"subject" yaml:"subject"`
+	Issuer      string    `json:"issuer" yaml:"issuer"`
+	NotAfter    time.Time `json:"not_after" yaml:"not_after"`
+	SelfSigned  bool      `json:"self_signed" yaml:"self_signed"`
+	Fingerprint string    `json:"fingerprint" yaml:"fingerprint"` // SHA-256 of the DER encoding
+}
+
+// ChainWarning reports an issuer certificate that expires within the warning window
+type ChainWarning struct {
+	ChainCertificate
+	Domains         []string `json:"domains"`
+	DaysUntilExpiry int      `json:"days_until_expiry"`
+}
+
+// ChainCertificates returns the intermediates and roots stored with the certificate,
+// from the bundle after the leaf and from the separate issuer file, without duplicates
+func (c *Certificate) ChainCertificates() ([]ChainCertificate, error) {
+	bundle, err := parsePEMCertificates(c.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	issuers, err := parsePEMCertificates(c.IssuerCert)
+	if err != nil {
+		return nil, err
+	}
+
+	var chain []ChainCertificate
+	seen := make(map[string]bool)
+
+	candidates := issuers
+	if len(bundle) > 1 {
+		candidates = append(bundle[1:], issuers...)
+	}
+
+	for _, cert := range candidates {
+		sum := sha256.Sum256(cert.Raw)
+		fingerprint := hex.EncodeToString(sum[:])
+		if seen[fingerprint] {
+			continue
+		}
+		seen[fingerprint] = true
+
+		chain = append(chain, ChainCertificate{
+			Subject:     cert.Subject.String(),
+			Issuer:      cert.Issuer.String(),
+			NotAfter:    cert.NotAfter,
+			SelfSigned:  bytes.Equal(cert.RawSubject, cert.RawIssuer),
+			Fingerprint: fingerprint,
+		})
+	}
+
+	return chain, nil
+}
+
+// ChainExpiresAt returns the earliest expiry among the stored issuer certificates,
+// or the zero time when no chain is stored
+func (c *Certificate) ChainExpiresAt() time.Time {
+	chain, err := c.ChainCertificates()
+	if err != nil {
+		return time.Time{}
+	}
+
+	var earliest time.Time
+	for _, cert := range chain {
+		if earliest.IsZero() || cert.NotAfter.Before(earliest) {
+			earliest = cert.NotAfter
+		}
+	}
+	return earliest
+}
+
+// ChainRoot returns the name of the root the stored chain leads to: the issuer
+// of its topmost certificate, which is what acme.preferred_chain selects by.
+// It is empty if no chain is stored.
+func (c *Certificate) ChainRoot() string {
+	if root := chainRoot(c.IssuerCert); root != "" {
+		return root
+	}
+	if bundle, err := parsePEMCertificates(c.Certificate); err == nil && len(bundle) > 1 {
+		return bundle[len(bundle)-1].Issuer.CommonName
+	}
+	return ""
+}
+
+// chainRoot returns the issuer of the topmost certificate in a PEM chain
+func chainRoot(chain []byte) string {
+	certs, err := parsePEMCertificates(chain)
+	if err != nil || len(certs) == 0 {
+		return ""
+	}
+	return certs[len(certs)-1].Issuer.CommonName
+}
+
+// leaf returns the parsed end-entity certificate, the first in the bundle
+func (c *Certificate) leaf() (*x509.Certificate, error) {
+	certs, err := parsePEMCertificates(c.Certificate)
+	if err != nil {
+		return nil, err
+	}
+	if len(certs) == 0 {
+		return nil, fmt.Errorf("no certificate found for %s", c.Domain)
+	}
+	return certs[0], nil
+}
+
+func parsePEMCertificates(data []byte) ([]*x509.Certificate, error) {
+	var certs []*x509.Certificate
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			break
+		}
+		if block.Type != "CERTIFICATE" {
+			continue
+		}
+
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse chain certificate: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	return certs, nil
+}
+
+// ChainMonitor alerts when an intermediate or root in a stored chain nears expiry,
+// even though the leaf certificates it signed are still valid
+type ChainMonitor struct {
+	warningDays int
+	notifier    notify.Notifier
+	metrics     *Metrics
+	logger      *log.Logger
+	mu          sync.Mutex
+	alerted     map[string]bool // fingerprints already reported
+}
+
+func NewChainMonitor(warningDays int, notifier notify.Notifier, metrics *Metrics, logger *log.Logger) *ChainMonitor {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[ChainMonitor] ", log.LstdFlags)
+	}
+
+	return &ChainMonitor{
+		warningDays: warningDays,
+		notifier:    notifier,
+		metrics:     metrics,
+		logger:      logger,
+		alerted:     make(map[string]bool),
+	}
+}
+
+// Check inspects the chains of the given certificates and alerts once per expiring
+// issuer certificate. It returns all issuer certificates inside the warning window.
+func (m *ChainMonitor) Check(certs map[string]*Certificate) []ChainWarning {
+	now := time.Now()
+	threshold := now.AddDate(0, 0, m.warningDays)
+
+	warnings := make(map[string]*ChainWarning)
+	for domain, cert := range certs {
+		chain, err := cert.ChainCertificates()
+		if err != nil {
+			m.logger.Printf("Failed to inspect certificate chain for %s: %v", domain, err)
+			continue
+		}
+
+		var earliest time.Time
+		for _, issuer := range chain {
+			if earliest.IsZero() || issuer.NotAfter.Before(earliest) {
+				earliest = issuer.NotAfter
+			}
+			if issuer.NotAfter.After(threshold) {
+				continue
+			}
+
+			w, exists := warnings[issuer.Fingerprint]
+			if !exists {
+				w = &ChainWarning{
+					ChainCertificate: issuer,
+					DaysUntilExpiry:  int(issuer.NotAfter.Sub(now).Hours() / 24),
+				}
+				warnings[issuer.Fingerprint] = w
+			}
+			w.Domains = append(w.Domains, domain)
+		}
+
+		if !earliest.IsZero() {
+			m.metrics.setChainExpiry(domain, earliest)
+		}
+	}
+
+	result := make([]ChainWarning, 0, len(warnings))
+	for _, w := range warnings {
+		sort.Strings(w.Domains)
+		result = append(result, *w)
+	}
+	sort.Slice(result, func(i, j int) bool {
+		return result[i].NotAfter.Before(result[j].NotAfter)
+	})
+
+	m.mu.Lock()
+	var fresh []ChainWarning
+	for _, w := range result {
+		if !m.alerted[w.Fingerprint] {
+			fresh = append(fresh, w)
+		}
+	}
+	// Forget issuers that left the window so a later regression alerts again
+	for fingerprint := range m.alerted {
+		if _, exists := warnings[fingerprint]; !exists {
+			delete(m.alerted, fingerprint)
+		}
+	}
+	for _, w := range fresh {
+		m.alerted[w.Fingerprint] = true
+	}
+	m.mu.Unlock()
+
+	for _, w := range fresh {
+		m.alert(w)
+	}
+
+	return result
+}
+
+func (m *ChainMonitor) alert(w ChainWarning) {
+	kind := "Intermediate"
+	if w.SelfSigned {
+		kind = "Root"
+	}
+
+	msg := notify.Message{
+		Level:   notify.LevelWarning,
+		Event:   notify.EventChainExpiring,
+		Subject: fmt.Sprintf("%s certificate %q expires in %d days", kind, w.Subject, w.DaysUntilExpiry),
+		Body: fmt.Sprintf("Issuer: %s\nExpires: %s\nSHA-256: %s\nAffected domains: %s\n\n"+
+			"The leaf certificates may still be valid, but their stored chain should be refreshed from the CA.",
+			w.Issuer, w.NotAfter.Format(time.RFC3339), w.Fingerprint, strings.Join(w.Domains, ", ")),
+	}
+	if w.DaysUntilExpiry <= 7 {
+		msg.Level = notify.LevelCritical
+	}
+
+	m.logger.Printf("%s (domains: %s)", msg.Subject, strings.Join(w.Domains, ", "))
+
+	if m.notifier == nil {
+		return
+	}
+	if err := m.notifier.Send(msg); err != nil {
+		m.logger.Printf("Failed to send chain expiry alert: %v", err)
+	}
+}
@@ -0,0 +1,179 @@
+package certmanager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testCA is a certificate authority used to build issued chains in tests
+type testCA struct {
+	cert *x509.Certificate
+	key  *ecdsa.PrivateKey
+	pem  []byte
+	url  string // AIA caIssuers URL embedded in certificates this CA signs
+	ocsp string // OCSP responder URL embedded in certificates this CA signs
+}
+
+func newTestCA(t *testing.T, name string, validDays int, parent *testCA) *testCA {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	serial, err := rand.Int(rand.Reader, big.NewInt(1<<62))
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: name},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Duration(validDays) * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+
+	signer, signerKey := template, key
+	if parent != nil {
+		signer, signerKey = parent.cert, parent.key
+		if parent.url != "" {
+			template.IssuingCertificateURL = []string{parent.url}
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, signer, &key.PublicKey, signerKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+
+	return &testCA{
+		cert: cert,
+		key:  key,
+		pem:  pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+}
+
+// issue returns a bundled leaf certificate for domain signed by ca
+func (ca *testCA) issue(t *testing.T, domain string, validDays int) *Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Duration(validDays) * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	if ca.url != "" {
+		template.IssuingCertificateURL = []string{ca.url}
+	}
+	if ca.ocsp != "" {
+		template.OCSPServer = []string{ca.ocsp}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, &key.PublicKey, ca.key)
+	require.NoError(t, err)
+
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	leaf := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: append(leaf, ca.pem...),
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		IssuerCert:  ca.pem,
+		IssuedAt:    time.Now(),
+	}
+	require.NoError(t, cert.parseCertificate())
+	return cert
+}
+
+func TestCertificate_ChainCertificates(t *testing.T) {
+	root := newTestCA(t, "Test Root", 3650, nil)
+	intermediate := newTestCA(t, "Test Intermediate", 20, root)
+	cert := intermediate.issue(t, "example.com", 10)
+
+	// Store the root in the issuer file as some CAs do
+	cert.IssuerCert = append(append([]byte{}, intermediate.pem...), root.pem...)
+
+	chain, err := cert.ChainCertificates()
+	require.NoError(t, err)
+	require.Len(t, chain, 2, "intermediate appears in both the bundle and issuer file but is listed once")
+
+	assert.Equal(t, "CN=Test Intermediate", chain[0].Subject)
+	assert.False(t, chain[0].SelfSigned)
+	assert.Equal(t, "CN=Test Root", chain[1].Subject)
+	assert.True(t, chain[1].SelfSigned)
+
+	assert.Equal(t, intermediate.cert.NotAfter, cert.ChainExpiresAt())
+}
+
+func TestCertificate_ChainRoot(t *testing.T) {
+	root := newTestCA(t, "Test Root X1", 3650, nil)
+	crossSigner := newTestCA(t, "Old Root", 3650, nil)
+	intermediate := newTestCA(t, "Test Intermediate", 365, root)
+	cert := intermediate.issue(t, "example.com", 10)
+
+	assert.Equal(t, "Test Root X1", cert.ChainRoot())
+
+	// The alternate chain ends in a cross-signed root
+	crossSigned := newTestCA(t, "Test Root X1", 365, crossSigner)
+	cert.IssuerCert = append(append([]byte{}, intermediate.pem...), crossSigned.pem...)
+	assert.Equal(t, "Old Root", cert.ChainRoot())
+
+	cert.Certificate = cert.Certificate[:len(cert.Certificate)-len(intermediate.pem)]
+	cert.IssuerCert = nil
+	assert.Empty(t, cert.ChainRoot())
+}
+
+func TestChainMonitor_Check(t *testing.T) {
+	root := newTestCA(t, "Test Root", 3650, nil)
+	expiring := newTestCA(t, "Expiring Intermediate", 20, root)
+	healthy := newTestCA(t, "Healthy Intermediate", 365, root)
+
+	certs := map[string]*Certificate{
+		"a.example.com": expiring.issue(t, "a.example.com", 10),
+		"b.example.com": expiring.issue(t, "b.example.com", 10),
+		"c.example.com": healthy.issue(t, "c.example.com", 60),
+	}
+
+	notifier := &recordingNotifier{}
+	monitor := NewChainMonitor(30, notifier, nil, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+
+	warnings := monitor.Check(certs)
+	require.Len(t, warnings, 1)
+	assert.Equal(t, "CN=Expiring Intermediate", warnings[0].Subject)
+	assert.Equal(t, []string{"a.example.com", "b.example.com"}, warnings[0].Domains)
+	assert.InDelta(t, 20, warnings[0].DaysUntilExpiry, 1)
+
+	require.Len(t, notifier.messages, 1)
+	assert.Equal(t, notify.LevelWarning, notifier.messages[0].Level)
+
+	// The same issuer is only reported once
+	monitor.Check(certs)
+	assert.Len(t, notifier.messages, 1)
+
+	// Refreshed chains clear the warning
+	delete(certs, "a.example.com")
+	delete(certs, "b.example.com")
+	assert.Empty(t, monitor.Check(certs))
+}
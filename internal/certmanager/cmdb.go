@@ -0,0 +1,58 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/sha256"
+
+	"github.com/O-tero/traefik-cert-manager/internal/inventory"
+)
+
+// inventoryRecords describes every stored certificate for the asset database
+func (cm *CertificateManager) inventoryRecords() []inventory.Record {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	roles := cm.domainRoles()
+	records := make([]inventory.Record, 0, len(cm.certs))
+	for domain, cert := range cm.certs {
+		leaf, err := cert.leaf()
+		if err != nil {
+			cm.logger.Printf("Warning: leaving %s out of the inventory: %v", domain, err)
+			continue
+		}
+
+		fingerprint := sha256.Sum256(leaf.Raw)
+		issuer := leaf.Issuer.CommonName
+		if issuer == "" {
+			issuer = leaf.Issuer.String()
+		}
+		certPath, _ := cm.GetCertificatePaths(domain)
+
+		records = append(records, inventory.Record{
+			Domain:      domain,
+			Service:     roles[domain].service,
+			SANs:        leaf.DNSNames,
+			Serial:      colonHex(leaf.SerialNumber.Bytes()),
+			Fingerprint: colonHex(fingerprint[:]),
+			Issuer:      issuer,
+			KeyType:     publicKeyType(leaf.PublicKey),
+			NotBefore:   leaf.NotBefore.UTC(),
+			NotAfter:    leaf.NotAfter.UTC(),
+			CertPath:    certPath,
+		})
+	}
+	return records
+}
+
+// ReconcileInventory reports certificates added, replaced or removed since the
+// last cycle to the configured asset database. Failures are logged and the
+// changes are reported again next cycle.
+func (cm *CertificateManager) ReconcileInventory(ctx context.Context) {
+	if cm.inventory == nil {
+		return
+	}
+
+	if _, err := cm.inventory.Reconcile(ctx, cm.inventoryRecords()); err != nil {
+		cm.logger.Printf("Warning: inventory reconciliation failed: %v", err)
+	}
+}
@@ -0,0 +1,60 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/inventory"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_ReconcileInventory(t *testing.T) {
+	var deltas []inventory.Delta
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var delta inventory.Delta
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&delta))
+		deltas = append(deltas, delta)
+	}))
+	defer server.Close()
+
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm := &CertificateManager{
+		config:    cfg,
+		logger:    logger,
+		inventory: inventory.NewReconciler(config.Inventory{Endpoint: server.URL}, 5*time.Second, testDir, logger),
+		certs:     map[string]*Certificate{"example.com": createTestCertificate("example.com", 60)},
+	}
+
+	cm.ReconcileInventory(context.Background())
+	require.Len(t, deltas, 1)
+	require.Len(t, deltas[0].Added, 1)
+	record := deltas[0].Added[0]
+	assert.Equal(t, "example.com", record.Domain)
+	assert.Equal(t, "test-service", record.Service)
+	assert.Equal(t, []string{"example.com"}, record.SANs)
+	assert.Equal(t, "RSA2048", record.KeyType)
+	assert.NotEmpty(t, record.Serial)
+	assert.NotEmpty(t, record.Fingerprint)
+
+	// Unchanged certificates are not reported again, removed ones are
+	cm.ReconcileInventory(context.Background())
+	require.Len(t, deltas, 1)
+
+	delete(cm.certs, "example.com")
+	cm.ReconcileInventory(context.Background())
+	require.Len(t, deltas, 2)
+	require.Len(t, deltas[1].Removed, 1)
+	assert.Equal(t, "example.com", deltas[1].Removed[0].Domain)
+}
@@ -0,0 +1,166 @@
+package certmanager
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"golang.org/x/net/publicsuffix"
+)
+
+// consolidationGroup is the names of one registered domain whose discovered
+// entries share their settings
+type consolidationGroup struct {
+	registered string
+	template   config.Domain     // settings of the group, without names
+	services   map[string]string // name -> service of the entry that reported it
+	names      []string
+}
+
+// consolidate applies the consolidation policy to the domains a discovery
+// source reports. Names are grouped by registered domain, e.g. example.co.uk,
+// and only merged with names whose entries have the same settings, so a name
+// is ordered the same way whichever certificate it ends up in.
+func consolidate(domains []config.Domain, policy config.Consolidation) []config.Domain {
+	if policy.Policy != config.ConsolidateSANs && policy.Policy != config.ConsolidateWildcard {
+		return domains
+	}
+
+	groups := make(map[string]*consolidationGroup)
+	var keys []string
+	seen := make(map[string]bool)
+	for _, d := range domains {
+		template := d
+		template.Service, template.Domain, template.Aliases, template.SANs = "", "", nil, nil
+		settings := fmt.Sprintf("%+v", template)
+
+		for _, name := range append(append([]string{d.Domain}, d.Aliases...), d.SANs...) {
+			if name == "" || seen[name] {
+				continue
+			}
+			seen[name] = true
+
+			registered := registeredDomain(name)
+			key := registered + "\x00" + settings
+			g, ok := groups[key]
+			if !ok {
+				g = &consolidationGroup{registered: registered, template: template, services: make(map[string]string)}
+				groups[key] = g
+				keys = append(keys, key)
+			}
+			g.services[name] = d.Service
+			g.names = append(g.names, name)
+		}
+	}
+	sort.Strings(keys)
+
+	var consolidated []config.Domain
+	for _, key := range keys {
+		g := groups[key]
+		sortNames(g.names, g.registered)
+		if policy.Policy == config.ConsolidateWildcard {
+			consolidated = append(consolidated, g.wildcard()...)
+		} else {
+			consolidated = append(consolidated, g.sans(policy.MaxNames)...)
+		}
+	}
+	return consolidated
+}
+
+// sans packs the names of the group into certificates of up to maxNames
+// names. Wildcard names keep certificates of their own, as they are
+// validated differently.
+func (g *consolidationGroup) sans(maxNames int) []config.Domain {
+	if maxNames <= 0 || maxNames > config.MaxCertificateNames {
+		maxNames = config.MaxCertificateNames
+	}
+
+	var domains, names []string
+	for _, name := range g.names {
+		if strings.HasPrefix(name, "*.") {
+			domains = append(domains, name)
+		} else {
+			names = append(names, name)
+		}
+	}
+
+	var consolidated []config.Domain
+	for _, name := range domains {
+		consolidated = append(consolidated, g.entry(name, nil))
+	}
+	for len(names) > 0 {
+		n := min(maxNames, len(names))
+		consolidated = append(consolidated, g.entry(names[0], names[1:n]))
+		names = names[n:]
+	}
+	return consolidated
+}
+
+// wildcard covers the subdomains directly below the registered domain with
+// one wildcard certificate that also names the registered domain itself, when
+// at least two were discovered. Deeper names keep certificates of their own.
+func (g *consolidationGroup) wildcard() []config.Domain {
+	wildcard := "*." + g.registered
+
+	var covered, rest []string
+	for _, name := range g.names {
+		if name != wildcard && coversHost([]string{wildcard}, name) {
+			covered = append(covered, name)
+		} else if name != wildcard && name != g.registered {
+			rest = append(rest, name)
+		}
+	}
+	if len(covered) < 2 {
+		var consolidated []config.Domain
+		for _, name := range g.names {
+			consolidated = append(consolidated, g.entry(name, nil))
+		}
+		return consolidated
+	}
+
+	var sans []string
+	if _, ok := g.services[g.registered]; ok {
+		sans = []string{g.registered}
+	}
+	entry := g.entry(wildcard, sans)
+	entry.Service = g.services[covered[0]]
+
+	consolidated := []config.Domain{entry}
+	for _, name := range rest {
+		consolidated = append(consolidated, g.entry(name, nil))
+	}
+	return consolidated
+}
+
+// entry returns the settings of the group for a certificate of name and sans
+func (g *consolidationGroup) entry(name string, sans []string) config.Domain {
+	d := g.template
+	d.Service = g.services[name]
+	d.Domain = name
+	if len(sans) > 0 {
+		d.SANs = append([]string(nil), sans...)
+	}
+	return d
+}
+
+// registeredDomain returns the domain name was registered under, e.g.
+// example.co.uk for www.example.co.uk, or name itself if it has none
+func registeredDomain(name string) string {
+	registered, err := publicsuffix.EffectiveTLDPlusOne(strings.TrimPrefix(name, "*."))
+	if err != nil {
+		return name
+	}
+	return registered
+}
+
+// sortNames sorts names with the registered domain first, so it becomes the
+// primary name of the certificate that includes it
+func sortNames(names []string, registered string) {
+	sort.Slice(names, func(i, j int) bool {
+		if (names[i] == registered) != (names[j] == registered) {
+			return names[i] == registered
+		}
+		return names[i] < names[j]
+	})
+}
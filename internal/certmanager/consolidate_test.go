@@ -0,0 +1,85 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestConsolidate(t *testing.T) {
+	discovered := []config.Domain{
+		{Service: "shop", Domain: "shop.example.com"},
+		{Service: "apex", Domain: "example.com", Aliases: []string{"api.example.com"}},
+		{Service: "blog", Domain: "blog.example.co.uk"},
+		{Service: "admin", Domain: "admin.internal.example.com"},
+		{Service: "staging", Domain: "staging.example.com", Profile: "shortlived"},
+	}
+
+	t.Run("per-domain", func(t *testing.T) {
+		got := consolidate(discovered, config.Consolidation{Policy: config.ConsolidatePerDomain})
+		assert.Equal(t, discovered, got)
+	})
+
+	t.Run("consolidate", func(t *testing.T) {
+		got := consolidate(discovered, config.Consolidation{Policy: config.ConsolidateSANs, MaxNames: 3})
+		assert.Equal(t, []config.Domain{
+			{Service: "blog", Domain: "blog.example.co.uk"},
+			{Service: "apex", Domain: "example.com", SANs: []string{"admin.internal.example.com", "api.example.com"}},
+			{Service: "shop", Domain: "shop.example.com"},
+			// Entries with other settings aren't merged
+			{Service: "staging", Domain: "staging.example.com", Profile: "shortlived"},
+		}, got)
+	})
+
+	t.Run("wildcard", func(t *testing.T) {
+		got := consolidate(discovered, config.Consolidation{Policy: config.ConsolidateWildcard})
+		assert.Equal(t, []config.Domain{
+			{Service: "blog", Domain: "blog.example.co.uk"},
+			{Service: "apex", Domain: "*.example.com", SANs: []string{"example.com"}},
+			{Service: "admin", Domain: "admin.internal.example.com"},
+			{Service: "staging", Domain: "staging.example.com", Profile: "shortlived"},
+		}, got)
+	})
+}
+
+func TestCertificateManager_SyncDiscoveredDomainsConsolidated(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Certificates.Consolidation = config.Consolidation{Policy: config.ConsolidateSANs, MaxNames: 100}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+		discovered: make(map[string][]config.Domain),
+	}
+
+	mockClient.On("RequestCertificate", "a.example.org").Return(createTestCertificate("a.example.org", 90), nil).Twice()
+
+	cm.SyncDiscoveredDomains(context.Background(), "docker", []config.Domain{
+		{Service: "a", Domain: "a.example.org"},
+		{Service: "b", Domain: "b.example.org"},
+	})
+	assert.Contains(t, cm.GetManagedDomains(), "a.example.org")
+	assert.NotContains(t, cm.GetManagedDomains(), "b.example.org")
+	assert.Equal(t, []string{"a.example.org", "b.example.org"}, cm.orderSANs("a.example.org"))
+
+	// A new subdomain joins the certificate, which is reissued to include it
+	cm.SyncDiscoveredDomains(context.Background(), "docker", []config.Domain{
+		{Service: "a", Domain: "a.example.org"},
+		{Service: "b", Domain: "b.example.org"},
+		{Service: "c", Domain: "c.example.org"},
+	})
+	require.Contains(t, cm.certs, "a.example.org")
+	assert.Equal(t, []string{"b.example.org", "c.example.org"}, cm.missingSANs("a.example.org", cm.certs["a.example.org"]))
+	mockClient.AssertExpectations(t)
+}
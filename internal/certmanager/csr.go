@@ -0,0 +1,146 @@
+package certmanager
+
+import (
+	"crypto"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"fmt"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+var (
+	oidKeyUsage    = asn1.ObjectIdentifier{2, 5, 29, 15}
+	oidExtKeyUsage = asn1.ObjectIdentifier{2, 5, 29, 37}
+	oidTLSFeature  = asn1.ObjectIdentifier{1, 3, 6, 1, 5, 5, 7, 1, 24}
+
+	// mustStapleFeature is the TLS feature extension value requesting status_request (RFC 7633)
+	mustStapleFeature = []byte{0x30, 0x03, 0x02, 0x01, 0x05}
+)
+
+var keyUsages = map[string]x509.KeyUsage{
+	"digital_signature": x509.KeyUsageDigitalSignature,
+	"key_encipherment":  x509.KeyUsageKeyEncipherment,
+	"key_agreement":     x509.KeyUsageKeyAgreement,
+}
+
+var extKeyUsages = map[string]asn1.ObjectIdentifier{
+	"server_auth": {1, 3, 6, 1, 5, 5, 7, 3, 1},
+	"client_auth": {1, 3, 6, 1, 5, 5, 7, 3, 2},
+}
+
+func (c *ACMEClient) mustStaple(domain string) bool {
+	return c.stapleFor != nil && c.stapleFor(domain)
+}
+
+// names returns the names ordered in the certificate of domain, domain first
+func (c *ACMEClient) names(domain string) []string {
+	if c.sansFor == nil {
+		return []string{domain}
+	}
+	return append([]string{domain}, c.sansFor(domain)...)
+}
+
+func (c *ACMEClient) csrTemplate(domain string) config.CSRTemplate {
+	if c.csrFor == nil {
+		return config.CSRTemplate{}
+	}
+	return c.csrFor(domain)
+}
+
+// obtain places a new order for request. Domains with a CSR template are
+// ordered with a CSR built from it instead of the one lego generates.
+func (c *ACMEClient) obtain(request certificate.ObtainRequest) (*certificate.Resource, error) {
+	domain := request.Domains[0]
+	if c.csrTemplate(domain).IsZero() {
+		request.MustStaple = c.mustStaple(domain)
+		return c.client.Certificate.Obtain(request)
+	}
+
+	csr, err := c.createCSR(domain, request.Domains, request.PrivateKey)
+	if err != nil {
+		return nil, err
+	}
+	return c.client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{
+		CSR:            csr,
+		PrivateKey:     request.PrivateKey,
+		Bundle:         request.Bundle,
+		PreferredChain: request.PreferredChain,
+		Profile:        request.Profile,
+	})
+}
+
+// createCSR builds the CSR for names from the template and Must-Staple flag of domain
+func (c *ACMEClient) createCSR(domain string, names []string, key crypto.PrivateKey) (*x509.CertificateRequest, error) {
+	tmpl := c.csrTemplate(domain)
+
+	request := &x509.CertificateRequest{
+		Subject: pkix.Name{
+			CommonName:         domain,
+			Organization:       tmpl.Organization,
+			OrganizationalUnit: tmpl.OrganizationalUnit,
+			Country:            tmpl.Country,
+			Province:           tmpl.Province,
+			Locality:           tmpl.Locality,
+		},
+		DNSNames: names,
+	}
+
+	if len(tmpl.KeyUsage) > 0 {
+		var usage x509.KeyUsage
+		for _, name := range tmpl.KeyUsage {
+			usage |= keyUsages[name]
+		}
+		ext, err := keyUsageExtension(usage)
+		if err != nil {
+			return nil, err
+		}
+		request.ExtraExtensions = append(request.ExtraExtensions, ext)
+	}
+
+	if len(tmpl.ExtKeyUsage) > 0 {
+		var oids []asn1.ObjectIdentifier
+		for _, name := range tmpl.ExtKeyUsage {
+			oids = append(oids, extKeyUsages[name])
+		}
+		value, err := asn1.Marshal(oids)
+		if err != nil {
+			return nil, fmt.Errorf("failed to encode extended key usage: %w", err)
+		}
+		request.ExtraExtensions = append(request.ExtraExtensions, pkix.Extension{Id: oidExtKeyUsage, Value: value})
+	}
+
+	if c.mustStaple(domain) {
+		request.ExtraExtensions = append(request.ExtraExtensions, pkix.Extension{Id: oidTLSFeature, Value: mustStapleFeature})
+	}
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, request, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create CSR: %w", err)
+	}
+	return x509.ParseCertificateRequest(der)
+}
+
+// keyUsageExtension encodes usage as the critical key usage extension
+func keyUsageExtension(usage x509.KeyUsage) (pkix.Extension, error) {
+	var bits asn1.BitString
+	for i := 0; i < 9; i++ {
+		if usage&(1<<i) == 0 {
+			continue
+		}
+		for len(bits.Bytes) <= i/8 {
+			bits.Bytes = append(bits.Bytes, 0)
+		}
+		bits.Bytes[i/8] |= 0x80 >> (i % 8)
+		bits.BitLength = i + 1
+	}
+
+	value, err := asn1.Marshal(bits)
+	if err != nil {
+		return pkix.Extension{}, fmt.Errorf("failed to encode key usage: %w", err)
+	}
+	return pkix.Extension{Id: oidKeyUsage, Critical: true, Value: value}, nil
+}
@@ -0,0 +1,74 @@
+package certmanager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"math/big"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// extension returns the value of the extension with id, or nil
+func extension(exts []pkix.Extension, id asn1.ObjectIdentifier) *pkix.Extension {
+	for i := range exts {
+		if exts[i].Id.Equal(id) {
+			return &exts[i]
+		}
+	}
+	return nil
+}
+
+func TestACMEClient_CreateCSR(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	client := &ACMEClient{
+		stapleFor: func(domain string) bool { return domain == "example.com" },
+		csrFor: func(domain string) config.CSRTemplate {
+			return config.CSRTemplate{
+				Organization: []string{"Example Ltd"},
+				Country:      []string{"KE"},
+				KeyUsage:     []string{"digital_signature", "key_encipherment"},
+				ExtKeyUsage:  []string{"server_auth", "client_auth"},
+			}
+		},
+	}
+
+	csr, err := client.createCSR("example.com", []string{"example.com", "www.example.com"}, key)
+	require.NoError(t, err)
+	require.NoError(t, csr.CheckSignature())
+
+	assert.Equal(t, "example.com", csr.Subject.CommonName)
+	assert.Equal(t, []string{"Example Ltd"}, csr.Subject.Organization)
+	assert.Equal(t, []string{"KE"}, csr.Subject.Country)
+	assert.Equal(t, []string{"example.com", "www.example.com"}, csr.DNSNames)
+
+	staple := extension(csr.Extensions, oidTLSFeature)
+	require.NotNil(t, staple, "Must-Staple extension missing")
+	assert.Equal(t, mustStapleFeature, staple.Value)
+
+	var ekus []asn1.ObjectIdentifier
+	_, err = asn1.Unmarshal(extension(csr.Extensions, oidExtKeyUsage).Value, &ekus)
+	require.NoError(t, err)
+	assert.Equal(t, []asn1.ObjectIdentifier{extKeyUsages["server_auth"], extKeyUsages["client_auth"]}, ekus)
+
+	// The key usage must be encoded the way crypto/x509 encodes it in certificates
+	template := &x509.Certificate{SerialNumber: big.NewInt(1), KeyUsage: x509.KeyUsageDigitalSignature | x509.KeyUsageKeyEncipherment}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(der)
+	require.NoError(t, err)
+	assert.Equal(t, extension(cert.Extensions, oidKeyUsage), extension(csr.Extensions, oidKeyUsage))
+
+	// Only flagged domains request Must-Staple
+	csr, err = client.createCSR("other.example.com", []string{"other.example.com"}, key)
+	require.NoError(t, err)
+	assert.Nil(t, extension(csr.Extensions, oidTLSFeature))
+}
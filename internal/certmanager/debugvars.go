@@ -0,0 +1,35 @@
+package certmanager
+
+import "time"
+
+// DebugVars returns the state of the manager exposed on the debug endpoint,
+// read each time the endpoint is requested
+func (cm *CertificateManager) DebugVars() map[string]func() any {
+	return map[string]func() any{
+		"orders_in_flight": func() any { return cm.OrdersInFlight() },
+		"acme_retries":     func() any { return cm.metrics.ACMERetries() },
+	}
+}
+
+// OrdersInFlight returns when each order still in flight in this process was
+// placed, by domain. An order that has been in flight for long is hanging.
+func (cm *CertificateManager) OrdersInFlight() map[string]time.Time {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	orders := make(map[string]time.Time, len(cm.ordering))
+	for domain, since := range cm.ordering {
+		orders[domain] = since
+	}
+	return orders
+}
+
+// DebugVars returns the state of the scheduler exposed on the debug endpoint,
+// read each time the endpoint is requested
+func (s *Scheduler) DebugVars() map[string]func() any {
+	return map[string]func() any{
+		"renewal_queue_depth": func() any { return s.renewalService.queue.Len() },
+		"next_run":            func() any { return s.GetNextRunTime() },
+		"running":             func() any { return s.IsRunning() },
+	}
+}
@@ -0,0 +1,264 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	// challengePathPrefix is where HTTP-01 tokens are served
+	challengePathPrefix = "/.well-known/acme-challenge/"
+	// diagnosisTimeout bounds a complete HTTP-01 diagnosis
+	diagnosisTimeout = 30 * time.Second
+)
+
+// ProbeResult is the outcome of fetching a challenge URL from one vantage point
+type ProbeResult struct {
+	Reachable  bool   `json:"reachable"` // the expected token came back
+	StatusCode int    `json:"status_code,omitempty"`
+	Error      string `json:"error,omitempty"`
+}
+
+func (r ProbeResult) String() string {
+	switch {
+	case r.Reachable:
+		return "reachable"
+	case r.Error != "":
+		return "unreachable: " + r.Error
+	default:
+		return fmt.Sprintf("unexpected response (status %d)", r.StatusCode)
+	}
+}
+
+// HTTP01Diagnosis explains why an HTTP-01 validation may have failed
+type HTTP01Diagnosis struct {
+	Domain     string       `json:"domain"`
+	URL        string       `json:"url"`
+	Local      ProbeResult  `json:"local"`
+	External   *ProbeResult `json:"external,omitempty"` // nil without a configured prober
+	Conclusion string       `json:"conclusion"`
+}
+
+func (d *HTTP01Diagnosis) String() string {
+	parts := []string{"local probe " + d.Local.String()}
+	if d.External != nil {
+		parts = append(parts, "external probe "+d.External.String())
+	}
+	return fmt.Sprintf("%s: %s", strings.Join(parts, ", "), d.Conclusion)
+}
+
+// Reachable reports whether the CA can be expected to fetch the challenge: from
+// outside when an external probe ran, otherwise from this host
+func (d *HTTP01Diagnosis) Reachable() bool {
+	if d.External != nil {
+		return d.External.Reachable
+	}
+	return d.Local.Reachable
+}
+
+// HTTP01Diagnoser serves a probe token on the challenge listener and fetches it
+// through the public domain, locally and from an external prober, to tell CA-side
+// problems apart from routing and firewall problems.
+//
+// The prober is called as GET <prober>?url=<challenge URL> and must answer with
+// JSON {"status_code": 200, "body": "...", "error": ""}.
+type HTTP01Diagnoser struct {
+	proberURL  string
+	listenAddr string
+	httpClient *http.Client
+	logger     *log.Logger
+
+	// challengeURL builds the public URL of a token; replaced in tests
+	challengeURL func(domain, token string) string
+	lookupHost   func(ctx context.Context, host string) ([]string, error)
+}
+
+// NewHTTP01Diagnoser returns a diagnoser binding listenAddr, the address of
+// the HTTP-01 challenge server; empty uses its default
+func NewHTTP01Diagnoser(proberURL, listenAddr string, logger *log.Logger) *HTTP01Diagnoser {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Diagnose] ", log.LstdFlags)
+	}
+	if listenAddr == "" {
+		listenAddr = defaultHTTP01Address
+	}
+
+	return &HTTP01Diagnoser{
+		proberURL:  proberURL,
+		listenAddr: listenAddr,
+		httpClient: &http.Client{Timeout: 10 * time.Second},
+		logger:     logger,
+		challengeURL: func(domain, token string) string {
+			return "http://" + domain + challengePathPrefix + token
+		},
+		lookupHost: net.DefaultResolver.LookupHost,
+	}
+}
+
+// Diagnose probes the challenge path of domain. It must run while no ACME
+// challenge is in progress, since it binds the challenge listener.
+func (d *HTTP01Diagnoser) Diagnose(ctx context.Context, domain string) (*HTTP01Diagnosis, error) {
+	ctx, cancel := context.WithTimeout(ctx, diagnosisTimeout)
+	defer cancel()
+
+	token, err := probeToken()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", d.listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind challenge listener %s: %w", d.listenAddr, err)
+	}
+
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != challengePathPrefix+token {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, token)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	diagnosis := &HTTP01Diagnosis{
+		Domain: domain,
+		URL:    d.challengeURL(domain, token),
+	}
+
+	diagnosis.Local = d.probeLocal(ctx, diagnosis.URL, token)
+	if d.proberURL != "" {
+		external := d.probeExternal(ctx, diagnosis.URL, token)
+		diagnosis.External = &external
+	}
+	diagnosis.Conclusion = conclude(diagnosis, d.listenAddr)
+
+	d.logger.Printf("HTTP-01 diagnosis for %s: %s", domain, diagnosis)
+	return diagnosis, nil
+}
+
+func (d *HTTP01Diagnoser) probeLocal(ctx context.Context, target, token string) ProbeResult {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, target, nil)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(io.LimitReader(resp.Body, 4096))
+	return ProbeResult{
+		Reachable:  resp.StatusCode == http.StatusOK && strings.TrimSpace(string(body)) == token,
+		StatusCode: resp.StatusCode,
+	}
+}
+
+func (d *HTTP01Diagnoser) probeExternal(ctx context.Context, target, token string) ProbeResult {
+	proberURL, err := url.Parse(d.proberURL)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("invalid prober URL: %v", err)}
+	}
+	query := proberURL.Query()
+	query.Set("url", target)
+	proberURL.RawQuery = query.Encode()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, proberURL.String(), nil)
+	if err != nil {
+		return ProbeResult{Error: err.Error()}
+	}
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return ProbeResult{Error: fmt.Sprintf("prober unavailable: %v", err)}
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return ProbeResult{Error: fmt.Sprintf("prober returned status %d", resp.StatusCode)}
+	}
+
+	var result struct {
+		StatusCode int    `json:"status_code"`
+		Body       string `json:"body"`
+		Error      string `json:"error"`
+	}
+	if err := json.NewDecoder(io.LimitReader(resp.Body, 64*1024)).Decode(&result); err != nil {
+		return ProbeResult{Error: fmt.Sprintf("invalid prober response: %v", err)}
+	}
+
+	return ProbeResult{
+		Reachable:  result.Error == "" && result.StatusCode == http.StatusOK && strings.TrimSpace(result.Body) == token,
+		StatusCode: result.StatusCode,
+		Error:      result.Error,
+	}
+}
+
+// CheckHTTP01Reachability probes the challenge path of every managed domain
+// validated with http-01 and returns the diagnoses of those it isn't reachable
+// for. It binds the challenge listener, so it must run before any order is
+// placed; an error means the listener couldn't be bound.
+func (cm *CertificateManager) CheckHTTP01Reachability(ctx context.Context) ([]*HTTP01Diagnosis, error) {
+	if cm.diagnoser == nil {
+		return nil, nil
+	}
+
+	domains := cm.GetManagedDomains()
+	sort.Strings(domains)
+
+	var unreachable []*HTTP01Diagnosis
+	for _, domain := range domains {
+		if strings.HasPrefix(domain, "*.") || cm.config.ChallengeFor(domain) == "dns-01" || cm.issuedInternally(domain) {
+			continue
+		}
+		if ctx.Err() != nil {
+			return unreachable, ctx.Err()
+		}
+
+		diagnosis, err := cm.diagnoser.Diagnose(ctx, domain)
+		if err != nil {
+			return unreachable, err
+		}
+		if !diagnosis.Reachable() {
+			unreachable = append(unreachable, diagnosis)
+		}
+	}
+	return unreachable, nil
+}
+
+func conclude(d *HTTP01Diagnosis, listenAddr string) string {
+	switch {
+	case d.External != nil && d.External.Reachable:
+		return "the challenge path is reachable from outside, so the failure is likely on the CA side (outage, CAA record or rate limit)"
+	case d.External != nil && d.Local.Reachable:
+		return "the challenge path is reachable from this host but not from outside; check firewalls, port 80 forwarding and public DNS records"
+	case d.Local.Reachable:
+		return "the challenge path is reachable from this host; configure acme.http01_prober to check external reachability"
+	default:
+		return "the challenge path is not reachable even from this host; check DNS, the Traefik router for " +
+			challengePathPrefix + " and that it forwards to " + listenAddr
+	}
+}
+
+func probeToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("failed to generate probe token: %w", err)
+	}
+	return "cert-manager-probe-" + hex.EncodeToString(b), nil
+}
@@ -0,0 +1,127 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// newTestDiagnoser binds the probe listener on a free local port and points the
+// challenge URL straight at it, as if Traefik forwarded the request
+func newTestDiagnoser(t *testing.T, proberURL string) *HTTP01Diagnoser {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	require.NoError(t, err)
+	addr := listener.Addr().String()
+	listener.Close()
+
+	d := NewHTTP01Diagnoser(proberURL, addr, nil)
+	d.challengeURL = func(domain, token string) string {
+		return "http://" + addr + challengePathPrefix + token
+	}
+	return d
+}
+
+// newTestProber fetches the requested URL itself, or reports it unreachable when blocked
+func newTestProber(t *testing.T, blocked bool) *httptest.Server {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		result := map[string]interface{}{}
+		if blocked {
+			result["error"] = "connection timed out"
+		} else if resp, err := http.Get(r.URL.Query().Get("url")); err != nil {
+			result["error"] = err.Error()
+		} else {
+			body, _ := io.ReadAll(resp.Body)
+			resp.Body.Close()
+			result["status_code"] = resp.StatusCode
+			result["body"] = string(body)
+		}
+		json.NewEncoder(w).Encode(result)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestHTTP01Diagnoser_ExternallyReachable(t *testing.T) {
+	prober := newTestProber(t, false)
+	d := newTestDiagnoser(t, prober.URL)
+
+	diagnosis, err := d.Diagnose(context.Background(), "example.com")
+	require.NoError(t, err)
+
+	assert.True(t, diagnosis.Local.Reachable)
+	require.NotNil(t, diagnosis.External)
+	assert.True(t, diagnosis.External.Reachable)
+	assert.Contains(t, diagnosis.Conclusion, "CA side")
+}
+
+func TestHTTP01Diagnoser_BlockedFromOutside(t *testing.T) {
+	prober := newTestProber(t, true)
+	d := newTestDiagnoser(t, prober.URL)
+
+	diagnosis, err := d.Diagnose(context.Background(), "example.com")
+	require.NoError(t, err)
+
+	assert.True(t, diagnosis.Local.Reachable)
+	require.NotNil(t, diagnosis.External)
+	assert.False(t, diagnosis.External.Reachable)
+	assert.Equal(t, "connection timed out", diagnosis.External.Error)
+	assert.Contains(t, diagnosis.Conclusion, "firewalls")
+}
+
+func TestHTTP01Diagnoser_NotRoutedLocally(t *testing.T) {
+	// Traefik answers but doesn't forward the challenge path
+	traefik := httptest.NewServer(http.NotFoundHandler())
+	defer traefik.Close()
+
+	d := newTestDiagnoser(t, "")
+	d.challengeURL = func(domain, token string) string {
+		return traefik.URL + challengePathPrefix + token
+	}
+
+	diagnosis, err := d.Diagnose(context.Background(), "example.com")
+	require.NoError(t, err)
+
+	assert.False(t, diagnosis.Local.Reachable)
+	assert.Equal(t, http.StatusNotFound, diagnosis.Local.StatusCode)
+	assert.Nil(t, diagnosis.External)
+	assert.Contains(t, diagnosis.Conclusion, "not reachable even from this host")
+}
+
+func TestCertificateManager_CheckHTTP01Reachability(t *testing.T) {
+	// Traefik routes the challenge path of example.com only
+	traefik := httptest.NewServer(http.NotFoundHandler())
+	defer traefik.Close()
+
+	d := newTestDiagnoser(t, "")
+	local := d.challengeURL
+	d.challengeURL = func(domain, token string) string {
+		if domain == "example.com" {
+			return local(domain, token)
+		}
+		return traefik.URL + challengePathPrefix + token
+	}
+
+	cfg := createTestConfig()
+	cfg.Domains = append(cfg.Domains,
+		config.Domain{Service: "shop", Domain: "shop.example.com", Challenge: "dns-01"},
+		config.Domain{Service: "wildcard", Domain: "*.example.com"})
+	cm := &CertificateManager{config: cfg, diagnoser: d, logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags)}
+
+	unreachable, err := cm.CheckHTTP01Reachability(context.Background())
+	require.NoError(t, err)
+	require.Len(t, unreachable, 1)
+	assert.Equal(t, "api.example.com", unreachable[0].Domain)
+	assert.False(t, unreachable[0].Reachable())
+}
@@ -0,0 +1,169 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// maxDirectorySize bounds the size of a cached ACME directory document
+const maxDirectorySize = 1 << 20
+
+// directoryCacheName returns the per-CA file the ACME directory is cached in
+func directoryCacheName(caDirURL string) string {
+	sum := sha256.Sum256([]byte(caDirURL))
+	return ".acme-directory-" + hex.EncodeToString(sum[:8]) + ".json"
+}
+
+// directoryCache is an HTTP transport that keeps the last good copy of a CA's
+// directory on disk and serves it when the CA is briefly unavailable, so a blip
+// at startup doesn't prevent the client from being created
+type directoryCache struct {
+	next   http.RoundTripper
+	url    string
+	path   string
+	logger *log.Logger
+}
+
+func newDirectoryCache(next http.RoundTripper, caDirURL, storagePath string, logger *log.Logger) *directoryCache {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if logger == nil {
+		logger = log.New(os.Stdout, "[ACME] ", log.LstdFlags)
+	}
+
+	return &directoryCache{
+		next:   next,
+		url:    caDirURL,
+		path:   filepath.Join(storagePath, directoryCacheName(caDirURL)),
+		logger: logger,
+	}
+}
+
+func (d *directoryCache) RoundTrip(req *http.Request) (*http.Response, error) {
+	if req.Method != http.MethodGet || req.URL.String() != d.url {
+		return d.next.RoundTrip(req)
+	}
+
+	resp, err := d.next.RoundTrip(req)
+	if err == nil && resp.StatusCode == http.StatusOK {
+		body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxDirectorySize))
+		resp.Body.Close()
+		if readErr != nil {
+			return d.cached(req, fmt.Errorf("failed to read directory: %w", readErr))
+		}
+
+		if writeErr := os.WriteFile(d.path, body, 0644); writeErr != nil {
+			d.logger.Printf("Warning: failed to cache ACME directory: %v", writeErr)
+		}
+		resp.Body = io.NopCloser(bytes.NewReader(body))
+		return resp, nil
+	}
+
+	if err == nil && resp.StatusCode < http.StatusInternalServerError {
+		// The CA answered; a client error isn't something a cached copy should hide
+		return resp, nil
+	}
+	if err == nil {
+		resp.Body.Close()
+		err = fmt.Errorf("directory returned status %d", resp.StatusCode)
+	}
+	return d.cached(req, err)
+}
+
+// cached answers req from the cached directory, or returns cause if there is none
+func (d *directoryCache) cached(req *http.Request, cause error) (*http.Response, error) {
+	body, err := os.ReadFile(d.path)
+	if err != nil {
+		return nil, cause
+	}
+
+	d.logger.Printf("Warning: ACME directory %s unavailable (%v), using cached copy", d.url, cause)
+
+	return &http.Response{
+		Status:        "200 OK",
+		StatusCode:    http.StatusOK,
+		Proto:         "HTTP/1.1",
+		ProtoMajor:    1,
+		ProtoMinor:    1,
+		Header:        http.Header{"Content-Type": []string{"application/json"}},
+		Body:          io.NopCloser(bytes.NewReader(body)),
+		ContentLength: int64(len(body)),
+		Request:       req,
+	}, nil
+}
+
+// isTransientACMEError reports whether an ACME operation failed for a reason that a
+// later attempt in the same run may not hit: network failures, rejected nonces that
+// outlasted lego's own retries, and server errors from the CA
+func isTransientACMEError(err error) bool {
+	var nonceErr *acme.NonceError
+	if errors.As(err, &nonceErr) {
+		return true
+	}
+
+	var problem *acme.ProblemDetails
+	if errors.As(err, &problem) {
+		return problem.HTTPStatus >= http.StatusInternalServerError
+	}
+
+	var netErr net.Error
+	if errors.As(err, &netErr) {
+		return true
+	}
+	return errors.Is(err, io.ErrUnexpectedEOF)
+}
+
+// withRetry runs op, retrying transient failures with exponential backoff
+// until ctx is done
+func (c *ACMEClient) withRetry(ctx context.Context, action, domain string, op func() error) error {
+	attempts := c.retryAttempts
+	if attempts < 1 {
+		attempts = 1
+	}
+	backoff := c.retryBackoff
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		err = op()
+		if err != nil && ctx.Err() != nil {
+			// lego doesn't keep the cause of a cancelled request in its errors
+			return fmt.Errorf("%s for %s interrupted: %w: %v", action, domain, ctx.Err(), err)
+		}
+		if err == nil || !isTransientACMEError(err) {
+			return err
+		}
+		if attempt == attempts {
+			break
+		}
+
+		c.metrics.retryACME()
+		c.logger.Printf("Transient error during %s for %s (attempt %d/%d), retrying in %v: %v",
+			action, domain, attempt, attempts, backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return fmt.Errorf("%s for %s interrupted: %w: %v", action, domain, ctx.Err(), err)
+		}
+		backoff *= 2
+	}
+
+	err = classifyACMEError(err)
+	if attempts > 1 {
+		return fmt.Errorf("gave up after %d attempts: %w", attempts, err)
+	}
+	return err
+}
@@ -0,0 +1,150 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDirectoryCache_ServesCachedDirectoryWhenCAIsDown(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		fmt.Fprint(w, `{"newNonce":"https://ca.example/nonce"}`)
+	}))
+	defer server.Close()
+
+	client := &http.Client{Transport: newDirectoryCache(nil, server.URL+"/directory", testDir, logger)}
+
+	resp, err := client.Get(server.URL + "/directory")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.FileExists(t, filepath.Join(testDir, directoryCacheName(server.URL+"/directory")))
+
+	status = http.StatusServiceUnavailable
+	resp, err = client.Get(server.URL + "/directory")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusOK, resp.StatusCode)
+	assert.JSONEq(t, `{"newNonce":"https://ca.example/nonce"}`, string(body))
+
+	// Client errors come from a reachable CA and are passed through
+	status = http.StatusNotFound
+	resp, err = client.Get(server.URL + "/directory")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusNotFound, resp.StatusCode)
+
+	// Only the directory is cached
+	status = http.StatusServiceUnavailable
+	resp, err = client.Get(server.URL + "/other")
+	require.NoError(t, err)
+	resp.Body.Close()
+	assert.Equal(t, http.StatusServiceUnavailable, resp.StatusCode)
+}
+
+func TestDirectoryCache_NoCachedCopy(t *testing.T) {
+	testDir := setupTestDir(t)
+
+	server := httptest.NewServer(http.NotFoundHandler())
+	url := server.URL + "/directory"
+	server.Close()
+
+	client := &http.Client{Transport: newDirectoryCache(nil, url, testDir, nil)}
+	_, err := client.Get(url)
+	assert.Error(t, err)
+}
+
+func TestIsTransientACMEError(t *testing.T) {
+	tests := []struct {
+		name      string
+		err       error
+		transient bool
+	}{
+		{"bad nonce", &acme.NonceError{ProblemDetails: &acme.ProblemDetails{HTTPStatus: 400, Type: acme.BadNonceErr}}, true},
+		{"server error", fmt.Errorf("order: %w", &acme.ProblemDetails{HTTPStatus: 503}), true},
+		{"rate limited", &acme.ProblemDetails{HTTPStatus: 429}, false},
+		{"unauthorized", &acme.ProblemDetails{HTTPStatus: 403}, false},
+		{"network", fmt.Errorf("failed to do request: %w", &net.OpError{Op: "dial", Err: errors.New("connection refused")}), true},
+		{"unexpected EOF", fmt.Errorf("read: %w", io.ErrUnexpectedEOF), true},
+		{"other", errors.New("invalid domain"), false},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			assert.Equal(t, tt.transient, isTransientACMEError(tt.err))
+		})
+	}
+}
+
+func TestACMEClient_WithRetry(t *testing.T) {
+	client := &ACMEClient{
+		logger:        log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		retryAttempts: 3,
+		retryBackoff:  time.Millisecond,
+	}
+	transient := &acme.ProblemDetails{HTTPStatus: 500}
+
+	t.Run("recovers", func(t *testing.T) {
+		calls := 0
+		err := client.withRetry(context.Background(), "issuance", "example.com", func() error {
+			calls++
+			if calls < 3 {
+				return transient
+			}
+			return nil
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("gives up", func(t *testing.T) {
+		calls := 0
+		err := client.withRetry(context.Background(), "issuance", "example.com", func() error {
+			calls++
+			return transient
+		})
+		assert.Error(t, err)
+		assert.ErrorIs(t, err, transient)
+		assert.Equal(t, 3, calls)
+	})
+
+	t.Run("permanent error", func(t *testing.T) {
+		calls := 0
+		err := client.withRetry(context.Background(), "issuance", "example.com", func() error {
+			calls++
+			return &acme.ProblemDetails{HTTPStatus: 403}
+		})
+		assert.Error(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("cancelled", func(t *testing.T) {
+		ctx, cancel := context.WithCancel(context.Background())
+		calls := 0
+		err := client.withRetry(ctx, "issuance", "example.com", func() error {
+			calls++
+			cancel()
+			return transient
+		})
+		assert.ErrorIs(t, err, context.Canceled)
+		assert.Equal(t, 1, calls)
+	})
+}
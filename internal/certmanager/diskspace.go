@@ -0,0 +1,146 @@
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+// ErrInsufficientStorage is returned when the storage volume is too full to safely write certificates
+var ErrInsufficientStorage = errors.New("insufficient storage space")
+
+// errDiskUsageUnsupported is returned on platforms where disk usage cannot be queried
+var errDiskUsageUnsupported = errors.New("disk usage not supported on this platform")
+
+// DiskUsage describes capacity of the volume holding the certificate storage path
+type DiskUsage struct {
+	TotalBytes  uint64
+	FreeBytes   uint64
+	TotalInodes uint64
+	FreeInodes  uint64
+}
+
+// StorageMonitor tracks free space and inodes under the storage path
+type StorageMonitor struct {
+	path          string
+	minFreeBytes  uint64
+	minFreeInodes uint64
+	notifier      notify.Notifier
+	metrics       *Metrics
+	logger        *log.Logger
+	mu            sync.Mutex
+	low           bool
+}
+
+func NewStorageMonitor(path string, minFreeMB, minFreeInodes int, notifier notify.Notifier, metrics *Metrics, logger *log.Logger) *StorageMonitor {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[StorageMonitor] ", log.LstdFlags)
+	}
+
+	return &StorageMonitor{
+		path:          path,
+		minFreeBytes:  uint64(minFreeMB) * 1024 * 1024,
+		minFreeInodes: uint64(minFreeInodes),
+		notifier:      notifier,
+		metrics:       metrics,
+		logger:        logger,
+	}
+}
+
+// Check refreshes storage metrics and alerts operators when the volume crosses the threshold
+func (m *StorageMonitor) Check() (DiskUsage, error) {
+	usage, err := getDiskUsage(m.path)
+	if err != nil {
+		return DiskUsage{}, err
+	}
+
+	low := m.isLow(usage)
+	m.metrics.setDiskUsage(usage, low)
+
+	m.mu.Lock()
+	changed := low != m.low
+	m.low = low
+	m.mu.Unlock()
+
+	if changed {
+		m.alert(usage, low)
+	}
+
+	return usage, nil
+}
+
+// EnsureCapacity returns ErrInsufficientStorage, a storage failure, if new
+// certificates cannot be written safely
+func (m *StorageMonitor) EnsureCapacity() error {
+	usage, err := m.Check()
+	if errors.Is(err, errDiskUsageUnsupported) {
+		return nil
+	}
+	if err != nil {
+		return fmt.Errorf("failed to check storage capacity: %w", err)
+	}
+
+	if m.isLow(usage) {
+		return classify(ErrStorage, fmt.Errorf("%w: %d MB and %d inodes free under %s", ErrInsufficientStorage,
+			usage.FreeBytes/(1024*1024), usage.FreeInodes, m.path))
+	}
+
+	return nil
+}
+
+// Writable verifies the storage path exists and accepts new files by writing and
+// removing a probe file
+func (m *StorageMonitor) Writable() error {
+	probe, err := os.CreateTemp(m.path, ".probe-*")
+	if err != nil {
+		return fmt.Errorf("storage path %s is not writable: %w", m.path, err)
+	}
+	probe.Close()
+
+	if err := os.Remove(probe.Name()); err != nil {
+		return fmt.Errorf("failed to remove storage probe: %w", err)
+	}
+	return nil
+}
+
+func (m *StorageMonitor) isLow(usage DiskUsage) bool {
+	if usage.FreeBytes < m.minFreeBytes {
+		return true
+	}
+	// Some filesystems (e.g. btrfs) report zero inodes; treat that as unlimited
+	if usage.TotalInodes > 0 && usage.FreeInodes < m.minFreeInodes {
+		return true
+	}
+	return false
+}
+
+func (m *StorageMonitor) alert(usage DiskUsage, low bool) {
+	msg := notify.Message{
+		Level:   notify.LevelInfo,
+		Event:   notify.EventStorageRecovered,
+		Subject: fmt.Sprintf("Certificate storage recovered on %s", m.path),
+		Body: fmt.Sprintf("Free space: %d MB\nFree inodes: %d\nCertificate issuance has resumed.",
+			usage.FreeBytes/(1024*1024), usage.FreeInodes),
+	}
+	if low {
+		msg.Level = notify.LevelCritical
+		msg.Event = notify.EventStorageLow
+		msg.Subject = fmt.Sprintf("Certificate storage low on %s", m.path)
+		msg.Body = fmt.Sprintf("Free space: %d MB (minimum %d MB)\nFree inodes: %d (minimum %d)\n"+
+			"New certificate issuance and renewal are refused until space is freed.",
+			usage.FreeBytes/(1024*1024), m.minFreeBytes/(1024*1024), usage.FreeInodes, m.minFreeInodes)
+	}
+
+	m.logger.Printf("%s", msg.Subject)
+
+	if m.notifier == nil {
+		return
+	}
+	if err := m.notifier.Send(msg); err != nil {
+		m.logger.Printf("Failed to send storage alert: %v", err)
+	}
+}
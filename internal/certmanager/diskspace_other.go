@@ -0,0 +1,7 @@
+//go:build !unix
+
+package certmanager
+
+func getDiskUsage(path string) (DiskUsage, error) {
+	return DiskUsage{}, errDiskUsageUnsupported
+}
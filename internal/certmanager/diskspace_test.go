@@ -0,0 +1,98 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Send(msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestStorageMonitor_IsLow(t *testing.T) {
+	monitor := NewStorageMonitor("/tmp", 10, 100, nil, nil, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+
+	assert.False(t, monitor.isLow(DiskUsage{FreeBytes: 20 * 1024 * 1024, TotalInodes: 1000, FreeInodes: 500}))
+	assert.True(t, monitor.isLow(DiskUsage{FreeBytes: 5 * 1024 * 1024, TotalInodes: 1000, FreeInodes: 500}))
+	assert.True(t, monitor.isLow(DiskUsage{FreeBytes: 20 * 1024 * 1024, TotalInodes: 1000, FreeInodes: 50}))
+	// Filesystems without inode accounting report zero totals
+	assert.False(t, monitor.isLow(DiskUsage{FreeBytes: 20 * 1024 * 1024}))
+}
+
+func TestStorageMonitor_EnsureCapacity(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	if _, err := getDiskUsage(testDir); errors.Is(err, errDiskUsageUnsupported) {
+		t.Skip("disk usage not supported on this platform")
+	}
+
+	monitor := NewStorageMonitor(testDir, 0, 0, nil, nil, logger)
+	require.NoError(t, monitor.EnsureCapacity())
+
+	// No real volume has an exabyte free, so this must be refused and alerted once
+	notifier := &recordingNotifier{}
+	monitor = NewStorageMonitor(testDir, 1<<40, 0, notifier, nil, logger)
+
+	err := monitor.EnsureCapacity()
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInsufficientStorage))
+
+	_ = monitor.EnsureCapacity()
+	require.Len(t, notifier.messages, 1)
+	assert.Equal(t, notify.LevelCritical, notifier.messages[0].Level)
+}
+
+func TestStorageMonitor_Writable(t *testing.T) {
+	testDir := setupTestDir(t)
+
+	monitor := NewStorageMonitor(testDir, 0, 0, nil, nil, nil)
+	require.NoError(t, monitor.Writable())
+
+	entries, err := os.ReadDir(testDir)
+	require.NoError(t, err)
+	assert.Empty(t, entries, "probe file should be removed")
+
+	missing := NewStorageMonitor(testDir+"/missing", 0, 0, nil, nil, nil)
+	assert.Error(t, missing.Writable())
+}
+
+func TestCertificateManager_RequestCertificate_InsufficientStorage(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	if _, err := getDiskUsage(testDir); errors.Is(err, errDiskUsageUnsupported) {
+		t.Skip("disk usage not supported on this platform")
+	}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:         cfg,
+		acmeClient:     mockClient,
+		storageMonitor: NewStorageMonitor(testDir, 1<<40, 0, nil, nil, logger),
+		logger:         logger,
+		certs:          make(map[string]*Certificate),
+	}
+
+	err := cm.RequestCertificate(context.Background(), "example.com")
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrInsufficientStorage))
+
+	mockClient.AssertNotCalled(t, "RequestCertificate")
+}
@@ -0,0 +1,22 @@
+//go:build unix
+
+package certmanager
+
+import (
+	"fmt"
+	"syscall"
+)
+
+func getDiskUsage(path string) (DiskUsage, error) {
+	var stat syscall.Statfs_t
+	if err := syscall.Statfs(path, &stat); err != nil {
+		return DiskUsage{}, fmt.Errorf("failed to stat filesystem: %w", err)
+	}
+
+	return DiskUsage{
+		TotalBytes:  uint64(stat.Blocks) * uint64(stat.Bsize),
+		FreeBytes:   uint64(stat.Bavail) * uint64(stat.Bsize),
+		TotalInodes: uint64(stat.Files),
+		FreeInodes:  uint64(stat.Ffree),
+	}, nil
+}
@@ -0,0 +1,237 @@
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+// Route is a hostname served by the proxy
+type Route struct {
+	Host string
+	TLS  bool // the proxy terminates TLS for it, so it needs a certificate
+}
+
+// Drift is the difference between the hostnames the proxy routes and the
+// certificates kept for them
+type Drift struct {
+	Uncovered []string              `json:"uncovered" yaml:"uncovered"` // hostnames routed over TLS that no certificate covers
+	Orphaned  []OrphanedCertificate `json:"orphaned" yaml:"orphaned"`   // certificates for domains no router serves
+}
+
+// OrphanedCertificate is a stored certificate none of whose names is routed
+type OrphanedCertificate struct {
+	Domain     string `json:"domain" yaml:"domain"`
+	Configured bool   `json:"configured" yaml:"configured"` // configured or discovered, so pruning keeps it
+}
+
+// Empty reports whether the routes and the certificates match
+func (d Drift) Empty() bool {
+	return len(d.Uncovered) == 0 && len(d.Orphaned) == 0
+}
+
+// CompareRoutes reports routed hostnames without a certificate and
+// certificates, managed or only found in storage, for domains no route serves
+func (cm *CertificateManager) CompareRoutes(routes []Route) Drift {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	names := make(map[string][]string)
+	for domain, cert := range cm.certs {
+		names[domain] = certificateNames(domain, cert)
+	}
+	for domain, cert := range cm.unmanaged {
+		names[domain] = certificateNames(domain, cert)
+	}
+
+	routed := make(map[string]bool)
+	secured := make(map[string]bool)
+	for _, route := range routes {
+		host := strings.ToLower(route.Host)
+		routed[host] = true
+		if route.TLS {
+			secured[host] = true
+		}
+	}
+
+	drift := Drift{Uncovered: []string{}, Orphaned: []OrphanedCertificate{}}
+	for host := range secured {
+		covered := false
+		for domain, certNames := range names {
+			if _, managed := cm.certs[domain]; managed && coversHost(certNames, host) {
+				covered = true
+				break
+			}
+		}
+		if !covered {
+			drift.Uncovered = append(drift.Uncovered, host)
+		}
+	}
+	sort.Strings(drift.Uncovered)
+
+	for domain, certNames := range names {
+		inUse := false
+		for host := range routed {
+			if coversHost(certNames, host) {
+				inUse = true
+				break
+			}
+		}
+		if !inUse {
+			drift.Orphaned = append(drift.Orphaned, OrphanedCertificate{
+				Domain:     domain,
+				Configured: cm.isConfigured(domain),
+			})
+		}
+	}
+	sort.Slice(drift.Orphaned, func(i, j int) bool {
+		return drift.Orphaned[i].Domain < drift.Orphaned[j].Domain
+	})
+
+	return drift
+}
+
+// CheckDrift compares routes with the certificates and alerts operators about
+// drift not reported before
+func (cm *CertificateManager) CheckDrift(routes []Route) Drift {
+	drift := cm.CompareRoutes(routes)
+	if cm.driftMonitor != nil {
+		cm.driftMonitor.Check(drift)
+	}
+	return drift
+}
+
+// PruneOrphans deletes the orphaned certificates of drift that are neither
+// configured nor discovered, and returns the domains deleted
+func (cm *CertificateManager) PruneOrphans(drift Drift) ([]string, error) {
+	var deleted []string
+	var errs []error
+	for _, orphan := range drift.Orphaned {
+		if orphan.Configured {
+			cm.logger.Printf("Keeping orphaned certificate for %s; remove the domain from the configuration to prune it", orphan.Domain)
+			continue
+		}
+		if err := cm.DeleteCertificate(orphan.Domain); err != nil {
+			errs = append(errs, err)
+			continue
+		}
+		deleted = append(deleted, orphan.Domain)
+	}
+	return deleted, errors.Join(errs...)
+}
+
+// certificateNames returns the lowercase domain and SANs of cert
+func certificateNames(domain string, cert *Certificate) []string {
+	names := []string{strings.ToLower(domain)}
+	for _, san := range cert.SANs {
+		names = append(names, strings.ToLower(san))
+	}
+	return names
+}
+
+// coversHost reports whether one of names matches host, a wildcard matching
+// a single label
+func coversHost(names []string, host string) bool {
+	for _, name := range names {
+		if name == host {
+			return true
+		}
+		if suffix, ok := strings.CutPrefix(name, "*"); ok {
+			if label, ok := strings.CutSuffix(host, suffix); ok && label != "" && !strings.Contains(label, ".") {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// DriftMonitor alerts once about each uncovered route and orphaned certificate
+// until it is resolved
+type DriftMonitor struct {
+	notifier notify.Notifier
+	metrics  *Metrics
+	logger   *log.Logger
+	mu       sync.Mutex
+	alerted  map[string]bool // "uncovered:" and "orphaned:" entries already reported
+}
+
+func NewDriftMonitor(notifier notify.Notifier, metrics *Metrics, logger *log.Logger) *DriftMonitor {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[DriftMonitor] ", log.LstdFlags)
+	}
+
+	return &DriftMonitor{
+		notifier: notifier,
+		metrics:  metrics,
+		logger:   logger,
+		alerted:  make(map[string]bool),
+	}
+}
+
+// Check updates the drift metrics and alerts about entries of drift that
+// weren't reported by an earlier check
+func (m *DriftMonitor) Check(drift Drift) {
+	m.metrics.setDrift(drift)
+
+	current := make(map[string]bool)
+	for _, host := range drift.Uncovered {
+		current["uncovered:"+host] = true
+	}
+	for _, orphan := range drift.Orphaned {
+		current["orphaned:"+orphan.Domain] = true
+	}
+
+	m.mu.Lock()
+	var uncovered, orphaned []string
+	for _, host := range drift.Uncovered {
+		if !m.alerted["uncovered:"+host] {
+			uncovered = append(uncovered, host)
+		}
+	}
+	for _, orphan := range drift.Orphaned {
+		if !m.alerted["orphaned:"+orphan.Domain] {
+			orphaned = append(orphaned, orphan.Domain)
+		}
+	}
+	// Forget resolved entries so a regression alerts again
+	m.alerted = current
+	m.mu.Unlock()
+
+	if len(uncovered) > 0 {
+		m.alert(notify.Message{
+			Level:   notify.LevelWarning,
+			Event:   notify.EventDrift,
+			Subject: fmt.Sprintf("Traefik routes %d hostnames without a certificate", len(uncovered)),
+			Body: fmt.Sprintf("Hostnames: %s\n\nAdd them to the configuration, or check why their certificates weren't issued.",
+				strings.Join(uncovered, ", ")),
+			Key: "drift:uncovered:" + strings.Join(uncovered, ","),
+		})
+	}
+	if len(orphaned) > 0 {
+		m.alert(notify.Message{
+			Level:   notify.LevelInfo,
+			Event:   notify.EventDrift,
+			Subject: fmt.Sprintf("%d certificates are kept for domains Traefik no longer routes", len(orphaned)),
+			Body: fmt.Sprintf("Domains: %s\n\nRemove them from the configuration and run drift --prune to delete them.",
+				strings.Join(orphaned, ", ")),
+			Key: "drift:orphaned:" + strings.Join(orphaned, ","),
+		})
+	}
+}
+
+func (m *DriftMonitor) alert(msg notify.Message) {
+	m.logger.Printf("%s", msg.Subject)
+
+	if m.notifier == nil {
+		return
+	}
+	if err := m.notifier.Send(msg); err != nil {
+		m.logger.Printf("Failed to send drift alert: %v", err)
+	}
+}
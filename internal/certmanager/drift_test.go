@@ -0,0 +1,82 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_CompareRoutes(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, _ := newAdoptionTestManager(t, testDir, logger)
+	cm.certs["*.apps.example.com"] = &Certificate{Domain: "*.apps.example.com"}
+
+	drift := cm.CompareRoutes([]Route{
+		{Host: "Example.com", TLS: true},
+		{Host: "shop.apps.example.com", TLS: true},
+		{Host: "a.b.apps.example.com", TLS: true},
+		// api.example.com is configured but has no certificate yet
+		{Host: "api.example.com", TLS: true},
+		// Plain HTTP routers need no certificate
+		{Host: "plain.example.com"},
+	})
+
+	assert.Equal(t, []string{"a.b.apps.example.com", "api.example.com"}, drift.Uncovered)
+	assert.Equal(t, []OrphanedCertificate{{Domain: "legacy.example.com"}}, drift.Orphaned)
+
+	// Once Traefik stops routing example.com, its configured certificate is an orphan too
+	drift = cm.CompareRoutes([]Route{{Host: "shop.apps.example.com", TLS: true}})
+	assert.Empty(t, drift.Uncovered)
+	assert.Equal(t, []OrphanedCertificate{
+		{Domain: "example.com", Configured: true},
+		{Domain: "legacy.example.com"},
+	}, drift.Orphaned)
+}
+
+func TestCertificateManager_PruneOrphans(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, _ := newAdoptionTestManager(t, testDir, logger)
+
+	drift := cm.CompareRoutes(nil)
+	deleted, err := cm.PruneOrphans(drift)
+	require.NoError(t, err)
+
+	// Configured domains are kept until removed from the configuration
+	assert.Equal(t, []string{"legacy.example.com"}, deleted)
+	assert.Empty(t, cm.UnmanagedCertificates())
+	assert.Contains(t, cm.ListCertificates(), "example.com")
+	assert.NoFileExists(t, filepath.Join(testDir, "legacy.example.com.crt"))
+	assert.FileExists(t, filepath.Join(testDir, "example.com.crt"))
+}
+
+func TestDriftMonitor_AlertsOnce(t *testing.T) {
+	notifier := &recordingNotifier{}
+	monitor := NewDriftMonitor(notifier, nil, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+
+	drift := Drift{
+		Uncovered: []string{"shop.example.com"},
+		Orphaned:  []OrphanedCertificate{{Domain: "old.example.com"}},
+	}
+	monitor.Check(drift)
+	require.Len(t, notifier.messages, 2)
+	assert.Contains(t, notifier.messages[0].Body, "shop.example.com")
+	assert.Contains(t, notifier.messages[1].Body, "old.example.com")
+
+	// Known drift isn't reported again
+	monitor.Check(drift)
+	assert.Len(t, notifier.messages, 2)
+
+	// Drift that was resolved and comes back is
+	monitor.Check(Drift{})
+	monitor.Check(Drift{Uncovered: []string{"shop.example.com"}})
+	require.Len(t, notifier.messages, 3)
+	assert.Contains(t, notifier.messages[2].Subject, "1 hostnames without a certificate")
+}
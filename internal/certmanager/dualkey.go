@@ -0,0 +1,284 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+	"gopkg.in/yaml.v2"
+)
+
+const (
+	// dualKeyDir holds the second certificate of dual-key domains, outside the
+	// storage root so it isn't mistaken for a domain of its own
+	dualKeyDir = "dualkey"
+	// dualKeyFileName is a Traefik dynamic configuration listing both
+	// certificates of every dual-key domain
+	dualKeyFileName = "dual-key-certificates.yml"
+)
+
+// companionIssuer is implemented by ACME clients that can order the second
+// certificate of a dual-key domain
+type companionIssuer interface {
+	RequestCompanion(ctx context.Context, domain, keyType string) (*Certificate, error)
+}
+
+// companionKeyType returns the key type of the certificate kept alongside one
+// of keyType: ECDSA next to RSA, RSA next to ECDSA
+func companionKeyType(keyType string) string {
+	if strings.HasPrefix(keyType, "EC") {
+		return "RSA2048"
+	}
+	return "EC256"
+}
+
+// companionPath returns the path of a companion file, ext being .crt, .key or .issuer.crt
+func companionPath(storagePath, domain, ext string) string {
+	return filepath.Join(storagePath, dualKeyDir, storageName(domain)+ext)
+}
+
+// RequestCompanion orders a certificate for domain with a key of keyType and
+// stores it in the dual-key directory, leaving the domain's main certificate alone
+func (c *ACMEClient) RequestCompanion(ctx context.Context, domain, keyType string) (*Certificate, error) {
+	ctx, cancel := c.orderContext(ctx)
+	defer cancel()
+	c, end, err := c.begin(ctx, domain)
+	if err != nil {
+		return nil, fmt.Errorf("failed to start issuance for %s: %w", domain, err)
+	}
+	defer end()
+
+	c.logger.Printf("Requesting %s certificate for domain: %s", keyType, domain)
+	defer c.wire.track(domain)()
+
+	key, err := certcrypto.GeneratePrivateKey(getKeyType(keyType))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	profile := c.profile(domain)
+	if err := c.checkProfile(profile); err != nil {
+		return nil, err
+	}
+
+	request := certificate.ObtainRequest{
+		Domains:        []string{domain},
+		Bundle:         true,
+		PrivateKey:     key,
+		Profile:        profile,
+		PreferredChain: c.chain,
+	}
+
+	var resource *certificate.Resource
+	err = c.withRetry(ctx, "issuance", domain, func() error {
+		if err := c.ensureRegistered(); err != nil {
+			return fmt.Errorf("failed to register: %w", err)
+		}
+		var err error
+		resource, err = c.obtain(request)
+		return err
+	})
+	// The order was journaled under the domain like any other; it isn't resumed,
+	// as the key isn't kept until it completes
+	if removeErr := c.orders.Remove(domain); removeErr != nil {
+		c.logger.Printf("Warning: failed to update order journal for %s: %v", domain, removeErr)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to obtain %s certificate: %w", keyType, err)
+	}
+
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: resource.Certificate,
+		PrivateKey:  resource.PrivateKey,
+		IssuerCert:  resource.IssuerCertificate,
+		URL:         resource.CertURL,
+		IssuedAt:    time.Now(),
+	}
+	if err := cert.parseCertificate(); err != nil {
+		c.logger.Printf("Warning: failed to parse certificate: %v", err)
+	}
+
+	// Stored even when the operation was cancelled, as the order can't be taken back
+	if err := c.saveCompanion(context.WithoutCancel(ctx), cert); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// saveCompanion stores the second certificate of a dual-key domain
+func (c *ACMEClient) saveCompanion(ctx context.Context, cert *Certificate) error {
+	if err := os.MkdirAll(filepath.Join(c.storagePath, dualKeyDir), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	keyData := cert.PrivateKey
+	if c.encryption != nil {
+		var err error
+		if keyData, err = c.sealPrivateKey(ctx, keyData); err != nil {
+			return fmt.Errorf("failed to encrypt private key: %w", err)
+		}
+	}
+	if err := os.WriteFile(companionPath(c.storagePath, cert.Domain, ".crt"), cert.Certificate, 0644); err != nil {
+		return fmt.Errorf("failed to save certificate file: %w", err)
+	}
+	if err := os.WriteFile(companionPath(c.storagePath, cert.Domain, ".key"), keyData, 0600); err != nil {
+		return fmt.Errorf("failed to save private key file: %w", err)
+	}
+	if cert.IssuerCert != nil {
+		if err := os.WriteFile(companionPath(c.storagePath, cert.Domain, ".issuer.crt"), cert.IssuerCert, 0644); err != nil {
+			c.logger.Printf("Warning: failed to save issuer certificate: %v", err)
+		}
+	}
+	return nil
+}
+
+// loadCompanion reads the stored second certificate of a dual-key domain,
+// without its key
+func (cm *CertificateManager) loadCompanion(domain string) (*Certificate, error) {
+	data, err := os.ReadFile(companionPath(cm.config.Certificates.StoragePath, domain, ".crt"))
+	if err != nil {
+		return nil, err
+	}
+	cert := &Certificate{Domain: domain, Certificate: data}
+	if err := cert.parseCertificate(); err != nil {
+		return nil, err
+	}
+	return cert, nil
+}
+
+// renewCompanion orders the second certificate of a dual-key domain. It is
+// called after the main certificate changed, so both are replaced together.
+func (cm *CertificateManager) renewCompanion(ctx context.Context, domain string) error {
+	issuer, ok := cm.acmeClient.(companionIssuer)
+	if !ok || !cm.config.DualKeyFor(domain) {
+		return nil
+	}
+
+	if err := cm.checkMaintenance(); err != nil {
+		return err
+	}
+	if err := cm.checkHold(domain); err != nil {
+		return err
+	}
+	if err := cm.ensureStorageCapacity(); err != nil {
+		return err
+	}
+
+	release, err := cm.lockDomain(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	keyType := companionKeyType(cm.config.KeyTypeFor(domain))
+	cert, err := issuer.RequestCompanion(ctx, domain, keyType)
+	cm.recordOrder(domain, err)
+	if err != nil {
+		return err
+	}
+	cm.logger.Printf("Obtained %s certificate for %s (expires: %s)", keyType, domain, cert.ExpiresAt.Format(time.RFC3339))
+
+	if err := cm.writeDualKeyConfig(); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+	return nil
+}
+
+// afterIssue keeps the second certificate of a dual-key domain in step with a
+// newly issued main certificate. Failures are reported, not returned, as the
+// main certificate is in place.
+func (cm *CertificateManager) afterIssue(ctx context.Context, domain string) {
+	if err := cm.renewCompanion(ctx, domain); err != nil {
+		cm.logger.Printf("Failed to renew dual-key certificate for %s: %v", domain, err)
+		cm.recordFailure(domain, "companion", err)
+		cm.notifyFailure(domain, "obtain the "+companionKeyType(cm.config.KeyTypeFor(domain)), err)
+	}
+}
+
+// RenewCompanions orders the second certificate of dual-key domains where it
+// is missing, e.g. after dual_key was enabled, or inside its renewal window
+func (cm *CertificateManager) RenewCompanions(ctx context.Context) {
+	if _, ok := cm.acmeClient.(companionIssuer); !ok {
+		return
+	}
+
+	cm.mu.RLock()
+	var domains []string
+	for domain := range cm.certs {
+		if cm.config.DualKeyFor(domain) {
+			domains = append(domains, domain)
+		}
+	}
+	cm.mu.RUnlock()
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		if _, held := cm.holds.Get(domain); held {
+			continue
+		}
+		if companion, err := cm.loadCompanion(domain); err == nil && !cm.needsRenewal(domain, companion) {
+			continue
+		}
+		cm.afterIssue(ctx, domain)
+	}
+}
+
+// writeDualKeyConfig publishes both certificates of every dual-key domain to
+// Traefik's file provider, which picks one per client, and removes a stale file otherwise
+func (cm *CertificateManager) writeDualKeyConfig() error {
+	storagePath, err := filepath.Abs(cm.config.Certificates.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve storage path: %w", err)
+	}
+	path := filepath.Join(storagePath, dualKeyFileName)
+
+	cm.mu.RLock()
+	var domains []string
+	for domain := range cm.certs {
+		if cm.config.DualKeyFor(domain) {
+			domains = append(domains, domain)
+		}
+	}
+	cm.mu.RUnlock()
+	sort.Strings(domains)
+
+	var dynamic TraefikDynamicTLS
+	for _, domain := range domains {
+		if _, err := os.Stat(companionPath(storagePath, domain, ".crt")); err != nil {
+			continue
+		}
+		dynamic.TLS.Certificates = append(dynamic.TLS.Certificates,
+			TraefikCertificate{
+				CertFile: filepath.Join(storagePath, storageName(domain)+".crt"),
+				KeyFile:  filepath.Join(storagePath, storageName(domain)+".key"),
+			},
+			TraefikCertificate{
+				CertFile: companionPath(storagePath, domain, ".crt"),
+				KeyFile:  companionPath(storagePath, domain, ".key"),
+			})
+	}
+
+	if len(dynamic.TLS.Certificates) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale dual-key configuration: %w", err)
+		}
+		return nil
+	}
+
+	data, err := yaml.Marshal(dynamic)
+	if err != nil {
+		return fmt.Errorf("failed to encode dual-key configuration: %w", err)
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write dual-key configuration: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,75 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+// companionMock orders companions by writing a test certificate to the dual-key directory
+type companionMock struct {
+	*MockACMEClient
+	requested []string
+}
+
+func (m *companionMock) RequestCompanion(ctx context.Context, domain, keyType string) (*Certificate, error) {
+	m.requested = append(m.requested, domain+" "+keyType)
+	cert := createTestCertificate(domain, 90)
+	if err := os.MkdirAll(filepath.Join(m.storagePath, dualKeyDir), 0755); err != nil {
+		return nil, err
+	}
+	return cert, os.WriteFile(companionPath(m.storagePath, domain, ".crt"), cert.Certificate, 0644)
+}
+
+func TestCompanionKeyType(t *testing.T) {
+	assert.Equal(t, "EC256", companionKeyType("RSA2048"))
+	assert.Equal(t, "EC256", companionKeyType("RSA4096"))
+	assert.Equal(t, "RSA2048", companionKeyType("EC256"))
+	assert.Equal(t, "RSA2048", companionKeyType("EC384"))
+}
+
+func TestCertificateManager_RenewCompanions(t *testing.T) {
+	tempDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = tempDir
+	cfg.Domains[0].DualKey = true
+
+	client := &companionMock{MockACMEClient: NewMockACMEClient(tempDir, nil)}
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: client,
+		logger:     log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		certs: map[string]*Certificate{
+			"example.com":     createTestCertificate("example.com", 60),
+			"api.example.com": createTestCertificate("api.example.com", 60),
+		},
+	}
+
+	// Only the dual-key domain gets a companion, with the other algorithm
+	cm.RenewCompanions(context.Background())
+	assert.Equal(t, []string{"example.com EC256"}, client.requested)
+
+	// A valid companion isn't ordered again
+	cm.RenewCompanions(context.Background())
+	assert.Len(t, client.requested, 1)
+
+	data, err := os.ReadFile(filepath.Join(tempDir, dualKeyFileName))
+	require.NoError(t, err)
+	var dynamic TraefikDynamicTLS
+	require.NoError(t, yaml.Unmarshal(data, &dynamic))
+	require.Len(t, dynamic.TLS.Certificates, 2)
+	assert.Equal(t, filepath.Join(tempDir, "example.com.crt"), dynamic.TLS.Certificates[0].CertFile)
+	assert.Equal(t, filepath.Join(tempDir, dualKeyDir, "example.com.key"), dynamic.TLS.Certificates[1].KeyFile)
+
+	// Turning dual_key off removes the configuration
+	cfg.Domains[0].DualKey = false
+	require.NoError(t, cm.writeDualKeyConfig())
+	_, err = os.Stat(filepath.Join(tempDir, dualKeyFileName))
+	assert.True(t, os.IsNotExist(err))
+}
@@ -0,0 +1,109 @@
+package certmanager
+
+import (
+	"errors"
+
+	"github.com/O-tero/traefik-cert-manager/internal/traefik"
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// Classes of failures. Errors of a class match its sentinel with errors.Is
+// wherever they are wrapped, so callers branch on the class rather than the
+// message.
+var (
+	// ErrRateLimited matches orders refused by a rate limit of the CA, or
+	// locally to stay under one
+	ErrRateLimited = errors.New("rate limited")
+	// ErrChallengeFailed matches orders whose challenge the CA couldn't validate
+	ErrChallengeFailed = errors.New("challenge failed")
+	// ErrStorage matches failures to write certificates to the storage path
+	ErrStorage = errors.New("storage failure")
+	// ErrTraefikUnreachable matches requests that didn't reach the Traefik API
+	ErrTraefikUnreachable = traefik.ErrUnreachable
+)
+
+// Error codes name the class of a failure in the management API, run
+// summaries and status reports
+const (
+	CodeRateLimited        = "rate_limited"
+	CodeChallengeFailed    = "challenge_failed"
+	CodeStorage            = "storage"
+	CodeTraefikUnreachable = "traefik_unreachable"
+	CodeMaintenance        = "maintenance"
+	CodeLocked             = "locked"
+	CodeNotFound           = "not_found"
+)
+
+// challengeProblems are the ACME problem types of failed challenge validations
+var challengeProblems = map[string]bool{
+	"urn:ietf:params:acme:error:unauthorized":      true,
+	"urn:ietf:params:acme:error:connection":        true,
+	"urn:ietf:params:acme:error:dns":               true,
+	"urn:ietf:params:acme:error:incorrectResponse": true,
+	"urn:ietf:params:acme:error:caa":               true,
+	"urn:ietf:params:acme:error:tls":               true,
+}
+
+// ErrorCode returns the code of the class err belongs to, or "" when it
+// belongs to none
+func ErrorCode(err error) string {
+	switch {
+	case err == nil:
+		return ""
+	case errors.Is(err, ErrRateLimited):
+		return CodeRateLimited
+	case errors.Is(err, ErrChallengeFailed):
+		return CodeChallengeFailed
+	case errors.Is(err, ErrStorage):
+		return CodeStorage
+	case errors.Is(err, ErrTraefikUnreachable):
+		return CodeTraefikUnreachable
+	case errors.Is(err, ErrMaintenance):
+		return CodeMaintenance
+	case errors.Is(err, ErrDomainLocked):
+		return CodeLocked
+	case errors.Is(err, ErrCertificateNotFound):
+		return CodeNotFound
+	default:
+		return ""
+	}
+}
+
+// classifiedError puts an error in a class without changing its message
+type classifiedError struct {
+	class error
+	err   error
+}
+
+func (e *classifiedError) Error() string {
+	return e.err.Error()
+}
+
+func (e *classifiedError) Unwrap() []error {
+	return []error{e.class, e.err}
+}
+
+// classify puts err in class, keeping nil errors nil
+func classify(class, err error) error {
+	if err == nil {
+		return nil
+	}
+	return &classifiedError{class: class, err: err}
+}
+
+// classifyACMEError puts err in the class of the problem the CA reported, if
+// it reported one
+func classifyACMEError(err error) error {
+	var problem *acme.ProblemDetails
+	if !errors.As(err, &problem) {
+		return err
+	}
+	switch {
+	case problem.Type == rateLimitedProblem:
+		return classify(ErrRateLimited, err)
+	case challengeProblems[problem.Type]:
+		return classify(ErrChallengeFailed, err)
+	default:
+		return err
+	}
+}
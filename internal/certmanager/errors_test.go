@@ -0,0 +1,39 @@
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/traefik"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestClassifyACMEError(t *testing.T) {
+	rateLimited := fmt.Errorf("failed to obtain certificate: %w",
+		&acme.ProblemDetails{Type: rateLimitedProblem, Detail: "too many new orders recently"})
+	err := classifyACMEError(rateLimited)
+	assert.ErrorIs(t, err, ErrRateLimited)
+	assert.Equal(t, rateLimited.Error(), err.Error())
+	assert.Equal(t, CodeRateLimited, ErrorCode(fmt.Errorf("gave up after 3 attempts: %w", err)))
+
+	unauthorized := &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:unauthorized", Detail: "invalid key authorization"}
+	err = classifyACMEError(fmt.Errorf("invalid authorization: %w", unauthorized))
+	assert.ErrorIs(t, err, ErrChallengeFailed)
+	assert.Equal(t, CodeChallengeFailed, ErrorCode(err))
+
+	malformed := fmt.Errorf("acme: %w", &acme.ProblemDetails{Type: "urn:ietf:params:acme:error:malformed"})
+	assert.Equal(t, "", ErrorCode(classifyACMEError(malformed)))
+	assert.Equal(t, "", ErrorCode(classifyACMEError(errors.New("connection reset"))))
+}
+
+func TestErrorCode(t *testing.T) {
+	assert.Equal(t, "", ErrorCode(nil))
+	assert.Equal(t, CodeRateLimited, ErrorCode(&DuplicateLimitError{Issued: 5, Limit: 5}))
+	assert.Equal(t, CodeStorage, ErrorCode(classify(ErrStorage, fmt.Errorf("%w: 1 MB free", ErrInsufficientStorage))))
+	assert.Equal(t, CodeTraefikUnreachable, ErrorCode(fmt.Errorf("failed to get routers: %w", traefik.ErrUnreachable)))
+	assert.Equal(t, CodeMaintenance, ErrorCode(fmt.Errorf("renewal skipped: %w", ErrMaintenance)))
+	assert.Equal(t, CodeLocked, ErrorCode(fmt.Errorf("%w for example.com", ErrDomainLocked)))
+	assert.Equal(t, CodeNotFound, ErrorCode(fmt.Errorf("%w: example.com", ErrCertificateNotFound)))
+}
@@ -0,0 +1,130 @@
+package certmanager
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+// expiryLevel returns the level an expiring certificate is reported at under
+// the escalation tiers, false while it is outside them. Certificates whose
+// renewal window is shorter than the warning tier, such as ones living hours,
+// would always be inside the tiers; they are reported once renewal falls
+// behind, at a half, a quarter and an eighth of the window remaining.
+func (cm *CertificateManager) expiryLevel(domain string, status CertificateHealth) (notify.Level, bool) {
+	escalation := cm.config.Notification.Escalation
+	cert := &Certificate{IssuedAt: status.IssuedAt, ExpiresAt: status.ExpiresAt}
+	if window, short := cm.policy().window(domain, cert); short && window < time.Duration(escalation.WarningDays)*24*time.Hour {
+		remaining := time.Until(status.ExpiresAt)
+		switch {
+		case remaining < window/8:
+			return notify.LevelPage, true
+		case remaining < window/4:
+			return notify.LevelCritical, true
+		case remaining < window/2:
+			return notify.LevelWarning, true
+		}
+		return "", false
+	}
+
+	daysUntilExpiry := status.DaysUntilExpiry
+	switch {
+	case daysUntilExpiry <= escalation.PageDays:
+		return notify.LevelPage, true
+	case daysUntilExpiry <= escalation.CriticalDays:
+		return notify.LevelCritical, true
+	case daysUntilExpiry <= escalation.WarningDays:
+		return notify.LevelWarning, true
+	}
+	return "", false
+}
+
+// NotifyExpiring reports every certificate inside the escalation tiers. The
+// notifier drops repeats within the dedup window, so this runs every cycle and
+// operators hear about a certificate again only when it reaches the next tier.
+func (cm *CertificateManager) NotifyExpiring() {
+	if cm.notifier == nil || cm.config.Notification.Escalation.WarningDays <= 0 {
+		return
+	}
+
+	health := cm.CheckCertificateHealth()
+	domains := make([]string, 0, len(health))
+	for domain := range health {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		status := health[domain]
+		level, ok := cm.expiryLevel(domain, status)
+		if !ok {
+			continue
+		}
+
+		msg := notify.Message{
+			Level:   level,
+			Event:   notify.EventExpiring,
+			Domain:  domain,
+			Key:     "expiry:" + domain,
+			Subject: fmt.Sprintf("Certificate for %s expires in %s", domain, expiresIn(status.ExpiresAt)),
+			Body: fmt.Sprintf("Expires: %s\nRenewal scheduled for: %s\n\n"+
+				"The certificate has not been renewed yet. Check the renewal log for errors.",
+				status.ExpiresAt.Format(time.RFC3339), status.RenewAt.Format(time.RFC3339)),
+		}
+		if status.IsExpired {
+			msg.Event = notify.EventExpired
+			msg.Subject = fmt.Sprintf("Certificate for %s has expired", domain)
+		}
+		if level == notify.LevelPage {
+			msg.Recipients = append(append([]string{}, cm.config.RecipientsFor(domain)...),
+				cm.config.Notification.Escalation.PageRecipients...)
+		}
+
+		if err := cm.notifier.Send(msg); err != nil {
+			cm.logger.Printf("Failed to send expiry notice for %s: %v", domain, err)
+		}
+	}
+}
+
+// expiresIn describes the remaining lifetime of a certificate in days, or in
+// hours and minutes once less than two days remain
+func expiresIn(expiresAt time.Time) string {
+	remaining := time.Until(expiresAt)
+	if remaining >= 48*time.Hour {
+		return fmt.Sprintf("%d days", int(math.Round(remaining.Hours()/24)))
+	}
+	remaining = remaining.Truncate(time.Minute)
+	return fmt.Sprintf("%dh%02dm", int(remaining.Hours()), int(remaining.Minutes())%60)
+}
+
+// notifyFailure reports a failed order for a domain
+func (cm *CertificateManager) notifyFailure(domain, action string, err error) {
+	if cm.notifier == nil {
+		return
+	}
+
+	msg := notify.Message{
+		Level:   notify.LevelCritical,
+		Event:   notify.EventFailure,
+		Domain:  domain,
+		Key:     "failure:" + domain,
+		Subject: fmt.Sprintf("Failed to %s certificate for %s", action, domain),
+		Body:    fmt.Sprintf("Error: %v\n\nThe order is retried on the next check.", err),
+	}
+	if sendErr := cm.notifier.Send(msg); sendErr != nil {
+		cm.logger.Printf("Failed to send failure notice for %s: %v", domain, sendErr)
+	}
+}
+
+// FlushNotifications sends the notification digest if one is due
+func (cm *CertificateManager) FlushNotifications() {
+	if cm.throttle == nil {
+		return
+	}
+	if err := cm.throttle.Flush(); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,91 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_NotifyExpiring(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.Domains = []config.Domain{
+		{Service: "a", Domain: "a.example.com"},
+		{Service: "b", Domain: "b.example.com"},
+		{Service: "c", Domain: "c.example.com"},
+		{Service: "d", Domain: "d.example.com"},
+	}
+	cfg.Notification.Escalation = config.Escalation{
+		WarningDays: 14, CriticalDays: 3, PageDays: 1, PageRecipients: []string{"pager@example.com"},
+	}
+
+	sent := &sentMessages{}
+	cm := &CertificateManager{
+		config:   cfg,
+		notifier: sent,
+		logger:   log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		certs: map[string]*Certificate{
+			"a.example.com": createTestCertificate("a.example.com", 60),
+			"b.example.com": createTestCertificate("b.example.com", 10),
+			"c.example.com": createTestCertificate("c.example.com", 2),
+			"d.example.com": createTestCertificate("d.example.com", -1),
+		},
+	}
+
+	cm.NotifyExpiring()
+
+	require.Len(t, sent.messages, 3)
+	levels := map[string]notify.Level{}
+	for _, msg := range sent.messages {
+		levels[msg.Domain] = msg.Level
+	}
+	assert.Equal(t, map[string]notify.Level{
+		"b.example.com": notify.LevelWarning,
+		"c.example.com": notify.LevelCritical,
+		"d.example.com": notify.LevelPage,
+	}, levels)
+
+	page := sent.messages[2]
+	assert.Equal(t, "Certificate for d.example.com has expired", page.Subject)
+	assert.Equal(t, []string{cfg.Email, "pager@example.com"}, page.Recipients)
+}
+
+func TestCertificateManager_NotifyExpiringShortLived(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.Domains = []config.Domain{
+		{Service: "a", Domain: "a.example.com", RenewBefore: "8h"},
+		{Service: "b", Domain: "b.example.com", RenewBefore: "8h"},
+		{Service: "c", Domain: "c.example.com", RenewBefore: "8h"},
+	}
+	cfg.Notification.Escalation = config.Escalation{WarningDays: 14, CriticalDays: 3, PageDays: 1}
+
+	expiringIn := func(domain string, remaining time.Duration) *Certificate {
+		cert := createTestCertificate(domain, 1)
+		cert.ExpiresAt = time.Now().Add(remaining)
+		return cert
+	}
+	sent := &sentMessages{}
+	cm := &CertificateManager{
+		config:   cfg,
+		notifier: sent,
+		logger:   log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		certs: map[string]*Certificate{
+			// Not yet renewed, but renewal hasn't fallen behind
+			"a.example.com": expiringIn("a.example.com", 6*time.Hour),
+			"b.example.com": expiringIn("b.example.com", 3*time.Hour),
+			"c.example.com": expiringIn("c.example.com", 30*time.Minute),
+		},
+	}
+
+	cm.NotifyExpiring()
+
+	require.Len(t, sent.messages, 2)
+	assert.Equal(t, notify.LevelWarning, sent.messages[0].Level)
+	assert.Equal(t, "Certificate for b.example.com expires in 2h59m", sent.messages[0].Subject)
+	assert.Equal(t, notify.LevelPage, sent.messages[1].Level)
+}
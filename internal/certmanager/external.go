@@ -0,0 +1,139 @@
+package certmanager
+
+import (
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"slices"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+// A domain can bring its own key material instead of a key generated for each
+// order: a private key file that every order uses, or a CSR whose private key
+// never leaves an HSM or another custodian. Certificates ordered for a CSR are
+// stored without a private key.
+
+func (c *ACMEClient) externalKey(domain string) (keyFile, csrFile string) {
+	if c.externalFor == nil {
+		return "", ""
+	}
+	return c.externalFor(domain)
+}
+
+// loadExternalKey reads the private key a domain is ordered with
+func loadExternalKey(path string) (crypto.PrivateKey, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read key file: %w", err)
+	}
+	key, err := certcrypto.ParsePEMPrivateKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse key file %s: %w", path, err)
+	}
+	return key, nil
+}
+
+// loadExternalCSR reads the CSR of a domain, which must be validly signed and
+// name the domain
+func loadExternalCSR(path, domain string) (*x509.CertificateRequest, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read CSR file: %w", err)
+	}
+
+	der := data
+	if block, _ := pem.Decode(data); block != nil {
+		der = block.Bytes
+	}
+	csr, err := x509.ParseCertificateRequest(der)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse CSR file %s: %w", path, err)
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("CSR file %s has an invalid signature: %w", path, err)
+	}
+	if !slices.Contains(certcrypto.ExtractDomainsCSR(csr), domain) {
+		return nil, fmt.Errorf("CSR file %s does not name %s", path, domain)
+	}
+	return csr, nil
+}
+
+// orderForCSR orders a certificate for the external CSR of domain, resuming an
+// interrupted order first, and stores it without a private key
+func (c *ACMEClient) orderForCSR(ctx context.Context, domain, csrFile, operation string) (*Certificate, error) {
+	csr, err := loadExternalCSR(csrFile, domain)
+	if err != nil {
+		return nil, err
+	}
+
+	profile := c.profile(domain)
+	if err := c.checkProfile(profile); err != nil {
+		return nil, err
+	}
+
+	var resource *certificate.Resource
+	err = c.withRetry(ctx, operation, domain, func() error {
+		if err := c.ensureRegistered(); err != nil {
+			return fmt.Errorf("failed to register: %w", err)
+		}
+
+		var err error
+		if resource, err = c.resumeOrder(domain, nil); err != nil || resource != nil {
+			return err
+		}
+		resource, err = c.client.Certificate.ObtainForCSR(certificate.ObtainForCSRRequest{
+			CSR:            csr,
+			Bundle:         true,
+			Profile:        profile,
+			PreferredChain: c.chain,
+		})
+		return err
+	})
+	if err != nil {
+		if !isTransientACMEError(err) && ctx.Err() == nil {
+			c.finishOrder(domain)
+		}
+		c.logger.Printf("Failed to obtain certificate for %s from its CSR: %v", domain, err)
+		return nil, fmt.Errorf("failed to obtain certificate: %w", err)
+	}
+
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: resource.Certificate,
+		IssuerCert:  resource.IssuerCertificate,
+		URL:         resource.CertURL,
+		IssuedAt:    time.Now(),
+		CA:          c.caDirURL,
+		OrderURL:    c.orderURL(domain),
+	}
+	if err := cert.parseCertificate(); err != nil {
+		c.logger.Printf("Warning: failed to parse certificate: %v", err)
+	}
+
+	if err := c.checkSwitch(ctx, cert); err != nil {
+		return nil, err
+	}
+	if err := c.saveCertificateTraced(ctx, cert); err != nil {
+		return nil, fmt.Errorf("failed to save certificate: %w", err)
+	}
+	c.finishOrder(domain)
+
+	c.logger.Printf("Certificate for the CSR of %s saved successfully", domain)
+	return cert, nil
+}
+
+// certMatchesCSR reports whether the leaf of bundle certifies the key of csr
+func certMatchesCSR(bundle []byte, csr *x509.CertificateRequest) bool {
+	certs, err := certcrypto.ParsePEMBundle(bundle)
+	if err != nil {
+		return false
+	}
+	pub, ok := certs[0].PublicKey.(interface{ Equal(crypto.PublicKey) bool })
+	return ok && pub.Equal(csr.PublicKey)
+}
@@ -0,0 +1,73 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// writeCSR writes a PEM CSR for names signed by the key of cert and returns its path
+func writeCSR(t *testing.T, dir string, cert *Certificate, names ...string) string {
+	t.Helper()
+	key, err := certcrypto.ParsePEMPrivateKey(cert.PrivateKey)
+	require.NoError(t, err)
+
+	der, err := x509.CreateCertificateRequest(rand.Reader, &x509.CertificateRequest{
+		Subject:  pkix.Name{CommonName: names[0]},
+		DNSNames: names,
+	}, key)
+	require.NoError(t, err)
+
+	path := filepath.Join(dir, names[0]+".csr")
+	require.NoError(t, os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE REQUEST", Bytes: der}), 0644))
+	return path
+}
+
+func TestLoadExternalCSR(t *testing.T) {
+	testDir := setupTestDir(t)
+	cert := createTestCertificate("example.com", 90)
+	path := writeCSR(t, testDir, cert, "example.com", "www.example.com")
+
+	csr, err := loadExternalCSR(path, "www.example.com")
+	require.NoError(t, err)
+	assert.True(t, certMatchesCSR(cert.Certificate, csr))
+	assert.False(t, certMatchesCSR(createTestCertificate("example.com", 90).Certificate, csr))
+
+	_, err = loadExternalCSR(path, "other.example.com")
+	assert.ErrorContains(t, err, "does not name other.example.com")
+}
+
+func TestACMEClient_StoresCSRCertificatesWithoutKey(t *testing.T) {
+	testDir := setupTestDir(t)
+	client := &ACMEClient{
+		storagePath: testDir,
+		logger:      log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+
+	// A key from before the domain switched to an external CSR is removed
+	require.NoError(t, client.saveCertificate(context.Background(), createTestCertificate("example.com", 90)))
+	cert := createTestCertificate("example.com", 90)
+	cert.PrivateKey = nil
+	require.NoError(t, client.saveCertificate(context.Background(), cert))
+	_, err := os.Stat(filepath.Join(testDir, "example.com.key"))
+	assert.True(t, os.IsNotExist(err))
+
+	_, err = client.LoadCertificate(context.Background(), "example.com")
+	assert.ErrorContains(t, err, "private key file not found")
+
+	client.externalFor = func(domain string) (string, string) { return "", "/etc/hsm/example.com.csr" }
+	loaded, err := client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Empty(t, loaded.PrivateKey)
+	assert.Equal(t, "example.com", loaded.Domain)
+}
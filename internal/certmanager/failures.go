@@ -0,0 +1,64 @@
+package certmanager
+
+import (
+	"sync"
+	"time"
+)
+
+// Failure is an order or deployment that failed. Some of them, like failed
+// deployments, don't fail the run they happen in, so they are collected for
+// strict mode, which fails a run on any of them.
+type Failure struct {
+	Domain    string    `json:"domain" yaml:"domain"`
+	Operation string    `json:"operation" yaml:"operation"` // obtain, renew, reissue, companion, deploy, pre_switch or post_switch
+	Error     string    `json:"error" yaml:"error"`
+	Code      string    `json:"code,omitempty" yaml:"code,omitempty"` // class of the error, see ErrorCode
+	Time      time.Time `json:"time" yaml:"time"`
+}
+
+// failureLog collects failures, and the domains issued certificates, until
+// the end of the run
+type failureLog struct {
+	mu       sync.Mutex
+	failures []Failure
+	issued   []string
+}
+
+// recordFailure adds a failure to the current run
+func (cm *CertificateManager) recordFailure(domain, operation string, err error) {
+	cm.failures.mu.Lock()
+	defer cm.failures.mu.Unlock()
+	cm.failures.failures = append(cm.failures.failures, Failure{
+		Domain:    domain,
+		Operation: operation,
+		Error:     err.Error(),
+		Code:      ErrorCode(err),
+		Time:      time.Now().UTC(),
+	})
+}
+
+// TakeFailures returns the failures recorded since the last call, oldest first
+func (cm *CertificateManager) TakeFailures() []Failure {
+	cm.failures.mu.Lock()
+	defer cm.failures.mu.Unlock()
+	failures := cm.failures.failures
+	cm.failures.failures = nil
+	return failures
+}
+
+// recordIssued adds a domain issued or renewed a certificate to the current run
+func (cm *CertificateManager) recordIssued(domain string) {
+	cm.failures.mu.Lock()
+	defer cm.failures.mu.Unlock()
+	cm.failures.issued = append(cm.failures.issued, domain)
+}
+
+// TakeIssued returns the domains issued or renewed certificates since the
+// last call, oldest first
+func (cm *CertificateManager) TakeIssued() []string {
+	cm.failures.mu.Lock()
+	defer cm.failures.mu.Unlock()
+	issued := cm.failures.issued
+	cm.failures.issued = nil
+	return issued
+}
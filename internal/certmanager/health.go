@@ -0,0 +1,127 @@
+package certmanager
+
+import (
+	"sort"
+)
+
+// ServiceHealth is the logical certificate of a service: the certificate for its
+// primary domain together with the certificates issued for its aliases
+type ServiceHealth struct {
+	Service string              `json:"service"`
+	Domain  string              `json:"domain"`
+	Group   string              `json:"group,omitempty"`
+	Status  string              `json:"status"` // worst status of the primary and its aliases
+	Primary *CertificateHealth  `json:"primary,omitempty"`
+	Aliases []CertificateHealth `json:"aliases,omitempty"`
+}
+
+// domainRole describes where a domain sits within its service's configuration
+type domainRole struct {
+	service string
+	primary string
+}
+
+// domainRoles maps every configured and discovered domain and alias to its
+// service and primary domain. Callers must hold cm.mu.
+func (cm *CertificateManager) domainRoles() map[string]domainRole {
+	roles := make(map[string]domainRole)
+
+	add := func(service, primary string, aliases []string) {
+		if _, exists := roles[primary]; !exists {
+			roles[primary] = domainRole{service: service, primary: primary}
+		}
+		for _, alias := range aliases {
+			if _, exists := roles[alias]; !exists {
+				roles[alias] = domainRole{service: service, primary: primary}
+			}
+		}
+	}
+
+	for _, d := range cm.config.Domains {
+		add(d.Service, d.Domain, d.Aliases)
+	}
+	for _, sourceDomains := range cm.discovered {
+		for _, d := range sourceDomains {
+			add(d.Service, d.Domain, d.Aliases)
+		}
+	}
+
+	return roles
+}
+
+// CheckServiceHealth groups certificate health by primary domain so each service
+// is reported as one logical certificate with its aliases beneath it
+func (cm *CertificateManager) CheckServiceHealth() []ServiceHealth {
+	health := cm.CheckCertificateHealth()
+
+	groups := make(map[string]*ServiceHealth)
+	group := func(primary, service string) *ServiceHealth {
+		g, exists := groups[primary]
+		if !exists {
+			g = &ServiceHealth{Service: service, Domain: primary, Group: cm.config.GroupFor(primary)}
+			groups[primary] = g
+		}
+		return g
+	}
+
+	for domain, status := range health {
+		status := status
+		if status.PrimaryDomain == "" {
+			g := group(domain, status.Service)
+			g.Primary = &status
+			continue
+		}
+
+		g := group(status.PrimaryDomain, status.Service)
+		g.Aliases = append(g.Aliases, status)
+	}
+
+	result := make([]ServiceHealth, 0, len(groups))
+	for _, g := range groups {
+		sort.Slice(g.Aliases, func(i, j int) bool {
+			return g.Aliases[i].Domain < g.Aliases[j].Domain
+		})
+
+		if g.Primary != nil {
+			g.Status = g.Primary.Status
+		}
+		for _, alias := range g.Aliases {
+			if g.Status == "" || statusSeverity(alias.Status) > statusSeverity(g.Status) {
+				g.Status = alias.Status
+			}
+		}
+
+		result = append(result, *g)
+	}
+
+	sort.Slice(result, func(i, j int) bool {
+		if result[i].Service != result[j].Service {
+			return result[i].Service < result[j].Service
+		}
+		return result[i].Domain < result[j].Domain
+	})
+
+	return result
+}
+
+// ServicesInGroup returns the services whose domain belongs to group
+func ServicesInGroup(services []ServiceHealth, group string) []ServiceHealth {
+	var result []ServiceHealth
+	for _, service := range services {
+		if service.Group == group {
+			result = append(result, service)
+		}
+	}
+	return result
+}
+
+func statusSeverity(status string) int {
+	switch status {
+	case "expired":
+		return 2
+	case "needs_renewal":
+		return 1
+	default:
+		return 0
+	}
+}
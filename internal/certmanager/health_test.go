@@ -0,0 +1,53 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_CheckServiceHealth(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.Domains = []config.Domain{
+		{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"}},
+		{Service: "api", Domain: "api.example.com"},
+	}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	cm := &CertificateManager{
+		config: cfg,
+		logger: logger,
+		certs: map[string]*Certificate{
+			"example.com":     createTestCertificate("example.com", 60),
+			"www.example.com": createTestCertificate("www.example.com", 10),
+			"api.example.com": createTestCertificate("api.example.com", 60),
+		},
+	}
+
+	health := cm.CheckCertificateHealth()
+	assert.Equal(t, "web", health["www.example.com"].Service)
+	assert.Equal(t, "example.com", health["www.example.com"].PrimaryDomain)
+	assert.Empty(t, health["example.com"].PrimaryDomain)
+
+	services := cm.CheckServiceHealth()
+	require.Len(t, services, 2)
+
+	assert.Equal(t, "api", services[0].Service)
+	assert.Equal(t, "valid", services[0].Status)
+	assert.Empty(t, services[0].Aliases)
+
+	web := services[1]
+	assert.Equal(t, "web", web.Service)
+	assert.Equal(t, "example.com", web.Domain)
+	require.NotNil(t, web.Primary)
+	assert.Equal(t, "valid", web.Primary.Status)
+	require.Len(t, web.Aliases, 1)
+	assert.Equal(t, "www.example.com", web.Aliases[0].Domain)
+
+	// An alias close to expiry marks the whole logical certificate
+	assert.Equal(t, "needs_renewal", web.Status)
+}
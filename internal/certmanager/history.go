@@ -0,0 +1,114 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+	"github.com/O-tero/traefik-cert-manager/internal/metadata"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+// historyTimeout bounds each write to the history database, so an unreachable
+// database can't stall renewals
+const historyTimeout = 10 * time.Second
+
+// History returns the history database, nil when none is configured
+func (cm *CertificateManager) History() *metadata.Store {
+	return cm.history
+}
+
+// recordHistory stores a certificate event and the certificate it produced
+func (cm *CertificateManager) recordHistory(event hooks.Event, domain string, cert *Certificate, cause error) {
+	if cm.history == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+
+	now := time.Now()
+	entry := metadata.Event{Domain: domain, Event: string(event), At: now}
+	if cause != nil {
+		entry.Error = cause.Error()
+	}
+	if cert != nil {
+		entry.Serial = cert.SerialNumber
+		if err := cm.history.RecordCertificate(ctx, metadata.Certificate{
+			Domain:     domain,
+			Serial:     cert.SerialNumber,
+			Issuer:     cert.Issuer,
+			SANs:       cert.SANs,
+			NotBefore:  cert.NotBefore,
+			NotAfter:   cert.ExpiresAt,
+			CA:         cert.CA,
+			OrderURL:   cert.OrderURL,
+			RecordedAt: now,
+		}); err != nil {
+			cm.logger.Printf("Warning: %v", err)
+		}
+	}
+	if err := cm.history.RecordEvent(ctx, entry); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+}
+
+// recordRun stores a finished scheduler run
+func (cm *CertificateManager) recordRun(summary *RunSummary) {
+	if cm.history == nil {
+		return
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+
+	data, err := json.Marshal(summary)
+	if err != nil {
+		cm.logger.Printf("Warning: failed to encode run summary: %v", err)
+		return
+	}
+	if err := cm.history.RecordRun(ctx, metadata.Run{
+		Run:        summary.Run,
+		Trigger:    summary.Trigger,
+		Status:     summary.Status,
+		Error:      summary.Error,
+		StartedAt:  summary.StartedAt,
+		FinishedAt: summary.FinishedAt,
+		Checked:    summary.Checked,
+		Renewed:    summary.Renewed,
+		Failed:     summary.Failed,
+		Skipped:    summary.Skipped,
+		Summary:    data,
+	}); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+}
+
+// historyNotifier records every notification handed to the underlying
+// notifier, and whether it was sent
+type historyNotifier struct {
+	notify.Notifier
+	history *metadata.Store
+	logger  *log.Logger
+}
+
+func (n *historyNotifier) Send(msg notify.Message) error {
+	err := n.Notifier.Send(msg)
+
+	ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+	defer cancel()
+	entry := metadata.Notification{
+		Domain:     msg.Domain,
+		Level:      string(msg.Level),
+		Subject:    msg.Subject,
+		Recipients: msg.Recipients,
+		At:         time.Now(),
+	}
+	if err != nil {
+		entry.Error = err.Error()
+	}
+	if recordErr := n.history.RecordNotification(ctx, entry); recordErr != nil {
+		n.logger.Printf("Warning: %v", recordErr)
+	}
+	return err
+}
@@ -0,0 +1,233 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+// holdsFileName records the domains whose automation is paused
+const holdsFileName = ".holds.json"
+
+var (
+	// ErrDomainHeld is returned for certificate operations refused while a domain is held
+	ErrDomainHeld = errors.New("domain is held")
+	ErrNotHeld    = errors.New("domain is not held")
+)
+
+// Hold pauses issuance, renewal and notifications of one domain, e.g. while it
+// migrates to another host, until it is released or Until passes
+type Hold struct {
+	Domain string    `json:"domain"`
+	Reason string    `json:"reason,omitempty"`
+	Since  time.Time `json:"since"`
+	Until  time.Time `json:"until,omitempty"` // zero holds the domain until it is released
+}
+
+// Active reports whether the hold is in force at now
+func (h Hold) Active(now time.Time) bool {
+	return h.Until.IsZero() || now.Before(h.Until)
+}
+
+// String describes the hold for logs and run summaries
+func (h Hold) String() string {
+	s := "held"
+	if !h.Until.IsZero() {
+		s += " until " + h.Until.Format(time.RFC3339)
+	}
+	if h.Reason != "" {
+		s += ": " + h.Reason
+	}
+	return s
+}
+
+// HoldStore keeps holds in a file in the storage path, so the CLI and the
+// management API of a running daemon share them and they survive restarts.
+// Expired holds are ignored and dropped on the next change. A nil store holds
+// no domain.
+type HoldStore struct {
+	path string
+	mu   sync.Mutex
+}
+
+func NewHoldStore(storagePath string) *HoldStore {
+	return &HoldStore{path: filepath.Join(storagePath, holdsFileName)}
+}
+
+// All returns the holds in force, by domain
+func (s *HoldStore) All() (map[string]Hold, error) {
+	if s == nil {
+		return map[string]Hold{}, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.load()
+}
+
+// Get returns the hold of domain, if one is in force
+func (s *HoldStore) Get(domain string) (Hold, bool) {
+	holds, err := s.All()
+	if err != nil {
+		// A damaged file still holds every domain: failing open would defeat the hold
+		return Hold{Domain: domain, Reason: err.Error()}, true
+	}
+	hold, ok := holds[domain]
+	return hold, ok
+}
+
+// Set holds a domain, replacing an earlier hold of it
+func (s *HoldStore) Set(hold Hold) error {
+	if s == nil {
+		return fmt.Errorf("holds are not available")
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holds, err := s.load()
+	if err != nil {
+		return err
+	}
+	holds[hold.Domain] = hold
+	return s.save(holds)
+}
+
+// Release removes the hold of domain, reporting whether there was one
+func (s *HoldStore) Release(domain string) (bool, error) {
+	if s == nil {
+		return false, nil
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	holds, err := s.load()
+	if err != nil {
+		return false, err
+	}
+	if _, ok := holds[domain]; !ok {
+		return false, nil
+	}
+	delete(holds, domain)
+	return true, s.save(holds)
+}
+
+// load reads the holds in force. Callers must hold s.mu.
+func (s *HoldStore) load() (map[string]Hold, error) {
+	holds := make(map[string]Hold)
+	data, err := os.ReadFile(s.path)
+	if os.IsNotExist(err) {
+		return holds, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read holds: %w", err)
+	}
+
+	var stored []Hold
+	if err := json.Unmarshal(data, &stored); err != nil {
+		return nil, fmt.Errorf("failed to parse holds: %w", err)
+	}
+	now := time.Now()
+	for _, hold := range stored {
+		if hold.Active(now) {
+			holds[hold.Domain] = hold
+		}
+	}
+	return holds, nil
+}
+
+// save writes holds sorted by domain, removing the file when there are none.
+// Callers must hold s.mu.
+func (s *HoldStore) save(holds map[string]Hold) error {
+	if len(holds) == 0 {
+		if err := os.Remove(s.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear holds: %w", err)
+		}
+		return nil
+	}
+
+	stored := make([]Hold, 0, len(holds))
+	for _, hold := range holds {
+		stored = append(stored, hold)
+	}
+	sort.Slice(stored, func(i, j int) bool { return stored[i].Domain < stored[j].Domain })
+
+	data, err := json.MarshalIndent(stored, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode holds: %w", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(s.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := writeFiles(storedFile{path: s.path, data: data, perm: 0644}); err != nil {
+		return fmt.Errorf("failed to write holds: %w", err)
+	}
+	return nil
+}
+
+// heldNotifier drops the notifications of held domains
+type heldNotifier struct {
+	notify.Notifier
+	holds *HoldStore
+}
+
+func (n *heldNotifier) Send(msg notify.Message) error {
+	if msg.Domain != "" {
+		if _, held := n.holds.Get(msg.Domain); held {
+			return nil
+		}
+	}
+	return n.Notifier.Send(msg)
+}
+
+// HoldDomain pauses automation of a managed domain until it is released or
+// until passes; a zero until holds it indefinitely
+func (cm *CertificateManager) HoldDomain(domain string, until time.Time, reason string) (Hold, error) {
+	cm.mu.RLock()
+	managed := cm.isManaged(domain)
+	cm.mu.RUnlock()
+	if !managed {
+		return Hold{}, fmt.Errorf("%w: %s", ErrDomainUnmanaged, domain)
+	}
+	if !until.IsZero() && !until.After(time.Now()) {
+		return Hold{}, fmt.Errorf("hold of %s must end in the future", domain)
+	}
+
+	hold := Hold{Domain: domain, Reason: reason, Since: time.Now().UTC(), Until: until}
+	if err := cm.holds.Set(hold); err != nil {
+		return Hold{}, err
+	}
+	cm.logger.Printf("Certificate automation of %s paused (%s)", domain, hold)
+	return hold, nil
+}
+
+// ReleaseDomain resumes automation of a held domain
+func (cm *CertificateManager) ReleaseDomain(domain string) error {
+	released, err := cm.holds.Release(domain)
+	if err != nil {
+		return err
+	}
+	if !released {
+		return fmt.Errorf("%w: %s", ErrNotHeld, domain)
+	}
+	cm.logger.Printf("Certificate automation of %s resumed", domain)
+	return nil
+}
+
+// Holds returns the holds in force, by domain
+func (cm *CertificateManager) Holds() (map[string]Hold, error) {
+	return cm.holds.All()
+}
+
+// checkHold refuses certificate operations for a held domain
+func (cm *CertificateManager) checkHold(domain string) error {
+	if hold, held := cm.holds.Get(domain); held {
+		return fmt.Errorf("%w: %s", ErrDomainHeld, hold)
+	}
+	return nil
+}
@@ -0,0 +1,84 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_HeldDomain(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Notification.Escalation = config.Escalation{WarningDays: 14}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	holds := NewHoldStore(testDir)
+	sent := &sentMessages{}
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		holds:      holds,
+		notifier:   &heldNotifier{Notifier: sent, holds: holds},
+		logger:     logger,
+		certs: map[string]*Certificate{
+			"example.com":     createTestCertificate("example.com", 10),
+			"api.example.com": createTestCertificate("api.example.com", 10),
+		},
+	}
+
+	_, err := cm.HoldDomain("unknown.example.com", time.Time{}, "")
+	assert.ErrorIs(t, err, ErrDomainUnmanaged)
+
+	until := time.Now().Add(72 * time.Hour)
+	_, err = cm.HoldDomain("example.com", until, "migration")
+	require.NoError(t, err)
+
+	// Renewal is refused without counting as a failure
+	err = cm.RenewCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrDomainHeld)
+	assert.Contains(t, err.Error(), "migration")
+	mockClient.AssertNotCalled(t, "RenewCertificate", "example.com")
+	assert.Empty(t, cm.TakeFailures())
+
+	health := cm.CheckCertificateHealth()
+	require.NotNil(t, health["example.com"].Hold)
+	assert.True(t, health["example.com"].Hold.Until.Equal(until))
+	assert.Nil(t, health["api.example.com"].Hold)
+
+	// Only the domain that isn't held is notified about
+	cm.NotifyExpiring()
+	require.Len(t, sent.messages, 1)
+	assert.Equal(t, "api.example.com", sent.messages[0].Domain)
+
+	require.NoError(t, cm.ReleaseDomain("example.com"))
+	assert.ErrorIs(t, cm.ReleaseDomain("example.com"), ErrNotHeld)
+	assert.Nil(t, cm.CheckCertificateHealth()["example.com"].Hold)
+}
+
+func TestHoldStore_ExpiredHoldsAreIgnored(t *testing.T) {
+	testDir := setupTestDir(t)
+	store := NewHoldStore(testDir)
+
+	require.NoError(t, store.Set(Hold{Domain: "expired.example.com", Until: time.Now().Add(-time.Minute)}))
+	require.NoError(t, store.Set(Hold{Domain: "held.example.com"}))
+
+	// Holds are shared through the storage path
+	holds, err := NewHoldStore(testDir).All()
+	require.NoError(t, err)
+	assert.Len(t, holds, 1)
+	assert.Contains(t, holds, "held.example.com")
+
+	n := &heldNotifier{Notifier: &sentMessages{}, holds: store}
+	require.NoError(t, n.Send(notify.Message{Domain: "held.example.com"}))
+	require.NoError(t, n.Send(notify.Message{Domain: "expired.example.com"}))
+	assert.Len(t, n.Notifier.(*sentMessages).messages, 1)
+}
@@ -0,0 +1,94 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/tls"
+	"fmt"
+	"time"
+)
+
+// ImportCertificate stores an externally issued certificate and key and brings the
+// domain under management. Missing intermediates are fetched through the AIA
+// extension, and the completed chain must verify before anything is written.
+func (cm *CertificateManager) ImportCertificate(ctx context.Context, certPEM, keyPEM []byte) (string, error) {
+	if _, err := tls.X509KeyPair(certPEM, keyPEM); err != nil {
+		return "", fmt.Errorf("certificate and key do not form a valid pair: %w", err)
+	}
+
+	certs, err := parsePEMCertificates(certPEM)
+	if err != nil {
+		return "", err
+	}
+	leaf, intermediates := certs[0], certs[1:]
+
+	domain := leaf.Subject.CommonName
+	if domain == "" && len(leaf.DNSNames) > 0 {
+		domain = leaf.DNSNames[0]
+	}
+	if domain == "" {
+		return "", fmt.Errorf("certificate has no common name or DNS names")
+	}
+
+	if err := verifyChain(leaf, intermediates, cm.trustRoots); err != nil {
+		fetcher := cm.chainFetcher
+		if fetcher == nil {
+			fetcher = NewChainFetcher(30 * time.Second)
+		}
+
+		cm.logger.Printf("Chain for imported certificate %s is incomplete (%v), fetching issuers via AIA", domain, err)
+		intermediates, err = fetcher.FetchChain(ctx, leaf)
+		if err != nil {
+			return "", fmt.Errorf("failed to complete chain for %s: %w", domain, err)
+		}
+
+		if err := verifyChain(leaf, intermediates, cm.trustRoots); err != nil {
+			return "", fmt.Errorf("completed chain for %s does not verify: %w", domain, err)
+		}
+		cm.logger.Printf("Completed chain for %s with %d intermediates", domain, len(intermediates))
+	}
+
+	issuerPEM := encodePEMCertificates(intermediates)
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: append(encodePEMCertificates(certs[:1]), issuerPEM...),
+		PrivateKey:  keyPEM,
+		IssuerCert:  issuerPEM,
+		IssuedAt:    leaf.NotBefore,
+		ExpiresAt:   leaf.NotAfter,
+	}
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.checkNotOrdering(domain); err != nil {
+		return "", err
+	}
+
+	if err := cm.acmeClient.SaveCertificate(ctx, cert); err != nil {
+		return "", fmt.Errorf("failed to save imported certificate for %s: %w", domain, err)
+	}
+
+	delete(cm.unmanaged, domain)
+	cm.certs[domain] = cert
+	cm.publishTraefikTLS()
+
+	if !cm.isConfigured(domain) {
+		if cm.adopted == nil {
+			cm.adopted = make(map[string]bool)
+		}
+		cm.adopted[domain] = true
+		if err := cm.saveAdopted(); err != nil {
+			return domain, err
+		}
+	}
+
+	cm.logger.Printf("Imported certificate for %s (expires: %s)", domain, cert.ExpiresAt.Format(time.RFC3339))
+	return domain, nil
+}
+
+// isConfigured reports whether a domain or alias is configured or discovered.
+// Callers must hold cm.mu.
+func (cm *CertificateManager) isConfigured(domain string) bool {
+	_, exists := cm.domainRoles()[domain]
+	return exists
+}
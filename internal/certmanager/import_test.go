@@ -0,0 +1,84 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_ImportCertificate(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	cas := make(map[string]*testCA)
+	server := serveIssuers(t, cas)
+
+	root := newTestCA(t, "Test Root", 3650, nil)
+	root.url = server.URL + "/root.der"
+	intermediate := newTestCA(t, "Test Intermediate", 365, root)
+	intermediate.url = server.URL + "/intermediate.der"
+	cas["root"], cas["intermediate"] = root, intermediate
+
+	issued := intermediate.issue(t, "imported.example.com", 60)
+	leaf, err := issued.leaf()
+	require.NoError(t, err)
+	leafOnly := encodePEMCertificates([]*x509.Certificate{leaf})
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	newManager := func(roots *x509.CertPool) (*CertificateManager, *MockACMEClient) {
+		mockClient := NewMockACMEClient(testDir, logger)
+		mockClient.On("SaveCertificate", mock.AnythingOfType("*certmanager.Certificate")).Return(nil)
+		return &CertificateManager{
+			config:       cfg,
+			acmeClient:   mockClient,
+			chainFetcher: NewChainFetcher(5 * time.Second),
+			trustRoots:   roots,
+			logger:       logger,
+			certs:        make(map[string]*Certificate),
+			unmanaged:    make(map[string]*Certificate),
+		}, mockClient
+	}
+
+	t.Run("completes missing intermediates", func(t *testing.T) {
+		roots := x509.NewCertPool()
+		roots.AddCert(root.cert)
+		cm, mockClient := newManager(roots)
+
+		domain, err := cm.ImportCertificate(context.Background(), leafOnly, issued.PrivateKey)
+		require.NoError(t, err)
+		assert.Equal(t, "imported.example.com", domain)
+		mockClient.AssertNumberOfCalls(t, "SaveCertificate", 1)
+
+		cert, err := cm.GetCertificate(domain)
+		require.NoError(t, err)
+		assert.Equal(t, intermediate.pem, cert.IssuerCert)
+		assert.Equal(t, issued.Certificate, cert.Certificate)
+
+		// Imported certificates are renewed like adopted ones
+		assert.Contains(t, cm.GetManagedDomains(), domain)
+	})
+
+	t.Run("rejects chains that do not verify", func(t *testing.T) {
+		cm, mockClient := newManager(x509.NewCertPool())
+
+		_, err := cm.ImportCertificate(context.Background(), leafOnly, issued.PrivateKey)
+		assert.ErrorContains(t, err, "does not verify")
+		mockClient.AssertNotCalled(t, "SaveCertificate", mock.Anything)
+	})
+
+	t.Run("rejects mismatched keys", func(t *testing.T) {
+		cm, _ := newManager(nil)
+		other := intermediate.issue(t, "other.example.com", 60)
+
+		_, err := cm.ImportCertificate(context.Background(), leafOnly, other.PrivateKey)
+		assert.Error(t, err)
+	})
+}
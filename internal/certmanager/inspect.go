@@ -0,0 +1,174 @@
+package certmanager
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// oidSCTList identifies the embedded Signed Certificate Timestamp list extension (RFC 6962)
+var oidSCTList = asn1.ObjectIdentifier{1, 3, 6, 1, 4, 1, 11129, 2, 4, 2}
+
+// CertificateInspection is everything worth knowing about a stored certificate,
+// as an operator would otherwise dig out with openssl x509 -text
+type CertificateInspection struct {
+	Domain             string             `json:"domain" yaml:"domain"`
+	Subject            string             `json:"subject" yaml:"subject"`
+	Issuer             string             `json:"issuer" yaml:"issuer"`
+	SANs               []string           `json:"sans" yaml:"sans"`
+	Serial             string             `json:"serial" yaml:"serial"`
+	NotBefore          time.Time          `json:"not_before" yaml:"not_before"`
+	NotAfter           time.Time          `json:"not_after" yaml:"not_after"`
+	SignatureAlgorithm string             `json:"signature_algorithm" yaml:"signature_algorithm"`
+	KeyAlgorithm       string             `json:"key_algorithm" yaml:"key_algorithm"`
+	KeySize            int                `json:"key_size" yaml:"key_size"`
+	FingerprintSHA1    string             `json:"fingerprint_sha1" yaml:"fingerprint_sha1"`
+	FingerprintSHA256  string             `json:"fingerprint_sha256" yaml:"fingerprint_sha256"`
+	OCSPServers        []string           `json:"ocsp_servers,omitempty" yaml:"ocsp_servers,omitempty"`
+	CRLDistribution    []string           `json:"crl_distribution_points,omitempty" yaml:"crl_distribution_points,omitempty"`
+	IssuingCertURL     []string           `json:"issuing_certificate_url,omitempty" yaml:"issuing_certificate_url,omitempty"`
+	SCTs               []SignedTimestamp  `json:"scts,omitempty" yaml:"scts,omitempty"`
+	Chain              []ChainCertificate `json:"chain" yaml:"chain"`
+	ChainRoot          string             `json:"chain_root,omitempty" yaml:"chain_root,omitempty"`
+	CA                 string             `json:"ca,omitempty" yaml:"ca,omitempty"` // directory URL of the CA it was ordered from, or internal-ca
+	OrderURL           string             `json:"order_url,omitempty" yaml:"order_url,omitempty"`
+	CertificateURL     string             `json:"certificate_url,omitempty" yaml:"certificate_url,omitempty"`
+}
+
+// SignedTimestamp is a Signed Certificate Timestamp embedded by the CA
+type SignedTimestamp struct {
+	Version   int       `json:"version" yaml:"version"`
+	LogID     string    `json:"log_id" yaml:"log_id"` // base64, as in CT log lists
+	Timestamp time.Time `json:"timestamp" yaml:"timestamp"`
+}
+
+// Inspect parses the stored leaf and chain of the certificate
+func (c *Certificate) Inspect() (*CertificateInspection, error) {
+	leaf, err := c.leaf()
+	if err != nil {
+		return nil, err
+	}
+	chain, err := c.ChainCertificates()
+	if err != nil {
+		return nil, err
+	}
+
+	sha1Sum := sha1.Sum(leaf.Raw)
+	sha256Sum := sha256.Sum256(leaf.Raw)
+
+	inspection := &CertificateInspection{
+		Domain:             c.Domain,
+		Subject:            leaf.Subject.String(),
+		Issuer:             leaf.Issuer.String(),
+		SANs:               leafSANs(leaf),
+		Serial:             colonHex(leaf.SerialNumber.Bytes()),
+		NotBefore:          leaf.NotBefore,
+		NotAfter:           leaf.NotAfter,
+		SignatureAlgorithm: leaf.SignatureAlgorithm.String(),
+		KeyAlgorithm:       leaf.PublicKeyAlgorithm.String(),
+		KeySize:            publicKeySize(leaf.PublicKey),
+		FingerprintSHA1:    colonHex(sha1Sum[:]),
+		FingerprintSHA256:  colonHex(sha256Sum[:]),
+		OCSPServers:        leaf.OCSPServer,
+		CRLDistribution:    leaf.CRLDistributionPoints,
+		IssuingCertURL:     leaf.IssuingCertificateURL,
+		Chain:              chain,
+		ChainRoot:          c.ChainRoot(),
+		CA:                 c.CA,
+		OrderURL:           c.OrderURL,
+		CertificateURL:     c.URL,
+	}
+
+	if inspection.SCTs, err = embeddedSCTs(leaf); err != nil {
+		return nil, fmt.Errorf("failed to parse SCTs: %w", err)
+	}
+	return inspection, nil
+}
+
+// publicKeySize returns the key size in bits
+func publicKeySize(key interface{}) int {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return key.N.BitLen()
+	case *ecdsa.PublicKey:
+		return key.Curve.Params().BitSize
+	case ed25519.PublicKey:
+		return 256
+	default:
+		return 0
+	}
+}
+
+// colonHex formats bytes the way openssl prints serials and fingerprints
+func colonHex(b []byte) string {
+	parts := make([]string, len(b))
+	for i, v := range b {
+		parts[i] = strings.ToUpper(hex.EncodeToString([]byte{v}))
+	}
+	return strings.Join(parts, ":")
+}
+
+// embeddedSCTs decodes the SignedCertificateTimestampList extension, a TLS
+// encoded list (RFC 6962 section 3.3) wrapped in an ASN.1 OCTET STRING
+func embeddedSCTs(cert *x509.Certificate) ([]SignedTimestamp, error) {
+	var raw []byte
+	for _, ext := range cert.Extensions {
+		if ext.Id.Equal(oidSCTList) {
+			raw = ext.Value
+			break
+		}
+	}
+	if raw == nil {
+		return nil, nil
+	}
+
+	var list []byte
+	if _, err := asn1.Unmarshal(raw, &list); err != nil {
+		return nil, err
+	}
+
+	list, ok := readOpaque16(&list)
+	if !ok {
+		return nil, fmt.Errorf("truncated SCT list")
+	}
+
+	var scts []SignedTimestamp
+	for len(list) > 0 {
+		sct, ok := readOpaque16(&list)
+		// version (1) + log id (32) + timestamp (8)
+		if !ok || len(sct) < 41 {
+			return nil, fmt.Errorf("truncated SCT")
+		}
+		millis := binary.BigEndian.Uint64(sct[33:41])
+		scts = append(scts, SignedTimestamp{
+			Version:   int(sct[0]) + 1,
+			LogID:     base64.StdEncoding.EncodeToString(sct[1:33]),
+			Timestamp: time.UnixMilli(int64(millis)).UTC(),
+		})
+	}
+	return scts, nil
+}
+
+// readOpaque16 consumes a 2-byte length prefixed value from data
+func readOpaque16(data *[]byte) ([]byte, bool) {
+	if len(*data) < 2 {
+		return nil, false
+	}
+	n := int(binary.BigEndian.Uint16(*data))
+	if len(*data) < 2+n {
+		return nil, false
+	}
+	value := (*data)[2 : 2+n]
+	*data = (*data)[2+n:]
+	return value, true
+}
@@ -0,0 +1,79 @@
+package certmanager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/pem"
+	"math/big"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// testSCTList encodes a SignedCertificateTimestampList with one SCT
+func testSCTList(t *testing.T, logID byte, timestamp time.Time) []byte {
+	sct := []byte{0} // v1
+	for i := 0; i < 32; i++ {
+		sct = append(sct, logID)
+	}
+	sct = binary.BigEndian.AppendUint64(sct, uint64(timestamp.UnixMilli()))
+	sct = append(sct, 0, 0)          // no extensions
+	sct = append(sct, 4, 3, 0, 1, 0) // ECDSA-SHA256, one byte signature
+
+	entry := binary.BigEndian.AppendUint16(nil, uint16(len(sct)))
+	entry = append(entry, sct...)
+	list := binary.BigEndian.AppendUint16(nil, uint16(len(entry)))
+	list = append(list, entry...)
+
+	value, err := asn1.Marshal(list)
+	require.NoError(t, err)
+	return value
+}
+
+func TestCertificate_Inspect(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	timestamp := time.Date(2030, 1, 1, 12, 0, 0, 0, time.UTC)
+	template := x509.Certificate{
+		SerialNumber:          big.NewInt(0x0abc),
+		Subject:               pkix.Name{CommonName: "example.com"},
+		DNSNames:              []string{"example.com", "www.example.com"},
+		NotBefore:             time.Now(),
+		NotAfter:              time.Now().Add(90 * 24 * time.Hour),
+		OCSPServer:            []string{"http://ocsp.example.test"},
+		CRLDistributionPoints: []string{"http://crl.example.test/1.crl"},
+		ExtraExtensions:       []pkix.Extension{{Id: oidSCTList, Value: testSCTList(t, 7, timestamp)}},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, &template, &template, &key.PublicKey, key)
+	require.NoError(t, err)
+
+	cert := &Certificate{
+		Domain:      "example.com",
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+	}
+
+	inspection, err := cert.Inspect()
+	require.NoError(t, err)
+
+	assert.Equal(t, "CN=example.com", inspection.Subject)
+	assert.Equal(t, []string{"example.com", "www.example.com"}, inspection.SANs)
+	assert.Equal(t, "0A:BC", inspection.Serial)
+	assert.Equal(t, "ECDSA", inspection.KeyAlgorithm)
+	assert.Equal(t, 256, inspection.KeySize)
+	assert.Len(t, inspection.FingerprintSHA256, 32*3-1)
+	assert.Equal(t, []string{"http://ocsp.example.test"}, inspection.OCSPServers)
+	assert.Equal(t, []string{"http://crl.example.test/1.crl"}, inspection.CRLDistribution)
+
+	require.Len(t, inspection.SCTs, 1)
+	assert.Equal(t, 1, inspection.SCTs[0].Version)
+	assert.Equal(t, timestamp, inspection.SCTs[0].Timestamp)
+	assert.Equal(t, "BwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwcHBwc=", inspection.SCTs[0].LogID)
+}
@@ -0,0 +1,262 @@
+package certmanager
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha1"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"fmt"
+	"log"
+	"math/big"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/encryption"
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// internalCADir holds the internal CA's certificate and key under the storage path
+const internalCADir = "internal-ca"
+
+// InternalCA is a certificate authority kept in the storage path. It signs the
+// certificates of domains no public CA can validate, such as *.lab.local, and
+// is generated the first time it is needed. Its key is sealed like the private
+// keys of certificates when encryption is configured.
+type InternalCA struct {
+	dir        string
+	commonName string
+	validity   time.Duration // of issued certificates
+	caValidity time.Duration // of the CA certificate when it is generated
+	encryption *encryption.Envelope
+	logger     *log.Logger
+
+	mu      sync.Mutex
+	cert    *x509.Certificate
+	certPEM []byte
+	key     crypto.Signer
+}
+
+func NewInternalCA(storagePath, commonName string, validity, caValidity time.Duration, envelope *encryption.Envelope, logger *log.Logger) *InternalCA {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[InternalCA] ", log.LstdFlags)
+	}
+
+	return &InternalCA{
+		dir:        filepath.Join(storagePath, internalCADir),
+		commonName: commonName,
+		validity:   validity,
+		caValidity: caValidity,
+		encryption: envelope,
+		logger:     logger,
+	}
+}
+
+// CertificatePEM returns the CA certificate, for the trust stores of clients
+func (ca *InternalCA) CertificatePEM() ([]byte, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	if err := ca.load(); err != nil {
+		return nil, err
+	}
+	return ca.certPEM, nil
+}
+
+// Sign issues a certificate for the names and key of csr. Subject and
+// extensions of the CSR, such as key usages from a CSR template, are kept.
+func (ca *InternalCA) Sign(csr *x509.CertificateRequest) ([]byte, error) {
+	ca.mu.Lock()
+	defer ca.mu.Unlock()
+
+	if err := ca.load(); err != nil {
+		return nil, err
+	}
+	if err := csr.CheckSignature(); err != nil {
+		return nil, fmt.Errorf("invalid CSR signature: %w", err)
+	}
+
+	serial, err := randomSerial()
+	if err != nil {
+		return nil, err
+	}
+
+	keyUsage := x509.KeyUsageDigitalSignature
+	if _, ok := csr.PublicKey.(*rsa.PublicKey); ok {
+		keyUsage |= x509.KeyUsageKeyEncipherment
+	}
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber: serial,
+		Subject:      csr.Subject,
+		DNSNames:     csr.DNSNames,
+		IPAddresses:  csr.IPAddresses,
+		NotBefore:    now.Add(-time.Minute),
+		NotAfter:     now.Add(ca.validity),
+		KeyUsage:     keyUsage,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		// Usages requested in the CSR replace the defaults above
+		ExtraExtensions: csr.Extensions,
+	}
+	if template.NotAfter.After(ca.cert.NotAfter) {
+		template.NotAfter = ca.cert.NotAfter
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, template, ca.cert, csr.PublicKey, ca.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign certificate: %w", err)
+	}
+	return pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), nil
+}
+
+// load reads the CA from storage, generating it if there is none. Callers must hold ca.mu.
+func (ca *InternalCA) load() error {
+	if ca.cert != nil {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), keyEncryptionTimeout)
+	defer cancel()
+
+	certPath := filepath.Join(ca.dir, "ca.crt")
+	keyPath := filepath.Join(ca.dir, "ca.key")
+
+	certPEM, err := os.ReadFile(certPath)
+	if os.IsNotExist(err) {
+		return ca.generate(ctx, certPath, keyPath)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read internal CA certificate: %w", err)
+	}
+
+	keyData, err := os.ReadFile(keyPath)
+	if err != nil {
+		return fmt.Errorf("failed to read internal CA key: %w", err)
+	}
+	if encryption.IsSealed(keyData) {
+		if ca.encryption == nil {
+			return fmt.Errorf("internal CA key is encrypted but no encryption provider is configured")
+		}
+		if keyData, err = ca.encryption.Open(ctx, keyData); err != nil {
+			return fmt.Errorf("failed to decrypt internal CA key: %w", err)
+		}
+	}
+
+	block, _ := pem.Decode(certPEM)
+	if block == nil {
+		return fmt.Errorf("failed to parse internal CA certificate PEM")
+	}
+	cert, err := x509.ParseCertificate(block.Bytes)
+	if err != nil {
+		return fmt.Errorf("failed to parse internal CA certificate: %w", err)
+	}
+	key, err := certcrypto.ParsePEMPrivateKey(keyData)
+	if err != nil {
+		return fmt.Errorf("failed to parse internal CA key: %w", err)
+	}
+	signer, ok := key.(crypto.Signer)
+	if !ok {
+		return fmt.Errorf("internal CA key can't sign")
+	}
+
+	if time.Now().After(cert.NotAfter) {
+		ca.logger.Printf("Warning: the internal CA certificate expired on %s; remove %s to generate a new one",
+			cert.NotAfter.Format("2006-01-02"), ca.dir)
+	}
+
+	ca.cert, ca.certPEM, ca.key = cert, certPEM, signer
+	return nil
+}
+
+// generate creates the CA's key and self-signed certificate
+func (ca *InternalCA) generate(ctx context.Context, certPath, keyPath string) error {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return fmt.Errorf("failed to generate internal CA key: %w", err)
+	}
+	serial, err := randomSerial()
+	if err != nil {
+		return err
+	}
+	publicKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		return fmt.Errorf("failed to encode internal CA key: %w", err)
+	}
+	keyID := sha1.Sum(publicKey)
+
+	now := time.Now()
+	template := &x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{CommonName: ca.commonName},
+		NotBefore:             now.Add(-time.Minute),
+		NotAfter:              now.Add(ca.caValidity),
+		KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign | x509.KeyUsageDigitalSignature,
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+		MaxPathLenZero:        true,
+		SubjectKeyId:          keyID[:],
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		return fmt.Errorf("failed to create internal CA certificate: %w", err)
+	}
+	cert, err := x509.ParseCertificate(der)
+	if err != nil {
+		return fmt.Errorf("failed to parse internal CA certificate: %w", err)
+	}
+
+	keyData := certcrypto.PEMEncode(key)
+	if ca.encryption != nil {
+		if keyData, err = ca.encryption.Seal(ctx, keyData); err != nil {
+			return fmt.Errorf("failed to encrypt internal CA key: %w", err)
+		}
+	}
+	if err := os.MkdirAll(ca.dir, 0700); err != nil {
+		return fmt.Errorf("failed to create internal CA directory: %w", err)
+	}
+	certPEM := pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der})
+	if err := writeFiles(
+		storedFile{path: keyPath, data: keyData, perm: 0600},
+		storedFile{path: certPath, data: certPEM, perm: 0644},
+	); err != nil {
+		return fmt.Errorf("failed to save internal CA: %w", err)
+	}
+
+	ca.logger.Printf("Generated internal CA %q (expires: %s); clients must trust %s",
+		ca.commonName, cert.NotAfter.Format(time.RFC3339), certPath)
+	ca.cert, ca.certPEM, ca.key = cert, certPEM, key
+	return nil
+}
+
+// randomSerial returns a random positive certificate serial number
+func randomSerial() (*big.Int, error) {
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate serial number: %w", err)
+	}
+	return serial.Add(serial, big.NewInt(1)), nil
+}
+
+// issuedInternally reports whether the internal CA signs the certificate of
+// domain, which then needs no DNS pre-check and counts against no CA limits
+func (cm *CertificateManager) issuedInternally(domain string) bool {
+	return cm.config.IssuerFor(domain) == config.IssuerInternalCA
+}
+
+// InternalCACertificate returns the certificate of the internal CA, generating
+// the CA if it doesn't exist yet, so clients can trust it before first issuance
+func (cm *CertificateManager) InternalCACertificate() ([]byte, error) {
+	if cm.internalCA == nil {
+		return nil, fmt.Errorf("the internal CA is not set up")
+	}
+	return cm.internalCA.CertificatePEM()
+}
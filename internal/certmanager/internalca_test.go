@@ -0,0 +1,76 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInternalCA_GeneratedOnceAndReused(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	first, err := NewInternalCA(testDir, "Lab CA", 90*24*time.Hour, 365*24*time.Hour, nil, logger).CertificatePEM()
+	require.NoError(t, err)
+	again, err := NewInternalCA(testDir, "Lab CA", 90*24*time.Hour, 365*24*time.Hour, nil, logger).CertificatePEM()
+	require.NoError(t, err)
+	assert.Equal(t, first, again, "a stored CA is reused")
+
+	info, err := os.Stat(filepath.Join(testDir, internalCADir, "ca.key"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	block, _ := pem.Decode(first)
+	require.NotNil(t, block)
+	caCert, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	assert.True(t, caCert.IsCA)
+	assert.Equal(t, "Lab CA", caCert.Subject.CommonName)
+}
+
+func TestACMEClients_IssuesInternalCADomains(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	ca := NewInternalCA(testDir, "Lab CA", 90*24*time.Hour, 365*24*time.Hour, nil, logger)
+
+	clients := &ACMEClients{
+		internal:    ca,
+		internalFor: func(domain string) bool { return domain == "*.lab.local" },
+		fallback:    &ACMEClient{storagePath: testDir, keyType: certcrypto.EC256, logger: logger},
+	}
+
+	cert, err := clients.RequestCertificate(context.Background(), "*.lab.local")
+	require.NoError(t, err)
+	assert.InDelta(t, 90, cert.DaysUntilExpiry(), 1)
+
+	// The certificate verifies for names under the wildcard with the CA as only root
+	caPEM, err := ca.CertificatePEM()
+	require.NoError(t, err)
+	roots := x509.NewCertPool()
+	require.True(t, roots.AppendCertsFromPEM(caPEM))
+	block, _ := pem.Decode(cert.Certificate)
+	leaf, err := x509.ParseCertificate(block.Bytes)
+	require.NoError(t, err)
+	_, err = leaf.Verify(x509.VerifyOptions{DNSName: "grafana.lab.local", Roots: roots})
+	assert.NoError(t, err)
+
+	stored, err := clients.LoadCertificate(context.Background(), "*.lab.local")
+	require.NoError(t, err)
+	assert.Equal(t, cert.Certificate, stored.Certificate)
+	assert.Equal(t, caPEM, stored.IssuerCert)
+
+	renewed, err := clients.RenewCertificate(context.Background(), cert)
+	require.NoError(t, err)
+	assert.NotEqual(t, cert.PrivateKey, renewed.PrivateKey, "renewals are issued with a new key")
+
+	assert.Error(t, clients.RevokeCertificate(context.Background(), renewed, 0))
+}
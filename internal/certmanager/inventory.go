@@ -0,0 +1,75 @@
+package certmanager
+
+import (
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/rsa"
+	"fmt"
+)
+
+// CertificateDetails describes a stored certificate for listings
+type CertificateDetails struct {
+	CertificateHealth
+	KeyType  string `json:"key_type"` // RSA2048, EC256, ... as in acme.key_type
+	CertPath string `json:"cert_path"`
+	KeyPath  string `json:"key_path"`
+}
+
+// CertificateDetails returns the health of every stored certificate together
+// with its key type and where it is stored
+func (cm *CertificateManager) CertificateDetails() []CertificateDetails {
+	health := cm.CheckCertificateHealth()
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	details := make([]CertificateDetails, 0, len(health))
+	for domain, status := range health {
+		entry := CertificateDetails{CertificateHealth: status}
+		entry.CertPath, entry.KeyPath = cm.GetCertificatePaths(domain)
+
+		if cert, ok := cm.certs[domain]; ok {
+			if leaf, err := cert.leaf(); err == nil {
+				entry.KeyType = publicKeyType(leaf.PublicKey)
+			}
+		}
+
+		details = append(details, entry)
+	}
+	return details
+}
+
+// PEMKeyPair is a PEM certificate chain and its private key
+type PEMKeyPair struct {
+	Certificate []byte
+	PrivateKey  []byte
+}
+
+// KeyPairs returns the PEM certificate and key of every managed certificate by
+// domain. Certificates whose key is kept outside the manager are left out.
+func (cm *CertificateManager) KeyPairs() map[string]PEMKeyPair {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	pairs := make(map[string]PEMKeyPair, len(cm.certs))
+	for domain, cert := range cm.certs {
+		if len(cert.PrivateKey) > 0 {
+			pairs[domain] = PEMKeyPair{Certificate: cert.Certificate, PrivateKey: cert.PrivateKey}
+		}
+	}
+	return pairs
+}
+
+// publicKeyType names a public key the way acme.key_type does
+func publicKeyType(key interface{}) string {
+	switch key := key.(type) {
+	case *rsa.PublicKey:
+		return fmt.Sprintf("RSA%d", key.N.BitLen())
+	case *ecdsa.PublicKey:
+		return fmt.Sprintf("EC%d", key.Curve.Params().BitSize)
+	case ed25519.PublicKey:
+		return "Ed25519"
+	default:
+		return "unknown"
+	}
+}
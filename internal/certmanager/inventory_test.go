@@ -0,0 +1,34 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_CertificateDetails(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	cm := &CertificateManager{
+		config: cfg,
+		logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		certs:  map[string]*Certificate{"example.com": createTestCertificate("example.com", 60)},
+	}
+
+	details := cm.CertificateDetails()
+	require.Len(t, details, 1)
+
+	detail := details[0]
+	assert.Equal(t, "example.com", detail.Domain)
+	assert.Equal(t, "valid", detail.Status)
+	assert.Equal(t, []string{"example.com"}, detail.SANs)
+	assert.Equal(t, "example.com", detail.Issuer) // self-signed
+	assert.Equal(t, "RSA2048", detail.KeyType)
+	assert.Equal(t, filepath.Join(testDir, "example.com.crt"), detail.CertPath)
+}
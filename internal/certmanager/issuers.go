@@ -0,0 +1,297 @@
+package certmanager
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/go-acme/lego/v4/certcrypto"
+)
+
+// issuer is a CA directory and the challenge domains ordered from it are validated with
+type issuer struct {
+	caDirURL  string
+	challenge string
+}
+
+// ACMEClients routes each domain to an ACME client for the CA and challenge
+// type it is configured with. The client for the global settings is created
+// up front; others are created when a domain first needs them and share its
+// order journal and storage.
+type ACMEClients struct {
+	base        ACMEConfig
+	issuerFor   func(domain string) issuer
+	internal    *InternalCA
+	internalFor func(domain string) bool
+	mu          sync.Mutex
+	clients     map[issuer]*ACMEClient
+	fallback    *ACMEClient
+}
+
+// NewACMEClients creates the client for base. issuerFor returns the CA
+// directory and challenge of a domain; empty values use those of base.
+func NewACMEClients(base ACMEConfig, issuerFor func(domain string) (caDirURL, challenge string)) (*ACMEClients, error) {
+	fallback, err := NewACMEClient(base)
+	if err != nil {
+		return nil, err
+	}
+	base.orders = fallback.orders
+
+	clients := &ACMEClients{
+		base:        base,
+		internal:    base.InternalCA,
+		internalFor: base.InternalCAFor,
+		clients:     map[issuer]*ACMEClient{{base.CADirURL, base.Challenge}: fallback},
+		fallback:    fallback,
+	}
+	clients.issuerFor = func(domain string) issuer {
+		if issuerFor == nil {
+			return issuer{base.CADirURL, base.Challenge}
+		}
+		caDirURL, challenge := issuerFor(domain)
+		if caDirURL == "" {
+			caDirURL = base.CADirURL
+		}
+		if challenge == "" {
+			challenge = base.Challenge
+		}
+		return issuer{caDirURL, challenge}
+	}
+	return clients, nil
+}
+
+// clientFor returns the client ordering certificates for domain
+func (a *ACMEClients) clientFor(domain string) (*ACMEClient, error) {
+	key := a.issuerFor(domain)
+
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	if client, ok := a.clients[key]; ok {
+		return client, nil
+	}
+
+	config := a.base
+	config.CADirURL = key.caDirURL
+	config.Challenge = key.challenge
+	client, err := NewACMEClient(config)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create ACME client for %s (%s): %w", key.caDirURL, key.challenge, err)
+	}
+	a.clients[key] = client
+	return client, nil
+}
+
+// issuedInternally reports whether the internal CA signs the certificates of domain
+func (a *ACMEClients) issuedInternally(domain string) bool {
+	return a.internal != nil && a.internalFor != nil && a.internalFor(domain)
+}
+
+// issueInternal signs a certificate for domain with a new key of keyType, or
+// the domain's key file, with the internal CA
+func (a *ACMEClients) issueInternal(domain string, keyType certcrypto.KeyType) (*Certificate, error) {
+	var key crypto.PrivateKey
+	var err error
+	if keyFile, _ := a.fallback.externalKey(domain); keyFile != "" {
+		if key, err = loadExternalKey(keyFile); err != nil {
+			return nil, err
+		}
+	} else if key, err = certcrypto.GeneratePrivateKey(keyType); err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	csr, err := a.fallback.createCSR(domain, a.fallback.names(domain), key)
+	if err != nil {
+		return nil, err
+	}
+	leaf, err := a.internal.Sign(csr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to issue certificate for %s with the internal CA: %w", domain, err)
+	}
+	caPEM, err := a.internal.CertificatePEM()
+	if err != nil {
+		return nil, err
+	}
+
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: append(leaf, caPEM...),
+		PrivateKey:  certcrypto.PEMEncode(key),
+		IssuerCert:  caPEM,
+		IssuedAt:    time.Now(),
+		CA:          config.IssuerInternalCA,
+	}
+	if err := cert.parseCertificate(); err != nil {
+		return nil, err
+	}
+	a.fallback.logger.Printf("Issued certificate for %s with the internal CA (expires: %s)", domain, cert.ExpiresAt.Format(time.RFC3339))
+	return cert, nil
+}
+
+func (a *ACMEClients) RequestCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	if a.issuedInternally(domain) {
+		cert, err := a.issueInternal(domain, a.fallback.domainKeyType(domain))
+		if err != nil {
+			return nil, err
+		}
+		if err := a.fallback.checkSwitch(ctx, cert); err != nil {
+			return nil, err
+		}
+		if err := a.fallback.SaveCertificate(ctx, cert); err != nil {
+			return nil, fmt.Errorf("failed to save certificate: %w", err)
+		}
+		return cert, nil
+	}
+
+	client, err := a.clientFor(domain)
+	if err != nil {
+		return nil, err
+	}
+	return client.RequestCertificate(ctx, domain)
+}
+
+func (a *ACMEClients) RenewCertificate(ctx context.Context, cert *Certificate) (*Certificate, error) {
+	if a.issuedInternally(cert.Domain) {
+		return a.RequestCertificate(ctx, cert.Domain)
+	}
+
+	client, err := a.clientFor(cert.Domain)
+	if err != nil {
+		return nil, err
+	}
+	return client.RenewCertificate(ctx, cert)
+}
+
+func (a *ACMEClients) RevokeCertificate(ctx context.Context, cert *Certificate, reason uint) error {
+	if a.issuedInternally(cert.Domain) {
+		return fmt.Errorf("certificates of the internal CA can't be revoked, it publishes no revocation lists")
+	}
+
+	client, err := a.clientFor(cert.Domain)
+	if err != nil {
+		return err
+	}
+	return client.RevokeCertificate(ctx, cert, reason)
+}
+
+func (a *ACMEClients) RequestCompanion(ctx context.Context, domain, keyType string) (*Certificate, error) {
+	if a.issuedInternally(domain) {
+		cert, err := a.issueInternal(domain, getKeyType(keyType))
+		if err != nil {
+			return nil, err
+		}
+		if err := a.fallback.saveCompanion(ctx, cert); err != nil {
+			return nil, err
+		}
+		return cert, nil
+	}
+
+	client, err := a.clientFor(domain)
+	if err != nil {
+		return nil, err
+	}
+	return client.RequestCompanion(ctx, domain, keyType)
+}
+
+// DeactivateStaleOrders deactivates stale orders with one client per CA,
+// each handling the orders placed with its CA
+func (a *ACMEClients) DeactivateStaleOrders(ctx context.Context, maxAge time.Duration) (int, error) {
+	a.mu.Lock()
+	perCA := make(map[string]*ACMEClient)
+	for key, client := range a.clients {
+		perCA[key.caDirURL] = client
+	}
+	a.mu.Unlock()
+
+	total := 0
+	var firstErr error
+	for _, client := range perCA {
+		n, err := client.DeactivateStaleOrders(ctx, maxAge)
+		total += n
+		if err != nil && firstErr == nil {
+			firstErr = err
+		}
+	}
+	return total, firstErr
+}
+
+// LoadCertificate, SaveChain, SaveCertificate, WriteOutputs, RestoreBackup and
+// RollbackCertificate only touch storage, which all clients share
+
+func (a *ACMEClients) LoadCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	return a.fallback.LoadCertificate(ctx, domain)
+}
+
+func (a *ACMEClients) SaveChain(ctx context.Context, cert *Certificate) error {
+	return a.fallback.SaveChain(ctx, cert)
+}
+
+func (a *ACMEClients) SaveCertificate(ctx context.Context, cert *Certificate) error {
+	return a.fallback.SaveCertificate(ctx, cert)
+}
+
+func (a *ACMEClients) WriteOutputs(cert *Certificate) error {
+	return a.fallback.WriteOutputs(cert)
+}
+
+func (a *ACMEClients) RestoreBackup(ctx context.Context, domain string) (*Certificate, error) {
+	return a.fallback.RestoreBackup(ctx, domain)
+}
+
+func (a *ACMEClients) RollbackCertificate(ctx context.Context, current *Certificate) (*Certificate, error) {
+	return a.fallback.RollbackCertificate(ctx, current)
+}
+
+// ownsOrder reports whether an order URL belongs to the client's CA
+func (c *ACMEClient) ownsOrder(orderURL string) bool {
+	order, err := url.Parse(orderURL)
+	if err != nil {
+		return false
+	}
+	directory, err := url.Parse(c.caDirURL)
+	if err != nil {
+		return false
+	}
+	return order.Host == directory.Host
+}
+
+// domainKeyType returns the key type new keys for domain are generated with
+func (c *ACMEClient) domainKeyType(domain string) certcrypto.KeyType {
+	if c.keyTypeFor != nil {
+		if keyType := c.keyTypeFor(domain); keyType != "" {
+			return getKeyType(keyType)
+		}
+	}
+	return c.keyType
+}
+
+// keyTypeOf returns the key type of a private key, empty for other kinds of key
+func keyTypeOf(key crypto.PrivateKey) certcrypto.KeyType {
+	switch key := key.(type) {
+	case *rsa.PrivateKey:
+		switch key.N.BitLen() {
+		case 2048:
+			return certcrypto.RSA2048
+		case 3072:
+			return certcrypto.RSA3072
+		case 4096:
+			return certcrypto.RSA4096
+		case 8192:
+			return certcrypto.RSA8192
+		}
+	case *ecdsa.PrivateKey:
+		switch key.Curve.Params().BitSize {
+		case 256:
+			return certcrypto.EC256
+		case 384:
+			return certcrypto.EC384
+		}
+	}
+	return ""
+}
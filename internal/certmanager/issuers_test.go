@@ -0,0 +1,88 @@
+package certmanager
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEClients_RoutesDomains(t *testing.T) {
+	production := &ACMEClient{caDirURL: "https://acme-v02.api.letsencrypt.org/directory"}
+	dns := &ACMEClient{caDirURL: "https://acme-v02.api.letsencrypt.org/directory"}
+	staging := &ACMEClient{caDirURL: "https://acme-staging-v02.api.letsencrypt.org/directory"}
+
+	clients := &ACMEClients{
+		issuerFor: func(domain string) issuer {
+			switch domain {
+			case "*.example.com":
+				return issuer{production.caDirURL, "dns-01"}
+			case "test.example.com":
+				return issuer{staging.caDirURL, "http-01"}
+			}
+			return issuer{production.caDirURL, "http-01"}
+		},
+		clients: map[issuer]*ACMEClient{
+			{production.caDirURL, "http-01"}: production,
+			{production.caDirURL, "dns-01"}:  dns,
+			{staging.caDirURL, "http-01"}:    staging,
+		},
+		fallback: production,
+	}
+
+	for domain, want := range map[string]*ACMEClient{
+		"example.com":      production,
+		"*.example.com":    dns,
+		"test.example.com": staging,
+	} {
+		got, err := clients.clientFor(domain)
+		require.NoError(t, err)
+		assert.Same(t, want, got, domain)
+	}
+}
+
+func TestACMEClient_OwnsOrder(t *testing.T) {
+	client := &ACMEClient{caDirURL: "https://acme-v02.api.letsencrypt.org/directory"}
+
+	assert.True(t, client.ownsOrder("https://acme-v02.api.letsencrypt.org/acme/order/1/2"))
+	assert.False(t, client.ownsOrder("https://acme-staging-v02.api.letsencrypt.org/acme/order/1/2"))
+}
+
+func TestACMEClient_DomainKeyType(t *testing.T) {
+	client := &ACMEClient{
+		keyType:    certcrypto.RSA2048,
+		keyTypeFor: func(domain string) string { return map[string]string{"ec.example.com": "EC256"}[domain] },
+	}
+
+	assert.Equal(t, certcrypto.EC256, client.domainKeyType("ec.example.com"))
+	assert.Equal(t, certcrypto.RSA2048, client.domainKeyType("example.com"))
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	assert.Equal(t, certcrypto.RSA2048, keyTypeOf(rsaKey))
+	assert.Equal(t, certcrypto.EC256, keyTypeOf(ecKey))
+}
+
+func TestRenewalPolicy_DomainRenewalDays(t *testing.T) {
+	policy := NewRenewalPolicy(30, 0, nil)
+	policy.renewalDaysFor = func(domain string) int {
+		if domain == "short.example.com" {
+			return 10
+		}
+		return 0
+	}
+
+	now := time.Now()
+	cert := &Certificate{ExpiresAt: now.Add(20 * 24 * time.Hour)}
+	assert.True(t, policy.NeedsRenewal("example.com", cert, now))
+	assert.False(t, policy.NeedsRenewal("short.example.com", cert, now))
+	assert.Equal(t, cert.ExpiresAt.Add(-10*24*time.Hour), policy.RenewAt("short.example.com", cert))
+}
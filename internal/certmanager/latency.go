@@ -0,0 +1,124 @@
+package certmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// latencyTransport records how long the CA takes to answer each request, labelled
+// with the ACME endpoint it targets. Fixed endpoints are learned from the
+// directory; per-order resources are recognised by their path.
+type latencyTransport struct {
+	next    http.RoundTripper
+	dirURL  string
+	ca      string
+	metrics *Metrics
+
+	mu        sync.RWMutex
+	endpoints map[string]string // URL -> endpoint, from the directory
+}
+
+func newLatencyTransport(next http.RoundTripper, caDirURL string, metrics *Metrics) *latencyTransport {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+
+	ca := caDirURL
+	if u, err := url.Parse(caDirURL); err == nil && u.Host != "" {
+		ca = u.Host
+	}
+
+	return &latencyTransport{
+		next:      next,
+		dirURL:    caDirURL,
+		ca:        ca,
+		metrics:   metrics,
+		endpoints: make(map[string]string),
+	}
+}
+
+func (l *latencyTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	endpoint := l.endpoint(req.URL)
+
+	start := time.Now()
+	resp, err := l.next.RoundTrip(req)
+	l.metrics.observeACMERequest(l.ca, endpoint, time.Since(start))
+
+	if err == nil && endpoint == "directory" && resp.StatusCode == http.StatusOK {
+		l.learnDirectory(resp)
+	}
+	return resp, err
+}
+
+// learnDirectory maps the directory's endpoint URLs to their names, leaving the
+// response body readable for the caller
+func (l *latencyTransport) learnDirectory(resp *http.Response) {
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxDirectorySize))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if err != nil {
+		return
+	}
+
+	var dir acme.Directory
+	if err := json.Unmarshal(body, &dir); err != nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	for u, name := range map[string]string{
+		dir.NewNonceURL:   "new-nonce",
+		dir.NewAccountURL: "new-account",
+		dir.NewOrderURL:   "new-order",
+		dir.RevokeCertURL: "revoke-cert",
+		dir.KeyChangeURL:  "key-change",
+		dir.RenewalInfo:   "renewal-info",
+	} {
+		if u != "" {
+			l.endpoints[u] = name
+		}
+	}
+}
+
+// endpoint names the ACME resource a request targets. Paths differ between CAs,
+// so per-order resources are matched on the words they conventionally contain.
+func (l *latencyTransport) endpoint(u *url.URL) string {
+	full := u.String()
+	if full == l.dirURL {
+		return "directory"
+	}
+
+	l.mu.RLock()
+	name, known := l.endpoints[full]
+	l.mu.RUnlock()
+	if known {
+		return name
+	}
+
+	path := strings.ToLower(u.Path)
+	switch {
+	case strings.Contains(path, "finalize"):
+		return "finalize"
+	case strings.Contains(path, "chal"):
+		return "challenge"
+	case strings.Contains(path, "authz"):
+		return "authorization"
+	case strings.Contains(path, "cert"):
+		return "certificate"
+	case strings.Contains(path, "order"):
+		return "order"
+	case strings.Contains(path, "acct"), strings.Contains(path, "account"):
+		return "account"
+	}
+	return "other"
+}
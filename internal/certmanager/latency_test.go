@@ -0,0 +1,80 @@
+package certmanager
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/O-tero/traefik-cert-manager/internal/metrics"
+)
+
+func TestLatencyTransport_RecordsPerEndpoint(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/directory" {
+			fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce-plz","newAccount":"%[1]s/reg","newOrder":"%[1]s/submit"}`, server.URL)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	defer server.Close()
+
+	registry := metrics.NewRegistry()
+	transport := newLatencyTransport(nil, server.URL+"/directory", NewMetrics(registry))
+	client := &http.Client{Transport: transport}
+
+	resp, err := client.Get(server.URL + "/directory")
+	require.NoError(t, err)
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	assert.Contains(t, string(body), "newOrder", "directory body must remain readable")
+
+	for _, path := range []string{"/submit", "/finalize/1/2", "/chall-v3/3/abc", "/nonce-plz"} {
+		resp, err := client.Post(server.URL+path, "application/jose+json", nil)
+		require.NoError(t, err)
+		resp.Body.Close()
+	}
+
+	var buf bytes.Buffer
+	registry.Write(&buf)
+	output := buf.String()
+
+	ca := mustHost(t, server.URL)
+	for _, endpoint := range []string{"directory", "new-order", "finalize", "challenge", "new-nonce"} {
+		assert.Contains(t, output, fmt.Sprintf(`certmanager_acme_request_duration_seconds_count{ca=%q,endpoint=%q} 1`, ca, endpoint))
+	}
+}
+
+func TestLatencyTransport_Endpoint(t *testing.T) {
+	transport := newLatencyTransport(nil, "https://acme.example/dir", nil)
+
+	tests := map[string]string{
+		"https://acme.example/dir":                  "directory",
+		"https://acme.example/acme/order/1/2":       "order",
+		"https://acme.example/acme/finalize/1/2":    "finalize",
+		"https://acme.example/acme/authz-v3/123":    "authorization",
+		"https://acme.example/acme/chall-v3/123/ab": "challenge",
+		"https://acme.example/acme/cert/fa23":       "certificate",
+		"https://acme.example/acme/acct/99":         "account",
+		"https://acme.example/unknown":              "other",
+	}
+	for raw, want := range tests {
+		u, err := url.Parse(raw)
+		require.NoError(t, err)
+		assert.Equal(t, want, transport.endpoint(u), raw)
+	}
+}
+
+func mustHost(t *testing.T, raw string) string {
+	t.Helper()
+	u, err := url.Parse(raw)
+	require.NoError(t, err)
+	return u.Host
+}
@@ -0,0 +1,127 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// locksDirName holds one lock file per domain with an order in progress
+const locksDirName = ".locks"
+
+// ErrDomainLocked is returned when another process or request is already ordering
+// a certificate for the domain
+var ErrDomainLocked = errors.New("certificate order already in progress")
+
+// DomainLockedError describes who holds a domain's lock
+type DomainLockedError struct {
+	Domain string
+	Holder LockHolder
+}
+
+func (e *DomainLockedError) Error() string {
+	return fmt.Sprintf("%v for %s (held by pid %d on %s since %s)", ErrDomainLocked, e.Domain,
+		e.Holder.PID, e.Holder.Hostname, e.Holder.AcquiredAt.Format(time.RFC3339))
+}
+
+func (e *DomainLockedError) Is(target error) bool {
+	return target == ErrDomainLocked
+}
+
+// LockHolder is the content of a lock file
+type LockHolder struct {
+	PID        int       `json:"pid"`
+	Hostname   string    `json:"hostname"`
+	AcquiredAt time.Time `json:"acquired_at"`
+}
+
+// DomainLocker serialises certificate orders per domain through lock files in the
+// storage path, so the scheduler, API requests and CLI invocations sharing the
+// storage never order the same certificate concurrently
+type DomainLocker struct {
+	dir string
+	ttl time.Duration // locks older than this are considered abandoned
+}
+
+func NewDomainLocker(storagePath string, ttl time.Duration) *DomainLocker {
+	return &DomainLocker{
+		dir: filepath.Join(storagePath, locksDirName),
+		ttl: ttl,
+	}
+}
+
+// Acquire takes the lock for domain. The returned function releases it.
+func (l *DomainLocker) Acquire(domain string) (func(), error) {
+	if err := os.MkdirAll(l.dir, 0755); err != nil {
+		return nil, fmt.Errorf("failed to create lock directory: %w", err)
+	}
+
+	path := filepath.Join(l.dir, storageName(domain)+".lock")
+	hostname, _ := os.Hostname()
+	holder := LockHolder{PID: os.Getpid(), Hostname: hostname, AcquiredAt: time.Now()}
+
+	// A second attempt follows the removal of a stale lock
+	for attempt := 0; attempt < 2; attempt++ {
+		err := writeLockFile(path, holder)
+		if err == nil {
+			return func() { os.Remove(path) }, nil
+		}
+		if !os.IsExist(err) {
+			return nil, fmt.Errorf("failed to create lock for %s: %w", domain, err)
+		}
+
+		existing, readErr := readLockFile(path)
+		if readErr == nil && !l.isStale(existing, hostname) {
+			return nil, &DomainLockedError{Domain: domain, Holder: existing}
+		}
+		if readErr != nil && !os.IsNotExist(readErr) {
+			// A lock file we can't parse is only broken once it has aged out
+			if info, statErr := os.Stat(path); statErr == nil && time.Since(info.ModTime()) < l.ttl {
+				return nil, &DomainLockedError{Domain: domain, Holder: LockHolder{AcquiredAt: info.ModTime()}}
+			}
+		}
+
+		os.Remove(path)
+	}
+
+	return nil, fmt.Errorf("failed to acquire lock for %s", domain)
+}
+
+// isStale reports whether a lock was abandoned: it has outlived the TTL, or its
+// holder ran on this host and has exited
+func (l *DomainLocker) isStale(holder LockHolder, hostname string) bool {
+	if l.ttl > 0 && time.Since(holder.AcquiredAt) > l.ttl {
+		return true
+	}
+	return holder.Hostname == hostname && holder.PID != os.Getpid() && !processAlive(holder.PID)
+}
+
+func writeLockFile(path string, holder LockHolder) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0644)
+	if err != nil {
+		return err
+	}
+
+	if err := json.NewEncoder(f).Encode(holder); err != nil {
+		f.Close()
+		os.Remove(path)
+		return err
+	}
+	return f.Close()
+}
+
+func readLockFile(path string) (LockHolder, error) {
+	var holder LockHolder
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return holder, err
+	}
+	if err := json.Unmarshal(data, &holder); err != nil {
+		return holder, fmt.Errorf("invalid lock file: %w", err)
+	}
+	return holder, nil
+}
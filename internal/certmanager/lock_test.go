@@ -0,0 +1,148 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainLocker_Exclusive(t *testing.T) {
+	testDir := setupTestDir(t)
+	locker := NewDomainLocker(testDir, time.Hour)
+
+	release, err := locker.Acquire("example.com")
+	require.NoError(t, err)
+
+	_, err = locker.Acquire("example.com")
+	assert.True(t, errors.Is(err, ErrDomainLocked))
+
+	var lockedErr *DomainLockedError
+	require.True(t, errors.As(err, &lockedErr))
+	assert.Equal(t, os.Getpid(), lockedErr.Holder.PID)
+
+	// Other domains are independent
+	releaseOther, err := locker.Acquire("api.example.com")
+	require.NoError(t, err)
+	releaseOther()
+
+	release()
+	release, err = locker.Acquire("example.com")
+	require.NoError(t, err)
+	release()
+}
+
+func TestDomainLocker_BreaksStaleLocks(t *testing.T) {
+	testDir := setupTestDir(t)
+	locker := NewDomainLocker(testDir, time.Hour)
+	require.NoError(t, os.MkdirAll(filepath.Join(testDir, locksDirName), 0755))
+	hostname, _ := os.Hostname()
+
+	// Outlived the TTL
+	path := filepath.Join(testDir, locksDirName, "example.com.lock")
+	require.NoError(t, writeLockFile(path, LockHolder{PID: os.Getpid(), Hostname: "elsewhere", AcquiredAt: time.Now().Add(-2 * time.Hour)}))
+
+	release, err := locker.Acquire("example.com")
+	require.NoError(t, err)
+	release()
+
+	// Held by a process on another host that may still be running
+	require.NoError(t, writeLockFile(path, LockHolder{PID: 1, Hostname: "elsewhere", AcquiredAt: time.Now()}))
+	_, err = locker.Acquire("example.com")
+	assert.ErrorIs(t, err, ErrDomainLocked)
+	os.Remove(path)
+
+	// Held by a process on this host that has exited
+	if processAlive(os.Getpid()) {
+		require.NoError(t, writeLockFile(path, LockHolder{PID: 1 << 22, Hostname: hostname, AcquiredAt: time.Now()}))
+		release, err = locker.Acquire("example.com")
+		require.NoError(t, err)
+		release()
+	}
+}
+
+func TestCertificateManager_SkipsLockedDomain(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		locks:      NewDomainLocker(testDir, time.Hour),
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+	}
+
+	// Another process is ordering example.com
+	release, err := NewDomainLocker(testDir, time.Hour).Acquire("example.com")
+	require.NoError(t, err)
+
+	err = cm.RequestCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrDomainLocked)
+	mockClient.AssertNotCalled(t, "RequestCertificate", "example.com")
+
+	release()
+	mockClient.On("RequestCertificate", "example.com").Return(createTestCertificate("example.com", 90), nil)
+	require.NoError(t, cm.RequestCertificate(context.Background(), "example.com"))
+
+	// The lock is released after the order
+	_, err = os.Stat(filepath.Join(testDir, locksDirName, "example.com.lock"))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCertificateManager_OrderInFlightBlocksOnlyItsDomain(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		locks:      NewDomainLocker(testDir, time.Hour),
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+	}
+
+	started := make(chan struct{})
+	proceed := make(chan struct{})
+	mockClient.On("RequestCertificate", "example.com").Run(func(mock.Arguments) {
+		close(started)
+		<-proceed
+	}).Return(createTestCertificate("example.com", 90), nil).Once()
+	mockClient.On("RequestCertificate", "api.example.com").Return(createTestCertificate("api.example.com", 90), nil)
+
+	done := make(chan error, 1)
+	go func() { done <- cm.RequestCertificate(context.Background(), "example.com") }()
+	<-started
+
+	// While example.com is being ordered, reads and other domains go ahead
+	assert.Empty(t, cm.ListCertificates())
+	assert.NotNil(t, cm.CheckCertificateHealth())
+	require.NoError(t, cm.RequestCertificate(context.Background(), "api.example.com"))
+
+	// and a second order for example.com is refused instead of placed twice
+	err := cm.RenewCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrDomainLocked)
+
+	close(proceed)
+	require.NoError(t, <-done)
+	mockClient.AssertNumberOfCalls(t, "RequestCertificate", 2)
+
+	cert, err := cm.GetCertificate("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, "example.com", cert.Domain)
+}
@@ -0,0 +1,139 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// maintenanceFileName records a maintenance window switched on at runtime
+const maintenanceFileName = ".maintenance.json"
+
+// ErrMaintenance is returned for certificate operations refused during maintenance
+var ErrMaintenance = errors.New("maintenance mode is active")
+
+// MaintenanceState describes whether automation is paused and why
+type MaintenanceState struct {
+	Enabled bool      `json:"enabled"`
+	Reason  string    `json:"reason,omitempty"`
+	Since   time.Time `json:"since,omitempty"`
+	Source  string    `json:"source,omitempty"` // config or runtime
+}
+
+// Maintenance is the global switch that pauses issuance, renewal and deployment.
+// The configuration can hold it on permanently; at runtime it is toggled through a
+// file in the storage path, so the CLI and the management API of a running daemon
+// share one state that also survives restarts.
+type Maintenance struct {
+	path       string
+	fromConfig bool
+	metrics    *Metrics
+}
+
+func NewMaintenance(storagePath string, fromConfig bool, metrics *Metrics) *Maintenance {
+	return &Maintenance{
+		path:       filepath.Join(storagePath, maintenanceFileName),
+		fromConfig: fromConfig,
+		metrics:    metrics,
+	}
+}
+
+// State returns the current maintenance state. The configuration takes precedence
+// over the runtime switch.
+func (m *Maintenance) State() MaintenanceState {
+	state := MaintenanceState{}
+	if m.fromConfig {
+		state = MaintenanceState{Enabled: true, Reason: "enabled in configuration", Source: "config"}
+	} else if data, err := os.ReadFile(m.path); err == nil {
+		// An unreadable file still pauses automation: failing open would defeat a freeze
+		state = MaintenanceState{Enabled: true, Source: "runtime"}
+		json.Unmarshal(data, &state)
+		state.Enabled = true
+		state.Source = "runtime"
+	}
+
+	m.metrics.setMaintenance(state.Enabled)
+	return state
+}
+
+// Enable switches maintenance mode on at runtime
+func (m *Maintenance) Enable(reason string) error {
+	state := MaintenanceState{Enabled: true, Reason: reason, Since: time.Now(), Source: "runtime"}
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode maintenance state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(m.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	tmp := m.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write maintenance state: %w", err)
+	}
+	if err := os.Rename(tmp, m.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write maintenance state: %w", err)
+	}
+
+	m.metrics.setMaintenance(true)
+	return nil
+}
+
+// Disable switches the runtime maintenance mode off. It can't override the
+// configuration.
+func (m *Maintenance) Disable() error {
+	if m.fromConfig {
+		return fmt.Errorf("maintenance mode is enabled in the configuration (app.maintenance)")
+	}
+	if err := os.Remove(m.path); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to clear maintenance state: %w", err)
+	}
+
+	m.metrics.setMaintenance(false)
+	return nil
+}
+
+// MaintenanceState returns whether certificate automation is paused
+func (cm *CertificateManager) MaintenanceState() MaintenanceState {
+	if cm.maintenance == nil {
+		return MaintenanceState{}
+	}
+	return cm.maintenance.State()
+}
+
+// SetMaintenance switches maintenance mode on or off
+func (cm *CertificateManager) SetMaintenance(enabled bool, reason string) error {
+	if cm.maintenance == nil {
+		return fmt.Errorf("maintenance mode is not available")
+	}
+
+	if !enabled {
+		if err := cm.maintenance.Disable(); err != nil {
+			return err
+		}
+		cm.logger.Printf("Maintenance mode disabled, certificate automation resumed")
+		return nil
+	}
+
+	if err := cm.maintenance.Enable(reason); err != nil {
+		return err
+	}
+	cm.logger.Printf("Maintenance mode enabled, certificate automation paused (reason: %s)", reason)
+	return nil
+}
+
+// checkMaintenance refuses certificate operations while maintenance mode is active
+func (cm *CertificateManager) checkMaintenance() error {
+	state := cm.MaintenanceState()
+	if !state.Enabled {
+		return nil
+	}
+	if state.Reason != "" {
+		return fmt.Errorf("%w: %s", ErrMaintenance, state.Reason)
+	}
+	return ErrMaintenance
+}
@@ -0,0 +1,64 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMaintenance_RuntimeSwitch(t *testing.T) {
+	testDir := setupTestDir(t)
+
+	m := NewMaintenance(testDir, false, nil)
+	assert.False(t, m.State().Enabled)
+
+	require.NoError(t, m.Enable("change freeze"))
+	state := NewMaintenance(testDir, false, nil).State()
+	assert.True(t, state.Enabled, "state must be shared through the storage path")
+	assert.Equal(t, "change freeze", state.Reason)
+	assert.Equal(t, "runtime", state.Source)
+
+	require.NoError(t, m.Disable())
+	assert.False(t, m.State().Enabled)
+}
+
+func TestMaintenance_ConfigCannotBeDisabledAtRuntime(t *testing.T) {
+	m := NewMaintenance(setupTestDir(t), true, nil)
+
+	assert.True(t, m.State().Enabled)
+	assert.Equal(t, "config", m.State().Source)
+	assert.Error(t, m.Disable())
+}
+
+func TestCertificateManager_PausedDuringMaintenance(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cm := &CertificateManager{
+		config:      cfg,
+		acmeClient:  mockClient,
+		maintenance: NewMaintenance(testDir, false, nil),
+		logger:      logger,
+		certs:       make(map[string]*Certificate),
+	}
+
+	require.NoError(t, cm.SetMaintenance(true, "incident 42"))
+
+	err := cm.RequestCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrMaintenance)
+	assert.Contains(t, err.Error(), "incident 42")
+	assert.NoError(t, cm.ProcessAllDomains(context.Background()))
+	mockClient.AssertNotCalled(t, "RequestCertificate", "example.com")
+
+	require.NoError(t, cm.SetMaintenance(false, ""))
+	mockClient.On("RequestCertificate", "example.com").Return(createTestCertificate("example.com", 90), nil)
+	assert.NoError(t, cm.RequestCertificate(context.Background(), "example.com"))
+}
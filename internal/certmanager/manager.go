@@ -1,122 +1,966 @@
 package certmanager
 
 import (
+	"bytes"
 	"context"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
 	"fmt"
 	"log"
 	"os"
 	"path/filepath"
+	"slices"
+	"strings"
 	"sync"
 	"time"
 
 	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/deploy"
+	"github.com/O-tero/traefik-cert-manager/internal/encryption"
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+	"github.com/O-tero/traefik-cert-manager/internal/inventory"
+	"github.com/O-tero/traefik-cert-manager/internal/metadata"
+	"github.com/O-tero/traefik-cert-manager/internal/metrics"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/O-tero/traefik-cert-manager/internal/report"
+	"github.com/O-tero/traefik-cert-manager/internal/resolver"
+	"github.com/O-tero/traefik-cert-manager/internal/switchhook"
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
 )
 
+// precheckTimeout bounds the DNS lookup made before ordering a certificate
+const precheckTimeout = 15 * time.Second
+
 // ACMEClientInterface defines the interface for ACME client methods used by CertificateManager
 type ACMEClientInterface interface {
-	RequestCertificate(domain string) (*Certificate, error)
-	RenewCertificate(cert *Certificate) (*Certificate, error)
-	LoadCertificate(domain string) (*Certificate, error)
+	RequestCertificate(ctx context.Context, domain string) (*Certificate, error)
+	RenewCertificate(ctx context.Context, cert *Certificate) (*Certificate, error)
+	RevokeCertificate(ctx context.Context, cert *Certificate, reason uint) error
+	DeactivateStaleOrders(ctx context.Context, maxAge time.Duration) (int, error)
+	LoadCertificate(ctx context.Context, domain string) (*Certificate, error)
+	SaveChain(ctx context.Context, cert *Certificate) error
+	SaveCertificate(ctx context.Context, cert *Certificate) error
 }
 
 type CertificateManager struct {
-	config     *config.Config
-	acmeClient ACMEClientInterface
-	logger     *log.Logger
-	mu         sync.RWMutex
-	certs      map[string]*Certificate
+	config         *config.Config
+	acmeClient     ACMEClientInterface
+	notifier       notify.Notifier
+	throttle       *notify.Throttle // nil sends every notification immediately
+	storageMonitor *StorageMonitor
+	chainMonitor   *ChainMonitor
+	driftMonitor   *DriftMonitor
+	ledger         *IssuanceLedger
+	usage          *UsageLedger // nil disables per-domain usage accounting
+	chainFetcher   *ChainFetcher
+	stapler        *OCSPStapler // nil caches no OCSP responses
+	internalCA     *InternalCA
+	trustRoots     *x509.CertPool // nil uses the system trust store
+	hooks          *hooks.Runner
+	deployer       *deploy.Deployer
+	switchHooks    *switchhook.Caller    // nil calls no switch webhooks
+	resolver       resolver.Resolver     // nil skips validation pre-checks
+	diagnoser      *HTTP01Diagnoser      // nil skips failure diagnosis
+	locks          *DomainLocker         // nil disables per-domain order locks
+	maintenance    *Maintenance          // nil never pauses automation
+	holds          *HoldStore            // nil holds no domain
+	wireDebug      *WireDebug            // nil logs no ACME exchanges
+	renewalPolicy  *RenewalPolicy        // nil renews as soon as a certificate needs renewal
+	notBeforeSkew  time.Duration         // tolerated clock skew in the NotBefore of new certificates
+	inventory      *inventory.Reconciler // nil disables CMDB reconciliation
+	history        *metadata.Store       // nil keeps no history database
+	report         *report.Publisher     // nil sends no weekly report
+	failures       failureLog            // failures of the current run
+	metrics        *Metrics              // nil records no metrics
+	logger         *log.Logger
+	mu             sync.RWMutex
+	certs          map[string]*Certificate
+	unmanaged      map[string]*Certificate // on disk but not configured, discovered or adopted
+	adopted        map[string]bool
+	discovered     map[string][]config.Domain // discovery source -> domains
+	ordering       map[string]time.Time       // domains with an order in flight in this process, and since when
 }
 
-func NewCertificateManager(cfg *config.Config, logger *log.Logger) (*CertificateManager, error) {
+// NewCertificateManager builds a manager whose metrics are registered with
+// registry. A nil registry keeps them in a registry of the manager's own.
+func NewCertificateManager(cfg *config.Config, registry *metrics.Registry, logger *log.Logger) (*CertificateManager, error) {
 	if logger == nil {
 		logger = log.New(os.Stdout, "[CertManager] ", log.LstdFlags)
 	}
+	if registry == nil {
+		registry = metrics.NewRegistry()
+	}
+	managerMetrics := NewMetrics(registry)
+
+	envelope, err := encryption.New(cfg.Certificates.Encryption)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up key encryption: %w", err)
+	}
+	if envelope != nil {
+		logger.Printf("Private keys are encrypted at rest using %s", envelope.Provider())
+	}
+
+	accountSigner, err := encryption.NewSigner(cfg.ACME.AccountKeyProvider, cfg.ACME.AccountKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up the ACME account key: %w", err)
+	}
+	if accountSigner != nil {
+		logger.Printf("ACME requests are signed with an account key held by %s", cfg.ACME.AccountKeyProvider)
+	}
+
+	retryBackoff, err := cfg.GetRetryBackoff()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACME retry backoff: %w", err)
+	}
+	orderTimeout, err := cfg.GetOrderTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid ACME order timeout: %w", err)
+	}
+
+	var rootCAs *x509.CertPool
+	if cfg.ACME.CACert != "" {
+		if rootCAs, err = LoadRootCAs(cfg.ACME.CACert); err != nil {
+			return nil, fmt.Errorf("invalid acme.ca_cert: %w", err)
+		}
+	}
+
+	var dns01Solver ChallengeSolver
+	if cfg.ACME.DNS01Webhook.Enabled() {
+		if dns01Solver, err = NewWebhookSolver(cfg.ACME.DNS01Webhook); err != nil {
+			return nil, fmt.Errorf("invalid acme.dns01_webhook: %w", err)
+		}
+	}
+
+	validity, caValidity, err := cfg.GetInternalCAValidity()
+	if err != nil {
+		return nil, fmt.Errorf("invalid internal CA validity: %w", err)
+	}
+	internalCA := NewInternalCA(cfg.Certificates.StoragePath, cfg.Certificates.InternalCA.CommonName, validity, caValidity, envelope, logger)
+
+	// Set once built; the ACME clients call back into it before switching certificates
+	var cm *CertificateManager
 
 	acmeConfig := ACMEConfig{
-		CADirURL:    cfg.ACME.CADirURL,
-		Email:       cfg.ACME.Email,
-		KeyType:     cfg.ACME.KeyType,
-		StoragePath: cfg.Certificates.StoragePath,
-		Logger:      logger,
+		CADirURL:         cfg.ACME.CADirURL,
+		Email:            cfg.ACME.Email,
+		KeyType:          cfg.ACME.KeyType,
+		StoragePath:      cfg.Certificates.StoragePath,
+		ArchiveRetention: cfg.Certificates.Archive.Retention,
+		CompressArchives: cfg.Certificates.Archive.Compression == "gzip",
+		Encryption:       envelope,
+		AccountSigner:    accountSigner,
+		CombinedPEM:      cfg.Certificates.CombinedPEM,
+		RetryAttempts:    cfg.ACME.RetryAttempts,
+		RetryBackoff:     retryBackoff,
+		OrderTimeout:     orderTimeout,
+		ProfileFor:       cfg.ProfileFor,
+		KeyTypeFor:       cfg.KeyTypeFor,
+		MustStapleFor:    cfg.MustStapleFor,
+		CSRFor:           cfg.CSRFor,
+		ExternalKeyFor:   cfg.ExternalKeyFor,
+		SANsFor: func(domain string) []string {
+			return cm.sansFor(domain)
+		},
+		Challenge:      cfg.ACME.Challenge,
+		HTTP01Address:  cfg.ACME.HTTP01.ListenAddress,
+		HTTP01Header:   cfg.ACME.HTTP01.ProxyHeader,
+		DNS01Command:   cfg.ACME.DNS01Command,
+		DNS01Solver:    dns01Solver,
+		PreferredChain: cfg.ACME.PreferredChain,
+		RootCAs:        rootCAs,
+		InternalCA:     internalCA,
+		InternalCAFor: func(domain string) bool {
+			return cfg.IssuerFor(domain) == config.IssuerInternalCA
+		},
+		BeforeSwitch: func(ctx context.Context, cert *Certificate) error {
+			return cm.beforeSwitch(ctx, cert)
+		},
+		Metrics: managerMetrics,
+		Logger:  logger,
+	}
+
+	wireDebug := NewWireDebug(cfg.Certificates.StoragePath, cfg.ACME.WireLog.Domains)
+	acmeConfig.wire = newWireLogger(wireDebug, cfg.WireLogPath(), int64(cfg.ACME.WireLog.MaxSizeMB)<<20, cfg.ACME.WireLog.MaxBackups)
+	if domains := wireDebug.Domains(); len(domains) > 0 {
+		logger.Printf("Logging ACME exchanges of %s to %s", strings.Join(domains, ", "), cfg.WireLogPath())
 	}
 
-	acmeClient, err := NewACMEClient(acmeConfig)
+	acmeClient, err := NewACMEClients(acmeConfig, func(domain string) (string, string) {
+		return cfg.CADirURLFor(domain), cfg.ChallengeFor(domain)
+	})
 	if err != nil {
 		return nil, fmt.Errorf("failed to create ACME client: %w", err)
 	}
 
-	cm := &CertificateManager{
-		config:     cfg,
-		acmeClient: acmeClient,
-		logger:     logger,
-		certs:      make(map[string]*Certificate),
+	dedupWindow, err := cfg.GetDedupWindow()
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification dedup window: %w", err)
+	}
+	digestTime, err := cfg.GetDigestTime()
+	if err != nil {
+		return nil, fmt.Errorf("invalid notification digest time: %w", err)
+	}
+
+	// Notices are addressed per domain, then deduplicated or held for the
+	// digest, and accounted to their domain when actually sent
+	email, err := notify.NewEmailNotifier(cfg.Notification, cfg.Email, logger)
+	if err != nil {
+		return nil, fmt.Errorf("failed to set up email notifications: %w", err)
+	}
+	var history *metadata.Store
+	var sender notify.Notifier = email
+	if cfg.Database.Enabled() {
+		retention, err := cfg.GetDatabaseRetention()
+		if err != nil {
+			return nil, fmt.Errorf("invalid database retention: %w", err)
+		}
+		ctx, cancel := context.WithTimeout(context.Background(), historyTimeout)
+		history, err = metadata.Open(ctx, cfg.Database, cfg.DatabaseDSN(), retention, logger)
+		cancel()
+		if err != nil {
+			return nil, fmt.Errorf("failed to open the history database: %w", err)
+		}
+		sender = &historyNotifier{Notifier: email, history: history, logger: logger}
+	}
+	usage := NewUsageLedger(cfg.Certificates.StoragePath, managerMetrics, logger)
+	throttle := notify.NewThrottle(&usageNotifier{
+		Notifier: sender,
+		usage:    usage,
+	}, dedupWindow, cfg.Notification.Digest, digestTime, cfg.Certificates.StoragePath, logger)
+	holds := NewHoldStore(cfg.Certificates.StoragePath)
+	notifier := &heldNotifier{
+		Notifier: &notify.DomainNotifier{Notifier: throttle, RecipientsFor: cfg.RecipientsFor},
+		holds:    holds,
+	}
+	minFreeMB, minFreeInodes := cfg.GetMinFreeSpace()
+	storageMonitor := NewStorageMonitor(cfg.Certificates.StoragePath, minFreeMB, minFreeInodes, notifier, managerMetrics, logger)
+
+	hookTimeout, err := cfg.GetHookTimeout()
+	if err != nil {
+		return nil, fmt.Errorf("invalid hook timeout: %w", err)
+	}
+
+	var dnsResolver resolver.Resolver
+	if !cfg.DNS.DisablePrecheck {
+		dnsResolver, err = resolver.New(cfg.DNS)
+		if err != nil {
+			return nil, fmt.Errorf("failed to set up DNS resolver: %w", err)
+		}
+		if len(cfg.DNS.DoHResolvers) > 0 {
+			logger.Printf("Validation pre-checks use DNS-over-HTTPS: %s", strings.Join(cfg.DNS.DoHResolvers, ", "))
+		}
 	}
 
-	if err := cm.loadExistingCertificates(); err != nil {
+	lockTTL, err := cfg.GetLockTTL()
+	if err != nil {
+		return nil, fmt.Errorf("invalid lock TTL: %w", err)
+	}
+
+	renewalJitter, err := cfg.GetRenewalJitter()
+	if err != nil {
+		return nil, fmt.Errorf("invalid renewal jitter: %w", err)
+	}
+	renewalHours, err := cfg.GetRenewalHours()
+	if err != nil {
+		return nil, fmt.Errorf("invalid renewal hours: %w", err)
+	}
+	notBeforeSkew, err := cfg.GetNotBeforeSkew()
+	if err != nil {
+		return nil, fmt.Errorf("invalid NotBefore skew: %w", err)
+	}
+
+	var inventoryReconciler *inventory.Reconciler
+	if cfg.Inventory.Enabled() {
+		inventoryTimeout, err := cfg.GetInventoryTimeout()
+		if err != nil {
+			return nil, fmt.Errorf("invalid inventory timeout: %w", err)
+		}
+		inventoryReconciler = inventory.NewReconciler(cfg.Inventory, inventoryTimeout, cfg.Certificates.StoragePath, logger)
+	}
+
+	var weeklyReport *report.Publisher
+	if cfg.Report.Enabled {
+		day, at, err := cfg.GetReportSchedule()
+		if err != nil {
+			return nil, fmt.Errorf("invalid report schedule: %w", err)
+		}
+		weeklyReport = report.NewPublisher(cfg.Report, day, at, sender, cfg.Certificates.StoragePath, logger)
+	}
+
+	ledgerPath := cfg.ACME.IssuanceLedger
+	if ledgerPath == "" {
+		ledgerPath = filepath.Join(cfg.Certificates.StoragePath, ledgerFileName)
+	}
+	ledger := NewIssuanceLedger(ledgerPath, cfg.ACME.DuplicateLimit, managerMetrics, logger)
+
+	renewalPolicy := NewRenewalPolicy(cfg.Certificates.RenewalDays, renewalJitter, renewalHours)
+	renewalPolicy.renewBefore = cfg.RenewBeforeFor
+	renewalPolicy.renewalDaysFor = cfg.RenewalDaysFor
+	renewalPolicy.hoursFor = cfg.RenewalHoursFor
+	renewalPolicy.profileFor = cfg.ProfileFor
+
+	var stapler *OCSPStapler
+	if cfg.Certificates.OCSPStapling.Enabled {
+		timeout, err := cfg.GetOCSPStaplingTimeout()
+		if err != nil {
+			return nil, fmt.Errorf("invalid OCSP stapling timeout: %w", err)
+		}
+		stapler = NewOCSPStapler(cfg.Certificates.StoragePath, timeout)
+	}
+
+	cm = &CertificateManager{
+		config:         cfg,
+		acmeClient:     acmeClient,
+		notifier:       notifier,
+		throttle:       throttle,
+		storageMonitor: storageMonitor,
+		chainMonitor:   NewChainMonitor(cfg.Certificates.ChainWarningDays, notifier, managerMetrics, logger),
+		driftMonitor:   NewDriftMonitor(notifier, managerMetrics, logger),
+		ledger:         ledger,
+		usage:          usage,
+		hooks:          hooks.NewRunner(cfg.Hooks, hookTimeout, logger),
+		deployer:       deploy.NewDeployer(logger),
+		switchHooks:    switchhook.NewCaller(logger),
+		resolver:       dnsResolver,
+		diagnoser:      NewHTTP01Diagnoser(cfg.ACME.HTTP01Prober, cfg.ACME.HTTP01.ListenAddress, logger),
+		locks:          NewDomainLocker(cfg.Certificates.StoragePath, lockTTL),
+		maintenance:    NewMaintenance(cfg.Certificates.StoragePath, cfg.App.Maintenance, managerMetrics),
+		holds:          holds,
+		wireDebug:      wireDebug,
+		renewalPolicy:  renewalPolicy,
+		notBeforeSkew:  notBeforeSkew,
+		inventory:      inventoryReconciler,
+		history:        history,
+		report:         weeklyReport,
+		chainFetcher:   NewChainFetcher(30 * time.Second),
+		stapler:        stapler,
+		internalCA:     internalCA,
+		metrics:        managerMetrics,
+		logger:         logger,
+		certs:          make(map[string]*Certificate),
+		unmanaged:      make(map[string]*Certificate),
+		adopted:        make(map[string]bool),
+		discovered:     make(map[string][]config.Domain),
+	}
+
+	if state := cm.MaintenanceState(); state.Enabled {
+		logger.Printf("Maintenance mode is active (%s), certificate automation is paused", state.Reason)
+	}
+
+	if err := cm.loadAdopted(); err != nil {
+		logger.Printf("Warning: failed to load adopted domains: %v", err)
+	}
+
+	if err := cm.writeWWWRedirects(); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+
+	if err := cm.loadExistingCertificates(context.Background()); err != nil {
 		logger.Printf("Warning: failed to load existing certificates: %v", err)
 	}
 
+	if err := cm.writeDualKeyConfig(); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+
+	if err := cm.writeTraefikTLS(); err != nil {
+		logger.Printf("Warning: %v", err)
+	}
+
 	return cm, nil
 }
 
-func (cm *CertificateManager) RequestCertificate(domain string) error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+func (cm *CertificateManager) RequestCertificate(ctx context.Context, domain string) error {
+	ctx, span := tracing.Start(ctx, "certificate.obtain", tracing.String("domain", domain))
+	defer span.End()
 
+	cert, replaced, err := cm.requestCertificate(ctx, domain)
+	switch {
+	case errors.Is(err, ErrDomainLocked), errors.Is(err, ErrMaintenance), errors.Is(err, ErrDomainHeld),
+		errors.Is(err, ErrDomainUnmanaged):
+		// The holder of the lock reports the outcome of its own order, and a
+		// paused order or one for a removed domain is not a failure
+	case err != nil:
+		span.RecordError(err)
+		cm.recordFailure(domain, "obtain", err)
+		cm.notifyFailure(domain, "obtain", err)
+		cm.runHooks(ctx, hooks.EventOnFailure, domain, nil, err)
+	case cert != nil && replaced:
+		if err = cm.afterSwitch(ctx, domain, cert); err != nil {
+			span.RecordError(err)
+			break
+		}
+		cm.afterIssue(ctx, domain)
+		cm.deployCertificate(ctx, domain, cert)
+		cm.runHooks(ctx, hooks.EventPostRenew, domain, cert, nil)
+	case cert != nil:
+		cm.publishTraefikTLS()
+		if err = cm.afterSwitch(ctx, domain, cert); err != nil {
+			span.RecordError(err)
+			break
+		}
+		cm.afterIssue(ctx, domain)
+		cm.deployCertificate(ctx, domain, cert)
+		cm.runHooks(ctx, hooks.EventPostIssue, domain, cert, nil)
+	}
+	return err
+}
+
+// requestCertificate obtains a certificate unless a valid one exists. It returns the
+// new certificate, or nil if none was needed, and whether it replaced an older one.
+func (cm *CertificateManager) requestCertificate(ctx context.Context, domain string) (*Certificate, bool, error) {
 	cm.logger.Printf("Requesting certificate for domain: %s", domain)
 
-	if cert, exists := cm.certs[domain]; exists {
-		if !cert.IsExpired() && !cert.NeedsRenewal(cm.config.Certificates.RenewalDays) {
+	// cm.mu only guards the maps; the order itself runs without it
+	cm.mu.Lock()
+	if err := cm.beginOrder(domain); err != nil {
+		cm.mu.Unlock()
+		return nil, false, err
+	}
+	defer cm.endOrder(domain)
+
+	cm.claimUnmanaged(domain)
+	managed := cm.isManaged(domain)
+	existing, replaced := cm.certs[domain]
+	cm.mu.Unlock()
+
+	if replaced {
+		missing := cm.missingSANs(domain, existing)
+		switch {
+		case len(missing) > 0:
+			cm.logger.Printf("Certificate for %s doesn't include %s, reissuing", domain, strings.Join(missing, ", "))
+		case !existing.IsExpired() && !cm.renewalDue(domain, existing):
 			cm.logger.Printf("Certificate for %s is still valid, skipping request", domain)
-			return nil
+			return nil, false, nil
+		default:
+			cm.logger.Printf("Certificate for %s needs renewal", domain)
 		}
-		cm.logger.Printf("Certificate for %s needs renewal", domain)
 	}
 
-	cert, err := cm.acmeClient.RequestCertificate(domain)
+	if err := cm.checkMaintenance(); err != nil {
+		return nil, false, fmt.Errorf("refusing to request certificate for %s: %w", domain, err)
+	}
+	if err := cm.checkHold(domain); err != nil {
+		return nil, false, fmt.Errorf("refusing to request certificate for %s: %w", domain, err)
+	}
+
+	if err := cm.ensureStorageCapacity(); err != nil {
+		return nil, false, fmt.Errorf("refusing to request certificate for %s: %w", domain, err)
+	}
+
+	if err := cm.checkDuplicateLimit(domain); err != nil {
+		return nil, false, fmt.Errorf("refusing to request certificate for %s: %w", domain, err)
+	}
+
+	if err := cm.precheckDomain(ctx, domain); err != nil {
+		return nil, false, fmt.Errorf("refusing to request certificate for %s: %w", domain, err)
+	}
+
+	release, err := cm.lockDomain(domain)
+	if err != nil {
+		return nil, false, err
+	}
+	defer release()
+
+	cert, err := cm.acmeClient.RequestCertificate(ctx, domain)
+	cm.recordOrder(domain, err)
 	if err != nil {
 		cm.logger.Printf("Failed to request certificate for %s: %v", domain, err)
-		return fmt.Errorf("failed to request certificate for %s: %w", domain, err)
+		return nil, false, fmt.Errorf("failed to request certificate for %s: %w", domain, cm.diagnoseFailure(ctx, domain, err))
 	}
 
+	if err := cm.checkNotBefore(ctx, cert, existing); err != nil {
+		return nil, false, err
+	}
+
+	cm.mu.Lock()
+	if managed && !cm.isManaged(domain) {
+		err := cm.removedDuringOrder(domain, cert)
+		cm.mu.Unlock()
+		return nil, false, err
+	}
 	cm.certs[domain] = cert
+	cm.mu.Unlock()
+	cm.recordIssuance(domain, cert)
 
-	cm.logger.Printf("Successfully requested certificate for %s (expires: %s)", 
+	cm.logger.Printf("Successfully requested certificate for %s (expires: %s)",
 		domain, cert.ExpiresAt.Format(time.RFC3339))
 
-	return nil
+	return cert, replaced, nil
 }
 
-func (cm *CertificateManager) RenewCertificate(domain string) error {
-	cm.mu.Lock()
-	defer cm.mu.Unlock()
+func (cm *CertificateManager) RenewCertificate(ctx context.Context, domain string) error {
+	ctx, span := tracing.Start(ctx, "certificate.renew", tracing.String("domain", domain))
+	defer span.End()
 
+	cert, err := cm.renewCertificate(ctx, domain)
+	if err != nil {
+		if !errors.Is(err, ErrDomainLocked) && !errors.Is(err, ErrMaintenance) && !errors.Is(err, ErrDomainHeld) &&
+			!errors.Is(err, ErrDomainUnmanaged) {
+			span.RecordError(err)
+			cm.recordFailure(domain, "renew", err)
+			cm.notifyFailure(domain, "renew", err)
+			cm.runHooks(ctx, hooks.EventOnFailure, domain, nil, err)
+		}
+		return err
+	}
+	if err := cm.afterSwitch(ctx, domain, cert); err != nil {
+		span.RecordError(err)
+		return err
+	}
+	cm.afterIssue(ctx, domain)
+	cm.deployCertificate(ctx, domain, cert)
+	cm.runHooks(ctx, hooks.EventPostRenew, domain, cert, nil)
+	return nil
+}
+
+func (cm *CertificateManager) renewCertificate(ctx context.Context, domain string) (*Certificate, error) {
 	cm.logger.Printf("Renewing certificate for domain: %s", domain)
 
+	cm.mu.Lock()
+	if err := cm.beginOrder(domain); err != nil {
+		cm.mu.Unlock()
+		return nil, err
+	}
+	defer cm.endOrder(domain)
+
 	cert, exists := cm.certs[domain]
+	_, released := cm.unmanaged[domain]
+	cm.mu.Unlock()
 	if !exists {
-		loadedCert, err := cm.acmeClient.LoadCertificate(domain)
+		// Loading a released certificate would quietly manage its domain again
+		if released {
+			return nil, fmt.Errorf("%w: %s", ErrDomainUnmanaged, domain)
+		}
+		// The order in flight keeps the domain from changing while its
+		// certificate is read from disk, so cm.mu is only held to store it
+		loadedCert, err := cm.loadCertificate(ctx, domain)
 		if err != nil {
-			return fmt.Errorf("certificate not found for domain %s: %w", domain, err)
+			return nil, fmt.Errorf("certificate not found for domain %s: %w", domain, err)
 		}
 		cert = loadedCert
+		cm.mu.Lock()
 		cm.certs[domain] = cert
+		cm.mu.Unlock()
+	}
+
+	if err := cm.checkMaintenance(); err != nil {
+		return nil, fmt.Errorf("refusing to renew certificate for %s: %w", domain, err)
+	}
+	if err := cm.checkHold(domain); err != nil {
+		return nil, fmt.Errorf("refusing to renew certificate for %s: %w", domain, err)
 	}
 
-	renewedCert, err := cm.acmeClient.RenewCertificate(cert)
+	if err := cm.ensureStorageCapacity(); err != nil {
+		return nil, fmt.Errorf("refusing to renew certificate for %s: %w", domain, err)
+	}
+
+	if err := cm.checkDuplicateLimit(domain); err != nil {
+		return nil, fmt.Errorf("refusing to renew certificate for %s: %w", domain, err)
+	}
+
+	if err := cm.precheckDomain(ctx, domain); err != nil {
+		return nil, fmt.Errorf("refusing to renew certificate for %s: %w", domain, err)
+	}
+
+	release, err := cm.lockDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	renewedCert, err := cm.acmeClient.RenewCertificate(ctx, cert)
+	cm.recordOrder(domain, err)
 	if err != nil {
 		cm.logger.Printf("Failed to renew certificate for %s: %v", domain, err)
-		return fmt.Errorf("failed to renew certificate for %s: %w", domain, err)
+		return nil, fmt.Errorf("failed to renew certificate for %s: %w", domain, cm.diagnoseFailure(ctx, domain, err))
+	}
+
+	if err := cm.checkNotBefore(ctx, renewedCert, cert); err != nil {
+		return nil, err
 	}
 
+	cm.mu.Lock()
+	if _, managed := cm.certs[domain]; !managed {
+		err := cm.removedDuringOrder(domain, renewedCert)
+		cm.mu.Unlock()
+		return nil, err
+	}
 	cm.certs[domain] = renewedCert
+	cm.mu.Unlock()
+	cm.recordIssuance(domain, renewedCert)
 
-	cm.logger.Printf("Successfully renewed certificate for %s (expires: %s)", 
+	cm.logger.Printf("Successfully renewed certificate for %s (expires: %s)",
 		domain, renewedCert.ExpiresAt.Format(time.RFC3339))
 
+	return renewedCert, nil
+}
+
+// removedDuringOrder keeps a certificate issued for a domain that stopped being
+// managed while its order was in flight as unmanaged, instead of adding the
+// domain back. Callers must hold cm.mu.
+func (cm *CertificateManager) removedDuringOrder(domain string, cert *Certificate) error {
+	cm.keepUnmanaged(domain, cert)
+	cm.logger.Printf("Domain %s was removed while its certificate was being issued; kept the new certificate as unmanaged (expires: %s)",
+		domain, cert.ExpiresAt.Format(time.RFC3339))
+	return fmt.Errorf("%w: %s was removed while its certificate was being issued", ErrDomainUnmanaged, domain)
+}
+
+// runHooks executes the configured hooks for a certificate event. Hook failures
+// are logged by the runner and never undo the certificate operation.
+func (cm *CertificateManager) runHooks(ctx context.Context, event hooks.Event, domain string, cert *Certificate, cause error) {
+	cm.recordHistory(event, domain, cert, cause)
+	if cm.hooks == nil {
+		return
+	}
+
+	certPath, keyPath := cm.GetCertificatePaths(domain)
+	hc := hooks.Context{
+		Domain:        domain,
+		CertPath:      certPath,
+		KeyPath:       keyPath,
+		IssuerPath:    filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+".issuer.crt"),
+		FullChainPath: filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+fullChainSuffix),
+		Err:           cause,
+	}
+	if cert != nil {
+		hc.ExpiresAt = cert.ExpiresAt
+	}
+
+	domainConfig, _ := cm.domainConfig(domain)
+	hc.Service = domainConfig.Service
+
+	cm.hooks.Run(ctx, event, domainConfig.Hooks, hc)
+}
+
+// deployCertificate copies a new certificate to the domain's remote targets. Failed
+// deployments are reported but leave the locally stored certificate in place.
+func (cm *CertificateManager) deployCertificate(ctx context.Context, domain string, cert *Certificate) {
+	if cm.deployer == nil {
+		return
+	}
+
+	domainConfig, _ := cm.domainConfig(domain)
+	if len(domainConfig.Deploy) == 0 {
+		return
+	}
+
+	err := cm.deployer.Deploy(ctx, domainConfig.Deploy, deploy.Bundle{
+		Domain:      domain,
+		Certificate: cert.Certificate,
+		PrivateKey:  cert.PrivateKey,
+		IssuerCert:  cert.IssuerCert,
+	})
+	if err == nil {
+		return
+	}
+	cm.recordFailure(domain, "deploy", err)
+	if cm.notifier == nil {
+		return
+	}
+
+	msg := notify.Message{
+		Level:   notify.LevelCritical,
+		Event:   notify.EventDeployFailure,
+		Domain:  domain,
+		Subject: fmt.Sprintf("Failed to deploy certificate for %s", domain),
+		Body: fmt.Sprintf("A new certificate for %s was issued but could not be deployed to all remote targets.\n\n"+
+			"Error: %v\n\nThe remote hosts may still serve the previous certificate.", domain, err),
+	}
+	if sendErr := cm.notifier.Send(msg); sendErr != nil {
+		cm.logger.Printf("Failed to send deployment failure alert: %v", sendErr)
+	}
+}
+
+// domainConfig returns the configured or discovered entry a domain or alias belongs to
+func (cm *CertificateManager) domainConfig(domain string) (config.Domain, bool) {
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	candidates := append([]config.Domain{}, cm.config.Domains...)
+	for _, sourceDomains := range cm.discovered {
+		candidates = append(candidates, sourceDomains...)
+	}
+
+	for _, d := range candidates {
+		if d.Domain == domain {
+			return d, true
+		}
+		for _, alias := range d.Aliases {
+			if alias == domain {
+				return d, true
+			}
+		}
+	}
+	return config.Domain{}, false
+}
+
+// CheckStorage refreshes storage metrics and alerts operators when the volume runs low
+func (cm *CertificateManager) CheckStorage() {
+	if cm.storageMonitor == nil {
+		return
+	}
+
+	usage, err := cm.storageMonitor.Check()
+	if err != nil {
+		cm.logger.Printf("Failed to check storage usage: %v", err)
+		return
+	}
+
+	cm.logger.Printf("Storage usage: %d MB free of %d MB, %d inodes free",
+		usage.FreeBytes/(1024*1024), usage.TotalBytes/(1024*1024), usage.FreeInodes)
+}
+
+// CheckStorageAccess reports whether certificates can currently be written to storage
+func (cm *CertificateManager) CheckStorageAccess() error {
+	if cm.storageMonitor == nil {
+		return nil
+	}
+	return cm.storageMonitor.Writable()
+}
+
+// CheckChains warns about intermediates and roots in stored chains that expire soon
+func (cm *CertificateManager) CheckChains() []ChainWarning {
+	if cm.chainMonitor == nil {
+		return nil
+	}
+	return cm.chainMonitor.Check(cm.ListCertificates())
+}
+
+// RefreshChain replaces the stored issuer chain of a certificate with the one its CA
+// currently publishes, without a new order or key change. It reports whether the
+// stored chain changed.
+func (cm *CertificateManager) RefreshChain(ctx context.Context, domain string) (bool, error) {
+	cert, err := cm.GetCertificate(domain)
+	if err != nil {
+		return false, err
+	}
+
+	leaf, err := cert.leaf()
+	if err != nil {
+		return false, err
+	}
+
+	fetcher := cm.chainFetcher
+	if fetcher == nil {
+		fetcher = NewChainFetcher(30 * time.Second)
+	}
+
+	chain, err := fetcher.FetchChain(ctx, leaf)
+	if err != nil {
+		return false, fmt.Errorf("failed to fetch chain for %s: %w", domain, err)
+	}
+
+	issuerPEM := encodePEMCertificates(chain)
+	bundle := append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: leaf.Raw}), issuerPEM...)
+	if bytes.Equal(bundle, cert.Certificate) && bytes.Equal(issuerPEM, cert.IssuerCert) {
+		return false, nil
+	}
+
+	refreshed := *cert
+	refreshed.Certificate = bundle
+	refreshed.IssuerCert = issuerPEM
+
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+
+	if err := cm.acmeClient.SaveChain(ctx, &refreshed); err != nil {
+		return false, fmt.Errorf("failed to save refreshed chain for %s: %w", domain, err)
+	}
+	cm.certs[domain] = &refreshed
+
+	cm.logger.Printf("Refreshed issuer chain for %s (%d intermediates)", domain, len(chain))
+	return true, nil
+}
+
+// RefreshChains refreshes the chain of every managed certificate and returns the
+// domains whose chain changed
+func (cm *CertificateManager) RefreshChains(ctx context.Context, domains []string) ([]string, error) {
+	var refreshed []string
+	var errs []error
+
+	for _, domain := range domains {
+		select {
+		case <-ctx.Done():
+			return refreshed, ctx.Err()
+		default:
+		}
+
+		changed, err := cm.RefreshChain(ctx, domain)
+		if err != nil {
+			cm.logger.Printf("Failed to refresh chain for %s: %v", domain, err)
+			errs = append(errs, err)
+			continue
+		}
+		if changed {
+			refreshed = append(refreshed, domain)
+		}
+	}
+
+	if len(errs) > 0 {
+		return refreshed, fmt.Errorf("failed to refresh %d chains: %v", len(errs), errs)
+	}
+	return refreshed, nil
+}
+
+// ensureStorageCapacity prevents issuance from leaving half-written certificate pairs on a full disk
+func (cm *CertificateManager) ensureStorageCapacity() error {
+	if cm.storageMonitor == nil {
+		return nil
+	}
+	return cm.storageMonitor.EnsureCapacity()
+}
+
+// lockDomain takes the storage lock for a domain before ordering its certificate
+func (cm *CertificateManager) lockDomain(domain string) (func(), error) {
+	if cm.locks == nil {
+		return func() {}, nil
+	}
+
+	release, err := cm.locks.Acquire(domain)
+	if err != nil {
+		cm.logger.Printf("Skipping order for %s: %v", domain, err)
+		return nil, err
+	}
+	return release, nil
+}
+
+// beginOrder marks domain as having an order in flight, so a second order for it
+// fails fast instead of being placed twice. Callers must hold cm.mu; endOrder
+// takes it itself.
+func (cm *CertificateManager) beginOrder(domain string) error {
+	if err := cm.checkNotOrdering(domain); err != nil {
+		cm.logger.Printf("Skipping order for %s: %v", domain, err)
+		return err
+	}
+	if cm.ordering == nil {
+		cm.ordering = make(map[string]time.Time)
+	}
+	cm.ordering[domain] = time.Now()
+	return nil
+}
+
+func (cm *CertificateManager) endOrder(domain string) {
+	cm.mu.Lock()
+	defer cm.mu.Unlock()
+	delete(cm.ordering, domain)
+}
+
+// checkNotOrdering refuses changes to a domain whose order is in flight, as the
+// order would overwrite them when it completes. Callers must hold cm.mu.
+func (cm *CertificateManager) checkNotOrdering(domain string) error {
+	if _, ok := cm.ordering[domain]; ok {
+		return fmt.Errorf("%w for %s", ErrDomainLocked, domain)
+	}
+	return nil
+}
+
+// diagnoseFailure probes the HTTP-01 challenge path after a failed order and adds
+// the findings to err, so notifications say whether the CA or the network is at fault
+func (cm *CertificateManager) diagnoseFailure(ctx context.Context, domain string, err error) error {
+	if cm.diagnoser == nil || strings.HasPrefix(domain, "*.") || isTransientACMEError(err) ||
+		cm.config.ChallengeFor(domain) == "dns-01" || ctx.Err() != nil {
+		return err
+	}
+
+	diagnosis, diagErr := cm.diagnoser.Diagnose(ctx, domain)
+	if diagErr != nil {
+		cm.logger.Printf("Failed to diagnose HTTP-01 failure for %s: %v", domain, diagErr)
+		return err
+	}
+	return fmt.Errorf("%w (HTTP-01 diagnosis: %s)", err, diagnosis)
+}
+
+// precheckDomain confirms a domain resolves before an order is placed, so a
+// missing record fails locally instead of counting against the CA's failed
+// validation limit
+func (cm *CertificateManager) precheckDomain(ctx context.Context, domain string) error {
+	if cm.resolver == nil || strings.HasPrefix(domain, "*.") || cm.issuedInternally(domain) {
+		return nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, precheckTimeout)
+	defer cancel()
+
+	cm.recordUsage(domain, UsageCounts{DNSQueries: 1})
+	if _, err := cm.resolver.LookupHost(ctx, domain); err != nil {
+		return fmt.Errorf("validation pre-check failed: %w", err)
+	}
 	return nil
 }
 
+// checkDuplicateLimit refuses issuance that would trip the CA's duplicate certificate limit
+func (cm *CertificateManager) checkDuplicateLimit(domain string) error {
+	if cm.ledger == nil || cm.issuedInternally(domain) {
+		return nil
+	}
+	return cm.ledger.Check(cm.orderSANs(domain))
+}
+
+// duplicateLimitStatus reports a domain whose SAN set reached the duplicate
+// certificate limit, nil otherwise
+func (cm *CertificateManager) duplicateLimitStatus(domain string) *DuplicateLimitStatus {
+	if cm.ledger == nil || cm.issuedInternally(domain) {
+		return nil
+	}
+	limited := cm.ledger.Limited(cm.orderSANs(domain))
+	if limited == nil {
+		return nil
+	}
+	return &DuplicateLimitStatus{Issued: limited.Issued, Limit: limited.Limit, RetryAfter: limited.RetryAfter}
+}
+
+// recordIssuance notes a certificate issued for domain in the run and its SAN
+// set in the issuance ledger
+func (cm *CertificateManager) recordIssuance(domain string, cert *Certificate) {
+	cm.recordIssued(domain)
+	if cm.ledger == nil || cm.issuedInternally(domain) {
+		return
+	}
+	sans := cert.SANs
+	if len(sans) == 0 {
+		sans = cm.orderSANs(domain)
+	}
+	if err := cm.ledger.Record(sans); err != nil {
+		cm.logger.Printf("Warning: failed to record issuance for %s: %v", domain, err)
+	}
+}
+
+// orderSANs returns the SAN set an order for domain requests: the names in
+// the CSR the domain brings, or the domain and its SANs
+func (cm *CertificateManager) orderSANs(domain string) []string {
+	if _, csrFile := cm.config.ExternalKeyFor(domain); csrFile != "" {
+		if csr, err := loadExternalCSR(csrFile, domain); err == nil {
+			return leafSANs(&x509.Certificate{DNSNames: csr.DNSNames, IPAddresses: csr.IPAddresses})
+		}
+	}
+	return append([]string{domain}, cm.sansFor(domain)...)
+}
+
+// sansFor returns the further names the certificate of domain includes, from
+// its configured or discovered entry
+func (cm *CertificateManager) sansFor(domain string) []string {
+	if d, ok := cm.domainConfig(domain); ok && d.Domain == domain {
+		return d.SANs
+	}
+	return nil
+}
+
+// missingSANs returns the SANs of domain its certificate doesn't include yet,
+// e.g. after consolidation added a discovered name to it
+func (cm *CertificateManager) missingSANs(domain string, cert *Certificate) []string {
+	names := certificateNames(domain, cert)
+
+	var missing []string
+	for _, san := range cm.sansFor(domain) {
+		if !slices.Contains(names, strings.ToLower(san)) {
+			missing = append(missing, san)
+		}
+	}
+	return missing
+}
+
 func (cm *CertificateManager) GetCertificate(domain string) (*Certificate, error) {
 	cm.mu.RLock()
 	defer cm.mu.RUnlock()
@@ -144,20 +988,45 @@ func (cm *CertificateManager) ListCertificates() map[string]*Certificate {
 
 func (cm *CertificateManager) CheckCertificateHealth() map[string]CertificateHealth {
 	cm.mu.RLock()
-	defer cm.mu.RUnlock()
-
 	health := make(map[string]CertificateHealth)
+	roles := cm.domainRoles()
+	holds, err := cm.holds.All()
+	if err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
 
 	for domain, cert := range cm.certs {
 		status := CertificateHealth{
-			Domain:    domain,
-			IssuedAt:  cert.IssuedAt,
-			ExpiresAt: cert.ExpiresAt,
-			IsExpired: cert.IsExpired(),
+			Domain:          domain,
+			IssuedAt:        cert.IssuedAt,
+			ExpiresAt:       cert.ExpiresAt,
+			IsExpired:       cert.IsExpired(),
 			DaysUntilExpiry: cert.DaysUntilExpiry(),
+			ChainExpiresAt:  cert.ChainExpiresAt(),
+			ChainRoot:       cert.ChainRoot(),
+			Issuer:          cert.Issuer,
+			Serial:          cert.SerialNumber,
+			SANs:            cert.SANs,
+			CA:              cert.CA,
+			OrderURL:        cert.OrderURL,
 		}
 
-		status.NeedsRenewal = cert.NeedsRenewal(cm.config.Certificates.RenewalDays)
+		if role, ok := roles[domain]; ok {
+			status.Service = role.service
+			if role.primary != domain {
+				status.PrimaryDomain = role.primary
+			}
+		}
+
+		status.NeedsRenewal = cm.needsRenewal(domain, cert)
+		status.RenewAt = cm.renewAt(domain, cert)
+		status.RenewalDue = cm.renewalDue(domain, cert)
+		if hold, held := holds[domain]; held {
+			status.Hold = &hold
+		}
+		if cm.stapler != nil {
+			status.Staple = cm.stapler.Status(cert, time.Now())
+		}
 
 		if status.IsExpired {
 			status.Status = "expired"
@@ -169,13 +1038,120 @@ func (cm *CertificateManager) CheckCertificateHealth() map[string]CertificateHea
 
 		health[domain] = status
 	}
+	cm.mu.RUnlock()
+
+	// The SAN set of an order is read under cm.mu, so this runs after releasing it
+	for domain, status := range health {
+		status.DuplicateLimit = cm.duplicateLimitStatus(domain)
+		health[domain] = status
+	}
 
 	return health
 }
 
-func (cm *CertificateManager) ProcessAllDomains(ctx context.Context) error {
+// GetManagedDomains returns configured, discovered and adopted domains, including aliases
+func (cm *CertificateManager) GetManagedDomains() []string {
 	domains := cm.config.GetAllDomains()
-	
+
+	cm.mu.RLock()
+	defer cm.mu.RUnlock()
+
+	seen := make(map[string]bool)
+	for _, domain := range domains {
+		seen[domain] = true
+	}
+
+	for domain := range cm.adopted {
+		if !seen[domain] {
+			seen[domain] = true
+			domains = append(domains, domain)
+		}
+	}
+
+	for _, sourceDomains := range cm.discovered {
+		for _, domainConfig := range sourceDomains {
+			for _, domain := range append([]string{domainConfig.Domain}, domainConfig.Aliases...) {
+				if !seen[domain] {
+					seen[domain] = true
+					domains = append(domains, domain)
+				}
+			}
+		}
+	}
+
+	return domains
+}
+
+// SyncDiscoveredDomains replaces the domains reported by a discovery source,
+// consolidated as certificates.consolidation says, requesting certificates for
+// new domains and those whose names changed and no longer renewing removed ones
+func (cm *CertificateManager) SyncDiscoveredDomains(ctx context.Context, source string, domains []config.Domain) {
+	if policy := cm.config.Certificates.Consolidation; policy.Policy != config.ConsolidatePerDomain {
+		reported := len(domains)
+		domains = consolidate(domains, policy)
+		if len(domains) < reported {
+			cm.logger.Printf("Consolidated %d domains from %s into %d certificates (%s)", reported, source, len(domains), policy.Policy)
+		}
+	}
+
+	// Managed domains and their SANs, to tell which certificates must change
+	before := make(map[string]string)
+	for _, domain := range cm.GetManagedDomains() {
+		before[domain] = strings.Join(cm.sansFor(domain), ",")
+	}
+
+	cm.mu.Lock()
+	if cm.discovered == nil {
+		cm.discovered = make(map[string][]config.Domain)
+	}
+	cm.discovered[source] = domains
+	cm.mu.Unlock()
+
+	after := make(map[string]string)
+	for _, domain := range cm.GetManagedDomains() {
+		after[domain] = strings.Join(cm.sansFor(domain), ",")
+	}
+
+	for domain := range before {
+		if _, ok := after[domain]; !ok {
+			cm.mu.Lock()
+			cm.releaseCertificate(domain)
+			cm.mu.Unlock()
+			cm.logger.Printf("Domain %s is no longer reported by %s, stopped managing it (files kept on disk)", domain, source)
+		}
+	}
+
+	for domain, sans := range after {
+		previous, existed := before[domain]
+		if existed && previous == sans {
+			continue
+		}
+
+		select {
+		case <-ctx.Done():
+			return
+		default:
+		}
+
+		if existed {
+			cm.logger.Printf("Names in the certificate for %s changed", domain)
+		} else {
+			cm.logger.Printf("Discovered new domain %s from %s", domain, source)
+		}
+		if err := cm.RequestCertificate(ctx, domain); err != nil {
+			cm.logger.Printf("Failed to request certificate for discovered domain %s: %v", domain, err)
+		}
+	}
+}
+
+func (cm *CertificateManager) ProcessAllDomains(ctx context.Context) error {
+	if state := cm.MaintenanceState(); state.Enabled {
+		cm.logger.Printf("Maintenance mode is active, skipping certificate processing")
+		return nil
+	}
+
+	domains := cm.GetManagedDomains()
+
 	cm.logger.Printf("Processing %d domains", len(domains))
 
 	var errs []error
@@ -184,7 +1160,7 @@ func (cm *CertificateManager) ProcessAllDomains(ctx context.Context) error {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
-			if err := cm.RequestCertificate(domain); err != nil {
+			if err := cm.RequestCertificate(ctx, domain); err != nil {
 				errs = append(errs, fmt.Errorf("failed to process domain %s: %w", domain, err))
 			}
 		}
@@ -198,19 +1174,24 @@ func (cm *CertificateManager) ProcessAllDomains(ctx context.Context) error {
 }
 
 func (cm *CertificateManager) RenewExpiredCertificates(ctx context.Context) error {
+	if state := cm.MaintenanceState(); state.Enabled {
+		cm.logger.Printf("Maintenance mode is active, skipping renewals")
+		return nil
+	}
+
 	health := cm.CheckCertificateHealth()
-	
+
 	var errs []error
 	for domain, status := range health {
-		if status.NeedsRenewal {
+		if status.RenewalDue {
 			select {
 			case <-ctx.Done():
 				return ctx.Err()
 			default:
-				cm.logger.Printf("Certificate for %s needs renewal (expires in %d days)", 
-					domain, status.DaysUntilExpiry)
-				
-				if err := cm.RenewCertificate(domain); err != nil {
+				cm.logger.Printf("Certificate for %s needs renewal (expires in %s)",
+					domain, expiresIn(status.ExpiresAt))
+
+				if err := cm.RenewCertificate(ctx, domain); err != nil && !errors.Is(err, ErrDomainUnmanaged) {
 					errs = append(errs, fmt.Errorf("failed to renew certificate for %s: %w", domain, err))
 				}
 			}
@@ -224,7 +1205,7 @@ func (cm *CertificateManager) RenewExpiredCertificates(ctx context.Context) erro
 	return nil
 }
 
-func (cm *CertificateManager) loadExistingCertificates() error {
+func (cm *CertificateManager) loadExistingCertificates(ctx context.Context) error {
 	storagePath := cm.config.Certificates.StoragePath
 
 	// Check if storage directory exists
@@ -244,24 +1225,36 @@ func (cm *CertificateManager) loadExistingCertificates() error {
 	// Find certificate files
 	certFiles := make(map[string]bool)
 	for _, entry := range entries {
-		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".crt" {
-			domain := entry.Name()[:len(entry.Name())-4] // Remove .crt extension
-			if domain != "" && domain != "issuer" {
-				certFiles[domain] = true
-			}
+		if entry.IsDir() {
+			continue
+		}
+		if domain, ok := domainFromCertFile(entry.Name()); ok {
+			certFiles[domain] = true
 		}
 	}
 
+	managed := make(map[string]bool)
+	for _, domain := range cm.GetManagedDomains() {
+		managed[domain] = true
+	}
+
 	// Load certificates
 	for domain := range certFiles {
-		cert, err := cm.acmeClient.LoadCertificate(domain)
+		cert, err := cm.loadCertificate(ctx, domain)
 		if err != nil {
 			cm.logger.Printf("Failed to load certificate for %s: %v", domain, err)
 			continue
 		}
 
+		if !managed[domain] {
+			cm.unmanaged[domain] = cert
+			cm.logger.Printf("Found unmanaged certificate for %s (expires: %s)",
+				domain, cert.ExpiresAt.Format(time.RFC3339))
+			continue
+		}
+
 		cm.certs[domain] = cert
-		cm.logger.Printf("Loaded certificate for %s (expires: %s)", 
+		cm.logger.Printf("Loaded certificate for %s (expires: %s)",
 			domain, cert.ExpiresAt.Format(time.RFC3339))
 	}
 
@@ -270,18 +1263,40 @@ func (cm *CertificateManager) loadExistingCertificates() error {
 }
 
 type CertificateHealth struct {
-	Domain          string    `json:"domain"`
-	Status          string    `json:"status"` // valid, needs_renewal, expired
-	IssuedAt        time.Time `json:"issued_at"`
-	ExpiresAt       time.Time `json:"expires_at"`
-	IsExpired       bool      `json:"is_expired"`
-	NeedsRenewal    bool      `json:"needs_renewal"`
-	DaysUntilExpiry int       `json:"days_until_expiry"`
+	Domain          string                `json:"domain"`
+	Service         string                `json:"service,omitempty"`
+	PrimaryDomain   string                `json:"primary_domain,omitempty"` // set when Domain is an alias
+	Status          string                `json:"status"`                   // valid, needs_renewal, expired
+	IssuedAt        time.Time             `json:"issued_at"`
+	ExpiresAt       time.Time             `json:"expires_at"`
+	IsExpired       bool                  `json:"is_expired"`
+	NeedsRenewal    bool                  `json:"needs_renewal"`
+	RenewAt         time.Time             `json:"renew_at"`    // this certificate's slot in the renewal window
+	RenewalDue      bool                  `json:"renewal_due"` // renewal window, slot and renewal hours all allow renewal
+	DaysUntilExpiry int                   `json:"days_until_expiry"`
+	ChainExpiresAt  time.Time             `json:"chain_expires_at"`     // earliest intermediate or root expiry, zero if no chain is stored
+	ChainRoot       string                `json:"chain_root,omitempty"` // root the stored chain leads to
+	Issuer          string                `json:"issuer,omitempty"`
+	Serial          string                `json:"serial,omitempty"`
+	SANs            []string              `json:"sans,omitempty"`
+	CA              string                `json:"ca,omitempty"` // directory URL of the CA it was ordered from, or internal-ca
+	OrderURL        string                `json:"order_url,omitempty"`
+	Hold            *Hold                 `json:"hold,omitempty"`            // automation is paused while set
+	DuplicateLimit  *DuplicateLimitStatus `json:"duplicate_limit,omitempty"` // issuance is refused while set
+	Staple          *Staple               `json:"staple,omitempty"`          // cached OCSP response, set with OCSP stapling enabled
+}
+
+// DuplicateLimitStatus reports a certificate whose exact SAN set was issued
+// as often as the CA allows within a week
+type DuplicateLimitStatus struct {
+	Issued     int       `json:"issued"`
+	Limit      int       `json:"limit"`
+	RetryAfter time.Time `json:"retry_after"`
 }
 
 func (cm *CertificateManager) GetCertificatePaths(domain string) (certPath, keyPath string) {
-	certPath = filepath.Join(cm.config.Certificates.StoragePath, domain+".crt")
-	keyPath = filepath.Join(cm.config.Certificates.StoragePath, domain+".key")
+	certPath = filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+".crt")
+	keyPath = filepath.Join(cm.config.Certificates.StoragePath, storageName(domain)+".key")
 	return certPath, keyPath
 }
 
@@ -303,4 +1318,4 @@ func (cm *CertificateManager) Cleanup() error {
 	}
 
 	return nil
-}
\ No newline at end of file
+}
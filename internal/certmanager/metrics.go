@@ -0,0 +1,154 @@
+package certmanager
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/metrics"
+)
+
+// Metrics are the collectors of one manager and the components it builds.
+// Each manager registers its own, so managers in one process don't share
+// values. A nil *Metrics records nothing.
+type Metrics struct {
+	acmeRequestDuration    *metrics.Histogram
+	duplicateLimitRefusals *metrics.Counter
+	domainUsageTotal       *metrics.Counter
+	bulkPending            *metrics.Gauge
+	renewalQueueDepth      *metrics.Gauge
+	renewalQueueOldest     *metrics.Gauge
+	storageFreeBytes       *metrics.Gauge
+	storageTotalBytes      *metrics.Gauge
+	storageFreeInodes      *metrics.Gauge
+	storageLow             *metrics.Gauge
+	maintenanceMode        *metrics.Gauge
+	chainExpiry            *metrics.Gauge
+	uncoveredRoutes        *metrics.Gauge
+	orphanedCertificates   *metrics.Gauge
+
+	// acmeRetries counts ACME requests retried after a transient error. It is
+	// published on the debug endpoint rather than as a metric.
+	acmeRetries atomic.Int64
+}
+
+// NewMetrics registers the collectors of a manager with registry
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		acmeRequestDuration: registry.NewHistogram("certmanager_acme_request_duration_seconds",
+			"Duration of HTTP requests to the ACME server, by CA and endpoint.", metrics.DefBuckets, "ca", "endpoint"),
+		duplicateLimitRefusals: registry.NewCounter("certmanager_duplicate_limit_refusals_total",
+			"Issuance attempts refused locally to stay under the CA duplicate certificate limit."),
+		domainUsageTotal: registry.NewCounter("certmanager_domain_usage_total",
+			"ACME orders, failed orders, DNS queries and notifications consumed per domain.", "domain", "kind"),
+		bulkPending: registry.NewGauge("certmanager_bulk_issuance_pending_domains",
+			"Managed domains still waiting for their first certificate from bulk issuance."),
+		renewalQueueDepth: registry.NewGauge("certmanager_renewal_queue_depth",
+			"Renewals waiting in the queue of the running renewal check, by priority.", "priority"),
+		renewalQueueOldest: registry.NewGauge("certmanager_renewal_queue_oldest_task_timestamp_seconds",
+			"Unix time the longest waiting renewal was queued, 0 when the queue is empty. Its age is time() minus this."),
+		storageFreeBytes:  registry.NewGauge("certmanager_storage_free_bytes", "Free bytes available on the certificate storage volume."),
+		storageTotalBytes: registry.NewGauge("certmanager_storage_total_bytes", "Total size in bytes of the certificate storage volume."),
+		storageFreeInodes: registry.NewGauge("certmanager_storage_free_inodes", "Free inodes on the certificate storage volume."),
+		storageLow: registry.NewGauge("certmanager_storage_low",
+			"Whether free space or inodes on the storage volume are below the configured threshold."),
+		maintenanceMode: registry.NewGauge("certmanager_maintenance_mode",
+			"Whether maintenance mode is active and certificate automation is paused."),
+		chainExpiry: registry.NewGauge("certmanager_chain_expiry_timestamp_seconds",
+			"Earliest expiry of the intermediate and root certificates stored with a domain's certificate.", "domain"),
+		uncoveredRoutes: registry.NewGauge("certmanager_drift_uncovered_routes",
+			"Hostnames routed over TLS by Traefik that no certificate covers."),
+		orphanedCertificates: registry.NewGauge("certmanager_drift_orphaned_certificates",
+			"Certificates kept for domains no Traefik router serves."),
+	}
+}
+
+func (m *Metrics) observeACMERequest(ca, endpoint string, duration time.Duration) {
+	if m != nil {
+		m.acmeRequestDuration.Observe(duration.Seconds(), ca, endpoint)
+	}
+}
+
+func (m *Metrics) refuseDuplicate() {
+	if m != nil {
+		m.duplicateLimitRefusals.Inc()
+	}
+}
+
+func (m *Metrics) addUsage(domain, kind string, n int) {
+	if m != nil {
+		m.domainUsageTotal.Add(float64(n), domain, kind)
+	}
+}
+
+func (m *Metrics) setBulkPending(pending int) {
+	if m != nil {
+		m.bulkPending.Set(float64(pending))
+	}
+}
+
+// setRenewalQueue exports the depth of the renewal queue by priority and when
+// its oldest task was queued
+func (m *Metrics) setRenewalQueue(depth map[int]int, oldest time.Time) {
+	if m == nil {
+		return
+	}
+	for _, priority := range []int{PriorityExpiring, PriorityExpired, PriorityManual} {
+		m.renewalQueueDepth.Set(float64(depth[priority]), priorityName(priority))
+	}
+	if oldest.IsZero() {
+		m.renewalQueueOldest.Set(0)
+	} else {
+		m.renewalQueueOldest.Set(float64(oldest.Unix()))
+	}
+}
+
+func (m *Metrics) setDiskUsage(usage DiskUsage, low bool) {
+	if m == nil {
+		return
+	}
+	m.storageFreeBytes.Set(float64(usage.FreeBytes))
+	m.storageTotalBytes.Set(float64(usage.TotalBytes))
+	m.storageFreeInodes.Set(float64(usage.FreeInodes))
+	m.storageLow.Set(boolValue(low))
+}
+
+func (m *Metrics) setMaintenance(enabled bool) {
+	if m != nil {
+		m.maintenanceMode.Set(boolValue(enabled))
+	}
+}
+
+func (m *Metrics) setChainExpiry(domain string, expiry time.Time) {
+	if m != nil {
+		m.chainExpiry.Set(float64(expiry.Unix()), domain)
+	}
+}
+
+func (m *Metrics) setDrift(drift Drift) {
+	if m == nil {
+		return
+	}
+	m.uncoveredRoutes.Set(float64(len(drift.Uncovered)))
+	m.orphanedCertificates.Set(float64(len(drift.Orphaned)))
+}
+
+func (m *Metrics) retryACME() {
+	if m != nil {
+		m.acmeRetries.Add(1)
+	}
+}
+
+// ACMERetries returns how many ACME requests were retried after a transient error
+func (m *Metrics) ACMERetries() int64 {
+	if m == nil {
+		return 0
+	}
+	return m.acmeRetries.Load()
+}
+
+func boolValue(b bool) float64 {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,35 @@
+package certmanager
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/O-tero/traefik-cert-manager/internal/metrics"
+)
+
+func TestMetrics_PerManager(t *testing.T) {
+	first, second := metrics.NewRegistry(), metrics.NewRegistry()
+	a, b := NewMetrics(first), NewMetrics(second)
+
+	a.retryACME()
+	a.setMaintenance(true)
+	b.setMaintenance(false)
+
+	assert.Equal(t, int64(1), a.ACMERetries())
+	assert.Equal(t, int64(0), b.ACMERetries())
+
+	var out bytes.Buffer
+	first.Write(&out)
+	assert.Contains(t, out.String(), "certmanager_maintenance_mode 1")
+	out.Reset()
+	second.Write(&out)
+	assert.Contains(t, out.String(), "certmanager_maintenance_mode 0")
+
+	// Components built without metrics record nothing
+	var none *Metrics
+	none.retryACME()
+	none.setDrift(Drift{Uncovered: []string{"example.com"}})
+	assert.Equal(t, int64(0), none.ACMERetries())
+}
@@ -0,0 +1,243 @@
+package certmanager
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	storageVersionFile = ".storage-version"
+	backupDirName      = "backups"
+)
+
+// StorageMigration upgrades the storage layout from Version-1 to Version
+type StorageMigration struct {
+	Version     int
+	Description string
+	apply       func(storagePath string, logger *log.Logger) error
+}
+
+// storageMigrations must be ordered by version and never reordered once released
+var storageMigrations = []StorageMigration{
+	{
+		Version:     1,
+		Description: "encode wildcard domains in certificate file names",
+		apply:       migrateWildcardFileNames,
+	},
+}
+
+// LatestStorageVersion is the storage layout version written by this build
+func LatestStorageVersion() int {
+	return storageMigrations[len(storageMigrations)-1].Version
+}
+
+// StorageVersion reads the layout version of the storage directory. Storage
+// created before versioning was introduced is reported as version 0.
+func StorageVersion(storagePath string) (int, error) {
+	data, err := os.ReadFile(filepath.Join(storagePath, storageVersionFile))
+	if os.IsNotExist(err) {
+		return 0, nil
+	}
+	if err != nil {
+		return 0, fmt.Errorf("failed to read storage version: %w", err)
+	}
+
+	version, err := strconv.Atoi(strings.TrimSpace(string(data)))
+	if err != nil {
+		return 0, fmt.Errorf("invalid storage version %q: %w", strings.TrimSpace(string(data)), err)
+	}
+
+	return version, nil
+}
+
+// PendingStorageMigrations returns the migrations needed to bring storage up to date
+func PendingStorageMigrations(storagePath string) ([]StorageMigration, error) {
+	version, err := StorageVersion(storagePath)
+	if err != nil {
+		return nil, err
+	}
+
+	if version > LatestStorageVersion() {
+		return nil, fmt.Errorf("storage version %d is newer than supported version %d; refusing to downgrade",
+			version, LatestStorageVersion())
+	}
+
+	fresh, err := isFreshStorage(storagePath)
+	if err != nil {
+		return nil, err
+	}
+	if version == 0 && fresh {
+		return nil, nil
+	}
+
+	var pending []StorageMigration
+	for _, migration := range storageMigrations {
+		if migration.Version > version {
+			pending = append(pending, migration)
+		}
+	}
+
+	return pending, nil
+}
+
+// MigrateStorage backs up the storage directory and applies pending migrations in order
+func MigrateStorage(storagePath string, logger *log.Logger) error {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Migrate] ", log.LstdFlags)
+	}
+
+	pending, err := PendingStorageMigrations(storagePath)
+	if err != nil {
+		return err
+	}
+
+	if len(pending) == 0 {
+		return writeStorageVersion(storagePath, LatestStorageVersion())
+	}
+
+	from, _ := StorageVersion(storagePath)
+	backupPath, err := backupStorage(storagePath, from)
+	if err != nil {
+		return fmt.Errorf("failed to back up storage before migration: %w", err)
+	}
+	logger.Printf("Backed up storage version %d to %s", from, backupPath)
+
+	for _, migration := range pending {
+		logger.Printf("Applying storage migration %d: %s", migration.Version, migration.Description)
+
+		if err := migration.apply(storagePath, logger); err != nil {
+			return fmt.Errorf("storage migration %d failed (backup at %s): %w", migration.Version, backupPath, err)
+		}
+
+		if err := writeStorageVersion(storagePath, migration.Version); err != nil {
+			return err
+		}
+	}
+
+	logger.Printf("Storage migrated from version %d to %d", from, LatestStorageVersion())
+	return nil
+}
+
+func writeStorageVersion(storagePath string, version int) error {
+	path := filepath.Join(storagePath, storageVersionFile)
+	if err := os.WriteFile(path, []byte(strconv.Itoa(version)+"\n"), 0644); err != nil {
+		return fmt.Errorf("failed to write storage version: %w", err)
+	}
+	return nil
+}
+
+// isFreshStorage reports whether the directory holds no certificates yet
+func isFreshStorage(storagePath string) (bool, error) {
+	entries, err := os.ReadDir(storagePath)
+	if os.IsNotExist(err) {
+		return true, nil
+	}
+	if err != nil {
+		return false, fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.Name() != storageVersionFile && entry.Name() != backupDirName {
+			return false, nil
+		}
+	}
+
+	return true, nil
+}
+
+// backupStorage writes a compressed snapshot of the storage directory, excluding earlier backups
+func backupStorage(storagePath string, version int) (string, error) {
+	backupDir := filepath.Join(storagePath, backupDirName)
+	if err := os.MkdirAll(backupDir, 0700); err != nil {
+		return "", err
+	}
+
+	backupPath := filepath.Join(backupDir,
+		fmt.Sprintf("storage-v%d-%s.tar.gz", version, time.Now().UTC().Format("20060102T150405Z")))
+
+	f, err := os.OpenFile(backupPath, os.O_CREATE|os.O_EXCL|os.O_WRONLY, 0600)
+	if err != nil {
+		return "", err
+	}
+
+	gz := gzip.NewWriter(f)
+	tw := tar.NewWriter(gz)
+
+	err = filepath.Walk(storagePath, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+
+		rel, err := filepath.Rel(storagePath, path)
+		if err != nil || rel == "." {
+			return err
+		}
+		if rel == backupDirName {
+			return filepath.SkipDir
+		}
+
+		header, err := tar.FileInfoHeader(info, "")
+		if err != nil {
+			return err
+		}
+		header.Name = filepath.ToSlash(rel)
+
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if !info.Mode().IsRegular() {
+			return nil
+		}
+
+		src, err := os.Open(path)
+		if err != nil {
+			return err
+		}
+		defer src.Close()
+
+		_, err = io.Copy(tw, src)
+		return err
+	})
+
+	for _, closer := range []io.Closer{tw, gz, f} {
+		if closeErr := closer.Close(); err == nil {
+			err = closeErr
+		}
+	}
+	if err != nil {
+		os.Remove(backupPath)
+		return "", err
+	}
+
+	return backupPath, nil
+}
+
+// migrateWildcardFileNames renames "*.example.com.crt" style files to "_.example.com.crt"
+func migrateWildcardFileNames(storagePath string, logger *log.Logger) error {
+	entries, err := os.ReadDir(storagePath)
+	if err != nil {
+		return fmt.Errorf("failed to read storage directory: %w", err)
+	}
+
+	for _, entry := range entries {
+		if entry.IsDir() || !strings.HasPrefix(entry.Name(), "*.") {
+			continue
+		}
+
+		newName := "_" + entry.Name()[1:]
+		if err := os.Rename(filepath.Join(storagePath, entry.Name()), filepath.Join(storagePath, newName)); err != nil {
+			return fmt.Errorf("failed to rename %s: %w", entry.Name(), err)
+		}
+		logger.Printf("Renamed %s to %s", entry.Name(), newName)
+	}
+
+	return nil
+}
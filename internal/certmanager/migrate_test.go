@@ -0,0 +1,91 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMigrateStorage_FreshStorage(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	pending, err := PendingStorageMigrations(testDir)
+	require.NoError(t, err)
+	assert.Empty(t, pending)
+
+	require.NoError(t, MigrateStorage(testDir, logger))
+
+	version, err := StorageVersion(testDir)
+	require.NoError(t, err)
+	assert.Equal(t, LatestStorageVersion(), version)
+
+	// No backup is needed when there was nothing to migrate
+	_, err = os.Stat(filepath.Join(testDir, backupDirName))
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestMigrateStorage_WildcardFileNames(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cert := createTestCertificate("*.example.com", 60)
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "*.example.com.crt"), cert.Certificate, 0644))
+	require.NoError(t, os.WriteFile(filepath.Join(testDir, "*.example.com.key"), cert.PrivateKey, 0600))
+
+	pending, err := PendingStorageMigrations(testDir)
+	require.NoError(t, err)
+	require.Len(t, pending, 1)
+
+	require.NoError(t, MigrateStorage(testDir, logger))
+
+	assert.FileExists(t, filepath.Join(testDir, "_.example.com.crt"))
+	assert.FileExists(t, filepath.Join(testDir, "_.example.com.key"))
+	assert.NoFileExists(t, filepath.Join(testDir, "*.example.com.crt"))
+
+	backups, err := os.ReadDir(filepath.Join(testDir, backupDirName))
+	require.NoError(t, err)
+	assert.Len(t, backups, 1)
+
+	version, err := StorageVersion(testDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, version)
+
+	// The encoded file maps back to the wildcard domain
+	domain, ok := domainFromCertFile("_.example.com.crt")
+	assert.True(t, ok)
+	assert.Equal(t, "*.example.com", domain)
+}
+
+func TestMigrateStorage_RefusesNewerVersion(t *testing.T) {
+	testDir := setupTestDir(t)
+	require.NoError(t, writeStorageVersion(testDir, LatestStorageVersion()+1))
+
+	_, err := PendingStorageMigrations(testDir)
+	assert.Error(t, err)
+	assert.Error(t, MigrateStorage(testDir, nil))
+}
+
+func TestDomainFromCertFile(t *testing.T) {
+	tests := []struct {
+		fileName string
+		domain   string
+		ok       bool
+	}{
+		{"example.com.crt", "example.com", true},
+		{"_.example.com.crt", "*.example.com", true},
+		{"example.com.issuer.crt", "", false},
+		{"issuer.crt", "", false},
+		{"example.com.key", "", false},
+	}
+
+	for _, tt := range tests {
+		domain, ok := domainFromCertFile(tt.fileName)
+		assert.Equal(t, tt.ok, ok, tt.fileName)
+		assert.Equal(t, tt.domain, domain, tt.fileName)
+	}
+}
@@ -0,0 +1,44 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+)
+
+// ErrNotYetValid is returned when a CA issues a certificate whose NotBefore
+// lies further in the future than the configured clock skew allows
+var ErrNotYetValid = errors.New("certificate is not yet valid")
+
+// checkNotBefore accepts a newly issued certificate whose NotBefore is at most
+// notBeforeSkew ahead of the local clock. A certificate further ahead is
+// rejected and the previous one is written back to storage; without a previous
+// certificate there is nothing to roll back to, so the new one is kept.
+func (cm *CertificateManager) checkNotBefore(ctx context.Context, issued, previous *Certificate) error {
+	ahead := time.Until(issued.NotBefore)
+	if ahead <= 0 {
+		return nil
+	}
+
+	if ahead <= cm.notBeforeSkew {
+		cm.logger.Printf("Certificate for %s becomes valid in %s, within the tolerated clock skew of %s",
+			issued.Domain, ahead.Round(time.Second), cm.notBeforeSkew)
+		return nil
+	}
+
+	err := fmt.Errorf("%w: %s becomes valid at %s, %s from now (tolerated skew %s)", ErrNotYetValid,
+		issued.Domain, issued.NotBefore.Format(time.RFC3339), ahead.Round(time.Second), cm.notBeforeSkew)
+
+	if previous == nil {
+		cm.logger.Printf("Warning: %v; keeping it as there is no previous certificate", err)
+		return nil
+	}
+
+	// Restored even when the operation was cancelled, so the new certificate isn't served
+	if saveErr := cm.acmeClient.SaveCertificate(context.WithoutCancel(ctx), previous); saveErr != nil {
+		return fmt.Errorf("%w; failed to restore previous certificate: %v", err, saveErr)
+	}
+	cm.logger.Printf("Rejected new certificate for %s, restored the previous one: %v", issued.Domain, err)
+	return err
+}
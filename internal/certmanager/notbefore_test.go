@@ -0,0 +1,60 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_RenewCertificate_NotBeforeSkew(t *testing.T) {
+	tests := []struct {
+		name      string
+		notBefore time.Duration
+		rollback  bool
+	}{
+		{name: "already valid", notBefore: -time.Minute},
+		{name: "within tolerated skew", notBefore: 2 * time.Minute},
+		{name: "beyond tolerated skew", notBefore: time.Hour, rollback: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			testDir := setupTestDir(t)
+			logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+			mockClient := NewMockACMEClient(testDir, logger)
+
+			oldCert := createTestCertificate("example.com", 15)
+			newCert := createTestCertificate("example.com", 90)
+			newCert.NotBefore = time.Now().Add(tt.notBefore)
+
+			cm := &CertificateManager{
+				config:        createTestConfig(),
+				acmeClient:    mockClient,
+				notBeforeSkew: 5 * time.Minute,
+				logger:        logger,
+				certs:         map[string]*Certificate{"example.com": oldCert},
+			}
+
+			mockClient.On("RenewCertificate", oldCert).Return(newCert, nil)
+			if tt.rollback {
+				mockClient.On("SaveCertificate", oldCert).Return(nil)
+			}
+
+			err := cm.RenewCertificate(context.Background(), "example.com")
+			mockClient.AssertExpectations(t)
+
+			if tt.rollback {
+				assert.ErrorIs(t, err, ErrNotYetValid)
+				assert.Same(t, oldCert, cm.certs["example.com"])
+				return
+			}
+			require.NoError(t, err)
+			assert.Same(t, newCert, cm.certs["example.com"])
+		})
+	}
+}
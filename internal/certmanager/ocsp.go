@@ -0,0 +1,246 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"time"
+
+	"golang.org/x/crypto/ocsp"
+)
+
+const (
+	// ocspSuffix names the DER OCSP response cached next to a certificate
+	ocspSuffix = ".ocsp"
+	// maxOCSPResponseSize bounds the size of a downloaded OCSP response
+	maxOCSPResponseSize = 1 << 20
+)
+
+// Staple states besides the good, revoked and unknown answers of a responder
+const (
+	StapleMissing     = "missing"     // no response cached yet, or none for this certificate
+	StapleUnsupported = "unsupported" // the certificate names no OCSP responder
+)
+
+// Staple describes the OCSP response cached for a certificate
+type Staple struct {
+	Status     string    `json:"status"` // good, revoked or unknown as the responder answered, missing or unsupported
+	ThisUpdate time.Time `json:"this_update,omitempty"`
+	NextUpdate time.Time `json:"next_update,omitempty"` // zero when the responder always has newer information
+	Fresh      bool      `json:"fresh"`                 // the response covers this certificate and hasn't passed its next update
+}
+
+// OCSPStapler fetches OCSP responses from the responders named in certificates
+// and caches them as <domain>.ocsp in the storage path, for servers that
+// staple from a file
+type OCSPStapler struct {
+	storagePath string
+	httpClient  *http.Client
+}
+
+func NewOCSPStapler(storagePath string, timeout time.Duration) *OCSPStapler {
+	return &OCSPStapler{
+		storagePath: storagePath,
+		httpClient:  &http.Client{Timeout: timeout},
+	}
+}
+
+// path returns where the response for a domain is cached
+func (s *OCSPStapler) path(domain string) string {
+	return filepath.Join(s.storagePath, storageName(domain)+ocspSuffix)
+}
+
+// Status describes the cached response of cert at now
+func (s *OCSPStapler) Status(cert *Certificate, now time.Time) *Staple {
+	leaf, issuer, err := ocspPair(cert)
+	if leaf == nil || len(leaf.OCSPServer) == 0 {
+		return &Staple{Status: StapleUnsupported}
+	}
+	if err != nil {
+		return &Staple{Status: StapleMissing}
+	}
+	resp, err := s.cached(cert.Domain, leaf, issuer)
+	if err != nil {
+		return &Staple{Status: StapleMissing}
+	}
+	return stapleOf(resp, now)
+}
+
+// Refresh fetches a new response for cert when none is cached for it or the
+// cached one is past half its validity, and reports whether it did. Certificates
+// naming no responder are skipped.
+func (s *OCSPStapler) Refresh(ctx context.Context, cert *Certificate, now time.Time) (bool, error) {
+	leaf, issuer, err := ocspPair(cert)
+	if leaf != nil && len(leaf.OCSPServer) == 0 {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	if resp, err := s.cached(cert.Domain, leaf, issuer); err == nil && !refreshDue(resp, now) {
+		return false, nil
+	}
+
+	der, err := s.fetch(ctx, leaf, issuer)
+	if err != nil {
+		return false, err
+	}
+	if err := writeFileAtomic(s.path(cert.Domain), der, 0644); err != nil {
+		return false, fmt.Errorf("failed to save OCSP response: %w", err)
+	}
+	return true, nil
+}
+
+// Remove deletes the cached response of cert unless it covers cert, so servers
+// never staple the response of a replaced certificate
+func (s *OCSPStapler) Remove(cert *Certificate) error {
+	if leaf, issuer, err := ocspPair(cert); err == nil {
+		if _, err := s.cached(cert.Domain, leaf, issuer); err == nil {
+			return nil
+		}
+	}
+	if err := os.Remove(s.path(cert.Domain)); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to remove stale OCSP response: %w", err)
+	}
+	return nil
+}
+
+// cached reads the response cached for domain, checking it was signed for leaf
+func (s *OCSPStapler) cached(domain string, leaf, issuer *x509.Certificate) (*ocsp.Response, error) {
+	der, err := os.ReadFile(s.path(domain))
+	if err != nil {
+		return nil, err
+	}
+	return ocsp.ParseResponseForCert(der, leaf, issuer)
+}
+
+// fetch asks each responder of leaf in turn for its status
+func (s *OCSPStapler) fetch(ctx context.Context, leaf, issuer *x509.Certificate) ([]byte, error) {
+	request, err := ocsp.CreateRequest(leaf, issuer, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create OCSP request: %w", err)
+	}
+
+	var lastErr error
+	for _, url := range leaf.OCSPServer {
+		der, err := s.post(ctx, url, request)
+		if err == nil {
+			_, err = ocsp.ParseResponseForCert(der, leaf, issuer)
+		}
+		if err != nil {
+			lastErr = fmt.Errorf("OCSP responder %s: %w", url, err)
+			continue
+		}
+		return der, nil
+	}
+	return nil, lastErr
+}
+
+func (s *OCSPStapler) post(ctx context.Context, url string, request []byte) ([]byte, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(request))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/ocsp-request")
+	req.Header.Set("Accept", "application/ocsp-response")
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("returned status %d", resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxOCSPResponseSize))
+}
+
+// ocspPair returns the leaf of cert and the certificate that signed it, taken
+// from the stored chain. The leaf is also returned when its issuer isn't stored.
+func ocspPair(cert *Certificate) (*x509.Certificate, *x509.Certificate, error) {
+	certs, err := parsePEMCertificates(fullChain(cert))
+	if err != nil {
+		return nil, nil, err
+	}
+	if len(certs) == 0 {
+		return nil, nil, fmt.Errorf("no certificate found for %s", cert.Domain)
+	}
+	leaf := certs[0]
+	for _, issuer := range certs[1:] {
+		if leaf.CheckSignatureFrom(issuer) == nil {
+			return leaf, issuer, nil
+		}
+	}
+	return leaf, nil, fmt.Errorf("no issuer of %s is stored, needed for OCSP", cert.Domain)
+}
+
+// refreshDue reports whether half the validity of resp has passed at now.
+// Responses without a next update are refreshed every time.
+func refreshDue(resp *ocsp.Response, now time.Time) bool {
+	if resp.NextUpdate.IsZero() {
+		return true
+	}
+	return !now.Before(resp.ThisUpdate.Add(resp.NextUpdate.Sub(resp.ThisUpdate) / 2))
+}
+
+func stapleOf(resp *ocsp.Response, now time.Time) *Staple {
+	staple := &Staple{ThisUpdate: resp.ThisUpdate, NextUpdate: resp.NextUpdate}
+	switch resp.Status {
+	case ocsp.Good:
+		staple.Status = "good"
+	case ocsp.Revoked:
+		staple.Status = "revoked"
+	default:
+		staple.Status = "unknown"
+	}
+	staple.Fresh = resp.NextUpdate.IsZero() || now.Before(resp.NextUpdate)
+	return staple
+}
+
+// RefreshStaples fetches new OCSP responses for the certificates whose cached
+// response is missing or past half its validity. A failed refresh leaves the
+// cached response in place; health output shows when it is no longer fresh.
+func (cm *CertificateManager) RefreshStaples(ctx context.Context) {
+	if cm.stapler == nil {
+		return
+	}
+	now := time.Now()
+	for domain, cert := range cm.ListCertificates() {
+		refreshed, err := cm.stapler.Refresh(ctx, cert, now)
+		if err != nil {
+			cm.logger.Printf("Warning: failed to refresh OCSP staple for %s: %v", domain, err)
+			continue
+		}
+		if refreshed {
+			cm.logger.Printf("Refreshed OCSP staple for %s", domain)
+		}
+	}
+}
+
+// writeFileAtomic replaces path with data in one step, so a server reading it
+// never sees a partial file
+func writeFileAtomic(path string, data []byte, perm os.FileMode) error {
+	tmp, err := os.CreateTemp(filepath.Dir(path), "."+filepath.Base(path)+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := tmp.Write(data); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), perm); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), path)
+}
@@ -0,0 +1,120 @@
+package certmanager
+
+import (
+	"context"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"golang.org/x/crypto/ocsp"
+)
+
+// serveOCSP answers OCSP requests for certificates of ca with status good and
+// a validity of four days, counting the requests
+func serveOCSP(t *testing.T, ca *testCA, requests *int) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		*requests++
+		body, err := io.ReadAll(r.Body)
+		require.NoError(t, err)
+		req, err := ocsp.ParseRequest(body)
+		require.NoError(t, err)
+
+		now := time.Now().Truncate(time.Minute)
+		der, err := ocsp.CreateResponse(ca.cert, ca.cert, ocsp.Response{
+			Status:       ocsp.Good,
+			SerialNumber: req.SerialNumber,
+			ThisUpdate:   now,
+			NextUpdate:   now.Add(4 * 24 * time.Hour),
+		}, ca.key)
+		require.NoError(t, err)
+		w.Header().Set("Content-Type", "application/ocsp-response")
+		w.Write(der)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestOCSPStapler_Refresh(t *testing.T) {
+	var requests int
+	ca := newTestCA(t, "Test CA", 365, nil)
+	ca.ocsp = serveOCSP(t, ca, &requests).URL
+	cert := ca.issue(t, "example.com", 60)
+
+	testDir := setupTestDir(t)
+	stapler := NewOCSPStapler(testDir, 5*time.Second)
+	now := time.Now()
+	assert.Equal(t, StapleMissing, stapler.Status(cert, now).Status)
+
+	refreshed, err := stapler.Refresh(context.Background(), cert, now)
+	require.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.FileExists(t, filepath.Join(testDir, "example.com.ocsp"))
+
+	staple := stapler.Status(cert, now)
+	assert.Equal(t, "good", staple.Status)
+	assert.True(t, staple.Fresh)
+
+	// The cached response is kept until half its validity has passed
+	refreshed, err = stapler.Refresh(context.Background(), cert, now.Add(24*time.Hour))
+	require.NoError(t, err)
+	assert.False(t, refreshed)
+	refreshed, err = stapler.Refresh(context.Background(), cert, now.Add(3*24*time.Hour))
+	require.NoError(t, err)
+	assert.True(t, refreshed)
+	assert.Equal(t, 2, requests)
+
+	// Past its next update the response is no longer fresh
+	assert.False(t, stapler.Status(cert, now.Add(5*24*time.Hour)).Fresh)
+
+	// A renewed certificate doesn't inherit the response of the previous one
+	renewed := ca.issue(t, "example.com", 60)
+	assert.Equal(t, StapleMissing, stapler.Status(renewed, now).Status)
+	require.NoError(t, stapler.Remove(renewed))
+	assert.NoFileExists(t, filepath.Join(testDir, "example.com.ocsp"))
+}
+
+func TestOCSPStapler_Unsupported(t *testing.T) {
+	ca := newTestCA(t, "Test CA", 365, nil)
+	cert := ca.issue(t, "example.com", 60)
+
+	stapler := NewOCSPStapler(setupTestDir(t), 5*time.Second)
+	refreshed, err := stapler.Refresh(context.Background(), cert, time.Now())
+	assert.NoError(t, err)
+	assert.False(t, refreshed)
+	assert.Equal(t, StapleUnsupported, stapler.Status(cert, time.Now()).Status)
+}
+
+func TestCertificateManager_RefreshStaples(t *testing.T) {
+	var requests int
+	ca := newTestCA(t, "Test CA", 365, nil)
+	ca.ocsp = serveOCSP(t, ca, &requests).URL
+
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Certificates.OCSPStapling = config.OCSPStapling{Enabled: true}
+	cm := &CertificateManager{
+		config:  cfg,
+		stapler: NewOCSPStapler(testDir, 5*time.Second),
+		logger:  log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		certs:   map[string]*Certificate{"example.com": ca.issue(t, "example.com", 60)},
+	}
+
+	cm.RefreshStaples(context.Background())
+	assert.Equal(t, 1, requests)
+
+	health := cm.CheckCertificateHealth()["example.com"]
+	require.NotNil(t, health.Staple)
+	assert.Equal(t, "good", health.Staple.Status)
+	assert.True(t, health.Staple.Fresh)
+}
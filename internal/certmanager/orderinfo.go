@@ -0,0 +1,51 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"fmt"
+)
+
+// orderInfoSuffix marks the file recording where a stored certificate was
+// ordered, which its PEM files don't say
+const orderInfoSuffix = ".acme.json"
+
+// orderInfo is the content of a certificate's order details file
+type orderInfo struct {
+	CA             string `json:"ca"` // directory URL, or internal-ca
+	OrderURL       string `json:"order_url,omitempty"`
+	CertificateURL string `json:"certificate_url,omitempty"`
+}
+
+// encodeOrderInfo returns the order details file of cert, nil for certificates
+// not ordered here, such as imported ones
+func encodeOrderInfo(cert *Certificate) ([]byte, error) {
+	if cert.CA == "" && cert.OrderURL == "" && cert.URL == "" {
+		return nil, nil
+	}
+	data, err := json.MarshalIndent(orderInfo{CA: cert.CA, OrderURL: cert.OrderURL, CertificateURL: cert.URL}, "", "  ")
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode order details: %w", err)
+	}
+	return data, nil
+}
+
+// decodeOrderInfo fills in the order details of cert. The details only serve
+// audits and debugging, so a damaged file is logged and otherwise ignored.
+func (c *ACMEClient) decodeOrderInfo(cert *Certificate, data []byte) {
+	var info orderInfo
+	if err := json.Unmarshal(data, &info); err != nil {
+		c.logger.Printf("Warning: ignoring order details of %s: %v", cert.Domain, err)
+		return
+	}
+	cert.CA, cert.OrderURL, cert.URL = info.CA, info.OrderURL, info.CertificateURL
+}
+
+// orderURL returns the URL of the order journaled for domain, empty when there
+// is none
+func (c *ACMEClient) orderURL(domain string) string {
+	order, ok, err := c.orders.Find(domain)
+	if err != nil || !ok {
+		return ""
+	}
+	return order.URL
+}
@@ -0,0 +1,46 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEClient_KeepsOrderDetails(t *testing.T) {
+	testDir := setupTestDir(t)
+	client := &ACMEClient{storagePath: testDir, logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags)}
+
+	ordered := createTestCertificate("example.com", 90)
+	ordered.CA = "https://ca.example.com/acme/acme/directory"
+	ordered.OrderURL = "https://ca.example.com/acme/acme/order/4xK1"
+	ordered.URL = "https://ca.example.com/acme/acme/certificate/9bQz"
+	require.NoError(t, client.saveCertificate(context.Background(), ordered))
+
+	// Survives a restart, unlike the in-memory certificate
+	loaded, err := client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, ordered.CA, loaded.CA)
+	assert.Equal(t, ordered.OrderURL, loaded.OrderURL)
+	assert.Equal(t, ordered.URL, loaded.URL)
+	assert.Equal(t, "example.com", loaded.Issuer) // self-signed
+	assert.Equal(t, "01", loaded.SerialNumber)
+	assert.Equal(t, []string{"example.com"}, loaded.SANs)
+
+	// An imported certificate wasn't ordered from that CA
+	require.NoError(t, client.saveCertificate(context.Background(), createTestCertificate("example.com", 60)))
+	loaded, err = client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Empty(t, loaded.CA)
+	assert.Empty(t, loaded.OrderURL)
+	assert.NoFileExists(t, filepath.Join(testDir, "example.com"+orderInfoSuffix))
+
+	// The previous generation's details are kept with its backup
+	restored, err := client.RestoreBackup(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, ordered.OrderURL, restored.OrderURL)
+}
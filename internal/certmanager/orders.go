@@ -0,0 +1,558 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/encryption"
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/go-acme/lego/v4/acme/api"
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/certificate"
+)
+
+const (
+	orderJournalName = ".acme-orders.json"
+	pendingKeySuffix = ".pending.key"
+
+	// maxOrderSize bounds the size of an order document inspected by orderRecorder
+	maxOrderSize = 1 << 20
+	// orderPollInterval and orderPollTimeout bound the wait for a resumed order to be issued
+	orderPollInterval = 2 * time.Second
+	orderPollTimeout  = 2 * time.Minute
+)
+
+// accountKeyName returns the per-CA file the ACME account key is kept in
+func accountKeyName(caDirURL string) string {
+	sum := sha256.Sum256([]byte(caDirURL))
+	return ".acme-account-" + hex.EncodeToString(sum[:8]) + ".key"
+}
+
+// loadAccountKey returns the account key stored at path, creating it on first
+// use. Orders belong to the account that placed them, so resuming one after a
+// restart needs the same account.
+func loadAccountKey(path, keyType string, envelope *encryption.Envelope) (crypto.PrivateKey, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), keyEncryptionTimeout)
+	defer cancel()
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if encryption.IsSealed(data) {
+			if envelope == nil {
+				return nil, fmt.Errorf("account key is encrypted but no encryption provider is configured")
+			}
+			if data, err = envelope.Open(ctx, data); err != nil {
+				return nil, fmt.Errorf("failed to decrypt account key: %w", err)
+			}
+		}
+		return certcrypto.ParsePEMPrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read account key: %w", err)
+	}
+
+	key, err := generatePrivateKey(keyType)
+	if err != nil {
+		return nil, err
+	}
+
+	data = certcrypto.PEMEncode(key)
+	if envelope != nil {
+		if data, err = envelope.Seal(ctx, data); err != nil {
+			return nil, fmt.Errorf("failed to encrypt account key: %w", err)
+		}
+	}
+	if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+		return nil, fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save account key: %w", err)
+	}
+	return key, nil
+}
+
+// pendingOrder is an ACME order placed but not yet turned into a stored certificate
+type pendingOrder struct {
+	URL       string    `json:"url"`
+	Domains   []string  `json:"domains"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// orderJournal records in-progress orders under StoragePath so an order cut
+// short by a crash or restart is finished instead of abandoned with its
+// authorizations still pending
+type orderJournal struct {
+	path string
+	mu   sync.Mutex
+}
+
+func newOrderJournal(storagePath string) *orderJournal {
+	return &orderJournal{path: filepath.Join(storagePath, orderJournalName)}
+}
+
+func (j *orderJournal) load() ([]pendingOrder, error) {
+	data, err := os.ReadFile(j.path)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read order journal: %w", err)
+	}
+
+	var orders []pendingOrder
+	if err := json.Unmarshal(data, &orders); err != nil {
+		return nil, fmt.Errorf("failed to parse order journal: %w", err)
+	}
+	return orders, nil
+}
+
+func (j *orderJournal) save(orders []pendingOrder) error {
+	if len(orders) == 0 {
+		if err := os.Remove(j.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove order journal: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(orders, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode order journal: %w", err)
+	}
+
+	tmp := j.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write order journal: %w", err)
+	}
+	if err := os.Rename(tmp, j.path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write order journal: %w", err)
+	}
+	return nil
+}
+
+// Record stores an order, replacing earlier orders for any of its domains
+func (j *orderJournal) Record(order pendingOrder) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	orders, err := j.load()
+	if err != nil {
+		return err
+	}
+
+	kept := []pendingOrder{order}
+	for _, existing := range orders {
+		if !sharesDomain(existing.Domains, order.Domains) {
+			kept = append(kept, existing)
+		}
+	}
+	return j.save(kept)
+}
+
+// Find returns the recorded order for a domain
+func (j *orderJournal) Find(domain string) (pendingOrder, bool, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	orders, err := j.load()
+	if err != nil {
+		return pendingOrder{}, false, err
+	}
+	for _, order := range orders {
+		if sharesDomain(order.Domains, []string{domain}) {
+			return order, true, nil
+		}
+	}
+	return pendingOrder{}, false, nil
+}
+
+// Remove forgets the recorded order for a domain
+func (j *orderJournal) Remove(domain string) error {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	orders, err := j.load()
+	if err != nil {
+		return err
+	}
+
+	var kept []pendingOrder
+	for _, order := range orders {
+		if !sharesDomain(order.Domains, []string{domain}) {
+			kept = append(kept, order)
+		}
+	}
+	if len(kept) == len(orders) {
+		return nil
+	}
+	return j.save(kept)
+}
+
+// OlderThan returns the recorded orders created before t
+func (j *orderJournal) OlderThan(t time.Time) ([]pendingOrder, error) {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	orders, err := j.load()
+	if err != nil {
+		return nil, err
+	}
+
+	var stale []pendingOrder
+	for _, order := range orders {
+		if order.CreatedAt.Before(t) {
+			stale = append(stale, order)
+		}
+	}
+	return stale, nil
+}
+
+// Len returns the number of recorded orders, zero if the journal can't be read
+func (j *orderJournal) Len() int {
+	j.mu.Lock()
+	defer j.mu.Unlock()
+
+	orders, _ := j.load()
+	return len(orders)
+}
+
+func sharesDomain(a, b []string) bool {
+	for _, x := range a {
+		for _, y := range b {
+			if x == y {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// orderRecorder is an HTTP transport that journals every order the CA creates,
+// as lego places orders internally and never hands out their URLs
+type orderRecorder struct {
+	next    http.RoundTripper
+	journal *orderJournal
+	logger  *log.Logger
+}
+
+func newOrderRecorder(next http.RoundTripper, journal *orderJournal, logger *log.Logger) *orderRecorder {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	if logger == nil {
+		logger = log.New(os.Stdout, "[ACME] ", log.LstdFlags)
+	}
+
+	return &orderRecorder{next: next, journal: journal, logger: logger}
+}
+
+func (o *orderRecorder) RoundTrip(req *http.Request) (*http.Response, error) {
+	resp, err := o.next.RoundTrip(req)
+	if err != nil || req.Method != http.MethodPost || resp.StatusCode != http.StatusCreated {
+		return resp, err
+	}
+
+	location := resp.Header.Get("Location")
+	if location == "" {
+		return resp, nil
+	}
+
+	body, readErr := io.ReadAll(io.LimitReader(resp.Body, maxOrderSize))
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(body))
+	if readErr != nil {
+		return resp, nil
+	}
+
+	// Accounts are created with 201 as well; only orders have a finalize URL
+	var order acme.Order
+	if json.Unmarshal(body, &order) != nil || order.Finalize == "" || len(order.Identifiers) == 0 {
+		return resp, nil
+	}
+
+	pending := pendingOrder{URL: location, CreatedAt: time.Now().UTC()}
+	for _, identifier := range order.Identifiers {
+		pending.Domains = append(pending.Domains, identifier.Value)
+	}
+	if err := o.journal.Record(pending); err != nil {
+		o.logger.Printf("Warning: failed to record order %s: %v", location, err)
+	}
+	return resp, nil
+}
+
+// pendingKeyPath returns where the key of a certificate being ordered is kept
+// until the order completes
+func (c *ACMEClient) pendingKeyPath(domain string) string {
+	return filepath.Join(c.storagePath, storageName(domain)+pendingKeySuffix)
+}
+
+// orderKey returns the key a new certificate for domain is ordered with: the
+// key of an interrupted order when there is one, a newly generated key otherwise.
+// The key is stored before ordering so an order resumed later can be finalized.
+func (c *ACMEClient) orderKey(ctx context.Context, domain string) (crypto.PrivateKey, error) {
+	if keyFile, _ := c.externalKey(domain); keyFile != "" {
+		return loadExternalKey(keyFile)
+	}
+
+	path := c.pendingKeyPath(domain)
+
+	data, err := os.ReadFile(path)
+	if err == nil {
+		if encryption.IsSealed(data) {
+			if data, err = c.openPrivateKey(ctx, data); err != nil {
+				return nil, fmt.Errorf("failed to decrypt pending key: %w", err)
+			}
+		}
+		return certcrypto.ParsePEMPrivateKey(data)
+	}
+	if !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read pending key: %w", err)
+	}
+
+	key, err := certcrypto.GeneratePrivateKey(c.domainKeyType(domain))
+	if err != nil {
+		return nil, fmt.Errorf("failed to generate private key: %w", err)
+	}
+
+	data = certcrypto.PEMEncode(key)
+	if c.encryption != nil {
+		if data, err = c.sealPrivateKey(ctx, data); err != nil {
+			return nil, fmt.Errorf("failed to encrypt pending key: %w", err)
+		}
+	}
+	if err := os.WriteFile(path, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to save pending key: %w", err)
+	}
+	return key, nil
+}
+
+// finishOrder forgets the order and pending key of a domain once the order
+// completed or can't be resumed
+func (c *ACMEClient) finishOrder(domain string) {
+	if err := c.orders.Remove(domain); err != nil {
+		c.logger.Printf("Warning: failed to update order journal for %s: %v", domain, err)
+	}
+	if err := os.Remove(c.pendingKeyPath(domain)); err != nil && !os.IsNotExist(err) {
+		c.logger.Printf("Warning: failed to remove pending key for %s: %v", domain, err)
+	}
+}
+
+// core returns a client for the ACME resources lego doesn't expose, such as
+// orders and authorizations, acting as the registered account
+func (c *ACMEClient) core() (*api.Core, error) {
+	if err := c.ensureRegistered(); err != nil {
+		return nil, fmt.Errorf("failed to register: %w", err)
+	}
+
+	core, err := api.New(c.httpClient, "", c.caDirURL, c.user.Registration.URI, c.user.key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to CA: %w", err)
+	}
+	return core, nil
+}
+
+// DeactivateStaleOrders deactivates the pending authorizations of journaled
+// orders older than maxAge and forgets those orders. Such orders were cut short
+// and never resumed, and their authorizations count against the CA's limit on
+// pending authorizations until they expire. It returns the number deactivated.
+func (c *ACMEClient) DeactivateStaleOrders(ctx context.Context, maxAge time.Duration) (int, error) {
+	orders, err := c.orders.OlderThan(time.Now().Add(-maxAge))
+	if err != nil {
+		return 0, err
+	}
+	// The journal is shared with the clients of other CAs, and an order whose
+	// domain is being ordered may be resumed right now
+	var stale []pendingOrder
+	for _, pending := range orders {
+		if c.ownsOrder(pending.URL) && !c.operations.running(pending.Domains) {
+			stale = append(stale, pending)
+		}
+	}
+	if len(stale) == 0 {
+		return 0, nil
+	}
+
+	core, err := c.bind(ctx).core()
+	if err != nil {
+		return 0, err
+	}
+
+	deactivated := 0
+	for _, pending := range stale {
+		order, err := core.Orders.Get(pending.URL)
+		if err != nil {
+			c.logger.Printf("Forgetting stale order %s that can no longer be fetched: %v", pending.URL, err)
+		}
+
+		for _, authzURL := range order.Authorizations {
+			authz, err := core.Authorizations.Get(authzURL)
+			if err != nil {
+				c.logger.Printf("Warning: failed to fetch authorization %s: %v", authzURL, err)
+				continue
+			}
+			if authz.Status != acme.StatusPending {
+				continue
+			}
+			if err := core.Authorizations.Deactivate(authzURL); err != nil {
+				c.logger.Printf("Warning: failed to deactivate authorization %s for %s: %v", authzURL, authz.Identifier.Value, err)
+				continue
+			}
+			deactivated++
+		}
+
+		for _, domain := range pending.Domains {
+			c.finishOrder(domain)
+		}
+	}
+	return deactivated, nil
+}
+
+// resumeOrder completes an order for domain left behind by an earlier run,
+// finalizing it with key, or the domain's external CSR if key is nil, when the
+// CA is still waiting for a CSR. It returns nil
+// when there is no order to resume, in which case a new order is placed; the CA
+// reuses the authorizations of an order that is still pending.
+func (c *ACMEClient) resumeOrder(domain string, key crypto.PrivateKey) (*certificate.Resource, error) {
+	pending, found, err := c.orders.Find(domain)
+	if err != nil || !found {
+		return nil, err
+	}
+
+	core, err := c.core()
+	if err != nil {
+		return nil, err
+	}
+
+	order, err := core.Orders.Get(pending.URL)
+	if err != nil {
+		c.logger.Printf("Order %s for %s can no longer be fetched, placing a new one: %v", pending.URL, domain, err)
+		return nil, c.orders.Remove(domain)
+	}
+
+	c.logger.Printf("Resuming %s order %s for %s placed at %s", order.Status, pending.URL, domain,
+		pending.CreatedAt.Format(time.RFC3339))
+
+	// Without a key the domain is ordered for its external CSR
+	var csr *x509.CertificateRequest
+	if key == nil {
+		_, csrFile := c.externalKey(domain)
+		if csr, err = loadExternalCSR(csrFile, domain); err != nil {
+			return nil, err
+		}
+	}
+
+	if order.Status == acme.StatusReady {
+		if csr == nil {
+			if csr, err = c.createCSR(domain, pending.Domains, key); err != nil {
+				return nil, err
+			}
+		}
+		if order, err = core.Orders.UpdateForCSR(order.Finalize, csr.Raw); err != nil {
+			return nil, fmt.Errorf("failed to finalize order: %w", err)
+		}
+	}
+
+	deadline := time.Now().Add(orderPollTimeout)
+	for order.Status == acme.StatusProcessing && time.Now().Before(deadline) {
+		time.Sleep(orderPollInterval)
+		if order, err = core.Orders.Get(pending.URL); err != nil {
+			return nil, fmt.Errorf("failed to poll order: %w", err)
+		}
+	}
+
+	if order.Status != acme.StatusValid {
+		if order.Status == acme.StatusPending {
+			c.logger.Printf("Authorizations of order %s are still pending, placing a new order", pending.URL)
+		} else {
+			c.logger.Printf("Order %s for %s is %s and can't be resumed", pending.URL, domain, order.Status)
+		}
+		return nil, c.orders.Remove(domain)
+	}
+
+	cert, issuer, err := c.downloadCertificate(core, order.Certificate)
+	if err != nil {
+		return nil, fmt.Errorf("failed to download certificate: %w", err)
+	}
+
+	var keyPEM []byte
+	if key == nil {
+		if !certMatchesCSR(cert, csr) {
+			c.logger.Printf("Certificate of order %s doesn't match the CSR, placing a new order", pending.URL)
+			return nil, c.orders.Remove(domain)
+		}
+	} else {
+		keyPEM = certcrypto.PEMEncode(key)
+		if _, err := tls.X509KeyPair(cert, keyPEM); err != nil {
+			c.logger.Printf("Certificate of order %s doesn't match the stored key, placing a new order: %v", pending.URL, err)
+			return nil, c.orders.Remove(domain)
+		}
+	}
+
+	return &certificate.Resource{
+		Domain:            domain,
+		CertURL:           order.Certificate,
+		CertStableURL:     order.Certificate,
+		PrivateKey:        keyPEM,
+		Certificate:       cert,
+		IssuerCertificate: issuer,
+	}, nil
+}
+
+// downloadCertificate fetches the certificate of a finalized order with the
+// preferred chain if the CA offers it, like lego does for new orders
+func (c *ACMEClient) downloadCertificate(core *api.Core, certURL string) ([]byte, []byte, error) {
+	if c.chain == "" {
+		return core.Certificates.Get(certURL, true)
+	}
+
+	alternates, err := core.Certificates.GetAll(certURL, true)
+	if err != nil {
+		return nil, nil, err
+	}
+	var fallback *acme.RawCertificate
+	for url, raw := range alternates {
+		if url == certURL {
+			fallback = raw
+		}
+		if chainRoot(raw.Issuer) == c.chain {
+			return raw.Cert, raw.Issuer, nil
+		}
+	}
+	if fallback == nil {
+		return nil, nil, fmt.Errorf("certificate %s not found", certURL)
+	}
+	c.logger.Printf("Preferred chain %q is not offered for %s, using the default", c.chain, certURL)
+	return fallback.Cert, fallback.Issuer, nil
+}
+
+// CleanupStaleOrders deactivates the pending authorizations left behind by
+// orders unfinished for longer than acme.stale_order_age
+func (cm *CertificateManager) CleanupStaleOrders(ctx context.Context) {
+	maxAge, err := cm.config.GetStaleOrderAge()
+	if err != nil || maxAge <= 0 {
+		return
+	}
+
+	deactivated, err := cm.acmeClient.DeactivateStaleOrders(ctx, maxAge)
+	if err != nil {
+		cm.logger.Printf("Warning: stale order cleanup failed: %v", err)
+		return
+	}
+	if deactivated > 0 {
+		cm.logger.Printf("Deactivated %d pending authorizations of stale orders", deactivated)
+	}
+}
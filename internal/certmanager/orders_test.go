@@ -0,0 +1,174 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/certcrypto"
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestOrderJournal(t *testing.T) {
+	journal := newOrderJournal(setupTestDir(t))
+
+	require.NoError(t, journal.Record(pendingOrder{URL: "https://ca/order/1", Domains: []string{"example.com"}}))
+	require.NoError(t, journal.Record(pendingOrder{URL: "https://ca/order/2", Domains: []string{"api.example.com"}}))
+	// A new order for a domain replaces the earlier one
+	require.NoError(t, journal.Record(pendingOrder{URL: "https://ca/order/3", Domains: []string{"example.com", "www.example.com"}}))
+	assert.Equal(t, 2, journal.Len())
+
+	order, found, err := journal.Find("www.example.com")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "https://ca/order/3", order.URL)
+
+	require.NoError(t, journal.Remove("example.com"))
+	_, found, err = journal.Find("www.example.com")
+	require.NoError(t, err)
+	assert.False(t, found)
+
+	require.NoError(t, journal.Remove("api.example.com"))
+	assert.NoFileExists(t, journal.path)
+}
+
+func TestOrderRecorder_RecordsCreatedOrders(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/new-order":
+			w.Header().Set("Location", "https://ca.test/order/42")
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"status":"pending","identifiers":[{"type":"dns","value":"example.com"}],"finalize":"https://ca.test/finalize/42"}`)
+		case "/new-account":
+			w.Header().Set("Location", "https://ca.test/acct/1")
+			w.WriteHeader(http.StatusCreated)
+			io.WriteString(w, `{"status":"valid"}`)
+		}
+	}))
+	defer server.Close()
+
+	journal := newOrderJournal(setupTestDir(t))
+	client := &http.Client{Transport: newOrderRecorder(http.DefaultTransport, journal, nil)}
+
+	for _, path := range []string{"/new-account", "/new-order"} {
+		resp, err := client.Post(server.URL+path, "application/jose+json", strings.NewReader("{}"))
+		require.NoError(t, err)
+		body, err := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		require.NoError(t, err)
+		assert.NotEmpty(t, body, "response body must stay readable")
+	}
+
+	assert.Equal(t, 1, journal.Len())
+	order, found, err := journal.Find("example.com")
+	require.NoError(t, err)
+	require.True(t, found)
+	assert.Equal(t, "https://ca.test/order/42", order.URL)
+}
+
+func TestLoadAccountKey_ReusesStoredKey(t *testing.T) {
+	path := filepath.Join(setupTestDir(t), accountKeyName("https://ca.test/directory"))
+
+	first, err := loadAccountKey(path, "RSA2048", nil)
+	require.NoError(t, err)
+	second, err := loadAccountKey(path, "RSA2048", nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, certcrypto.PEMEncode(first), certcrypto.PEMEncode(second))
+}
+
+func TestACMEClient_OrderKeyIsKeptUntilFinished(t *testing.T) {
+	testDir := setupTestDir(t)
+	client := &ACMEClient{
+		storagePath: testDir,
+		keyType:     certcrypto.EC256,
+		orders:      newOrderJournal(testDir),
+		logger:      log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+
+	first, err := client.orderKey(context.Background(), "example.com")
+	require.NoError(t, err)
+	second, err := client.orderKey(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, certcrypto.PEMEncode(first), certcrypto.PEMEncode(second))
+
+	client.finishOrder("example.com")
+	assert.NoFileExists(t, client.pendingKeyPath("example.com"))
+}
+
+// fakeACMEServer serves a directory, nonces, one order and its authorizations,
+// without checking request signatures
+func fakeACMEServer(t *testing.T, authzStatus map[string]string) (*httptest.Server, *[]string) {
+	var deactivated []string
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce")
+		switch {
+		case r.URL.Path == "/directory":
+			fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order"}`, server.URL)
+		case r.URL.Path == "/nonce":
+			w.WriteHeader(http.StatusOK)
+		case r.URL.Path == "/order/1":
+			fmt.Fprintf(w, `{"status":"pending","identifiers":[{"type":"dns","value":"example.com"}],"authorizations":["%[1]s/authz/a","%[1]s/authz/b"],"finalize":"%[1]s/finalize/1"}`, server.URL)
+		case strings.HasPrefix(r.URL.Path, "/authz/"):
+			name := strings.TrimPrefix(r.URL.Path, "/authz/")
+			body, _ := io.ReadAll(r.Body)
+			// POST-as-GET has an empty payload, deactivation doesn't
+			if !strings.Contains(string(body), `"payload":""`) {
+				deactivated = append(deactivated, name)
+				authzStatus[name] = "deactivated"
+			}
+			fmt.Fprintf(w, `{"status":%q,"identifier":{"type":"dns","value":"example.com"}}`, authzStatus[name])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	t.Cleanup(server.Close)
+	return server, &deactivated
+}
+
+func TestACMEClient_DeactivateStaleOrders(t *testing.T) {
+	server, deactivated := fakeACMEServer(t, map[string]string{"a": "pending", "b": "valid"})
+
+	testDir := setupTestDir(t)
+	key, err := loadAccountKey(filepath.Join(testDir, "account.key"), "RSA2048", nil)
+	require.NoError(t, err)
+
+	client := &ACMEClient{
+		user:        &ACMEUser{key: key, Registration: &registration.Resource{URI: server.URL + "/account/1"}},
+		httpClient:  server.Client(),
+		caDirURL:    server.URL + "/directory",
+		orders:      newOrderJournal(testDir),
+		operations:  newRunningOperations(),
+		storagePath: testDir,
+		logger:      log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+
+	require.NoError(t, client.orders.Record(pendingOrder{
+		URL: server.URL + "/order/1", Domains: []string{"example.com"}, CreatedAt: time.Now().Add(-48 * time.Hour),
+	}))
+	require.NoError(t, client.orders.Record(pendingOrder{
+		URL: server.URL + "/order/2", Domains: []string{"api.example.com"}, CreatedAt: time.Now(),
+	}))
+
+	n, err := client.DeactivateStaleOrders(context.Background(), 24*time.Hour)
+	require.NoError(t, err)
+	assert.Equal(t, 1, n)
+	assert.Equal(t, []string{"a"}, *deactivated)
+
+	// The stale order is forgotten, the recent one kept for resumption
+	_, found, _ := client.orders.Find("example.com")
+	assert.False(t, found)
+	_, found, _ = client.orders.Find("api.example.com")
+	assert.True(t, found)
+}
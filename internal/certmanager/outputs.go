@@ -0,0 +1,70 @@
+package certmanager
+
+import (
+	"bytes"
+	"encoding/pem"
+	"fmt"
+	"os"
+	"path/filepath"
+)
+
+// File name suffixes of the output variants written next to the .crt and .key
+// files, for consumers that expect the chain or chain and key in one file
+const (
+	fullChainSuffix = ".fullchain.pem" // leaf followed by intermediates
+	combinedSuffix  = ".combined.pem"  // full chain followed by the private key, as HAProxy expects
+)
+
+// fullChain returns the leaf followed by the intermediates of cert. The stored
+// bundle already holds them for ordered certificates; imported and adopted
+// ones may carry the intermediates only in the issuer file.
+func fullChain(cert *Certificate) []byte {
+	chain := append([]byte{}, cert.Certificate...)
+	if len(chain) > 0 && chain[len(chain)-1] != '\n' {
+		chain = append(chain, '\n')
+	}
+
+	rest := cert.IssuerCert
+	for {
+		var block *pem.Block
+		block, rest = pem.Decode(rest)
+		if block == nil {
+			return chain
+		}
+		encoded := pem.EncodeToMemory(block)
+		if !bytes.Contains(chain, bytes.TrimSpace(encoded)) {
+			chain = append(chain, encoded...)
+		}
+	}
+}
+
+// writeOutputs writes the output variants of a stored certificate. The combined
+// file is only written with a plaintext key at hand, and removed when disabled.
+// A cached OCSP response of a replaced certificate is removed.
+func (c *ACMEClient) writeOutputs(cert *Certificate) error {
+	name := storageName(cert.Domain)
+	chain := fullChain(cert)
+
+	if err := os.WriteFile(filepath.Join(c.storagePath, name+fullChainSuffix), chain, 0644); err != nil {
+		return fmt.Errorf("failed to save full chain file: %w", err)
+	}
+	// A server stapling from file must not get the response of the previous certificate
+	if err := NewOCSPStapler(c.storagePath, 0).Remove(cert); err != nil {
+		return err
+	}
+
+	combinedPath := filepath.Join(c.storagePath, name+combinedSuffix)
+	if !c.combinedPEM {
+		if err := os.Remove(combinedPath); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove combined PEM file: %w", err)
+		}
+		return nil
+	}
+	if len(cert.PrivateKey) == 0 {
+		return nil
+	}
+	if err := os.WriteFile(combinedPath, append(chain, cert.PrivateKey...), 0600); err != nil {
+		return fmt.Errorf("failed to save combined PEM file: %w", err)
+	}
+	return nil
+}
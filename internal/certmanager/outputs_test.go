@@ -0,0 +1,70 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/pem"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// countPEM returns the number of PEM blocks of each type in data
+func countPEM(data []byte) map[string]int {
+	counts := make(map[string]int)
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return counts
+		}
+		counts[block.Type]++
+	}
+}
+
+func TestFullChain(t *testing.T) {
+	leaf := createTestCertificate("example.com", 90)
+	issuer := createTestCertificate("Test CA", 365).Certificate
+
+	// An imported leaf gets its intermediate from the issuer file
+	cert := &Certificate{Domain: "example.com", Certificate: leaf.Certificate, IssuerCert: issuer}
+	assert.Equal(t, map[string]int{"CERTIFICATE": 2}, countPEM(fullChain(cert)))
+
+	// An ordered bundle already holds it
+	cert.Certificate = append(append([]byte{}, leaf.Certificate...), issuer...)
+	assert.Equal(t, map[string]int{"CERTIFICATE": 2}, countPEM(fullChain(cert)))
+}
+
+func TestACMEClient_WritesOutputVariants(t *testing.T) {
+	testDir := setupTestDir(t)
+	client := &ACMEClient{
+		storagePath: testDir,
+		combinedPEM: true,
+		logger:      log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+
+	cert := createTestCertificate("*.example.com", 90)
+	cert.IssuerCert = createTestCertificate("Test CA", 365).Certificate
+	require.NoError(t, client.saveCertificate(context.Background(), cert))
+
+	chain, err := os.ReadFile(filepath.Join(testDir, "_.example.com"+fullChainSuffix))
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"CERTIFICATE": 2}, countPEM(chain))
+
+	combinedPath := filepath.Join(testDir, "_.example.com"+combinedSuffix)
+	combined, err := os.ReadFile(combinedPath)
+	require.NoError(t, err)
+	assert.Equal(t, map[string]int{"CERTIFICATE": 2, "RSA PRIVATE KEY": 1}, countPEM(combined))
+	info, err := os.Stat(combinedPath)
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+	// Disabling the combined file removes it
+	client.combinedPEM = false
+	require.NoError(t, client.SaveChain(context.Background(), cert))
+	_, err = os.Stat(combinedPath)
+	assert.True(t, os.IsNotExist(err))
+}
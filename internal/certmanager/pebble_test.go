@@ -0,0 +1,111 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/pebble"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// startPebble points at the Pebble in PEBBLE_DIR_URL, which must accept
+// challenges without validating them, or starts the pebble in PATH. The test
+// is skipped without either.
+func startPebble(t *testing.T, opts pebble.Options) (dirURL, caCert string) {
+	if dirURL, caCert, ok := pebble.FromEnvironment(); ok {
+		return dirURL, caCert
+	}
+
+	opts.AlwaysValid = true
+	instance, err := pebble.Start(context.Background(), opts)
+	if err != nil {
+		t.Skipf("Pebble is not available, set %s or install pebble: %v", pebble.EnvDirURL, err)
+	}
+	t.Cleanup(func() {
+		if t.Failed() {
+			t.Logf("Pebble output:\n%s", instance.Output())
+		}
+		instance.Close()
+	})
+	return instance.DirURL, instance.CACert
+}
+
+// pebbleManager creates a certificate manager ordering domain from Pebble
+func pebbleManager(t *testing.T, dirURL, caCert, domain string) *CertificateManager {
+	storage := setupTestDir(t)
+	configFile := filepath.Join(storage, "config.yaml")
+	require.NoError(t, os.WriteFile(configFile, []byte(fmt.Sprintf(`
+traefik_api: "http://localhost:8080/api"
+email: "ops@example.com"
+notification:
+  smtp_host: "localhost"
+  smtp_port: 25
+domains:
+  - service: "web"
+    domain: %q
+acme:
+  ca_dir_url: %q
+  ca_cert: %q
+  key_type: "EC256"
+  retry_attempts: 3
+  retry_backoff: "100ms"
+certificates:
+  storage_path: %q
+dns:
+  disable_precheck: true
+`, domain, dirURL, caCert, filepath.Join(storage, "certs"))), 0644))
+
+	cfg, err := config.LoadConfig(configFile)
+	require.NoError(t, err)
+	cm, err := NewCertificateManager(cfg, nil, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+	require.NoError(t, err)
+	return cm
+}
+
+func TestPebble_IssueAndRenew(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integration test")
+	}
+	dirURL, caCert := startPebble(t, pebble.Options{})
+	cm := pebbleManager(t, dirURL, caCert, "pebble.example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+
+	require.NoError(t, cm.RequestCertificate(ctx, "pebble.example.com"))
+	issued, err := cm.GetCertificate("pebble.example.com")
+	require.NoError(t, err)
+	assert.Contains(t, issued.SANs, "pebble.example.com")
+	assert.True(t, issued.ExpiresAt.After(time.Now()))
+	certPath, keyPath := cm.GetCertificatePaths("pebble.example.com")
+	assert.FileExists(t, certPath)
+	assert.FileExists(t, keyPath)
+
+	require.NoError(t, cm.RenewCertificate(ctx, "pebble.example.com"))
+	renewed, err := cm.GetCertificate("pebble.example.com")
+	require.NoError(t, err)
+	assert.NotEqual(t, issued.SerialNumber, renewed.SerialNumber)
+}
+
+func TestPebble_RetriesRejectedNonces(t *testing.T) {
+	if testing.Short() {
+		t.Skip("integration test")
+	}
+	if _, _, ok := pebble.FromEnvironment(); ok {
+		t.Skip("needs a Pebble started by the test to reject nonces")
+	}
+	// Chaos: Pebble rejects half the nonces, which lego and the retries absorb
+	dirURL, caCert := startPebble(t, pebble.Options{NonceReject: 50})
+	cm := pebbleManager(t, dirURL, caCert, "chaos.example.com")
+
+	ctx, cancel := context.WithTimeout(context.Background(), 2*time.Minute)
+	defer cancel()
+	require.NoError(t, cm.RequestCertificate(ctx, "chaos.example.com"))
+}
@@ -0,0 +1,48 @@
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// ErrUnknownProfile is returned when a domain asks for a certificate profile
+// the CA doesn't offer
+var ErrUnknownProfile = errors.New("unknown ACME profile")
+
+// profile returns the CA profile requested for domain, empty for the default
+func (c *ACMEClient) profile(domain string) string {
+	if c.profileFor == nil {
+		return ""
+	}
+	return c.profileFor(domain)
+}
+
+// checkProfile confirms the CA advertises profile in its directory, so a typo
+// fails before an order is placed rather than being rejected or ignored by the CA
+func (c *ACMEClient) checkProfile(profile string) error {
+	if profile == "" {
+		return nil
+	}
+
+	core, err := c.core()
+	if err != nil {
+		return err
+	}
+
+	offered := core.GetDirectory().Meta.Profiles
+	if _, ok := offered[profile]; ok {
+		return nil
+	}
+	if len(offered) == 0 {
+		return fmt.Errorf("%w %q: the CA doesn't support certificate profiles", ErrUnknownProfile, profile)
+	}
+
+	names := make([]string, 0, len(offered))
+	for name := range offered {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return fmt.Errorf("%w %q: the CA offers %s", ErrUnknownProfile, profile, strings.Join(names, ", "))
+}
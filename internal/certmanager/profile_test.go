@@ -0,0 +1,123 @@
+package certmanager
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/registration"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestACMEClient_CheckProfile(t *testing.T) {
+	var server *httptest.Server
+	server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `{"newNonce":"%[1]s/nonce","newAccount":"%[1]s/account","newOrder":"%[1]s/order",`+
+			`"meta":{"profiles":{"classic":"90 days","shortlived":"6 days"}}}`, server.URL)
+	}))
+	defer server.Close()
+
+	testDir := setupTestDir(t)
+	key, err := loadAccountKey(filepath.Join(testDir, "account.key"), "RSA2048", nil)
+	require.NoError(t, err)
+
+	client := &ACMEClient{
+		user:       &ACMEUser{key: key, Registration: &registration.Resource{URI: server.URL + "/account/1"}},
+		httpClient: server.Client(),
+		caDirURL:   server.URL + "/directory",
+		profileFor: func(domain string) string { return map[string]string{"short.example.com": "shortlived"}[domain] },
+		logger:     log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+
+	assert.Equal(t, "shortlived", client.profile("short.example.com"))
+	assert.Equal(t, "", client.profile("example.com"))
+
+	assert.NoError(t, client.checkProfile(""))
+	assert.NoError(t, client.checkProfile("shortlived"))
+
+	err = client.checkProfile("short-lived")
+	assert.True(t, errors.Is(err, ErrUnknownProfile))
+	assert.Contains(t, err.Error(), "the CA offers classic, shortlived")
+}
+
+func TestRenewalPolicy_ProfileWindow(t *testing.T) {
+	policy := NewRenewalPolicy(30, 72*time.Hour, nil)
+	policy.renewBefore = func(domain string) (time.Duration, bool) {
+		if domain == "short.example.com" {
+			return 48 * time.Hour, true
+		}
+		return 0, false
+	}
+
+	now := time.Now()
+	short := &Certificate{Domain: "short.example.com", ExpiresAt: now.Add(4 * 24 * time.Hour)}
+	assert.False(t, policy.NeedsRenewal("short.example.com", short, now), "a 6-day certificate with 4 days left isn't due")
+	assert.True(t, policy.NeedsRenewal("short.example.com", short, now.Add(50*time.Hour)))
+
+	// The jitter is capped to the first half of the short window
+	renewAt := policy.RenewAt("short.example.com", short)
+	assert.False(t, renewAt.Before(short.ExpiresAt.Add(-48*time.Hour)))
+	assert.True(t, renewAt.Before(short.ExpiresAt.Add(-24*time.Hour)))
+
+	// Past the middle of the window renewal is urgent
+	assert.True(t, policy.Due("short.example.com", short, short.ExpiresAt.Add(-23*time.Hour)))
+
+	// Other domains keep renewal_days
+	long := &Certificate{Domain: "example.com", ExpiresAt: now.Add(20 * 24 * time.Hour)}
+	assert.True(t, policy.NeedsRenewal("example.com", long, now))
+}
+
+func TestRenewalPolicy_SubDailyWindow(t *testing.T) {
+	policy := NewRenewalPolicy(30, 72*time.Hour, nil)
+	policy.renewBefore = func(domain string) (time.Duration, bool) { return 8 * time.Hour, true }
+
+	// A 24-hour certificate, as step-ca issues by default
+	now := time.Now()
+	cert := &Certificate{Domain: "app.lab.example.com", ExpiresAt: now.Add(24 * time.Hour)}
+	assert.False(t, policy.NeedsRenewal(cert.Domain, cert, now))
+	assert.False(t, policy.Due(cert.Domain, cert, now.Add(15*time.Hour)))
+	assert.True(t, policy.NeedsRenewal(cert.Domain, cert, now.Add(17*time.Hour)))
+
+	renewAt := policy.RenewAt(cert.Domain, cert)
+	assert.False(t, renewAt.Before(cert.ExpiresAt.Add(-8*time.Hour)))
+	assert.True(t, renewAt.Before(cert.ExpiresAt.Add(-4*time.Hour)))
+	assert.True(t, policy.Due(cert.Domain, cert, cert.ExpiresAt.Add(-3*time.Hour)))
+}
+
+func TestRenewalPolicy_ProfileLifetime(t *testing.T) {
+	policy := NewRenewalPolicy(30, 72*time.Hour, nil)
+	profiles := map[string]string{"short.example.com": "shortlived", "server.example.com": "tlsserver"}
+	policy.profileFor = func(domain string) string { return profiles[domain] }
+
+	// A 6-day shortlived certificate renews with a third of its lifetime left
+	// instead of always being inside the 30-day window
+	now := time.Now()
+	short := &Certificate{Domain: "short.example.com", NotBefore: now, ExpiresAt: now.Add(6 * 24 * time.Hour)}
+	assert.False(t, policy.NeedsRenewal(short.Domain, short, now))
+	assert.False(t, policy.NeedsRenewal(short.Domain, short, now.Add(3*24*time.Hour)))
+	assert.True(t, policy.NeedsRenewal(short.Domain, short, now.Add(4*24*time.Hour+time.Hour)))
+	renewAt := policy.RenewAt(short.Domain, short)
+	assert.False(t, renewAt.Before(short.ExpiresAt.Add(-48*time.Hour)))
+	assert.True(t, renewAt.Before(short.ExpiresAt.Add(-24*time.Hour)))
+
+	// Certificates living long enough for renewal_days keep it
+	server := &Certificate{Domain: "server.example.com", NotBefore: now, ExpiresAt: now.Add(90 * 24 * time.Hour)}
+	window, adjusted := policy.window(server.Domain, server)
+	assert.Equal(t, 30*24*time.Hour, window)
+	assert.False(t, adjusted)
+
+	// Without a profile, a certificate shorter than renewal_days is always due
+	plain := &Certificate{Domain: "example.com", NotBefore: now, ExpiresAt: now.Add(6 * 24 * time.Hour)}
+	assert.True(t, policy.NeedsRenewal(plain.Domain, plain, now))
+
+	// renew_before set for the profile takes precedence
+	policy.renewBefore = func(domain string) (time.Duration, bool) { return 72 * time.Hour, domain == short.Domain }
+	assert.True(t, policy.NeedsRenewal(short.Domain, short, now.Add(3*24*time.Hour+time.Hour)))
+}
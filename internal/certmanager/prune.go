@@ -0,0 +1,173 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// orphanedFileName records since when certificates have been orphaned
+const orphanedFileName = ".orphaned.json"
+
+// PruneOrphanedCertificates marks certificates that no configured, discovered
+// or adopted domain refers to as orphaned and notifies operators. Once one
+// stayed orphaned for the grace period it is revoked, unless it was issued by
+// the internal CA, imported or has expired, and deleted from storage and the
+// Traefik TLS configuration. A failed revocation is retried on the next run.
+func (cm *CertificateManager) PruneOrphanedCertificates(ctx context.Context) {
+	if !cm.config.Certificates.Prune.Enabled {
+		return
+	}
+
+	orphaned, err := cm.loadOrphaned()
+	if err != nil {
+		cm.logger.Printf("Warning: %v", err)
+		return
+	}
+
+	unmanaged := cm.UnmanagedCertificates()
+	now := time.Now()
+	grace := time.Duration(cm.config.Certificates.Prune.GraceDays) * 24 * time.Hour
+
+	// Domains that returned or were adopted, and certificates deleted by hand,
+	// are no longer orphaned
+	for domain := range orphaned {
+		if _, ok := unmanaged[domain]; !ok {
+			delete(orphaned, domain)
+			cm.logger.Printf("Certificate for %s is no longer orphaned and won't be pruned", domain)
+		}
+	}
+
+	domains := make([]string, 0, len(unmanaged))
+	for domain := range unmanaged {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	for _, domain := range domains {
+		cert := unmanaged[domain]
+		since, marked := orphaned[domain]
+		if !marked {
+			orphaned[domain] = now
+			cm.notifyOrphaned(domain, now.Add(grace))
+			continue
+		}
+		if now.Before(since.Add(grace)) {
+			continue
+		}
+
+		if ctx.Err() != nil {
+			break
+		}
+		if err := cm.pruneCertificate(ctx, domain, cert); err != nil {
+			cm.logger.Printf("Failed to prune certificate for %s: %v", domain, err)
+			cm.notifyFailure(domain, "prune", err)
+			continue
+		}
+		delete(orphaned, domain)
+	}
+
+	if err := cm.saveOrphaned(orphaned); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+}
+
+// pruneCertificate revokes cert where its CA can, then deletes it
+func (cm *CertificateManager) pruneCertificate(ctx context.Context, domain string, cert *Certificate) error {
+	if cert.CA != "" && cert.CA != config.IssuerInternalCA && !cert.IsExpired() {
+		release, err := cm.lockDomain(domain)
+		if err != nil {
+			return err
+		}
+		err = cm.acmeClient.RevokeCertificate(ctx, cert, acme.CRLReasonCessationOfOperation)
+		release()
+		if err != nil {
+			return fmt.Errorf("failed to revoke certificate: %w", err)
+		}
+		cm.logger.Printf("Revoked orphaned certificate for %s", domain)
+	}
+
+	if err := cm.DeleteCertificate(domain); err != nil {
+		return err
+	}
+
+	if cm.notifier != nil {
+		msg := notify.Message{
+			Level:   notify.LevelInfo,
+			Event:   notify.EventPruned,
+			Domain:  domain,
+			Subject: fmt.Sprintf("Pruned orphaned certificate for %s", domain),
+			Body:    "The certificate was revoked where its CA allows it and deleted from storage and the Traefik TLS configuration.",
+		}
+		if err := cm.notifier.Send(msg); err != nil {
+			cm.logger.Printf("Failed to send prune notice for %s: %v", domain, err)
+		}
+	}
+	return nil
+}
+
+func (cm *CertificateManager) notifyOrphaned(domain string, pruneAt time.Time) {
+	cm.logger.Printf("Certificate for %s is orphaned and will be pruned on %s", domain, pruneAt.Format(time.RFC3339))
+	if cm.notifier == nil {
+		return
+	}
+
+	msg := notify.Message{
+		Level:   notify.LevelWarning,
+		Event:   notify.EventOrphaned,
+		Domain:  domain,
+		Key:     "orphaned:" + domain,
+		Subject: fmt.Sprintf("Certificate for %s is orphaned", domain),
+		Body: fmt.Sprintf("%s is no longer configured or discovered, so its certificate isn't renewed.\n\n"+
+			"It will be revoked and deleted on %s unless the domain returns or the certificate is adopted.",
+			domain, pruneAt.Format(time.RFC3339)),
+	}
+	if err := cm.notifier.Send(msg); err != nil {
+		cm.logger.Printf("Failed to send orphan notice for %s: %v", domain, err)
+	}
+}
+
+func (cm *CertificateManager) loadOrphaned() (map[string]time.Time, error) {
+	orphaned := make(map[string]time.Time)
+	data, err := os.ReadFile(cm.orphanedPath())
+	if err != nil {
+		if os.IsNotExist(err) {
+			return orphaned, nil
+		}
+		return nil, fmt.Errorf("failed to read orphaned certificates: %w", err)
+	}
+	if err := json.Unmarshal(data, &orphaned); err != nil {
+		return nil, fmt.Errorf("failed to parse orphaned certificates: %w", err)
+	}
+	return orphaned, nil
+}
+
+func (cm *CertificateManager) saveOrphaned(orphaned map[string]time.Time) error {
+	if len(orphaned) == 0 {
+		if err := os.Remove(cm.orphanedPath()); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to save orphaned certificates: %w", err)
+		}
+		return nil
+	}
+
+	data, err := json.MarshalIndent(orphaned, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode orphaned certificates: %w", err)
+	}
+	if err := os.WriteFile(cm.orphanedPath(), data, 0644); err != nil {
+		return fmt.Errorf("failed to save orphaned certificates: %w", err)
+	}
+	return nil
+}
+
+func (cm *CertificateManager) orphanedPath() string {
+	return filepath.Join(cm.config.Certificates.StoragePath, orphanedFileName)
+}
@@ -0,0 +1,76 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_PruneOrphanedCertificates(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, mockClient := newAdoptionTestManager(t, testDir, logger)
+	cm.config.Certificates.Prune.Enabled = true
+	cm.config.Certificates.Prune.GraceDays = 30
+	notifier := &recordingNotifier{}
+	cm.notifier = notifier
+
+	legacy := cm.UnmanagedCertificates()["legacy.example.com"]
+	legacy.CA = "https://acme.example.com/directory"
+
+	// The first run marks the certificate and notifies
+	cm.PruneOrphanedCertificates(context.Background())
+	orphaned, err := cm.loadOrphaned()
+	require.NoError(t, err)
+	assert.Contains(t, orphaned, "legacy.example.com")
+	assert.NotContains(t, orphaned, "example.com")
+	require.Len(t, notifier.messages, 1)
+	assert.Equal(t, "Certificate for legacy.example.com is orphaned", notifier.messages[0].Subject)
+
+	// Within the grace period nothing else happens
+	cm.PruneOrphanedCertificates(context.Background())
+	assert.Len(t, notifier.messages, 1)
+	assert.FileExists(t, filepath.Join(testDir, "legacy.example.com.crt"))
+
+	// A failed revocation keeps the certificate for the next run
+	orphaned["legacy.example.com"] = time.Now().AddDate(0, 0, -31)
+	require.NoError(t, cm.saveOrphaned(orphaned))
+	mockClient.On("RevokeCertificate", legacy, acme.CRLReasonCessationOfOperation).Return(errors.New("CA unavailable")).Once()
+	cm.PruneOrphanedCertificates(context.Background())
+	assert.FileExists(t, filepath.Join(testDir, "legacy.example.com.crt"))
+
+	mockClient.On("RevokeCertificate", legacy, acme.CRLReasonCessationOfOperation).Return(nil).Once()
+	cm.PruneOrphanedCertificates(context.Background())
+	assert.NoFileExists(t, filepath.Join(testDir, "legacy.example.com.crt"))
+	assert.Empty(t, cm.UnmanagedCertificates())
+	assert.NoFileExists(t, filepath.Join(testDir, orphanedFileName))
+	assert.FileExists(t, filepath.Join(testDir, "example.com.crt"))
+	mockClient.AssertExpectations(t)
+}
+
+func TestCertificateManager_PruneOrphanedCertificates_Adopted(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	cm, _ := newAdoptionTestManager(t, testDir, logger)
+	cm.config.Certificates.Prune.Enabled = true
+	cm.config.Certificates.Prune.GraceDays = 30
+
+	cm.PruneOrphanedCertificates(context.Background())
+	require.FileExists(t, filepath.Join(testDir, orphanedFileName))
+
+	// Adopting an orphaned certificate keeps it
+	require.NoError(t, cm.AdoptCertificate("legacy.example.com"))
+	cm.PruneOrphanedCertificates(context.Background())
+	assert.NoFileExists(t, filepath.Join(testDir, orphanedFileName))
+	assert.FileExists(t, filepath.Join(testDir, "legacy.example.com.crt"))
+}
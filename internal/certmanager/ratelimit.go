@@ -0,0 +1,188 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// duplicateWindow is the sliding window Let's Encrypt applies to its duplicate certificate limit
+const duplicateWindow = 7 * 24 * time.Hour
+
+const ledgerFileName = ".issuance-ledger.json"
+
+// ErrDuplicateLimit is returned when issuing a certificate would exceed the duplicate certificate limit
+var ErrDuplicateLimit = errors.New("duplicate certificate limit reached")
+
+// DuplicateLimitError reports when an identical SAN set may be issued again
+type DuplicateLimitError struct {
+	SANs       []string
+	Issued     int
+	Limit      int
+	RetryAfter time.Time
+}
+
+func (e *DuplicateLimitError) Error() string {
+	return fmt.Sprintf("%v: %d certificates for [%s] issued in the last %v (limit %d), retry after %s",
+		ErrDuplicateLimit, e.Issued, strings.Join(e.SANs, ", "), duplicateWindow, e.Limit,
+		e.RetryAfter.Format(time.RFC3339))
+}
+
+func (e *DuplicateLimitError) Is(target error) bool {
+	return target == ErrDuplicateLimit || target == ErrRateLimited
+}
+
+// IssuanceLedger records when each exact SAN set was issued so the manager can
+// stop before the CA locks the set out for a week
+type IssuanceLedger struct {
+	path    string
+	limit   int
+	metrics *Metrics
+	logger  *log.Logger
+	now     func() time.Time
+	mu      sync.Mutex
+	loaded  bool
+	entries map[string][]time.Time // sanKey -> issuance times
+}
+
+// NewIssuanceLedger creates a ledger kept in the file at path, refusing
+// issuance once a SAN set reaches limit
+func NewIssuanceLedger(path string, limit int, metrics *Metrics, logger *log.Logger) *IssuanceLedger {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[IssuanceLedger] ", log.LstdFlags)
+	}
+
+	return &IssuanceLedger{
+		path:    path,
+		limit:   limit,
+		metrics: metrics,
+		logger:  logger,
+		now:     time.Now,
+		entries: make(map[string][]time.Time),
+	}
+}
+
+// Check returns a *DuplicateLimitError when the SAN set has reached the limit
+func (l *IssuanceLedger) Check(sans []string) error {
+	if limited := l.Limited(sans); limited != nil {
+		l.metrics.refuseDuplicate()
+		return limited
+	}
+	return nil
+}
+
+// Limited returns when the SAN set may be issued again if it has reached the
+// limit, nil otherwise. Unlike Check it doesn't count as a refusal.
+func (l *IssuanceLedger) Limited(sans []string) *DuplicateLimitError {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.load()
+
+	issued := l.recent(sanKey(sans))
+	if len(issued) < l.limit {
+		return nil
+	}
+	return &DuplicateLimitError{
+		SANs:       normalizeSANs(sans),
+		Issued:     len(issued),
+		Limit:      l.limit,
+		RetryAfter: issued[len(issued)-l.limit].Add(duplicateWindow),
+	}
+}
+
+// Record notes a successful issuance of the SAN set and persists the ledger
+func (l *IssuanceLedger) Record(sans []string) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.load()
+
+	key := sanKey(sans)
+	l.entries[key] = append(l.recent(key), l.now())
+
+	return l.save()
+}
+
+// recent returns issuance times within the window, oldest first, pruning older ones
+func (l *IssuanceLedger) recent(key string) []time.Time {
+	cutoff := l.now().Add(-duplicateWindow)
+
+	var kept []time.Time
+	for _, t := range l.entries[key] {
+		if t.After(cutoff) {
+			kept = append(kept, t)
+		}
+	}
+
+	if len(kept) == 0 {
+		delete(l.entries, key)
+	} else {
+		l.entries[key] = kept
+	}
+	return kept
+}
+
+func (l *IssuanceLedger) load() {
+	if l.loaded {
+		return
+	}
+	l.loaded = true
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.logger.Printf("Warning: failed to read issuance ledger: %v", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &l.entries); err != nil {
+		l.logger.Printf("Warning: ignoring corrupt issuance ledger %s: %v", l.path, err)
+		l.entries = make(map[string][]time.Time)
+	}
+}
+
+func (l *IssuanceLedger) save() error {
+	data, err := json.MarshalIndent(l.entries, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode issuance ledger: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write issuance ledger: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+func normalizeSANs(sans []string) []string {
+	seen := make(map[string]bool)
+	var out []string
+	for _, san := range sans {
+		san = strings.ToLower(strings.TrimSpace(san))
+		if san == "" || seen[san] {
+			continue
+		}
+		seen[san] = true
+		out = append(out, san)
+	}
+	sort.Strings(out)
+	return out
+}
+
+// sanKey identifies an exact SAN set regardless of order or case
+func sanKey(sans []string) string {
+	return strings.Join(normalizeSANs(sans), ",")
+}
@@ -0,0 +1,158 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestIssuanceLedger_DuplicateLimit(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	now := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	ledger := NewIssuanceLedger(filepath.Join(testDir, ledgerFileName), 2, nil, logger)
+	ledger.now = func() time.Time { return now }
+
+	sans := []string{"www.example.com", "example.com"}
+	require.NoError(t, ledger.Check(sans))
+	require.NoError(t, ledger.Record(sans))
+
+	now = now.Add(time.Hour)
+	require.NoError(t, ledger.Record([]string{"EXAMPLE.com", "www.example.com"}))
+
+	// A different SAN set is tracked separately
+	assert.NoError(t, ledger.Check([]string{"example.com"}))
+
+	err := ledger.Check(sans)
+	require.Error(t, err)
+	assert.True(t, errors.Is(err, ErrDuplicateLimit))
+
+	var limitErr *DuplicateLimitError
+	require.True(t, errors.As(err, &limitErr))
+	assert.Equal(t, 2, limitErr.Issued)
+	assert.Equal(t, []string{"example.com", "www.example.com"}, limitErr.SANs)
+	assert.Equal(t, time.Date(2024, 1, 8, 0, 0, 0, 0, time.UTC), limitErr.RetryAfter)
+
+	// The ledger survives a restart
+	reloaded := NewIssuanceLedger(filepath.Join(testDir, ledgerFileName), 2, nil, logger)
+	reloaded.now = func() time.Time { return now }
+	assert.Error(t, reloaded.Check(sans))
+
+	// Once the oldest issuance leaves the window the set may be issued again
+	now = limitErr.RetryAfter.Add(time.Second)
+	assert.NoError(t, reloaded.Check(sans))
+}
+
+func TestCertificateManager_RequestCertificate_DuplicateLimit(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	ledger := NewIssuanceLedger(filepath.Join(testDir, ledgerFileName), 1, nil, logger)
+	require.NoError(t, ledger.Record([]string{"example.com"}))
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		ledger:     ledger,
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+	}
+
+	err := cm.RequestCertificate(context.Background(), "example.com")
+	assert.True(t, errors.Is(err, ErrDuplicateLimit))
+	mockClient.AssertNotCalled(t, "RequestCertificate", "example.com")
+}
+
+func TestCheckCertificateHealth_DuplicateLimit(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cert := createTestCertificate("example.com", 90)
+	cfg.Domains[0].CSRFile = writeCSR(t, testDir, cert, "example.com", "www.example.com")
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	ledger := NewIssuanceLedger(filepath.Join(testDir, ledgerFileName), 1, nil, logger)
+	cm := &CertificateManager{
+		config: cfg,
+		ledger: ledger,
+		logger: logger,
+		certs: map[string]*Certificate{
+			"example.com":     cert,
+			"api.example.com": createTestCertificate("api.example.com", 90),
+		},
+	}
+
+	// The SAN set of example.com is that of its CSR
+	assert.Equal(t, []string{"example.com", "www.example.com"}, cm.orderSANs("example.com"))
+	require.NoError(t, ledger.Record([]string{"www.example.com", "example.com"}))
+	require.NoError(t, ledger.Record([]string{"api.example.com", "www.api.example.com"}))
+
+	health := cm.CheckCertificateHealth()
+	limit := health["example.com"].DuplicateLimit
+	require.NotNil(t, limit)
+	assert.Equal(t, 1, limit.Issued)
+	assert.Equal(t, 1, limit.Limit)
+	assert.WithinDuration(t, time.Now().Add(duplicateWindow), limit.RetryAfter, time.Minute)
+	// Another SAN set than the one api.example.com is ordered with
+	assert.Nil(t, health["api.example.com"].DuplicateLimit)
+}
+
+func TestCheckCertificateHealth_DuplicateLimitWithWriters(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	cm := &CertificateManager{
+		config: cfg,
+		ledger: NewIssuanceLedger(filepath.Join(testDir, ledgerFileName), 1, nil, logger),
+		logger: logger,
+		certs:  make(map[string]*Certificate),
+	}
+	for i := 0; i < 20; i++ {
+		domain := fmt.Sprintf("host%d.example.com", i)
+		cm.certs[domain] = createTestCertificate(domain, 90)
+	}
+
+	// A writer queueing on cm.mu mustn't block health checks reading the SANs of a domain
+	stop := make(chan struct{})
+	defer close(stop)
+	go func() {
+		for {
+			select {
+			case <-stop:
+				return
+			default:
+				cm.mu.Lock()
+				cm.mu.Unlock()
+			}
+		}
+	}()
+
+	done := make(chan struct{})
+	go func() {
+		defer close(done)
+		for i := 0; i < 100; i++ {
+			cm.CheckCertificateHealth()
+		}
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(10 * time.Second):
+		t.Fatal("CheckCertificateHealth deadlocked")
+	}
+}
@@ -0,0 +1,123 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
+)
+
+// ReissueCertificate orders a certificate with a new key for a managed domain
+// even though its current one is valid, e.g. after a key compromise or to pick
+// up changed SANs or key type. With revoke set, the replaced certificate is
+// revoked for reason once the new one is in place.
+func (cm *CertificateManager) ReissueCertificate(ctx context.Context, domain string, revoke bool, reason uint) (*Certificate, error) {
+	ctx, span := tracing.Start(ctx, "certificate.reissue", tracing.String("domain", domain), tracing.Bool("revoke", revoke))
+	defer span.End()
+
+	cert, previous, err := cm.reissueCertificate(ctx, domain, revoke, reason)
+	switch {
+	case errors.Is(err, ErrDomainLocked), errors.Is(err, ErrMaintenance), errors.Is(err, ErrDomainHeld),
+		errors.Is(err, ErrDomainUnmanaged):
+		return nil, err
+	case cert == nil:
+		span.RecordError(err)
+		cm.recordFailure(domain, "reissue", err)
+		cm.notifyFailure(domain, "re-issue", err)
+		cm.runHooks(ctx, hooks.EventOnFailure, domain, nil, err)
+		return nil, err
+	}
+
+	if previous == nil {
+		cm.publishTraefikTLS()
+	}
+	if switchErr := cm.afterSwitch(ctx, domain, cert); switchErr != nil {
+		span.RecordError(switchErr)
+		return cert, errors.Join(switchErr, err)
+	}
+	cm.afterIssue(ctx, domain)
+	cm.deployCertificate(ctx, domain, cert)
+	if previous != nil {
+		cm.runHooks(ctx, hooks.EventPostRenew, domain, cert, nil)
+	} else {
+		cm.runHooks(ctx, hooks.EventPostIssue, domain, cert, nil)
+	}
+	// A failed revocation leaves the new certificate in place
+	span.RecordError(err)
+	return cert, err
+}
+
+// reissueCertificate returns the new certificate and the one it replaced, nil
+// if the domain had none. A failed revocation is returned with both.
+func (cm *CertificateManager) reissueCertificate(ctx context.Context, domain string, revoke bool, reason uint) (*Certificate, *Certificate, error) {
+	cm.logger.Printf("Re-issuing certificate for domain: %s", domain)
+
+	cm.mu.Lock()
+	if err := cm.beginOrder(domain); err != nil {
+		cm.mu.Unlock()
+		return nil, nil, err
+	}
+	defer cm.endOrder(domain)
+
+	cm.claimUnmanaged(domain)
+	if !cm.isManaged(domain) {
+		cm.mu.Unlock()
+		return nil, nil, fmt.Errorf("%w: %s", ErrDomainUnmanaged, domain)
+	}
+	previous := cm.certs[domain]
+	cm.mu.Unlock()
+
+	if err := cm.checkMaintenance(); err != nil {
+		return nil, nil, fmt.Errorf("refusing to re-issue certificate for %s: %w", domain, err)
+	}
+	if err := cm.checkHold(domain); err != nil {
+		return nil, nil, fmt.Errorf("refusing to re-issue certificate for %s: %w", domain, err)
+	}
+	if err := cm.ensureStorageCapacity(); err != nil {
+		return nil, nil, fmt.Errorf("refusing to re-issue certificate for %s: %w", domain, err)
+	}
+	if err := cm.checkDuplicateLimit(domain); err != nil {
+		return nil, nil, fmt.Errorf("refusing to re-issue certificate for %s: %w", domain, err)
+	}
+	if err := cm.precheckDomain(ctx, domain); err != nil {
+		return nil, nil, fmt.Errorf("refusing to re-issue certificate for %s: %w", domain, err)
+	}
+
+	release, err := cm.lockDomain(domain)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer release()
+
+	// Issuance generates a new key, where renewal would reuse the current one
+	cert, err := cm.acmeClient.RequestCertificate(ctx, domain)
+	cm.recordOrder(domain, err)
+	if err != nil {
+		cm.logger.Printf("Failed to re-issue certificate for %s: %v", domain, err)
+		return nil, nil, fmt.Errorf("failed to re-issue certificate for %s: %w", domain, cm.diagnoseFailure(ctx, domain, err))
+	}
+	if err := cm.checkNotBefore(ctx, cert, previous); err != nil {
+		return nil, nil, err
+	}
+
+	cm.mu.Lock()
+	cm.certs[domain] = cert
+	cm.mu.Unlock()
+	cm.recordIssuance(domain, cert)
+
+	cm.logger.Printf("Successfully re-issued certificate for %s (expires: %s)",
+		domain, cert.ExpiresAt.Format(time.RFC3339))
+
+	if !revoke || previous == nil {
+		return cert, previous, nil
+	}
+	if err := cm.acmeClient.RevokeCertificate(ctx, previous, reason); err != nil {
+		return cert, previous, fmt.Errorf("re-issued certificate for %s but failed to revoke the previous one (serial %s): %w",
+			domain, previous.SerialNumber, err)
+	}
+	cm.logger.Printf("Revoked the previous certificate for %s (serial %s)", domain, previous.SerialNumber)
+	return cert, previous, nil
+}
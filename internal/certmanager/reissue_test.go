@@ -0,0 +1,53 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_ReissueCertificate(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	// A valid certificate is replaced all the same
+	current := createTestCertificate("example.com", 60)
+	reissued := createTestCertificate("example.com", 90)
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      map[string]*Certificate{"example.com": current},
+	}
+
+	mockClient.On("RequestCertificate", "example.com").Return(reissued, nil).Twice()
+	mockClient.On("RevokeCertificate", current, acme.CRLReasonKeyCompromise).Return(nil).Once()
+
+	cert, err := cm.ReissueCertificate(context.Background(), "example.com", true, acme.CRLReasonKeyCompromise)
+	require.NoError(t, err)
+	assert.Same(t, reissued, cert)
+	got, err := cm.GetCertificate("example.com")
+	require.NoError(t, err)
+	assert.Same(t, reissued, got)
+
+	// A failed revocation keeps the new certificate
+	mockClient.On("RevokeCertificate", reissued, acme.CRLReasonSuperseded).Return(errors.New("already revoked")).Once()
+	cert, err = cm.ReissueCertificate(context.Background(), "example.com", true, acme.CRLReasonSuperseded)
+	assert.Same(t, reissued, cert)
+	assert.ErrorContains(t, err, "failed to revoke the previous one")
+	mockClient.AssertExpectations(t)
+	assert.Empty(t, cm.TakeFailures())
+
+	_, err = cm.ReissueCertificate(context.Background(), "unknown.example.com", false, acme.CRLReasonSuperseded)
+	assert.ErrorIs(t, err, ErrDomainUnmanaged)
+}
@@ -8,7 +8,7 @@ import (
 	"log"
 	"os"
 	"path/filepath"
-	"strings"
+	"sync"
 	"time"
 )
 
@@ -85,7 +85,7 @@ func (rc *RenewalChecker) NeedsRenewal(certPath string) bool {
 
 // NeedsRenewalByDomain checks if a certificate needs renewal by domain name
 func (rc *RenewalChecker) NeedsRenewalByDomain(domain string) bool {
-	certPath := filepath.Join(rc.storagePath, domain+".crt")
+	certPath := filepath.Join(rc.storagePath, storageName(domain)+".crt")
 	return rc.NeedsRenewal(certPath)
 }
 
@@ -124,7 +124,7 @@ func (rc *RenewalChecker) GetAllCertificates() ([]string, error) {
 	for _, entry := range entries {
 		if !entry.IsDir() && filepath.Ext(entry.Name()) == ".crt" {
 			// Skip issuer certificates
-			if filepath.Base(entry.Name()) == "issuer.crt" {
+			if _, ok := domainFromCertFile(entry.Name()); !ok {
 				continue
 			}
 			
@@ -156,85 +156,160 @@ func (rc *RenewalChecker) CheckAllCertificates() ([]string, error) {
 	return needsRenewal, nil
 }
 
+// Renewal priorities; tasks of a higher priority are renewed first
+const (
+	PriorityExpiring = iota // due for renewal
+	PriorityExpired
+	PriorityManual // requested through the API while a run renews
+)
+
 // RenewalTask represents a certificate renewal task
 type RenewalTask struct {
 	Domain      string
 	CertPath    string
 	KeyPath     string
-	Priority    int       
+	Priority    int
+	ExpiresAt   time.Time // orders tasks of the same priority, soonest expiry first
 	ScheduledAt time.Time
+	QueuedAt    time.Time // set by AddTask
+
+	ctx    context.Context // of a manual request; nil for tasks of the run
+	result chan error      // receives the outcome of a manual request
 }
 
 // RenewalQueue manages renewal tasks
 type RenewalQueue struct {
-	tasks  []RenewalTask
-	logger *log.Logger
+	mu      sync.Mutex
+	tasks   []RenewalTask
+	metrics *Metrics
+	logger  *log.Logger
 }
 
-func NewRenewalQueue(logger *log.Logger) *RenewalQueue {
+func NewRenewalQueue(metrics *Metrics, logger *log.Logger) *RenewalQueue {
 	if logger == nil {
 		logger = log.New(os.Stdout, "[RenewalQueue] ", log.LstdFlags)
 	}
 
 	return &RenewalQueue{
-		tasks:  make([]RenewalTask, 0),
-		logger: logger,
+		tasks:   make([]RenewalTask, 0),
+		metrics: metrics,
+		logger:  logger,
 	}
 }
 
 // AddTask adds a renewal task to the queue
 func (rq *RenewalQueue) AddTask(task RenewalTask) {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
+	if task.QueuedAt.IsZero() {
+		task.QueuedAt = time.Now()
+	}
 	rq.tasks = append(rq.tasks, task)
-	rq.logger.Printf("Added renewal task for domain: %s", task.Domain)
+	rq.updateMetrics()
+	rq.logger.Printf("Added %s renewal task for domain: %s", priorityName(task.Priority), task.Domain)
 }
 
+// GetNextTask removes and returns the ready task of the highest priority,
+// the one expiring first among equals, or nil when no task is ready
 func (rq *RenewalQueue) GetNextTask() *RenewalTask {
-	if len(rq.tasks) == 0 {
-		return nil
-	}
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
 
-	// Find task with highest priority that's ready to be executed
-	var nextTask *RenewalTask
-	var nextIndex int = -1
-	
+	now := time.Now()
+	next := -1
 	for i, task := range rq.tasks {
-		if time.Now().After(task.ScheduledAt) {
-			if nextTask == nil || task.Priority > nextTask.Priority {
-				nextTask = &task
-				nextIndex = i
-			}
+		if task.ScheduledAt.After(now) {
+			continue
+		}
+		if next < 0 || runsBefore(task, rq.tasks[next]) {
+			next = i
 		}
 	}
-
-	if nextTask != nil && nextIndex >= 0 {
-		rq.tasks = append(rq.tasks[:nextIndex], rq.tasks[nextIndex+1:]...)
+	if next < 0 {
+		return nil
 	}
 
-	return nextTask
+	task := rq.tasks[next]
+	rq.tasks = append(rq.tasks[:next], rq.tasks[next+1:]...)
+	rq.updateMetrics()
+	return &task
+}
+
+// runsBefore orders tasks by priority, then expiry, then the time they were queued
+func runsBefore(a, b RenewalTask) bool {
+	if a.Priority != b.Priority {
+		return a.Priority > b.Priority
+	}
+	if !a.ExpiresAt.Equal(b.ExpiresAt) {
+		return a.ExpiresAt.Before(b.ExpiresAt)
+	}
+	return a.QueuedAt.Before(b.QueuedAt)
 }
 
 // HasPendingTasks returns true if there are pending tasks
 func (rq *RenewalQueue) HasPendingTasks() bool {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
 	return len(rq.tasks) > 0
 }
 
 func (rq *RenewalQueue) Clear() {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
 	rq.tasks = make([]RenewalTask, 0)
+	rq.updateMetrics()
 	rq.logger.Printf("Cleared all renewal tasks")
 }
 
+// Len returns the number of queued tasks, ready or not
+func (rq *RenewalQueue) Len() int {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+	return len(rq.tasks)
+}
+
 // GetPendingCount returns the number of pending tasks
 func (rq *RenewalQueue) GetPendingCount() int {
+	rq.mu.Lock()
+	defer rq.mu.Unlock()
+
 	count := 0
 	now := time.Now()
 	for _, task := range rq.tasks {
-		if now.After(task.ScheduledAt) {
+		if !task.ScheduledAt.After(now) {
 			count++
 		}
 	}
 	return count
 }
 
+// updateMetrics exports the depth of the queue and its oldest task; the
+// caller holds mu
+func (rq *RenewalQueue) updateMetrics() {
+	depth := make(map[int]int)
+	var oldest time.Time
+	for _, task := range rq.tasks {
+		depth[task.Priority]++
+		if oldest.IsZero() || task.QueuedAt.Before(oldest) {
+			oldest = task.QueuedAt
+		}
+	}
+	rq.metrics.setRenewalQueue(depth, oldest)
+}
+
+func priorityName(priority int) string {
+	switch priority {
+	case PriorityManual:
+		return "manual"
+	case PriorityExpired:
+		return "expired"
+	default:
+		return "expiring"
+	}
+}
+
 // RenewalService orchestrates the certificate renewal process
 type RenewalService struct {
 	checker    *RenewalChecker
@@ -255,7 +330,7 @@ func NewRenewalService(manager *CertificateManager, storagePath string, logger *
 
 	return &RenewalService{
 		checker:    NewRenewalChecker(storagePath, logger),
-		queue:      NewRenewalQueue(logger),
+		queue:      NewRenewalQueue(manager.metrics, logger),
 		manager:    manager,
 		logger:     logger,
 		ctx:        ctx,
@@ -286,7 +361,7 @@ func (rs *RenewalService) ProcessRenewals() error {
 
 		rs.logger.Printf("Processing renewal for domain: %s", domain)
 		
-		if err := rs.manager.RenewCertificate(domain); err != nil {
+		if err := rs.manager.RenewCertificate(rs.ctx, domain); err != nil {
 			rs.logger.Printf("Failed to renew certificate for %s: %v", domain, err)
 			errors = append(errors, fmt.Errorf("renewal failed for %s: %w", domain, err))
 		} else {
@@ -302,19 +377,8 @@ func (rs *RenewalService) ProcessRenewals() error {
 }
 
 func (rs *RenewalService) extractDomainFromPath(certPath string) string {
-	filename := filepath.Base(certPath)
-	if !strings.HasSuffix(filename, ".crt") {
-		return ""
-	}
-	
-	// Remove .crt extension
-	domain := filename[:len(filename)-4]
-	
-	// Skip issuer certificates
-	if domain == "issuer" {
-		return ""
-	}
-	
+	// Issuer chains and other files yield no domain
+	domain, _ := domainFromCertFile(filepath.Base(certPath))
 	return domain
 }
 
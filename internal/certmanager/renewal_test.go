@@ -0,0 +1,97 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenewalQueue_OrdersByPriorityAndExpiry(t *testing.T) {
+	queue := NewRenewalQueue(nil, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+	now := time.Now()
+
+	queue.AddTask(RenewalTask{Domain: "later.example.com", Priority: PriorityExpiring, ExpiresAt: now.Add(20 * 24 * time.Hour), ScheduledAt: now})
+	queue.AddTask(RenewalTask{Domain: "sooner.example.com", Priority: PriorityExpiring, ExpiresAt: now.Add(10 * 24 * time.Hour), ScheduledAt: now})
+	queue.AddTask(RenewalTask{Domain: "expired.example.com", Priority: PriorityExpired, ExpiresAt: now.Add(-time.Hour), ScheduledAt: now})
+	queue.AddTask(RenewalTask{Domain: "manual.example.com", Priority: PriorityManual, ScheduledAt: now})
+	queue.AddTask(RenewalTask{Domain: "future.example.com", Priority: PriorityManual, ScheduledAt: now.Add(time.Hour)})
+	assert.Equal(t, 4, queue.GetPendingCount())
+
+	var order []string
+	for task := queue.GetNextTask(); task != nil; task = queue.GetNextTask() {
+		order = append(order, task.Domain)
+		assert.False(t, task.QueuedAt.IsZero())
+	}
+	assert.Equal(t, []string{"manual.example.com", "expired.example.com", "sooner.example.com", "later.example.com"}, order)
+
+	// Tasks scheduled later stay queued
+	assert.True(t, queue.HasPendingTasks())
+	queue.Clear()
+	assert.False(t, queue.HasPendingTasks())
+}
+
+func TestScheduler_RenewsExpiredFirstAndLetsManualRenewalsJumpTheQueue(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	now := time.Now()
+	cm := &CertificateManager{
+		config: cfg,
+		logger: logger,
+		certs: map[string]*Certificate{
+			"expired.example.com": createTestCertificateAt(t, "expired.example.com", now.Add(-91*24*time.Hour), 90),
+			"sooner.example.com":  createTestCertificate("sooner.example.com", 5),
+			"later.example.com":   createTestCertificate("later.example.com", 10),
+			"manual.example.com":  createTestCertificate("manual.example.com", 80),
+		},
+	}
+	scheduler, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+
+	var mu sync.Mutex
+	var order []string
+	var manual sync.WaitGroup
+	var manualErr error
+	mockClient := NewMockACMEClient(testDir, logger)
+	mockClient.On("RenewCertificate", mock.Anything).Run(func(args mock.Arguments) {
+		domain := args.Get(0).(*Certificate).Domain
+		mu.Lock()
+		order = append(order, domain)
+		first := len(order) == 1
+		mu.Unlock()
+
+		// A request arriving during the first renewal goes next
+		if first {
+			manual.Add(1)
+			go func() {
+				defer manual.Done()
+				manualErr = scheduler.RenewNow(context.Background(), "manual.example.com")
+			}()
+			require.Eventually(t, func() bool {
+				return scheduler.renewalService.queue.GetPendingCount() == 3
+			}, 5*time.Second, 10*time.Millisecond)
+		}
+	}).Return(nil, errors.New("CA unavailable"))
+	cm.acmeClient = mockClient
+
+	scheduler.performRenewalCheck()
+	manual.Wait()
+
+	assert.Equal(t, []string{"expired.example.com", "manual.example.com", "sooner.example.com", "later.example.com"}, order)
+	assert.ErrorContains(t, manualErr, "CA unavailable")
+	assert.False(t, scheduler.renewalService.queue.HasPendingTasks())
+
+	// Without a run working through the queue, the request is renewed right away
+	require.ErrorContains(t, scheduler.RenewNow(context.Background(), "manual.example.com"), "CA unavailable")
+	assert.Equal(t, "manual.example.com", order[len(order)-1])
+}
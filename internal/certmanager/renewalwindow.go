@@ -0,0 +1,161 @@
+package certmanager
+
+import (
+	"hash/fnv"
+	"strconv"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// urgentRenewal is the remaining lifetime below which renewal hours are ignored
+const urgentRenewal = 3 * 24 * time.Hour
+
+// profileLifetimeShare is the share of its lifetime a certificate issued under
+// a CA profile has left at the latest when it enters its renewal window, e.g.
+// 2 days of a 6-day shortlived certificate, as the CA recommends
+const profileLifetimeShare = 3
+
+// RenewalPolicy decides when a certificate inside its renewal window is actually
+// renewed. Each certificate gets its own offset into the window, so domains
+// issued together don't all renew in the same scheduler tick, and renewals can be
+// confined to quiet hours.
+type RenewalPolicy struct {
+	renewalDays int
+	jitter      time.Duration       // maximum offset into the renewal window
+	hours       *config.DailyWindow // nil allows renewals at any time
+	// renewBefore returns the renewal window of a domain that sets one as a
+	// duration, for certificates living hours or days; nil uses renewalDays for
+	// every domain
+	renewBefore func(domain string) (time.Duration, bool)
+	// renewalDaysFor returns the renewal_days of a domain that overrides
+	// them; nil uses renewalDays for every domain
+	renewalDaysFor func(domain string) int
+	// hoursFor returns the renewal hours of a domain, e.g. those of its
+	// group; nil uses hours for every domain
+	hoursFor func(domain string) *config.DailyWindow
+	// profileFor returns the CA profile requested for a domain. Certificates
+	// issued under a profile without renew_before have their renewal_days
+	// window shortened to their lifetime; nil leaves renewal_days as is.
+	profileFor func(domain string) string
+}
+
+func NewRenewalPolicy(renewalDays int, jitter time.Duration, hours *config.DailyWindow) *RenewalPolicy {
+	return &RenewalPolicy{
+		renewalDays: renewalDays,
+		jitter:      jitter,
+		hours:       hours,
+	}
+}
+
+// RenewAt returns the earliest time the certificate for domain is renewed. The
+// offset is derived from the domain and expiry, so it is stable across restarts
+// and changes with every new certificate.
+func (p *RenewalPolicy) RenewAt(domain string, cert *Certificate) time.Time {
+	window, short := p.window(domain, cert)
+	start := cert.ExpiresAt.Add(-window)
+
+	// Short-lived certificates keep the second half of their window for retries
+	jitter := p.jitter
+	if short && jitter > window/2 {
+		jitter = window / 2
+	}
+	if jitter <= 0 {
+		return start
+	}
+
+	h := fnv.New64a()
+	h.Write([]byte(domain + "|" + strconv.FormatInt(cert.ExpiresAt.Unix(), 10)))
+	return start.Add(time.Duration(h.Sum64() % uint64(jitter)))
+}
+
+// window returns how long before expiry the domain's certificate enters its
+// renewal window, and whether it is a duration for short-lived certificates,
+// set as such or derived from the lifetime of a certificate issued under a
+// CA profile
+func (p *RenewalPolicy) window(domain string, cert *Certificate) (time.Duration, bool) {
+	if p.renewBefore != nil {
+		if window, ok := p.renewBefore(domain); ok {
+			return window, true
+		}
+	}
+	window := time.Duration(p.days(domain)) * 24 * time.Hour
+	if p.profileFor != nil && p.profileFor(domain) != "" {
+		if share := cert.lifetime() / profileLifetimeShare; share > 0 && share < window {
+			return share, true
+		}
+	}
+	return window, false
+}
+
+// days returns the renewal_days that apply to domain
+func (p *RenewalPolicy) days(domain string) int {
+	if p.renewalDaysFor != nil {
+		if days := p.renewalDaysFor(domain); days > 0 {
+			return days
+		}
+	}
+	return p.renewalDays
+}
+
+// NeedsRenewal reports whether the certificate is inside its renewal window
+func (p *RenewalPolicy) NeedsRenewal(domain string, cert *Certificate, now time.Time) bool {
+	if window, ok := p.window(domain, cert); ok {
+		return cert.ExpiresAt.Sub(now) < window
+	}
+	return cert.NeedsRenewal(p.days(domain))
+}
+
+// Due reports whether the certificate should be renewed at now
+func (p *RenewalPolicy) Due(domain string, cert *Certificate, now time.Time) bool {
+	if !p.NeedsRenewal(domain, cert, now) {
+		return false
+	}
+
+	// Certificates close to expiry renew regardless of their slot or the hour.
+	// For short-lived certificates that is the second half of the window.
+	urgent := urgentRenewal
+	if window, _ := p.window(domain, cert); window < 2*urgentRenewal {
+		urgent = window / 2
+	}
+	if cert.ExpiresAt.Sub(now) < urgent {
+		return true
+	}
+	if now.Before(p.RenewAt(domain, cert)) {
+		return false
+	}
+	hours := p.hours
+	if p.hoursFor != nil {
+		hours = p.hoursFor(domain)
+	}
+	return hours == nil || hours.Contains(now)
+}
+
+// policy returns the renewal policy, or one renewing as soon as a certificate
+// enters its configured window when none is set
+func (cm *CertificateManager) policy() *RenewalPolicy {
+	if cm.renewalPolicy != nil {
+		return cm.renewalPolicy
+	}
+	policy := NewRenewalPolicy(cm.config.Certificates.RenewalDays, 0, nil)
+	policy.renewBefore = cm.config.RenewBeforeFor
+	policy.renewalDaysFor = cm.config.RenewalDaysFor
+	policy.profileFor = cm.config.ProfileFor
+	return policy
+}
+
+// renewalDue reports whether a certificate should be renewed now under the
+// configured jitter and renewal hours
+func (cm *CertificateManager) renewalDue(domain string, cert *Certificate) bool {
+	return cm.policy().Due(domain, cert, time.Now())
+}
+
+// needsRenewal reports whether a certificate is inside its renewal window
+func (cm *CertificateManager) needsRenewal(domain string, cert *Certificate) bool {
+	return cm.policy().NeedsRenewal(domain, cert, time.Now())
+}
+
+// renewAt returns when a certificate is scheduled for renewal
+func (cm *CertificateManager) renewAt(domain string, cert *Certificate) time.Time {
+	return cm.policy().RenewAt(domain, cert)
+}
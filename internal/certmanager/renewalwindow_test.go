@@ -0,0 +1,74 @@
+package certmanager
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func TestRenewalPolicy_SpreadsDomains(t *testing.T) {
+	policy := NewRenewalPolicy(30, 72*time.Hour, nil)
+	expiresAt := time.Now().Add(40 * 24 * time.Hour).Truncate(time.Second)
+	start := expiresAt.Add(-30 * 24 * time.Hour)
+
+	slots := make(map[time.Time]bool)
+	for i := 0; i < 20; i++ {
+		domain := fmt.Sprintf("host%d.example.com", i)
+		cert := &Certificate{Domain: domain, ExpiresAt: expiresAt}
+
+		renewAt := policy.RenewAt(domain, cert)
+		assert.False(t, renewAt.Before(start), "slot must not precede the renewal window")
+		assert.True(t, renewAt.Before(start.Add(72*time.Hour)), "slot must be within the jitter")
+		assert.Equal(t, renewAt, policy.RenewAt(domain, cert), "slot must be stable")
+		slots[renewAt] = true
+	}
+	assert.Greater(t, len(slots), 1, "domains should get different slots")
+}
+
+func TestRenewalPolicy_Due(t *testing.T) {
+	hours, err := config.ParseDailyWindow("02:00-05:00")
+	assert.NoError(t, err)
+	policy := NewRenewalPolicy(30, 0, &hours)
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	cert := &Certificate{Domain: "example.com", ExpiresAt: day.Add(20 * 24 * time.Hour)}
+
+	assert.True(t, policy.Due("example.com", cert, day.Add(3*time.Hour)))
+	assert.False(t, policy.Due("example.com", cert, day.Add(12*time.Hour)), "outside renewal hours")
+
+	valid := &Certificate{Domain: "example.com", ExpiresAt: day.Add(60 * 24 * time.Hour)}
+	assert.False(t, policy.Due("example.com", valid, day.Add(3*time.Hour)), "not yet in the renewal window")
+
+	urgent := &Certificate{Domain: "example.com", ExpiresAt: now.Add(24 * time.Hour)}
+	assert.True(t, policy.Due("example.com", urgent, now), "urgent renewals ignore renewal hours")
+}
+
+func TestRenewalPolicy_GroupHours(t *testing.T) {
+	cfg := &config.Config{
+		Certificates: config.Certificates{RenewalHours: "02:00-05:00"},
+		Groups:       map[string]config.DomainGroup{"staging": {RenewalHours: "09:00-17:00"}},
+		Domains: []config.Domain{
+			{Service: "web", Domain: "example.com"},
+			{Service: "dev", Domain: "dev.example.com", Group: "staging"},
+		},
+	}
+	hours, err := cfg.GetRenewalHours()
+	assert.NoError(t, err)
+	policy := NewRenewalPolicy(30, 0, hours)
+	policy.hoursFor = cfg.RenewalHoursFor
+
+	now := time.Now()
+	day := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.Local)
+	expiresAt := day.Add(20 * 24 * time.Hour)
+
+	// Each group renews on its own schedule
+	assert.True(t, policy.Due("example.com", &Certificate{ExpiresAt: expiresAt}, day.Add(3*time.Hour)))
+	assert.False(t, policy.Due("example.com", &Certificate{ExpiresAt: expiresAt}, day.Add(12*time.Hour)))
+	assert.False(t, policy.Due("dev.example.com", &Certificate{ExpiresAt: expiresAt}, day.Add(3*time.Hour)))
+	assert.True(t, policy.Due("dev.example.com", &Certificate{ExpiresAt: expiresAt}, day.Add(12*time.Hour)))
+}
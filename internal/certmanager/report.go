@@ -0,0 +1,94 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/report"
+)
+
+// ErrReportDisabled is returned when a weekly report is requested while
+// report.enabled is off
+var ErrReportDisabled = errors.New("weekly report is disabled")
+
+// reportTimeout bounds the delivery of a weekly report
+const reportTimeout = time.Minute
+
+// WeeklyReport summarizes the week ending at now from the run summaries in the
+// storage path and the certificates currently managed. Renewals and failures
+// are only covered as far back as app.run_history keeps summaries.
+func (cm *CertificateManager) WeeklyReport(now time.Time) *report.Weekly {
+	w := report.NewWeekly(now, cm.config.Report.UpcomingDays)
+
+	dir := filepath.Join(cm.config.Certificates.StoragePath, runSummaryDir)
+	for _, name := range runSummaryNames(dir) {
+		data, err := os.ReadFile(filepath.Join(dir, name))
+		if err != nil {
+			continue
+		}
+		var summary RunSummary
+		if err := json.Unmarshal(data, &summary); err != nil {
+			cm.logger.Printf("Warning: leaving %s out of the weekly report: %v", name, err)
+			continue
+		}
+		if !w.Covers(summary.StartedAt) {
+			continue
+		}
+
+		w.Runs++
+		for _, run := range summary.Domains {
+			// A renewed domain's expiry is that of the certificate it replaced
+			if run.Outcome == "renewed" && !run.ExpiresAt.IsZero() {
+				w.AddRenewal(run.Domain, summary.StartedAt, run.ExpiresAt)
+			}
+		}
+		for _, f := range summary.Failures {
+			w.AddFailure(report.Failure{Domain: f.Domain, Operation: f.Operation, Error: f.Error, At: f.Time})
+		}
+	}
+
+	cm.mu.RLock()
+	for domain, cert := range cm.certs {
+		w.AddCertificate(domain, cert.ExpiresAt)
+	}
+	cm.mu.RUnlock()
+
+	w.Finish()
+	return w
+}
+
+// SendWeeklyReport publishes the report of the week ending now to the
+// configured destinations
+func (cm *CertificateManager) SendWeeklyReport(ctx context.Context) error {
+	if cm.report == nil {
+		return ErrReportDisabled
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, reportTimeout)
+	defer cancel()
+	return cm.report.Publish(ctx, cm.WeeklyReport(time.Now()))
+}
+
+// SendWeeklyReportIfDue sends the weekly report on its day and time, or when
+// the manager starts after missing it. Failures are logged.
+func (cm *CertificateManager) SendWeeklyReportIfDue() {
+	if cm.report == nil {
+		return
+	}
+
+	due, err := cm.report.Due(time.Now())
+	if err != nil {
+		cm.logger.Printf("Warning: %v", err)
+		return
+	}
+	if !due {
+		return
+	}
+	if err := cm.SendWeeklyReport(context.Background()); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+}
@@ -0,0 +1,55 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWeeklyReport(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Report.UpcomingDays = 30
+
+	cm := &CertificateManager{
+		config: cfg,
+		logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+		certs: map[string]*Certificate{
+			"renewed.example.com":  createTestCertificate("renewed.example.com", 89),
+			"expiring.example.com": createTestCertificate("expiring.example.com", 12),
+		},
+	}
+
+	now := time.Now()
+	lastWeek := newRunSummary(1, "schedule")
+	lastWeek.StartedAt = now.Add(-10 * 24 * time.Hour)
+	lastWeek.add(DomainRun{Domain: "renewed.example.com", Outcome: "renewed", ExpiresAt: now.Add(20 * 24 * time.Hour)})
+	lastWeek.finish("succeeded", nil)
+	require.NoError(t, saveRunSummary(testDir, lastWeek, 30))
+
+	thisWeek := newRunSummary(2, "schedule")
+	thisWeek.StartedAt = now.Add(-24 * time.Hour)
+	thisWeek.add(DomainRun{Domain: "renewed.example.com", Outcome: "renewed", ExpiresAt: now.Add(29 * 24 * time.Hour)})
+	thisWeek.add(DomainRun{Domain: "expiring.example.com", Outcome: "failed", ExpiresAt: now.Add(12 * 24 * time.Hour)})
+	thisWeek.Failures = []Failure{{Domain: "expiring.example.com", Operation: "renew", Error: "CA unavailable", Time: now.Add(-23 * time.Hour)}}
+	thisWeek.finish("failed", nil)
+	require.NoError(t, saveRunSummary(testDir, thisWeek, 30))
+
+	w := cm.WeeklyReport(now)
+	assert.Equal(t, 1, w.Runs)
+	assert.Equal(t, 2, w.Certificates)
+	require.Len(t, w.Renewed, 1)
+	assert.Equal(t, "renewed.example.com", w.Renewed[0].Domain)
+	assert.Equal(t, 30.0, w.AverageLeadDays)
+	require.Len(t, w.Upcoming, 1)
+	assert.Equal(t, "expiring.example.com", w.Upcoming[0].Domain)
+	require.Len(t, w.Failures, 1)
+	assert.Equal(t, "CA unavailable", w.Failures[0].Error)
+
+	assert.ErrorIs(t, cm.SendWeeklyReport(t.Context()), ErrReportDisabled)
+}
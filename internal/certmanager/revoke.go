@@ -0,0 +1,67 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/go-acme/lego/v4/acme"
+)
+
+// revocationReasons maps the names accepted by RevokeCertificate to RFC 5280 reason codes
+var revocationReasons = map[string]uint{
+	"unspecified":          acme.CRLReasonUnspecified,
+	"keyCompromise":        acme.CRLReasonKeyCompromise,
+	"affiliationChanged":   acme.CRLReasonAffiliationChanged,
+	"superseded":           acme.CRLReasonSuperseded,
+	"cessationOfOperation": acme.CRLReasonCessationOfOperation,
+}
+
+// ParseRevocationReason returns the RFC 5280 reason code for a reason name
+// such as keyCompromise or superseded
+func ParseRevocationReason(name string) (uint, error) {
+	for reason, code := range revocationReasons {
+		if strings.EqualFold(reason, name) {
+			return code, nil
+		}
+	}
+
+	names := make([]string, 0, len(revocationReasons))
+	for reason := range revocationReasons {
+		names = append(names, reason)
+	}
+	sort.Strings(names)
+	return 0, fmt.Errorf("unknown revocation reason %q, expected one of %s", name, strings.Join(names, ", "))
+}
+
+// RevokeCertificate revokes the current certificate of a domain at its CA. The
+// revoked certificate stays in storage until it is replaced.
+func (cm *CertificateManager) RevokeCertificate(ctx context.Context, domain string, reason uint) error {
+	cm.mu.Lock()
+	cert, exists := cm.certs[domain]
+	if !exists {
+		cm.mu.Unlock()
+		return fmt.Errorf("%w: %s", ErrCertificateNotFound, domain)
+	}
+	// Revoking while the certificate is being replaced would revoke the wrong one
+	if err := cm.beginOrder(domain); err != nil {
+		cm.mu.Unlock()
+		return err
+	}
+	defer cm.endOrder(domain)
+	cm.mu.Unlock()
+
+	release, err := cm.lockDomain(domain)
+	if err != nil {
+		return err
+	}
+	defer release()
+
+	if err := cm.acmeClient.RevokeCertificate(ctx, cert, reason); err != nil {
+		return fmt.Errorf("failed to revoke certificate for %s: %w", domain, err)
+	}
+
+	cm.logger.Printf("Revoked certificate for %s (was valid until %s)", domain, cert.ExpiresAt.Format("2006-01-02"))
+	return nil
+}
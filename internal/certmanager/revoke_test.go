@@ -0,0 +1,41 @@
+package certmanager
+
+import (
+	"context"
+	"log"
+	"os"
+	"testing"
+
+	"github.com/go-acme/lego/v4/acme"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_RevokeCertificate(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+
+	cert := createTestCertificate("example.com", 60)
+	cm := &CertificateManager{
+		config:     createTestConfig(),
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      map[string]*Certificate{"example.com": cert},
+	}
+
+	mockClient.On("RevokeCertificate", cert, acme.CRLReasonKeyCompromise).Return(nil)
+	require.NoError(t, cm.RevokeCertificate(context.Background(), "example.com", acme.CRLReasonKeyCompromise))
+	mockClient.AssertExpectations(t)
+
+	assert.ErrorIs(t, cm.RevokeCertificate(context.Background(), "api.example.com", acme.CRLReasonUnspecified), ErrCertificateNotFound)
+}
+
+func TestParseRevocationReason(t *testing.T) {
+	code, err := ParseRevocationReason("keycompromise")
+	require.NoError(t, err)
+	assert.Equal(t, acme.CRLReasonKeyCompromise, code)
+
+	_, err = ParseRevocationReason("bored")
+	assert.ErrorContains(t, err, "expected one of")
+}
@@ -0,0 +1,119 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+)
+
+// ErrNoPreviousCertificate is returned by a rollback when the archive holds no
+// usable certificate issued before the current one
+var ErrNoPreviousCertificate = errors.New("no previous certificate to roll back to")
+
+// rollbacker reinstates an archived generation of a certificate
+type rollbacker interface {
+	RollbackCertificate(ctx context.Context, current *Certificate) (*Certificate, error)
+}
+
+// RollbackCertificate stores the newest archived generation issued before the
+// current certificate in its place. The current certificate is archived in
+// turn, so repeated rollbacks walk further back. Expired or unreadable
+// generations are skipped.
+func (c *ACMEClient) RollbackCertificate(ctx context.Context, current *Certificate) (*Certificate, error) {
+	domain := current.Domain
+	if !c.archive.Enabled() {
+		return nil, fmt.Errorf("%w: archiving is disabled, set certificates.archive.retention to keep previous certificates", ErrNoPreviousCertificate)
+	}
+
+	entries, err := c.archive.generations(domain)
+	if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read archive of %s: %w", domain, err)
+	}
+
+	name := storageName(domain)
+	for _, entry := range entries {
+		path := filepath.Join(c.archive.domainDir(domain), entry.Name())
+		files, err := readGeneration(path)
+		if err != nil {
+			c.logger.Printf("Warning: skipping archived generation %s: %v", path, err)
+			continue
+		}
+
+		previous, err := c.decodePair(ctx, domain, files[name+".crt"], files[name+".key"], files[name+".issuer.crt"], time.Now())
+		if err != nil {
+			c.logger.Printf("Warning: skipping archived generation %s: %v", path, err)
+			continue
+		}
+		if !previous.NotBefore.Before(current.NotBefore) || previous.IsExpired() {
+			continue
+		}
+		if info, ok := files[name+orderInfoSuffix]; ok {
+			c.decodeOrderInfo(previous, info)
+		}
+
+		if err := c.saveCertificate(ctx, previous); err != nil {
+			return nil, err
+		}
+		return previous, nil
+	}
+
+	return nil, fmt.Errorf("%w: the archive of %s has no valid certificate issued before the current one", ErrNoPreviousCertificate, domain)
+}
+
+// RollbackCertificate reinstates the previous certificate of a domain and
+// deploys it, for when a newly issued certificate causes problems for clients.
+// The next renewal replaces it as usual.
+func (cm *CertificateManager) RollbackCertificate(ctx context.Context, domain string) (*Certificate, error) {
+	client, ok := cm.acmeClient.(rollbacker)
+	if !ok {
+		return nil, fmt.Errorf("%w: the certificate store does not keep previous certificates", ErrNoPreviousCertificate)
+	}
+
+	if err := cm.checkMaintenance(); err != nil {
+		return nil, fmt.Errorf("refusing to roll back certificate for %s: %w", domain, err)
+	}
+
+	cm.mu.Lock()
+	current, exists := cm.certs[domain]
+	if !exists {
+		cm.mu.Unlock()
+		return nil, fmt.Errorf("%w: %s", ErrCertificateNotFound, domain)
+	}
+	// An order in flight would overwrite the rolled back certificate
+	if err := cm.beginOrder(domain); err != nil {
+		cm.mu.Unlock()
+		return nil, err
+	}
+	defer cm.endOrder(domain)
+	cm.mu.Unlock()
+
+	release, err := cm.lockDomain(domain)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	previous, err := client.RollbackCertificate(ctx, current)
+	if err != nil {
+		return nil, fmt.Errorf("failed to roll back certificate for %s: %w", domain, err)
+	}
+
+	cm.mu.Lock()
+	cm.certs[domain] = previous
+	cm.mu.Unlock()
+
+	cm.logger.Printf("Rolled back certificate for %s to the one issued %s (expires: %s)",
+		domain, previous.NotBefore.Format(time.RFC3339), previous.ExpiresAt.Format(time.RFC3339))
+
+	if err := cm.writeDualKeyConfig(); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+	cm.deployCertificate(ctx, domain, previous)
+	cm.runHooks(ctx, hooks.EventPostRenew, domain, previous, nil)
+	return previous, nil
+}
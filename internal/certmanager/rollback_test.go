@@ -0,0 +1,103 @@
+package certmanager
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"log"
+	"math/big"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// createTestCertificateAt returns a self-signed certificate for domain valid
+// from notBefore, so certificates issued in the same second can be ordered
+func createTestCertificateAt(t *testing.T, domain string, notBefore time.Time, validDays int) *Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(time.Now().UnixNano()),
+		Subject:      pkix.Name{CommonName: domain},
+		DNSNames:     []string{domain},
+		NotBefore:    notBefore,
+		NotAfter:     notBefore.Add(time.Duration(validDays) * 24 * time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:  []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	require.NoError(t, err)
+
+	cert := &Certificate{
+		Domain:      domain,
+		Certificate: pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}),
+		PrivateKey:  pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}),
+		IssuedAt:    notBefore,
+	}
+	require.NoError(t, cert.parseCertificate())
+	return cert
+}
+
+func TestCertificateManager_RollbackCertificate(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	client := &ACMEClient{storagePath: testDir, logger: logger, archive: NewCertificateArchive(testDir, 5, true, logger)}
+
+	now := time.Now()
+	expired := createTestCertificateAt(t, "example.com", now.Add(-120*24*time.Hour), 90)
+	previous := createTestCertificateAt(t, "example.com", now.Add(-60*24*time.Hour), 90)
+	current := createTestCertificateAt(t, "example.com", now.Add(-time.Hour), 90)
+	for _, cert := range []*Certificate{expired, previous, current} {
+		require.NoError(t, client.saveCertificate(context.Background(), cert))
+	}
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: client,
+		logger:     logger,
+		certs:      map[string]*Certificate{"example.com": current},
+		unmanaged:  make(map[string]*Certificate),
+	}
+
+	rolledBack, err := cm.RollbackCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, previous.Certificate, rolledBack.Certificate)
+
+	stored, err := client.LoadCertificate(context.Background(), "example.com")
+	require.NoError(t, err)
+	assert.Equal(t, previous.Certificate, stored.Certificate)
+	inMemory, err := cm.GetCertificate("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, previous.Certificate, inMemory.Certificate)
+
+	// The replaced certificate was archived, but is newer and not rolled back to;
+	// the only older one has expired
+	_, err = cm.RollbackCertificate(context.Background(), "example.com")
+	assert.ErrorIs(t, err, ErrNoPreviousCertificate)
+
+	_, err = cm.RollbackCertificate(context.Background(), "unknown.example.com")
+	assert.ErrorIs(t, err, ErrCertificateNotFound)
+}
+
+func TestACMEClient_RollbackRequiresArchive(t *testing.T) {
+	testDir := setupTestDir(t)
+	client := &ACMEClient{storagePath: testDir, logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags)}
+
+	_, err := client.RollbackCertificate(context.Background(), createTestCertificate("example.com", 30))
+	assert.ErrorIs(t, err, ErrNoPreviousCertificate)
+}
@@ -0,0 +1,164 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// runSummaryDir holds a summary of each scheduler cycle in the storage path,
+// named so that they sort by start time
+const runSummaryDir = "runs"
+
+// ErrNoRunSummary is returned when no scheduler cycle has been recorded yet
+var ErrNoRunSummary = errors.New("no renewal run has been recorded")
+
+// RunSummary records what a scheduler cycle did, so there is a durable record
+// of renewals even when the logs are gone
+type RunSummary struct {
+	Run        int         `json:"run"`     // scheduled run number; manual runs carry that of the last scheduled run
+	Trigger    string      `json:"trigger"` // schedule, manual or retry
+	StartedAt  time.Time   `json:"started_at"`
+	FinishedAt time.Time   `json:"finished_at"`
+	Duration   string      `json:"duration"`
+	Status     string      `json:"status"` // succeeded, failed or skipped
+	Error      string      `json:"error,omitempty"`
+	Checked    int         `json:"checked"`
+	Renewed    int         `json:"renewed"`
+	Failed     int         `json:"failed"`
+	Skipped    int         `json:"skipped"`  // backing off after failures or locked by another process
+	Deferred   int         `json:"deferred"` // waiting for their renewal slot or renewal hours
+	Domains    []DomainRun `json:"domains"`
+	Failures   []Failure   `json:"failures,omitempty"` // including those that don't fail the run, like deployments
+}
+
+// DomainRun is the outcome of one certificate in a scheduler cycle
+type DomainRun struct {
+	Domain    string    `json:"domain"`
+	Outcome   string    `json:"outcome"` // valid, deferred, skipped, renewed or failed
+	Reason    string    `json:"reason,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	ErrorCode string    `json:"error_code,omitempty"` // class of the error, see ErrorCode
+	Duration  string    `json:"duration,omitempty"`   // of the renewal attempt
+	ExpiresAt time.Time `json:"expires_at"`
+}
+
+func newRunSummary(run int, trigger string) *RunSummary {
+	return &RunSummary{Run: run, Trigger: trigger, StartedAt: time.Now(), Domains: []DomainRun{}}
+}
+
+func (r *RunSummary) add(result DomainRun) {
+	r.Checked++
+	switch result.Outcome {
+	case "renewed":
+		r.Renewed++
+	case "failed":
+		r.Failed++
+	case "skipped":
+		r.Skipped++
+	case "deferred":
+		r.Deferred++
+	}
+	r.Domains = append(r.Domains, result)
+}
+
+// finish completes the summary with the result of the cycle
+func (r *RunSummary) finish(status string, err error) {
+	r.FinishedAt = time.Now()
+	r.Duration = r.FinishedAt.Sub(r.StartedAt).Round(time.Millisecond).String()
+	r.Status = status
+	if err != nil {
+		r.Error = err.Error()
+	}
+	sort.Slice(r.Domains, func(i, j int) bool { return r.Domains[i].Domain < r.Domains[j].Domain })
+}
+
+// saveRunSummary writes summary to the storage path and removes all but the
+// newest keep summaries
+func saveRunSummary(storagePath string, summary *RunSummary, keep int) error {
+	dir := filepath.Join(storagePath, runSummaryDir)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return fmt.Errorf("failed to create run summary directory: %w", err)
+	}
+
+	data, err := json.MarshalIndent(summary, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode run summary: %w", err)
+	}
+	name := fmt.Sprintf("run-%s-%d.json", summary.StartedAt.UTC().Format("20060102T150405Z"), summary.Run)
+	tmp := filepath.Join(dir, "."+name+".tmp")
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+	if err := os.Rename(tmp, filepath.Join(dir, name)); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write run summary: %w", err)
+	}
+
+	names := runSummaryNames(dir)
+	for i := 0; keep > 0 && i < len(names)-keep; i++ {
+		os.Remove(filepath.Join(dir, names[i]))
+	}
+	return nil
+}
+
+// runSummaryNames returns the summary files in dir, oldest first
+func runSummaryNames(dir string) []string {
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+	var names []string
+	for _, entry := range entries {
+		if name := entry.Name(); strings.HasPrefix(name, "run-") && strings.HasSuffix(name, ".json") {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+	return names
+}
+
+// LatestRunSummary returns the summary of the most recent scheduler cycle
+func (cm *CertificateManager) LatestRunSummary() (*RunSummary, error) {
+	dir := filepath.Join(cm.config.Certificates.StoragePath, runSummaryDir)
+	names := runSummaryNames(dir)
+	if len(names) == 0 {
+		return nil, ErrNoRunSummary
+	}
+
+	return readRunSummary(filepath.Join(dir, names[len(names)-1]))
+}
+
+// RecentRunSummaries returns the summaries of up to limit of the most recent
+// scheduler cycles, newest first
+func (cm *CertificateManager) RecentRunSummaries(limit int) ([]*RunSummary, error) {
+	dir := filepath.Join(cm.config.Certificates.StoragePath, runSummaryDir)
+	names := runSummaryNames(dir)
+
+	var summaries []*RunSummary
+	for i := len(names) - 1; i >= 0 && len(summaries) < limit; i-- {
+		summary, err := readRunSummary(filepath.Join(dir, names[i]))
+		if err != nil {
+			return summaries, err
+		}
+		summaries = append(summaries, summary)
+	}
+	return summaries, nil
+}
+
+func readRunSummary(path string) (*RunSummary, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read run summary: %w", err)
+	}
+	var summary RunSummary
+	if err := json.Unmarshal(data, &summary); err != nil {
+		return nil, fmt.Errorf("failed to parse run summary: %w", err)
+	}
+	return &summary, nil
+}
@@ -0,0 +1,129 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_SavesRunSummary(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	mockClient.On("RenewCertificate", mock.Anything).Return(nil, errors.New("CA unavailable"))
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs: map[string]*Certificate{
+			"example.com":       createTestCertificate("example.com", 10),
+			"valid.example.com": createTestCertificate("valid.example.com", 80),
+		},
+	}
+
+	_, err := cm.LatestRunSummary()
+	assert.ErrorIs(t, err, ErrNoRunSummary)
+
+	scheduler, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+	scheduler.performRenewalCheck()
+
+	summary, err := cm.LatestRunSummary()
+	require.NoError(t, err)
+	assert.Equal(t, 1, summary.Run)
+	assert.Equal(t, "schedule", summary.Trigger)
+	assert.Equal(t, "failed", summary.Status)
+	assert.Contains(t, summary.Error, "CA unavailable")
+	assert.Equal(t, 2, summary.Checked)
+	assert.Equal(t, 1, summary.Failed)
+	require.Len(t, summary.Domains, 2)
+	assert.Equal(t, "example.com", summary.Domains[0].Domain)
+	assert.Equal(t, "failed", summary.Domains[0].Outcome)
+	assert.Contains(t, summary.Domains[0].Error, "CA unavailable")
+	assert.NotEmpty(t, summary.Domains[0].Duration)
+	assert.Equal(t, "valid", summary.Domains[1].Outcome)
+
+	// The next run finds the failed domain backing off
+	require.NoError(t, scheduler.RunOnce())
+	summary, err = cm.LatestRunSummary()
+	require.NoError(t, err)
+	assert.Equal(t, "manual", summary.Trigger)
+	assert.Equal(t, "succeeded", summary.Status)
+	assert.Equal(t, 1, summary.Skipped)
+	assert.Equal(t, "skipped", summary.Domains[0].Outcome)
+	assert.Contains(t, summary.Domains[0].Reason, "backing off after 1 failures")
+
+	recent, err := cm.RecentRunSummaries(5)
+	require.NoError(t, err)
+	require.NotEmpty(t, recent)
+	assert.Equal(t, "manual", recent[0].Trigger)
+}
+
+func TestScheduler_StrictModeFailsOnReportedFailures(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.App.Strict = true
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: NewMockACMEClient(testDir, logger),
+		logger:     logger,
+		certs: map[string]*Certificate{
+			"example.com": createTestCertificate("example.com", 80),
+		},
+	}
+	scheduler, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+
+	// A failed deployment doesn't fail the renewal, but fails a strict run
+	cm.recordFailure("example.com", "deploy", errors.New("connection refused"))
+	require.NoError(t, scheduler.RunOnce())
+
+	summary, err := cm.LatestRunSummary()
+	require.NoError(t, err)
+	assert.Equal(t, "failed", summary.Status)
+	require.Len(t, summary.Failures, 1)
+	assert.Equal(t, "deploy", summary.Failures[0].Operation)
+	assert.Equal(t, "connection refused", summary.Failures[0].Error)
+	assert.ErrorContains(t, scheduler.CheckLastRun(context.Background()), "1 operations failed")
+	assert.Empty(t, cm.TakeFailures())
+
+	// The next clean run makes the daemon ready again
+	require.NoError(t, scheduler.RunOnce())
+	assert.NoError(t, scheduler.CheckLastRun(context.Background()))
+}
+
+func TestSaveRunSummary_KeepsNewest(t *testing.T) {
+	testDir := setupTestDir(t)
+	start := time.Date(2024, 5, 1, 0, 0, 0, 0, time.UTC)
+
+	for run := 1; run <= 4; run++ {
+		summary := newRunSummary(run, "schedule")
+		summary.StartedAt = start.Add(time.Duration(run) * time.Hour)
+		summary.finish("succeeded", nil)
+		require.NoError(t, saveRunSummary(testDir, summary, 2))
+	}
+
+	names := runSummaryNames(filepath.Join(testDir, runSummaryDir))
+	assert.Equal(t, []string{"run-20240501T030000Z-3.json", "run-20240501T040000Z-4.json"}, names)
+
+	cm := &CertificateManager{config: createTestConfig()}
+	cm.config.Certificates.StoragePath = testDir
+	summary, err := cm.LatestRunSummary()
+	require.NoError(t, err)
+	assert.Equal(t, 4, summary.Run)
+}
@@ -2,9 +2,12 @@ package certmanager
 
 import (
 	"context"
+	"errors"
 	"fmt"
 	"log"
 	"os"
+	"path/filepath"
+	"sort"
 	"sync"
 	"time"
 
@@ -25,8 +28,19 @@ type Scheduler struct {
 	lastRunTime    time.Time
 	nextRunTime    time.Time
 	stats          SchedulerStats
+	statePath      string                      // stats and retry timers persisted across restarts
+	retries        map[string]DomainRetryState // domains whose renewals keep failing
+	lastRunErr     error                       // why the last run failed in strict mode
+	onRun          func(*RunSummary)           // told about every finished run, e.g. for systemd status
+	renewing       sync.Mutex                  // held by the run working through the renewal queue
+	queueMu        sync.Mutex
+	draining       bool // a run is working through the renewal queue; guarded by queueMu
 }
 
+// errQueueClosed tells a manual renewal that the run it was queued in ended
+// before getting to it
+var errQueueClosed = errors.New("renewal queue closed")
+
 // SchedulerStats holds statistics about scheduler operations
 type SchedulerStats struct {
 	TotalRuns           int           `json:"total_runs"`
@@ -63,9 +77,23 @@ func NewScheduler(cfg *config.Config, manager *CertificateManager, logger *log.L
 		stats: SchedulerStats{
 			StartTime: time.Now(),
 		},
+		statePath: filepath.Join(cfg.Certificates.StoragePath, schedulerStateFileName),
+		retries:   make(map[string]DomainRetryState),
 	}
+	scheduler.restoreState()
 
 	scheduler.nextRunTime = time.Now().Add(checkInterval)
+
+	if hours, _ := cfg.GetRenewalHours(); hours != nil && checkInterval > hours.Duration() {
+		logger.Printf("Warning: check interval %v is longer than the renewal hours %s; some days may see no renewal check inside them",
+			checkInterval, cfg.Certificates.RenewalHours)
+	}
+	for name, group := range cfg.Groups {
+		if hours, err := config.ParseDailyWindow(group.RenewalHours); err == nil && checkInterval > hours.Duration() {
+			logger.Printf("Warning: check interval %v is longer than the renewal hours %s of group %s; some days may see no renewal check inside them",
+				checkInterval, group.RenewalHours, name)
+		}
+	}
 	
 	logger.Printf("Scheduler initialized with check interval: %v", checkInterval)
 	return scheduler, nil
@@ -111,6 +139,7 @@ func (s *Scheduler) Stop() error {
 	
 	s.isRunning = false
 	s.renewalService.Stop()
+	s.persistState(s.snapshotState())
 	
 	s.logger.Printf("Scheduler stopped successfully")
 	return nil
@@ -157,10 +186,31 @@ func (s *Scheduler) run() {
 		return
 	}
 
+	// The notification digest and weekly report are sent at their time, independent of the check interval
+	digestTicker := time.NewTicker(time.Minute)
+	defer digestTicker.Stop()
+
+	// OCSP responses may be valid for less than the check interval
+	var stapleTick <-chan time.Time
+	if s.config.Certificates.OCSPStapling.Enabled {
+		interval, err := s.config.GetOCSPStaplingInterval()
+		if err != nil {
+			interval = time.Hour
+		}
+		stapleTicker := time.NewTicker(interval)
+		defer stapleTicker.Stop()
+		stapleTick = stapleTicker.C
+	}
+
 	for {
 		select {
 		case <-s.ticker.C:
 			s.performRenewalCheck()
+		case <-digestTicker.C:
+			s.renewalService.manager.FlushNotifications()
+			s.renewalService.manager.SendWeeklyReportIfDue()
+		case <-stapleTick:
+			s.renewalService.manager.RefreshStaples(s.ctx)
 		case <-s.ctx.Done():
 			s.logger.Printf("Scheduler main loop stopped")
 			return
@@ -170,6 +220,11 @@ func (s *Scheduler) run() {
 
 // performRenewalCheck executes the certificate renewal check
 func (s *Scheduler) performRenewalCheck() {
+	if s.renewalService.manager.MaintenanceState().Enabled {
+		s.skipForMaintenance()
+		return
+	}
+
 	startTime := time.Now()
 	
 	s.mu.Lock()
@@ -181,18 +236,32 @@ func (s *Scheduler) performRenewalCheck() {
 	s.mu.Unlock()
 
 	s.logger.Printf("Starting scheduled certificate renewal check (run #%d)", s.stats.TotalRuns)
+	summary := newRunSummary(s.stats.TotalRuns, "schedule")
+
+	s.renewalService.manager.CheckStorage()
 
 	// Create a context with timeout for this operation
-	timeout, err := s.config.GetTimeout()
+	timeout, err := s.config.GetRunTimeout()
 	if err != nil {
-		timeout = 10 * time.Minute // Default timeout
+		timeout = time.Hour // Default timeout
 	}
 	
 	ctx, cancel := context.WithTimeout(s.ctx, timeout)
 	defer cancel()
+	ctx, span := startRunSpan(ctx, summary)
+	defer endRunSpan(span, summary)
+
+	s.refreshExpiringChains(ctx)
+	s.renewalService.manager.CleanupStaleOrders(ctx)
 
 	// Perform the renewal process
-	err = s.performRenewalWithContext(ctx)
+	err = s.performRenewalWithContext(ctx, summary)
+	s.renewalService.manager.RenewCompanions(ctx)
+	s.renewalService.manager.RefreshStaples(ctx)
+	s.renewalService.manager.PruneOrphanedCertificates(ctx)
+	s.renewalService.manager.ReconcileInventory(ctx)
+	s.renewalService.manager.NotifyExpiring()
+	s.renewalService.manager.FlushNotifications()
 	
 	duration := time.Since(startTime)
 	
@@ -206,48 +275,217 @@ func (s *Scheduler) performRenewalCheck() {
 		s.logger.Printf("Scheduled renewal check completed successfully in %v", duration)
 	}
 	s.mu.Unlock()
+
+	s.saveRunSummary(summary, err)
+	s.saveState()
+}
+
+// saveRunSummary completes summary with the result of the cycle and the
+// failures recorded during it, and stores it
+func (s *Scheduler) saveRunSummary(summary *RunSummary, err error) {
+	summary.Failures = s.renewalService.manager.TakeFailures()
+	// Renewals are counted per domain in the summary already
+	s.renewalService.manager.TakeIssued()
+	if err == nil && s.config.App.Strict && len(summary.Failures) > 0 {
+		err = fmt.Errorf("%d operations failed", len(summary.Failures))
+	}
+	if s.config.App.Strict {
+		s.mu.Lock()
+		s.lastRunErr = err
+		s.mu.Unlock()
+	}
+
+	status := "succeeded"
+	if err != nil {
+		status = "failed"
+	}
+	summary.finish(status, err)
+	if err := saveRunSummary(s.config.Certificates.StoragePath, summary, s.config.App.RunHistory); err != nil {
+		s.logger.Printf("Warning: failed to save run summary: %v", err)
+	}
+	s.reportRun(summary)
 }
 
-// performRenewalWithContext performs renewal with context cancellation support
-func (s *Scheduler) performRenewalWithContext(ctx context.Context) error {
+// OnRun registers fn to be called with the summary of every finished run
+func (s *Scheduler) OnRun(fn func(*RunSummary)) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.onRun = fn
+}
+
+func (s *Scheduler) reportRun(summary *RunSummary) {
+	s.renewalService.manager.recordRun(summary)
+
+	s.mu.RLock()
+	fn := s.onRun
+	s.mu.RUnlock()
+	if fn != nil {
+		fn(summary)
+	}
+}
+
+// CheckLastRun fails while the last run had failures in strict mode, for the
+// readiness check of pipelines that gate deployments on certificate health
+func (s *Scheduler) CheckLastRun(ctx context.Context) error {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+	if s.lastRunErr != nil {
+		return fmt.Errorf("last renewal run failed: %w", s.lastRunErr)
+	}
+	return nil
+}
+
+// skipForMaintenance keeps monitoring storage and chains while renewals are paused
+func (s *Scheduler) skipForMaintenance() {
+	s.mu.Lock()
+	checkInterval, _ := s.config.GetCheckInterval()
+	s.nextRunTime = time.Now().Add(checkInterval)
+	s.mu.Unlock()
+
+	s.logger.Printf("Maintenance mode is active, skipping scheduled renewal check")
+
+	s.renewalService.manager.CheckStorage()
+	s.renewalService.manager.CheckChains()
+
+	summary := newRunSummary(s.GetStats().TotalRuns, "schedule")
+	summary.finish("skipped", errors.New("maintenance mode is active"))
+	if err := saveRunSummary(s.config.Certificates.StoragePath, summary, s.config.App.RunHistory); err != nil {
+		s.logger.Printf("Warning: failed to save run summary: %v", err)
+	}
+	s.reportRun(summary)
+}
+
+// refreshExpiringChains fetches current chains for certificates whose stored
+// intermediates or roots are about to expire
+func (s *Scheduler) refreshExpiringChains(ctx context.Context) {
+	warnings := s.renewalService.manager.CheckChains()
+	if len(warnings) == 0 {
+		return
+	}
+
+	seen := make(map[string]bool)
+	var domains []string
+	for _, w := range warnings {
+		for _, domain := range w.Domains {
+			if !seen[domain] {
+				seen[domain] = true
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	refreshed, err := s.renewalService.manager.RefreshChains(ctx, domains)
+	if err != nil {
+		s.logger.Printf("Chain refresh incomplete: %v", err)
+	}
+	if len(refreshed) > 0 {
+		s.logger.Printf("Refreshed issuer chains for %d certificates", len(refreshed))
+	}
+}
+
+// performRenewalWithContext performs renewal with context cancellation support,
+// recording the outcome for each certificate in summary
+func (s *Scheduler) performRenewalWithContext(ctx context.Context, summary *RunSummary) error {
 	select {
 	case <-ctx.Done():
 		return ctx.Err()
 	default:
 	}
 
+	// Overlapping runs take turns so they don't renew the same certificates
+	s.renewing.Lock()
+	defer s.renewing.Unlock()
+
 	health := s.renewalService.manager.CheckCertificateHealth()
-	
-	var renewalCount int
-	var errors []error
 
+	var renewalCount, deferredCount int
+	var errs []error
+	now := time.Now()
+
+	// Due certificates are queued and renewed in order of priority once all
+	// are known, so expired ones don't wait behind the merely expiring
+	queued := make(map[string]DomainRun)
 	for domain, status := range health {
+		result := DomainRun{Domain: domain, Outcome: "valid", ExpiresAt: status.ExpiresAt}
+
+		if status.Hold != nil && status.NeedsRenewal {
+			result.Outcome = "skipped"
+			result.Reason = status.Hold.String()
+			summary.add(result)
+			continue
+		}
+
+		if status.NeedsRenewal && !status.RenewalDue {
+			deferredCount++
+			result.Outcome = "deferred"
+			result.Reason = fmt.Sprintf("waiting for its renewal slot at %s or renewal hours", status.RenewAt.Format(time.RFC3339))
+			summary.add(result)
+			continue
+		}
+
+		if status.RenewalDue {
+			if retry, waiting := s.backingOff(domain, now); waiting {
+				s.logger.Printf("Skipping renewal of %s after %d failures, next attempt at %s",
+					domain, retry.Failures, retry.NextAttempt.Format(time.RFC3339))
+				result.Outcome = "skipped"
+				result.Reason = fmt.Sprintf("backing off after %d failures until %s", retry.Failures, retry.NextAttempt.Format(time.RFC3339))
+				summary.add(result)
+				continue
+			}
+
+			priority := PriorityExpiring
+			if status.IsExpired {
+				priority = PriorityExpired
+			}
+			queued[domain] = result
+			s.renewalService.queue.AddTask(RenewalTask{
+				Domain:      domain,
+				Priority:    priority,
+				ExpiresAt:   status.ExpiresAt,
+				ScheduledAt: now,
+			})
+			continue
+		}
+		summary.add(result)
+	}
+
+	s.startDraining()
+	defer s.stopDraining()
+	for task := s.nextTask(); task != nil; task = s.nextTask() {
+		if task.result != nil {
+			// A manual request that jumped the queue
+			task.result <- s.renewalService.manager.RenewCertificate(task.ctx, task.Domain)
+			continue
+		}
+
 		select {
 		case <-ctx.Done():
 			return ctx.Err()
 		default:
 		}
 
-		if status.NeedsRenewal {
-			s.logger.Printf("Certificate for %s needs renewal (expires in %d days)", 
-				domain, status.DaysUntilExpiry)
-			
-			if err := s.renewalService.manager.RenewCertificate(domain); err != nil {
-				s.logger.Printf("Failed to renew certificate for %s: %v", domain, err)
-				errors = append(errors, fmt.Errorf("failed to renew %s: %w", domain, err))
-			} else {
-				renewalCount++
-				s.logger.Printf("Successfully renewed certificate for %s", domain)
-			}
+		result := queued[task.Domain]
+		s.logger.Printf("Certificate for %s needs renewal (expires in %s)",
+			task.Domain, expiresIn(task.ExpiresAt))
+
+		if err := s.renew(ctx, task.Domain, &result); err != nil {
+			errs = append(errs, fmt.Errorf("failed to renew %s: %w", task.Domain, err))
+		} else if result.Outcome == "renewed" {
+			renewalCount++
 		}
+		summary.add(result)
 	}
 
 	s.mu.Lock()
 	s.stats.CertificatesRenewed += renewalCount
 	s.mu.Unlock()
 
-	if len(errors) > 0 {
-		return fmt.Errorf("renewal errors: %v", errors)
+	if deferredCount > 0 {
+		s.logger.Printf("%d certificates are waiting for their renewal slot or renewal hours", deferredCount)
+	}
+
+	if len(errs) > 0 {
+		return fmt.Errorf("renewal errors: %v", errs)
 	}
 
 	if renewalCount > 0 {
@@ -259,19 +497,201 @@ func (s *Scheduler) performRenewalWithContext(ctx context.Context) error {
 	return nil
 }
 
+// startDraining lets manual renewals join the renewal queue
+func (s *Scheduler) startDraining() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+	s.draining = true
+}
+
+// nextTask takes the next task off the renewal queue. Once it is empty, manual
+// renewals no longer join it.
+func (s *Scheduler) nextTask() *RenewalTask {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	task := s.renewalService.queue.GetNextTask()
+	if task == nil {
+		s.draining = false
+	}
+	return task
+}
+
+// stopDraining empties the queue of a run that ended early. Manual renewals
+// still waiting in it are handed back to their requests.
+func (s *Scheduler) stopDraining() {
+	s.queueMu.Lock()
+	defer s.queueMu.Unlock()
+
+	if !s.draining {
+		return
+	}
+	s.draining = false
+	for task := s.renewalService.queue.GetNextTask(); task != nil; task = s.renewalService.queue.GetNextTask() {
+		if task.result != nil {
+			task.result <- errQueueClosed
+		}
+	}
+	s.renewalService.queue.Clear()
+}
+
+// RenewNow renews a domain on request. While a run works through the renewal
+// queue, the request jumps it and is renewed next instead of waiting for the
+// run to finish.
+func (s *Scheduler) RenewNow(ctx context.Context, domain string) error {
+	result := make(chan error, 1)
+
+	s.queueMu.Lock()
+	queued := s.draining
+	if queued {
+		s.renewalService.queue.AddTask(RenewalTask{
+			Domain:      domain,
+			Priority:    PriorityManual,
+			ScheduledAt: time.Now(),
+			ctx:         ctx,
+			result:      result,
+		})
+	}
+	s.queueMu.Unlock()
+
+	if !queued {
+		return s.renewalService.manager.RenewCertificate(ctx, domain)
+	}
+	select {
+	case err := <-result:
+		if errors.Is(err, errQueueClosed) {
+			return s.renewalService.manager.RenewCertificate(ctx, domain)
+		}
+		return err
+	case <-ctx.Done():
+		// The run gives up on the task as soon as it gets to it
+		return ctx.Err()
+	}
+}
+
+// renew renews a domain, records the outcome in result and updates its retry
+// timer. It returns the error of a failed renewal.
+func (s *Scheduler) renew(ctx context.Context, domain string, result *DomainRun) error {
+	started := time.Now()
+	err := s.renewalService.manager.RenewCertificate(ctx, domain)
+	result.Duration = time.Since(started).Round(time.Millisecond).String()
+	switch {
+	case errors.Is(err, ErrDomainLocked):
+		// Another process is renewing it; that is not a failure of this domain
+		result.Outcome = "skipped"
+		result.Reason = "being renewed by another process"
+		return nil
+	case errors.Is(err, ErrDomainHeld):
+		// Held after this check started, or retried while held
+		result.Outcome = "skipped"
+		result.Reason = "held"
+		return nil
+	case errors.Is(err, ErrDomainUnmanaged):
+		// Discovery removed it after this check started
+		s.logger.Printf("Certificate for %s is no longer managed, skipped its renewal", domain)
+		s.recordResult(domain, nil)
+		result.Outcome = "skipped"
+		result.Reason = "removed during this run"
+		result.Error = err.Error()
+		return nil
+	}
+	s.recordResult(domain, err)
+
+	if err != nil {
+		s.logger.Printf("Failed to renew certificate for %s: %v", domain, err)
+		result.Outcome = "failed"
+		result.Error = err.Error()
+		result.ErrorCode = ErrorCode(err)
+		return err
+	}
+	s.logger.Printf("Successfully renewed certificate for %s", domain)
+	result.Outcome = "renewed"
+	return nil
+}
+
+// RetryFailed re-attempts only the domains whose last renewal failed, instead
+// of checking every certificate. Domains still backing off are skipped unless
+// now is set. Failed renewals are reported in the summary, not returned.
+func (s *Scheduler) RetryFailed(ctx context.Context, now bool) (*RunSummary, error) {
+	if s.renewalService.manager.MaintenanceState().Enabled {
+		return nil, ErrMaintenance
+	}
+
+	failing := s.GetRetryState()
+	domains := make([]string, 0, len(failing))
+	for domain := range failing {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	s.logger.Printf("Retrying %d domains whose last renewal failed", len(domains))
+	summary := newRunSummary(s.GetStats().TotalRuns, "retry")
+	ctx, span := startRunSpan(ctx, summary)
+	defer endRunSpan(span, summary)
+	health := s.renewalService.manager.CheckCertificateHealth()
+
+	var renewalCount int
+	var errs []error
+	for _, domain := range domains {
+		if err := ctx.Err(); err != nil {
+			errs = append(errs, err)
+			break
+		}
+
+		retry := failing[domain]
+		status, managed := health[domain]
+		result := DomainRun{Domain: domain, ExpiresAt: status.ExpiresAt}
+		switch {
+		case !managed:
+			// Removed or deleted since it failed, there is nothing left to retry
+			s.recordResult(domain, nil)
+			result.Outcome = "skipped"
+			result.Reason = "no longer managed"
+		case !now && summary.StartedAt.Before(retry.NextAttempt):
+			result.Outcome = "skipped"
+			result.Reason = fmt.Sprintf("backing off after %d failures until %s", retry.Failures, retry.NextAttempt.Format(time.RFC3339))
+		default:
+			if err := s.renew(ctx, domain, &result); err != nil {
+				errs = append(errs, fmt.Errorf("failed to renew %s: %w", domain, err))
+			} else if result.Outcome == "renewed" {
+				renewalCount++
+			}
+		}
+		summary.add(result)
+	}
+
+	s.mu.Lock()
+	s.stats.CertificatesRenewed += renewalCount
+	s.mu.Unlock()
+
+	var err error
+	if len(errs) > 0 {
+		err = fmt.Errorf("renewal errors: %v", errs)
+	}
+	s.saveRunSummary(summary, err)
+	s.saveState()
+	return summary, nil
+}
+
 // RunOnce performs a single renewal check outside of the regular schedule
 func (s *Scheduler) RunOnce() error {
 	s.logger.Printf("Performing manual certificate renewal check")
 	
-	timeout, err := s.config.GetTimeout()
+	timeout, err := s.config.GetRunTimeout()
 	if err != nil {
-		timeout = 10 * time.Minute
+		timeout = time.Hour
 	}
 	
 	ctx, cancel := context.WithTimeout(context.Background(), timeout)
 	defer cancel()
 	
-	return s.performRenewalWithContext(ctx)
+	summary := newRunSummary(s.GetStats().TotalRuns, "manual")
+	ctx, span := startRunSpan(ctx, summary)
+	defer endRunSpan(span, summary)
+	err = s.performRenewalWithContext(ctx, summary)
+	s.saveRunSummary(summary, err)
+	s.saveState()
+	return err
 }
 
 // Reschedule changes the scheduler interval
@@ -323,6 +743,7 @@ type SchedulerStatus struct {
 	LastRunTime     time.Time     `json:"last_run_time"`
 	CheckInterval   string        `json:"check_interval"`
 	Stats           SchedulerStats `json:"stats"`
+	Retries         map[string]DomainRetryState `json:"retries,omitempty"`
 }
 
 func (s *Scheduler) GetStatus() SchedulerStatus {
@@ -338,5 +759,6 @@ func (s *Scheduler) GetStatus() SchedulerStatus {
 		LastRunTime:   s.lastRunTime,
 		CheckInterval: interval.String(),
 		Stats:         s.stats,
+		Retries:       s.snapshotState().Retries,
 	}
 }
\ No newline at end of file
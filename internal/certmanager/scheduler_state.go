@@ -0,0 +1,183 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+)
+
+// schedulerStateFileName keeps scheduler statistics and retry timers across restarts
+const schedulerStateFileName = ".scheduler-state.json"
+
+const (
+	// retryBaseDelay is how long a domain waits after its first failed renewal
+	retryBaseDelay = time.Hour
+	// retryMaxDelay caps the wait between renewal attempts of a failing domain
+	retryMaxDelay = 24 * time.Hour
+)
+
+// DomainRetryState tracks consecutive renewal failures of a domain
+type DomainRetryState struct {
+	Failures    int       `json:"failures"`
+	LastError   string    `json:"last_error,omitempty"`
+	LastFailure time.Time `json:"last_failure"`
+	NextAttempt time.Time `json:"next_attempt"` // scheduled renewals skip the domain until then
+}
+
+// schedulerState is the persisted part of the scheduler
+type schedulerState struct {
+	Stats   SchedulerStats              `json:"stats"`
+	Retries map[string]DomainRetryState `json:"retries,omitempty"`
+}
+
+// retryDelay doubles the wait for every consecutive failure
+func retryDelay(failures int) time.Duration {
+	delay := retryBaseDelay
+	for i := 1; i < failures && delay < retryMaxDelay; i++ {
+		delay *= 2
+	}
+	if delay > retryMaxDelay {
+		delay = retryMaxDelay
+	}
+	return delay
+}
+
+func loadSchedulerState(path string) (*schedulerState, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, fmt.Errorf("failed to read scheduler state: %w", err)
+	}
+
+	var state schedulerState
+	if err := json.Unmarshal(data, &state); err != nil {
+		return nil, fmt.Errorf("failed to parse scheduler state: %w", err)
+	}
+	return &state, nil
+}
+
+func saveSchedulerState(path string, state *schedulerState) error {
+	data, err := json.MarshalIndent(state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode scheduler state: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write scheduler state: %w", err)
+	}
+	return nil
+}
+
+// SavedSchedulerState returns the statistics and retry timers last saved by a
+// scheduler using storagePath, such as that of a daemon running in another
+// process. Its next run time is only set while that scheduler runs. It returns
+// nil when no scheduler has saved its state yet.
+func SavedSchedulerState(storagePath string) (*SchedulerStats, map[string]DomainRetryState, error) {
+	state, err := loadSchedulerState(filepath.Join(storagePath, schedulerStateFileName))
+	if err != nil || state == nil {
+		return nil, nil, err
+	}
+	return &state.Stats, state.Retries, nil
+}
+
+// restoreState loads statistics and retry timers saved by a previous run
+func (s *Scheduler) restoreState() {
+	state, err := loadSchedulerState(s.statePath)
+	if err != nil {
+		s.logger.Printf("Warning: starting with fresh scheduler state: %v", err)
+		return
+	}
+	if state == nil {
+		return
+	}
+
+	startTime := s.stats.StartTime
+	s.stats = state.Stats
+	s.stats.StartTime = startTime
+	s.stats.NextRunTime = time.Time{}
+	s.lastRunTime = state.Stats.LastRunTime
+	if state.Retries != nil {
+		s.retries = state.Retries
+	}
+
+	s.logger.Printf("Restored scheduler state: %d previous runs, %d domains backing off after failures",
+		s.stats.TotalRuns, len(s.retries))
+}
+
+// saveState persists statistics and retry timers. Callers must not hold s.mu.
+func (s *Scheduler) saveState() {
+	s.mu.RLock()
+	state := s.snapshotState()
+	s.mu.RUnlock()
+
+	s.persistState(state)
+}
+
+// snapshotState copies the state to persist. Callers must hold s.mu.
+func (s *Scheduler) snapshotState() *schedulerState {
+	state := &schedulerState{
+		Stats:   s.stats,
+		Retries: make(map[string]DomainRetryState, len(s.retries)),
+	}
+	if s.isRunning {
+		state.Stats.NextRunTime = s.nextRunTime
+	}
+	for domain, retry := range s.retries {
+		state.Retries[domain] = retry
+	}
+	return state
+}
+
+func (s *Scheduler) persistState(state *schedulerState) {
+	if err := saveSchedulerState(s.statePath, state); err != nil {
+		s.logger.Printf("Warning: %v", err)
+	}
+}
+
+// backingOff reports whether a failing domain is still waiting for its next attempt
+func (s *Scheduler) backingOff(domain string, now time.Time) (DomainRetryState, bool) {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	retry, exists := s.retries[domain]
+	return retry, exists && now.Before(retry.NextAttempt)
+}
+
+// recordResult updates the retry timer of a domain after a renewal attempt
+func (s *Scheduler) recordResult(domain string, err error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if err == nil {
+		delete(s.retries, domain)
+		return
+	}
+
+	retry := s.retries[domain]
+	retry.Failures++
+	retry.LastError = err.Error()
+	retry.LastFailure = time.Now()
+	retry.NextAttempt = retry.LastFailure.Add(retryDelay(retry.Failures))
+	s.retries[domain] = retry
+}
+
+// GetRetryState returns the domains whose scheduled renewals are backing off
+func (s *Scheduler) GetRetryState() map[string]DomainRetryState {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]DomainRetryState, len(s.retries))
+	for domain, retry := range s.retries {
+		result[domain] = retry
+	}
+	return result
+}
@@ -0,0 +1,114 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestScheduler_PersistsRetryStateAcrossRestarts(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	mockClient.On("RenewCertificate", mock.Anything).Return(nil, errors.New("CA unavailable"))
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs:      map[string]*Certificate{"example.com": createTestCertificate("example.com", 10)},
+	}
+
+	scheduler, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+	scheduler.performRenewalCheck()
+
+	retry, exists := scheduler.GetRetryState()["example.com"]
+	require.True(t, exists)
+	assert.Equal(t, 1, retry.Failures)
+	assert.Contains(t, retry.LastError, "CA unavailable")
+	assert.WithinDuration(t, time.Now().Add(retryBaseDelay), retry.NextAttempt, time.Minute)
+
+	// Other processes, such as the top command, read what was saved
+	stats, retries, err := SavedSchedulerState(testDir)
+	require.NoError(t, err)
+	assert.Equal(t, 1, stats.TotalRuns)
+	assert.True(t, stats.NextRunTime.IsZero(), "a scheduler that isn't running has no next run")
+	assert.Contains(t, retries, "example.com")
+
+	// A restarted scheduler keeps the stats and honours the backoff
+	restarted, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+	assert.Equal(t, 1, restarted.GetStats().TotalRuns)
+	assert.Equal(t, 1, restarted.GetStats().FailedRuns)
+	assert.Contains(t, restarted.GetRetryState(), "example.com")
+
+	require.NoError(t, restarted.performRenewalWithContext(context.Background(), newRunSummary(1, "manual")))
+	mockClient.AssertNumberOfCalls(t, "RenewCertificate", 1)
+}
+
+func TestScheduler_RetryFailed(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	mockClient.On("RenewCertificate", mock.Anything).Return(nil, errors.New("CA unavailable")).Once()
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockClient,
+		logger:     logger,
+		certs: map[string]*Certificate{
+			"example.com":     createTestCertificate("example.com", 10),
+			"api.example.com": createTestCertificate("api.example.com", 80),
+		},
+	}
+
+	scheduler, err := NewScheduler(cfg, cm, logger)
+	require.NoError(t, err)
+	scheduler.performRenewalCheck()
+	scheduler.recordResult("removed.example.com", errors.New("CA unavailable"))
+
+	// Without --now the failed domain keeps backing off; the removed one is forgotten
+	summary, err := scheduler.RetryFailed(context.Background(), false)
+	require.NoError(t, err)
+	assert.Equal(t, "retry", summary.Trigger)
+	require.Len(t, summary.Domains, 2)
+	assert.Equal(t, "example.com", summary.Domains[0].Domain)
+	assert.Contains(t, summary.Domains[0].Reason, "backing off after 1 failures")
+	assert.Equal(t, "no longer managed", summary.Domains[1].Reason)
+	assert.NotContains(t, scheduler.GetRetryState(), "removed.example.com")
+	mockClient.AssertNumberOfCalls(t, "RenewCertificate", 1)
+
+	mockClient.On("RenewCertificate", mock.Anything).Return(createTestCertificate("example.com", 90), nil)
+	summary, err = scheduler.RetryFailed(context.Background(), true)
+	require.NoError(t, err)
+	assert.Equal(t, "succeeded", summary.Status)
+	assert.Equal(t, 1, summary.Checked)
+	assert.Equal(t, 1, summary.Renewed)
+	assert.Empty(t, scheduler.GetRetryState())
+	mockClient.AssertNumberOfCalls(t, "RenewCertificate", 2)
+
+	latest, err := cm.LatestRunSummary()
+	require.NoError(t, err)
+	assert.Equal(t, "retry", latest.Trigger)
+}
+
+func TestRetryDelay(t *testing.T) {
+	assert.Equal(t, time.Hour, retryDelay(1))
+	assert.Equal(t, 2*time.Hour, retryDelay(2))
+	assert.Equal(t, 8*time.Hour, retryDelay(4))
+	assert.Equal(t, retryMaxDelay, retryDelay(10))
+}
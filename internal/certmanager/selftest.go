@@ -0,0 +1,169 @@
+package certmanager
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// Hops an HTTP-01 validation request passes, in order
+const (
+	HopDNS             = "dns"
+	HopPort80          = "port_80"
+	HopTraefikRule     = "traefik_rule"
+	HopChallengeServer = "challenge_server"
+	HopExternal        = "external"
+)
+
+// SelfTestStep is the outcome of checking one hop
+type SelfTestStep struct {
+	Hop     string `json:"hop" yaml:"hop"`
+	OK      bool   `json:"ok" yaml:"ok"`
+	Skipped bool   `json:"skipped,omitempty" yaml:"skipped,omitempty"` // an earlier hop failed, or it isn't configured
+	Detail  string `json:"detail" yaml:"detail"`
+}
+
+// SelfTest reports which hop of HTTP-01 validation fails for a domain
+type SelfTest struct {
+	Domain string         `json:"domain" yaml:"domain"`
+	URL    string         `json:"url" yaml:"url"`
+	Steps  []SelfTestStep `json:"steps" yaml:"steps"`
+	Failed string         `json:"failed,omitempty" yaml:"failed,omitempty"` // the first hop that failed
+}
+
+// check records the outcome of fn for hop, or skips the hop after an earlier
+// one failed
+func (s *SelfTest) check(hop string, fn func() (bool, string)) {
+	if s.Failed != "" {
+		s.Steps = append(s.Steps, SelfTestStep{Hop: hop, Skipped: true, Detail: "not checked, " + s.Failed + " failed"})
+		return
+	}
+	ok, detail := fn()
+	s.Steps = append(s.Steps, SelfTestStep{Hop: hop, OK: ok, Detail: detail})
+	if !ok {
+		s.Failed = hop
+	}
+}
+
+// SelfTest serves a dummy token on the challenge listener and follows the path
+// a CA's validation request takes to it, hop by hop: DNS, port 80, the Traefik
+// rule for the challenge path, the challenge server and, with a prober, the
+// outside. No order is placed, so no validation attempt is spent.
+func (cm *CertificateManager) SelfTest(ctx context.Context, domain string) (*SelfTest, error) {
+	if strings.HasPrefix(domain, "*.") {
+		return nil, fmt.Errorf("wildcard domains are validated with dns-01")
+	}
+	if cm.config.ChallengeFor(domain) == "dns-01" {
+		return nil, fmt.Errorf("%s is validated with dns-01", domain)
+	}
+	if cm.diagnoser == nil {
+		return nil, fmt.Errorf("HTTP-01 diagnosis is not set up")
+	}
+	return cm.diagnoser.SelfTest(ctx, domain)
+}
+
+// SelfTest checks each hop to the challenge listener for domain. Like
+// Diagnose, it binds the challenge listener.
+func (d *HTTP01Diagnoser) SelfTest(ctx context.Context, domain string) (*SelfTest, error) {
+	ctx, cancel := context.WithTimeout(ctx, diagnosisTimeout)
+	defer cancel()
+
+	token, err := probeToken()
+	if err != nil {
+		return nil, err
+	}
+
+	listener, err := net.Listen("tcp", d.listenAddr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to bind challenge listener %s: %w", d.listenAddr, err)
+	}
+	server := &http.Server{Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != challengePathPrefix+token {
+			http.NotFound(w, r)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain")
+		io.WriteString(w, token)
+	})}
+	go server.Serve(listener)
+	defer server.Close()
+
+	test := &SelfTest{Domain: domain, URL: d.challengeURL(domain, token)}
+	target, err := url.Parse(test.URL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid challenge URL: %w", err)
+	}
+
+	test.check(HopDNS, func() (bool, string) {
+		addrs, err := d.lookupHost(ctx, target.Hostname())
+		if err != nil {
+			return false, fmt.Sprintf("%s doesn't resolve: %v", target.Hostname(), err)
+		}
+		return true, fmt.Sprintf("%s resolves to %s", target.Hostname(), strings.Join(addrs, ", "))
+	})
+
+	port := target.Port()
+	if port == "" {
+		port = "80"
+	}
+	test.check(HopPort80, func() (bool, string) {
+		var dialer net.Dialer
+		conn, err := dialer.DialContext(ctx, "tcp", net.JoinHostPort(target.Hostname(), port))
+		if err != nil {
+			return false, fmt.Sprintf("nothing accepts connections on port %s: %v", port, err)
+		}
+		conn.Close()
+		return true, fmt.Sprintf("port %s accepts connections", port)
+	})
+
+	// Traefik answers 404 without a router for the path, and 502 to 504 when the
+	// router's service can't reach the challenge server
+	var local ProbeResult
+	test.check(HopTraefikRule, func() (bool, string) {
+		local = d.probeLocal(ctx, test.URL, token)
+		switch {
+		case local.Error != "":
+			return false, "request failed: " + local.Error
+		case local.Reachable, local.StatusCode == http.StatusBadGateway,
+			local.StatusCode == http.StatusServiceUnavailable, local.StatusCode == http.StatusGatewayTimeout:
+			return true, "a router forwards " + challengePathPrefix
+		default:
+			return false, fmt.Sprintf("%s; add a router for PathPrefix(`%s`) on the HTTP entrypoint", local, challengePathPrefix)
+		}
+	})
+
+	test.check(HopChallengeServer, func() (bool, string) {
+		if !local.Reachable {
+			return false, fmt.Sprintf("%s; the router's service must forward to %s", local, d.listenAddr)
+		}
+		return true, "the token came back from " + d.listenAddr
+	})
+
+	if d.proberURL == "" && test.Failed == "" {
+		test.Steps = append(test.Steps, SelfTestStep{Hop: HopExternal, Skipped: true,
+			Detail: "configure acme.http01_prober to check reachability from outside"})
+	} else {
+		test.check(HopExternal, func() (bool, string) {
+			external := d.probeExternal(ctx, test.URL, token)
+			if !external.Reachable {
+				return false, fmt.Sprintf("the prober got %s; check firewalls, port 80 forwarding and public DNS records", external)
+			}
+			return true, "the token came back through the prober"
+		})
+	}
+
+	d.logger.Printf("HTTP-01 self-test for %s: %s", domain, test.Summary())
+	return test, nil
+}
+
+// Summary names the hop that failed, or says that all passed
+func (s *SelfTest) Summary() string {
+	if s.Failed == "" {
+		return "all checked hops passed"
+	}
+	return s.Failed + " failed"
+}
@@ -0,0 +1,97 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// hopResults maps each hop of a self-test to ok, FAILED or skipped
+func hopResults(test *SelfTest) map[string]string {
+	results := make(map[string]string)
+	for _, step := range test.Steps {
+		switch {
+		case step.Skipped:
+			results[step.Hop] = "skipped"
+		case step.OK:
+			results[step.Hop] = "ok"
+		default:
+			results[step.Hop] = "FAILED"
+		}
+	}
+	return results
+}
+
+func TestHTTP01Diagnoser_SelfTest(t *testing.T) {
+	d := newTestDiagnoser(t, "")
+
+	test, err := d.SelfTest(context.Background(), "example.com")
+	require.NoError(t, err)
+
+	assert.Empty(t, test.Failed)
+	assert.Equal(t, map[string]string{
+		HopDNS:             "ok",
+		HopPort80:          "ok",
+		HopTraefikRule:     "ok",
+		HopChallengeServer: "ok",
+		HopExternal:        "skipped",
+	}, hopResults(test))
+}
+
+func TestHTTP01Diagnoser_SelfTestFailedHop(t *testing.T) {
+	tests := []struct {
+		name    string
+		traefik http.Handler
+		lookup  error
+		failed  string
+	}{
+		{name: "no dns record", lookup: errors.New("no such host"), failed: HopDNS},
+		{name: "no router for the challenge path", traefik: http.NotFoundHandler(), failed: HopTraefikRule},
+		{
+			name: "challenge server unreachable from traefik",
+			traefik: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				http.Error(w, "Bad Gateway", http.StatusBadGateway)
+			}),
+			failed: HopChallengeServer,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			d := newTestDiagnoser(t, "")
+			if tt.traefik != nil {
+				traefik := httptest.NewServer(tt.traefik)
+				defer traefik.Close()
+				d.challengeURL = func(domain, token string) string {
+					return traefik.URL + challengePathPrefix + token
+				}
+			}
+			if tt.lookup != nil {
+				d.lookupHost = func(ctx context.Context, host string) ([]string, error) {
+					return nil, tt.lookup
+				}
+			}
+
+			test, err := d.SelfTest(context.Background(), "example.com")
+			require.NoError(t, err)
+
+			assert.Equal(t, tt.failed, test.Failed)
+			assert.Equal(t, "FAILED", hopResults(test)[tt.failed])
+			assert.Equal(t, "skipped", hopResults(test)[HopExternal])
+		})
+	}
+}
+
+func TestCertificateManager_SelfTestDNS01(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.ACME.Challenge = "dns-01"
+	cm := &CertificateManager{config: cfg, diagnoser: newTestDiagnoser(t, "")}
+
+	_, err := cm.SelfTest(context.Background(), "example.com")
+	assert.EqualError(t, err, "example.com is validated with dns-01")
+}
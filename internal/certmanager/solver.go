@@ -0,0 +1,174 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
+	"github.com/go-acme/lego/v4/challenge/dns01"
+)
+
+// DNSRecord is the TXT record answering a dns-01 challenge
+type DNSRecord struct {
+	Domain string `json:"domain"` // being validated, e.g. *.example.com
+	FQDN   string `json:"fqdn"`   // of the record after following CNAMEs, e.g. _acme-challenge.example.com.
+	Value  string `json:"value"`
+}
+
+// ChallengeSolver creates and removes the records of dns-01 challenges. It
+// lets DNS providers be added without compiling in a lego provider.
+type ChallengeSolver interface {
+	Present(ctx context.Context, record DNSRecord) error
+	CleanUp(ctx context.Context, record DNSRecord) error
+	// Timeout returns how long to wait for a presented record to be visible,
+	// and how often to check
+	Timeout() (timeout, interval time.Duration)
+}
+
+// solverProvider adapts a ChallengeSolver to lego's challenge provider,
+// running it under the context of the operation ordering the certificate
+type solverProvider struct {
+	solver ChallengeSolver
+	ctx    context.Context
+
+	// challenges holds the span of each presented challenge until its
+	// cleanup, so the trace shows how long propagation and validation took
+	mu         sync.Mutex
+	challenges map[string]*tracing.Span
+}
+
+func (p *solverProvider) Present(domain, token, keyAuth string) error {
+	record := challengeRecord(domain, keyAuth)
+	ctx, challenge := tracing.Start(p.ctx, "dns01.challenge",
+		tracing.String("domain", domain), tracing.String("dns.record", record.FQDN))
+	ctx, span := tracing.Start(ctx, "dns01.present")
+	err := p.solver.Present(ctx, record)
+	span.RecordError(err)
+	span.End()
+	if err != nil {
+		challenge.RecordError(err)
+		challenge.End()
+		return err
+	}
+
+	p.mu.Lock()
+	defer p.mu.Unlock()
+	if p.challenges == nil {
+		p.challenges = make(map[string]*tracing.Span)
+	}
+	p.challenges[domain+" "+token] = challenge
+	return nil
+}
+
+// CleanUp also runs when the operation was cancelled, so no record is left behind
+func (p *solverProvider) CleanUp(domain, token, keyAuth string) error {
+	p.mu.Lock()
+	challenge := p.challenges[domain+" "+token]
+	delete(p.challenges, domain+" "+token)
+	p.mu.Unlock()
+	defer challenge.End()
+
+	ctx := context.WithoutCancel(p.ctx)
+	_, span := tracing.Start(tracing.ContextWithSpan(ctx, challenge), "dns01.cleanup")
+	err := p.solver.CleanUp(ctx, challengeRecord(domain, keyAuth))
+	span.RecordError(err)
+	span.End()
+	return err
+}
+
+func (p *solverProvider) Timeout() (time.Duration, time.Duration) {
+	return p.solver.Timeout()
+}
+
+func challengeRecord(domain, keyAuth string) DNSRecord {
+	info := dns01.GetChallengeInfo(domain, keyAuth)
+	return DNSRecord{Domain: domain, FQDN: info.EffectiveFQDN, Value: info.Value}
+}
+
+// WebhookSolver creates and removes dns-01 records by POSTing them as JSON to
+// user-provided endpoints, which talk to the DNS provider
+type WebhookSolver struct {
+	presentURL         string
+	cleanupURL         string
+	token              string
+	propagationTimeout time.Duration
+	pollingInterval    time.Duration
+	httpClient         *http.Client
+}
+
+func NewWebhookSolver(cfg config.DNS01Webhook) (*WebhookSolver, error) {
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook timeout: %w", err)
+	}
+	propagationTimeout, err := time.ParseDuration(cfg.PropagationTimeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook propagation timeout: %w", err)
+	}
+	pollingInterval, err := time.ParseDuration(cfg.PollingInterval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid webhook polling interval: %w", err)
+	}
+
+	return &WebhookSolver{
+		presentURL:         cfg.PresentURL,
+		cleanupURL:         cfg.CleanupURL,
+		token:              cfg.Token,
+		propagationTimeout: propagationTimeout,
+		pollingInterval:    pollingInterval,
+		httpClient:         &http.Client{Timeout: timeout},
+	}, nil
+}
+
+func (s *WebhookSolver) Present(ctx context.Context, record DNSRecord) error {
+	if err := s.post(ctx, s.presentURL, record); err != nil {
+		return fmt.Errorf("failed to present dns-01 record %s: %w", record.FQDN, err)
+	}
+	return nil
+}
+
+func (s *WebhookSolver) CleanUp(ctx context.Context, record DNSRecord) error {
+	if err := s.post(ctx, s.cleanupURL, record); err != nil {
+		return fmt.Errorf("failed to clean up dns-01 record %s: %w", record.FQDN, err)
+	}
+	return nil
+}
+
+func (s *WebhookSolver) Timeout() (time.Duration, time.Duration) {
+	return s.propagationTimeout, s.pollingInterval
+}
+
+func (s *WebhookSolver) post(ctx context.Context, endpoint string, record DNSRecord) error {
+	payload, err := json.Marshal(record)
+	if err != nil {
+		return fmt.Errorf("failed to encode record: %w", err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if s.token != "" {
+		req.Header.Set("Authorization", "Bearer "+s.token)
+	}
+
+	resp, err := s.httpClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
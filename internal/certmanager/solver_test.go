@@ -0,0 +1,63 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWebhookSolver(t *testing.T) {
+	var calls []string
+	var records []DNSRecord
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		assert.Equal(t, "Bearer secret", r.Header.Get("Authorization"))
+		var record DNSRecord
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&record))
+		calls = append(calls, r.URL.Path)
+		records = append(records, record)
+		if record.Domain == "broken.example.com" {
+			http.Error(w, "zone not found", http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	solver, err := NewWebhookSolver(config.DNS01Webhook{
+		PresentURL:         server.URL + "/present",
+		CleanupURL:         server.URL + "/cleanup",
+		Token:              "secret",
+		Timeout:            "5s",
+		PropagationTimeout: "2m",
+		PollingInterval:    "5s",
+	})
+	require.NoError(t, err)
+
+	timeout, interval := solver.Timeout()
+	assert.Equal(t, 2*time.Minute, timeout)
+	assert.Equal(t, 5*time.Second, interval)
+
+	// Records are presented under the operation's context and cleaned up even
+	// once it is cancelled
+	ctx, cancel := context.WithCancel(context.Background())
+	provider := &solverProvider{solver: solver, ctx: ctx}
+
+	require.NoError(t, provider.Present("example.com", "token", "key-authorization"))
+	cancel()
+	require.NoError(t, provider.CleanUp("example.com", "token", "key-authorization"))
+
+	assert.Equal(t, []string{"/present", "/cleanup"}, calls)
+	assert.Equal(t, "example.com", records[0].Domain)
+	assert.Equal(t, "_acme-challenge.example.com.", records[0].FQDN)
+	assert.NotEmpty(t, records[0].Value)
+	assert.Equal(t, records[0], records[1])
+
+	err = solver.Present(context.Background(), DNSRecord{Domain: "broken.example.com", FQDN: "_acme-challenge.broken.example.com."})
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "404 Not Found: zone not found")
+}
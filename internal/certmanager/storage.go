@@ -0,0 +1,85 @@
+package certmanager
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// storageName encodes a domain for use in file names. Wildcards are stored
+// with a leading "_" because "*" is not portable across filesystems.
+func storageName(domain string) string {
+	if strings.HasPrefix(domain, "*.") {
+		return "_" + domain[1:]
+	}
+	return domain
+}
+
+// domainFromStorageName reverses storageName
+func domainFromStorageName(name string) string {
+	if strings.HasPrefix(name, "_.") {
+		return "*" + name[1:]
+	}
+	return name
+}
+
+// domainFromCertFile returns the domain for a leaf certificate file name,
+// or false for issuer chains and other files
+func domainFromCertFile(fileName string) (string, bool) {
+	if filepath.Ext(fileName) != ".crt" {
+		return "", false
+	}
+
+	name := strings.TrimSuffix(fileName, ".crt")
+	if name == "" || name == "issuer" || strings.HasSuffix(name, ".issuer") {
+		return "", false
+	}
+
+	return domainFromStorageName(name), true
+}
+
+// storedFile is one file replaced by writeFiles
+type storedFile struct {
+	path string
+	data []byte
+	perm os.FileMode
+}
+
+// writeFiles replaces files through temporary files that are renamed into place
+// once all of them are on disk, so a failed write leaves the previous files
+// intact. A crash between two renames can still leave a mix of generations,
+// which loading detects.
+func writeFiles(files ...storedFile) error {
+	for i, file := range files {
+		if err := writeSynced(file.path+".tmp", file.data, file.perm); err != nil {
+			for _, written := range files[:i+1] {
+				os.Remove(written.path + ".tmp")
+			}
+			return err
+		}
+	}
+
+	for _, file := range files {
+		if err := os.Rename(file.path+".tmp", file.path); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// writeSynced writes a file and flushes it to disk before it is renamed into place
+func writeSynced(path string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, perm)
+	if err != nil {
+		return err
+	}
+	if _, err := f.Write(data); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Sync(); err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
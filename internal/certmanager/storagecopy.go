@@ -0,0 +1,188 @@
+package certmanager
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"io/fs"
+	"log"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// StorageCopy describes the storage copied by CopyStorage
+type StorageCopy struct {
+	From         string   `json:"from"`
+	To           string   `json:"to"`
+	Files        int      `json:"files"`
+	Bytes        int64    `json:"bytes"`
+	Certificates []string `json:"certificates"` // domains whose certificates were copied
+}
+
+// StoragePathFromURL returns the directory of a storage location, given as
+// file://PATH or as a plain path. Storage is only kept on the filesystem, so
+// other schemes are rejected.
+func StoragePathFromURL(location string) (string, error) {
+	if !strings.Contains(location, "://") {
+		return location, nil
+	}
+
+	u, err := url.Parse(location)
+	if err != nil {
+		return "", fmt.Errorf("invalid storage location %q: %w", location, err)
+	}
+	if u.Scheme != "file" {
+		return "", fmt.Errorf("unsupported storage location %q: only file:// storage is supported", location)
+	}
+
+	// file://./certs parses ./certs as host and path
+	path := u.Host + u.Path
+	if path == "" {
+		return "", fmt.Errorf("storage location %q has no path", location)
+	}
+	return path, nil
+}
+
+// CopyStorage copies everything under the storage directory from to the
+// directory to, including keys, the ACME account, order journals, state and
+// metadata, and then compares each copied file with its source. Locks of
+// orders in progress are not copied, and a storage with any is refused, as the
+// copy must not run while cert-manager is writing to from. The target must
+// not exist or be empty.
+func CopyStorage(from, to string, logger *log.Logger) (*StorageCopy, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Storage] ", log.LstdFlags)
+	}
+
+	absFrom, err := filepath.Abs(from)
+	if err != nil {
+		return nil, err
+	}
+	absTo, err := filepath.Abs(to)
+	if err != nil {
+		return nil, err
+	}
+	if absFrom == absTo || strings.HasPrefix(absTo, absFrom+string(filepath.Separator)) {
+		return nil, fmt.Errorf("target %s is inside the source storage %s", to, from)
+	}
+
+	if info, err := os.Stat(from); err != nil {
+		return nil, fmt.Errorf("failed to read source storage: %w", err)
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("source storage %s is not a directory", from)
+	}
+	if locks, _ := os.ReadDir(filepath.Join(from, locksDirName)); len(locks) > 0 {
+		return nil, fmt.Errorf("source storage %s has orders in progress; stop cert-manager before copying it", from)
+	}
+	if entries, err := os.ReadDir(to); err == nil && len(entries) > 0 {
+		return nil, fmt.Errorf("target storage %s is not empty", to)
+	} else if err != nil && !os.IsNotExist(err) {
+		return nil, fmt.Errorf("failed to read target storage: %w", err)
+	}
+
+	files, err := storageFiles(from)
+	if err != nil {
+		return nil, fmt.Errorf("failed to list source storage: %w", err)
+	}
+
+	result := &StorageCopy{From: from, To: to, Certificates: []string{}}
+	for _, name := range files {
+		size, err := copyStorageFile(filepath.Join(from, name), filepath.Join(to, name))
+		if err != nil {
+			return nil, fmt.Errorf("failed to copy %s: %w", name, err)
+		}
+		result.Files++
+		result.Bytes += size
+		if filepath.Dir(name) == "." {
+			if domain, ok := domainFromCertFile(name); ok {
+				result.Certificates = append(result.Certificates, domain)
+			}
+		}
+	}
+
+	if err := verifyStorageCopy(from, to, files); err != nil {
+		return nil, fmt.Errorf("copy of %s doesn't match its source: %w", from, err)
+	}
+
+	logger.Printf("Copied %d files (%d certificates) from %s to %s", result.Files, len(result.Certificates), from, to)
+	return result, nil
+}
+
+// storageFiles lists the regular files under dir relative to it, sorted,
+// leaving out locks and the temporary files of interrupted writes
+func storageFiles(dir string) ([]string, error) {
+	var files []string
+	err := filepath.WalkDir(dir, func(path string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if entry.IsDir() && entry.Name() == locksDirName {
+			return filepath.SkipDir
+		}
+		if !entry.Type().IsRegular() || strings.HasSuffix(entry.Name(), ".tmp") {
+			return nil
+		}
+		name, err := filepath.Rel(dir, path)
+		if err != nil {
+			return err
+		}
+		files = append(files, name)
+		return nil
+	})
+	sort.Strings(files)
+	return files, err
+}
+
+// copyStorageFile copies a file with its permissions and modification time,
+// which loading takes as the issuance time of a certificate
+func copyStorageFile(from, to string) (int64, error) {
+	info, err := os.Stat(from)
+	if err != nil {
+		return 0, err
+	}
+	data, err := os.ReadFile(from)
+	if err != nil {
+		return 0, err
+	}
+
+	if err := os.MkdirAll(filepath.Dir(to), 0700); err != nil {
+		return 0, err
+	}
+	if err := writeFiles(storedFile{path: to, data: data, perm: info.Mode().Perm()}); err != nil {
+		return 0, err
+	}
+	if err := os.Chtimes(to, info.ModTime(), info.ModTime()); err != nil {
+		return 0, err
+	}
+	return int64(len(data)), nil
+}
+
+// verifyStorageCopy compares the checksum of each copied file with its source
+func verifyStorageCopy(from, to string, files []string) error {
+	for _, name := range files {
+		want, err := fileChecksum(filepath.Join(from, name))
+		if err != nil {
+			return err
+		}
+		got, err := fileChecksum(filepath.Join(to, name))
+		if err != nil {
+			return err
+		}
+		if !bytes.Equal(got, want) {
+			return fmt.Errorf("%s differs", name)
+		}
+	}
+	return nil
+}
+
+func fileChecksum(path string) ([]byte, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	return sum[:], nil
+}
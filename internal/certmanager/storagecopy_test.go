@@ -0,0 +1,82 @@
+package certmanager
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStoragePathFromURL(t *testing.T) {
+	tests := []struct {
+		location string
+		path     string
+		err      string
+	}{
+		{location: "./certs", path: "./certs"},
+		{location: "file://./certs", path: "./certs"},
+		{location: "file:///var/lib/cert-manager", path: "/var/lib/cert-manager"},
+		{location: "vault://vault:8200/secret/certs", err: `unsupported storage location "vault://vault:8200/secret/certs": only file:// storage is supported`},
+		{location: "file://", err: `storage location "file://" has no path`},
+	}
+	for _, tt := range tests {
+		path, err := StoragePathFromURL(tt.location)
+		if tt.err != "" {
+			assert.EqualError(t, err, tt.err)
+			continue
+		}
+		require.NoError(t, err, tt.location)
+		assert.Equal(t, tt.path, path)
+	}
+}
+
+func TestCopyStorage(t *testing.T) {
+	from := setupTestDir(t)
+	issuedAt := time.Now().Add(-48 * time.Hour).Truncate(time.Second)
+	for name, perm := range map[string]os.FileMode{
+		"example.com.crt":             0644,
+		"example.com.key":             0600,
+		"_.example.org.crt":           0644,
+		"_.example.org.key":           0600,
+		"acme-account.key":            0600,
+		"runs/20250101T000000Z.json":  0644,
+		"example.com.crt.tmp":         0644,
+		locksDirName + "/example.com": 0644,
+	} {
+		path := filepath.Join(from, name)
+		require.NoError(t, os.MkdirAll(filepath.Dir(path), 0755))
+		require.NoError(t, os.WriteFile(path, []byte(name), perm))
+		require.NoError(t, os.Chtimes(path, issuedAt, issuedAt))
+	}
+	to := filepath.Join(setupTestDir(t), "storage")
+
+	_, err := CopyStorage(from, to, nil)
+	assert.ErrorContains(t, err, "has orders in progress")
+
+	require.NoError(t, os.RemoveAll(filepath.Join(from, locksDirName)))
+	copied, err := CopyStorage(from, to, nil)
+	require.NoError(t, err)
+
+	assert.Equal(t, 6, copied.Files)
+	assert.Equal(t, []string{"*.example.org", "example.com"}, copied.Certificates)
+	for _, name := range []string{"example.com.key", "runs/20250101T000000Z.json"} {
+		data, err := os.ReadFile(filepath.Join(to, name))
+		require.NoError(t, err)
+		assert.Equal(t, name, string(data))
+	}
+	info, err := os.Stat(filepath.Join(to, "example.com.key"))
+	require.NoError(t, err)
+	assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	assert.True(t, info.ModTime().Equal(issuedAt))
+	assert.NoFileExists(t, filepath.Join(to, "example.com.crt.tmp"))
+
+	// The target now holds a storage, which is never overwritten
+	_, err = CopyStorage(from, to, nil)
+	assert.ErrorContains(t, err, "is not empty")
+
+	_, err = CopyStorage(from, filepath.Join(from, "copy"), nil)
+	assert.ErrorContains(t, err, "is inside the source storage")
+}
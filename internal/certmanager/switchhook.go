@@ -0,0 +1,79 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/O-tero/traefik-cert-manager/internal/switchhook"
+)
+
+// ErrSwitchAborted is returned when a failing pre_switch webhook keeps the
+// previous certificate in place
+var ErrSwitchAborted = errors.New("certificate switch aborted")
+
+// beforeSwitch calls the pre_switch webhook of a new certificate's domain
+// before the certificate is stored and Traefik switches to it. With failure
+// policy abort, a failed call keeps the previous certificate.
+func (cm *CertificateManager) beforeSwitch(ctx context.Context, cert *Certificate) error {
+	if err := cm.callSwitchWebhook(ctx, switchhook.EventPreSwitch, cert); err != nil {
+		cm.logger.Printf("Keeping the previous certificate of %s: %v", cert.Domain, err)
+		return fmt.Errorf("%w for %s: %w", ErrSwitchAborted, cert.Domain, err)
+	}
+	return nil
+}
+
+// afterSwitch calls the post_switch webhook of a domain once Traefik can serve
+// its new certificate. With failure policy abort, a failed call stops the
+// rollout: it is reported as a failure, and remote deployments and post hooks
+// are skipped.
+func (cm *CertificateManager) afterSwitch(ctx context.Context, domain string, cert *Certificate) error {
+	err := cm.callSwitchWebhook(ctx, switchhook.EventPostSwitch, cert)
+	if err == nil {
+		return nil
+	}
+
+	cm.recordFailure(domain, switchhook.EventPostSwitch, err)
+	cm.runHooks(ctx, hooks.EventOnFailure, domain, cert, err)
+	if cm.notifier != nil {
+		msg := notify.Message{
+			Level:   notify.LevelCritical,
+			Event:   notify.EventSwitchFailure,
+			Domain:  domain,
+			Subject: fmt.Sprintf("Post-switch webhook failed for %s", domain),
+			Body: fmt.Sprintf("Traefik serves the new certificate for %s, but its post_switch webhook failed.\n\n"+
+				"Error: %v\n\nRemote deployments and post hooks were skipped.", domain, err),
+		}
+		if sendErr := cm.notifier.Send(msg); sendErr != nil {
+			cm.logger.Printf("Failed to send post-switch failure alert: %v", sendErr)
+		}
+	}
+	return fmt.Errorf("post_switch webhook for %s: %w", domain, err)
+}
+
+// callSwitchWebhook calls the webhook of an event for cert's domain, if it has
+// one. Failures under failure policy continue are recorded and not returned.
+func (cm *CertificateManager) callSwitchWebhook(ctx context.Context, event string, cert *Certificate) error {
+	entry, _ := cm.domainConfig(cert.Domain)
+	webhooks := entry.Switch
+	if cm.switchHooks == nil || switchhook.URL(webhooks, event) == "" {
+		return nil
+	}
+
+	err := cm.switchHooks.Call(ctx, webhooks, switchhook.Payload{
+		Event:    event,
+		Domain:   cert.Domain,
+		Service:  entry.Service,
+		Serial:   cert.SerialNumber,
+		NotAfter: cert.ExpiresAt,
+	})
+	if err != nil && webhooks.FailurePolicy == config.SwitchContinue {
+		cm.logger.Printf("Warning: %v; continuing as its failure policy is continue", err)
+		cm.recordFailure(cert.Domain, event, err)
+		return nil
+	}
+	return err
+}
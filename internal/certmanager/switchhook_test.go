@@ -0,0 +1,84 @@
+package certmanager
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/switchhook"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/mock"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSwitchWebhooks(t *testing.T) {
+	var events []string
+	failing := map[string]bool{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var p switchhook.Payload
+		require.NoError(t, json.NewDecoder(r.Body).Decode(&p))
+		events = append(events, p.Event+" "+p.Domain)
+		if failing[p.Event] {
+			http.Error(w, "CDN unavailable", http.StatusBadGateway)
+		}
+	}))
+	defer server.Close()
+
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Domains[0].Switch = config.SwitchWebhooks{
+		PreSwitch:     server.URL + "/pre",
+		PostSwitch:    server.URL + "/post",
+		Timeout:       "5s",
+		FailurePolicy: config.SwitchAbort,
+	}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	mockClient := NewMockACMEClient(testDir, logger)
+	renewed := createTestCertificate("example.com", 90)
+	mockClient.On("RenewCertificate", mock.Anything).Return(renewed, nil)
+	cm := &CertificateManager{
+		config:      cfg,
+		acmeClient:  mockClient,
+		switchHooks: switchhook.NewCaller(logger),
+		logger:      logger,
+		certs:       map[string]*Certificate{"example.com": createTestCertificate("example.com", 10)},
+	}
+
+	// The ACME client asks before storing a new certificate
+	require.NoError(t, cm.beforeSwitch(context.Background(), renewed))
+	failing[switchhook.EventPreSwitch] = true
+	assert.ErrorIs(t, cm.beforeSwitch(context.Background(), renewed), ErrSwitchAborted)
+	assert.Equal(t, []string{"pre_switch example.com", "pre_switch example.com"}, events)
+
+	// A failed post_switch fails the renewal of a certificate already in place
+	events = nil
+	failing[switchhook.EventPostSwitch] = true
+	err := cm.RenewCertificate(context.Background(), "example.com")
+	assert.ErrorContains(t, err, "CDN unavailable")
+	assert.Equal(t, []string{"post_switch example.com"}, events)
+	got, err := cm.GetCertificate("example.com")
+	require.NoError(t, err)
+	assert.Same(t, renewed, got)
+	failures := cm.TakeFailures()
+	require.Len(t, failures, 1)
+	assert.Equal(t, "post_switch", failures[0].Operation)
+
+	// With failure policy continue, failures are only recorded
+	cfg.Domains[0].Switch.FailurePolicy = config.SwitchContinue
+	assert.NoError(t, cm.beforeSwitch(context.Background(), renewed))
+	assert.NoError(t, cm.RenewCertificate(context.Background(), "example.com"))
+	failures = cm.TakeFailures()
+	require.Len(t, failures, 2)
+	assert.Equal(t, "pre_switch", failures[0].Operation)
+	assert.Equal(t, "post_switch", failures[1].Operation)
+
+	// Domains without webhooks aren't held up
+	assert.NoError(t, cm.beforeSwitch(context.Background(), createTestCertificate("api.example.com", 90)))
+}
@@ -0,0 +1,54 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
+)
+
+// startOrderSpan begins the span of an ACME order for domain, the parent of
+// the spans of the requests sent to the CA and of the challenges solved for it
+func (c *ACMEClient) startOrderSpan(ctx context.Context, domain, action string) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "acme.order",
+		tracing.String("domain", domain),
+		tracing.String("acme.action", action),
+		tracing.String("acme.ca", c.caDirURL))
+}
+
+// saveCertificateTraced saves an issued cert within a storage span of ctx's
+// trace. It is stored even when the operation was cancelled, as the order
+// can't be taken back.
+func (c *ACMEClient) saveCertificateTraced(ctx context.Context, cert *Certificate) error {
+	ctx, span := tracing.Start(ctx, "storage.save_certificate",
+		tracing.String("domain", cert.Domain),
+		tracing.Bool("storage.encrypted", c.encryption != nil))
+	defer span.End()
+
+	err := c.saveCertificate(context.WithoutCancel(ctx), cert)
+	span.RecordError(err)
+	return err
+}
+
+// startRunSpan begins the span of a scheduler run, the root of the traces of
+// the renewals it performs
+func startRunSpan(ctx context.Context, summary *RunSummary) (context.Context, *tracing.Span) {
+	return tracing.Start(ctx, "scheduler.run",
+		tracing.String("run.trigger", summary.Trigger),
+		tracing.Int("run.number", summary.Run))
+}
+
+// endRunSpan records the outcome of a finished run on its span and ends it
+func endRunSpan(span *tracing.Span, summary *RunSummary) {
+	span.SetAttributes(
+		tracing.String("run.status", summary.Status),
+		tracing.Int("run.checked", summary.Checked),
+		tracing.Int("run.renewed", summary.Renewed),
+		tracing.Int("run.failed", summary.Failed),
+		tracing.Int("run.skipped", summary.Skipped),
+		tracing.Int("run.deferred", summary.Deferred))
+	if summary.Error != "" {
+		span.RecordError(errors.New(summary.Error))
+	}
+	span.End()
+}
@@ -0,0 +1,160 @@
+package certmanager
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"gopkg.in/yaml.v2"
+)
+
+// traefikTLSFileName is a Traefik dynamic configuration listing every stored
+// certificate with the default certificate and TLS options
+const traefikTLSFileName = "tls.yml"
+
+// TraefikDynamicTLS is a Traefik dynamic configuration with only a tls section
+type TraefikDynamicTLS struct {
+	TLS TraefikTLSSection `yaml:"tls" json:"tls"`
+}
+
+// TraefikTLSSection lists certificates, certificate stores and TLS options
+type TraefikTLSSection struct {
+	Certificates []TraefikCertificate         `yaml:"certificates" json:"certificates"`
+	Stores       map[string]TraefikTLSStore   `yaml:"stores,omitempty" json:"stores,omitempty"`
+	Options      map[string]TraefikTLSOptions `yaml:"options,omitempty" json:"options,omitempty"`
+}
+
+// TraefikCertificate is a certificate and its key, as file paths or, for the
+// HTTP provider, PEM contents
+type TraefikCertificate struct {
+	CertFile string `yaml:"certFile" json:"certFile"`
+	KeyFile  string `yaml:"keyFile" json:"keyFile"`
+}
+
+// TraefikTLSStore is a certificate store
+type TraefikTLSStore struct {
+	DefaultCertificate *TraefikCertificate `yaml:"defaultCertificate,omitempty" json:"defaultCertificate,omitempty"`
+}
+
+// TraefikTLSOptions are named TLS options
+type TraefikTLSOptions struct {
+	MinVersion       string   `yaml:"minVersion,omitempty" json:"minVersion,omitempty"`
+	MaxVersion       string   `yaml:"maxVersion,omitempty" json:"maxVersion,omitempty"`
+	CipherSuites     []string `yaml:"cipherSuites,omitempty" json:"cipherSuites,omitempty"`
+	CurvePreferences []string `yaml:"curvePreferences,omitempty" json:"curvePreferences,omitempty"`
+	SNIStrict        bool     `yaml:"sniStrict,omitempty" json:"sniStrict,omitempty"`
+	ALPNProtocols    []string `yaml:"alpnProtocols,omitempty" json:"alpnProtocols,omitempty"`
+}
+
+// traefikTLS builds the dynamic configuration for certs, sorted by domain so
+// Traefik only reloads when a certificate changed. The default certificate is
+// left out until its domain has a certificate.
+func traefikTLS(cfg config.TraefikTLS, certs map[string]TraefikCertificate) TraefikDynamicTLS {
+	domains := make([]string, 0, len(certs))
+	for domain := range certs {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+
+	dynamic := TraefikDynamicTLS{TLS: TraefikTLSSection{Certificates: make([]TraefikCertificate, 0, len(domains))}}
+	for _, domain := range domains {
+		dynamic.TLS.Certificates = append(dynamic.TLS.Certificates, certs[domain])
+	}
+	if cert, ok := certs[cfg.DefaultCertificate]; ok {
+		dynamic.TLS.Stores = map[string]TraefikTLSStore{"default": {DefaultCertificate: &cert}}
+	}
+	if len(cfg.Options) > 0 {
+		dynamic.TLS.Options = make(map[string]TraefikTLSOptions, len(cfg.Options))
+		for name, options := range cfg.Options {
+			dynamic.TLS.Options[name] = TraefikTLSOptions{
+				MinVersion:       options.MinVersion,
+				MaxVersion:       options.MaxVersion,
+				CipherSuites:     options.CipherSuites,
+				CurvePreferences: options.CurvePreferences,
+				SNIStrict:        options.SNIStrict,
+				ALPNProtocols:    options.ALPNProtocols,
+			}
+		}
+	}
+	return dynamic
+}
+
+// TraefikProviderConfiguration returns the dynamic configuration served to
+// Traefik's HTTP provider, with the PEM contents of every managed certificate
+// and its key. Certificates whose key is kept outside the manager are left out.
+func (cm *CertificateManager) TraefikProviderConfiguration() TraefikDynamicTLS {
+	pairs := cm.KeyPairs()
+	certs := make(map[string]TraefikCertificate, len(pairs))
+	for domain, pair := range pairs {
+		certs[domain] = TraefikCertificate{CertFile: string(pair.Certificate), KeyFile: string(pair.PrivateKey)}
+	}
+
+	return traefikTLS(cm.config.TraefikTLS, certs)
+}
+
+// writeTraefikTLS publishes every certificate in the storage path with the
+// default certificate and TLS options to Traefik's file provider, and removes
+// a stale file otherwise. The file is only rewritten when it changes, so
+// Traefik doesn't reload for nothing.
+func (cm *CertificateManager) writeTraefikTLS() error {
+	storagePath, err := filepath.Abs(cm.config.Certificates.StoragePath)
+	if err != nil {
+		return fmt.Errorf("failed to resolve storage path: %w", err)
+	}
+	path := filepath.Join(storagePath, traefikTLSFileName)
+
+	if !cm.config.TraefikTLS.File {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale Traefik TLS configuration: %w", err)
+		}
+		return nil
+	}
+
+	// The storage path is listed rather than cm.certs, so certificates no
+	// longer managed but kept on disk stay served
+	entries, err := os.ReadDir(storagePath)
+	if err != nil {
+		return fmt.Errorf("failed to list certificates: %w", err)
+	}
+	certs := make(map[string]TraefikCertificate)
+	for _, entry := range entries {
+		domain, ok := domainFromCertFile(entry.Name())
+		if !ok {
+			continue
+		}
+		keyFile := filepath.Join(storagePath, storageName(domain)+".key")
+		if _, err := os.Stat(keyFile); err != nil {
+			continue
+		}
+		certs[domain] = TraefikCertificate{CertFile: filepath.Join(storagePath, entry.Name()), KeyFile: keyFile}
+	}
+	if domain := cm.config.TraefikTLS.DefaultCertificate; domain != "" {
+		if _, ok := certs[domain]; !ok {
+			cm.logger.Printf("Warning: no certificate for %s yet, Traefik keeps its own default certificate", domain)
+		}
+	}
+
+	data, err := yaml.Marshal(traefikTLS(cm.config.TraefikTLS, certs))
+	if err != nil {
+		return fmt.Errorf("failed to encode Traefik TLS configuration: %w", err)
+	}
+	if current, err := os.ReadFile(path); err == nil && bytes.Equal(current, data) {
+		return nil
+	}
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write Traefik TLS configuration: %w", err)
+	}
+	return os.Rename(tmp, path)
+}
+
+// publishTraefikTLS rewrites the Traefik TLS configuration after the stored
+// certificates changed, reporting failures
+func (cm *CertificateManager) publishTraefikTLS() {
+	if err := cm.writeTraefikTLS(); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+}
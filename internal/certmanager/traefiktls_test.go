@@ -0,0 +1,85 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"gopkg.in/yaml.v2"
+)
+
+func TestCertificateManager_WriteTraefikTLS(t *testing.T) {
+	tempDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = tempDir
+	cfg.TraefikTLS = config.TraefikTLS{
+		File:               true,
+		DefaultCertificate: "example.com",
+		Options: map[string]config.TLSOptions{
+			"default": {MinVersion: "VersionTLS12", CipherSuites: []string{"TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256"}},
+			"modern":  {MinVersion: "VersionTLS13", SNIStrict: true},
+		},
+	}
+	cm := &CertificateManager{config: cfg, logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags)}
+
+	for _, name := range []string{"example.com.crt", "example.com.key", "example.com.issuer.crt", "_.example.org.crt", "_.example.org.key",
+		"external.example.com.crt"} {
+		require.NoError(t, os.WriteFile(filepath.Join(tempDir, name), []byte("pem"), 0600))
+	}
+	require.NoError(t, cm.writeTraefikTLS())
+
+	path := filepath.Join(tempDir, traefikTLSFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	var dynamic TraefikDynamicTLS
+	require.NoError(t, yaml.Unmarshal(data, &dynamic))
+
+	// Certificates without a stored key are left out, and the order is stable
+	assert.Equal(t, []TraefikCertificate{
+		{CertFile: filepath.Join(tempDir, "_.example.org.crt"), KeyFile: filepath.Join(tempDir, "_.example.org.key")},
+		{CertFile: filepath.Join(tempDir, "example.com.crt"), KeyFile: filepath.Join(tempDir, "example.com.key")},
+	}, dynamic.TLS.Certificates)
+	require.NotNil(t, dynamic.TLS.Stores["default"].DefaultCertificate)
+	assert.Equal(t, filepath.Join(tempDir, "example.com.crt"), dynamic.TLS.Stores["default"].DefaultCertificate.CertFile)
+	assert.Equal(t, "VersionTLS12", dynamic.TLS.Options["default"].MinVersion)
+	assert.True(t, dynamic.TLS.Options["modern"].SNIStrict)
+
+	// An unchanged configuration isn't rewritten, so Traefik doesn't reload
+	old := time.Now().Add(-time.Hour)
+	require.NoError(t, os.Chtimes(path, old, old))
+	require.NoError(t, cm.writeTraefikTLS())
+	info, err := os.Stat(path)
+	require.NoError(t, err)
+	assert.True(t, info.ModTime().Equal(old))
+
+	// Turning the file off removes it
+	cfg.TraefikTLS.File = false
+	require.NoError(t, cm.writeTraefikTLS())
+	_, err = os.Stat(path)
+	assert.True(t, os.IsNotExist(err))
+}
+
+func TestCertificateManager_TraefikProviderConfiguration(t *testing.T) {
+	cfg := createTestConfig()
+	cfg.TraefikTLS.DefaultCertificate = "example.com"
+	external := createTestCertificate("external.example.com", 60)
+	external.PrivateKey = nil
+	cm := &CertificateManager{
+		config: cfg,
+		certs: map[string]*Certificate{
+			"example.com":          createTestCertificate("example.com", 60),
+			"external.example.com": external,
+		},
+	}
+
+	dynamic := cm.TraefikProviderConfiguration()
+	require.Len(t, dynamic.TLS.Certificates, 1)
+	assert.Equal(t, string(cm.certs["example.com"].PrivateKey), dynamic.TLS.Certificates[0].KeyFile)
+	assert.Equal(t, dynamic.TLS.Certificates[0], *dynamic.TLS.Stores["default"].DefaultCertificate)
+	assert.Nil(t, dynamic.TLS.Options)
+}
@@ -0,0 +1,230 @@
+package certmanager
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+const usageFileName = ".usage.json"
+
+// usageRetention is how many days of per-domain usage are kept
+const usageRetention = 90
+
+// usageDayFormat keys the daily usage buckets
+const usageDayFormat = "2006-01-02"
+
+// UsageCounts is what a domain consumed: ACME orders placed, orders that
+// failed, DNS queries made by validation pre-checks and notifications sent
+type UsageCounts struct {
+	Orders        int `json:"orders" yaml:"orders"`
+	FailedOrders  int `json:"failed_orders" yaml:"failed_orders"`
+	DNSQueries    int `json:"dns_queries" yaml:"dns_queries"`
+	Notifications int `json:"notifications" yaml:"notifications"`
+}
+
+func (u *UsageCounts) add(other UsageCounts) {
+	u.Orders += other.Orders
+	u.FailedOrders += other.FailedOrders
+	u.DNSQueries += other.DNSQueries
+	u.Notifications += other.Notifications
+}
+
+// Total is the sum of all counts
+func (u UsageCounts) Total() int {
+	return u.Orders + u.FailedOrders + u.DNSQueries + u.Notifications
+}
+
+// DomainUsage is a domain's usage over a period
+type DomainUsage struct {
+	Domain      string `json:"domain" yaml:"domain"`
+	UsageCounts `yaml:",inline"`
+}
+
+// UsageLedger accounts orders, DNS queries and notifications to the domains
+// that caused them, in daily buckets, so noisy domains can be found
+type UsageLedger struct {
+	path    string
+	metrics *Metrics
+	logger  *log.Logger
+	now     func() time.Time
+	mu      sync.Mutex
+	loaded  bool
+	days    map[string]map[string]*UsageCounts // day -> domain -> counts
+}
+
+func NewUsageLedger(storagePath string, metrics *Metrics, logger *log.Logger) *UsageLedger {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Usage] ", log.LstdFlags)
+	}
+
+	return &UsageLedger{
+		path:    filepath.Join(storagePath, usageFileName),
+		metrics: metrics,
+		logger:  logger,
+		now:     time.Now,
+		days:    make(map[string]map[string]*UsageCounts),
+	}
+}
+
+// Add accounts counts to domain for today and persists the ledger
+func (l *UsageLedger) Add(domain string, counts UsageCounts) error {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.load()
+
+	for kind, n := range map[string]int{
+		"orders":        counts.Orders,
+		"failed_orders": counts.FailedOrders,
+		"dns_queries":   counts.DNSQueries,
+		"notifications": counts.Notifications,
+	} {
+		if n > 0 {
+			l.metrics.addUsage(domain, kind, n)
+		}
+	}
+
+	day := l.now().UTC().Format(usageDayFormat)
+	if l.days[day] == nil {
+		l.days[day] = make(map[string]*UsageCounts)
+	}
+	if l.days[day][domain] == nil {
+		l.days[day][domain] = &UsageCounts{}
+	}
+	l.days[day][domain].add(counts)
+
+	l.prune()
+	return l.save()
+}
+
+// Since returns each domain's usage from the day of since onwards, busiest first
+func (l *UsageLedger) Since(since time.Time) []DomainUsage {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.load()
+
+	first := since.UTC().Format(usageDayFormat)
+	totals := make(map[string]*UsageCounts)
+	for day, domains := range l.days {
+		if day < first {
+			continue
+		}
+		for domain, counts := range domains {
+			if totals[domain] == nil {
+				totals[domain] = &UsageCounts{}
+			}
+			totals[domain].add(*counts)
+		}
+	}
+
+	usage := make([]DomainUsage, 0, len(totals))
+	for domain, counts := range totals {
+		usage = append(usage, DomainUsage{Domain: domain, UsageCounts: *counts})
+	}
+	sort.Slice(usage, func(i, j int) bool {
+		if usage[i].Total() != usage[j].Total() {
+			return usage[i].Total() > usage[j].Total()
+		}
+		return usage[i].Domain < usage[j].Domain
+	})
+	return usage
+}
+
+// prune drops days older than the retention period
+func (l *UsageLedger) prune() {
+	cutoff := l.now().UTC().AddDate(0, 0, -usageRetention).Format(usageDayFormat)
+	for day := range l.days {
+		if day < cutoff {
+			delete(l.days, day)
+		}
+	}
+}
+
+func (l *UsageLedger) load() {
+	if l.loaded {
+		return
+	}
+	l.loaded = true
+
+	data, err := os.ReadFile(l.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			l.logger.Printf("Warning: failed to read usage ledger: %v", err)
+		}
+		return
+	}
+
+	if err := json.Unmarshal(data, &l.days); err != nil {
+		l.logger.Printf("Warning: ignoring corrupt usage ledger %s: %v", l.path, err)
+		l.days = make(map[string]map[string]*UsageCounts)
+	}
+}
+
+func (l *UsageLedger) save() error {
+	data, err := json.MarshalIndent(l.days, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode usage ledger: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(l.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmp := l.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write usage ledger: %w", err)
+	}
+	return os.Rename(tmp, l.path)
+}
+
+// usageNotifier accounts every notification about a domain to that domain
+type usageNotifier struct {
+	notify.Notifier
+	usage *UsageLedger
+}
+
+func (n *usageNotifier) Send(msg notify.Message) error {
+	err := n.Notifier.Send(msg)
+	if err == nil && msg.Domain != "" {
+		if addErr := n.usage.Add(msg.Domain, UsageCounts{Notifications: 1}); addErr != nil {
+			n.usage.logger.Printf("Warning: %v", addErr)
+		}
+	}
+	return err
+}
+
+// recordUsage accounts counts to domain
+func (cm *CertificateManager) recordUsage(domain string, counts UsageCounts) {
+	if cm.usage == nil {
+		return
+	}
+	if err := cm.usage.Add(domain, counts); err != nil {
+		cm.logger.Printf("Warning: failed to record usage for %s: %v", domain, err)
+	}
+}
+
+// recordOrder accounts an ACME order for domain and whether it failed
+func (cm *CertificateManager) recordOrder(domain string, err error) {
+	counts := UsageCounts{Orders: 1}
+	if err != nil {
+		counts.FailedOrders = 1
+	}
+	cm.recordUsage(domain, counts)
+}
+
+// Usage returns each domain's usage since the given time, busiest first
+func (cm *CertificateManager) Usage(since time.Time) []DomainUsage {
+	if cm.usage == nil {
+		return []DomainUsage{}
+	}
+	return cm.usage.Since(since)
+}
@@ -0,0 +1,89 @@
+package certmanager
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type sentMessages struct {
+	messages []notify.Message
+}
+
+func (s *sentMessages) Send(msg notify.Message) error {
+	s.messages = append(s.messages, msg)
+	return nil
+}
+
+func TestUsageLedger(t *testing.T) {
+	testDir := setupTestDir(t)
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	now := time.Date(2030, 3, 10, 12, 0, 0, 0, time.UTC)
+	ledger := NewUsageLedger(testDir, nil, logger)
+	ledger.now = func() time.Time { return now }
+
+	require.NoError(t, ledger.Add("quiet.example.com", UsageCounts{Orders: 1}))
+	require.NoError(t, ledger.Add("noisy.example.com", UsageCounts{Orders: 1, FailedOrders: 1}))
+	now = now.AddDate(0, 0, 1)
+	require.NoError(t, ledger.Add("noisy.example.com", UsageCounts{Orders: 1, FailedOrders: 1, DNSQueries: 1}))
+
+	usage := ledger.Since(now.AddDate(0, 0, -7))
+	require.Len(t, usage, 2)
+	assert.Equal(t, DomainUsage{Domain: "noisy.example.com", UsageCounts: UsageCounts{Orders: 2, FailedOrders: 2, DNSQueries: 1}}, usage[0])
+	assert.Equal(t, "quiet.example.com", usage[1].Domain)
+
+	// Only today
+	usage = ledger.Since(now)
+	require.Len(t, usage, 1)
+	assert.Equal(t, 1, usage[0].Orders)
+
+	// Persisted across restarts
+	reloaded := NewUsageLedger(testDir, nil, logger)
+	reloaded.now = ledger.now
+	assert.Equal(t, ledger.Since(now.AddDate(0, 0, -7)), reloaded.Since(now.AddDate(0, 0, -7)))
+
+	// Old days are dropped
+	now = now.AddDate(0, 0, usageRetention+1)
+	require.NoError(t, reloaded.Add("new.example.com", UsageCounts{Notifications: 1}))
+	usage = reloaded.Since(time.Time{})
+	require.Len(t, usage, 1)
+	assert.Equal(t, "new.example.com", usage[0].Domain)
+}
+
+func TestCertificateManager_RecordsOrderUsage(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+
+	mockACME := &MockACMEClient{}
+	mockACME.On("RequestCertificate", "example.com").Return(nil, errors.New("urn:ietf:params:acme:error:unauthorized"))
+
+	usage := NewUsageLedger(testDir, nil, logger)
+	sent := &sentMessages{}
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: mockACME,
+		usage:      usage,
+		notifier:   &usageNotifier{Notifier: sent, usage: usage},
+		logger:     logger,
+		certs:      make(map[string]*Certificate),
+	}
+
+	// The failed order is reported, which counts as a notification
+	assert.Error(t, cm.RequestCertificate(context.Background(), "example.com"))
+	require.NoError(t, cm.notifier.Send(notify.Message{Domain: "example.com", Subject: "test"}))
+	require.NoError(t, cm.notifier.Send(notify.Message{Subject: "storage low"}))
+
+	assert.Equal(t, []DomainUsage{{Domain: "example.com", UsageCounts: UsageCounts{Orders: 1, FailedOrders: 1, Notifications: 2}}},
+		cm.Usage(time.Now().Add(-time.Hour)))
+	assert.Len(t, sent.messages, 3)
+}
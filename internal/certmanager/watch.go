@@ -0,0 +1,189 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/hooks"
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchSettle is how long a certificate's files must stay unchanged before
+// they are reloaded, so a .crt and .key copied one after the other are read
+// as a pair
+const watchSettle = 2 * time.Second
+
+// outputWriter rewrites the files derived from a stored certificate
+type outputWriter interface {
+	WriteOutputs(cert *Certificate) error
+}
+
+// WatchStorage reloads certificates whose files are replaced in the storage
+// path by hand until ctx is done. Without it, the stale certificate stays in
+// memory, in health reports and on remote targets until a restart.
+func (cm *CertificateManager) WatchStorage(ctx context.Context) error {
+	return cm.watchStorage(ctx, watchSettle)
+}
+
+func (cm *CertificateManager) watchStorage(ctx context.Context, settle time.Duration) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("failed to create storage watcher: %w", err)
+	}
+	defer watcher.Close()
+
+	storagePath := cm.config.Certificates.StoragePath
+	if err := watcher.Add(storagePath); err != nil {
+		return fmt.Errorf("failed to watch %s: %w", storagePath, err)
+	}
+	cm.logger.Printf("Watching %s for certificates replaced by hand", storagePath)
+
+	ticker := time.NewTicker(settle / 4)
+	defer ticker.Stop()
+
+	changed := make(map[string]time.Time) // domain -> last change
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case event, ok := <-watcher.Events:
+			if !ok {
+				return nil
+			}
+			if domain, ok := domainFromPairFile(filepath.Base(event.Name)); ok && !event.Has(fsnotify.Chmod) {
+				changed[domain] = time.Now()
+			}
+		case err, ok := <-watcher.Errors:
+			if !ok {
+				return nil
+			}
+			cm.logger.Printf("Warning: storage watcher: %v", err)
+		case <-ticker.C:
+			for domain, at := range changed {
+				if time.Since(at) < settle {
+					continue
+				}
+				delete(changed, domain)
+				cm.reloadCertificate(ctx, domain)
+			}
+		}
+	}
+}
+
+// domainFromPairFile returns the domain of a leaf certificate or private key file
+func domainFromPairFile(fileName string) (string, bool) {
+	if name, ok := strings.CutSuffix(fileName, ".key"); ok && name != "" && !strings.HasPrefix(name, ".") {
+		return domainFromStorageName(name), true
+	}
+	if strings.HasPrefix(fileName, ".") {
+		return "", false
+	}
+	return domainFromCertFile(fileName)
+}
+
+// reloadCertificate replaces the in-memory certificate of domain with the one
+// on disk if it changed and is a matching pair, then treats it like a renewal: derived files are
+// rewritten and it is deployed. A certificate whose files were removed is
+// forgotten, like after a restart.
+func (cm *CertificateManager) reloadCertificate(ctx context.Context, domain string) {
+	cm.mu.RLock()
+	_, ordering := cm.ordering[domain]
+	current, managed := cm.certs[domain]
+	if !managed {
+		current = cm.unmanaged[domain]
+	}
+	cm.mu.RUnlock()
+
+	// An order in flight writes these files itself and updates the certificate after
+	if ordering {
+		return
+	}
+
+	certPath, _ := cm.GetCertificatePaths(domain)
+	if _, err := os.Stat(certPath); os.IsNotExist(err) {
+		if current == nil {
+			return
+		}
+		cm.mu.Lock()
+		delete(cm.certs, domain)
+		delete(cm.unmanaged, domain)
+		cm.mu.Unlock()
+		cm.logger.Printf("Certificate for %s was removed from storage, forgetting it", domain)
+		cm.publishTraefikTLS()
+		return
+	}
+
+	// Not restoring a backup here: a corrupt pair may be a copy still in progress
+	cert, err := cm.acmeClient.LoadCertificate(ctx, domain)
+	if err != nil {
+		cm.logger.Printf("Warning: ignoring changed certificate files of %s, keeping the previous certificate: %v", domain, err)
+		return
+	}
+
+	if current != nil && bytes.Equal(cert.Certificate, current.Certificate) && bytes.Equal(cert.PrivateKey, current.PrivateKey) {
+		return
+	}
+
+	cm.mu.Lock()
+	if _, ok := cm.ordering[domain]; ok {
+		cm.mu.Unlock()
+		return
+	}
+	_, managed = cm.certs[domain]
+	if managed || cm.isManaged(domain) {
+		cm.certs[domain] = cert
+		delete(cm.unmanaged, domain)
+		managed = true
+	} else {
+		cm.keepUnmanaged(domain, cert)
+	}
+	cm.mu.Unlock()
+
+	cm.logger.Printf("Reloaded certificate for %s replaced in storage (expires: %s)", domain, cert.ExpiresAt.Format(time.RFC3339))
+
+	if writer, ok := cm.acmeClient.(outputWriter); ok {
+		if err := writer.WriteOutputs(cert); err != nil {
+			cm.logger.Printf("Warning: failed to rewrite outputs of %s: %v", domain, err)
+		}
+	}
+	if !managed {
+		return
+	}
+	if err := cm.checkMaintenance(); err != nil {
+		cm.logger.Printf("Not deploying the reloaded certificate for %s: %v", domain, err)
+		return
+	}
+	if err := cm.writeDualKeyConfig(); err != nil {
+		cm.logger.Printf("Warning: %v", err)
+	}
+	cm.deployCertificate(ctx, domain, cert)
+	cm.runHooks(ctx, hooks.EventPostRenew, domain, cert, nil)
+}
+
+// RefreshCertificates rereads the certificates of managed domains from
+// storage without acting on changes, for views of certificates a daemon in
+// another process renews. Certificates that can't be read are kept as they are.
+func (cm *CertificateManager) RefreshCertificates(ctx context.Context) {
+	for _, domain := range cm.GetManagedDomains() {
+		certPath, _ := cm.GetCertificatePaths(domain)
+		if _, err := os.Stat(certPath); os.IsNotExist(err) {
+			cm.mu.Lock()
+			delete(cm.certs, domain)
+			cm.mu.Unlock()
+			continue
+		}
+
+		cert, err := cm.acmeClient.LoadCertificate(ctx, domain)
+		if err != nil {
+			continue
+		}
+		cm.mu.Lock()
+		cm.certs[domain] = cert
+		cm.mu.Unlock()
+	}
+}
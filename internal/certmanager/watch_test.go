@@ -0,0 +1,130 @@
+package certmanager
+
+import (
+	"bytes"
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDomainFromPairFile(t *testing.T) {
+	for name, want := range map[string]string{
+		"example.com.crt":           "example.com",
+		"example.com.key":           "example.com",
+		"_.example.com.key":         "*.example.com",
+		"example.com.issuer.crt":    "",
+		"example.com.fullchain.pem": "",
+		".example.com.key.tmp":      "",
+		".storage-version":          "",
+	} {
+		domain, ok := domainFromPairFile(name)
+		assert.Equal(t, want, domain, name)
+		assert.Equal(t, want != "", ok, name)
+	}
+}
+
+func TestCertificateManager_WatchStorageReloadsReplacedCertificates(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	client := &ACMEClient{storagePath: testDir, logger: logger}
+	original := createTestCertificate("example.com", 10)
+	require.NoError(t, client.saveCertificate(context.Background(), original))
+
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: client,
+		logger:     logger,
+		certs:      map[string]*Certificate{"example.com": original},
+		unmanaged:  make(map[string]*Certificate),
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	done := make(chan error, 1)
+	go func() { done <- cm.watchStorage(ctx, 100*time.Millisecond) }()
+	defer func() {
+		cancel()
+		require.NoError(t, <-done)
+	}()
+
+	certPath, keyPath := cm.GetCertificatePaths("example.com")
+	replace := func(cert, key []byte) {
+		require.NoError(t, os.WriteFile(certPath, cert, 0644))
+		require.NoError(t, os.WriteFile(keyPath, key, 0600))
+	}
+	current := func() *Certificate {
+		cert, err := cm.GetCertificate("example.com")
+		if err != nil {
+			return nil
+		}
+		return cert
+	}
+
+	// An operator replaces the pair; writing again until it is picked up also
+	// covers writes made before the watcher started
+	replacement := createTestCertificate("example.com", 90)
+	require.Eventually(t, func() bool {
+		replace(replacement.Certificate, replacement.PrivateKey)
+		return bytes.Equal(current().Certificate, replacement.Certificate)
+	}, 5*time.Second, 300*time.Millisecond)
+
+	chain, err := os.ReadFile(filepath.Join(testDir, "example.com"+fullChainSuffix))
+	require.NoError(t, err)
+	assert.Equal(t, replacement.Certificate, chain)
+
+	// A certificate with someone else's key is not taken
+	mismatched := createTestCertificate("example.com", 60)
+	replace(mismatched.Certificate, replacement.PrivateKey)
+	assert.Never(t, func() bool {
+		return !bytes.Equal(current().Certificate, replacement.Certificate)
+	}, time.Second, 50*time.Millisecond)
+
+	// Removed files are forgotten, as after a restart
+	require.NoError(t, os.Remove(certPath))
+	require.NoError(t, os.Remove(keyPath))
+	assert.Eventually(t, func() bool { return current() == nil }, 5*time.Second, 50*time.Millisecond)
+}
+
+func TestCertificateManager_RefreshCertificates(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	client := &ACMEClient{storagePath: testDir, logger: logger}
+	original := createTestCertificate("example.com", 10)
+	cm := &CertificateManager{
+		config:     cfg,
+		acmeClient: client,
+		logger:     logger,
+		certs:      map[string]*Certificate{"example.com": original},
+	}
+
+	// Another process renewed one certificate and issued the other
+	renewed := createTestCertificate("example.com", 90)
+	issued := createTestCertificate("api.example.com", 90)
+	require.NoError(t, client.saveCertificate(context.Background(), renewed))
+	require.NoError(t, client.saveCertificate(context.Background(), issued))
+
+	cm.RefreshCertificates(context.Background())
+	cert, err := cm.GetCertificate("example.com")
+	require.NoError(t, err)
+	assert.Equal(t, renewed.Certificate, cert.Certificate)
+	cert, err = cm.GetCertificate("api.example.com")
+	require.NoError(t, err)
+	assert.Equal(t, issued.Certificate, cert.Certificate)
+
+	certPath, _ := cm.GetCertificatePaths("api.example.com")
+	require.NoError(t, os.Remove(certPath))
+	cm.RefreshCertificates(context.Background())
+	_, err = cm.GetCertificate("api.example.com")
+	assert.Error(t, err)
+}
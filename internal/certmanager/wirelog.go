@@ -0,0 +1,293 @@
+package certmanager
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/logfile"
+)
+
+const (
+	// wireDebugFileName lists the domains whose ACME exchanges are logged,
+	// switched on at runtime
+	wireDebugFileName = ".acme-debug.json"
+	// maxWireLogBody bounds how much of a response body is logged
+	maxWireLogBody = 8 << 10
+)
+
+// allDomains enables wire logging for every domain
+const allDomains = "*"
+
+// WireDebug selects the domains whose ACME HTTP exchanges are written to the
+// wire log. Domains listed in the configuration are always logged; others are
+// switched on and off at runtime through a file in the storage path, which the
+// CLI and the management API of a running daemon share.
+type WireDebug struct {
+	path       string
+	fromConfig []string
+}
+
+func NewWireDebug(storagePath string, fromConfig []string) *WireDebug {
+	return &WireDebug{
+		path:       filepath.Join(storagePath, wireDebugFileName),
+		fromConfig: fromConfig,
+	}
+}
+
+// Domains returns the domains logged, "*" standing for all
+func (w *WireDebug) Domains() []string {
+	domains := append([]string{}, w.fromConfig...)
+	for _, domain := range w.runtime() {
+		if !slices.Contains(domains, domain) {
+			domains = append(domains, domain)
+		}
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+// Enabled reports whether the exchanges of domain are logged
+func (w *WireDebug) Enabled(domain string) bool {
+	domains := w.Domains()
+	return slices.Contains(domains, domain) || slices.Contains(domains, allDomains)
+}
+
+// Enable logs the exchanges of domain, or of all domains for "*"
+func (w *WireDebug) Enable(domain string) error {
+	domains := w.runtime()
+	if slices.Contains(domains, domain) {
+		return nil
+	}
+	return w.save(append(domains, domain))
+}
+
+// Disable stops logging domain at runtime. It can't override the configuration.
+func (w *WireDebug) Disable(domain string) error {
+	if slices.Contains(w.fromConfig, domain) {
+		return fmt.Errorf("ACME wire logging of %s is enabled in the configuration (acme.wire_log.domains)", domain)
+	}
+
+	var remaining []string
+	for _, d := range w.runtime() {
+		if d != domain {
+			remaining = append(remaining, d)
+		}
+	}
+	if len(remaining) == 0 {
+		if err := os.Remove(w.path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to clear ACME debug state: %w", err)
+		}
+		return nil
+	}
+	return w.save(remaining)
+}
+
+func (w *WireDebug) runtime() []string {
+	data, err := os.ReadFile(w.path)
+	if err != nil {
+		return nil
+	}
+	var state struct {
+		Domains []string `json:"domains"`
+	}
+	json.Unmarshal(data, &state)
+	return state.Domains
+}
+
+func (w *WireDebug) save(domains []string) error {
+	sort.Strings(domains)
+	data, err := json.MarshalIndent(map[string][]string{"domains": domains}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode ACME debug state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+	tmp := w.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write ACME debug state: %w", err)
+	}
+	return os.Rename(tmp, w.path)
+}
+
+// wireLogger writes the HTTP exchanges with the CA to a rotating file while an
+// operation for a debugged domain is running. Exchanges aren't tied to a domain
+// at the HTTP level, so those of operations running at the same time for other
+// domains are logged too, labelled with the debugged ones. JWS payloads and
+// signatures are redacted; the protected header is logged decoded.
+type wireLogger struct {
+	debug *WireDebug
+	out   *logfile.Writer
+
+	mu       sync.Mutex
+	inflight map[string]int // debugged domains with operations running
+}
+
+func newWireLogger(debug *WireDebug, path string, maxSize int64, backups int) *wireLogger {
+	return &wireLogger{
+		debug:    debug,
+		out:      logfile.New(path, logfile.Options{MaxSize: maxSize, MaxBackups: backups}),
+		inflight: make(map[string]int),
+	}
+}
+
+// wrap returns a transport logging the exchanges of next
+func (l *wireLogger) wrap(next http.RoundTripper) http.RoundTripper {
+	if l == nil {
+		return next
+	}
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &wireTransport{next: next, logger: l}
+}
+
+// track marks an operation for domain as running until the returned function is called
+func (l *wireLogger) track(domain string) func() {
+	if l == nil || !l.debug.Enabled(domain) {
+		return func() {}
+	}
+
+	l.mu.Lock()
+	l.inflight[domain]++
+	l.mu.Unlock()
+
+	return func() {
+		l.mu.Lock()
+		defer l.mu.Unlock()
+		if l.inflight[domain]--; l.inflight[domain] <= 0 {
+			delete(l.inflight, domain)
+		}
+	}
+}
+
+// active returns the debugged domains with operations running
+func (l *wireLogger) active() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	domains := make([]string, 0, len(l.inflight))
+	for domain := range l.inflight {
+		domains = append(domains, domain)
+	}
+	sort.Strings(domains)
+	return domains
+}
+
+type wireTransport struct {
+	next   http.RoundTripper
+	logger *wireLogger
+}
+
+func (t *wireTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	domains := t.logger.active()
+	if len(domains) == 0 {
+		return t.next.RoundTrip(req)
+	}
+
+	var reqBody []byte
+	if req.Body != nil {
+		reqBody, _ = io.ReadAll(req.Body)
+		req.Body.Close()
+		req.Body = io.NopCloser(bytes.NewReader(reqBody))
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "=== %s [%s]\n", time.Now().UTC().Format(time.RFC3339Nano), strings.Join(domains, ", "))
+	fmt.Fprintf(&b, "> %s %s\n", req.Method, req.URL)
+	if len(reqBody) > 0 {
+		fmt.Fprintf(&b, "> %s\n", redactJWS(reqBody))
+	}
+
+	start := time.Now()
+	resp, err := t.next.RoundTrip(req)
+	if err != nil {
+		fmt.Fprintf(&b, "< error after %s: %v\n", time.Since(start).Round(time.Millisecond), err)
+		t.logger.out.Write([]byte(b.String()))
+		return resp, err
+	}
+
+	respBody, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	resp.Body = io.NopCloser(bytes.NewReader(respBody))
+
+	fmt.Fprintf(&b, "< %s in %s\n", resp.Status, time.Since(start).Round(time.Millisecond))
+	for _, name := range []string{"Content-Type", "Location", "Link", "Retry-After", "Replay-Nonce"} {
+		for _, value := range resp.Header.Values(name) {
+			fmt.Fprintf(&b, "< %s: %s\n", name, value)
+		}
+	}
+	if len(respBody) > 0 {
+		if len(respBody) > maxWireLogBody {
+			fmt.Fprintf(&b, "< %s\n< ... %d more bytes\n", respBody[:maxWireLogBody], len(respBody)-maxWireLogBody)
+		} else {
+			fmt.Fprintf(&b, "< %s\n", bytes.TrimSpace(respBody))
+		}
+	}
+	t.logger.out.Write([]byte(b.String()))
+	return resp, nil
+}
+
+// redactJWS replaces the payload and signature of a JWS request body and
+// decodes its protected header. Bodies that aren't JWS are left out entirely.
+func redactJWS(body []byte) string {
+	var jws struct {
+		Protected string `json:"protected"`
+		Payload   string `json:"payload"`
+		Signature string `json:"signature"`
+	}
+	if err := json.Unmarshal(body, &jws); err != nil || jws.Protected == "" {
+		return fmt.Sprintf("<%d bytes redacted>", len(body))
+	}
+
+	protected, err := base64.RawURLEncoding.DecodeString(jws.Protected)
+	if err != nil {
+		protected = []byte(jws.Protected)
+	}
+	payload := "POST-as-GET"
+	if jws.Payload != "" {
+		payload = fmt.Sprintf("<%d bytes redacted>", len(jws.Payload))
+	}
+	return fmt.Sprintf("protected=%s payload=%s signature=<redacted>", protected, payload)
+}
+
+// WireDebugDomains returns the domains whose ACME exchanges are logged
+func (cm *CertificateManager) WireDebugDomains() []string {
+	if cm.wireDebug == nil {
+		return []string{}
+	}
+	return cm.wireDebug.Domains()
+}
+
+// SetWireDebug switches ACME wire logging of a domain on or off
+func (cm *CertificateManager) SetWireDebug(domain string, enabled bool) error {
+	if cm.wireDebug == nil {
+		return fmt.Errorf("ACME wire logging is not available")
+	}
+
+	if !enabled {
+		if err := cm.wireDebug.Disable(domain); err != nil {
+			return err
+		}
+		cm.logger.Printf("ACME wire logging disabled for %s", domain)
+		return nil
+	}
+
+	if err := cm.wireDebug.Enable(domain); err != nil {
+		return err
+	}
+	cm.logger.Printf("ACME wire logging enabled for %s", domain)
+	return nil
+}
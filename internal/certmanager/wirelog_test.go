@@ -0,0 +1,87 @@
+package certmanager
+
+import (
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestWireDebug_EnableDisable(t *testing.T) {
+	testDir := setupTestDir(t)
+	debug := NewWireDebug(testDir, []string{"configured.example.com"})
+
+	assert.True(t, debug.Enabled("configured.example.com"))
+	assert.False(t, debug.Enabled("example.com"))
+
+	require.NoError(t, debug.Enable("example.com"))
+	assert.True(t, NewWireDebug(testDir, nil).Enabled("example.com"), "runtime state should be shared through the storage path")
+	assert.Equal(t, []string{"configured.example.com", "example.com"}, debug.Domains())
+
+	assert.Error(t, debug.Disable("configured.example.com"))
+	require.NoError(t, debug.Disable("example.com"))
+	assert.False(t, debug.Enabled("example.com"))
+	_, err := os.Stat(filepath.Join(testDir, wireDebugFileName))
+	assert.True(t, os.IsNotExist(err))
+
+	require.NoError(t, debug.Enable(allDomains))
+	assert.True(t, debug.Enabled("other.example.com"))
+}
+
+func TestRedactJWS(t *testing.T) {
+	protected := base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"ES256","nonce":"abc"}`))
+	body := `{"protected":"` + protected + `","payload":"c2VjcmV0","signature":"c2ln"}`
+
+	redacted := redactJWS([]byte(body))
+	assert.Contains(t, redacted, `"nonce":"abc"`)
+	assert.NotContains(t, redacted, "c2VjcmV0")
+	assert.NotContains(t, redacted, "c2ln")
+
+	assert.Contains(t, redactJWS([]byte(`{"protected":"`+protected+`","payload":"","signature":"c2ln"}`)), "POST-as-GET")
+	assert.Equal(t, "<7 bytes redacted>", redactJWS([]byte("key=val")))
+}
+
+func TestWireLogger_LogsTrackedDomainsOnly(t *testing.T) {
+	testDir := setupTestDir(t)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Replay-Nonce", "nonce-1")
+		io.WriteString(w, `{"status":"valid"}`)
+	}))
+	defer server.Close()
+
+	debug := NewWireDebug(testDir, []string{"debug.example.com"})
+	logPath := filepath.Join(testDir, "wire.log")
+	logger := newWireLogger(debug, logPath, 1<<20, 1)
+	client := &http.Client{Transport: logger.wrap(http.DefaultTransport)}
+
+	get := func() {
+		resp, err := client.Get(server.URL + "/acme/order/1")
+		require.NoError(t, err)
+		body, _ := io.ReadAll(resp.Body)
+		resp.Body.Close()
+		assert.Equal(t, `{"status":"valid"}`, string(body), "the response body must still reach the caller")
+	}
+
+	done := logger.track("quiet.example.com")
+	get()
+	done()
+	_, err := os.Stat(logPath)
+	assert.True(t, os.IsNotExist(err), "exchanges of other domains should not be logged")
+
+	done = logger.track("debug.example.com")
+	get()
+	done()
+	logged, err := os.ReadFile(logPath)
+	require.NoError(t, err)
+	assert.Contains(t, string(logged), "[debug.example.com]")
+	assert.Contains(t, string(logged), "> GET "+server.URL+"/acme/order/1")
+	assert.Contains(t, string(logged), "< Replay-Nonce: nonce-1")
+	assert.Contains(t, string(logged), `< {"status":"valid"}`)
+	assert.Empty(t, logger.active())
+}
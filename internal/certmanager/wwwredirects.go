@@ -0,0 +1,96 @@
+package certmanager
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"gopkg.in/yaml.v2"
+)
+
+// wwwRedirectsFileName is a Traefik dynamic configuration with a redirect
+// router for every name paired with pair_www
+const wwwRedirectsFileName = "www-redirects.yml"
+
+type traefikDynamicConfig struct {
+	HTTP traefikHTTPConfig `yaml:"http"`
+}
+
+type traefikHTTPConfig struct {
+	Routers     map[string]traefikRouter     `yaml:"routers"`
+	Middlewares map[string]traefikMiddleware `yaml:"middlewares"`
+}
+
+type traefikRouter struct {
+	Rule        string   `yaml:"rule"`
+	Middlewares []string `yaml:"middlewares"`
+	Service     string   `yaml:"service"`
+	TLS         struct{} `yaml:"tls"`
+}
+
+type traefikMiddleware struct {
+	RedirectRegex traefikRedirectRegex `yaml:"redirectRegex"`
+}
+
+type traefikRedirectRegex struct {
+	Regex       string `yaml:"regex"`
+	Replacement string `yaml:"replacement"`
+	Permanent   bool   `yaml:"permanent"`
+}
+
+// wwwRedirectsConfig builds the redirect routers for the given redirects
+func wwwRedirectsConfig(redirects []config.WWWRedirect) traefikDynamicConfig {
+	dynamic := traefikDynamicConfig{HTTP: traefikHTTPConfig{
+		Routers:     make(map[string]traefikRouter),
+		Middlewares: make(map[string]traefikMiddleware),
+	}}
+
+	for _, redirect := range redirects {
+		name := "www-redirect-" + strings.ReplaceAll(redirect.From, ".", "-")
+		dynamic.HTTP.Middlewares[name] = traefikMiddleware{RedirectRegex: traefikRedirectRegex{
+			Regex:       "^https?://" + regexp.QuoteMeta(redirect.From) + "/(.*)",
+			Replacement: "https://" + redirect.To + "/${1}",
+			Permanent:   true,
+		}}
+		dynamic.HTTP.Routers[name] = traefikRouter{
+			Rule:        fmt.Sprintf("Host(`%s`)", redirect.From),
+			Middlewares: []string{name},
+			Service:     "noop@internal",
+		}
+	}
+	return dynamic
+}
+
+// writeWWWRedirects publishes the redirect routers implied by pair_www so
+// Traefik's file provider can serve them, and removes a stale file otherwise
+func (cm *CertificateManager) writeWWWRedirects() error {
+	path := filepath.Join(cm.config.Certificates.StoragePath, wwwRedirectsFileName)
+
+	redirects := cm.config.WWWRedirects()
+	if len(redirects) == 0 {
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale redirect hints: %w", err)
+		}
+		return nil
+	}
+
+	data, err := yaml.Marshal(wwwRedirectsConfig(redirects))
+	if err != nil {
+		return fmt.Errorf("failed to encode redirect hints: %w", err)
+	}
+
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write redirect hints: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write redirect hints: %w", err)
+	}
+
+	cm.logger.Printf("Wrote %d www redirect routers to %s; load it with Traefik's file provider", len(redirects), path)
+	return nil
+}
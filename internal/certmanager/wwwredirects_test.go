@@ -0,0 +1,38 @@
+package certmanager
+
+import (
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCertificateManager_WriteWWWRedirects(t *testing.T) {
+	testDir := setupTestDir(t)
+	cfg := createTestConfig()
+	cfg.Certificates.StoragePath = testDir
+	cfg.Domains = []config.Domain{
+		{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"}, PairWWW: "redirect_to_apex"},
+	}
+
+	cm := &CertificateManager{
+		config: cfg,
+		logger: log.New(os.Stdout, "[TEST] ", log.LstdFlags),
+	}
+	require.NoError(t, cm.writeWWWRedirects())
+
+	path := filepath.Join(testDir, wwwRedirectsFileName)
+	data, err := os.ReadFile(path)
+	require.NoError(t, err)
+	assert.Contains(t, string(data), "rule: Host(`www.example.com`)")
+	assert.Contains(t, string(data), "replacement: https://example.com/${1}")
+	assert.Contains(t, string(data), "service: noop@internal")
+
+	cfg.Domains[0].PairWWW = "both"
+	require.NoError(t, cm.writeWWWRedirects())
+	assert.NoFileExists(t, path)
+}
@@ -1,167 +1,2371 @@
 package config
 
 import (
+	"crypto/sha256"
+	"crypto/tls"
+	"encoding/hex"
+	"encoding/json"
 	"fmt"
+	"net"
+	"net/url"
 	"os"
+	"path"
 	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"text/template"
 	"time"
+)
+
+// application configuration
+type Config struct {
+	Include      []string               `yaml:"include"` // files, glob patterns or directories merged into this one
+	TraefikAPI   string                 `yaml:"traefik_api"`
+	Traefik      Traefik                `yaml:"traefik"`
+	Email        string                 `yaml:"email"`
+	Notification Notification           `yaml:"notification"`
+	Domains      []Domain               `yaml:"domains"`
+	Groups       map[string]DomainGroup `yaml:"groups"` // settings shared by the domains of a group
+	ACME         ACME                   `yaml:"acme"`
+	Certificates Certificates           `yaml:"certificates"`
+	App          App                    `yaml:"app"`
+	Scheduler    Scheduler              `yaml:"scheduler"`
+	Metrics      Metrics                `yaml:"metrics"`
+	Debug        Debug                  `yaml:"debug"`
+	Tracing      Tracing                `yaml:"tracing"`
+	TraefikTLS   TraefikTLS             `yaml:"traefik_tls"`
+	Health       Health                 `yaml:"health"`
+	API          API                    `yaml:"api"`
+	SDS          SDS                    `yaml:"sds"`
+	DNS          DNS                    `yaml:"dns"`
+	Discovery    Discovery              `yaml:"discovery"`
+	Hooks        Hooks                  `yaml:"hooks"`
+	Inventory    Inventory              `yaml:"inventory"`
+	Database     Database               `yaml:"database"`
+	Report       Report                 `yaml:"report"`
+}
+
+type Notification struct {
+	SMTPHost        string     `yaml:"smtp_host"`
+	SMTPPort        int        `yaml:"smtp_port"`
+	TLS             string     `yaml:"tls"`  // starttls, implicit or none
+	Auth            string     `yaml:"auth"` // plain, login or cram-md5; used when username is set
+	Username        string     `yaml:"username"`
+	Password        string     `yaml:"password"`
+	From            string     `yaml:"from"`
+	SubjectTemplate string     `yaml:"subject_template"` // Go template file for the subject; empty uses the built-in one
+	TextTemplate    string     `yaml:"text_template"`    // Go template file for the plain-text part; empty uses the built-in one
+	HTMLTemplate    string     `yaml:"html_template"`    // Go template file for the HTML part; empty uses the built-in one
+	DedupWindow     string     `yaml:"dedup_window"`     // a notice is not repeated within this, unless it escalates
+	Digest          bool       `yaml:"digest"`           // collect domain notices below page level into one message a day
+	DigestTime      string     `yaml:"digest_time"`      // local time the digest is sent, e.g. "08:00"
+	Escalation      Escalation `yaml:"escalation"`
+}
+
+// Escalation sets the remaining lifetime at which expiring certificates are
+// reported at each level. Page notices bypass the digest and also go to
+// PageRecipients, such as an email-to-pager gateway.
+type Escalation struct {
+	WarningDays    int      `yaml:"warning_days"`
+	CriticalDays   int      `yaml:"critical_days"`
+	PageDays       int      `yaml:"page_days"`
+	PageRecipients []string `yaml:"page_recipients"`
+}
+
+// Domain is a certificate to manage. Settings left empty fall back to the
+// global ones.
+type Domain struct {
+	Service     string         `yaml:"service"`
+	Domain      string         `yaml:"domain"`
+	Aliases     []string       `yaml:"aliases"`
+	SANs        []string       `yaml:"sans"`  // further names in this domain's certificate, instead of certificates of their own
+	Hooks       Hooks          `yaml:"hooks"` // run in addition to the global hooks
+	Deploy      []DeployTarget `yaml:"deploy"`
+	Switch      SwitchWebhooks `yaml:"switch_webhooks"`
+	PairWWW     string         `yaml:"pair_www"`     // overrides certificates.pair_www for this domain
+	Profile     string         `yaml:"profile"`      // overrides acme.profile for this domain
+	RenewalDays int            `yaml:"renewal_days"` // overrides certificates.renewal_days
+	RenewBefore string         `yaml:"renew_before"` // renew this long before expiry, for certificates living hours; overrides renewal_days and the profile's renew_before
+	KeyType     string         `yaml:"key_type"`     // overrides acme.key_type
+	Challenge   string         `yaml:"challenge"`    // overrides acme.challenge
+	CADirURL    string         `yaml:"ca_dir_url"`   // overrides acme.ca_dir_url, to order from another CA
+	Recipients  []string       `yaml:"recipients"`   // notified about this domain instead of email
+	DualKey     bool           `yaml:"dual_key"`     // also keep a certificate with the other key algorithm
+	MustStaple  bool           `yaml:"must_staple"`  // request the OCSP Must-Staple extension
+	CSR         CSRTemplate    `yaml:"csr"`          // replaces the default CSR
+	KeyFile     string         `yaml:"key_file"`     // PEM private key every order uses instead of a generated one
+	CSRFile     string         `yaml:"csr_file"`     // CSR to order for; its private key is kept elsewhere, e.g. in an HSM
+	Issuer      string         `yaml:"issuer"`       // acme (default) or internal-ca, for names no public CA can validate
+	Group       string         `yaml:"group"`        // e.g. production or staging, for group-scoped operations and the settings under groups
+}
+
+// DomainGroup holds settings shared by the domains tagged with its name.
+// Settings of a domain take precedence over those of its group.
+type DomainGroup struct {
+	Recipients   []string `yaml:"recipients"`    // notified about the group's domains instead of email
+	RenewalDays  int      `yaml:"renewal_days"`  // overrides certificates.renewal_days
+	RenewalHours string   `yaml:"renewal_hours"` // overrides certificates.renewal_hours, e.g. "09:00-17:00" for staging
+}
+
+func (g *DomainGroup) validate(renewalJitter string) error {
+	if g.RenewalDays < 0 {
+		return fmt.Errorf("renewal_days must not be negative")
+	}
+	if g.RenewalDays > 0 && renewalJitter != "" {
+		if jitter, err := time.ParseDuration(renewalJitter); err == nil && jitter >= time.Duration(g.RenewalDays)*24*time.Hour {
+			return fmt.Errorf("renewal_days must be longer than certificates.renewal_jitter")
+		}
+	}
+	if g.RenewalHours != "" {
+		if _, err := ParseDailyWindow(g.RenewalHours); err != nil {
+			return fmt.Errorf("renewal_hours is invalid: %w", err)
+		}
+	}
+	for _, recipient := range g.Recipients {
+		if !strings.Contains(recipient, "@") {
+			return fmt.Errorf("recipient %q is not an email address", recipient)
+		}
+	}
+	return nil
+}
+
+// Issuers a domain's certificate can come from
+const (
+	IssuerACME       = "acme"
+	IssuerInternalCA = "internal-ca"
+)
+
+// Consolidation policies for discovered domains
+const (
+	ConsolidatePerDomain = "per-domain"
+	ConsolidateSANs      = "consolidate"
+	ConsolidateWildcard  = "wildcard"
+)
+
+// MaxCertificateNames is the most names a CA puts in one certificate
+const MaxCertificateNames = 100
+
+// CSRTemplate customizes the certificate signing request of a domain. Subject
+// fields besides the common name are only kept by CAs that validate them;
+// Let's Encrypt and most other ACME CAs drop them.
+type CSRTemplate struct {
+	Organization       []string `yaml:"organization"`
+	OrganizationalUnit []string `yaml:"organizational_unit"`
+	Country            []string `yaml:"country"`
+	Province           []string `yaml:"province"`
+	Locality           []string `yaml:"locality"`
+	KeyUsage           []string `yaml:"key_usage"`     // digital_signature, key_encipherment, key_agreement
+	ExtKeyUsage        []string `yaml:"ext_key_usage"` // server_auth, client_auth
+}
+
+// IsZero reports whether the template leaves the CSR to the default
+func (t CSRTemplate) IsZero() bool {
+	return len(t.Organization) == 0 && len(t.OrganizationalUnit) == 0 && len(t.Country) == 0 &&
+		len(t.Province) == 0 && len(t.Locality) == 0 && len(t.KeyUsage) == 0 && len(t.ExtKeyUsage) == 0
+}
+
+func (t *CSRTemplate) validate() error {
+	for _, usage := range t.KeyUsage {
+		if usage != "digital_signature" && usage != "key_encipherment" && usage != "key_agreement" {
+			return fmt.Errorf("csr.key_usage must be digital_signature, key_encipherment or key_agreement, got %q", usage)
+		}
+	}
+	for _, usage := range t.ExtKeyUsage {
+		if usage != "server_auth" && usage != "client_auth" {
+			return fmt.Errorf("csr.ext_key_usage must be server_auth or client_auth, got %q", usage)
+		}
+	}
+	return nil
+}
+
+// DeployTarget copies a domain's certificate to a remote host over SSH after
+// each issuance or renewal, then optionally runs a reload command there
+type DeployTarget struct {
+	Host           string `yaml:"host"` // host or host:port
+	User           string `yaml:"user"`
+	PrivateKeyFile string `yaml:"private_key_file"`
+	KnownHostsFile string `yaml:"known_hosts_file"` // defaults to ~/.ssh/known_hosts
+	HostKey        string `yaml:"host_key"`         // pinned key in authorized_keys format, instead of known_hosts
+	Method         string `yaml:"method"`           // sftp or scp
+	CertPath       string `yaml:"cert_path"`        // full chain
+	KeyPath        string `yaml:"key_path"`
+	ChainPath      string `yaml:"chain_path"` // optional, issuer chain only
+	ReloadCommand  string `yaml:"reload_command"`
+	Timeout        string `yaml:"timeout"`
+}
+
+// SwitchWebhooks are called around the moment Traefik is given a domain's new
+// certificate, e.g. to drain connections before and purge a CDN after it
+type SwitchWebhooks struct {
+	PreSwitch     string `yaml:"pre_switch"`     // URL posted to before the new certificate is stored
+	PostSwitch    string `yaml:"post_switch"`    // URL posted to once Traefik can serve it
+	Timeout       string `yaml:"timeout"`        // of each call
+	FailurePolicy string `yaml:"failure_policy"` // abort (default) or continue
+}
+
+// Failure policies of switch webhooks
+const (
+	SwitchAbort    = "abort"    // a failing pre_switch keeps the previous certificate, a failing post_switch stops the rollout
+	SwitchContinue = "continue" // failures are reported and the rollout goes on
+)
+
+// Enabled reports whether any switch webhook is configured
+func (w SwitchWebhooks) Enabled() bool {
+	return w.PreSwitch != "" || w.PostSwitch != ""
+}
+
+func (w *SwitchWebhooks) validate() error {
+	for _, endpoint := range []struct{ name, url string }{
+		{"pre_switch", w.PreSwitch},
+		{"post_switch", w.PostSwitch},
+	} {
+		if endpoint.url == "" {
+			continue
+		}
+		if u, err := url.Parse(endpoint.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("%s must be an http or https URL", endpoint.name)
+		}
+	}
+	if w.Timeout != "" {
+		if d, err := time.ParseDuration(w.Timeout); err != nil || d <= 0 {
+			return fmt.Errorf("timeout must be a positive duration")
+		}
+	}
+	switch w.FailurePolicy {
+	case "", SwitchAbort, SwitchContinue:
+	default:
+		return fmt.Errorf("failure_policy must be abort or continue")
+	}
+	return nil
+}
+
+// Hooks are shell commands run after certificate events. Commands receive
+// CERT_MANAGER_* environment variables describing the domain and its files.
+type Hooks struct {
+	PostIssue []string `yaml:"post_issue"`
+	PostRenew []string `yaml:"post_renew"`
+	OnFailure []string `yaml:"on_failure"`
+	Timeout   string   `yaml:"timeout"` // per command; only read from the global section
+}
+
+// Commands returns the commands configured for an event: post_issue, post_renew or on_failure
+func (h Hooks) Commands(event string) []string {
+	switch event {
+	case "post_issue":
+		return h.PostIssue
+	case "post_renew":
+		return h.PostRenew
+	case "on_failure":
+		return h.OnFailure
+	}
+	return nil
+}
+
+// Inventory pushes the changes to the certificate inventory to an asset
+// database (CMDB) after every renewal cycle, either as a JSON POST to endpoint
+// or on the standard input of command
+type Inventory struct {
+	Endpoint string `yaml:"endpoint"` // URL receiving each delta, e.g. a ServiceNow scripted REST API
+	Token    string `yaml:"token"`    // bearer token sent to endpoint
+	Username string `yaml:"username"` // basic auth for endpoint, instead of a token
+	Password string `yaml:"password"`
+	Command  string `yaml:"command"` // plugin run with the delta on standard input, instead of endpoint
+	Timeout  string `yaml:"timeout"`
+}
+
+// Enabled reports whether an endpoint or command is configured
+func (i Inventory) Enabled() bool {
+	return i.Endpoint != "" || i.Command != ""
+}
+
+// Database keeps certificate metadata and the history of certificate events,
+// notifications and scheduler runs in a relational database, for historical
+// queries through the management API. The storage path stays the source of
+// truth for certificates.
+type Database struct {
+	Driver    string `yaml:"driver"`    // sqlite or postgres; empty keeps no history
+	DSN       string `yaml:"dsn"`       // connection string; sqlite defaults to history.db in the storage path
+	Retention string `yaml:"retention"` // drop history older than this; empty keeps all of it
+}
+
+// Enabled reports whether a database is configured
+func (d Database) Enabled() bool {
+	return d.Driver != ""
+}
+
+// Database drivers
+const (
+	DatabaseSQLite   = "sqlite"
+	DatabasePostgres = "postgres"
+)
+
+func (d *Database) validate() error {
+	switch d.Driver {
+	case "", DatabaseSQLite:
+	case DatabasePostgres:
+		if d.DSN == "" {
+			return fmt.Errorf("database.dsn is required for postgres")
+		}
+	default:
+		return fmt.Errorf("database.driver must be sqlite or postgres")
+	}
+	if d.Retention != "" {
+		if retention, err := time.ParseDuration(d.Retention); err != nil || retention <= 0 {
+			return fmt.Errorf("database.retention must be a positive duration")
+		}
+	}
+	return nil
+}
+
+// Report sends a weekly summary of all certificates for ops reviews: renewed
+// this week, upcoming expiries, failures and the average renewal lead time
+type Report struct {
+	Enabled      bool     `yaml:"enabled"`
+	Day          string   `yaml:"day"`           // weekday it is sent, e.g. "monday"
+	Time         string   `yaml:"time"`          // local time it is sent, e.g. "09:00"
+	Email        bool     `yaml:"email"`         // email it to recipients
+	Recipients   []string `yaml:"recipients"`    // empty sends to the global email
+	WebhookURL   string   `yaml:"webhook_url"`   // POST it as JSON here
+	Directory    string   `yaml:"directory"`     // write it as weekly-<year>-W<week>.md here
+	UpcomingDays int      `yaml:"upcoming_days"` // list certificates expiring within this many days
+}
+
+func (r *Report) validate() error {
+	if !r.Enabled {
+		return nil
+	}
+	if !r.Email && r.WebhookURL == "" && r.Directory == "" {
+		return fmt.Errorf("report needs email, webhook_url or directory to be delivered")
+	}
+	if r.Day != "" {
+		if _, err := parseWeekday(r.Day); err != nil {
+			return fmt.Errorf("report.day: %w", err)
+		}
+	}
+	if r.Time != "" {
+		if _, err := parseClock(r.Time); err != nil {
+			return fmt.Errorf("report.time: %w", err)
+		}
+	}
+	if r.WebhookURL != "" {
+		if u, err := url.Parse(r.WebhookURL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("report.webhook_url must be an http or https URL")
+		}
+	}
+	if r.UpcomingDays < 0 {
+		return fmt.Errorf("report.upcoming_days must not be negative")
+	}
+	return nil
+}
+
+// ACME client configuration
+type ACME struct {
+	CADirURL       string                 `yaml:"ca_dir_url"`
+	KeyType        string                 `yaml:"key_type"`
+	Email          string                 `yaml:"email"`
+	DuplicateLimit int                    `yaml:"duplicate_limit"` // identical SAN sets allowed per week
+	IssuanceLedger string                 `yaml:"issuance_ledger"` // file recording issuances per SAN set; empty keeps it in the storage path
+	RetryAttempts  int                    `yaml:"retry_attempts"`  // tries per domain and run on network or nonce failures
+	RetryBackoff   string                 `yaml:"retry_backoff"`   // delay before the first retry, doubled for each further one
+	HTTP01Prober   string                 `yaml:"http01_prober"`   // external service fetching challenge URLs when validation fails
+	StaleOrderAge  string                 `yaml:"stale_order_age"` // unfinished orders older than this have their pending authorizations deactivated
+	OrderTimeout   string                 `yaml:"order_timeout"`   // bounds each order, retries included; unfinished orders are resumed later
+	Profile        string                 `yaml:"profile"`         // certificate profile requested from the CA, e.g. "shortlived"; empty uses the CA's default
+	Profiles       map[string]ACMEProfile `yaml:"profiles"`
+	Challenge      string                 `yaml:"challenge"`       // http-01 or dns-01
+	HTTP01         HTTP01                 `yaml:"http01"`          // where http-01 challenges are answered
+	DNS01Command   string                 `yaml:"dns01_command"`   // program creating and removing dns-01 TXT records
+	DNS01Webhook   DNS01Webhook           `yaml:"dns01_webhook"`   // HTTP endpoints creating and removing them, instead of dns01_command
+	PreferredChain string                 `yaml:"preferred_chain"` // common name of the root whose chain to download, e.g. "ISRG Root X1"; empty takes the CA's default
+	CACert         string                 `yaml:"ca_cert"`         // PEM bundle of roots trusted for the CA's HTTPS endpoints besides the system roots, e.g. of a step-ca
+	WireLog        ACMEWireLog            `yaml:"wire_log"`
+	BulkIssuance   BulkIssuance           `yaml:"bulk_issuance"`
+
+	AccountKeyProvider string     `yaml:"account_key_provider"` // aws-kms, gcp-kms or command; empty keeps the account key in the storage path
+	AccountKey         AccountKey `yaml:"account_key"`
+}
+
+// HTTP01 configures the listener answering HTTP-01 challenges, to which
+// Traefik forwards /.well-known/acme-challenge/ requests
+type HTTP01 struct {
+	ListenAddress     string `yaml:"listen_address"`     // host:port; an empty host listens on every interface
+	ProxyHeader       string `yaml:"proxy_header"`       // header carrying the requested host behind a proxy, e.g. X-Forwarded-Host or Forwarded; empty checks Host
+	CheckReachability bool   `yaml:"check_reachability"` // probe the challenge path of every http-01 domain at startup
+}
+
+// BulkIssuance issues the certificates of domains that have none in the
+// background, at a bounded rate, instead of within the first run, for fleets
+// too large to issue before scheduler.run_timeout. Progress is checkpointed in
+// the storage path, so a restarted daemon continues where it stopped.
+type BulkIssuance struct {
+	Enabled          bool   `yaml:"enabled"`
+	OrdersPerHour    int    `yaml:"orders_per_hour"`    // new orders placed per hour at most
+	RateLimitBackoff string `yaml:"rate_limit_backoff"` // pause after the CA rate limits the account without saying until when
+}
+
+// AccountKey locates an ACME account key that never leaves a KMS or hardware
+// token. The key must be an ECDSA P-256 or P-384 key.
+type AccountKey struct {
+	AWSKMS  AWSKMS            `yaml:"aws_kms"` // asymmetric signing key
+	GCPKMS  GCPKMS            `yaml:"gcp_kms"` // key_name of a crypto key version
+	Command AccountKeyCommand `yaml:"command"`
+}
+
+// AccountKeyCommand signs with a program such as pkcs11-tool, for keys held by
+// a PKCS#11 token
+type AccountKeyCommand struct {
+	Program       string `yaml:"program"`         // run through the shell; signs the digest on stdin and writes the DER signature to stdout
+	PublicKeyFile string `yaml:"public_key_file"` // PEM public key or certificate of the token's key
+}
+
+// DNS01Webhook creates and removes dns-01 TXT records by POSTing the record
+// to HTTP endpoints, for DNS providers lego has no provider for
+type DNS01Webhook struct {
+	PresentURL         string `yaml:"present_url"`         // creates the TXT record
+	CleanupURL         string `yaml:"cleanup_url"`         // removes it once the challenge is done
+	Token              string `yaml:"token"`               // bearer token sent to both endpoints
+	Timeout            string `yaml:"timeout"`             // of each request
+	PropagationTimeout string `yaml:"propagation_timeout"` // how long to wait for the record to be visible
+	PollingInterval    string `yaml:"polling_interval"`    // between checks whether it is
+}
+
+// Enabled reports whether the webhook endpoints are configured
+func (w DNS01Webhook) Enabled() bool {
+	return w.PresentURL != ""
+}
+
+// headerNameRe matches HTTP header names
+var headerNameRe = regexp.MustCompile(`^[A-Za-z0-9-]+$`)
+
+func (h *HTTP01) validate() error {
+	if h.ListenAddress != "" {
+		_, port, err := net.SplitHostPort(h.ListenAddress)
+		if err != nil {
+			return fmt.Errorf("acme.http01.listen_address is invalid: %w", err)
+		}
+		if n, err := strconv.Atoi(port); err != nil || n < 1 || n > 65535 {
+			return fmt.Errorf("acme.http01.listen_address must have a port from 1 to 65535")
+		}
+	}
+	if h.ProxyHeader != "" && !headerNameRe.MatchString(h.ProxyHeader) {
+		return fmt.Errorf("acme.http01.proxy_header %q is not a header name", h.ProxyHeader)
+	}
+	return nil
+}
+
+func (w *DNS01Webhook) validate() error {
+	if !w.Enabled() {
+		if w.CleanupURL != "" {
+			return fmt.Errorf("acme.dns01_webhook.cleanup_url requires present_url")
+		}
+		return nil
+	}
+	for _, endpoint := range []struct{ name, url string }{
+		{"present_url", w.PresentURL},
+		{"cleanup_url", w.CleanupURL},
+	} {
+		if u, err := url.Parse(endpoint.url); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("acme.dns01_webhook.%s must be an http or https URL", endpoint.name)
+		}
+	}
+	for _, duration := range []struct{ name, value string }{
+		{"timeout", w.Timeout},
+		{"propagation_timeout", w.PropagationTimeout},
+		{"polling_interval", w.PollingInterval},
+	} {
+		if d, err := time.ParseDuration(duration.value); err != nil || d <= 0 {
+			return fmt.Errorf("acme.dns01_webhook.%s must be a positive duration", duration.name)
+		}
+	}
+	return nil
+}
+
+// ACMEWireLog writes the HTTP exchanges with the CA of selected domains to a
+// separate file, for debugging rejections. JWS payloads and signatures are
+// redacted. Further domains can be switched on at runtime.
+type ACMEWireLog struct {
+	File       string   `yaml:"file"`        // defaults to acme-wire.log in the storage path
+	MaxSizeMB  int      `yaml:"max_size_mb"` // rotate the file when it would grow beyond this
+	MaxBackups int      `yaml:"max_backups"` // rotated files to keep
+	Domains    []string `yaml:"domains"`     // always logged; "*" logs every domain
+}
+
+// validKeyTypes are the values key_type accepts
+var validKeyTypes = map[string]bool{"RSA2048": true, "RSA4096": true, "EC256": true, "EC384": true}
+
+func validChallenge(challenge string) bool {
+	return challenge == "" || challenge == "http-01" || challenge == "dns-01"
+}
+
+// ACMEProfile schedules renewals of certificates issued under a CA profile
+// whose lifetime is too short for certificates.renewal_days
+type ACMEProfile struct {
+	RenewBefore   string `yaml:"renew_before"`   // renew this long before expiry, e.g. "72h"
+	CheckInterval string `yaml:"check_interval"` // shortens app.check_interval while a domain uses the profile
+}
+
+// Certificate management settings
+type Certificates struct {
+	RenewalDays      int           `yaml:"renewal_days"`
+	RenewBefore      string        `yaml:"renew_before"` // renew this long before expiry instead of renewal_days, for CAs issuing certificates that live hours
+	StoragePath      string        `yaml:"storage_path"`
+	MinFreeSpaceMB   *int          `yaml:"min_free_space_mb"`  // refuse issuance below this much free space, 0 disables the check
+	MinFreeInodes    *int          `yaml:"min_free_inodes"`    // refuse issuance below this many free inodes, 0 disables the check
+	ChainWarningDays int           `yaml:"chain_warning_days"` // warn this long before an intermediate or root expires
+	LockTTL          string        `yaml:"lock_ttl"`           // age after which an order lock is considered abandoned
+	RenewalJitter    string        `yaml:"renewal_jitter"`     // spread each domain's renewal over this much of the renewal window
+	RenewalHours     string        `yaml:"renewal_hours"`      // local time of day renewals may run, e.g. "02:00-05:00"
+	PairWWW          string        `yaml:"pair_www"`           // none, both, redirect_to_apex or redirect_to_www
+	NotBeforeSkew    string        `yaml:"not_before_skew"`    // how far in the future a new certificate's NotBefore may lie
+	DualKey          bool          `yaml:"dual_key"`           // keep an RSA and an ECDSA certificate for every domain
+	CombinedPEM      bool          `yaml:"combined_pem"`       // also write the full chain and private key to one file per domain
+	DisableWatch     bool          `yaml:"disable_watch"`      // don't reload certificates replaced in the storage path by hand
+	Archive          Archive       `yaml:"archive"`
+	Encryption       Encryption    `yaml:"encryption"`
+	InternalCA       InternalCA    `yaml:"internal_ca"`
+	OCSPStapling     OCSPStapling  `yaml:"ocsp_stapling"`
+	Prune            Prune         `yaml:"prune"`
+	Consolidation    Consolidation `yaml:"consolidation"`
+}
+
+// Consolidation groups discovered subdomains of the same registered domain
+// into fewer certificates, reducing certificate count and rate limit
+// pressure. Configured domains keep their own certificates.
+type Consolidation struct {
+	Policy   string `yaml:"policy"`    // per-domain (default), consolidate into SAN certificates, or wildcard
+	MaxNames int    `yaml:"max_names"` // names per consolidated certificate, at most 100
+}
+
+// Prune revokes and deletes the certificates of domains removed from the
+// configuration and discovery once they stayed orphaned for the grace period.
+// Orphaned certificates are no longer renewed; adopting one keeps it.
+type Prune struct {
+	Enabled   bool `yaml:"enabled"`
+	GraceDays int  `yaml:"grace_days"` // days an orphaned certificate is kept in case its domain returns
+}
+
+// OCSPStapling keeps the current OCSP response of every certificate in a
+// <domain>.ocsp file next to it, for servers that staple from a file. A
+// response is refreshed once half of its validity has passed.
+type OCSPStapling struct {
+	Enabled  bool   `yaml:"enabled"`
+	Interval string `yaml:"interval"` // how often responses are checked for refresh
+	Timeout  string `yaml:"timeout"`  // bounds each request to a responder
+}
+
+// Archive controls retention of previous certificate generations
+type Archive struct {
+	Retention   int    `yaml:"retention"`   // generations to keep, 0 disables archiving and rollback
+	Compression string `yaml:"compression"` // gzip or none
+}
+
+// InternalCA configures the CA kept in the storage path that signs the
+// certificates of domains with issuer internal-ca. It is generated the first
+// time it is needed; clients must trust its certificate.
+type InternalCA struct {
+	CommonName string `yaml:"common_name"` // subject of the CA certificate when it is generated
+	Validity   string `yaml:"validity"`    // lifetime of issued certificates
+	CAValidity string `yaml:"ca_validity"` // lifetime of the CA certificate when it is generated
+}
+
+// Encryption configures envelope encryption of stored private keys. Each key
+// is sealed with a random data key, which is in turn wrapped by the provider.
+type Encryption struct {
+	Provider string        `yaml:"provider"` // age, aws-kms or gcp-kms; empty stores keys in plaintext
+	Age      AgeEncryption `yaml:"age"`
+	AWSKMS   AWSKMS        `yaml:"aws_kms"`
+	GCPKMS   GCPKMS        `yaml:"gcp_kms"`
+}
+
+// AgeEncryption wraps data keys for one or more age recipients
+type AgeEncryption struct {
+	Recipients   []string `yaml:"recipients"`
+	IdentityFile string   `yaml:"identity_file"` // needed to unwrap keys
+}
+
+// AWSKMS wraps data keys with an AWS KMS key. Credentials come from the
+// standard AWS_ACCESS_KEY_ID, AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables.
+type AWSKMS struct {
+	KeyID    string `yaml:"key_id"`
+	Region   string `yaml:"region"`
+	Endpoint string `yaml:"endpoint"` // optional override, e.g. for a VPC endpoint
+}
+
+// GCPKMS wraps data keys with a Cloud KMS crypto key. An access token is taken
+// from GOOGLE_OAUTH_ACCESS_TOKEN or the instance metadata server.
+type GCPKMS struct {
+	KeyName  string `yaml:"key_name"` // projects/*/locations/*/keyRings/*/cryptoKeys/*
+	Endpoint string `yaml:"endpoint"`
+}
+
+// App holds application-level settings
+type App struct {
+	LogLevel          string  `yaml:"log_level"`
+	CheckInterval     string  `yaml:"check_interval"`
+	Timeout           string  `yaml:"timeout"`            // deprecated: use traefik.timeout
+	RunTimeout        string  `yaml:"run_timeout"`        // deprecated: use scheduler.run_timeout
+	StartupRetries    int     `yaml:"startup_retries"`    // Traefik connection attempts before starting degraded
+	StartupBackoff    string  `yaml:"startup_backoff"`    // delay before the first retry, doubled for each further one
+	ReconnectInterval string  `yaml:"reconnect_interval"` // how often a degraded daemon retries Traefik
+	Maintenance       bool    `yaml:"maintenance"`        // pause issuance, renewal and deployment
+	RunHistory        int     `yaml:"run_history"`        // summaries of scheduler runs kept in the storage path
+	Strict            bool    `yaml:"strict"`             // any failure in a run, including deployments, fails it
+	LogFile           LogFile `yaml:"log_file"`
+}
+
+// Defaults of scheduler.run_timeout and acme.order_timeout
+const (
+	defaultRunTimeout   = time.Hour
+	defaultOrderTimeout = 10 * time.Minute
+)
+
+// Traefik holds settings for the Traefik API client
+type Traefik struct {
+	Timeout string `yaml:"timeout"` // of each request to the Traefik API
+	Drift   Drift  `yaml:"drift"`
+}
+
+// Drift periodically compares the hostnames of Traefik's routers with the
+// managed certificates and alerts when routers serve domains without a
+// certificate, or certificates are kept for domains no router serves
+type Drift struct {
+	Enabled  bool   `yaml:"enabled"`
+	Interval string `yaml:"interval"`
+}
+
+// Scheduler holds settings for renewal runs
+type Scheduler struct {
+	RunTimeout string `yaml:"run_timeout"` // bounds a renewal run; orders still pending are cancelled
+}
+
+// LogFile writes the log to a rotated file instead of stdout, for installs
+// without systemd or another log collector
+type LogFile struct {
+	Path           string `yaml:"path"`            // empty logs to stdout
+	MaxSizeMB      int    `yaml:"max_size_mb"`     // rotate the file when it would grow beyond this
+	RotateInterval string `yaml:"rotate_interval"` // also rotate at every multiple of this, e.g. "24h" for daily files
+	MaxBackups     int    `yaml:"max_backups"`     // rotated files to keep, 0 keeps all
+	MaxAge         string `yaml:"max_age"`         // remove rotated files older than this; empty keeps them
+}
+
+// Metrics holds settings for the Prometheus metrics endpoint
+type Metrics struct {
+	Enabled       bool   `yaml:"enabled"`
+	ListenAddress string `yaml:"listen_address"`
+}
+
+// Debug holds settings for the profiling and runtime state endpoints used to
+// diagnose a hung daemon without restarting it under a debugger
+type Debug struct {
+	Enabled       bool   `yaml:"enabled"`
+	ListenAddress string `yaml:"listen_address"` // host:port on a loopback address, the endpoints are unauthenticated
+}
+
+// Tracing exports OpenTelemetry spans of renewal runs, ACME orders, Traefik
+// API calls and storage operations over OTLP/HTTP, to Jaeger, Tempo or an
+// OpenTelemetry collector
+type Tracing struct {
+	Endpoint    string            `yaml:"endpoint"`     // OTLP/HTTP base URL, e.g. http://jaeger:4318; empty disables tracing
+	Headers     map[string]string `yaml:"headers"`      // sent with every export, e.g. for authentication
+	ServiceName string            `yaml:"service_name"` // service.name of the exported spans
+	SampleRatio float64           `yaml:"sample_ratio"` // fraction of traces exported, from 0 to 1
+}
+
+// Enabled reports whether an endpoint is configured
+func (t Tracing) Enabled() bool {
+	return t.Endpoint != ""
+}
+
+func (t *Tracing) validate() error {
+	if t.Endpoint != "" {
+		if u, err := url.Parse(t.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("tracing.endpoint must be an http:// or https:// URL")
+		}
+	}
+	if t.SampleRatio < 0 || t.SampleRatio > 1 {
+		return fmt.Errorf("tracing.sample_ratio must be between 0 and 1")
+	}
+	return nil
+}
+
+// TraefikTLS controls the TLS section of the dynamic configuration published
+// to Traefik, as tls.yml in the storage path for the file provider and through
+// the management API for the HTTP provider
+type TraefikTLS struct {
+	File               bool                  `yaml:"file"`                // write tls.yml listing every stored certificate
+	DefaultCertificate string                `yaml:"default_certificate"` // domain whose certificate is served to clients without a matching SNI
+	Options            map[string]TLSOptions `yaml:"options"`             // named TLS options; "default" applies to routers without their own
+}
+
+// TLSOptions are Traefik TLS options, referenced by routers or entrypoints as
+// <name>@file or <name>@http
+type TLSOptions struct {
+	MinVersion       string   `yaml:"min_version"` // VersionTLS10 to VersionTLS13
+	MaxVersion       string   `yaml:"max_version"`
+	CipherSuites     []string `yaml:"cipher_suites"`     // Go names, e.g. TLS_ECDHE_ECDSA_WITH_AES_128_GCM_SHA256; TLS 1.3 suites can't be chosen
+	CurvePreferences []string `yaml:"curve_preferences"` // CurveP256, CurveP384, CurveP521, X25519 or X25519MLKEM768
+	SNIStrict        bool     `yaml:"sni_strict"`        // reject clients without a matching SNI instead of serving the default certificate
+	ALPNProtocols    []string `yaml:"alpn_protocols"`
+}
+
+// tlsVersions orders the TLS versions Traefik accepts
+var tlsVersions = map[string]int{"VersionTLS10": 10, "VersionTLS11": 11, "VersionTLS12": 12, "VersionTLS13": 13}
+
+// tlsCurves are the curves Traefik accepts
+var tlsCurves = map[string]bool{"CurveP256": true, "CurveP384": true, "CurveP521": true, "X25519": true, "X25519MLKEM768": true}
+
+func (o *TLSOptions) validate() error {
+	for _, version := range []string{o.MinVersion, o.MaxVersion} {
+		if _, ok := tlsVersions[version]; version != "" && !ok {
+			return fmt.Errorf("unknown TLS version %q, use VersionTLS10 to VersionTLS13", version)
+		}
+	}
+	if o.MinVersion != "" && o.MaxVersion != "" && tlsVersions[o.MinVersion] > tlsVersions[o.MaxVersion] {
+		return fmt.Errorf("min_version is above max_version")
+	}
+
+	suites := make(map[string]bool)
+	for _, suite := range tls.CipherSuites() {
+		suites[suite.Name] = true
+	}
+	for _, suite := range tls.InsecureCipherSuites() {
+		suites[suite.Name] = true
+	}
+	for _, suite := range o.CipherSuites {
+		if !suites[suite] {
+			return fmt.Errorf("unknown cipher suite %q", suite)
+		}
+	}
+	for _, curve := range o.CurvePreferences {
+		if !tlsCurves[curve] {
+			return fmt.Errorf("unknown curve %q", curve)
+		}
+	}
+	return nil
+}
+
+// Health holds settings for the /healthz, /readyz and /livez endpoints
+type Health struct {
+	Enabled       bool   `yaml:"enabled"`
+	ListenAddress string `yaml:"listen_address"`
+}
+
+// API holds settings for the management API
+type API struct {
+	Enabled         bool   `yaml:"enabled"`
+	ListenAddress   string `yaml:"listen_address"`
+	Token           string `yaml:"token"`            // bearer token required by every request when set
+	IdempotencyTTL  string `yaml:"idempotency_ttl"`  // how long responses are replayed for a repeated Idempotency-Key
+	ForwardAuth     bool   `yaml:"forward_auth"`     // serve certificate status headers to Traefik's ForwardAuth middleware, without the token
+	TraefikProvider bool   `yaml:"traefik_provider"` // serve every certificate, keys included, to Traefik's HTTP provider
+}
+
+// SDS serves the managed certificates to Envoy and other proxies over the
+// secret discovery service, one secret per domain named after it. The secrets
+// include private keys, so a listener reachable from other hosts requires
+// mutual TLS.
+type SDS struct {
+	Enabled         bool   `yaml:"enabled"`
+	ListenAddress   string `yaml:"listen_address"`   // host:port, or unix:/path for a Unix socket
+	RefreshInterval string `yaml:"refresh_interval"` // how often renewed certificates are pushed to clients
+	TLSCertFile     string `yaml:"tls_cert_file"`
+	TLSKeyFile      string `yaml:"tls_key_file"`
+	ClientCAFile    string `yaml:"client_ca_file"` // clients must present a certificate issued by this CA
+}
+
+// DNS selects the resolvers used for validation pre-checks and propagation polling
+type DNS struct {
+	DoHResolvers    []string `yaml:"doh_resolvers"` // DNS-over-HTTPS endpoints; empty uses the system resolver
+	Timeout         string   `yaml:"timeout"`
+	DisablePrecheck bool     `yaml:"disable_precheck"` // skip checking that a domain resolves before ordering
+}
+
+// Discovery configures dynamic domain sources
+type Discovery struct {
+	Docker   DockerDiscovery  `yaml:"docker"`
+	Vhosts   VhostDiscovery   `yaml:"vhosts"`
+	DNSZones DNSZoneDiscovery `yaml:"dns_zones"`
+}
+
+// DockerDiscovery reads domains from labels on running containers
+type DockerDiscovery struct {
+	Enabled     bool   `yaml:"enabled"`
+	Endpoint    string `yaml:"endpoint"`
+	LabelPrefix string `yaml:"label_prefix"`
+}
+
+// VhostDiscovery reads domains from nginx and Apache configuration files
+type VhostDiscovery struct {
+	Enabled  bool     `yaml:"enabled"`
+	Paths    []string `yaml:"paths"`    // files, directories or glob patterns
+	Interval string   `yaml:"interval"` // how often the paths are rescanned
+}
+
+// DNSZoneDiscovery enumerates records in DNS zones hosted at Route53 or
+// Cloudflare. Route53 credentials come from the standard AWS_ACCESS_KEY_ID,
+// AWS_SECRET_ACCESS_KEY and AWS_SESSION_TOKEN variables, the Cloudflare API
+// token from CLOUDFLARE_API_TOKEN.
+type DNSZoneDiscovery struct {
+	Enabled     bool     `yaml:"enabled"`
+	Provider    string   `yaml:"provider"`     // route53 or cloudflare
+	Zones       []string `yaml:"zones"`        // hosted zone IDs (Route53) or zone IDs (Cloudflare)
+	Include     []string `yaml:"include"`      // record name patterns such as "*.example.com"; empty matches every record
+	Exclude     []string `yaml:"exclude"`      // record name patterns to leave out
+	RecordTypes []string `yaml:"record_types"` // record types to consider
+	Issue       bool     `yaml:"issue"`        // issue certificates for matching records instead of only proposing them
+	Interval    string   `yaml:"interval"`     // how often the zones are listed
+	Endpoint    string   `yaml:"endpoint"`     // optional API endpoint override
+}
+
+// Validate reads a configuration file and returns every problem found in it,
+// where LoadConfig stops at the first, along with the configuration with
+// defaults applied
+func Validate(configPath string) (*Config, []error, error) {
+	config, err := readConfig(configPath)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	problems := config.Problems()
+	config.setDefaults()
+	return config, problems, nil
+}
+
+// configuration from a YAML, JSON or TOML file and the files it includes
+func LoadConfig(configPath string) (*Config, error) {
+	if _, err := os.Stat(configPath); os.IsNotExist(err) {
+		return nil, fmt.Errorf("config file not found: %s", configPath)
+	}
+
+	config, err := readConfig(configPath)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := config.validate(); err != nil {
+		return nil, fmt.Errorf("invalid configuration: %w", err)
+	}
+
+	config.setDefaults()
+	config.pairWWW()
+
+	return config, nil
+}
+
+// validate ensures the configuration is valid, reporting the first problem
+func (c *Config) validate() error {
+	if problems := c.Problems(); len(problems) > 0 {
+		return problems[0]
+	}
+	return nil
+}
+
+// Problems returns every problem with the configuration, in the order validate
+// would report them one at a time
+func (c *Config) Problems() []error {
+	var problems []error
+
+	if c.TraefikAPI == "" {
+		problems = append(problems, fmt.Errorf("traefik_api is required"))
+	}
+
+	if c.Email == "" {
+		problems = append(problems, fmt.Errorf("email is required"))
+	}
+
+	if c.Notification.SMTPHost == "" {
+		problems = append(problems, fmt.Errorf("notification.smtp_host is required"))
+	}
+
+	if c.Notification.SMTPPort == 0 {
+		problems = append(problems, fmt.Errorf("notification.smtp_port is required"))
+	}
+
+	if err := c.Notification.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := c.App.LogFile.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if len(c.Domains) == 0 && !c.Discovery.Docker.Enabled && !c.Discovery.Vhosts.Enabled &&
+		!(c.Discovery.DNSZones.Enabled && c.Discovery.DNSZones.Issue) {
+		problems = append(problems, fmt.Errorf("at least one domain configuration is required"))
+	}
+
+	if c.Discovery.Vhosts.Enabled && len(c.Discovery.Vhosts.Paths) == 0 {
+		problems = append(problems, fmt.Errorf("discovery.vhosts.paths is required when vhost discovery is enabled"))
+	}
+	if c.Discovery.Vhosts.Interval != "" {
+		if _, err := time.ParseDuration(c.Discovery.Vhosts.Interval); err != nil {
+			problems = append(problems, fmt.Errorf("discovery.vhosts.interval is invalid: %w", err))
+		}
+	}
+
+	if err := c.Discovery.DNSZones.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if c.ACME.DuplicateLimit < 0 {
+		problems = append(problems, fmt.Errorf("acme.duplicate_limit must not be negative"))
+	}
+
+	if err := c.ACME.AccountKey.validate(c.ACME.AccountKeyProvider); err != nil {
+		problems = append(problems, err)
+	}
+
+	if c.ACME.WireLog.MaxSizeMB < 0 || c.ACME.WireLog.MaxBackups < 0 {
+		problems = append(problems, fmt.Errorf("acme.wire_log.max_size_mb and max_backups must not be negative"))
+	}
+
+	if c.ACME.HTTP01Prober != "" {
+		if u, err := url.Parse(c.ACME.HTTP01Prober); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			problems = append(problems, fmt.Errorf("acme.http01_prober must be an http:// or https:// URL"))
+		}
+	}
+
+	if c.App.StartupRetries < 0 {
+		problems = append(problems, fmt.Errorf("app.startup_retries must not be negative"))
+	}
+
+	if c.App.RunHistory < 0 {
+		problems = append(problems, fmt.Errorf("app.run_history must not be negative"))
+	}
+
+	if c.App.StartupBackoff != "" {
+		if _, err := time.ParseDuration(c.App.StartupBackoff); err != nil {
+			problems = append(problems, fmt.Errorf("app.startup_backoff is invalid: %w", err))
+		}
+	}
+
+	if c.App.CheckInterval != "" {
+		if _, err := time.ParseDuration(c.App.CheckInterval); err != nil {
+			problems = append(problems, fmt.Errorf("app.check_interval is invalid: %w", err))
+		}
+	}
+
+	problems = append(problems, c.timeoutProblems()...)
+
+	if c.App.ReconnectInterval != "" {
+		if _, err := time.ParseDuration(c.App.ReconnectInterval); err != nil {
+			problems = append(problems, fmt.Errorf("app.reconnect_interval is invalid: %w", err))
+		}
+	}
+
+	if c.ACME.RetryAttempts < 0 {
+		problems = append(problems, fmt.Errorf("acme.retry_attempts must not be negative"))
+	}
+
+	if c.ACME.RetryBackoff != "" {
+		if _, err := time.ParseDuration(c.ACME.RetryBackoff); err != nil {
+			problems = append(problems, fmt.Errorf("acme.retry_backoff is invalid: %w", err))
+		}
+	}
+
+	profileNames := make([]string, 0, len(c.ACME.Profiles))
+	for name := range c.ACME.Profiles {
+		profileNames = append(profileNames, name)
+	}
+	sort.Strings(profileNames)
+	for _, name := range profileNames {
+		profile := c.ACME.Profiles[name]
+		if err := profile.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("acme.profiles.%s: %w", name, err))
+		}
+	}
+
+	if c.ACME.CACert != "" {
+		if _, err := os.Stat(c.ACME.CACert); err != nil {
+			problems = append(problems, fmt.Errorf("acme.ca_cert is not accessible: %w", err))
+		}
+	}
+
+	if !validChallenge(c.ACME.Challenge) {
+		problems = append(problems, fmt.Errorf("acme.challenge must be http-01 or dns-01"))
+	}
+	if err := c.ACME.DNS01Webhook.validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if err := c.ACME.HTTP01.validate(); err != nil {
+		problems = append(problems, err)
+	}
+	switch {
+	case c.ACME.DNS01Command != "" && c.ACME.DNS01Webhook.Enabled():
+		problems = append(problems, fmt.Errorf("acme.dns01_command and acme.dns01_webhook are mutually exclusive"))
+	case c.ACME.DNS01Command == "" && !c.ACME.DNS01Webhook.Enabled() && c.usesChallenge("dns-01"):
+		problems = append(problems, fmt.Errorf("acme.dns01_command or acme.dns01_webhook is required for the dns-01 challenge"))
+	}
+
+	if c.ACME.StaleOrderAge != "" {
+		if _, err := time.ParseDuration(c.ACME.StaleOrderAge); err != nil {
+			problems = append(problems, fmt.Errorf("acme.stale_order_age is invalid: %w", err))
+		}
+	}
+
+	for i, server := range c.DNS.DoHResolvers {
+		if !strings.HasPrefix(server, "https://") {
+			problems = append(problems, fmt.Errorf("dns.doh_resolvers[%d] must be an https:// URL", i))
+		}
+	}
+
+	if c.DNS.Timeout != "" {
+		if _, err := time.ParseDuration(c.DNS.Timeout); err != nil {
+			problems = append(problems, fmt.Errorf("dns.timeout is invalid: %w", err))
+		}
+	}
+
+	if c.Certificates.MinFreeSpaceMB != nil && *c.Certificates.MinFreeSpaceMB < 0 {
+		problems = append(problems, fmt.Errorf("certificates.min_free_space_mb must not be negative"))
+	}
+
+	if c.Certificates.MinFreeInodes != nil && *c.Certificates.MinFreeInodes < 0 {
+		problems = append(problems, fmt.Errorf("certificates.min_free_inodes must not be negative"))
+	}
+
+	if c.Certificates.ChainWarningDays < 0 {
+		problems = append(problems, fmt.Errorf("certificates.chain_warning_days must not be negative"))
+	}
+
+	if c.Certificates.LockTTL != "" {
+		if _, err := time.ParseDuration(c.Certificates.LockTTL); err != nil {
+			problems = append(problems, fmt.Errorf("certificates.lock_ttl is invalid: %w", err))
+		}
+	}
+
+	if c.Certificates.NotBeforeSkew != "" {
+		skew, err := time.ParseDuration(c.Certificates.NotBeforeSkew)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("certificates.not_before_skew is invalid: %w", err))
+		}
+		if skew < 0 {
+			problems = append(problems, fmt.Errorf("certificates.not_before_skew must not be negative"))
+		}
+	}
+
+	if c.API.IdempotencyTTL != "" {
+		if _, err := time.ParseDuration(c.API.IdempotencyTTL); err != nil {
+			problems = append(problems, fmt.Errorf("api.idempotency_ttl is invalid: %w", err))
+		}
+	}
+
+	if c.API.TraefikProvider && c.API.Token == "" {
+		problems = append(problems, fmt.Errorf("api.token is required with api.traefik_provider, which serves private keys"))
+	}
+
+	problems = append(problems, c.SDS.problems()...)
+	problems = append(problems, c.Debug.problems()...)
+
+	if c.Certificates.RenewalJitter != "" {
+		jitter, err := time.ParseDuration(c.Certificates.RenewalJitter)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("certificates.renewal_jitter is invalid: %w", err))
+		}
+		renewalDays := c.Certificates.RenewalDays
+		if renewalDays == 0 {
+			renewalDays = 30
+		}
+		if jitter < 0 || jitter >= time.Duration(renewalDays)*24*time.Hour {
+			problems = append(problems, fmt.Errorf("certificates.renewal_jitter must be positive and shorter than renewal_days"))
+		}
+	}
+
+	if c.Certificates.RenewBefore != "" {
+		if d, err := time.ParseDuration(c.Certificates.RenewBefore); err != nil || d <= 0 {
+			problems = append(problems, fmt.Errorf("certificates.renew_before must be a positive duration"))
+		}
+	}
+
+	if c.Certificates.RenewalHours != "" {
+		if _, err := ParseDailyWindow(c.Certificates.RenewalHours); err != nil {
+			problems = append(problems, fmt.Errorf("certificates.renewal_hours is invalid: %w", err))
+		}
+	}
+
+	if !validWWWPairing(c.Certificates.PairWWW) {
+		problems = append(problems, fmt.Errorf("certificates.pair_www must be none, both, redirect_to_apex or redirect_to_www"))
+	}
+
+	if c.Certificates.Archive.Retention < 0 {
+		problems = append(problems, fmt.Errorf("certificates.archive.retention must not be negative"))
+	}
+
+	switch c.Certificates.Archive.Compression {
+	case "", "gzip", "none":
+	default:
+		problems = append(problems, fmt.Errorf("certificates.archive.compression must be gzip or none"))
+	}
+
+	if c.usesInternalCA() {
+		validity, err := time.ParseDuration(c.Certificates.InternalCA.Validity)
+		if err != nil || validity <= 0 {
+			problems = append(problems, fmt.Errorf("certificates.internal_ca.validity must be a positive duration"))
+		} else if validity <= c.defaultRenewalWindow() {
+			problems = append(problems, fmt.Errorf("certificates.internal_ca.validity must be longer than certificates.renewal_days or renew_before"))
+		}
+		if caValidity, err := time.ParseDuration(c.Certificates.InternalCA.CAValidity); err != nil || caValidity <= validity {
+			problems = append(problems, fmt.Errorf("certificates.internal_ca.ca_validity must be a duration longer than validity"))
+		}
+	}
+
+	if c.ACME.BulkIssuance.OrdersPerHour < 0 {
+		problems = append(problems, fmt.Errorf("acme.bulk_issuance.orders_per_hour must not be negative"))
+	}
+	if c.ACME.BulkIssuance.RateLimitBackoff != "" {
+		if d, err := time.ParseDuration(c.ACME.BulkIssuance.RateLimitBackoff); err != nil {
+			problems = append(problems, fmt.Errorf("acme.bulk_issuance.rate_limit_backoff is invalid: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, fmt.Errorf("acme.bulk_issuance.rate_limit_backoff must be positive"))
+		}
+	}
+
+	if c.Traefik.Drift.Interval != "" {
+		if d, err := time.ParseDuration(c.Traefik.Drift.Interval); err != nil {
+			problems = append(problems, fmt.Errorf("traefik.drift.interval is invalid: %w", err))
+		} else if d <= 0 {
+			problems = append(problems, fmt.Errorf("traefik.drift.interval must be positive"))
+		}
+	}
+
+	if c.Certificates.Prune.GraceDays < 0 {
+		problems = append(problems, fmt.Errorf("certificates.prune.grace_days must not be negative"))
+	}
+
+	switch c.Certificates.Consolidation.Policy {
+	case "", ConsolidatePerDomain, ConsolidateSANs, ConsolidateWildcard:
+	default:
+		problems = append(problems, fmt.Errorf("certificates.consolidation.policy must be per-domain, consolidate or wildcard"))
+	}
+	if n := c.Certificates.Consolidation.MaxNames; n < 0 || n > MaxCertificateNames {
+		problems = append(problems, fmt.Errorf("certificates.consolidation.max_names must be from 1 to %d", MaxCertificateNames))
+	}
+
+	stapling := []struct{ field, value string }{
+		{"certificates.ocsp_stapling.interval", c.Certificates.OCSPStapling.Interval},
+		{"certificates.ocsp_stapling.timeout", c.Certificates.OCSPStapling.Timeout},
+	}
+	for _, s := range stapling {
+		if s.value == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(s.value); err != nil {
+			problems = append(problems, fmt.Errorf("%s is invalid: %w", s.field, err))
+		} else if d <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive", s.field))
+		}
+	}
+
+	if c.Hooks.Timeout != "" {
+		if _, err := time.ParseDuration(c.Hooks.Timeout); err != nil {
+			problems = append(problems, fmt.Errorf("hooks.timeout is invalid: %w", err))
+		}
+	}
+
+	if err := c.Certificates.Encryption.validate(); err != nil {
+		problems = append(problems, err)
+	}
+	if c.Certificates.CombinedPEM && c.Certificates.Encryption.Provider != "" {
+		problems = append(problems, fmt.Errorf("certificates.combined_pem would store private keys in plaintext and can't be used with certificates.encryption"))
+	}
+	if c.TraefikTLS.File && c.Certificates.Encryption.Provider != "" {
+		problems = append(problems, fmt.Errorf("traefik_tls.file can't be used with certificates.encryption, as Traefik can't read encrypted keys; use api.traefik_provider"))
+	}
+
+	optionNames := make([]string, 0, len(c.TraefikTLS.Options))
+	for name := range c.TraefikTLS.Options {
+		optionNames = append(optionNames, name)
+	}
+	sort.Strings(optionNames)
+	for _, name := range optionNames {
+		options := c.TraefikTLS.Options[name]
+		if err := options.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("traefik_tls.options.%s: %w", name, err))
+		}
+	}
+
+	if err := c.Inventory.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := c.Database.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := c.Report.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	if err := c.Tracing.validate(); err != nil {
+		problems = append(problems, err)
+	}
+
+	groupNames := make([]string, 0, len(c.Groups))
+	for name := range c.Groups {
+		groupNames = append(groupNames, name)
+	}
+	sort.Strings(groupNames)
+	for _, name := range groupNames {
+		group := c.Groups[name]
+		if err := group.validate(c.Certificates.RenewalJitter); err != nil {
+			problems = append(problems, fmt.Errorf("groups.%s: %w", name, err))
+		}
+	}
+
+	// Validate each domain. Every name may be certified by one entry only.
+	claimedBy := make(map[string]int)
+	for i, domain := range c.Domains {
+		if domain.Service == "" {
+			problems = append(problems, fmt.Errorf("domain[%d].service is required", i))
+		}
+		if domain.Domain == "" {
+			problems = append(problems, fmt.Errorf("domain[%d].domain is required", i))
+		}
+		if !validWWWPairing(domain.PairWWW) {
+			problems = append(problems, fmt.Errorf("domain[%d].pair_www must be none, both, redirect_to_apex or redirect_to_www", i))
+		}
+		if err := domain.validate(c.Certificates.RenewalJitter); err != nil {
+			problems = append(problems, fmt.Errorf("domain[%d]: %w", i, err))
+		}
+		for j, target := range domain.Deploy {
+			if err := target.validate(); err != nil {
+				problems = append(problems, fmt.Errorf("domain[%d].deploy[%d]: %w", i, j, err))
+			}
+		}
+		if err := domain.Switch.validate(); err != nil {
+			problems = append(problems, fmt.Errorf("domain[%d].switch_webhooks: %w", i, err))
+		}
+		if len(domain.SANs) >= MaxCertificateNames {
+			problems = append(problems, fmt.Errorf("domain[%d].sans must list fewer than %d names", i, MaxCertificateNames))
+		}
+		names := append(append([]string{domain.Domain}, domain.Aliases...), domain.SANs...)
+		for j, name := range names {
+			if name == "" {
+				continue
+			}
+			other, claimed := claimedBy[name]
+			switch {
+			case !claimed:
+				claimedBy[name] = i
+			case other == i:
+				problems = append(problems, fmt.Errorf("domain[%d] lists %s more than once", i, name))
+			case j == 0 && c.Domains[other].Domain == name:
+				problems = append(problems, fmt.Errorf("domain[%d].domain %s duplicates domain[%d]", i, name, other))
+			default:
+				problems = append(problems, fmt.Errorf("domain[%d] and domain[%d] both include %s", other, i, name))
+			}
+		}
+	}
+
+	return problems
+}
+
+// problems checks the SDS listener. Its secrets carry private keys, so only a
+// Unix socket or loopback address may be served without mutual TLS.
+func (s SDS) problems() []error {
+	if !s.Enabled {
+		return nil
+	}
+	var problems []error
+	if (s.TLSCertFile == "") != (s.TLSKeyFile == "") {
+		problems = append(problems, fmt.Errorf("sds.tls_cert_file and sds.tls_key_file must be set together"))
+	}
+	if s.ClientCAFile != "" && s.TLSCertFile == "" {
+		problems = append(problems, fmt.Errorf("sds.client_ca_file requires sds.tls_cert_file and sds.tls_key_file"))
+	}
+	if s.RefreshInterval != "" {
+		interval, err := time.ParseDuration(s.RefreshInterval)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("sds.refresh_interval is invalid: %w", err))
+		} else if interval <= 0 {
+			problems = append(problems, fmt.Errorf("sds.refresh_interval must be positive"))
+		}
+	}
+
+	if s.ListenAddress == "" || strings.HasPrefix(s.ListenAddress, "unix:") {
+		return problems
+	}
+	host, _, err := net.SplitHostPort(s.ListenAddress)
+	if err != nil {
+		return append(problems, fmt.Errorf("sds.listen_address is invalid: %w", err))
+	}
+	ip := net.ParseIP(host)
+	loopback := host == "localhost" || (ip != nil && ip.IsLoopback())
+	if !loopback && s.ClientCAFile == "" {
+		problems = append(problems, fmt.Errorf("sds.listen_address %s is reachable from other hosts and requires sds.client_ca_file, as the secrets include private keys", s.ListenAddress))
+	}
+	return problems
+}
+
+// problems checks that the debug endpoints aren't exposed to other hosts, as
+// anyone reaching them can read profiles and the command line
+func (d Debug) problems() []error {
+	if d.ListenAddress == "" {
+		return nil
+	}
+	host, _, err := net.SplitHostPort(d.ListenAddress)
+	if err != nil {
+		return []error{fmt.Errorf("debug.listen_address is invalid: %w", err)}
+	}
+	ip := net.ParseIP(host)
+	if host != "localhost" && (ip == nil || !ip.IsLoopback()) {
+		return []error{fmt.Errorf("debug.listen_address %s must be a loopback address, as the debug endpoints are unauthenticated", d.ListenAddress)}
+	}
+	return nil
+}
+
+// timeoutProblems checks the Traefik, order and run timeouts, each falling back
+// to its deprecated app setting
+func (c *Config) timeoutProblems() []error {
+	var problems []error
+	timeouts := []struct {
+		field, value string
+	}{
+		{"app.timeout", c.App.Timeout},
+		{"app.run_timeout", c.App.RunTimeout},
+		{"traefik.timeout", c.Traefik.Timeout},
+		{"acme.order_timeout", c.ACME.OrderTimeout},
+		{"scheduler.run_timeout", c.Scheduler.RunTimeout},
+	}
+	parsed := make(map[string]time.Duration)
+	for _, t := range timeouts {
+		if t.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(t.value)
+		if err != nil {
+			problems = append(problems, fmt.Errorf("%s is invalid: %w", t.field, err))
+			continue
+		}
+		if d <= 0 {
+			problems = append(problems, fmt.Errorf("%s must be positive", t.field))
+			continue
+		}
+		parsed[t.field] = d
+	}
+
+	if c.Traefik.Timeout != "" && c.App.Timeout != "" {
+		problems = append(problems, fmt.Errorf("app.timeout is deprecated and can't be combined with traefik.timeout"))
+	}
+	if c.Scheduler.RunTimeout != "" && c.App.RunTimeout != "" {
+		problems = append(problems, fmt.Errorf("app.run_timeout is deprecated and can't be combined with scheduler.run_timeout"))
+	}
+
+	// An order must be able to finish within a run
+	order, ok := parsed["acme.order_timeout"]
+	if !ok {
+		return problems
+	}
+	run, ok := parsed["scheduler.run_timeout"]
+	if !ok {
+		run, ok = parsed["app.run_timeout"]
+	}
+	if !ok && c.App.RunTimeout == "" && c.Scheduler.RunTimeout == "" {
+		run, ok = defaultRunTimeout, true
+	}
+	if ok && order > run {
+		problems = append(problems, fmt.Errorf("acme.order_timeout (%s) exceeds the run timeout (%s)", order, run))
+	}
+	return problems
+}
+
+// validate checks the overrides of a domain. renewal_days must leave room for
+// the global renewal jitter, as certificates.renewal_days does.
+func (d *Domain) validate(renewalJitter string) error {
+	if d.RenewalDays < 0 {
+		return fmt.Errorf("renewal_days must not be negative")
+	}
+	if d.RenewalDays > 0 && renewalJitter != "" {
+		if jitter, err := time.ParseDuration(renewalJitter); err == nil && jitter >= time.Duration(d.RenewalDays)*24*time.Hour {
+			return fmt.Errorf("renewal_days must be longer than certificates.renewal_jitter")
+		}
+	}
+	if d.KeyType != "" && !validKeyTypes[d.KeyType] {
+		return fmt.Errorf("key_type must be RSA2048, RSA4096, EC256 or EC384")
+	}
+	if !validChallenge(d.Challenge) {
+		return fmt.Errorf("challenge must be http-01 or dns-01")
+	}
+	if d.CADirURL != "" {
+		if u, err := url.Parse(d.CADirURL); err != nil || u.Scheme != "https" || u.Host == "" {
+			return fmt.Errorf("ca_dir_url must be an https:// URL")
+		}
+	}
+	for _, recipient := range d.Recipients {
+		if !strings.Contains(recipient, "@") {
+			return fmt.Errorf("recipient %q is not an email address", recipient)
+		}
+	}
+	if d.RenewBefore != "" {
+		if d.RenewalDays > 0 {
+			return fmt.Errorf("renewal_days and renew_before are mutually exclusive")
+		}
+		if window, err := time.ParseDuration(d.RenewBefore); err != nil || window <= 0 {
+			return fmt.Errorf("renew_before must be a positive duration")
+		}
+	}
+	if d.KeyFile != "" && d.CSRFile != "" {
+		return fmt.Errorf("key_file and csr_file are mutually exclusive")
+	}
+	if d.KeyFile != "" || d.CSRFile != "" {
+		if d.KeyType != "" || d.DualKey {
+			return fmt.Errorf("key_type and dual_key can't be used with key_file or csr_file")
+		}
+		// Only one of them is set
+		for name, path := range map[string]string{"key_file": d.KeyFile, "csr_file": d.CSRFile} {
+			if _, err := os.Stat(path); path != "" && err != nil {
+				return fmt.Errorf("%s is not accessible: %w", name, err)
+			}
+		}
+	}
+	if d.CSRFile != "" && (!d.CSR.IsZero() || d.MustStaple) {
+		return fmt.Errorf("csr and must_staple can't be used with csr_file, whose CSR is used as is")
+	}
+	switch d.Issuer {
+	case "", IssuerACME:
+	case IssuerInternalCA:
+		if d.Challenge != "" || d.CADirURL != "" || d.Profile != "" || d.MustStaple || d.CSRFile != "" {
+			return fmt.Errorf("challenge, ca_dir_url, profile, must_staple and csr_file can't be used with issuer internal-ca")
+		}
+	default:
+		return fmt.Errorf("issuer must be acme or internal-ca")
+	}
+	return d.CSR.validate()
+}
+
+func (p *ACMEProfile) validate() error {
+	fields := []struct{ name, value string }{
+		{"renew_before", p.RenewBefore},
+		{"check_interval", p.CheckInterval},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return fmt.Errorf("%s is invalid: %w", field.name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("%s must be positive", field.name)
+		}
+	}
+	return nil
+}
+
+func (t *DeployTarget) validate() error {
+	if t.Host == "" {
+		return fmt.Errorf("host is required")
+	}
+	if t.User == "" {
+		return fmt.Errorf("user is required")
+	}
+	if t.PrivateKeyFile == "" {
+		return fmt.Errorf("private_key_file is required")
+	}
+	if t.CertPath == "" || t.KeyPath == "" {
+		return fmt.Errorf("cert_path and key_path are required")
+	}
+	switch t.Method {
+	case "", "sftp", "scp":
+	default:
+		return fmt.Errorf("method must be sftp or scp")
+	}
+	if t.Timeout != "" {
+		if _, err := time.ParseDuration(t.Timeout); err != nil {
+			return fmt.Errorf("timeout is invalid: %w", err)
+		}
+	}
+	return nil
+}
+
+func (e *Encryption) validate() error {
+	switch e.Provider {
+	case "":
+	case "age":
+		if len(e.Age.Recipients) == 0 {
+			return fmt.Errorf("certificates.encryption.age.recipients is required")
+		}
+		if e.Age.IdentityFile == "" {
+			return fmt.Errorf("certificates.encryption.age.identity_file is required")
+		}
+	case "aws-kms":
+		if e.AWSKMS.KeyID == "" {
+			return fmt.Errorf("certificates.encryption.aws_kms.key_id is required")
+		}
+		if e.AWSKMS.Region == "" {
+			return fmt.Errorf("certificates.encryption.aws_kms.region is required")
+		}
+	case "gcp-kms":
+		if e.GCPKMS.KeyName == "" {
+			return fmt.Errorf("certificates.encryption.gcp_kms.key_name is required")
+		}
+	default:
+		return fmt.Errorf("certificates.encryption.provider must be age, aws-kms or gcp-kms")
+	}
+	return nil
+}
+
+func (a *AccountKey) validate(provider string) error {
+	switch provider {
+	case "":
+	case "aws-kms":
+		if a.AWSKMS.KeyID == "" {
+			return fmt.Errorf("acme.account_key.aws_kms.key_id is required")
+		}
+		if a.AWSKMS.Region == "" {
+			return fmt.Errorf("acme.account_key.aws_kms.region is required")
+		}
+	case "gcp-kms":
+		if !strings.Contains(a.GCPKMS.KeyName, "/cryptoKeyVersions/") {
+			return fmt.Errorf("acme.account_key.gcp_kms.key_name must name a crypto key version")
+		}
+	case "command":
+		if a.Command.Program == "" {
+			return fmt.Errorf("acme.account_key.command.program is required")
+		}
+		if a.Command.PublicKeyFile == "" {
+			return fmt.Errorf("acme.account_key.command.public_key_file is required")
+		}
+	default:
+		return fmt.Errorf("acme.account_key_provider must be aws-kms, gcp-kms or command")
+	}
+	return nil
+}
+
+func (n *Notification) validate() error {
+	switch n.TLS {
+	case "", "starttls", "implicit", "none":
+	default:
+		return fmt.Errorf("notification.tls must be starttls, implicit or none, got %q", n.TLS)
+	}
+	switch n.Auth {
+	case "", "plain", "login", "cram-md5":
+	default:
+		return fmt.Errorf("notification.auth must be plain, login or cram-md5, got %q", n.Auth)
+	}
+	if n.SubjectTemplate != "" {
+		if _, err := template.ParseFiles(n.SubjectTemplate); err != nil {
+			return fmt.Errorf("notification.subject_template is invalid: %w", err)
+		}
+	}
+	if n.TextTemplate != "" {
+		if _, err := template.ParseFiles(n.TextTemplate); err != nil {
+			return fmt.Errorf("notification.text_template is invalid: %w", err)
+		}
+	}
+	if n.HTMLTemplate != "" {
+		if _, err := template.ParseFiles(n.HTMLTemplate); err != nil {
+			return fmt.Errorf("notification.html_template is invalid: %w", err)
+		}
+	}
+	if n.DedupWindow != "" {
+		window, err := time.ParseDuration(n.DedupWindow)
+		if err != nil {
+			return fmt.Errorf("notification.dedup_window is invalid: %w", err)
+		}
+		if window < 0 {
+			return fmt.Errorf("notification.dedup_window must not be negative")
+		}
+	}
+	if n.DigestTime != "" {
+		if _, err := parseClock(n.DigestTime); err != nil {
+			return fmt.Errorf("notification.digest_time is invalid: %w", err)
+		}
+	}
+	e := n.Escalation
+	if e.WarningDays < 0 || e.CriticalDays < 0 || e.PageDays < 0 {
+		return fmt.Errorf("notification.escalation days must not be negative")
+	}
+	if (e.WarningDays > 0 && e.CriticalDays > e.WarningDays) || (e.CriticalDays > 0 && e.PageDays > e.CriticalDays) {
+		return fmt.Errorf("notification.escalation must have warning_days >= critical_days >= page_days")
+	}
+	return nil
+}
+
+func (l *LogFile) validate() error {
+	if l.MaxSizeMB < 0 || l.MaxBackups < 0 {
+		return fmt.Errorf("app.log_file.max_size_mb and max_backups must not be negative")
+	}
+	fields := []struct{ name, value string }{
+		{"rotate_interval", l.RotateInterval},
+		{"max_age", l.MaxAge},
+	}
+	for _, field := range fields {
+		if field.value == "" {
+			continue
+		}
+		d, err := time.ParseDuration(field.value)
+		if err != nil {
+			return fmt.Errorf("app.log_file.%s is invalid: %w", field.name, err)
+		}
+		if d <= 0 {
+			return fmt.Errorf("app.log_file.%s must be positive", field.name)
+		}
+	}
+	return nil
+}
+
+func (i *Inventory) validate() error {
+	if i.Endpoint != "" {
+		if u, err := url.Parse(i.Endpoint); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("inventory.endpoint must be an http or https URL")
+		}
+		if i.Command != "" {
+			return fmt.Errorf("inventory.endpoint and inventory.command are mutually exclusive")
+		}
+	}
+	if i.Token != "" && i.Username != "" {
+		return fmt.Errorf("inventory.token and inventory.username are mutually exclusive")
+	}
+	if i.Timeout != "" {
+		if _, err := time.ParseDuration(i.Timeout); err != nil {
+			return fmt.Errorf("inventory.timeout is invalid: %w", err)
+		}
+	}
+	return nil
+}
+
+func (d *DNSZoneDiscovery) validate() error {
+	if !d.Enabled {
+		return nil
+	}
+	switch d.Provider {
+	case "route53", "cloudflare":
+	default:
+		return fmt.Errorf("discovery.dns_zones.provider must be route53 or cloudflare")
+	}
+	if len(d.Zones) == 0 {
+		return fmt.Errorf("discovery.dns_zones.zones is required when DNS zone discovery is enabled")
+	}
+	for _, pattern := range append(append([]string{}, d.Include...), d.Exclude...) {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("discovery.dns_zones pattern %q is invalid: %w", pattern, err)
+		}
+	}
+	if d.Interval != "" {
+		if _, err := time.ParseDuration(d.Interval); err != nil {
+			return fmt.Errorf("discovery.dns_zones.interval is invalid: %w", err)
+		}
+	}
+	return nil
+}
+
+// setDefaults sets default values for optional fields
+func (c *Config) setDefaults() {
+	if c.ACME.CADirURL == "" {
+		c.ACME.CADirURL = "https://acme-v02.api.letsencrypt.org/directory"
+	}
+	if c.ACME.KeyType == "" {
+		c.ACME.KeyType = "RSA2048"
+	}
+	if c.ACME.Email == "" {
+		c.ACME.Email = c.Email
+	}
+	if c.ACME.DuplicateLimit == 0 {
+		c.ACME.DuplicateLimit = 5 // Let's Encrypt duplicate certificate limit
+	}
+	if c.ACME.RetryAttempts == 0 {
+		c.ACME.RetryAttempts = 3
+	}
+	if c.ACME.RetryBackoff == "" {
+		c.ACME.RetryBackoff = "10s"
+	}
+	if c.ACME.StaleOrderAge == "" {
+		c.ACME.StaleOrderAge = "24h"
+	}
+	if c.ACME.DNS01Webhook.Timeout == "" {
+		c.ACME.DNS01Webhook.Timeout = "30s"
+	}
+	if c.ACME.DNS01Webhook.PropagationTimeout == "" {
+		c.ACME.DNS01Webhook.PropagationTimeout = "60s"
+	}
+	if c.ACME.DNS01Webhook.PollingInterval == "" {
+		c.ACME.DNS01Webhook.PollingInterval = "2s"
+	}
+	if c.ACME.WireLog.MaxSizeMB == 0 {
+		c.ACME.WireLog.MaxSizeMB = 10
+	}
+	if c.ACME.WireLog.MaxBackups == 0 {
+		c.ACME.WireLog.MaxBackups = 3
+	}
+	if c.ACME.Challenge == "" {
+		c.ACME.Challenge = "http-01"
+	}
+	if c.ACME.HTTP01.ListenAddress == "" {
+		c.ACME.HTTP01.ListenAddress = ":5002"
+	}
+
+	if c.Certificates.RenewalDays == 0 {
+		c.Certificates.RenewalDays = 30
+	}
+	if c.Certificates.StoragePath == "" {
+		c.Certificates.StoragePath = "./certs"
+	}
+	// Unset thresholds get defaults; 0 disables the check
+	if c.Certificates.MinFreeSpaceMB == nil {
+		minFreeSpaceMB := 10
+		c.Certificates.MinFreeSpaceMB = &minFreeSpaceMB
+	}
+	if c.Certificates.MinFreeInodes == nil {
+		minFreeInodes := 100
+		c.Certificates.MinFreeInodes = &minFreeInodes
+	}
+	if c.Certificates.ChainWarningDays == 0 {
+		c.Certificates.ChainWarningDays = 60
+	}
+	if c.Certificates.LockTTL == "" {
+		c.Certificates.LockTTL = "1h"
+	}
+	if c.Certificates.NotBeforeSkew == "" {
+		c.Certificates.NotBeforeSkew = "5m"
+	}
+	if c.Certificates.Archive.Compression == "" {
+		c.Certificates.Archive.Compression = "gzip"
+	}
+	if c.Certificates.InternalCA.CommonName == "" {
+		c.Certificates.InternalCA.CommonName = "Traefik Cert Manager Internal CA"
+	}
+	if c.Certificates.InternalCA.Validity == "" {
+		c.Certificates.InternalCA.Validity = "2160h"
+	}
+	if c.Certificates.InternalCA.CAValidity == "" {
+		c.Certificates.InternalCA.CAValidity = "87600h"
+	}
+	if c.Certificates.OCSPStapling.Interval == "" {
+		c.Certificates.OCSPStapling.Interval = "1h"
+	}
+	if c.Certificates.OCSPStapling.Timeout == "" {
+		c.Certificates.OCSPStapling.Timeout = "10s"
+	}
+	if c.Certificates.Prune.GraceDays == 0 {
+		c.Certificates.Prune.GraceDays = 30
+	}
+	if c.Certificates.Consolidation.Policy == "" {
+		c.Certificates.Consolidation.Policy = ConsolidatePerDomain
+	}
+	if c.Certificates.Consolidation.MaxNames == 0 {
+		c.Certificates.Consolidation.MaxNames = MaxCertificateNames
+	}
+
+	if c.App.LogLevel == "" {
+		c.App.LogLevel = "info"
+	}
+	if c.App.CheckInterval == "" {
+		c.App.CheckInterval = "24h"
+	}
+	if c.Traefik.Timeout == "" {
+		c.Traefik.Timeout = c.App.Timeout
+	}
+	if c.Traefik.Timeout == "" {
+		c.Traefik.Timeout = "30s"
+	}
+	if c.ACME.BulkIssuance.OrdersPerHour == 0 {
+		c.ACME.BulkIssuance.OrdersPerHour = 100
+	}
+	if c.ACME.BulkIssuance.RateLimitBackoff == "" {
+		c.ACME.BulkIssuance.RateLimitBackoff = "1h"
+	}
+	if c.Traefik.Drift.Interval == "" {
+		c.Traefik.Drift.Interval = "1h"
+	}
+	if c.Scheduler.RunTimeout == "" {
+		c.Scheduler.RunTimeout = c.App.RunTimeout
+	}
+	if c.Scheduler.RunTimeout == "" {
+		c.Scheduler.RunTimeout = "1h"
+	}
+	if c.ACME.OrderTimeout == "" {
+		// Leave a run room for several orders, but never outlast a short one
+		c.ACME.OrderTimeout = "10m"
+		if run, err := time.ParseDuration(c.Scheduler.RunTimeout); err == nil && run < defaultOrderTimeout {
+			c.ACME.OrderTimeout = c.Scheduler.RunTimeout
+		}
+	}
+	if c.App.StartupRetries == 0 {
+		c.App.StartupRetries = 5
+	}
+	if c.App.StartupBackoff == "" {
+		c.App.StartupBackoff = "2s"
+	}
+	if c.App.ReconnectInterval == "" {
+		c.App.ReconnectInterval = "30s"
+	}
+	if c.App.RunHistory == 0 {
+		c.App.RunHistory = 30
+	}
+	if c.App.LogFile.MaxSizeMB == 0 {
+		c.App.LogFile.MaxSizeMB = 100
+	}
+
+	if c.Notification.From == "" {
+		c.Notification.From = "noreply@example.com"
+	}
+	if c.Notification.TLS == "" {
+		c.Notification.TLS = "starttls"
+	}
+	if c.Notification.Auth == "" {
+		c.Notification.Auth = "plain"
+	}
+	if c.Notification.DedupWindow == "" {
+		c.Notification.DedupWindow = "24h"
+	}
+	if c.Notification.DigestTime == "" {
+		c.Notification.DigestTime = "08:00"
+	}
+	if c.Notification.Escalation.WarningDays == 0 {
+		c.Notification.Escalation.WarningDays = 14
+	}
+	if c.Notification.Escalation.CriticalDays == 0 {
+		c.Notification.Escalation.CriticalDays = 3
+	}
+	if c.Notification.Escalation.PageDays == 0 {
+		c.Notification.Escalation.PageDays = 1
+	}
 
-	"gopkg.in/yaml.v2"
-)
+	for i := range c.Domains {
+		for j := range c.Domains[i].Deploy {
+			target := &c.Domains[i].Deploy[j]
+			if target.Method == "" {
+				target.Method = "sftp"
+			}
+			if target.Timeout == "" {
+				target.Timeout = "60s"
+			}
+		}
+		if webhooks := &c.Domains[i].Switch; webhooks.Enabled() {
+			if webhooks.Timeout == "" {
+				webhooks.Timeout = "30s"
+			}
+			if webhooks.FailurePolicy == "" {
+				webhooks.FailurePolicy = SwitchAbort
+			}
+		}
+	}
 
-// application configuration
-type Config struct {
-	TraefikAPI   string       `yaml:"traefik_api"`
-	Email        string       `yaml:"email"`
-	Notification Notification `yaml:"notification"`
-	Domains      []Domain     `yaml:"domains"`
-	ACME         ACME         `yaml:"acme"`
-	Certificates Certificates `yaml:"certificates"`
-	App          App          `yaml:"app"`
+	if c.Hooks.Timeout == "" {
+		c.Hooks.Timeout = "60s"
+	}
+
+	if c.Inventory.Timeout == "" {
+		c.Inventory.Timeout = "30s"
+	}
+
+	if c.Metrics.ListenAddress == "" {
+		c.Metrics.ListenAddress = ":9090"
+	}
+
+	if c.Report.Day == "" {
+		c.Report.Day = "monday"
+	}
+	if c.Report.Time == "" {
+		c.Report.Time = "09:00"
+	}
+	if c.Report.UpcomingDays == 0 {
+		c.Report.UpcomingDays = 30
+	}
+
+	if c.Tracing.ServiceName == "" {
+		c.Tracing.ServiceName = "traefik-cert-manager"
+	}
+	if c.Tracing.SampleRatio == 0 {
+		c.Tracing.SampleRatio = 1
+	}
+
+	if c.DNS.Timeout == "" {
+		c.DNS.Timeout = "10s"
+	}
+
+	if c.Health.ListenAddress == "" {
+		c.Health.ListenAddress = ":8081"
+	}
+
+	if c.API.ListenAddress == "" {
+		c.API.ListenAddress = "127.0.0.1:8082"
+	}
+	if c.API.IdempotencyTTL == "" {
+		c.API.IdempotencyTTL = "24h"
+	}
+
+	if c.Debug.ListenAddress == "" {
+		c.Debug.ListenAddress = "127.0.0.1:6060"
+	}
+	if c.SDS.ListenAddress == "" {
+		c.SDS.ListenAddress = "127.0.0.1:18000"
+	}
+	if c.SDS.RefreshInterval == "" {
+		c.SDS.RefreshInterval = "10s"
+	}
+
+	if c.Discovery.Docker.Endpoint == "" {
+		c.Discovery.Docker.Endpoint = "unix:///var/run/docker.sock"
+	}
+	if c.Discovery.Vhosts.Interval == "" {
+		c.Discovery.Vhosts.Interval = "5m"
+	}
+	if c.Discovery.DNSZones.Interval == "" {
+		c.Discovery.DNSZones.Interval = "15m"
+	}
+	if len(c.Discovery.DNSZones.RecordTypes) == 0 {
+		c.Discovery.DNSZones.RecordTypes = []string{"A", "AAAA", "CNAME"}
+	}
+	if c.Discovery.Docker.LabelPrefix == "" {
+		c.Discovery.Docker.LabelPrefix = "cert-manager"
+	}
 }
 
-type Notification struct {
-	SMTPHost string `yaml:"smtp_host"`
-	SMTPPort int    `yaml:"smtp_port"`
-	Username string `yaml:"username"`
-	Password string `yaml:"password"`
-	From     string `yaml:"from"`
+// GetCheckInterval returns app.check_interval, shortened to the check interval
+// of any ACME profile a configured domain uses, and to a quarter of the
+// shortest renew_before, so certificates living hours are checked several
+// times inside their renewal window
+func (c *Config) GetCheckInterval() (time.Duration, error) {
+	interval, err := time.ParseDuration(c.App.CheckInterval)
+	if err != nil {
+		return 0, err
+	}
+
+	used := map[string]bool{c.ACME.Profile: true}
+	for _, domain := range c.Domains {
+		used[domain.Profile] = true
+	}
+	for name := range used {
+		profile, ok := c.ACME.Profiles[name]
+		if !ok || profile.CheckInterval == "" {
+			continue
+		}
+		if d, err := time.ParseDuration(profile.CheckInterval); err == nil && d < interval {
+			interval = d
+		}
+	}
+	for _, domain := range c.Domains {
+		if window, ok := c.RenewBeforeFor(domain.Domain); ok && window/4 < interval {
+			interval = window / 4
+		}
+	}
+	return interval, nil
 }
 
-type Domain struct {
-	Service string   `yaml:"service"`
-	Domain  string   `yaml:"domain"`
-	Aliases []string `yaml:"aliases"`
+// domainEntry returns the configured entry certifying a domain or alias, nil
+// for discovered and unknown domains
+func (c *Config) domainEntry(domain string) *Domain {
+	for i, d := range c.Domains {
+		if d.Domain == domain {
+			return &c.Domains[i]
+		}
+		for _, alias := range d.Aliases {
+			if alias == domain {
+				return &c.Domains[i]
+			}
+		}
+	}
+	return nil
 }
 
-// ACME client configuration
-type ACME struct {
-	CADirURL string `yaml:"ca_dir_url"`
-	KeyType  string `yaml:"key_type"`
-	Email    string `yaml:"email"`
+// ProfileFor returns the CA profile requested for a domain or alias, empty
+// for the CA's default
+func (c *Config) ProfileFor(domain string) string {
+	if d := c.domainEntry(domain); d != nil && d.Profile != "" {
+		return d.Profile
+	}
+	return c.ACME.Profile
 }
 
-// Certificate management settings
-type Certificates struct {
-	RenewalDays int    `yaml:"renewal_days"`
-	StoragePath string `yaml:"storage_path"`
+// RenewalDaysFor returns how many days before expiry a domain's certificate
+// is renewed
+func (c *Config) RenewalDaysFor(domain string) int {
+	if days := c.renewalDaysOverride(domain); days > 0 {
+		return days
+	}
+	return c.Certificates.RenewalDays
 }
 
-// App holds application-level settings
-type App struct {
-	LogLevel      string `yaml:"log_level"`
-	CheckInterval string `yaml:"check_interval"`
-	Timeout       string `yaml:"timeout"`
+// renewalDaysOverride returns the renewal_days set by a domain or its group,
+// zero when neither sets them
+func (c *Config) renewalDaysOverride(domain string) int {
+	d := c.domainEntry(domain)
+	if d == nil {
+		return 0
+	}
+	if d.RenewalDays > 0 {
+		return d.RenewalDays
+	}
+	return c.Groups[d.Group].RenewalDays
 }
 
-// configuration from a YAML file
-func LoadConfig(configPath string) (*Config, error) {
-	if _, err := os.Stat(configPath); os.IsNotExist(err) {
-		return nil, fmt.Errorf("config file not found: %s", configPath)
+// GroupFor returns the group of a domain or alias, empty when it has none
+func (c *Config) GroupFor(domain string) string {
+	if d := c.domainEntry(domain); d != nil {
+		return d.Group
+	}
+	return ""
+}
+
+// DomainsInGroup returns the primary domains of the entries in a group
+func (c *Config) DomainsInGroup(group string) []string {
+	var domains []string
+	for _, d := range c.Domains {
+		if d.Group == group {
+			domains = append(domains, d.Domain)
+		}
 	}
+	return domains
+}
 
-	data, err := os.ReadFile(configPath)
+// RenewalHoursFor returns the daily window a domain's certificate may be
+// renewed in, set by its group or certificates.renewal_hours; nil allows
+// renewals at any time
+func (c *Config) RenewalHoursFor(domain string) *DailyWindow {
+	hours := c.Certificates.RenewalHours
+	if group, ok := c.Groups[c.GroupFor(domain)]; ok && group.RenewalHours != "" {
+		hours = group.RenewalHours
+	}
+	if hours == "" {
+		return nil
+	}
+	window, err := ParseDailyWindow(hours)
 	if err != nil {
-		return nil, fmt.Errorf("failed to read config file: %w", err)
+		return nil
 	}
+	return &window
+}
 
-	var config Config
-	if err := yaml.Unmarshal(data, &config); err != nil {
-		return nil, fmt.Errorf("failed to parse config file: %w", err)
+// KeyTypeFor returns the key type a domain's certificate is ordered with
+func (c *Config) KeyTypeFor(domain string) string {
+	if d := c.domainEntry(domain); d != nil && d.KeyType != "" {
+		return d.KeyType
 	}
+	return c.ACME.KeyType
+}
 
-	if err := config.validate(); err != nil {
-		return nil, fmt.Errorf("invalid configuration: %w", err)
+// MustStapleFor reports whether a domain's certificates request OCSP Must-Staple
+func (c *Config) MustStapleFor(domain string) bool {
+	d := c.domainEntry(domain)
+	return d != nil && d.MustStaple
+}
+
+// CSRFor returns the CSR template of a domain
+func (c *Config) CSRFor(domain string) CSRTemplate {
+	if d := c.domainEntry(domain); d != nil {
+		return d.CSR
 	}
+	return CSRTemplate{}
+}
 
-	config.setDefaults()
+// ExternalKeyFor returns the key or CSR file a domain is ordered with, both
+// empty when keys are generated
+func (c *Config) ExternalKeyFor(domain string) (keyFile, csrFile string) {
+	if d := c.domainEntry(domain); d != nil {
+		return d.KeyFile, d.CSRFile
+	}
+	return "", ""
+}
 
-	return &config, nil
+// ChallengeFor returns the challenge type a domain is validated with
+func (c *Config) ChallengeFor(domain string) string {
+	if d := c.domainEntry(domain); d != nil && d.Challenge != "" {
+		return d.Challenge
+	}
+	// CAs only validate wildcard names over DNS
+	if strings.HasPrefix(domain, "*.") {
+		return "dns-01"
+	}
+	return c.ACME.Challenge
 }
 
-// validate ensures the configuration is valid
-func (c *Config) validate() error {
-	if c.TraefikAPI == "" {
-		return fmt.Errorf("traefik_api is required")
+// CADirURLFor returns the directory of the CA a domain is ordered from
+func (c *Config) CADirURLFor(domain string) string {
+	if d := c.domainEntry(domain); d != nil && d.CADirURL != "" {
+		return d.CADirURL
 	}
+	return c.ACME.CADirURL
+}
 
-	if c.Email == "" {
-		return fmt.Errorf("email is required")
+// UsePebble orders every ACME certificate from the Pebble test CA at dirURL,
+// whose HTTPS certificate chains to the roots in caCert. Certificates and the
+// issuance ledger are kept in a pebble directory of the storage path, apart
+// from publicly trusted ones.
+func (c *Config) UsePebble(dirURL, caCert string) {
+	c.ACME.CADirURL = dirURL
+	c.ACME.CACert = caCert
+	for i := range c.Domains {
+		c.Domains[i].CADirURL = ""
 	}
+	c.Certificates.StoragePath = filepath.Join(c.Certificates.StoragePath, "pebble")
+	c.ACME.IssuanceLedger = ""
+}
 
-	if c.Notification.SMTPHost == "" {
-		return fmt.Errorf("notification.smtp_host is required")
+// IssuerFor returns the issuer of a domain's certificate, acme or internal-ca
+func (c *Config) IssuerFor(domain string) string {
+	if d := c.domainEntry(domain); d != nil && d.Issuer != "" {
+		return d.Issuer
 	}
+	return IssuerACME
+}
 
-	if c.Notification.SMTPPort == 0 {
-		return fmt.Errorf("notification.smtp_port is required")
+// usesInternalCA reports whether any configured domain is issued by the internal CA
+func (c *Config) usesInternalCA() bool {
+	for _, d := range c.Domains {
+		if d.Issuer == IssuerInternalCA {
+			return true
+		}
 	}
+	return false
+}
 
-	if len(c.Domains) == 0 {
-		return fmt.Errorf("at least one domain configuration is required")
+// WireLogPath returns the file ACME exchanges of debugged domains are logged to
+func (c *Config) WireLogPath() string {
+	if c.ACME.WireLog.File != "" {
+		return c.ACME.WireLog.File
 	}
+	return filepath.Join(c.Certificates.StoragePath, "acme-wire.log")
+}
 
-	// Validate each domain
-	for i, domain := range c.Domains {
-		if domain.Service == "" {
-			return fmt.Errorf("domain[%d].service is required", i)
+// RecipientsFor returns who is notified about a domain: its own recipients,
+// those of its group or email
+func (c *Config) RecipientsFor(domain string) []string {
+	d := c.domainEntry(domain)
+	if d != nil && len(d.Recipients) > 0 {
+		return d.Recipients
+	}
+	if d != nil && len(c.Groups[d.Group].Recipients) > 0 {
+		return c.Groups[d.Group].Recipients
+	}
+	return []string{c.Email}
+}
+
+// DualKeyFor reports whether a domain keeps a second certificate with the
+// other key algorithm
+func (c *Config) DualKeyFor(domain string) bool {
+	d := c.domainEntry(domain)
+	if d != nil && (d.KeyFile != "" || d.CSRFile != "") {
+		return false // only the domain's own key is used
+	}
+	return c.Certificates.DualKey || (d != nil && d.DualKey)
+}
+
+// usesChallenge reports whether any configured domain is validated with challenge
+func (c *Config) usesChallenge(challenge string) bool {
+	if c.ACME.Challenge == challenge {
+		return true
+	}
+	if challenge == "dns-01" && c.Certificates.Consolidation.Policy == ConsolidateWildcard {
+		return true
+	}
+	for _, d := range c.Domains {
+		if d.Challenge == challenge {
+			return true
 		}
-		if domain.Domain == "" {
-			return fmt.Errorf("domain[%d].domain is required", i)
+	}
+	return false
+}
+
+// RenewBeforeFor returns how long before expiry a domain's certificate is
+// renewed when that is set as a duration rather than in days: by the domain,
+// its ACME profile or certificates.renew_before, in that order. renewal_days
+// set on the domain or its group takes precedence over certificates.renew_before.
+func (c *Config) RenewBeforeFor(domain string) (time.Duration, bool) {
+	entry := c.domainEntry(domain)
+	if entry != nil && entry.RenewBefore != "" {
+		if d, err := time.ParseDuration(entry.RenewBefore); err == nil {
+			return d, true
 		}
 	}
+	if d, ok := c.GetRenewBefore(c.ProfileFor(domain)); ok {
+		return d, true
+	}
+	if c.renewalDaysOverride(domain) > 0 || c.Certificates.RenewBefore == "" {
+		return 0, false
+	}
+	d, err := time.ParseDuration(c.Certificates.RenewBefore)
+	if err != nil {
+		return 0, false
+	}
+	return d, true
+}
 
-	return nil
+// defaultRenewalWindow returns how long before expiry certificates without
+// overrides are renewed
+func (c *Config) defaultRenewalWindow() time.Duration {
+	if d, err := time.ParseDuration(c.Certificates.RenewBefore); err == nil && d > 0 {
+		return d
+	}
+	return time.Duration(c.Certificates.RenewalDays) * 24 * time.Hour
 }
 
-// setDefaults sets default values for optional fields
-func (c *Config) setDefaults() {
-	if c.ACME.CADirURL == "" {
-		c.ACME.CADirURL = "https://acme-v02.api.letsencrypt.org/directory"
+// GetRenewBefore returns how long before expiry certificates of a profile are
+// renewed, and false when the profile doesn't override certificates.renewal_days
+func (c *Config) GetRenewBefore(profile string) (time.Duration, bool) {
+	p, ok := c.ACME.Profiles[profile]
+	if !ok || p.RenewBefore == "" {
+		return 0, false
 	}
-	if c.ACME.KeyType == "" {
-		c.ACME.KeyType = "RSA2048"
+	d, err := time.ParseDuration(p.RenewBefore)
+	if err != nil {
+		return 0, false
 	}
-	if c.ACME.Email == "" {
-		c.ACME.Email = c.Email
+	return d, true
+}
+
+func (c *Config) GetTraefikTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.Traefik.Timeout)
+}
+
+func (c *Config) GetDriftInterval() (time.Duration, error) {
+	return time.ParseDuration(c.Traefik.Drift.Interval)
+}
+
+func (c *Config) GetRunTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.Scheduler.RunTimeout)
+}
+
+func (c *Config) GetOrderTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.ACME.OrderTimeout)
+}
+
+func (c *Config) GetRetryBackoff() (time.Duration, error) {
+	return time.ParseDuration(c.ACME.RetryBackoff)
+}
+
+func (c *Config) GetStaleOrderAge() (time.Duration, error) {
+	return time.ParseDuration(c.ACME.StaleOrderAge)
+}
+
+func (c *Config) GetStartupBackoff() (time.Duration, error) {
+	return time.ParseDuration(c.App.StartupBackoff)
+}
+
+func (c *Config) GetReconnectInterval() (time.Duration, error) {
+	return time.ParseDuration(c.App.ReconnectInterval)
+}
+
+func (c *Config) GetSDSRefreshInterval() (time.Duration, error) {
+	return time.ParseDuration(c.SDS.RefreshInterval)
+}
+
+func (c *Config) GetOCSPStaplingInterval() (time.Duration, error) {
+	return time.ParseDuration(c.Certificates.OCSPStapling.Interval)
+}
+
+func (c *Config) GetOCSPStaplingTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.Certificates.OCSPStapling.Timeout)
+}
+
+func (c *Config) GetLockTTL() (time.Duration, error) {
+	return time.ParseDuration(c.Certificates.LockTTL)
+}
+
+// GetRenewalJitter returns the renewal spread, zero when it is not configured
+func (c *Config) GetRenewalJitter() (time.Duration, error) {
+	if c.Certificates.RenewalJitter == "" {
+		return 0, nil
 	}
+	return time.ParseDuration(c.Certificates.RenewalJitter)
+}
 
-	if c.Certificates.RenewalDays == 0 {
-		c.Certificates.RenewalDays = 30
+// GetRenewalHours returns the daily renewal window, nil when renewals may run at any time
+func (c *Config) GetRenewalHours() (*DailyWindow, error) {
+	if c.Certificates.RenewalHours == "" {
+		return nil, nil
 	}
-	if c.Certificates.StoragePath == "" {
-		c.Certificates.StoragePath = "./certs"
+	window, err := ParseDailyWindow(c.Certificates.RenewalHours)
+	if err != nil {
+		return nil, err
 	}
+	return &window, nil
+}
 
-	if c.App.LogLevel == "" {
-		c.App.LogLevel = "info"
+// GetNotBeforeSkew returns how far in the future a newly issued certificate's
+// NotBefore may lie before it is rejected, zero when it is not configured
+func (c *Config) GetNotBeforeSkew() (time.Duration, error) {
+	if c.Certificates.NotBeforeSkew == "" {
+		return 0, nil
 	}
-	if c.App.CheckInterval == "" {
-		c.App.CheckInterval = "24h"
+	return time.ParseDuration(c.Certificates.NotBeforeSkew)
+}
+
+// GetMinFreeSpace returns the free space in MB and the free inodes below which
+// issuance is refused, 0 where the check is disabled
+func (c *Config) GetMinFreeSpace() (megabytes, inodes int) {
+	if c.Certificates.MinFreeSpaceMB != nil {
+		megabytes = *c.Certificates.MinFreeSpaceMB
 	}
-	if c.App.Timeout == "" {
-		c.App.Timeout = "30s"
+	if c.Certificates.MinFreeInodes != nil {
+		inodes = *c.Certificates.MinFreeInodes
 	}
+	return megabytes, inodes
+}
 
-	if c.Notification.From == "" {
-		c.Notification.From = "noreply@example.com"
+func (c *Config) GetHookTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.Hooks.Timeout)
+}
+
+// GetInternalCAValidity returns the lifetimes of certificates issued by the
+// internal CA and of the CA certificate itself
+func (c *Config) GetInternalCAValidity() (validity, caValidity time.Duration, err error) {
+	if validity, err = time.ParseDuration(c.Certificates.InternalCA.Validity); err != nil {
+		return 0, 0, err
 	}
+	caValidity, err = time.ParseDuration(c.Certificates.InternalCA.CAValidity)
+	return validity, caValidity, err
 }
 
-func (c *Config) GetCheckInterval() (time.Duration, error) {
-	return time.ParseDuration(c.App.CheckInterval)
+func (c *Config) GetDedupWindow() (time.Duration, error) {
+	return time.ParseDuration(c.Notification.DedupWindow)
+}
+
+// GetDigestTime returns the time of day the notification digest is sent, in
+// minutes after midnight
+func (c *Config) GetDigestTime() (int, error) {
+	return parseClock(c.Notification.DigestTime)
+}
+
+// GetReportSchedule returns the weekday and the time of day, in minutes after
+// midnight, the weekly report is sent
+func (c *Config) GetReportSchedule() (time.Weekday, int, error) {
+	day, err := parseWeekday(c.Report.Day)
+	if err != nil {
+		return 0, 0, err
+	}
+	at, err := parseClock(c.Report.Time)
+	return day, at, err
+}
+
+func (c *Config) GetInventoryTimeout() (time.Duration, error) {
+	return time.ParseDuration(c.Inventory.Timeout)
+}
+
+// GetDatabaseRetention returns how long history is kept, zero to keep all of it
+func (c *Config) GetDatabaseRetention() (time.Duration, error) {
+	if c.Database.Retention == "" {
+		return 0, nil
+	}
+	return time.ParseDuration(c.Database.Retention)
 }
 
-func (c *Config) GetTimeout() (time.Duration, error) {
-	return time.ParseDuration(c.App.Timeout)
+// DatabaseDSN returns the connection string of the history database
+func (c *Config) DatabaseDSN() string {
+	if c.Database.DSN == "" && c.Database.Driver == DatabaseSQLite {
+		return filepath.Join(c.Certificates.StoragePath, "history.db")
+	}
+	return c.Database.DSN
 }
 
 func (c *Config) GetCertPath(domain string) string {
-	return filepath.Join(c.Certificates.StoragePath, domain+".crt")
+	return filepath.Join(c.Certificates.StoragePath, certFileName(domain)+".crt")
 }
 
 func (c *Config) GetKeyPath(domain string) string {
-	return filepath.Join(c.Certificates.StoragePath, domain+".key")
+	return filepath.Join(c.Certificates.StoragePath, certFileName(domain)+".key")
+}
+
+// certFileName mirrors the storage encoding used by the certificate manager,
+// where wildcard domains are stored with a leading "_"
+func certFileName(domain string) string {
+	if strings.HasPrefix(domain, "*.") {
+		return "_" + domain[1:]
+	}
+	return domain
 }
 
 // GetAllDomains returns all configured domains including aliases
@@ -174,6 +2378,96 @@ func (c *Config) GetAllDomains() []string {
 	return domains
 }
 
+// WWWRedirect is a name whose requests should be redirected to its paired canonical name
+type WWWRedirect struct {
+	From string
+	To   string
+}
+
+func validWWWPairing(mode string) bool {
+	switch mode {
+	case "", "none", "both", "redirect_to_apex", "redirect_to_www":
+		return true
+	}
+	return false
+}
+
+// wwwPairing returns the pair_www mode of a domain. The global setting only
+// applies to www names and names with two labels, since deeper names such as
+// api.example.com rarely have a www variant.
+func (c *Config) wwwPairing(domain Domain) string {
+	if domain.PairWWW != "" {
+		return domain.PairWWW
+	}
+	if strings.HasPrefix(domain.Domain, "www.") || strings.Count(domain.Domain, ".") == 1 {
+		return c.Certificates.PairWWW
+	}
+	return ""
+}
+
+// wwwCounterpart returns the apex of a www name or the www name of an apex
+func wwwCounterpart(domain string) string {
+	if strings.HasPrefix(domain, "*.") || !strings.Contains(domain, ".") {
+		return ""
+	}
+	if apex, ok := strings.CutPrefix(domain, "www."); ok {
+		return apex
+	}
+	return "www." + domain
+}
+
+// pairWWW adds the apex or www partner of each paired domain as an alias,
+// unless it is already configured
+func (c *Config) pairWWW() {
+	configured := make(map[string]bool)
+	for _, domain := range c.GetAllDomains() {
+		configured[domain] = true
+	}
+
+	for i, domain := range c.Domains {
+		switch c.wwwPairing(domain) {
+		case "", "none":
+			continue
+		}
+		if partner := wwwCounterpart(domain.Domain); partner != "" && !configured[partner] {
+			c.Domains[i].Aliases = append(c.Domains[i].Aliases, partner)
+			configured[partner] = true
+		}
+	}
+}
+
+// WWWRedirects lists the redirects implied by the pair_www settings
+func (c *Config) WWWRedirects() []WWWRedirect {
+	var redirects []WWWRedirect
+	seen := make(map[string]bool)
+	for _, domain := range c.Domains {
+		partner := wwwCounterpart(domain.Domain)
+		if partner == "" {
+			continue
+		}
+
+		apex, www := domain.Domain, partner
+		if strings.HasPrefix(domain.Domain, "www.") {
+			apex, www = partner, domain.Domain
+		}
+
+		var redirect WWWRedirect
+		switch c.wwwPairing(domain) {
+		case "redirect_to_apex":
+			redirect = WWWRedirect{From: www, To: apex}
+		case "redirect_to_www":
+			redirect = WWWRedirect{From: apex, To: www}
+		default:
+			continue
+		}
+		if !seen[redirect.From] {
+			seen[redirect.From] = true
+			redirects = append(redirects, redirect)
+		}
+	}
+	return redirects
+}
+
 func (c *Config) GetDomainForService(serviceName string) (string, bool) {
 	for _, domainConfig := range c.Domains {
 		if domainConfig.Service == serviceName {
@@ -181,4 +2475,91 @@ func (c *Config) GetDomainForService(serviceName string) (string, bool) {
 		}
 	}
 	return "", false
-}
\ No newline at end of file
+}
+
+// Hash returns a deterministic SHA-256 of the effective configuration so
+// instances can be compared for drift. Domain and alias order is ignored.
+func (c *Config) Hash() string {
+	canonical := *c
+	canonical.Domains = make([]Domain, len(c.Domains))
+	for i, domain := range c.Domains {
+		domain.Aliases = append([]string(nil), domain.Aliases...)
+		sort.Strings(domain.Aliases)
+		domain.SANs = append([]string(nil), domain.SANs...)
+		sort.Strings(domain.SANs)
+		canonical.Domains[i] = domain
+	}
+	sort.Slice(canonical.Domains, func(i, j int) bool {
+		return canonical.Domains[i].Domain < canonical.Domains[j].Domain
+	})
+
+	// encoding/json emits struct fields in declaration order, so the output is stable
+	data, err := json.Marshal(canonical)
+	if err != nil {
+		return ""
+	}
+
+	sum := sha256.Sum256(data)
+	return hex.EncodeToString(sum[:])
+}
+
+// DailyWindow is a time-of-day range in minutes after midnight. A window whose
+// end is before its start wraps past midnight.
+type DailyWindow struct {
+	Start int
+	End   int
+}
+
+// ParseDailyWindow parses a range such as "02:00-05:00" or "22:00-04:00"
+func ParseDailyWindow(s string) (DailyWindow, error) {
+	from, to, ok := strings.Cut(s, "-")
+	if !ok {
+		return DailyWindow{}, fmt.Errorf("expected HH:MM-HH:MM, got %q", s)
+	}
+
+	start, err := parseClock(strings.TrimSpace(from))
+	if err != nil {
+		return DailyWindow{}, err
+	}
+	end, err := parseClock(strings.TrimSpace(to))
+	if err != nil {
+		return DailyWindow{}, err
+	}
+	if start == end {
+		return DailyWindow{}, fmt.Errorf("window %q is empty", s)
+	}
+
+	return DailyWindow{Start: start, End: end}, nil
+}
+
+// Contains reports whether t falls inside the window, in t's location
+func (w DailyWindow) Contains(t time.Time) bool {
+	minute := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return minute >= w.Start && minute < w.End
+	}
+	return minute >= w.Start || minute < w.End
+}
+
+// Duration returns the length of the window
+func (w DailyWindow) Duration() time.Duration {
+	return time.Duration((w.End-w.Start+24*60)%(24*60)) * time.Minute
+}
+
+// parseWeekday parses an English weekday name, e.g. "monday"
+func parseWeekday(s string) (time.Weekday, error) {
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if strings.EqualFold(s, day.String()) {
+			return day, nil
+		}
+	}
+	return 0, fmt.Errorf("invalid weekday %q", s)
+}
+
+func parseClock(s string) (int, error) {
+	t, err := time.Parse("15:04", s)
+	if err != nil {
+		return 0, fmt.Errorf("invalid time of day %q, expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
@@ -3,6 +3,7 @@ package config
 import (
 	"os"
 	"path/filepath"
+	"reflect"
 	"strings"
 	"testing"
 	"time"
@@ -155,6 +156,97 @@ domains:
 		t.Errorf("Expected default StoragePath to be './certs', got '%s'", config.Certificates.StoragePath)
 	}
 
+	if megabytes, inodes := config.GetMinFreeSpace(); megabytes != 10 || inodes != 100 {
+		t.Errorf("Expected default free space thresholds of 10 MB and 100 inodes, got %d and %d", megabytes, inodes)
+	}
+
+	if config.Certificates.ChainWarningDays != 60 {
+		t.Errorf("Expected default ChainWarningDays to be 60, got %d", config.Certificates.ChainWarningDays)
+	}
+
+	if config.Certificates.NotBeforeSkew != "5m" {
+		t.Errorf("Expected default NotBeforeSkew to be 5m, got %s", config.Certificates.NotBeforeSkew)
+	}
+
+	if config.Notification.DedupWindow != "24h" || config.Notification.DigestTime != "08:00" {
+		t.Errorf("Expected default dedup window 24h and digest time 08:00, got %s and %s",
+			config.Notification.DedupWindow, config.Notification.DigestTime)
+	}
+
+	if config.Notification.TLS != "starttls" || config.Notification.Auth != "plain" {
+		t.Errorf("Expected default SMTP TLS starttls and auth plain, got %s and %s",
+			config.Notification.TLS, config.Notification.Auth)
+	}
+
+	if e := config.Notification.Escalation; e.WarningDays != 14 || e.CriticalDays != 3 || e.PageDays != 1 {
+		t.Errorf("Expected default escalation at 14, 3 and 1 days, got %+v", e)
+	}
+
+	if config.ACME.Challenge != "http-01" {
+		t.Errorf("Expected default Challenge to be http-01, got %s", config.ACME.Challenge)
+	}
+
+	if config.ACME.StaleOrderAge != "24h" {
+		t.Errorf("Expected default StaleOrderAge to be 24h, got %s", config.ACME.StaleOrderAge)
+	}
+
+	if config.App.RunHistory != 30 {
+		t.Errorf("Expected default run history to be 30, got %d", config.App.RunHistory)
+	}
+	if config.App.LogFile.MaxSizeMB != 100 {
+		t.Errorf("Expected default log file size to be 100 MB, got %d", config.App.LogFile.MaxSizeMB)
+	}
+
+	if config.ACME.WireLog.MaxSizeMB != 10 || config.ACME.WireLog.MaxBackups != 3 {
+		t.Errorf("Expected default wire log rotation to be 10 MB with 3 backups, got %d MB with %d", config.ACME.WireLog.MaxSizeMB, config.ACME.WireLog.MaxBackups)
+	}
+
+	if config.ACME.DuplicateLimit != 5 {
+		t.Errorf("Expected default DuplicateLimit to be 5, got %d", config.ACME.DuplicateLimit)
+	}
+	if config.ACME.RetryAttempts != 3 {
+		t.Errorf("Expected default RetryAttempts to be 3, got %d", config.ACME.RetryAttempts)
+	}
+	if config.ACME.RetryBackoff != "10s" {
+		t.Errorf("Expected default RetryBackoff to be '10s', got '%s'", config.ACME.RetryBackoff)
+	}
+
+	if config.Hooks.Timeout != "60s" {
+		t.Errorf("Expected default hooks Timeout to be '60s', got '%s'", config.Hooks.Timeout)
+	}
+
+	if config.Inventory.Timeout != "30s" {
+		t.Errorf("Expected default inventory Timeout to be '30s', got '%s'", config.Inventory.Timeout)
+	}
+
+	if config.Metrics.ListenAddress != ":9090" {
+		t.Errorf("Expected default metrics ListenAddress to be ':9090', got '%s'", config.Metrics.ListenAddress)
+	}
+	if config.App.StartupRetries != 5 {
+		t.Errorf("Expected default StartupRetries to be 5, got %d", config.App.StartupRetries)
+	}
+	if config.Scheduler.RunTimeout != "1h" {
+		t.Errorf("Expected default RunTimeout to be '1h', got '%s'", config.Scheduler.RunTimeout)
+	}
+	if config.App.ReconnectInterval != "30s" {
+		t.Errorf("Expected default ReconnectInterval to be '30s', got '%s'", config.App.ReconnectInterval)
+	}
+	if config.Health.ListenAddress != ":8081" {
+		t.Errorf("Expected default health ListenAddress to be ':8081', got '%s'", config.Health.ListenAddress)
+	}
+	if config.API.ListenAddress != "127.0.0.1:8082" {
+		t.Errorf("Expected default API ListenAddress to be '127.0.0.1:8082', got '%s'", config.API.ListenAddress)
+	}
+	if config.API.IdempotencyTTL != "24h" {
+		t.Errorf("Expected default API IdempotencyTTL to be '24h', got '%s'", config.API.IdempotencyTTL)
+	}
+	if config.Discovery.Vhosts.Interval != "5m" {
+		t.Errorf("Expected default vhost discovery Interval to be '5m', got '%s'", config.Discovery.Vhosts.Interval)
+	}
+	if config.Discovery.DNSZones.Interval != "15m" {
+		t.Errorf("Expected default DNS zone discovery Interval to be '15m', got '%s'", config.Discovery.DNSZones.Interval)
+	}
+
 	if config.App.LogLevel != "info" {
 		t.Errorf("Expected default LogLevel to be 'info', got '%s'", config.App.LogLevel)
 	}
@@ -175,72 +267,622 @@ func TestConfigValidation(t *testing.T) {
 		expectedError  string
 	}{
 		{
-			name: "missing traefik_api",
+			name: "missing traefik_api",
+			config: Config{
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+			},
+			expectedError: "traefik_api is required",
+		},
+		{
+			name: "missing email",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+			},
+			expectedError: "email is required",
+		},
+		{
+			name: "missing smtp_host",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+			},
+			expectedError: "notification.smtp_host is required",
+		},
+		{
+			name: "missing smtp_port",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com"},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+			},
+			expectedError: "notification.smtp_port is required",
+		},
+		{
+			name: "no domains",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{},
+			},
+			expectedError: "at least one domain configuration is required",
+		},
+		{
+			name: "domain missing service",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Domain: "example.com"}},
+			},
+			expectedError: "domain[0].service is required",
+		},
+		{
+			name: "domain missing domain",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web"}},
+			},
+			expectedError: "domain[0].domain is required",
+		},
+		{
+			name: "deploy target missing key_path",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com", Deploy: []DeployTarget{{
+					Host: "edge1", User: "deploy", PrivateKeyFile: "/keys/id", CertPath: "/etc/ssl/web.crt",
+				}}}},
+			},
+			expectedError: "domain[0].deploy[0]: cert_path and key_path are required",
+		},
+		{
+			name: "deploy target invalid method",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com", Deploy: []DeployTarget{{
+					Host: "edge1", User: "deploy", PrivateKeyFile: "/keys/id",
+					CertPath: "/etc/ssl/web.crt", KeyPath: "/etc/ssl/web.key", Method: "rsync",
+				}}}},
+			},
+			expectedError: "domain[0].deploy[0]: method must be sftp or scp",
+		},
+		{
+			name: "switch webhook without scheme",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com", Switch: SwitchWebhooks{PreSwitch: "drain.example.com/hook"}}},
+			},
+			expectedError: "domain[0].switch_webhooks: pre_switch must be an http or https URL",
+		},
+		{
+			name: "unknown switch failure policy",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com", Switch: SwitchWebhooks{PostSwitch: "https://cdn.example.com/purge", FailurePolicy: "retry"}}},
+			},
+			expectedError: "domain[0].switch_webhooks: failure_policy must be abort or continue",
+		},
+		{
+			name: "plain http DoH resolver",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				DNS: DNS{DoHResolvers: []string{"http://dns.example/dns-query"}},
+			},
+			expectedError: "dns.doh_resolvers[0] must be an https:// URL",
+		},
+		{
+			name: "renewal jitter longer than renewal window",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{RenewalDays: 10, RenewalJitter: "240h"},
+			},
+			expectedError: "certificates.renewal_jitter must be positive and shorter than renewal_days",
+		},
+		{
+			name: "malformed renewal hours",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{RenewalHours: "2am-5am"},
+			},
+			expectedError: `certificates.renewal_hours is invalid: invalid time of day "2am", expected HH:MM`,
+		},
+		{
+			name: "DNS zone discovery without zones",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Discovery: Discovery{DNSZones: DNSZoneDiscovery{Enabled: true, Provider: "route53", Issue: true}},
+			},
+			expectedError: "discovery.dns_zones.zones is required when DNS zone discovery is enabled",
+		},
+		{
+			name: "DNS zone discovery with unknown provider",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Discovery: Discovery{DNSZones: DNSZoneDiscovery{Enabled: true, Provider: "gandi", Zones: []string{"example.com"}}},
+			},
+			expectedError: "discovery.dns_zones.provider must be route53 or cloudflare",
+		},
+		{
+			name: "unknown www pairing",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com", PairWWW: "always"}},
+			},
+			expectedError: "domain[0].pair_www must be none, both, redirect_to_apex or redirect_to_www",
+		},
+		{
+			name: "negative NotBefore skew",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{NotBeforeSkew: "-1m"},
+			},
+			expectedError: "certificates.not_before_skew must not be negative",
+		},
+		{
+			name: "zero run timeout",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				App: App{RunTimeout: "0s"},
+			},
+			expectedError: "app.run_timeout must be positive",
+		},
+		{
+			name: "order timeout beyond the run timeout",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				ACME: ACME{OrderTimeout: "2h"},
+			},
+			expectedError: "acme.order_timeout (2h0m0s) exceeds the run timeout (1h0m0s)",
+		},
+		{
+			name: "deprecated traefik timeout alongside its replacement",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Traefik: Traefik{Timeout: "10s"},
+				App: App{Timeout: "30s"},
+			},
+			expectedError: "app.timeout is deprecated and can't be combined with traefik.timeout",
+		},
+		{
+			name: "inventory endpoint and command",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Inventory: Inventory{Endpoint: "https://cmdb.example.com/api", Command: "cmdb-sync"},
+			},
+			expectedError: "inventory.endpoint and inventory.command are mutually exclusive",
+		},
+		{
+			name: "postgres database without dsn",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Database: Database{Driver: "postgres"},
+			},
+			expectedError: "database.dsn is required for postgres",
+		},
+		{
+			name: "tracing endpoint without scheme",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Tracing: Tracing{Endpoint: "jaeger:4318"},
+			},
+			expectedError: "tracing.endpoint must be an http:// or https:// URL",
+		},
+		{
+			name: "report without destination",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Report: Report{Enabled: true},
+			},
+			expectedError: "report needs email, webhook_url or directory to be delivered",
+		},
+		{
+			name: "report on unknown weekday",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Report: Report{Enabled: true, Email: true, Day: "mon"},
+			},
+			expectedError: "report.day: invalid weekday \"mon\"",
+		},
+		{
+			name: "traefik provider without api token",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				API: API{Enabled: true, TraefikProvider: true},
+			},
+			expectedError: "api.token is required with api.traefik_provider, which serves private keys",
+		},
+		{
+			name: "sds reachable from other hosts without client ca",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				SDS: SDS{Enabled: true, ListenAddress: "0.0.0.0:18000", TLSCertFile: "sds.crt", TLSKeyFile: "sds.key"},
+			},
+			expectedError: "sds.listen_address 0.0.0.0:18000 is reachable from other hosts and requires sds.client_ca_file, as the secrets include private keys",
+		},
+		{
+			name: "debug endpoints reachable from other hosts",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Debug: Debug{Enabled: true, ListenAddress: ":6060"},
+			},
+			expectedError: "debug.listen_address :6060 must be a loopback address, as the debug endpoints are unauthenticated",
+		},
+		{
+			name: "http-01 listen address without port",
+			config: Config{
+				TraefikAPI:   "http://localhost:8080/api",
+				Email:        "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains:      []Domain{{Service: "web", Domain: "example.com"}},
+				ACME:         ACME{HTTP01: HTTP01{ListenAddress: "10.0.0.5:0"}},
+			},
+			expectedError: "acme.http01.listen_address must have a port from 1 to 65535",
+		},
+		{
+			name: "http-01 proxy header with spaces",
+			config: Config{
+				TraefikAPI:   "http://localhost:8080/api",
+				Email:        "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains:      []Domain{{Service: "web", Domain: "example.com"}},
+				ACME:         ACME{HTTP01: HTTP01{ProxyHeader: "X Forwarded Host"}},
+			},
+			expectedError: `acme.http01.proxy_header "X Forwarded Host" is not a header name`,
+		},
+		{
+			name: "unknown consolidation policy",
+			config: Config{
+				TraefikAPI:   "http://localhost:8080/api",
+				Email:        "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains:      []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{Consolidation: Consolidation{Policy: "san"}},
+			},
+			expectedError: "certificates.consolidation.policy must be per-domain, consolidate or wildcard",
+		},
+		{
+			name: "wildcard consolidation without dns-01",
+			config: Config{
+				TraefikAPI:   "http://localhost:8080/api",
+				Email:        "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains:      []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{Consolidation: Consolidation{Policy: ConsolidateWildcard}},
+			},
+			expectedError: "acme.dns01_command or acme.dns01_webhook is required for the dns-01 challenge",
+		},
+		{
+			name: "san certified by another domain",
+			config: Config{
+				TraefikAPI:   "http://localhost:8080/api",
+				Email:        "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{
+					{Service: "web", Domain: "example.com", SANs: []string{"api.example.com"}},
+					{Service: "api", Domain: "api.example.com"},
+				},
+			},
+			expectedError: "domain[0] and domain[1] both include api.example.com",
+		},
+		{
+			name: "sds client ca without server certificate",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				SDS: SDS{Enabled: true, ListenAddress: "unix:/run/cert-manager/sds.sock", ClientCAFile: "ca.crt"},
+			},
+			expectedError: "sds.client_ca_file requires sds.tls_cert_file and sds.tls_key_file",
+		},
+		{
+			name: "negative prune grace period",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{Prune: Prune{Enabled: true, GraceDays: -1}},
+			},
+			expectedError: "certificates.prune.grace_days must not be negative",
+		},
+		{
+			name: "invalid bulk issuance backoff",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				ACME: ACME{BulkIssuance: BulkIssuance{Enabled: true, RateLimitBackoff: "0s"}},
+			},
+			expectedError: "acme.bulk_issuance.rate_limit_backoff must be positive",
+		},
+		{
+			name: "negative drift interval",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Traefik: Traefik{Drift: Drift{Enabled: true, Interval: "-1h"}},
+			},
+			expectedError: "traefik.drift.interval must be positive",
+		},
+		{
+			name: "traefik tls options with unknown cipher suite",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				TraefikTLS: TraefikTLS{Options: map[string]TLSOptions{"default": {CipherSuites: []string{"TLS_RSA_WITH_RC5"}}}},
+			},
+			expectedError: `traefik_tls.options.default: unknown cipher suite "TLS_RSA_WITH_RC5"`,
+		},
+		{
+			name: "traefik tls options with min version above max",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				TraefikTLS: TraefikTLS{Options: map[string]TLSOptions{"modern": {MinVersion: "VersionTLS13", MaxVersion: "VersionTLS12"}}},
+			},
+			expectedError: "traefik_tls.options.modern: min_version is above max_version",
+		},
+		{
+			name: "escalation tiers out of order",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587,
+					Escalation: Escalation{WarningDays: 7, CriticalDays: 10}},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+			},
+			expectedError: "notification.escalation must have warning_days >= critical_days >= page_days",
+		},
+		{
+			name: "combined PEM with encryption",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Certificates: Certificates{CombinedPEM: true,
+					Encryption: Encryption{Provider: "age", Age: AgeEncryption{Recipients: []string{"age1xyz"}, IdentityFile: "/etc/age.key"}}},
+			},
+			expectedError: "certificates.combined_pem would store private keys in plaintext and can't be used with certificates.encryption",
+		},
+		{
+			name: "invalid SMTP TLS mode",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 465, TLS: "ssl"},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+			},
+			expectedError: `notification.tls must be starttls, implicit or none, got "ssl"`,
+		},
+		{
+			name: "invalid domain key type",
 			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
 				Email: "test@example.com",
 				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
-				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Domains: []Domain{{Service: "web", Domain: "example.com", KeyType: "RSA1024"}},
 			},
-			expectedError: "traefik_api is required",
+			expectedError: "domain[0]: key_type must be RSA2048, RSA4096, EC256 or EC384",
 		},
 		{
-			name: "missing email",
+			name: "dns-01 without command",
 			config: Config{
 				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
 				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
-				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				Domains: []Domain{{Service: "web", Domain: "*.example.com", Challenge: "dns-01"}},
 			},
-			expectedError: "email is required",
+			expectedError: "acme.dns01_command or acme.dns01_webhook is required for the dns-01 challenge",
 		},
 		{
-			name: "missing smtp_host",
+			name: "dns-01 webhook with command",
 			config: Config{
 				TraefikAPI: "http://localhost:8080/api",
 				Email: "test@example.com",
-				Notification: Notification{SMTPPort: 587},
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "*.example.com", Challenge: "dns-01"}},
+				ACME: ACME{DNS01Command: "/usr/local/bin/dns-hook", DNS01Webhook: DNS01Webhook{
+					PresentURL: "https://dns.example.com/present", CleanupURL: "https://dns.example.com/cleanup",
+					Timeout: "30s", PropagationTimeout: "60s", PollingInterval: "2s",
+				}},
+			},
+			expectedError: "acme.dns01_command and acme.dns01_webhook are mutually exclusive",
+		},
+		{
+			name: "dns-01 webhook without cleanup URL",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "*.example.com", Challenge: "dns-01"}},
+				ACME: ACME{DNS01Webhook: DNS01Webhook{PresentURL: "https://dns.example.com/present"}},
+			},
+			expectedError: "acme.dns01_webhook.cleanup_url must be an http or https URL",
+		},
+		{
+			name: "invalid profile renewal window",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
 				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				ACME: ACME{Profiles: map[string]ACMEProfile{"shortlived": {RenewBefore: "3d"}}},
 			},
-			expectedError: "notification.smtp_host is required",
+			expectedError: `acme.profiles.shortlived: renew_before is invalid: time: unknown unit "d" in duration "3d"`,
 		},
 		{
-			name: "missing smtp_port",
+			name: "negative wire log rotation",
 			config: Config{
 				TraefikAPI: "http://localhost:8080/api",
 				Email: "test@example.com",
-				Notification: Notification{SMTPHost: "smtp.test.com"},
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
 				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				ACME: ACME{WireLog: ACMEWireLog{MaxSizeMB: -1}},
 			},
-			expectedError: "notification.smtp_port is required",
+			expectedError: "acme.wire_log.max_size_mb and max_backups must not be negative",
 		},
 		{
-			name: "no domains",
+			name: "invalid CSR key usage",
 			config: Config{
 				TraefikAPI: "http://localhost:8080/api",
 				Email: "test@example.com",
 				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
-				Domains: []Domain{},
+				Domains: []Domain{{Service: "web", Domain: "example.com", CSR: CSRTemplate{KeyUsage: []string{"cert_sign"}}}},
 			},
-			expectedError: "at least one domain configuration is required",
+			expectedError: `domain[0]: csr.key_usage must be digital_signature, key_encipherment or key_agreement, got "cert_sign"`,
 		},
 		{
-			name: "domain missing service",
+			name: "key file and CSR file",
 			config: Config{
 				TraefikAPI: "http://localhost:8080/api",
 				Email: "test@example.com",
 				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
-				Domains: []Domain{{Domain: "example.com"}},
+				Domains: []Domain{{Service: "web", Domain: "example.com", KeyFile: "/etc/keys/example.com.key", CSRFile: "/etc/keys/example.com.csr"}},
 			},
-			expectedError: "domain[0].service is required",
+			expectedError: "domain[0]: key_file and csr_file are mutually exclusive",
 		},
 		{
-			name: "domain missing domain",
+			name: "internal CA with ACME options",
 			config: Config{
 				TraefikAPI: "http://localhost:8080/api",
 				Email: "test@example.com",
 				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
-				Domains: []Domain{{Service: "web"}},
+				Domains: []Domain{{Service: "lab", Domain: "*.lab.local", Issuer: "internal-ca", CADirURL: "https://ca.example.com/directory"}},
+				Certificates: Certificates{InternalCA: InternalCA{Validity: "2160h", CAValidity: "87600h"}},
 			},
-			expectedError: "domain[0].domain is required",
+			expectedError: "domain[0]: challenge, ca_dir_url, profile, must_staple and csr_file can't be used with issuer internal-ca",
+		},
+		{
+			name: "internal CA validity within renewal window",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "lab", Domain: "*.lab.local", Issuer: "internal-ca"}},
+				Certificates: Certificates{RenewalDays: 30, InternalCA: InternalCA{Validity: "240h", CAValidity: "87600h"}},
+			},
+			expectedError: "certificates.internal_ca.validity must be longer than certificates.renewal_days or renew_before",
+		},
+		{
+			name: "renewal days and renew before on one domain",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com", RenewalDays: 10, RenewBefore: "8h"}},
+			},
+			expectedError: "domain[0]: renewal_days and renew_before are mutually exclusive",
+		},
+		{
+			name: "missing ACME CA certificate",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				ACME: ACME{CACert: "/nonexistent/root_ca.crt"},
+			},
+			expectedError: "acme.ca_cert is not accessible: stat /nonexistent/root_ca.crt: no such file or directory",
+		},
+		{
+			name: "account key version missing",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				ACME: ACME{AccountKeyProvider: "gcp-kms", AccountKey: AccountKey{GCPKMS: GCPKMS{KeyName: "projects/p/locations/global/keyRings/r/cryptoKeys/acme"}}},
+			},
+			expectedError: "acme.account_key.gcp_kms.key_name must name a crypto key version",
+		},
+		{
+			name: "invalid log rotation interval",
+			config: Config{
+				TraefikAPI: "http://localhost:8080/api",
+				Email: "test@example.com",
+				Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+				Domains: []Domain{{Service: "web", Domain: "example.com"}},
+				App: App{LogFile: LogFile{Path: "/var/log/cert-manager.log", RotateInterval: "daily"}},
+			},
+			expectedError: `app.log_file.rotate_interval is invalid: time: invalid duration "daily"`,
 		},
 	}
 
@@ -256,6 +898,216 @@ func TestConfigValidation(t *testing.T) {
 	}
 }
 
+func TestACMEProfiles(t *testing.T) {
+	config := &Config{
+		Domains: []Domain{
+			{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"}, Profile: "shortlived"},
+			{Service: "api", Domain: "api.example.com"},
+		},
+		ACME: ACME{
+			Profile: "classic",
+			Profiles: map[string]ACMEProfile{
+				"shortlived": {RenewBefore: "72h", CheckInterval: "1h"},
+			},
+		},
+		App: App{CheckInterval: "12h"},
+	}
+
+	if got := config.ProfileFor("www.example.com"); got != "shortlived" {
+		t.Errorf("ProfileFor(alias) = %q, want shortlived", got)
+	}
+	if got := config.ProfileFor("api.example.com"); got != "classic" {
+		t.Errorf("ProfileFor(api.example.com) = %q, want the acme.profile default", got)
+	}
+
+	if d, ok := config.GetRenewBefore("shortlived"); !ok || d != 72*time.Hour {
+		t.Errorf("GetRenewBefore(shortlived) = %v, %v", d, ok)
+	}
+	if _, ok := config.GetRenewBefore("classic"); ok {
+		t.Errorf("GetRenewBefore(classic) should fall back to renewal_days")
+	}
+
+	if interval, _ := config.GetCheckInterval(); interval != time.Hour {
+		t.Errorf("GetCheckInterval() = %v, want the profile's 1h", interval)
+	}
+	config.Domains[0].Profile = ""
+	if interval, _ := config.GetCheckInterval(); interval != 12*time.Hour {
+		t.Errorf("GetCheckInterval() = %v, want 12h once no domain uses the profile", interval)
+	}
+}
+
+func TestRenewBeforeFor(t *testing.T) {
+	config := &Config{
+		Domains: []Domain{
+			{Service: "web", Domain: "example.com", RenewBefore: "8h"},
+			{Service: "api", Domain: "api.example.com", RenewalDays: 10},
+			{Service: "shop", Domain: "shop.example.com", Profile: "shortlived"},
+			{Service: "docs", Domain: "docs.example.com"},
+		},
+		ACME: ACME{
+			Profiles: map[string]ACMEProfile{"shortlived": {RenewBefore: "72h"}},
+		},
+		Certificates: Certificates{RenewalDays: 30, RenewBefore: "16h"},
+		App:          App{CheckInterval: "24h"},
+	}
+
+	tests := []struct {
+		domain string
+		want   time.Duration
+		ok     bool
+	}{
+		{"example.com", 8 * time.Hour, true},
+		{"api.example.com", 0, false},
+		{"shop.example.com", 72 * time.Hour, true},
+		{"docs.example.com", 16 * time.Hour, true},
+	}
+	for _, tt := range tests {
+		if got, ok := config.RenewBeforeFor(tt.domain); got != tt.want || ok != tt.ok {
+			t.Errorf("RenewBeforeFor(%s) = %v, %v; want %v, %v", tt.domain, got, ok, tt.want, tt.ok)
+		}
+	}
+
+	if interval, _ := config.GetCheckInterval(); interval != 2*time.Hour {
+		t.Errorf("GetCheckInterval() = %v, want a quarter of the shortest renew_before", interval)
+	}
+}
+
+func TestDomainOverrides(t *testing.T) {
+	config := &Config{
+		Email: "ops@example.com",
+		Domains: []Domain{
+			{
+				Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"},
+				RenewalDays: 14, KeyType: "EC256", Challenge: "dns-01",
+				CADirURL:   "https://acme-staging-v02.api.letsencrypt.org/directory",
+				Recipients: []string{"web@example.com"},
+			},
+			{Service: "api", Domain: "api.example.com"},
+		},
+	}
+	config.setDefaults()
+
+	if got := config.RenewalDaysFor("www.example.com"); got != 14 {
+		t.Errorf("RenewalDaysFor(alias) = %d, want 14", got)
+	}
+	if got := config.KeyTypeFor("example.com"); got != "EC256" {
+		t.Errorf("KeyTypeFor() = %s, want EC256", got)
+	}
+	if got := config.ChallengeFor("example.com"); got != "dns-01" {
+		t.Errorf("ChallengeFor() = %s, want dns-01", got)
+	}
+	if got := config.CADirURLFor("example.com"); got != config.Domains[0].CADirURL {
+		t.Errorf("CADirURLFor() = %s, want the domain's CA", got)
+	}
+	if got := config.RecipientsFor("example.com"); len(got) != 1 || got[0] != "web@example.com" {
+		t.Errorf("RecipientsFor() = %v, want the domain's recipients", got)
+	}
+
+	// Domains without overrides, and unknown ones, get the global settings
+	for _, domain := range []string{"api.example.com", "other.example.com"} {
+		if got := config.RenewalDaysFor(domain); got != 30 {
+			t.Errorf("RenewalDaysFor(%s) = %d, want 30", domain, got)
+		}
+		if got := config.KeyTypeFor(domain); got != "RSA2048" {
+			t.Errorf("KeyTypeFor(%s) = %s, want RSA2048", domain, got)
+		}
+		if got := config.ChallengeFor(domain); got != "http-01" {
+			t.Errorf("ChallengeFor(%s) = %s, want http-01", domain, got)
+		}
+		if got := config.CADirURLFor(domain); got != config.ACME.CADirURL {
+			t.Errorf("CADirURLFor(%s) = %s, want acme.ca_dir_url", domain, got)
+		}
+		if got := config.RecipientsFor(domain); len(got) != 1 || got[0] != "ops@example.com" {
+			t.Errorf("RecipientsFor(%s) = %v, want email", domain, got)
+		}
+	}
+}
+
+func TestDomainGroups(t *testing.T) {
+	config := &Config{
+		Email:        "ops@example.com",
+		Certificates: Certificates{RenewalHours: "02:00-05:00"},
+		Groups: map[string]DomainGroup{
+			"production": {Recipients: []string{"oncall@example.com"}, RenewalDays: 20},
+			"staging":    {RenewalHours: "09:00-17:00"},
+		},
+		Domains: []Domain{
+			{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"}, Group: "production"},
+			{Service: "api", Domain: "api.example.com", Group: "production", RenewalDays: 10, Recipients: []string{"api@example.com"}},
+			{Service: "dev", Domain: "dev.example.com", Group: "staging"},
+			{Service: "tools", Domain: "tools.example.com", Group: "internal"},
+		},
+	}
+	config.setDefaults()
+
+	if got := config.DomainsInGroup("production"); !reflect.DeepEqual(got, []string{"example.com", "api.example.com"}) {
+		t.Errorf("DomainsInGroup(production) = %v", got)
+	}
+	if got := config.GroupFor("www.example.com"); got != "production" {
+		t.Errorf("GroupFor(alias) = %q, want production", got)
+	}
+
+	// Settings of the domain take precedence over those of its group
+	if got := config.RecipientsFor("www.example.com"); !reflect.DeepEqual(got, []string{"oncall@example.com"}) {
+		t.Errorf("RecipientsFor(production) = %v, want the group's recipients", got)
+	}
+	if got := config.RecipientsFor("api.example.com"); !reflect.DeepEqual(got, []string{"api@example.com"}) {
+		t.Errorf("RecipientsFor(api) = %v, want the domain's recipients", got)
+	}
+	if got := config.RenewalDaysFor("example.com"); got != 20 {
+		t.Errorf("RenewalDaysFor(production) = %d, want 20", got)
+	}
+	if got := config.RenewalDaysFor("api.example.com"); got != 10 {
+		t.Errorf("RenewalDaysFor(api) = %d, want 10", got)
+	}
+
+	// Groups without settings only tag their domains
+	if got := config.RecipientsFor("tools.example.com"); !reflect.DeepEqual(got, []string{"ops@example.com"}) {
+		t.Errorf("RecipientsFor(internal) = %v, want email", got)
+	}
+	if got := config.RenewalDaysFor("tools.example.com"); got != 30 {
+		t.Errorf("RenewalDaysFor(internal) = %d, want 30", got)
+	}
+
+	noon := time.Date(2030, 1, 1, 12, 0, 0, 0, time.Local)
+	if hours := config.RenewalHoursFor("dev.example.com"); hours == nil || !hours.Contains(noon) {
+		t.Errorf("RenewalHoursFor(staging) = %v, want the group's hours", hours)
+	}
+	if hours := config.RenewalHoursFor("tools.example.com"); hours == nil || hours.Contains(noon) {
+		t.Errorf("RenewalHoursFor(internal) = %v, want certificates.renewal_hours", hours)
+	}
+
+	config.Groups["staging"] = DomainGroup{RenewalHours: "late", Recipients: []string{"dev"}}
+	var reported bool
+	for _, problem := range config.Problems() {
+		reported = reported || strings.HasPrefix(problem.Error(), "groups.staging: renewal_hours is invalid")
+	}
+	if !reported {
+		t.Errorf("Expected invalid group renewal hours to be reported, got %v", config.Problems())
+	}
+}
+
+func TestDualKeyFor(t *testing.T) {
+	config := &Config{
+		Domains: []Domain{
+			{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"}, DualKey: true},
+			{Service: "api", Domain: "api.example.com"},
+		},
+	}
+
+	if !config.DualKeyFor("www.example.com") {
+		t.Error("DualKeyFor(alias) = false, want true")
+	}
+	if config.DualKeyFor("api.example.com") {
+		t.Error("DualKeyFor() = true for a domain without dual_key")
+	}
+
+	config.Certificates.DualKey = true
+	if !config.DualKeyFor("api.example.com") {
+		t.Error("DualKeyFor() = false with certificates.dual_key set")
+	}
+}
+
 func TestConfigHelperMethods(t *testing.T) {
 	config := &Config{
 		Certificates: Certificates{
@@ -267,8 +1119,8 @@ func TestConfigHelperMethods(t *testing.T) {
 		},
 		App: App{
 			CheckInterval: "12h",
-			Timeout: "30s",
 		},
+		Traefik: Traefik{Timeout: "30s"},
 	}
 
 	certPath := config.GetCertPath("example.com")
@@ -310,7 +1162,7 @@ func TestConfigHelperMethods(t *testing.T) {
 		t.Errorf("Expected check interval 12h, got %v", interval)
 	}
 
-	timeout, err := config.GetTimeout()
+	timeout, err := config.GetTraefikTimeout()
 	if err != nil {
 		t.Errorf("Failed to parse timeout: %v", err)
 	}
@@ -319,6 +1171,65 @@ func TestConfigHelperMethods(t *testing.T) {
 	}
 }
 
+func TestTimeoutDefaults(t *testing.T) {
+	tests := []struct {
+		name                string
+		config              Config
+		traefik, run, order string
+	}{
+		{"defaults", Config{}, "30s", "1h", "10m"},
+		{"deprecated app settings", Config{App: App{Timeout: "5s", RunTimeout: "2h"}}, "5s", "2h", "10m"},
+		{"short run", Config{Scheduler: Scheduler{RunTimeout: "5m"}}, "30s", "5m", "5m"},
+		{"explicit", Config{Traefik: Traefik{Timeout: "10s"}, ACME: ACME{OrderTimeout: "20m"}, Scheduler: Scheduler{RunTimeout: "3h"}}, "10s", "3h", "20m"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			tt.config.setDefaults()
+			if tt.config.Traefik.Timeout != tt.traefik {
+				t.Errorf("Expected traefik.timeout %s, got %s", tt.traefik, tt.config.Traefik.Timeout)
+			}
+			if tt.config.Scheduler.RunTimeout != tt.run {
+				t.Errorf("Expected scheduler.run_timeout %s, got %s", tt.run, tt.config.Scheduler.RunTimeout)
+			}
+			if tt.config.ACME.OrderTimeout != tt.order {
+				t.Errorf("Expected acme.order_timeout %s, got %s", tt.order, tt.config.ACME.OrderTimeout)
+			}
+		})
+	}
+}
+
+func TestLoadConfigDisablesFreeSpaceChecks(t *testing.T) {
+	tempDir := t.TempDir()
+	configPath := filepath.Join(tempDir, "config.yaml")
+
+	configContent := `
+traefik_api: "http://localhost:8080/api"
+email: "test@example.com"
+certificates:
+  min_free_space_mb: 0
+  min_free_inodes: 0
+notification:
+  smtp_host: "smtp.test.com"
+  smtp_port: 587
+domains:
+  - service: "web"
+    domain: "example.com"
+`
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to create test config file: %v", err)
+	}
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	// 0 disables the checks rather than falling back to the defaults
+	if megabytes, inodes := config.GetMinFreeSpace(); megabytes != 0 || inodes != 0 {
+		t.Errorf("Expected free space checks disabled, got thresholds of %d MB and %d inodes", megabytes, inodes)
+	}
+}
+
 func TestLoadConfigFileNotFound(t *testing.T) {
 	_, err := LoadConfig("/nonexistent/config.yaml")
 	if err == nil {
@@ -352,4 +1263,149 @@ invalid_yaml: [
 	if !strings.Contains(err.Error(), "failed to parse config file") {
 		t.Errorf("Expected 'failed to parse config file' error, got: %v", err)
 	}
-}
\ No newline at end of file
+}
+
+func TestConfigHash(t *testing.T) {
+	base := func() *Config {
+		return &Config{
+			TraefikAPI: "http://localhost:8080/api",
+			Email:      "test@example.com",
+			Domains: []Domain{
+				{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com", "shop.example.com"}},
+				{Service: "api", Domain: "api.example.com"},
+			},
+		}
+	}
+
+	original := base()
+	hash := original.Hash()
+	if len(hash) != 64 {
+		t.Fatalf("Expected 64 character hex hash, got '%s'", hash)
+	}
+
+	if base().Hash() != hash {
+		t.Error("Expected hash to be deterministic")
+	}
+
+	reordered := base()
+	reordered.Domains[0], reordered.Domains[1] = reordered.Domains[1], reordered.Domains[0]
+	reordered.Domains[1].Aliases = []string{"shop.example.com", "www.example.com"}
+	if reordered.Hash() != hash {
+		t.Error("Expected domain and alias order not to affect the hash")
+	}
+
+	if original.Domains[0].Domain != "example.com" || original.Domains[0].Aliases[0] != "www.example.com" {
+		t.Error("Expected Hash not to modify the configuration")
+	}
+
+	changed := base()
+	changed.Certificates.RenewalDays = 14
+	if changed.Hash() == hash {
+		t.Error("Expected a configuration change to change the hash")
+	}
+}
+
+func TestDailyWindow_Contains(t *testing.T) {
+	at := func(clock string) time.Time {
+		parsed, _ := time.Parse("15:04", clock)
+		return parsed
+	}
+
+	night, err := ParseDailyWindow("22:00-04:00")
+	if err != nil {
+		t.Fatalf("ParseDailyWindow failed: %v", err)
+	}
+	morning, err := ParseDailyWindow("02:00-05:00")
+	if err != nil {
+		t.Fatalf("ParseDailyWindow failed: %v", err)
+	}
+
+	tests := []struct {
+		window DailyWindow
+		clock  string
+		want   bool
+	}{
+		{morning, "02:00", true},
+		{morning, "04:59", true},
+		{morning, "05:00", false},
+		{morning, "12:00", false},
+		{night, "23:30", true},
+		{night, "03:00", true},
+		{night, "04:00", false},
+		{night, "21:59", false},
+	}
+	for _, tt := range tests {
+		if got := tt.window.Contains(at(tt.clock)); got != tt.want {
+			t.Errorf("%+v.Contains(%s) = %v, want %v", tt.window, tt.clock, got, tt.want)
+		}
+	}
+}
+
+func TestPairWWW(t *testing.T) {
+	config := &Config{
+		Certificates: Certificates{PairWWW: "redirect_to_apex"},
+		Domains: []Domain{
+			{Service: "web", Domain: "example.com"},
+			{Service: "shop", Domain: "www.example.org", PairWWW: "redirect_to_www"},
+			{Service: "api", Domain: "api.example.com"},
+			{Service: "blog", Domain: "example.net", Aliases: []string{"www.example.net"}, PairWWW: "both"},
+			{Service: "docs", Domain: "example.dev", PairWWW: "none"},
+		},
+	}
+	config.pairWWW()
+
+	expectedAliases := map[string][]string{
+		"example.com":     {"www.example.com"},
+		"www.example.org": {"example.org"},
+		"api.example.com": nil,
+		"example.net":     {"www.example.net"},
+		"example.dev":     nil,
+	}
+	for _, domain := range config.Domains {
+		if !reflect.DeepEqual(domain.Aliases, expectedAliases[domain.Domain]) {
+			t.Errorf("Expected aliases of %s to be %v, got %v", domain.Domain, expectedAliases[domain.Domain], domain.Aliases)
+		}
+	}
+
+	expectedRedirects := []WWWRedirect{
+		{From: "www.example.com", To: "example.com"},
+		{From: "example.org", To: "www.example.org"},
+	}
+	if redirects := config.WWWRedirects(); !reflect.DeepEqual(redirects, expectedRedirects) {
+		t.Errorf("Expected redirects %v, got %v", expectedRedirects, redirects)
+	}
+}
+
+func TestConfigProblems(t *testing.T) {
+	config := &Config{
+		TraefikAPI: "http://localhost:8080/api",
+		Notification: Notification{SMTPHost: "smtp.test.com", SMTPPort: 587},
+		App: App{CheckInterval: "daily"},
+		Domains: []Domain{
+			{Service: "web", Domain: "example.com", Aliases: []string{"www.example.com"}},
+			{Service: "shop", Domain: "shop.example.com", Aliases: []string{"www.example.com"}},
+			{Service: "web2", Domain: "example.com"},
+			{Service: "api", Domain: "api.example.com", Aliases: []string{"api.example.com"}},
+		},
+	}
+
+	var got []string
+	for _, problem := range config.Problems() {
+		got = append(got, problem.Error())
+	}
+
+	expected := []string{
+		"email is required",
+		`app.check_interval is invalid: time: invalid duration "daily"`,
+		"domain[0] and domain[1] both include www.example.com",
+		"domain[2].domain example.com duplicates domain[0]",
+		"domain[3] lists api.example.com more than once",
+	}
+	if !reflect.DeepEqual(got, expected) {
+		t.Errorf("Expected problems %q, got %q", expected, got)
+	}
+
+	if err := config.validate(); err == nil || err.Error() != expected[0] {
+		t.Errorf("Expected validate to report the first problem, got %v", err)
+	}
+}
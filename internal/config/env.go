@@ -0,0 +1,145 @@
+package config
+
+import (
+	"fmt"
+	"reflect"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// EnvPrefix starts the environment variables that override configuration
+// fields. The rest of the name is the field's YAML path in upper case with
+// sections joined by underscores, e.g. CERTMANAGER_NOTIFICATION_PASSWORD for
+// notification.password. Lists of strings are comma separated; the domains
+// list and other lists of sections can't be overridden.
+const EnvPrefix = "CERTMANAGER_"
+
+// envName matches the names ${...} interpolates
+var envName = regexp.MustCompile(`^[A-Za-z_][A-Za-z0-9_]*$`)
+
+// interpolateEnv replaces ${NAME} and ${NAME:-default} in configuration text
+// with environment variables, looked up with lookup. $${ stands for a literal
+// ${, and ${...} that doesn't hold a variable name is left untouched. Lines
+// that are entirely a # comment are copied as they are. Referencing an unset
+// variable without a default is an error.
+func interpolateEnv(data []byte, lookup func(string) (string, bool)) ([]byte, error) {
+	var out strings.Builder
+	var unset []string
+
+	for _, line := range strings.SplitAfter(string(data), "\n") {
+		if strings.HasPrefix(strings.TrimSpace(line), "#") {
+			out.WriteString(line)
+			continue
+		}
+		unset = append(unset, interpolateLine(&out, line, lookup)...)
+	}
+
+	if len(unset) > 0 {
+		return nil, fmt.Errorf("config references unset environment variables: %s", strings.Join(unset, ", "))
+	}
+	return []byte(out.String()), nil
+}
+
+// interpolateLine writes text to out with its references replaced and returns
+// the names of unset variables
+func interpolateLine(out *strings.Builder, text string, lookup func(string) (string, bool)) []string {
+	var unset []string
+	for {
+		i := strings.Index(text, "${")
+		if i < 0 {
+			out.WriteString(text)
+			return unset
+		}
+		if i > 0 && text[i-1] == '$' {
+			out.WriteString(text[:i-1] + "${")
+			text = text[i+2:]
+			continue
+		}
+
+		end := strings.IndexByte(text[i:], '}')
+		if end < 0 {
+			out.WriteString(text)
+			return unset
+		}
+		expr := text[i+2 : i+end]
+		name, fallback, hasFallback := strings.Cut(expr, ":-")
+
+		out.WriteString(text[:i])
+		text = text[i+end+1:]
+
+		if !envName.MatchString(name) {
+			out.WriteString("${" + expr + "}")
+			continue
+		}
+		if value, ok := lookup(name); ok {
+			out.WriteString(value)
+		} else if hasFallback {
+			out.WriteString(fallback)
+		} else {
+			unset = append(unset, name)
+		}
+	}
+}
+
+// applyEnvOverrides sets every field that has a CERTMANAGER_* variable. It runs
+// after the file is parsed and before defaults, so the environment beats the
+// file and the file beats the defaults.
+func applyEnvOverrides(c *Config, lookup func(string) (string, bool)) error {
+	return overrideFields(reflect.ValueOf(c).Elem(), strings.TrimSuffix(EnvPrefix, "_"), lookup)
+}
+
+func overrideFields(v reflect.Value, prefix string, lookup func(string) (string, bool)) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := prefix + "_" + strings.ToUpper(tag)
+		field := v.Field(i)
+
+		if field.Kind() == reflect.Struct {
+			if err := overrideFields(field, name, lookup); err != nil {
+				return err
+			}
+			continue
+		}
+
+		value, ok := lookup(name)
+		if !ok {
+			continue
+		}
+
+		switch field.Kind() {
+		case reflect.String:
+			field.SetString(value)
+		case reflect.Int:
+			n, err := strconv.Atoi(value)
+			if err != nil {
+				return fmt.Errorf("%s must be an integer", name)
+			}
+			field.SetInt(int64(n))
+		case reflect.Bool:
+			b, err := strconv.ParseBool(value)
+			if err != nil {
+				return fmt.Errorf("%s must be true or false", name)
+			}
+			field.SetBool(b)
+		case reflect.Slice:
+			if field.Type().Elem().Kind() != reflect.String {
+				return fmt.Errorf("%s can't be set from the environment", name)
+			}
+			var items []string
+			for _, item := range strings.Split(value, ",") {
+				if item = strings.TrimSpace(item); item != "" {
+					items = append(items, item)
+				}
+			}
+			field.Set(reflect.ValueOf(items))
+		default:
+			return fmt.Errorf("%s can't be set from the environment", name)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,98 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+func TestInterpolateEnv(t *testing.T) {
+	env := map[string]string{"SMTP_PASSWORD": "s3cret", "EMPTY": ""}
+	lookup := func(name string) (string, bool) {
+		value, ok := env[name]
+		return value, ok
+	}
+
+	tests := []struct {
+		in      string
+		want    string
+		wantErr bool
+	}{
+		{`password: "${SMTP_PASSWORD}"`, `password: "s3cret"`, false},
+		{`host: ${SMTP_HOST:-smtp.example.com}`, `host: smtp.example.com`, false},
+		{`from: "${EMPTY:-unused}"`, `from: ""`, false},
+		{`literal: "$${SMTP_PASSWORD}"`, `literal: "${SMTP_PASSWORD}"`, false},
+		{`replacement: "https://example.com/${1}"`, `replacement: "https://example.com/${1}"`, false},
+		{`unterminated: ${SMTP_PASSWORD`, `unterminated: ${SMTP_PASSWORD`, false},
+		{"# ${MISSING} in a comment\npassword: ${SMTP_PASSWORD}", "# ${MISSING} in a comment\npassword: s3cret", false},
+		{`password: ${MISSING}`, ``, true},
+	}
+
+	for _, tt := range tests {
+		got, err := interpolateEnv([]byte(tt.in), lookup)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("interpolateEnv(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !tt.wantErr && string(got) != tt.want {
+			t.Errorf("interpolateEnv(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestLoadConfigWithEnvironment(t *testing.T) {
+	configContent := `
+traefik_api: "http://localhost:8080/api"
+email: "test@example.com"
+notification:
+  smtp_host: "smtp.test.com"
+  smtp_port: 587
+  password: "${TEST_SMTP_PASSWORD}"
+certificates:
+  renewal_days: 20
+domains:
+  - service: "web"
+    domain: "example.com"
+`
+	configPath := filepath.Join(t.TempDir(), "config.yaml")
+	if err := os.WriteFile(configPath, []byte(configContent), 0644); err != nil {
+		t.Fatalf("Failed to write test config: %v", err)
+	}
+
+	t.Setenv("TEST_SMTP_PASSWORD", "from-file-reference")
+	t.Setenv("CERTMANAGER_NOTIFICATION_SMTP_PORT", "2525")
+	t.Setenv("CERTMANAGER_CERTIFICATES_RENEWAL_DAYS", "14")
+	t.Setenv("CERTMANAGER_API_TOKEN", "api-token")
+	t.Setenv("CERTMANAGER_API_ENABLED", "true")
+	t.Setenv("CERTMANAGER_DNS_DOH_RESOLVERS", "https://a.example/dns-query, https://b.example/dns-query")
+
+	config, err := LoadConfig(configPath)
+	if err != nil {
+		t.Fatalf("Failed to load config: %v", err)
+	}
+
+	if config.Notification.Password != "from-file-reference" {
+		t.Errorf("Expected interpolated password, got %q", config.Notification.Password)
+	}
+	if config.Notification.SMTPPort != 2525 {
+		t.Errorf("Expected SMTP port from the environment, got %d", config.Notification.SMTPPort)
+	}
+	if config.Certificates.RenewalDays != 14 {
+		t.Errorf("Expected renewal days from the environment to beat the file, got %d", config.Certificates.RenewalDays)
+	}
+	if !config.API.Enabled || config.API.Token != "api-token" {
+		t.Errorf("Expected API settings from the environment, got %+v", config.API)
+	}
+	if want := []string{"https://a.example/dns-query", "https://b.example/dns-query"}; !reflect.DeepEqual(config.DNS.DoHResolvers, want) {
+		t.Errorf("Expected DoH resolvers %v, got %v", want, config.DNS.DoHResolvers)
+	}
+	if config.Certificates.StoragePath != "./certs" {
+		t.Errorf("Expected default storage path, got %q", config.Certificates.StoragePath)
+	}
+
+	t.Setenv("CERTMANAGER_NOTIFICATION_SMTP_PORT", "smtp")
+	if _, err := LoadConfig(configPath); err == nil || err.Error() != "invalid environment override: CERTMANAGER_NOTIFICATION_SMTP_PORT must be an integer" {
+		t.Errorf("Expected an invalid override error, got %v", err)
+	}
+}
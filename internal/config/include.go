@@ -0,0 +1,184 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"sort"
+	"strings"
+
+	"github.com/BurntSushi/toml"
+	"gopkg.in/yaml.v2"
+)
+
+// configExtensions are the file types read from included directories
+var configExtensions = map[string]bool{".yaml": true, ".yml": true, ".json": true, ".toml": true}
+
+// readConfig loads the file at path together with everything it includes,
+// then applies CERTMANAGER_* overrides
+func readConfig(path string) (*Config, error) {
+	config, err := readConfigFile(path, nil)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := applyEnvOverrides(config, os.LookupEnv); err != nil {
+		return nil, fmt.Errorf("invalid environment override: %w", err)
+	}
+	return config, nil
+}
+
+// readConfigFile decodes one file and merges the files it includes into it.
+// parents are the files including it, to detect include cycles.
+func readConfigFile(path string, parents []string) (*Config, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read config file: %w", err)
+	}
+
+	config, err := decodeConfig(path, data)
+	if err != nil {
+		return nil, err
+	}
+
+	includes, err := resolveIncludes(path, config.Include)
+	if err != nil {
+		return nil, err
+	}
+	config.Include = nil
+
+	parents = append(parents, path)
+	for _, include := range includes {
+		for _, parent := range parents {
+			if sameFile(parent, include) {
+				return nil, fmt.Errorf("config include cycle: %s includes %s", path, include)
+			}
+		}
+
+		included, err := readConfigFile(include, parents)
+		if err != nil {
+			return nil, err
+		}
+		if err := mergeFields(reflect.ValueOf(config).Elem(), reflect.ValueOf(included).Elem(), "", include); err != nil {
+			return nil, err
+		}
+	}
+	return config, nil
+}
+
+// decodeConfig interpolates ${VAR} references and decodes YAML, JSON or TOML
+// depending on the file extension. Keys are the same in every format.
+func decodeConfig(path string, data []byte) (*Config, error) {
+	data, err := interpolateEnv(data, os.LookupEnv)
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+
+	var document interface{}
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		err = json.Unmarshal(data, &document)
+	case ".toml":
+		var table map[string]interface{}
+		err = toml.Unmarshal(data, &table)
+		document = table
+	default:
+		var config Config
+		if err := yaml.Unmarshal(data, &config); err != nil {
+			return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+		}
+		return &config, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+
+	// JSON and TOML documents are re-encoded as YAML so the yaml tags on
+	// Config apply to every format. Their values are typed already, so the
+	// round trip doesn't reinterpret strings the way YAML scalars would be.
+	data, err = yaml.Marshal(document)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	var config Config
+	if err := yaml.Unmarshal(data, &config); err != nil {
+		return nil, fmt.Errorf("failed to parse config file %s: %w", path, err)
+	}
+	return &config, nil
+}
+
+// resolveIncludes expands include entries relative to the including file.
+// An entry may name a file, a glob pattern or a directory such as conf.d,
+// whose YAML, JSON and TOML files are included in name order.
+func resolveIncludes(path string, entries []string) ([]string, error) {
+	var files []string
+	for _, entry := range entries {
+		if !filepath.IsAbs(entry) {
+			entry = filepath.Join(filepath.Dir(path), entry)
+		}
+
+		if info, err := os.Stat(entry); err == nil && info.IsDir() {
+			dirEntries, err := os.ReadDir(entry)
+			if err != nil {
+				return nil, fmt.Errorf("failed to read included directory: %w", err)
+			}
+			for _, dirEntry := range dirEntries {
+				if !dirEntry.IsDir() && configExtensions[strings.ToLower(filepath.Ext(dirEntry.Name()))] {
+					files = append(files, filepath.Join(entry, dirEntry.Name()))
+				}
+			}
+			continue
+		}
+
+		matches, err := filepath.Glob(entry)
+		if err != nil {
+			return nil, fmt.Errorf("invalid include pattern %q: %w", entry, err)
+		}
+		if len(matches) == 0 && !strings.ContainsAny(entry, "*?[") {
+			return nil, fmt.Errorf("included config file not found: %s", entry)
+		}
+		sort.Strings(matches)
+		files = append(files, matches...)
+	}
+	return files, nil
+}
+
+// mergeFields adds the settings of an included file to v. Lists are appended,
+// so domains can be split across files; any other setting may be made once.
+func mergeFields(v, included reflect.Value, prefix, file string) error {
+	t := v.Type()
+	for i := 0; i < t.NumField(); i++ {
+		tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+		if tag == "" || tag == "-" {
+			continue
+		}
+		name := tag
+		if prefix != "" {
+			name = prefix + "." + tag
+		}
+		field, value := v.Field(i), included.Field(i)
+
+		switch {
+		case field.Kind() == reflect.Struct:
+			if err := mergeFields(field, value, name, file); err != nil {
+				return err
+			}
+		case field.Kind() == reflect.Slice:
+			field.Set(reflect.AppendSlice(field, value))
+		case value.IsZero():
+		case !field.IsZero():
+			return fmt.Errorf("%s sets %s, which is already set", file, name)
+		default:
+			field.Set(value)
+		}
+	}
+	return nil
+}
+
+func sameFile(a, b string) bool {
+	infoA, errA := os.Stat(a)
+	infoB, errB := os.Stat(b)
+	return errA == nil && errB == nil && os.SameFile(infoA, infoB)
+}
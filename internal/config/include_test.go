@@ -0,0 +1,168 @@
+package config
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func writeConfigFiles(t *testing.T, files map[string]string) string {
+	t.Helper()
+
+	dir := t.TempDir()
+	for name, content := range files {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	return dir
+}
+
+func TestLoadConfigFormats(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.json": `{
+  "traefik_api": "http://localhost:8080/api",
+  "email": "test@example.com",
+  "notification": {"smtp_host": "smtp.test.com", "smtp_port": 587, "password": "0123"},
+  "domains": [{"service": "web", "domain": "example.com", "aliases": ["www.example.com"]}]
+}`,
+		"config.toml": `
+traefik_api = "http://localhost:8080/api"
+email = "test@example.com"
+
+[notification]
+smtp_host = "smtp.test.com"
+smtp_port = 587
+password = "0123"
+
+[[domains]]
+service = "web"
+domain = "example.com"
+aliases = ["www.example.com"]
+`,
+	})
+
+	for _, name := range []string{"config.json", "config.toml"} {
+		config, err := LoadConfig(filepath.Join(dir, name))
+		if err != nil {
+			t.Fatalf("LoadConfig(%s) error = %v", name, err)
+		}
+		if config.Notification.SMTPPort != 587 || config.Notification.Password != "0123" {
+			t.Errorf("%s: notification = %+v", name, config.Notification)
+		}
+		if len(config.Domains) != 1 || config.Domains[0].Aliases[0] != "www.example.com" {
+			t.Errorf("%s: domains = %+v", name, config.Domains)
+		}
+		if config.ACME.KeyType != "RSA2048" {
+			t.Errorf("%s: defaults were not applied", name)
+		}
+	}
+}
+
+func TestLoadConfigIncludes(t *testing.T) {
+	dir := writeConfigFiles(t, map[string]string{
+		"config.yaml": `
+include: ["conf.d", "extra/*.yaml"]
+traefik_api: "http://localhost:8080/api"
+email: "test@example.com"
+notification:
+  smtp_host: "smtp.test.com"
+  smtp_port: 587
+domains:
+  - service: "web"
+    domain: "example.com"
+`,
+		"conf.d/10-team-a.yaml": `
+domains:
+  - service: "api"
+    domain: "api.example.com"
+`,
+		"conf.d/20-team-b.json": `{"domains": [{"service": "shop", "domain": "shop.example.com"}]}`,
+		"conf.d/README":         "not a config file",
+		"extra/acme.yaml": `
+acme:
+  key_type: "EC256"
+include: ["../nested/more.toml"]
+`,
+		"nested/more.toml": `
+[[domains]]
+service = "blog"
+domain = "blog.example.com"
+`,
+	})
+
+	config, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+	if err != nil {
+		t.Fatalf("LoadConfig() error = %v", err)
+	}
+
+	var domains []string
+	for _, domain := range config.Domains {
+		domains = append(domains, domain.Domain)
+	}
+	if got := strings.Join(domains, " "); got != "example.com api.example.com shop.example.com blog.example.com" {
+		t.Errorf("domains = %s", got)
+	}
+	if config.ACME.KeyType != "EC256" {
+		t.Errorf("ACME.KeyType = %q, want the included EC256", config.ACME.KeyType)
+	}
+	if config.Include != nil {
+		t.Errorf("Include = %v, want it resolved", config.Include)
+	}
+}
+
+func TestLoadConfigIncludeErrors(t *testing.T) {
+	base := `
+traefik_api: "http://localhost:8080/api"
+email: "test@example.com"
+notification:
+  smtp_host: "smtp.test.com"
+  smtp_port: 587
+domains:
+  - service: "web"
+    domain: "example.com"
+`
+
+	tests := []struct {
+		name  string
+		files map[string]string
+		want  string
+	}{
+		{
+			name: "setting made twice",
+			files: map[string]string{
+				"config.yaml": base + "include: [\"other.yaml\"]\n",
+				"other.yaml":  "email: \"other@example.com\"\n",
+			},
+			want: "sets email, which is already set",
+		},
+		{
+			name: "cycle",
+			files: map[string]string{
+				"config.yaml": base + "include: [\"other.yaml\"]\n",
+				"other.yaml":  "include: [\"config.yaml\"]\n",
+			},
+			want: "config include cycle",
+		},
+		{
+			name:  "missing file",
+			files: map[string]string{"config.yaml": base + "include: [\"missing.yaml\"]\n"},
+			want:  "included config file not found",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			dir := writeConfigFiles(t, tt.files)
+			_, err := LoadConfig(filepath.Join(dir, "config.yaml"))
+			if err == nil || !strings.Contains(err.Error(), tt.want) {
+				t.Errorf("LoadConfig() error = %v, want %q", err, tt.want)
+			}
+		})
+	}
+}
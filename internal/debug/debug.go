@@ -0,0 +1,84 @@
+// Package debug serves the Go profiler and the runtime state of the daemon, to
+// diagnose a hung daemon without restarting it under a debugger
+package debug
+
+import (
+	"context"
+	"expvar"
+	"fmt"
+	"log"
+	"net/http"
+	"net/http/pprof"
+	"os"
+	"runtime"
+)
+
+// Server exposes the profiler and runtime state:
+//
+//   - /debug/pprof/ serves CPU, heap, goroutine, block and mutex profiles
+//   - /debug/vars serves memory statistics and the published variables as JSON
+type Server struct {
+	vars   *expvar.Map // served under /debug/vars as "certmanager"
+	logger *log.Logger
+	server *http.Server
+}
+
+func NewServer(addr string, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Debug] ", log.LstdFlags)
+	}
+
+	// Not published with expvar, whose names are global to the process, so
+	// each server only serves the variables published to it
+	s := &Server{vars: new(expvar.Map).Init(), logger: logger}
+	s.vars.Set("goroutines", expvar.Func(func() any { return runtime.NumGoroutine() }))
+	s.server = &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
+	}
+	return s
+}
+
+// Publish exposes the value each function returns under its name, replacing
+// a variable published before under the same name
+func (s *Server) Publish(funcs map[string]func() any) {
+	for name, fn := range funcs {
+		s.vars.Set(name, expvar.Func(fn))
+	}
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/debug/pprof/", pprof.Index)
+	mux.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	mux.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	mux.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	mux.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	mux.HandleFunc("/debug/vars", s.serveVars)
+	return mux
+}
+
+// serveVars writes the variables of the process, like memory statistics,
+// and those published to this server as JSON, as expvar.Handler does
+func (s *Server) serveVars(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "application/json; charset=utf-8")
+	fmt.Fprintf(w, "{\n")
+	expvar.Do(func(kv expvar.KeyValue) {
+		fmt.Fprintf(w, "%q: %s,\n", kv.Key, kv.Value)
+	})
+	fmt.Fprintf(w, "%q: %s\n}\n", "certmanager", s.vars)
+}
+
+// Start serves the endpoints in the background
+func (s *Server) Start() {
+	go func() {
+		s.logger.Printf("Serving debug endpoints on %s (/debug/pprof/, /debug/vars)", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Printf("Debug server failed: %v", err)
+		}
+	}()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
@@ -0,0 +1,73 @@
+package debug
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestServer_Vars(t *testing.T) {
+	server := NewServer("127.0.0.1:0", nil)
+	server.Publish(map[string]func() any{
+		"renewal_queue_depth": func() any { return 3 },
+	})
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+
+	var body struct {
+		CertManager map[string]any `json:"certmanager"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if body.CertManager["renewal_queue_depth"] != float64(3) {
+		t.Errorf("expected renewal_queue_depth 3, got %v", body.CertManager["renewal_queue_depth"])
+	}
+	if _, ok := body.CertManager["goroutines"]; !ok {
+		t.Errorf("goroutines are not published: %v", body.CertManager)
+	}
+}
+
+func TestServer_Profiles(t *testing.T) {
+	server := NewServer("127.0.0.1:0", nil)
+
+	rec := httptest.NewRecorder()
+	server.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/pprof/goroutine?debug=1", nil))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected status 200, got %d", rec.Code)
+	}
+	if !strings.Contains(rec.Body.String(), "goroutine profile:") {
+		t.Errorf("unexpected goroutine profile:\n%s", rec.Body.String())
+	}
+}
+
+func TestServer_VarsPerServer(t *testing.T) {
+	first := NewServer("127.0.0.1:0", nil)
+	second := NewServer("127.0.0.1:0", nil)
+	first.Publish(map[string]func() any{
+		"orders_in_flight": func() any { return 1 },
+	})
+
+	rec := httptest.NewRecorder()
+	second.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/debug/vars", nil))
+
+	var body struct {
+		MemStats    map[string]any `json:"memstats"`
+		CertManager map[string]any `json:"certmanager"`
+	}
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("failed to parse response: %v", err)
+	}
+	if _, ok := body.CertManager["orders_in_flight"]; ok {
+		t.Errorf("variables published to one server are served by another: %v", body.CertManager)
+	}
+	if body.MemStats == nil {
+		t.Errorf("memory statistics are not served")
+	}
+}
@@ -0,0 +1,341 @@
+package deploy
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/pkg/sftp"
+	"golang.org/x/crypto/ssh"
+	"golang.org/x/crypto/ssh/knownhosts"
+)
+
+// Bundle is the certificate material copied to a remote host
+type Bundle struct {
+	Domain      string
+	Certificate []byte // leaf followed by the issuer chain
+	PrivateKey  []byte
+	IssuerCert  []byte
+}
+
+// remoteFile is a single upload with the mode it is created with
+type remoteFile struct {
+	path string
+	data []byte
+	mode os.FileMode
+}
+
+// Deployer copies certificates to remote hosts over SSH and reloads the services
+// that use them
+type Deployer struct {
+	logger *log.Logger
+}
+
+func NewDeployer(logger *log.Logger) *Deployer {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Deploy] ", log.LstdFlags)
+	}
+
+	return &Deployer{logger: logger}
+}
+
+// Deploy uploads the bundle to every target. Every target is attempted even if an
+// earlier one fails; the first error is returned.
+func (d *Deployer) Deploy(ctx context.Context, targets []config.DeployTarget, b Bundle) error {
+	var firstErr error
+	for _, target := range targets {
+		if err := d.deployTarget(ctx, target, b); err != nil {
+			d.logger.Printf("Failed to deploy certificate for %s to %s: %v", b.Domain, target.Host, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to deploy to %s: %w", target.Host, err)
+			}
+			continue
+		}
+		d.logger.Printf("Deployed certificate for %s to %s", b.Domain, target.Host)
+	}
+	return firstErr
+}
+
+func (d *Deployer) deployTarget(ctx context.Context, target config.DeployTarget, b Bundle) error {
+	if target.Timeout != "" {
+		timeout, err := time.ParseDuration(target.Timeout)
+		if err != nil {
+			return fmt.Errorf("invalid timeout: %w", err)
+		}
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, timeout)
+		defer cancel()
+	}
+
+	client, err := dial(ctx, target)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	// Closing the connection aborts any transfer still running at the deadline
+	stop := context.AfterFunc(ctx, func() { client.Close() })
+	defer stop()
+
+	// The key goes first so the certificate never references a key that isn't there yet.
+	// Certificates ordered for an external CSR have no key to copy.
+	var files []remoteFile
+	if len(b.PrivateKey) > 0 {
+		files = append(files, remoteFile{path: target.KeyPath, data: b.PrivateKey, mode: 0600})
+	}
+	files = append(files, remoteFile{path: target.CertPath, data: b.Certificate, mode: 0644})
+	if target.ChainPath != "" {
+		files = append(files, remoteFile{path: target.ChainPath, data: b.IssuerCert, mode: 0644})
+	}
+
+	if target.Method == "scp" {
+		err = uploadSCP(client, files)
+	} else {
+		err = uploadSFTP(client, files)
+	}
+	if err != nil {
+		return withContext(ctx, err)
+	}
+
+	if target.ReloadCommand != "" {
+		if err := runCommand(client, target.ReloadCommand); err != nil {
+			return withContext(ctx, fmt.Errorf("reload command failed: %w", err))
+		}
+	}
+
+	return nil
+}
+
+// withContext reports a deadline instead of the connection error it caused
+func withContext(ctx context.Context, err error) error {
+	if ctx.Err() != nil {
+		return fmt.Errorf("%w: %v", ctx.Err(), err)
+	}
+	return err
+}
+
+func dial(ctx context.Context, target config.DeployTarget) (*ssh.Client, error) {
+	keyData, err := os.ReadFile(target.PrivateKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read private key: %w", err)
+	}
+	signer, err := ssh.ParsePrivateKey(keyData)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse private key: %w", err)
+	}
+
+	hostKeyCallback, err := hostKeyCallback(target)
+	if err != nil {
+		return nil, err
+	}
+
+	addr := target.Host
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "22")
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect: %w", err)
+	}
+
+	clientConfig := &ssh.ClientConfig{
+		User:            target.User,
+		Auth:            []ssh.AuthMethod{ssh.PublicKeys(signer)},
+		HostKeyCallback: hostKeyCallback,
+	}
+	// Closing the connection aborts a handshake cut short by ctx; once the
+	// client is up, deployTarget closes it instead
+	stop := context.AfterFunc(ctx, func() { conn.Close() })
+	sshConn, chans, reqs, err := ssh.NewClientConn(conn, addr, clientConfig)
+	stop()
+	if err != nil {
+		conn.Close()
+		return nil, withContext(ctx, fmt.Errorf("failed to establish SSH connection: %w", err))
+	}
+
+	return ssh.NewClient(sshConn, chans, reqs), nil
+}
+
+// hostKeyCallback verifies the server against a pinned key when one is configured,
+// otherwise against a known_hosts file
+func hostKeyCallback(target config.DeployTarget) (ssh.HostKeyCallback, error) {
+	if target.HostKey != "" {
+		key, _, _, _, err := ssh.ParseAuthorizedKey([]byte(target.HostKey))
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse host key: %w", err)
+		}
+		return ssh.FixedHostKey(key), nil
+	}
+
+	knownHostsFile := target.KnownHostsFile
+	if knownHostsFile == "" {
+		home, err := os.UserHomeDir()
+		if err != nil {
+			return nil, fmt.Errorf("failed to locate known_hosts: %w", err)
+		}
+		knownHostsFile = filepath.Join(home, ".ssh", "known_hosts")
+	}
+
+	callback, err := knownhosts.New(knownHostsFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load known_hosts: %w", err)
+	}
+	return callback, nil
+}
+
+// uploadSFTP writes each file to a temporary name and renames it into place so
+// services never read a partially written certificate
+func uploadSFTP(client *ssh.Client, files []remoteFile) error {
+	sc, err := sftp.NewClient(client)
+	if err != nil {
+		return fmt.Errorf("failed to start SFTP session: %w", err)
+	}
+	defer sc.Close()
+
+	for _, file := range files {
+		tmpPath := file.path + ".tmp"
+
+		f, err := sc.OpenFile(tmpPath, os.O_WRONLY|os.O_CREATE|os.O_TRUNC)
+		if err != nil {
+			return fmt.Errorf("failed to create %s: %w", tmpPath, err)
+		}
+		if err := f.Chmod(file.mode); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to set permissions on %s: %w", tmpPath, err)
+		}
+		if _, err := f.Write(file.data); err != nil {
+			f.Close()
+			return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+		}
+		if err := f.Close(); err != nil {
+			return fmt.Errorf("failed to write %s: %w", tmpPath, err)
+		}
+
+		if err := sc.PosixRename(tmpPath, file.path); err != nil {
+			// Servers without the posix-rename extension refuse to overwrite
+			sc.Remove(file.path)
+			if err := sc.Rename(tmpPath, file.path); err != nil {
+				return fmt.Errorf("failed to move %s into place: %w", file.path, err)
+			}
+		}
+	}
+
+	return nil
+}
+
+// uploadSCP sends each file with the scp sink protocol, for hosts without SFTP
+func uploadSCP(client *ssh.Client, files []remoteFile) error {
+	for _, file := range files {
+		if err := scpFile(client, file); err != nil {
+			return fmt.Errorf("failed to copy %s: %w", file.path, err)
+		}
+	}
+	return nil
+}
+
+func scpFile(client *ssh.Client, file remoteFile) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	stdin, err := session.StdinPipe()
+	if err != nil {
+		return err
+	}
+	stdout, err := session.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	acks := bufio.NewReader(stdout)
+
+	if err := session.Start("scp -t " + shellQuote(file.path)); err != nil {
+		return fmt.Errorf("failed to start scp: %w", err)
+	}
+
+	if err := readAck(acks); err != nil {
+		return err
+	}
+	fmt.Fprintf(stdin, "C%04o %d %s\n", file.mode.Perm(), len(file.data), path.Base(file.path))
+	if err := readAck(acks); err != nil {
+		return err
+	}
+	stdin.Write(file.data)
+	stdin.Write([]byte{0})
+	if err := readAck(acks); err != nil {
+		return err
+	}
+	stdin.Close()
+
+	return session.Wait()
+}
+
+// readAck reads an scp status byte: 0 is success, 1 and 2 carry an error message
+func readAck(r *bufio.Reader) error {
+	status, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("failed to read scp response: %w", err)
+	}
+	if status == 0 {
+		return nil
+	}
+
+	msg, _ := r.ReadString('\n')
+	return fmt.Errorf("scp: %s", strings.TrimSpace(msg))
+}
+
+func runCommand(client *ssh.Client, command string) error {
+	session, err := client.NewSession()
+	if err != nil {
+		return fmt.Errorf("failed to open session: %w", err)
+	}
+	defer session.Close()
+
+	// The session copies stdout and stderr in their own goroutines
+	var output syncBuffer
+	session.Stdout = &output
+	session.Stderr = &output
+
+	if err := session.Run(command); err != nil {
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+	return nil
+}
+
+// syncBuffer collects the output of a remote command
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
@@ -0,0 +1,113 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"strings"
+	"time"
+)
+
+const cloudflareEndpoint = "https://api.cloudflare.com/client/v4"
+
+// cloudflareLister lists zone records with the Cloudflare API
+type cloudflareLister struct {
+	endpoint   string
+	token      string
+	httpClient *http.Client
+}
+
+type cloudflareRecordsResponse struct {
+	Success bool `json:"success"`
+	Errors  []struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	} `json:"errors"`
+	Result []struct {
+		Name     string `json:"name"`
+		Type     string `json:"type"`
+		ZoneName string `json:"zone_name"`
+	} `json:"result"`
+	ResultInfo struct {
+		Page       int `json:"page"`
+		TotalPages int `json:"total_pages"`
+	} `json:"result_info"`
+}
+
+// newCloudflareLister creates a lister using the API token from CLOUDFLARE_API_TOKEN
+func newCloudflareLister(endpoint string) (*cloudflareLister, error) {
+	token := os.Getenv("CLOUDFLARE_API_TOKEN")
+	if token == "" {
+		return nil, fmt.Errorf("CLOUDFLARE_API_TOKEN must be set")
+	}
+
+	if endpoint == "" {
+		endpoint = cloudflareEndpoint
+	}
+
+	return &cloudflareLister{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		token:      token,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (l *cloudflareLister) ListRecords(ctx context.Context, zoneID string) (string, []dnsRecord, error) {
+	var zoneName string
+	var records []dnsRecord
+
+	for page := 1; ; page++ {
+		resp, err := l.listPage(ctx, zoneID, page)
+		if err != nil {
+			return "", nil, err
+		}
+		for _, record := range resp.Result {
+			zoneName = record.ZoneName
+			records = append(records, dnsRecord{Name: record.Name, Type: record.Type})
+		}
+
+		if page >= resp.ResultInfo.TotalPages {
+			break
+		}
+	}
+
+	return zoneName, records, nil
+}
+
+func (l *cloudflareLister) listPage(ctx context.Context, zoneID string, page int) (*cloudflareRecordsResponse, error) {
+	endpoint := fmt.Sprintf("%s/zones/%s/dns_records?page=%d&per_page=100", l.endpoint, url.PathEscape(zoneID), page)
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Cloudflare request: %w", err)
+	}
+	req.Header.Set("Authorization", "Bearer "+l.token)
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Cloudflare API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("Cloudflare API returned status %d: %s", resp.StatusCode, body)
+	}
+
+	var result cloudflareRecordsResponse
+	if err := json.Unmarshal(body, &result); err != nil {
+		return nil, fmt.Errorf("failed to decode Cloudflare response: %w", err)
+	}
+	if !result.Success {
+		var messages []string
+		for _, e := range result.Errors {
+			messages = append(messages, fmt.Sprintf("%d: %s", e.Code, e.Message))
+		}
+		return nil, fmt.Errorf("Cloudflare API request failed: %s", strings.Join(messages, "; "))
+	}
+	return &result, nil
+}
@@ -0,0 +1,16 @@
+package discovery
+
+import (
+	"context"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// Provider discovers domains that should be managed from an external source
+type Provider interface {
+	// Name identifies the provider in logs and as the owner of its domains
+	Name() string
+	// Watch reports the full set of discovered domains every time it changes,
+	// until the context is cancelled
+	Watch(ctx context.Context, onChange func([]config.Domain)) error
+}
@@ -0,0 +1,183 @@
+package discovery
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"path"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+const dnsZoneRequestTimeout = time.Minute
+
+// dnsRecord is one record set of a hosted zone
+type dnsRecord struct {
+	Name string
+	Type string
+}
+
+// zoneLister lists the records of a hosted zone at a DNS provider
+type zoneLister interface {
+	// ListRecords returns the zone's name and its record sets
+	ListRecords(ctx context.Context, zoneID string) (string, []dnsRecord, error)
+}
+
+// DNSZoneProvider discovers domains from records in DNS zones hosted at a
+// cloud provider, so names created by other teams get certificates without
+// being added to the configuration by hand
+type DNSZoneProvider struct {
+	lister      zoneLister
+	zones       []string
+	include     []string
+	exclude     []string
+	recordTypes map[string]bool
+	interval    time.Duration
+	logger      *log.Logger
+}
+
+func NewDNSZoneProvider(cfg config.DNSZoneDiscovery, logger *log.Logger) (*DNSZoneProvider, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[DNSZones] ", log.LstdFlags)
+	}
+
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS zone scan interval: %w", err)
+	}
+
+	var lister zoneLister
+	switch cfg.Provider {
+	case "route53":
+		lister, err = newRoute53Lister(cfg.Endpoint)
+	case "cloudflare":
+		lister, err = newCloudflareLister(cfg.Endpoint)
+	default:
+		err = fmt.Errorf("unsupported DNS provider: %s", cfg.Provider)
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	recordTypes := make(map[string]bool, len(cfg.RecordTypes))
+	for _, recordType := range cfg.RecordTypes {
+		recordTypes[strings.ToUpper(recordType)] = true
+	}
+
+	return &DNSZoneProvider{
+		lister:      lister,
+		zones:       cfg.Zones,
+		include:     cfg.Include,
+		exclude:     cfg.Exclude,
+		recordTypes: recordTypes,
+		interval:    interval,
+		logger:      logger,
+	}, nil
+}
+
+func (p *DNSZoneProvider) Name() string {
+	return "dns-zones"
+}
+
+// Domains lists the matching records of every configured zone. Each record
+// becomes its own domain, with the zone name as its service.
+func (p *DNSZoneProvider) Domains(ctx context.Context) ([]config.Domain, error) {
+	ctx, cancel := context.WithTimeout(ctx, dnsZoneRequestTimeout)
+	defer cancel()
+
+	byDomain := make(map[string]config.Domain)
+	for _, zoneID := range p.zones {
+		zoneName, records, err := p.lister.ListRecords(ctx, zoneID)
+		if err != nil {
+			return nil, fmt.Errorf("failed to list records of zone %s: %w", zoneID, err)
+		}
+
+		service := normalizeRecordName(zoneName)
+		if service == "" {
+			service = zoneID
+		}
+
+		for _, record := range records {
+			name := normalizeRecordName(record.Name)
+			if name == "" || !p.recordTypes[strings.ToUpper(record.Type)] || !p.matches(name) {
+				continue
+			}
+			if _, exists := byDomain[name]; !exists {
+				byDomain[name] = config.Domain{Service: service, Domain: name}
+			}
+		}
+	}
+
+	domains := make([]config.Domain, 0, len(byDomain))
+	for _, domain := range byDomain {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+
+	return domains, nil
+}
+
+// matches applies the include and exclude patterns to a record name
+func (p *DNSZoneProvider) matches(name string) bool {
+	for _, pattern := range p.exclude {
+		if ok, _ := path.Match(pattern, name); ok {
+			return false
+		}
+	}
+	if len(p.include) == 0 {
+		return true
+	}
+	for _, pattern := range p.include {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// Watch lists the zones every interval and reports changes
+func (p *DNSZoneProvider) Watch(ctx context.Context, onChange func([]config.Domain)) error {
+	var current []config.Domain
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		domains, err := p.Domains(ctx)
+		if err != nil {
+			p.logger.Printf("DNS zone scan failed: %v", err)
+		} else if current == nil || !reflect.DeepEqual(domains, current) {
+			current = domains
+			p.logger.Printf("Discovered %d domains from DNS zones", len(domains))
+			onChange(domains)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// normalizeRecordName turns a record name into a domain, or "" for service
+// records such as _dmarc or _acme-challenge that never serve TLS
+func normalizeRecordName(name string) string {
+	name = strings.ToLower(strings.TrimSuffix(name, "."))
+	// Route53 returns the wildcard label escaped
+	name = strings.ReplaceAll(name, `\052`, "*")
+
+	for _, label := range strings.Split(name, ".") {
+		if label == "" || strings.HasPrefix(label, "_") {
+			return ""
+		}
+	}
+	if strings.Contains(name, "*") && (!strings.HasPrefix(name, "*.") || strings.Count(name, "*") > 1) {
+		return ""
+	}
+	return name
+}
@@ -0,0 +1,148 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+const route53Zone = `<GetHostedZoneResponse><HostedZone><Id>/hostedzone/Z1</Id><Name>example.com.</Name></HostedZone></GetHostedZoneResponse>`
+
+var route53Pages = map[string]string{
+	"": `<ListResourceRecordSetsResponse><ResourceRecordSets>
+<ResourceRecordSet><Name>example.com.</Name><Type>A</Type></ResourceRecordSet>
+<ResourceRecordSet><Name>example.com.</Name><Type>MX</Type></ResourceRecordSet>
+<ResourceRecordSet><Name>_dmarc.example.com.</Name><Type>TXT</Type></ResourceRecordSet>
+</ResourceRecordSets><IsTruncated>true</IsTruncated><NextRecordName>\052.apps.example.com.</NextRecordName><NextRecordType>CNAME</NextRecordType></ListResourceRecordSetsResponse>`,
+	`\052.apps.example.com.`: `<ListResourceRecordSetsResponse><ResourceRecordSets>
+<ResourceRecordSet><Name>\052.apps.example.com.</Name><Type>CNAME</Type></ResourceRecordSet>
+<ResourceRecordSet><Name>db.internal.example.com.</Name><Type>A</Type></ResourceRecordSet>
+</ResourceRecordSets><IsTruncated>false</IsTruncated></ListResourceRecordSetsResponse>`,
+}
+
+func TestDNSZoneProvider_Route53(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !strings.HasPrefix(r.Header.Get("Authorization"), "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/") {
+			http.Error(w, "unsigned request", http.StatusForbidden)
+			return
+		}
+		switch r.URL.Path {
+		case "/2013-04-01/hostedzone/Z1":
+			fmt.Fprint(w, route53Zone)
+		case "/2013-04-01/hostedzone/Z1/rrset":
+			fmt.Fprint(w, route53Pages[r.URL.Query().Get("name")])
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider, err := NewDNSZoneProvider(config.DNSZoneDiscovery{
+		Provider:    "route53",
+		Zones:       []string{"/hostedzone/Z1"},
+		Exclude:     []string{"*.internal.example.com"},
+		RecordTypes: []string{"A", "AAAA", "CNAME"},
+		Interval:    "15m",
+		Endpoint:    server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create DNS zone provider: %v", err)
+	}
+
+	domains, err := provider.Domains(context.Background())
+	if err != nil {
+		t.Fatalf("Domains() failed: %v", err)
+	}
+
+	want := []config.Domain{
+		{Service: "example.com", Domain: "*.apps.example.com"},
+		{Service: "example.com", Domain: "example.com"},
+	}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("domains = %+v, want %+v", domains, want)
+	}
+}
+
+func TestDNSZoneProvider_Cloudflare(t *testing.T) {
+	t.Setenv("CLOUDFLARE_API_TOKEN", "cf-token")
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer cf-token" {
+			http.Error(w, "missing token", http.StatusForbidden)
+			return
+		}
+
+		resp := cloudflareRecordsResponse{Success: true}
+		resp.ResultInfo.TotalPages = 2
+		name := "www.example.org"
+		if r.URL.Query().Get("page") == "2" {
+			name = "shop.example.org"
+		}
+		resp.Result = append(resp.Result, struct {
+			Name     string `json:"name"`
+			Type     string `json:"type"`
+			ZoneName string `json:"zone_name"`
+		}{Name: name, Type: "CNAME", ZoneName: "example.org"})
+		json.NewEncoder(w).Encode(resp)
+	}))
+	defer server.Close()
+
+	provider, err := NewDNSZoneProvider(config.DNSZoneDiscovery{
+		Provider:    "cloudflare",
+		Zones:       []string{"023e105f4ecef8ad9ca31a8372d0c353"},
+		Include:     []string{"*.example.org"},
+		RecordTypes: []string{"cname"},
+		Interval:    "15m",
+		Endpoint:    server.URL,
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create DNS zone provider: %v", err)
+	}
+
+	domains, err := provider.Domains(context.Background())
+	if err != nil {
+		t.Fatalf("Domains() failed: %v", err)
+	}
+
+	want := []config.Domain{
+		{Service: "example.org", Domain: "shop.example.org"},
+		{Service: "example.org", Domain: "www.example.org"},
+	}
+	if !reflect.DeepEqual(domains, want) {
+		t.Errorf("domains = %+v, want %+v", domains, want)
+	}
+}
+
+func TestNewDNSZoneProvider_RequiresCredentials(t *testing.T) {
+	t.Setenv("CLOUDFLARE_API_TOKEN", "")
+
+	_, err := NewDNSZoneProvider(config.DNSZoneDiscovery{Provider: "cloudflare", Interval: "15m"}, nil)
+	if err == nil {
+		t.Error("expected an error without CLOUDFLARE_API_TOKEN")
+	}
+}
+
+func TestNormalizeRecordName(t *testing.T) {
+	tests := map[string]string{
+		"Example.COM.":           "example.com",
+		`\052.example.com.`:      "*.example.com",
+		"_acme-challenge.a.com.": "",
+		"*.*.example.com.":       "",
+		"a.*.example.com.":       "",
+	}
+	for name, want := range tests {
+		if got := normalizeRecordName(name); got != want {
+			t.Errorf("normalizeRecordName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
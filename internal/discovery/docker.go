@@ -0,0 +1,244 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"reflect"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+const (
+	dockerRequestTimeout = 30 * time.Second
+	dockerMaxBackoff     = time.Minute
+)
+
+// DockerProvider discovers domains from labels on running Docker containers
+type DockerProvider struct {
+	baseURL     string
+	httpClient  *http.Client
+	labelPrefix string
+	logger      *log.Logger
+}
+
+type dockerContainer struct {
+	ID     string            `json:"Id"`
+	Names  []string          `json:"Names"`
+	Labels map[string]string `json:"Labels"`
+}
+
+type dockerEvent struct {
+	Type   string `json:"Type"`
+	Action string `json:"Action"`
+}
+
+func NewDockerProvider(cfg config.DockerDiscovery, logger *log.Logger) (*DockerProvider, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Docker] ", log.LstdFlags)
+	}
+
+	endpoint, err := url.Parse(cfg.Endpoint)
+	if err != nil {
+		return nil, fmt.Errorf("invalid docker endpoint: %w", err)
+	}
+
+	provider := &DockerProvider{
+		labelPrefix: cfg.LabelPrefix,
+		logger:      logger,
+	}
+
+	switch endpoint.Scheme {
+	case "unix":
+		socketPath := endpoint.Path
+		provider.baseURL = "http://docker"
+		provider.httpClient = &http.Client{
+			Transport: &http.Transport{
+				DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+					var dialer net.Dialer
+					return dialer.DialContext(ctx, "unix", socketPath)
+				},
+			},
+		}
+	case "tcp", "http":
+		provider.baseURL = "http://" + endpoint.Host
+		provider.httpClient = &http.Client{}
+	case "https":
+		provider.baseURL = "https://" + endpoint.Host
+		provider.httpClient = &http.Client{}
+	default:
+		return nil, fmt.Errorf("unsupported docker endpoint scheme: %s", endpoint.Scheme)
+	}
+
+	return provider, nil
+}
+
+func (p *DockerProvider) Name() string {
+	return "docker"
+}
+
+// Domains lists the domains declared by labels on currently running containers
+func (p *DockerProvider) Domains(ctx context.Context) ([]config.Domain, error) {
+	ctx, cancel := context.WithTimeout(ctx, dockerRequestTimeout)
+	defer cancel()
+
+	filters, _ := json.Marshal(map[string][]string{"label": {p.label("domain")}})
+	endpoint := fmt.Sprintf("%s/containers/json?filters=%s", p.baseURL, url.QueryEscape(string(filters)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to call Docker API: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		body, _ := io.ReadAll(resp.Body)
+		return nil, fmt.Errorf("Docker API returned status %d: %s", resp.StatusCode, string(body))
+	}
+
+	var containers []dockerContainer
+	if err := json.NewDecoder(resp.Body).Decode(&containers); err != nil {
+		return nil, fmt.Errorf("failed to decode containers response: %w", err)
+	}
+
+	return p.domainsFromContainers(containers), nil
+}
+
+func (p *DockerProvider) domainsFromContainers(containers []dockerContainer) []config.Domain {
+	byDomain := make(map[string]config.Domain)
+
+	for _, container := range containers {
+		domain := strings.ToLower(strings.TrimSpace(container.Labels[p.label("domain")]))
+		if domain == "" {
+			continue
+		}
+
+		service := container.Labels[p.label("service")]
+		if service == "" && len(container.Names) > 0 {
+			service = strings.TrimPrefix(container.Names[0], "/")
+		}
+
+		var aliases []string
+		for _, alias := range strings.Split(container.Labels[p.label("aliases")], ",") {
+			if alias = strings.ToLower(strings.TrimSpace(alias)); alias != "" {
+				aliases = append(aliases, alias)
+			}
+		}
+
+		if _, exists := byDomain[domain]; exists {
+			p.logger.Printf("Domain %s is declared by multiple containers, using the first one", domain)
+			continue
+		}
+
+		byDomain[domain] = config.Domain{
+			Service: service,
+			Domain:  domain,
+			Aliases: aliases,
+		}
+	}
+
+	domains := make([]config.Domain, 0, len(byDomain))
+	for _, domain := range byDomain {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+
+	return domains
+}
+
+// Watch lists labelled containers and re-lists them whenever a container starts or stops
+func (p *DockerProvider) Watch(ctx context.Context, onChange func([]config.Domain)) error {
+	var current []config.Domain
+	backoff := time.Second
+
+	refresh := func() error {
+		domains, err := p.Domains(ctx)
+		if err != nil {
+			return err
+		}
+		if current == nil || !reflect.DeepEqual(domains, current) {
+			current = domains
+			p.logger.Printf("Discovered %d domains from Docker labels", len(domains))
+			onChange(domains)
+		}
+		return nil
+	}
+
+	for {
+		err := refresh()
+		if err == nil {
+			err = p.streamEvents(ctx, refresh)
+			backoff = time.Second
+		}
+
+		if ctx.Err() != nil {
+			return ctx.Err()
+		}
+
+		p.logger.Printf("Docker discovery interrupted, retrying in %v: %v", backoff, err)
+		select {
+		case <-time.After(backoff):
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+
+		backoff *= 2
+		if backoff > dockerMaxBackoff {
+			backoff = dockerMaxBackoff
+		}
+	}
+}
+
+// streamEvents blocks on the Docker event stream and calls refresh on container lifecycle events
+func (p *DockerProvider) streamEvents(ctx context.Context, refresh func() error) error {
+	filters, _ := json.Marshal(map[string][]string{
+		"type":  {"container"},
+		"event": {"start", "die", "destroy"},
+	})
+	endpoint := fmt.Sprintf("%s/events?filters=%s", p.baseURL, url.QueryEscape(string(filters)))
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create events request: %w", err)
+	}
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to subscribe to Docker events: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Docker events API returned status %d", resp.StatusCode)
+	}
+
+	decoder := json.NewDecoder(resp.Body)
+	for {
+		var event dockerEvent
+		if err := decoder.Decode(&event); err != nil {
+			return fmt.Errorf("Docker event stream closed: %w", err)
+		}
+
+		if err := refresh(); err != nil {
+			p.logger.Printf("Failed to refresh containers after %s event: %v", event.Action, err)
+		}
+	}
+}
+
+func (p *DockerProvider) label(name string) string {
+	return p.labelPrefix + "." + name
+}
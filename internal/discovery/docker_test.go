@@ -0,0 +1,136 @@
+package discovery
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func newTestDockerProvider(t *testing.T, serverURL string) *DockerProvider {
+	t.Helper()
+
+	provider, err := NewDockerProvider(config.DockerDiscovery{
+		Endpoint:    serverURL,
+		LabelPrefix: "cert-manager",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create docker provider: %v", err)
+	}
+	return provider
+}
+
+func TestDockerProvider_Domains(t *testing.T) {
+	containers := []dockerContainer{
+		{
+			ID:    "abc",
+			Names: []string{"/web"},
+			Labels: map[string]string{
+				"cert-manager.domain":  "Example.com",
+				"cert-manager.aliases": "www.example.com, shop.example.com",
+			},
+		},
+		{
+			ID:    "def",
+			Names: []string{"/api"},
+			Labels: map[string]string{
+				"cert-manager.domain":  "api.example.com",
+				"cert-manager.service": "api-service",
+			},
+		},
+		{
+			ID:     "ghi",
+			Names:  []string{"/unlabelled"},
+			Labels: map[string]string{},
+		},
+	}
+
+	var gotFilters string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/containers/json" {
+			http.NotFound(w, r)
+			return
+		}
+		gotFilters = r.URL.Query().Get("filters")
+		json.NewEncoder(w).Encode(containers)
+	}))
+	defer server.Close()
+
+	provider := newTestDockerProvider(t, server.URL)
+
+	domains, err := provider.Domains(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to list domains: %v", err)
+	}
+
+	if gotFilters != `{"label":["cert-manager.domain"]}` {
+		t.Errorf("Unexpected filters query: %s", gotFilters)
+	}
+
+	if len(domains) != 2 {
+		t.Fatalf("Expected 2 domains, got %d: %v", len(domains), domains)
+	}
+
+	if domains[0].Domain != "api.example.com" || domains[0].Service != "api-service" {
+		t.Errorf("Unexpected first domain: %+v", domains[0])
+	}
+
+	if domains[1].Domain != "example.com" || domains[1].Service != "web" {
+		t.Errorf("Unexpected second domain: %+v", domains[1])
+	}
+
+	if len(domains[1].Aliases) != 2 || domains[1].Aliases[1] != "shop.example.com" {
+		t.Errorf("Unexpected aliases: %v", domains[1].Aliases)
+	}
+}
+
+func TestDockerProvider_Watch(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/containers/json":
+			json.NewEncoder(w).Encode([]dockerContainer{
+				{Names: []string{"/web"}, Labels: map[string]string{"cert-manager.domain": "example.com"}},
+			})
+		case "/events":
+			// Hold the stream open until the client goes away
+			w.WriteHeader(http.StatusOK)
+			w.(http.Flusher).Flush()
+			<-r.Context().Done()
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := newTestDockerProvider(t, server.URL)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	updates := make(chan []config.Domain, 1)
+
+	done := make(chan error)
+	go func() {
+		done <- provider.Watch(ctx, func(domains []config.Domain) {
+			updates <- domains
+		})
+	}()
+
+	domains := <-updates
+	if len(domains) != 1 || domains[0].Domain != "example.com" {
+		t.Errorf("Unexpected discovered domains: %v", domains)
+	}
+
+	cancel()
+	if err := <-done; err != context.Canceled {
+		t.Errorf("Expected context.Canceled, got %v", err)
+	}
+}
+
+func TestNewDockerProvider_UnsupportedScheme(t *testing.T) {
+	_, err := NewDockerProvider(config.DockerDiscovery{Endpoint: "ftp://docker"}, nil)
+	if err == nil {
+		t.Error("Expected error for unsupported endpoint scheme")
+	}
+}
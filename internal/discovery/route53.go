@@ -0,0 +1,118 @@
+package discovery
+
+import (
+	"context"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/sigv4"
+)
+
+const (
+	route53Endpoint = "https://route53.amazonaws.com"
+	// Route53 is a global service signed in us-east-1
+	route53Region = "us-east-1"
+)
+
+// route53Lister lists hosted zone records with the Route53 REST API
+type route53Lister struct {
+	endpoint   string
+	signer     *sigv4.Signer
+	httpClient *http.Client
+}
+
+type route53HostedZone struct {
+	HostedZone struct {
+		Name string `xml:"Name"`
+	} `xml:"HostedZone"`
+}
+
+type route53RecordSets struct {
+	RecordSets []struct {
+		Name string `xml:"Name"`
+		Type string `xml:"Type"`
+	} `xml:"ResourceRecordSets>ResourceRecordSet"`
+	IsTruncated    bool   `xml:"IsTruncated"`
+	NextRecordName string `xml:"NextRecordName"`
+	NextRecordType string `xml:"NextRecordType"`
+}
+
+// newRoute53Lister creates a lister using credentials from the environment
+func newRoute53Lister(endpoint string) (*route53Lister, error) {
+	signer, err := sigv4.FromEnvironment("route53", route53Region)
+	if err != nil {
+		return nil, err
+	}
+
+	if endpoint == "" {
+		endpoint = route53Endpoint
+	}
+
+	return &route53Lister{
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		signer:     signer,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (l *route53Lister) ListRecords(ctx context.Context, zoneID string) (string, []dnsRecord, error) {
+	zoneID = strings.TrimPrefix(zoneID, "/hostedzone/")
+
+	var zone route53HostedZone
+	if err := l.get(ctx, "/2013-04-01/hostedzone/"+url.PathEscape(zoneID), nil, &zone); err != nil {
+		return "", nil, err
+	}
+
+	var records []dnsRecord
+	query := url.Values{}
+	for {
+		var page route53RecordSets
+		if err := l.get(ctx, "/2013-04-01/hostedzone/"+url.PathEscape(zoneID)+"/rrset", query, &page); err != nil {
+			return "", nil, err
+		}
+		for _, set := range page.RecordSets {
+			records = append(records, dnsRecord{Name: set.Name, Type: set.Type})
+		}
+
+		if !page.IsTruncated {
+			break
+		}
+		query = url.Values{"name": {page.NextRecordName}, "type": {page.NextRecordType}}
+	}
+
+	return zone.HostedZone.Name, records, nil
+}
+
+func (l *route53Lister) get(ctx context.Context, path string, query url.Values, out interface{}) error {
+	endpoint := l.endpoint + path
+	if len(query) > 0 {
+		endpoint += "?" + sigv4.EncodeQuery(query)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create Route53 request: %w", err)
+	}
+	l.signer.Sign(req, nil)
+
+	resp, err := l.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call Route53: %w", err)
+	}
+	defer resp.Body.Close()
+
+	body, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("Route53 returned status %d: %s", resp.StatusCode, body)
+	}
+
+	if err := xml.Unmarshal(body, out); err != nil {
+		return fmt.Errorf("failed to decode Route53 response: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,258 @@
+package discovery
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"io/fs"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// maxVhostFileSize bounds how much of a single configuration file is scanned
+const maxVhostFileSize = 4 << 20
+
+var (
+	nginxServerName  = regexp.MustCompile(`\bserver_name\s+([^;]+);`)
+	apacheVhostStart = regexp.MustCompile(`(?i)^\s*<VirtualHost\b`)
+	apacheVhostEnd   = regexp.MustCompile(`(?i)^\s*</VirtualHost\s*>`)
+	apacheDirective  = regexp.MustCompile(`(?i)^\s*(ServerName|ServerAlias)\s+(.+)$`)
+)
+
+// VhostProvider discovers domains from nginx server_name and Apache
+// ServerName/ServerAlias directives, so certificates for sites still served by
+// those web servers are ready before they move behind Traefik
+type VhostProvider struct {
+	paths    []string // files, directories or glob patterns
+	interval time.Duration
+	logger   *log.Logger
+}
+
+// vhost is one server block or virtual host
+type vhost struct {
+	file  string
+	names []string
+}
+
+func NewVhostProvider(cfg config.VhostDiscovery, logger *log.Logger) (*VhostProvider, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Vhosts] ", log.LstdFlags)
+	}
+
+	interval, err := time.ParseDuration(cfg.Interval)
+	if err != nil {
+		return nil, fmt.Errorf("invalid vhost scan interval: %w", err)
+	}
+
+	return &VhostProvider{
+		paths:    cfg.Paths,
+		interval: interval,
+		logger:   logger,
+	}, nil
+}
+
+func (p *VhostProvider) Name() string {
+	return "vhosts"
+}
+
+// Domains scans the configured paths. The first name of each server block or
+// virtual host becomes the domain, the others its aliases.
+func (p *VhostProvider) Domains() ([]config.Domain, error) {
+	files, err := p.files()
+	if err != nil {
+		return nil, err
+	}
+
+	var vhosts []vhost
+	for _, file := range files {
+		found, err := scanVhostFile(file)
+		if err != nil {
+			p.logger.Printf("Skipping %s: %v", file, err)
+			continue
+		}
+		vhosts = append(vhosts, found...)
+	}
+
+	return p.domainsFromVhosts(vhosts), nil
+}
+
+func (p *VhostProvider) domainsFromVhosts(vhosts []vhost) []config.Domain {
+	byDomain := make(map[string]config.Domain)
+	claimed := make(map[string]bool)
+
+	for _, vh := range vhosts {
+		var names []string
+		for _, name := range vh.names {
+			if name = normalizeVhostName(name); name != "" && !claimed[name] {
+				claimed[name] = true
+				names = append(names, name)
+			}
+		}
+		if len(names) == 0 {
+			continue
+		}
+
+		byDomain[names[0]] = config.Domain{
+			Service: strings.TrimSuffix(filepath.Base(vh.file), filepath.Ext(vh.file)),
+			Domain:  names[0],
+			Aliases: names[1:],
+		}
+	}
+
+	domains := make([]config.Domain, 0, len(byDomain))
+	for _, domain := range byDomain {
+		domains = append(domains, domain)
+	}
+	sort.Slice(domains, func(i, j int) bool { return domains[i].Domain < domains[j].Domain })
+
+	return domains
+}
+
+// files expands the configured paths into the regular files below them
+func (p *VhostProvider) files() ([]string, error) {
+	seen := make(map[string]bool)
+	var files []string
+
+	for _, pattern := range p.paths {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("invalid vhost path %q: %w", pattern, err)
+		}
+
+		for _, match := range matches {
+			err := filepath.WalkDir(match, func(path string, d fs.DirEntry, err error) error {
+				if err != nil {
+					return err
+				}
+				if d.IsDir() || seen[path] {
+					return nil
+				}
+				seen[path] = true
+				files = append(files, path)
+				return nil
+			})
+			if err != nil {
+				return nil, fmt.Errorf("failed to scan %s: %w", match, err)
+			}
+		}
+	}
+
+	sort.Strings(files)
+	return files, nil
+}
+
+// Watch rescans the configured paths every interval and reports changes
+func (p *VhostProvider) Watch(ctx context.Context, onChange func([]config.Domain)) error {
+	var current []config.Domain
+	ticker := time.NewTicker(p.interval)
+	defer ticker.Stop()
+
+	for {
+		domains, err := p.Domains()
+		if err != nil {
+			p.logger.Printf("Vhost scan failed: %v", err)
+		} else if current == nil || !reflect.DeepEqual(domains, current) {
+			current = domains
+			p.logger.Printf("Discovered %d domains from web server configuration", len(domains))
+			onChange(domains)
+		}
+
+		select {
+		case <-ticker.C:
+		case <-ctx.Done():
+			return ctx.Err()
+		}
+	}
+}
+
+// scanVhostFile extracts nginx server blocks and Apache virtual hosts from a file
+func scanVhostFile(path string) ([]vhost, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	var vhosts []vhost
+	var nginxConfig strings.Builder
+	var apache *vhost
+
+	scanner := bufio.NewScanner(io.LimitReader(f, maxVhostFileSize))
+	for scanner.Scan() {
+		line := scanner.Text()
+		if i := strings.Index(line, "#"); i >= 0 {
+			line = line[:i]
+		}
+		nginxConfig.WriteString(line)
+		nginxConfig.WriteByte('\n')
+
+		switch {
+		case apacheVhostStart.MatchString(line):
+			apache = &vhost{file: path}
+		case apacheVhostEnd.MatchString(line):
+			if apache != nil && len(apache.names) > 0 {
+				vhosts = append(vhosts, *apache)
+			}
+			apache = nil
+		case apache != nil:
+			if m := apacheDirective.FindStringSubmatch(line); m != nil {
+				names := strings.Fields(m[2])
+				if len(names) == 0 {
+					continue
+				}
+				if strings.EqualFold(m[1], "ServerName") {
+					// The server name leads, whatever order the directives come in
+					apache.names = append(names[:1], apache.names...)
+				} else {
+					apache.names = append(apache.names, names...)
+				}
+			}
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, err
+	}
+
+	for _, m := range nginxServerName.FindAllStringSubmatch(nginxConfig.String(), -1) {
+		vhosts = append(vhosts, vhost{file: path, names: strings.Fields(m[1])})
+	}
+	return vhosts, nil
+}
+
+// normalizeVhostName turns a server name into a domain, or "" for names that
+// can't have a public certificate: catch-alls, regular expressions, IPs and
+// single-label hosts
+func normalizeVhostName(name string) string {
+	name = strings.ToLower(strings.Trim(name, `"'`))
+	name = strings.TrimPrefix(strings.TrimPrefix(name, "http://"), "https://")
+	if host, _, err := net.SplitHostPort(name); err == nil {
+		name = host
+	}
+	// nginx ".example.com" matches example.com and its subdomains
+	name = strings.TrimPrefix(name, ".")
+	name = strings.TrimSuffix(name, ".")
+
+	switch {
+	case name == "" || name == "_" || name == "localhost":
+		return ""
+	case strings.HasPrefix(name, "~") || strings.ContainsAny(name, "$()[]\\^"):
+		return ""
+	case strings.HasSuffix(name, ".*") || strings.Count(name, "*") > 1:
+		return ""
+	case net.ParseIP(name) != nil || !strings.Contains(name, "."):
+		return ""
+	case strings.Contains(name, "*") && !strings.HasPrefix(name, "*."):
+		return ""
+	}
+	return name
+}
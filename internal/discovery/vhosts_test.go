@@ -0,0 +1,95 @@
+package discovery
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+const nginxSite = `
+server {
+    listen 80;
+    server_name example.com www.example.com;  # primary site
+    # server_name commented.example.com;
+}
+
+server {
+    listen 80 default_server;
+    server_name _;
+}
+
+server {
+    server_name ~^(?<user>.+)\.users\.example\.com$ .static.example.com;
+}
+`
+
+const apacheSite = `
+<VirtualHost *:443>
+    ServerAlias shop.example.org
+    ServerName https://example.org:443
+    ServerAlias 192.0.2.10 www.example.org
+</VirtualHost>
+
+<VirtualHost *:80>
+    ServerName example.com
+</VirtualHost>
+`
+
+func TestVhostProvider_Domains(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "nginx"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "nginx", "site.conf"), []byte(nginxSite), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "shop.conf"), []byte(apacheSite), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	provider, err := NewVhostProvider(config.VhostDiscovery{
+		Paths:    []string{filepath.Join(dir, "nginx"), filepath.Join(dir, "*.conf")},
+		Interval: "5m",
+	}, nil)
+	if err != nil {
+		t.Fatalf("Failed to create vhost provider: %v", err)
+	}
+
+	domains, err := provider.Domains()
+	if err != nil {
+		t.Fatalf("Domains failed: %v", err)
+	}
+
+	// example.com is claimed by the nginx site, scanned first
+	expected := []config.Domain{
+		{Service: "site", Domain: "example.com", Aliases: []string{"www.example.com"}},
+		{Service: "shop", Domain: "example.org", Aliases: []string{"shop.example.org", "www.example.org"}},
+		{Service: "site", Domain: "static.example.com", Aliases: []string{}},
+	}
+	if !reflect.DeepEqual(domains, expected) {
+		t.Errorf("Domains() = %+v, want %+v", domains, expected)
+	}
+}
+
+func TestNormalizeVhostName(t *testing.T) {
+	tests := map[string]string{
+		"Example.COM":                "example.com",
+		"*.example.com":              "*.example.com",
+		".example.com":               "example.com",
+		"example.com:8443":           "example.com",
+		"_":                          "",
+		"localhost":                  "",
+		"intranet":                   "",
+		"10.0.0.1":                   "",
+		"www.example.*":              "",
+		"~^www\\d+\\.example\\.com$": "",
+	}
+	for in, want := range tests {
+		if got := normalizeVhostName(in); got != want {
+			t.Errorf("normalizeVhostName(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
@@ -0,0 +1,81 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"os"
+
+	"filippo.io/age"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// AgeWrapper wraps data keys for a set of age recipients
+type AgeWrapper struct {
+	recipients []age.Recipient
+	identities []age.Identity
+}
+
+// NewAgeWrapper parses the configured recipients and identity file
+func NewAgeWrapper(cfg config.AgeEncryption) (*AgeWrapper, error) {
+	var recipients []age.Recipient
+	for _, r := range cfg.Recipients {
+		recipient, err := age.ParseX25519Recipient(r)
+		if err != nil {
+			return nil, fmt.Errorf("invalid age recipient %q: %w", r, err)
+		}
+		recipients = append(recipients, recipient)
+	}
+	if len(recipients) == 0 {
+		return nil, fmt.Errorf("at least one age recipient is required")
+	}
+
+	var identities []age.Identity
+	if cfg.IdentityFile != "" {
+		f, err := os.Open(cfg.IdentityFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open age identity file: %w", err)
+		}
+		defer f.Close()
+
+		identities, err = age.ParseIdentities(f)
+		if err != nil {
+			return nil, fmt.Errorf("failed to parse age identity file: %w", err)
+		}
+	}
+
+	return &AgeWrapper{recipients: recipients, identities: identities}, nil
+}
+
+func (w *AgeWrapper) Name() string {
+	return "age"
+}
+
+func (w *AgeWrapper) WrapKey(_ context.Context, dataKey []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	writer, err := age.Encrypt(&buf, w.recipients...)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := writer.Write(dataKey); err != nil {
+		return nil, err
+	}
+	if err := writer.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (w *AgeWrapper) UnwrapKey(_ context.Context, wrapped []byte) ([]byte, error) {
+	if len(w.identities) == 0 {
+		return nil, fmt.Errorf("no age identity configured")
+	}
+
+	reader, err := age.Decrypt(bytes.NewReader(wrapped), w.identities...)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(reader)
+}
@@ -0,0 +1,168 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// pemType marks private keys sealed by an Envelope
+const pemType = "CERT-MANAGER ENCRYPTED PRIVATE KEY"
+
+// ErrNotSealed is returned when Open is given data that was not produced by Seal
+var ErrNotSealed = errors.New("data is not an encrypted private key")
+
+// KeyWrapper protects data keys with a key held by an external custodian
+type KeyWrapper interface {
+	Name() string
+	WrapKey(ctx context.Context, dataKey []byte) ([]byte, error)
+	UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error)
+}
+
+// Envelope seals data with a fresh AES-256-GCM data key per call and stores
+// the data key wrapped by a KeyWrapper alongside the ciphertext
+type Envelope struct {
+	wrapper KeyWrapper
+}
+
+// NewEnvelope creates an envelope around the given key wrapper
+func NewEnvelope(wrapper KeyWrapper) *Envelope {
+	return &Envelope{wrapper: wrapper}
+}
+
+// New builds an envelope for the configured provider. It returns nil when
+// encryption is disabled.
+func New(cfg config.Encryption) (*Envelope, error) {
+	var (
+		wrapper KeyWrapper
+		err     error
+	)
+
+	switch cfg.Provider {
+	case "":
+		return nil, nil
+	case "age":
+		wrapper, err = NewAgeWrapper(cfg.Age)
+	case "aws-kms":
+		wrapper, err = NewAWSKMSWrapper(cfg.AWSKMS)
+	case "gcp-kms":
+		wrapper, err = NewGCPKMSWrapper(cfg.GCPKMS)
+	default:
+		return nil, fmt.Errorf("unknown encryption provider: %s", cfg.Provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s key wrapper: %w", cfg.Provider, err)
+	}
+
+	return NewEnvelope(wrapper), nil
+}
+
+// Provider returns the name of the key wrapper in use
+func (e *Envelope) Provider() string {
+	return e.wrapper.Name()
+}
+
+// Seal encrypts plaintext and returns a PEM block carrying the wrapped data key
+func (e *Envelope) Seal(ctx context.Context, plaintext []byte) ([]byte, error) {
+	dataKey := make([]byte, 32)
+	if _, err := io.ReadFull(rand.Reader, dataKey); err != nil {
+		return nil, fmt.Errorf("failed to generate data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, gcm.NonceSize())
+	if _, err := io.ReadFull(rand.Reader, nonce); err != nil {
+		return nil, fmt.Errorf("failed to generate nonce: %w", err)
+	}
+
+	wrapped, err := e.wrapper.WrapKey(ctx, dataKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to wrap data key: %w", err)
+	}
+
+	block := &pem.Block{
+		Type: pemType,
+		Headers: map[string]string{
+			"Provider":    e.wrapper.Name(),
+			"Wrapped-Key": base64.StdEncoding.EncodeToString(wrapped),
+			"Nonce":       base64.StdEncoding.EncodeToString(nonce),
+		},
+		Bytes: gcm.Seal(nil, nonce, plaintext, []byte(e.wrapper.Name())),
+	}
+
+	return pem.EncodeToMemory(block), nil
+}
+
+// Open decrypts data produced by Seal
+func (e *Envelope) Open(ctx context.Context, data []byte) ([]byte, error) {
+	block, _ := pem.Decode(data)
+	if block == nil || block.Type != pemType {
+		return nil, ErrNotSealed
+	}
+
+	provider := block.Headers["Provider"]
+	if provider != e.wrapper.Name() {
+		return nil, fmt.Errorf("key was sealed with provider %q, but %q is configured", provider, e.wrapper.Name())
+	}
+
+	wrapped, err := base64.StdEncoding.DecodeString(block.Headers["Wrapped-Key"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid wrapped key: %w", err)
+	}
+	nonce, err := base64.StdEncoding.DecodeString(block.Headers["Nonce"])
+	if err != nil {
+		return nil, fmt.Errorf("invalid nonce: %w", err)
+	}
+
+	dataKey, err := e.wrapper.UnwrapKey(ctx, wrapped)
+	if err != nil {
+		return nil, fmt.Errorf("failed to unwrap data key: %w", err)
+	}
+	defer clear(dataKey)
+
+	gcm, err := newGCM(dataKey)
+	if err != nil {
+		return nil, err
+	}
+	if len(nonce) != gcm.NonceSize() {
+		return nil, fmt.Errorf("invalid nonce length %d", len(nonce))
+	}
+
+	plaintext, err := gcm.Open(nil, nonce, block.Bytes, []byte(provider))
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %w", err)
+	}
+
+	return plaintext, nil
+}
+
+// IsSealed reports whether data looks like the output of Seal
+func IsSealed(data []byte) bool {
+	return bytes.Contains(data, []byte("-----BEGIN "+pemType+"-----"))
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create cipher: %w", err)
+	}
+	gcm, err := cipher.NewGCM(block)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create GCM: %w", err)
+	}
+	return gcm, nil
+}
@@ -0,0 +1,167 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+const gcpMetadataTokenURL = "http://metadata.google.internal/computeMetadata/v1/instance/service-accounts/default/token"
+
+// GCPKMSWrapper wraps data keys with the Cloud KMS encrypt and decrypt REST APIs
+type GCPKMSWrapper struct {
+	keyName    string
+	endpoint   string
+	tokenURL   string
+	httpClient *http.Client
+
+	mu          sync.Mutex
+	token       string
+	tokenExpiry time.Time
+}
+
+// NewGCPKMSWrapper creates a wrapper for the given crypto key
+func NewGCPKMSWrapper(cfg config.GCPKMS) (*GCPKMSWrapper, error) {
+	if !strings.HasPrefix(cfg.KeyName, "projects/") || !strings.Contains(cfg.KeyName, "/cryptoKeys/") {
+		return nil, fmt.Errorf("key_name must have the form projects/*/locations/*/keyRings/*/cryptoKeys/*")
+	}
+
+	endpoint := cfg.Endpoint
+	if endpoint == "" {
+		endpoint = "https://cloudkms.googleapis.com"
+	}
+
+	return &GCPKMSWrapper{
+		keyName:    cfg.KeyName,
+		endpoint:   strings.TrimSuffix(endpoint, "/"),
+		tokenURL:   gcpMetadataTokenURL,
+		httpClient: &http.Client{Timeout: 30 * time.Second},
+	}, nil
+}
+
+func (w *GCPKMSWrapper) Name() string {
+	return "gcp-kms"
+}
+
+func (w *GCPKMSWrapper) WrapKey(ctx context.Context, dataKey []byte) ([]byte, error) {
+	var resp struct {
+		Ciphertext []byte `json:"ciphertext"`
+	}
+	if err := w.call(ctx, "encrypt", map[string][]byte{"plaintext": dataKey}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Ciphertext, nil
+}
+
+func (w *GCPKMSWrapper) UnwrapKey(ctx context.Context, wrapped []byte) ([]byte, error) {
+	var resp struct {
+		Plaintext []byte `json:"plaintext"`
+	}
+	if err := w.call(ctx, "decrypt", map[string][]byte{"ciphertext": wrapped}, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Plaintext, nil
+}
+
+func (w *GCPKMSWrapper) call(ctx context.Context, method string, in, out interface{}) error {
+	body, err := json.Marshal(in)
+	if err != nil {
+		return fmt.Errorf("failed to encode KMS request: %w", err)
+	}
+
+	url := fmt.Sprintf("%s/v1/%s:%s", w.endpoint, w.keyName, method)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create KMS request: %w", err)
+	}
+	return w.do(req, method, out)
+}
+
+// get fetches a sub-resource of the key, such as its public key
+func (w *GCPKMSWrapper) get(ctx context.Context, resource string, out interface{}) error {
+	url := fmt.Sprintf("%s/v1/%s/%s", w.endpoint, w.keyName, resource)
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+	if err != nil {
+		return fmt.Errorf("failed to create KMS request: %w", err)
+	}
+	return w.do(req, resource, out)
+}
+
+func (w *GCPKMSWrapper) do(req *http.Request, method string, out interface{}) error {
+	token, err := w.accessToken(req.Context())
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Authorization", "Bearer "+token)
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to call KMS %s: %w", method, err)
+	}
+	defer resp.Body.Close()
+
+	respBody, _ := io.ReadAll(resp.Body)
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("KMS %s returned status %d: %s", method, resp.StatusCode, respBody)
+	}
+
+	if err := json.Unmarshal(respBody, out); err != nil {
+		return fmt.Errorf("failed to decode KMS %s response: %w", method, err)
+	}
+	return nil
+}
+
+// accessToken returns GOOGLE_OAUTH_ACCESS_TOKEN when set, otherwise a cached
+// token for the instance's default service account
+func (w *GCPKMSWrapper) accessToken(ctx context.Context) (string, error) {
+	if token := os.Getenv("GOOGLE_OAUTH_ACCESS_TOKEN"); token != "" {
+		return token, nil
+	}
+
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.token != "" && time.Now().Before(w.tokenExpiry) {
+		return w.token, nil
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, w.tokenURL, nil)
+	if err != nil {
+		return "", fmt.Errorf("failed to create token request: %w", err)
+	}
+	req.Header.Set("Metadata-Flavor", "Google")
+
+	resp, err := w.httpClient.Do(req)
+	if err != nil {
+		return "", fmt.Errorf("failed to fetch access token from metadata server: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("metadata server returned status %d", resp.StatusCode)
+	}
+
+	var token struct {
+		AccessToken string `json:"access_token"`
+		ExpiresIn   int    `json:"expires_in"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&token); err != nil {
+		return "", fmt.Errorf("failed to decode access token: %w", err)
+	}
+
+	// Refresh a minute early so in-flight calls never carry an expired token
+	w.token = token.AccessToken
+	w.tokenExpiry = time.Now().Add(time.Duration(token.ExpiresIn)*time.Second - time.Minute)
+
+	return w.token, nil
+}
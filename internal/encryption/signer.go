@@ -0,0 +1,228 @@
+package encryption
+
+import (
+	"bytes"
+	"context"
+	"crypto"
+	"crypto/x509"
+	"encoding/pem"
+	"fmt"
+	"io"
+	"os"
+	"os/exec"
+	"runtime"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// signTimeout bounds a single signature by a KMS or signing program
+const signTimeout = 30 * time.Second
+
+// NewSigner returns the signer of an account key held by the configured
+// provider. It returns nil when the account key is kept in the storage path.
+func NewSigner(provider string, cfg config.AccountKey) (crypto.Signer, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	var (
+		signer crypto.Signer
+		err    error
+	)
+
+	switch provider {
+	case "":
+		return nil, nil
+	case "aws-kms":
+		signer, err = NewAWSKMSSigner(ctx, cfg.AWSKMS)
+	case "gcp-kms":
+		signer, err = NewGCPKMSSigner(ctx, cfg.GCPKMS)
+	case "command":
+		signer, err = NewCommandSigner(cfg.Command)
+	default:
+		return nil, fmt.Errorf("unknown account key provider: %s", provider)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to initialize %s account key: %w", provider, err)
+	}
+	return signer, nil
+}
+
+// AWSKMSSigner signs digests with an asymmetric AWS KMS key
+type AWSKMSSigner struct {
+	kms    *AWSKMSWrapper
+	public crypto.PublicKey
+}
+
+// NewAWSKMSSigner fetches the public key of the given KMS key
+func NewAWSKMSSigner(ctx context.Context, cfg config.AWSKMS) (*AWSKMSSigner, error) {
+	kms, err := NewAWSKMSWrapper(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		PublicKey []byte `json:"PublicKey"`
+	}
+	if err := kms.call(ctx, "GetPublicKey", map[string]interface{}{"KeyId": kms.keyID}, &resp); err != nil {
+		return nil, err
+	}
+	public, err := x509.ParsePKIXPublicKey(resp.PublicKey)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse KMS public key: %w", err)
+	}
+	return &AWSKMSSigner{kms: kms, public: public}, nil
+}
+
+func (s *AWSKMSSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign returns the DER encoded ECDSA signature of digest
+func (s *AWSKMSSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var algorithm string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		algorithm = "ECDSA_SHA_256"
+	case crypto.SHA384:
+		algorithm = "ECDSA_SHA_384"
+	default:
+		return nil, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	var resp struct {
+		Signature []byte `json:"Signature"`
+	}
+	req := map[string]interface{}{
+		"KeyId":            s.kms.keyID,
+		"Message":          digest,
+		"MessageType":      "DIGEST",
+		"SigningAlgorithm": algorithm,
+	}
+	if err := s.kms.call(ctx, "Sign", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// GCPKMSSigner signs digests with a Cloud KMS asymmetric key version
+type GCPKMSSigner struct {
+	kms    *GCPKMSWrapper
+	public crypto.PublicKey
+}
+
+// NewGCPKMSSigner fetches the public key of the given crypto key version
+func NewGCPKMSSigner(ctx context.Context, cfg config.GCPKMS) (*GCPKMSSigner, error) {
+	kms, err := NewGCPKMSWrapper(cfg)
+	if err != nil {
+		return nil, err
+	}
+
+	var resp struct {
+		PEM string `json:"pem"`
+	}
+	if err := kms.get(ctx, "publicKey", &resp); err != nil {
+		return nil, err
+	}
+	public, err := parsePublicKey([]byte(resp.PEM))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse KMS public key: %w", err)
+	}
+	return &GCPKMSSigner{kms: kms, public: public}, nil
+}
+
+func (s *GCPKMSSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign returns the DER encoded ECDSA signature of digest
+func (s *GCPKMSSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	var field string
+	switch opts.HashFunc() {
+	case crypto.SHA256:
+		field = "sha256"
+	case crypto.SHA384:
+		field = "sha384"
+	default:
+		return nil, fmt.Errorf("unsupported hash %v", opts.HashFunc())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	var resp struct {
+		Signature []byte `json:"signature"`
+	}
+	req := map[string]interface{}{"digest": map[string][]byte{field: digest}}
+	if err := s.kms.call(ctx, "asymmetricSign", req, &resp); err != nil {
+		return nil, err
+	}
+	return resp.Signature, nil
+}
+
+// CommandSigner signs digests by running a program, for keys held by a PKCS#11
+// token or another device without a network API
+type CommandSigner struct {
+	program string
+	public  crypto.PublicKey
+}
+
+// NewCommandSigner reads the public key of the program's key
+func NewCommandSigner(cfg config.AccountKeyCommand) (*CommandSigner, error) {
+	data, err := os.ReadFile(cfg.PublicKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read public key file: %w", err)
+	}
+	public, err := parsePublicKey(data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse public key file %s: %w", cfg.PublicKeyFile, err)
+	}
+	return &CommandSigner{program: cfg.Program, public: public}, nil
+}
+
+func (s *CommandSigner) Public() crypto.PublicKey {
+	return s.public
+}
+
+// Sign passes digest to the program on stdin and returns the DER encoded
+// signature it writes to stdout
+func (s *CommandSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(context.Background(), signTimeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", s.program)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", s.program)
+	}
+	cmd.Env = append(os.Environ(), "CERT_MANAGER_DIGEST="+opts.HashFunc().String())
+	cmd.Stdin = bytes.NewReader(digest)
+
+	var stdout, stderr bytes.Buffer
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		return nil, fmt.Errorf("signing program failed: %w: %s", err, bytes.TrimSpace(stderr.Bytes()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// parsePublicKey reads a PEM public key or the public key of a PEM certificate
+func parsePublicKey(data []byte) (crypto.PublicKey, error) {
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, fmt.Errorf("no PEM data found")
+	}
+	if block.Type == "CERTIFICATE" {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, err
+		}
+		return cert.PublicKey, nil
+	}
+	return x509.ParsePKIXPublicKey(block.Bytes)
+}
@@ -0,0 +1,141 @@
+package encryption
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func TestAWSKMSSigner(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "AKIDEXAMPLE")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "secret")
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Message          []byte
+			MessageType      string
+			SigningAlgorithm string
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+
+		switch r.Header.Get("X-Amz-Target") {
+		case "TrentService.GetPublicKey":
+			json.NewEncoder(w).Encode(map[string][]byte{"PublicKey": public})
+		case "TrentService.Sign":
+			if req.MessageType != "DIGEST" || req.SigningAlgorithm != "ECDSA_SHA_256" {
+				t.Errorf("unexpected Sign request: %+v", req)
+			}
+			sig, _ := ecdsa.SignASN1(rand.Reader, key, req.Message)
+			json.NewEncoder(w).Encode(map[string][]byte{"Signature": sig})
+		default:
+			w.WriteHeader(http.StatusBadRequest)
+		}
+	}))
+	defer server.Close()
+
+	signer, err := NewSigner("aws-kms", config.AccountKey{
+		AWSKMS: config.AWSKMS{KeyID: "alias/acme", Region: "us-east-1", Endpoint: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifySigner(t, signer, &key.PublicKey)
+}
+
+func TestGCPKMSSigner(t *testing.T) {
+	t.Setenv("GOOGLE_OAUTH_ACCESS_TOKEN", "test-token")
+
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	keyName := "projects/p/locations/global/keyRings/r/cryptoKeys/k/cryptoKeyVersions/1"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch {
+		case r.Method == http.MethodGet && r.URL.Path == "/v1/"+keyName+"/publicKey":
+			json.NewEncoder(w).Encode(map[string]string{
+				"pem": string(pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public})),
+			})
+		case r.Method == http.MethodPost && r.URL.Path == "/v1/"+keyName+":asymmetricSign":
+			var req struct {
+				Digest map[string][]byte `json:"digest"`
+			}
+			json.NewDecoder(r.Body).Decode(&req)
+			sig, _ := ecdsa.SignASN1(rand.Reader, key, req.Digest["sha256"])
+			json.NewEncoder(w).Encode(map[string][]byte{"signature": sig})
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	signer, err := NewSigner("gcp-kms", config.AccountKey{
+		GCPKMS: config.GCPKMS{KeyName: keyName, Endpoint: server.URL},
+	})
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	verifySigner(t, signer, &key.PublicKey)
+}
+
+func TestCommandSigner(t *testing.T) {
+	key, _ := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	public, _ := x509.MarshalPKIXPublicKey(&key.PublicKey)
+
+	path := filepath.Join(t.TempDir(), "account.pub")
+	if err := os.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: public}), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	// The program gets the digest on stdin; cat makes it visible in the output
+	signer, err := NewSigner("command", config.AccountKey{
+		Command: config.AccountKeyCommand{Program: "cat", PublicKeyFile: path},
+	})
+	if err != nil {
+		t.Fatalf("NewSigner() error = %v", err)
+	}
+	if !signer.Public().(*ecdsa.PublicKey).Equal(&key.PublicKey) {
+		t.Error("Public() does not return the key of public_key_file")
+	}
+	out, err := signer.Sign(rand.Reader, []byte("digest"), crypto.SHA256)
+	if err != nil || string(out) != "digest" {
+		t.Errorf("Sign() = %q, %v; want the program's output", out, err)
+	}
+
+	failing, _ := NewSigner("command", config.AccountKey{
+		Command: config.AccountKeyCommand{Program: "echo token locked >&2; exit 1", PublicKeyFile: path},
+	})
+	if _, err := failing.Sign(rand.Reader, []byte("digest"), crypto.SHA256); err == nil {
+		t.Error("Sign() should fail when the program fails")
+	}
+}
+
+func verifySigner(t *testing.T, signer crypto.Signer, want *ecdsa.PublicKey) {
+	t.Helper()
+
+	if !signer.Public().(*ecdsa.PublicKey).Equal(want) {
+		t.Fatal("Public() does not return the KMS key")
+	}
+	digest := sha256.Sum256([]byte("signed content"))
+	sig, err := signer.Sign(rand.Reader, digest[:], crypto.SHA256)
+	if err != nil {
+		t.Fatalf("Sign() error = %v", err)
+	}
+	if !ecdsa.VerifyASN1(want, digest[:], sig) {
+		t.Error("signature does not verify")
+	}
+}
@@ -0,0 +1,160 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"os"
+	"sort"
+	"sync"
+	"time"
+)
+
+// checkTimeout bounds a single readiness or liveness check
+const checkTimeout = 5 * time.Second
+
+// Check reports whether a dependency is usable
+type Check func(ctx context.Context) error
+
+// Response is the JSON body returned by every endpoint
+type Response struct {
+	Status string            `json:"status"` // ok or failing
+	Checks map[string]string `json:"checks,omitempty"`
+}
+
+// Server exposes /healthz, /readyz and /livez for orchestrators and load balancers.
+//
+//   - /healthz answers as long as the process is serving requests
+//   - /readyz runs the readiness checks (configuration, storage, Traefik API)
+//   - /livez runs the liveness checks (background workers still running)
+type Server struct {
+	logger *log.Logger
+	server *http.Server
+
+	mu        sync.RWMutex
+	readiness map[string]Check
+	liveness  map[string]Check
+}
+
+func NewServer(addr string, logger *log.Logger) *Server {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Health] ", log.LstdFlags)
+	}
+
+	s := &Server{
+		logger:    logger,
+		readiness: make(map[string]Check),
+		liveness:  make(map[string]Check),
+	}
+	s.server = &http.Server{
+		Addr:    addr,
+		Handler: s.Handler(),
+	}
+	return s
+}
+
+// AddReadinessCheck registers a check that must pass before the daemon receives traffic
+func (s *Server) AddReadinessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.readiness[name] = check
+}
+
+// AddLivenessCheck registers a check whose failure means the daemon should be restarted
+func (s *Server) AddLivenessCheck(name string, check Check) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.liveness[name] = check
+}
+
+func (s *Server) Handler() http.Handler {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/healthz", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, Response{Status: "ok"})
+	})
+	mux.HandleFunc("/readyz", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, s.run(r.Context(), s.checks(s.readiness)))
+	})
+	mux.HandleFunc("/livez", func(w http.ResponseWriter, r *http.Request) {
+		writeResponse(w, s.run(r.Context(), s.checks(s.liveness)))
+	})
+	return mux
+}
+
+// Start serves the endpoints in the background
+func (s *Server) Start() {
+	go func() {
+		s.logger.Printf("Serving health checks on %s (/healthz, /readyz, /livez)", s.server.Addr)
+		if err := s.server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+			s.logger.Printf("Health server failed: %v", err)
+		}
+	}()
+}
+
+func (s *Server) Shutdown(ctx context.Context) error {
+	return s.server.Shutdown(ctx)
+}
+
+func (s *Server) checks(set map[string]Check) map[string]Check {
+	s.mu.RLock()
+	defer s.mu.RUnlock()
+
+	result := make(map[string]Check, len(set))
+	for name, check := range set {
+		result[name] = check
+	}
+	return result
+}
+
+// run executes checks concurrently so one slow dependency doesn't delay the others
+func (s *Server) run(ctx context.Context, checks map[string]Check) Response {
+	ctx, cancel := context.WithTimeout(ctx, checkTimeout)
+	defer cancel()
+
+	resp := Response{Status: "ok", Checks: make(map[string]string, len(checks))}
+
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	for name, check := range checks {
+		wg.Add(1)
+		go func(name string, check Check) {
+			defer wg.Done()
+
+			result := "ok"
+			if err := check(ctx); err != nil {
+				result = err.Error()
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+			resp.Checks[name] = result
+			if result != "ok" {
+				resp.Status = "failing"
+			}
+		}(name, check)
+	}
+	wg.Wait()
+
+	if resp.Status != "ok" {
+		failed := make([]string, 0)
+		for name, result := range resp.Checks {
+			if result != "ok" {
+				failed = append(failed, name)
+			}
+		}
+		sort.Strings(failed)
+		s.logger.Printf("Health checks failing: %v", failed)
+	}
+
+	return resp
+}
+
+func writeResponse(w http.ResponseWriter, resp Response) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	if resp.Status != "ok" {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	json.NewEncoder(w).Encode(resp)
+}
@@ -0,0 +1,87 @@
+package health
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func get(t *testing.T, handler http.Handler, path string) (int, Response) {
+	t.Helper()
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, path, nil))
+
+	var resp Response
+	if err := json.NewDecoder(rec.Body).Decode(&resp); err != nil {
+		t.Fatalf("failed to decode %s response: %v", path, err)
+	}
+	return rec.Code, resp
+}
+
+func TestServer_Healthz(t *testing.T) {
+	s := NewServer(":0", nil)
+	s.AddReadinessCheck("traefik", func(ctx context.Context) error {
+		return errors.New("unreachable")
+	})
+
+	code, resp := get(t, s.Handler(), "/healthz")
+	if code != http.StatusOK || resp.Status != "ok" {
+		t.Errorf("/healthz = %d %q, want 200 ok regardless of readiness", code, resp.Status)
+	}
+}
+
+func TestServer_Readyz(t *testing.T) {
+	s := NewServer(":0", nil)
+	s.AddReadinessCheck("storage", func(ctx context.Context) error { return nil })
+
+	code, resp := get(t, s.Handler(), "/readyz")
+	if code != http.StatusOK {
+		t.Errorf("/readyz = %d, want 200", code)
+	}
+	if resp.Checks["storage"] != "ok" {
+		t.Errorf("storage check = %q, want ok", resp.Checks["storage"])
+	}
+
+	s.AddReadinessCheck("traefik", func(ctx context.Context) error {
+		return errors.New("connection refused")
+	})
+
+	code, resp = get(t, s.Handler(), "/readyz")
+	if code != http.StatusServiceUnavailable {
+		t.Errorf("/readyz = %d, want 503", code)
+	}
+	if resp.Status != "failing" {
+		t.Errorf("status = %q, want failing", resp.Status)
+	}
+	if resp.Checks["traefik"] != "connection refused" {
+		t.Errorf("traefik check = %q, want the check error", resp.Checks["traefik"])
+	}
+	if resp.Checks["storage"] != "ok" {
+		t.Errorf("storage check = %q, want ok", resp.Checks["storage"])
+	}
+}
+
+func TestServer_Livez(t *testing.T) {
+	s := NewServer(":0", nil)
+
+	running := true
+	s.AddLivenessCheck("scheduler", func(ctx context.Context) error {
+		if !running {
+			return errors.New("scheduler is not running")
+		}
+		return nil
+	})
+
+	if code, _ := get(t, s.Handler(), "/livez"); code != http.StatusOK {
+		t.Errorf("/livez = %d, want 200", code)
+	}
+
+	running = false
+	if code, _ := get(t, s.Handler(), "/livez"); code != http.StatusServiceUnavailable {
+		t.Errorf("/livez = %d, want 503", code)
+	}
+}
@@ -0,0 +1,146 @@
+package hooks
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// Event names a point in the certificate lifecycle that hooks can attach to
+type Event string
+
+const (
+	EventPostIssue Event = "post_issue"
+	EventPostRenew Event = "post_renew"
+	EventOnFailure Event = "on_failure"
+)
+
+// Context describes the certificate a hook runs for
+type Context struct {
+	Domain        string
+	Service       string
+	CertPath      string
+	KeyPath       string
+	IssuerPath    string
+	FullChainPath string
+	ExpiresAt     time.Time
+	Err           error // set for on_failure
+}
+
+// Runner executes global and per-domain hook commands
+type Runner struct {
+	global  config.Hooks
+	timeout time.Duration
+	logger  *log.Logger
+}
+
+func NewRunner(global config.Hooks, timeout time.Duration, logger *log.Logger) *Runner {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Hooks] ", log.LstdFlags)
+	}
+
+	return &Runner{
+		global:  global,
+		timeout: timeout,
+		logger:  logger,
+	}
+}
+
+// Run executes the global commands for event followed by the domain's own.
+// Every command runs even if an earlier one fails; the first error is returned.
+// Once ctx is done the running command is killed and the rest are skipped.
+func (r *Runner) Run(ctx context.Context, event Event, domainHooks config.Hooks, hc Context) error {
+	commands := append(append([]string{}, r.global.Commands(string(event))...), domainHooks.Commands(string(event))...)
+	if len(commands) == 0 {
+		return nil
+	}
+
+	env := append(os.Environ(), environment(event, hc)...)
+
+	var firstErr error
+	for _, command := range commands {
+		if err := ctx.Err(); err != nil {
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s hooks cancelled: %w", event, err)
+			}
+			break
+		}
+		if err := r.runCommand(ctx, command, env); err != nil {
+			r.logger.Printf("%s hook for %s failed: %v", event, hc.Domain, err)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("%s hook %q failed: %w", event, command, err)
+			}
+			continue
+		}
+		r.logger.Printf("Ran %s hook for %s: %s", event, hc.Domain, command)
+	}
+
+	return firstErr
+}
+
+func (r *Runner) runCommand(parent context.Context, command string, env []string) error {
+	ctx := parent
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+	cmd.Env = env
+	setProcessGroup(cmd)
+	// Don't wait forever on output pipes held open by orphaned children
+	cmd.WaitDelay = time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	err := cmd.Run()
+	if parent.Err() != nil {
+		return parent.Err()
+	}
+	if ctx.Err() == context.DeadlineExceeded {
+		return fmt.Errorf("timed out after %v", r.timeout)
+	}
+	if err != nil {
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("%w: %s", err, out)
+		}
+		return err
+	}
+
+	return nil
+}
+
+func environment(event Event, hc Context) []string {
+	env := []string{
+		"CERT_MANAGER_EVENT=" + string(event),
+		"CERT_MANAGER_DOMAIN=" + hc.Domain,
+		"CERT_MANAGER_SERVICE=" + hc.Service,
+		"CERT_MANAGER_CERT_PATH=" + hc.CertPath,
+		"CERT_MANAGER_KEY_PATH=" + hc.KeyPath,
+		"CERT_MANAGER_ISSUER_PATH=" + hc.IssuerPath,
+		"CERT_MANAGER_FULLCHAIN_PATH=" + hc.FullChainPath,
+	}
+	if !hc.ExpiresAt.IsZero() {
+		env = append(env, "CERT_MANAGER_EXPIRES_AT="+hc.ExpiresAt.UTC().Format(time.RFC3339))
+	}
+	if hc.Err != nil {
+		env = append(env, "CERT_MANAGER_ERROR="+hc.Err.Error())
+	}
+	return env
+}
@@ -0,0 +1,120 @@
+package hooks
+
+import (
+	"context"
+	"errors"
+	"log"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func skipOnWindows(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("hook tests use POSIX shell syntax")
+	}
+}
+
+func TestRunner_Run(t *testing.T) {
+	skipOnWindows(t)
+
+	out := filepath.Join(t.TempDir(), "out")
+	global := config.Hooks{
+		PostRenew: []string{`echo "global $CERT_MANAGER_EVENT $CERT_MANAGER_DOMAIN $CERT_MANAGER_EXPIRES_AT" >> ` + out},
+	}
+	domain := config.Hooks{
+		PostRenew: []string{`echo "domain $CERT_MANAGER_SERVICE $CERT_MANAGER_CERT_PATH" >> ` + out},
+		PostIssue: []string{`echo "not run" >> ` + out},
+	}
+
+	runner := NewRunner(global, 10*time.Second, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+	err := runner.Run(context.Background(), EventPostRenew, domain, Context{
+		Domain:    "example.com",
+		Service:   "web",
+		CertPath:  "/certs/example.com.crt",
+		ExpiresAt: time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC),
+	})
+	if err != nil {
+		t.Fatalf("Run() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("failed to read hook output: %v", err)
+	}
+
+	want := "global post_renew example.com 2030-01-02T03:04:05Z\ndomain web /certs/example.com.crt\n"
+	if string(data) != want {
+		t.Errorf("hook output = %q, want %q", data, want)
+	}
+}
+
+func TestRunner_RunFailure(t *testing.T) {
+	skipOnWindows(t)
+
+	out := filepath.Join(t.TempDir(), "out")
+	global := config.Hooks{
+		OnFailure: []string{
+			`echo "boom" >&2; exit 3`,
+			`echo "$CERT_MANAGER_ERROR" > ` + out,
+		},
+	}
+
+	runner := NewRunner(global, 10*time.Second, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+	err := runner.Run(context.Background(), EventOnFailure, config.Hooks{}, Context{
+		Domain: "example.com",
+		Err:    errors.New("rate limited"),
+	})
+	if err == nil || !strings.Contains(err.Error(), "boom") {
+		t.Errorf("Run() error = %v, want the failing command's output", err)
+	}
+
+	// Later commands still run after a failure
+	data, _ := os.ReadFile(out)
+	if strings.TrimSpace(string(data)) != "rate limited" {
+		t.Errorf("CERT_MANAGER_ERROR = %q, want %q", data, "rate limited")
+	}
+}
+
+func TestRunner_Timeout(t *testing.T) {
+	skipOnWindows(t)
+
+	global := config.Hooks{PostIssue: []string{"sleep 5"}}
+	runner := NewRunner(global, 100*time.Millisecond, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+
+	start := time.Now()
+	err := runner.Run(context.Background(), EventPostIssue, config.Hooks{}, Context{Domain: "example.com"})
+	if err == nil || !strings.Contains(err.Error(), "timed out") {
+		t.Errorf("Run() error = %v, want timeout", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Run() did not stop the command at the timeout")
+	}
+}
+
+func TestRunner_Cancelled(t *testing.T) {
+	skipOnWindows(t)
+
+	marker := filepath.Join(t.TempDir(), "ran")
+	global := config.Hooks{PostIssue: []string{"sleep 5", "touch " + marker}}
+	runner := NewRunner(global, time.Minute, log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	start := time.Now()
+	err := runner.Run(ctx, EventPostIssue, config.Hooks{}, Context{Domain: "example.com"})
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("Run() error = %v, want context.DeadlineExceeded", err)
+	}
+	if time.Since(start) > 3*time.Second {
+		t.Errorf("Run() did not stop the command once its context was done")
+	}
+	if _, err := os.Stat(marker); err == nil {
+		t.Errorf("Run() ran the next command after its context was done")
+	}
+}
@@ -0,0 +1,246 @@
+package inventory
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"reflect"
+	"runtime"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// stateFileName holds the inventory as last delivered, relative to the storage path
+const stateFileName = ".inventory-state.json"
+
+// Record is a certificate as the asset database sees it
+type Record struct {
+	Domain      string    `json:"domain"`
+	Service     string    `json:"service,omitempty"`
+	SANs        []string  `json:"sans"`
+	Serial      string    `json:"serial"`
+	Fingerprint string    `json:"fingerprint_sha256"`
+	Issuer      string    `json:"issuer"`
+	KeyType     string    `json:"key_type"`
+	NotBefore   time.Time `json:"not_before"`
+	NotAfter    time.Time `json:"not_after"`
+	CertPath    string    `json:"cert_path"`
+}
+
+// Delta is what changed in the inventory since the last successful delivery
+type Delta struct {
+	Host        string    `json:"host"`
+	GeneratedAt time.Time `json:"generated_at"`
+	Added       []Record  `json:"added"`
+	Updated     []Record  `json:"updated"`
+	Removed     []Record  `json:"removed"`
+}
+
+// Empty reports whether nothing changed
+func (d Delta) Empty() bool {
+	return len(d.Added) == 0 && len(d.Updated) == 0 && len(d.Removed) == 0
+}
+
+// Diff compares two inventories by domain
+func Diff(previous, current []Record) Delta {
+	before := make(map[string]Record, len(previous))
+	for _, record := range previous {
+		before[record.Domain] = record
+	}
+
+	delta := Delta{Added: []Record{}, Updated: []Record{}, Removed: []Record{}}
+	seen := make(map[string]bool, len(current))
+	for _, record := range current {
+		seen[record.Domain] = true
+		old, ok := before[record.Domain]
+		switch {
+		case !ok:
+			delta.Added = append(delta.Added, record)
+		case !reflect.DeepEqual(normalize(old), normalize(record)):
+			delta.Updated = append(delta.Updated, record)
+		}
+	}
+	for _, record := range previous {
+		if !seen[record.Domain] {
+			delta.Removed = append(delta.Removed, record)
+		}
+	}
+
+	for _, records := range [][]Record{delta.Added, delta.Updated, delta.Removed} {
+		sort.Slice(records, func(i, j int) bool { return records[i].Domain < records[j].Domain })
+	}
+	return delta
+}
+
+// normalize makes records read back from the state file comparable with fresh ones
+func normalize(r Record) Record {
+	r.NotBefore = r.NotBefore.UTC()
+	r.NotAfter = r.NotAfter.UTC()
+	if len(r.SANs) == 0 {
+		r.SANs = nil
+	}
+	return r
+}
+
+// Reconciler delivers inventory deltas to a CMDB endpoint or plugin command
+type Reconciler struct {
+	cfg        config.Inventory
+	timeout    time.Duration
+	statePath  string
+	httpClient *http.Client
+	logger     *log.Logger
+}
+
+func NewReconciler(cfg config.Inventory, timeout time.Duration, storagePath string, logger *log.Logger) *Reconciler {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Inventory] ", log.LstdFlags)
+	}
+
+	return &Reconciler{
+		cfg:        cfg,
+		timeout:    timeout,
+		statePath:  filepath.Join(storagePath, stateFileName),
+		httpClient: &http.Client{Timeout: timeout},
+		logger:     logger,
+	}
+}
+
+// Reconcile delivers the difference between current and the inventory last
+// delivered. The state is only advanced once delivery succeeds, so a failed
+// delta is included again in the next one.
+func (r *Reconciler) Reconcile(ctx context.Context, current []Record) (Delta, error) {
+	previous, err := r.loadState()
+	if err != nil {
+		return Delta{}, err
+	}
+
+	delta := Diff(previous, current)
+	if delta.Empty() {
+		return delta, nil
+	}
+	delta.Host, _ = os.Hostname()
+	delta.GeneratedAt = time.Now().UTC()
+
+	payload, err := json.Marshal(delta)
+	if err != nil {
+		return delta, fmt.Errorf("failed to encode inventory delta: %w", err)
+	}
+
+	if r.cfg.Command != "" {
+		err = r.runCommand(ctx, payload)
+	} else {
+		err = r.post(ctx, payload)
+	}
+	if err != nil {
+		return delta, err
+	}
+
+	if err := r.saveState(current); err != nil {
+		return delta, err
+	}
+	r.logger.Printf("Reported inventory changes: %d added, %d updated, %d removed",
+		len(delta.Added), len(delta.Updated), len(delta.Removed))
+	return delta, nil
+}
+
+func (r *Reconciler) post(ctx context.Context, payload []byte) error {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, r.cfg.Endpoint, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create inventory request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	switch {
+	case r.cfg.Token != "":
+		req.Header.Set("Authorization", "Bearer "+r.cfg.Token)
+	case r.cfg.Username != "":
+		req.SetBasicAuth(r.cfg.Username, r.cfg.Password)
+	}
+
+	resp, err := r.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to deliver inventory delta: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("inventory endpoint returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (r *Reconciler) runCommand(ctx context.Context, payload []byte) error {
+	if r.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, r.timeout)
+		defer cancel()
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", r.cfg.Command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", r.cfg.Command)
+	}
+	cmd.Stdin = bytes.NewReader(payload)
+	cmd.WaitDelay = time.Second
+
+	var output bytes.Buffer
+	cmd.Stdout = &output
+	cmd.Stderr = &output
+
+	if err := cmd.Run(); err != nil {
+		if ctx.Err() == context.DeadlineExceeded {
+			return fmt.Errorf("inventory command timed out after %v", r.timeout)
+		}
+		if out := strings.TrimSpace(output.String()); out != "" {
+			return fmt.Errorf("inventory command failed: %w: %s", err, out)
+		}
+		return fmt.Errorf("inventory command failed: %w", err)
+	}
+	return nil
+}
+
+func (r *Reconciler) loadState() ([]Record, error) {
+	data, err := os.ReadFile(r.statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read inventory state: %w", err)
+	}
+
+	var records []Record
+	if err := json.Unmarshal(data, &records); err != nil {
+		return nil, fmt.Errorf("failed to parse inventory state: %w", err)
+	}
+	return records, nil
+}
+
+func (r *Reconciler) saveState(records []Record) error {
+	data, err := json.MarshalIndent(records, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode inventory state: %w", err)
+	}
+
+	tmp := r.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write inventory state: %w", err)
+	}
+	if err := os.Rename(tmp, r.statePath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write inventory state: %w", err)
+	}
+	return nil
+}
@@ -0,0 +1,130 @@
+package inventory
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func testRecord(domain, serial string) Record {
+	return Record{
+		Domain:    domain,
+		SANs:      []string{domain},
+		Serial:    serial,
+		NotBefore: time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC),
+		NotAfter:  time.Date(2030, 4, 1, 0, 0, 0, 0, time.UTC),
+	}
+}
+
+func testLogger() *log.Logger {
+	return log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+}
+
+func TestDiff(t *testing.T) {
+	previous := []Record{testRecord("a.example.com", "01"), testRecord("b.example.com", "02"), testRecord("c.example.com", "03")}
+	current := []Record{testRecord("d.example.com", "04"), testRecord("b.example.com", "05"), testRecord("a.example.com", "01")}
+
+	delta := Diff(previous, current)
+	if len(delta.Added) != 1 || delta.Added[0].Domain != "d.example.com" {
+		t.Errorf("Added = %+v, want d.example.com", delta.Added)
+	}
+	if len(delta.Updated) != 1 || delta.Updated[0].Serial != "05" {
+		t.Errorf("Updated = %+v, want b.example.com with serial 05", delta.Updated)
+	}
+	if len(delta.Removed) != 1 || delta.Removed[0].Domain != "c.example.com" {
+		t.Errorf("Removed = %+v, want c.example.com", delta.Removed)
+	}
+
+	if !Diff(current, current).Empty() {
+		t.Errorf("Diff of an unchanged inventory is not empty")
+	}
+}
+
+func TestReconciler_Endpoint(t *testing.T) {
+	var deltas []Delta
+	fail := false
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("Authorization = %q", r.Header.Get("Authorization"))
+		}
+		if fail {
+			http.Error(w, "CMDB unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var delta Delta
+		if err := json.NewDecoder(r.Body).Decode(&delta); err != nil {
+			t.Errorf("failed to decode delta: %v", err)
+		}
+		deltas = append(deltas, delta)
+	}))
+	defer server.Close()
+
+	storage := t.TempDir()
+	reconciler := NewReconciler(config.Inventory{Endpoint: server.URL, Token: "secret"}, 5*time.Second, storage, testLogger())
+	ctx := context.Background()
+
+	first := []Record{testRecord("a.example.com", "01")}
+	if _, err := reconciler.Reconcile(ctx, first); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	// Nothing changed, nothing is sent
+	if _, err := reconciler.Reconcile(ctx, first); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(deltas) != 1 || len(deltas[0].Added) != 1 {
+		t.Fatalf("deltas = %+v, want one adding a.example.com", deltas)
+	}
+
+	// A failed delivery is repeated with the next change
+	fail = true
+	second := []Record{testRecord("a.example.com", "02")}
+	if _, err := reconciler.Reconcile(ctx, second); err == nil {
+		t.Fatalf("Reconcile() succeeded against a failing endpoint")
+	}
+	fail = false
+	third := append(second, testRecord("b.example.com", "03"))
+	if _, err := reconciler.Reconcile(ctx, third); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if len(deltas) != 2 || len(deltas[1].Updated) != 1 || len(deltas[1].Added) != 1 {
+		t.Errorf("second delta = %+v, want a.example.com updated and b.example.com added", deltas[len(deltas)-1])
+	}
+
+	if _, err := os.Stat(filepath.Join(storage, stateFileName)); err != nil {
+		t.Errorf("inventory state was not saved: %v", err)
+	}
+}
+
+func TestReconciler_Command(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses POSIX shell syntax")
+	}
+
+	out := filepath.Join(t.TempDir(), "delta.json")
+	reconciler := NewReconciler(config.Inventory{Command: "cat > " + out}, 5*time.Second, t.TempDir(), testLogger())
+
+	if _, err := reconciler.Reconcile(context.Background(), []Record{testRecord("a.example.com", "01")}); err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+
+	data, err := os.ReadFile(out)
+	if err != nil {
+		t.Fatalf("command did not receive the delta: %v", err)
+	}
+	var delta Delta
+	if err := json.Unmarshal(data, &delta); err != nil {
+		t.Fatalf("failed to decode delta: %v", err)
+	}
+	if len(delta.Added) != 1 || delta.Added[0].Domain != "a.example.com" {
+		t.Errorf("delta = %+v, want a.example.com added", delta)
+	}
+}
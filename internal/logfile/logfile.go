@@ -0,0 +1,179 @@
+package logfile
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// backupTimeFormat stamps rotated files, e.g. cert-manager-2024-05-01T00-00-00.000.log
+const backupTimeFormat = "2006-01-02T15-04-05.000"
+
+// Options control when a file is rotated and how many rotated files are kept.
+// Zero values disable the respective limit.
+type Options struct {
+	MaxSize    int64         // rotate before the file grows beyond this many bytes
+	Interval   time.Duration // rotate when a write crosses an interval boundary (UTC)
+	MaxBackups int           // rotated files to keep
+	MaxAge     time.Duration // remove rotated files older than this
+}
+
+// Writer appends to a file, renaming it with a timestamp when it grows too big
+// or an interval has passed, and removes rotated files beyond the retention
+// limits. It is safe for concurrent use.
+type Writer struct {
+	path string
+	opts Options
+	now  func() time.Time
+
+	mu      sync.Mutex
+	file    *os.File
+	size    int64
+	started time.Time // creation of the current file, or its last write when reopened
+}
+
+func New(path string, opts Options) *Writer {
+	return &Writer{path: path, opts: opts, now: time.Now}
+}
+
+func (w *Writer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		if err := w.open(); err != nil {
+			return 0, err
+		}
+	}
+	if w.due(int64(len(p))) {
+		if err := w.rotate(); err != nil {
+			return 0, err
+		}
+	}
+
+	n, err := w.file.Write(p)
+	w.size += int64(n)
+	return n, err
+}
+
+// Open opens the file ahead of the first write, to report an unusable path early
+func (w *Writer) Open() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file != nil {
+		return nil
+	}
+	return w.open()
+}
+
+// Close closes the current file; the next write reopens it
+func (w *Writer) Close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+// due reports whether the file must be rotated before writing n bytes
+func (w *Writer) due(n int64) bool {
+	if w.size == 0 {
+		return false
+	}
+	if w.opts.MaxSize > 0 && w.size+n > w.opts.MaxSize {
+		return true
+	}
+	return w.opts.Interval > 0 && !w.now().Truncate(w.opts.Interval).Equal(w.started.Truncate(w.opts.Interval))
+}
+
+func (w *Writer) open() error {
+	if err := os.MkdirAll(filepath.Dir(w.path), 0755); err != nil {
+		return fmt.Errorf("failed to create log directory: %w", err)
+	}
+	file, err := os.OpenFile(w.path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0600)
+	if err != nil {
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+	info, err := file.Stat()
+	if err != nil {
+		file.Close()
+		return fmt.Errorf("failed to open log file: %w", err)
+	}
+
+	w.file, w.size, w.started = file, info.Size(), w.now()
+	if info.Size() > 0 {
+		w.started = info.ModTime()
+	}
+	return nil
+}
+
+func (w *Writer) rotate() error {
+	w.file.Close()
+	w.file = nil
+
+	if err := os.Rename(w.path, w.backupName(w.now())); err != nil && !os.IsNotExist(err) {
+		return fmt.Errorf("failed to rotate log file: %w", err)
+	}
+	w.prune()
+	return w.open()
+}
+
+func (w *Writer) backupName(t time.Time) string {
+	ext := filepath.Ext(w.path)
+	return strings.TrimSuffix(w.path, ext) + "-" + t.UTC().Format(backupTimeFormat) + ext
+}
+
+// prune removes rotated files beyond MaxBackups or older than MaxAge
+func (w *Writer) prune() {
+	if w.opts.MaxBackups <= 0 && w.opts.MaxAge <= 0 {
+		return
+	}
+
+	backups := w.backups()
+	cutoff := w.now().Add(-w.opts.MaxAge)
+	for i, backup := range backups {
+		if (w.opts.MaxBackups > 0 && i >= w.opts.MaxBackups) || (w.opts.MaxAge > 0 && backup.rotated.Before(cutoff)) {
+			os.Remove(backup.path)
+		}
+	}
+}
+
+type backup struct {
+	path    string
+	rotated time.Time
+}
+
+// backups returns the rotated files of the writer, newest first
+func (w *Writer) backups() []backup {
+	ext := filepath.Ext(w.path)
+	prefix := filepath.Base(strings.TrimSuffix(w.path, ext)) + "-"
+
+	entries, err := os.ReadDir(filepath.Dir(w.path))
+	if err != nil {
+		return nil
+	}
+
+	var backups []backup
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+			continue
+		}
+		rotated, err := time.Parse(backupTimeFormat, strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext))
+		if err != nil {
+			continue
+		}
+		backups = append(backups, backup{path: filepath.Join(filepath.Dir(w.path), name), rotated: rotated})
+	}
+	sort.Slice(backups, func(i, j int) bool { return backups[i].rotated.After(backups[j].rotated) })
+	return backups
+}
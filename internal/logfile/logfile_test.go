@@ -0,0 +1,100 @@
+package logfile
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// clock is a settable time source for rotation tests
+type clock struct{ t time.Time }
+
+func (c *clock) now() time.Time { return c.t }
+
+func newTestWriter(t *testing.T, opts Options) (*Writer, *clock) {
+	t.Helper()
+	c := &clock{t: time.Date(2024, 5, 1, 10, 0, 0, 0, time.UTC)}
+	w := New(filepath.Join(t.TempDir(), "cert-manager.log"), opts)
+	w.now = c.now
+	t.Cleanup(func() { w.Close() })
+	return w, c
+}
+
+func write(t *testing.T, w *Writer, line string) {
+	t.Helper()
+	if _, err := w.Write([]byte(line)); err != nil {
+		t.Fatalf("Write(%q): %v", line, err)
+	}
+}
+
+func read(t *testing.T, path string) string {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(data)
+}
+
+func TestWriter_RotatesBySize(t *testing.T) {
+	w, c := newTestWriter(t, Options{MaxSize: 10, MaxBackups: 2})
+
+	for _, line := range []string{"first\n", "second\n", "third\n", "fourth\n"} {
+		write(t, w, line)
+		c.t = c.t.Add(time.Second)
+	}
+
+	if got := read(t, w.path); got != "fourth\n" {
+		t.Errorf("current file = %q, want the last line", got)
+	}
+	backups := w.backups()
+	if len(backups) != 2 {
+		t.Fatalf("got %d rotated files, want 2", len(backups))
+	}
+	if got := read(t, backups[0].path); got != "third\n" {
+		t.Errorf("newest rotated file = %q, want third", got)
+	}
+	if got := read(t, backups[1].path); got != "second\n" {
+		t.Errorf("oldest rotated file = %q, want second", got)
+	}
+}
+
+func TestWriter_RotatesByInterval(t *testing.T) {
+	w, c := newTestWriter(t, Options{Interval: 24 * time.Hour})
+
+	write(t, w, "monday\n")
+	c.t = c.t.Add(12 * time.Hour)
+	write(t, w, "still monday\n")
+	if len(w.backups()) != 0 {
+		t.Fatal("rotated within the interval")
+	}
+
+	c.t = c.t.Add(12 * time.Hour)
+	write(t, w, "tuesday\n")
+	backups := w.backups()
+	if len(backups) != 1 {
+		t.Fatalf("got %d rotated files, want 1", len(backups))
+	}
+	if !strings.HasSuffix(backups[0].path, "cert-manager-2024-05-02T10-00-00.000.log") {
+		t.Errorf("rotated file = %s, want it stamped with the rotation time", backups[0].path)
+	}
+	if got := read(t, w.path); got != "tuesday\n" {
+		t.Errorf("current file = %q", got)
+	}
+}
+
+func TestWriter_RemovesOldBackups(t *testing.T) {
+	w, c := newTestWriter(t, Options{MaxSize: 1, MaxAge: 48 * time.Hour})
+
+	write(t, w, "a\n")
+	write(t, w, "b\n") // rotates a out on day 1
+	c.t = c.t.Add(72 * time.Hour)
+	write(t, w, "c\n") // rotates b out on day 4, removing a
+
+	backups := w.backups()
+	if len(backups) != 1 || read(t, backups[0].path) != "b\n" {
+		t.Errorf("backups = %+v, want only the one rotated within max age", backups)
+	}
+}
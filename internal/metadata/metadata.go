@@ -0,0 +1,438 @@
+// Package metadata keeps certificate metadata and the history of certificate
+// events, notifications and scheduler runs in a relational database, SQLite
+// or Postgres, so the management API can answer historical queries without
+// reading run summaries and PEM files from the storage path.
+//
+// Every build embeds SQLite through modernc.org/sqlite. The Postgres driver
+// is optional: only a binary built with -tags postgres registers pgx.
+package metadata
+
+import (
+	"context"
+	"database/sql"
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// driverNames are the database/sql drivers serving each configured driver
+var driverNames = map[string]string{
+	config.DatabaseSQLite:   "sqlite",
+	config.DatabasePostgres: "pgx",
+}
+
+// Certificate is one certificate generation of a domain
+type Certificate struct {
+	Domain     string    `json:"domain"`
+	Serial     string    `json:"serial"`
+	Issuer     string    `json:"issuer,omitempty"`
+	SANs       []string  `json:"sans"`
+	NotBefore  time.Time `json:"not_before"`
+	NotAfter   time.Time `json:"not_after"`
+	CA         string    `json:"ca,omitempty"`
+	OrderURL   string    `json:"order_url,omitempty"`
+	RecordedAt time.Time `json:"recorded_at"`
+}
+
+// Event is the outcome of an issuance or renewal of a domain's certificate
+type Event struct {
+	ID     int64     `json:"id"`
+	Domain string    `json:"domain"`
+	Event  string    `json:"event"`            // post_issue, post_renew or on_failure
+	Serial string    `json:"serial,omitempty"` // of the new certificate
+	Error  string    `json:"error,omitempty"`
+	At     time.Time `json:"at"`
+}
+
+// Notification is a notice that was sent, or failed to send
+type Notification struct {
+	ID         int64     `json:"id"`
+	Domain     string    `json:"domain,omitempty"`
+	Level      string    `json:"level"`
+	Subject    string    `json:"subject"`
+	Recipients []string  `json:"recipients,omitempty"`
+	Error      string    `json:"error,omitempty"`
+	At         time.Time `json:"at"`
+}
+
+// Run is a finished scheduler run
+type Run struct {
+	ID         int64           `json:"id"`
+	Run        int             `json:"run"`
+	Trigger    string          `json:"trigger"`
+	Status     string          `json:"status"`
+	Error      string          `json:"error,omitempty"`
+	StartedAt  time.Time       `json:"started_at"`
+	FinishedAt time.Time       `json:"finished_at"`
+	Checked    int             `json:"checked"`
+	Renewed    int             `json:"renewed"`
+	Failed     int             `json:"failed"`
+	Skipped    int             `json:"skipped"`
+	Summary    json.RawMessage `json:"summary,omitempty"` // the run summary as written to the storage path
+}
+
+// Filter narrows a historical query. Zero fields don't filter.
+type Filter struct {
+	Domain string
+	Since  time.Time
+	Limit  int // newest entries first; zero applies defaultLimit
+}
+
+// defaultLimit bounds queries that don't set a limit
+const defaultLimit = 100
+
+// Store is a history database
+type Store struct {
+	db        *sql.DB
+	driver    string
+	retention time.Duration // zero keeps all history
+	logger    *log.Logger
+}
+
+// Open connects to the configured database and creates its tables
+func Open(ctx context.Context, cfg config.Database, dsn string, retention time.Duration, logger *log.Logger) (*Store, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Metadata] ", log.LstdFlags)
+	}
+
+	name, ok := driverNames[cfg.Driver]
+	if !ok {
+		return nil, fmt.Errorf("unknown database driver %q", cfg.Driver)
+	}
+	if !slices.Contains(sql.Drivers(), name) {
+		return nil, fmt.Errorf("this binary was built without the %s driver; rebuild it with -tags %s", cfg.Driver, cfg.Driver)
+	}
+
+	db, err := sql.Open(name, dsn)
+	if err != nil {
+		return nil, fmt.Errorf("failed to open the %s database: %w", cfg.Driver, err)
+	}
+	if cfg.Driver == config.DatabaseSQLite {
+		// SQLite serializes writers; one connection avoids "database is locked"
+		db.SetMaxOpenConns(1)
+	}
+
+	s := &Store{db: db, driver: cfg.Driver, retention: retention, logger: logger}
+	if err := s.migrate(ctx); err != nil {
+		db.Close()
+		return nil, err
+	}
+	return s, nil
+}
+
+func (s *Store) Close() error {
+	return s.db.Close()
+}
+
+// migrate applies the schema migrations the database hasn't seen yet
+func (s *Store) migrate(ctx context.Context) error {
+	if _, err := s.db.ExecContext(ctx, `CREATE TABLE IF NOT EXISTS schema_migrations (version INTEGER PRIMARY KEY)`); err != nil {
+		return fmt.Errorf("failed to create the migrations table: %w", err)
+	}
+
+	var applied int
+	if err := s.db.QueryRowContext(ctx, `SELECT COALESCE(MAX(version), 0) FROM schema_migrations`).Scan(&applied); err != nil {
+		return fmt.Errorf("failed to read the schema version: %w", err)
+	}
+
+	for i, migration := range migrations(s.driver) {
+		version := i + 1
+		if version <= applied {
+			continue
+		}
+		tx, err := s.db.BeginTx(ctx, nil)
+		if err != nil {
+			return fmt.Errorf("failed to migrate the database: %w", err)
+		}
+		for _, statement := range migration {
+			if _, err := tx.ExecContext(ctx, statement); err != nil {
+				tx.Rollback()
+				return fmt.Errorf("failed to apply migration %d: %w", version, err)
+			}
+		}
+		if _, err := tx.ExecContext(ctx, s.rebind(`INSERT INTO schema_migrations (version) VALUES (?)`), version); err != nil {
+			tx.Rollback()
+			return fmt.Errorf("failed to record migration %d: %w", version, err)
+		}
+		if err := tx.Commit(); err != nil {
+			return fmt.Errorf("failed to apply migration %d: %w", version, err)
+		}
+	}
+	return nil
+}
+
+// migrations returns the schema changes in order. Released migrations must
+// never change; append new ones instead.
+func migrations(driver string) [][]string {
+	id := "INTEGER PRIMARY KEY AUTOINCREMENT"
+	if driver == config.DatabasePostgres {
+		id = "BIGSERIAL PRIMARY KEY"
+	}
+
+	return [][]string{{
+		`CREATE TABLE certificates (
+			serial TEXT PRIMARY KEY,
+			domain TEXT NOT NULL,
+			issuer TEXT NOT NULL,
+			sans TEXT NOT NULL,
+			not_before TIMESTAMP NOT NULL,
+			not_after TIMESTAMP NOT NULL,
+			ca TEXT NOT NULL,
+			order_url TEXT NOT NULL,
+			recorded_at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX certificates_domain ON certificates (domain, recorded_at)`,
+		`CREATE TABLE events (
+			id ` + id + `,
+			domain TEXT NOT NULL,
+			event TEXT NOT NULL,
+			serial TEXT NOT NULL,
+			error TEXT NOT NULL,
+			at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX events_domain ON events (domain, at)`,
+		`CREATE TABLE notifications (
+			id ` + id + `,
+			domain TEXT NOT NULL,
+			level TEXT NOT NULL,
+			subject TEXT NOT NULL,
+			recipients TEXT NOT NULL,
+			error TEXT NOT NULL,
+			at TIMESTAMP NOT NULL
+		)`,
+		`CREATE INDEX notifications_domain ON notifications (domain, at)`,
+		`CREATE TABLE runs (
+			id ` + id + `,
+			run INTEGER NOT NULL,
+			trigger_name TEXT NOT NULL,
+			status TEXT NOT NULL,
+			error TEXT NOT NULL,
+			started_at TIMESTAMP NOT NULL,
+			finished_at TIMESTAMP NOT NULL,
+			checked INTEGER NOT NULL,
+			renewed INTEGER NOT NULL,
+			failed INTEGER NOT NULL,
+			skipped INTEGER NOT NULL,
+			summary TEXT NOT NULL
+		)`,
+		`CREATE INDEX runs_started_at ON runs (started_at)`,
+	}}
+}
+
+// rebind rewrites ? placeholders to the $1, $2... Postgres expects
+func (s *Store) rebind(query string) string {
+	if s.driver != config.DatabasePostgres {
+		return query
+	}
+
+	var b strings.Builder
+	n := 0
+	for _, r := range query {
+		if r == '?' {
+			n++
+			b.WriteString("$" + strconv.Itoa(n))
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// RecordCertificate stores a certificate generation; recording it again is a no-op
+func (s *Store) RecordCertificate(ctx context.Context, c Certificate) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO certificates
+		(serial, domain, issuer, sans, not_before, not_after, ca, order_url, recorded_at)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?) ON CONFLICT (serial) DO NOTHING`),
+		c.Serial, c.Domain, c.Issuer, strings.Join(c.SANs, ","), c.NotBefore.UTC(), c.NotAfter.UTC(),
+		c.CA, c.OrderURL, c.RecordedAt.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record certificate %s of %s: %w", c.Serial, c.Domain, err)
+	}
+	return nil
+}
+
+func (s *Store) RecordEvent(ctx context.Context, e Event) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO events (domain, event, serial, error, at) VALUES (?, ?, ?, ?, ?)`),
+		e.Domain, e.Event, e.Serial, e.Error, e.At.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record %s event of %s: %w", e.Event, e.Domain, err)
+	}
+	return nil
+}
+
+func (s *Store) RecordNotification(ctx context.Context, n Notification) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO notifications
+		(domain, level, subject, recipients, error, at) VALUES (?, ?, ?, ?, ?, ?)`),
+		n.Domain, n.Level, n.Subject, strings.Join(n.Recipients, ","), n.Error, n.At.UTC())
+	if err != nil {
+		return fmt.Errorf("failed to record notification %q: %w", n.Subject, err)
+	}
+	return nil
+}
+
+// RecordRun stores a finished run and drops history past the retention
+func (s *Store) RecordRun(ctx context.Context, r Run) error {
+	_, err := s.db.ExecContext(ctx, s.rebind(`INSERT INTO runs
+		(run, trigger_name, status, error, started_at, finished_at, checked, renewed, failed, skipped, summary)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`),
+		r.Run, r.Trigger, r.Status, r.Error, r.StartedAt.UTC(), r.FinishedAt.UTC(),
+		r.Checked, r.Renewed, r.Failed, r.Skipped, string(r.Summary))
+	if err != nil {
+		return fmt.Errorf("failed to record run %d: %w", r.Run, err)
+	}
+
+	if s.retention > 0 {
+		if err := s.prune(ctx, time.Now().Add(-s.retention)); err != nil {
+			s.logger.Printf("Warning: %v", err)
+		}
+	}
+	return nil
+}
+
+// prune drops history recorded before cutoff. Certificates are kept while
+// they are valid.
+func (s *Store) prune(ctx context.Context, cutoff time.Time) error {
+	for _, statement := range []string{
+		`DELETE FROM events WHERE at < ?`,
+		`DELETE FROM notifications WHERE at < ?`,
+		`DELETE FROM runs WHERE started_at < ?`,
+		`DELETE FROM certificates WHERE not_after < ?`,
+	} {
+		if _, err := s.db.ExecContext(ctx, s.rebind(statement), cutoff.UTC()); err != nil {
+			return fmt.Errorf("failed to prune history: %w", err)
+		}
+	}
+	return nil
+}
+
+// where builds the WHERE clause and arguments of a filtered query
+func (f Filter) where(timeColumn string, hasDomain bool) (string, []interface{}) {
+	var conditions []string
+	var args []interface{}
+	if f.Domain != "" && hasDomain {
+		conditions = append(conditions, "domain = ?")
+		args = append(args, f.Domain)
+	}
+	if !f.Since.IsZero() {
+		conditions = append(conditions, timeColumn+" >= ?")
+		args = append(args, f.Since.UTC())
+	}
+
+	limit := f.Limit
+	if limit <= 0 {
+		limit = defaultLimit
+	}
+	args = append(args, limit)
+
+	clause := ""
+	if len(conditions) > 0 {
+		clause = " WHERE " + strings.Join(conditions, " AND ")
+	}
+	return clause + " ORDER BY " + timeColumn + " DESC LIMIT ?", args
+}
+
+// Certificates returns certificate generations, newest first
+func (s *Store) Certificates(ctx context.Context, f Filter) ([]Certificate, error) {
+	clause, args := f.where("recorded_at", true)
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT serial, domain, issuer, sans, not_before, not_after, ca, order_url, recorded_at
+		FROM certificates`+clause), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query certificates: %w", err)
+	}
+	defer rows.Close()
+
+	certificates := []Certificate{}
+	for rows.Next() {
+		var c Certificate
+		var sans string
+		if err := rows.Scan(&c.Serial, &c.Domain, &c.Issuer, &sans, &c.NotBefore, &c.NotAfter, &c.CA, &c.OrderURL, &c.RecordedAt); err != nil {
+			return nil, fmt.Errorf("failed to read certificate: %w", err)
+		}
+		c.SANs = splitList(sans)
+		certificates = append(certificates, c)
+	}
+	return certificates, rows.Err()
+}
+
+// Events returns certificate events, newest first
+func (s *Store) Events(ctx context.Context, f Filter) ([]Event, error) {
+	clause, args := f.where("at", true)
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT id, domain, event, serial, error, at FROM events`+clause), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query events: %w", err)
+	}
+	defer rows.Close()
+
+	events := []Event{}
+	for rows.Next() {
+		var e Event
+		if err := rows.Scan(&e.ID, &e.Domain, &e.Event, &e.Serial, &e.Error, &e.At); err != nil {
+			return nil, fmt.Errorf("failed to read event: %w", err)
+		}
+		events = append(events, e)
+	}
+	return events, rows.Err()
+}
+
+// Notifications returns sent notifications, newest first
+func (s *Store) Notifications(ctx context.Context, f Filter) ([]Notification, error) {
+	clause, args := f.where("at", true)
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT id, domain, level, subject, recipients, error, at
+		FROM notifications`+clause), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query notifications: %w", err)
+	}
+	defer rows.Close()
+
+	notifications := []Notification{}
+	for rows.Next() {
+		var n Notification
+		var recipients string
+		if err := rows.Scan(&n.ID, &n.Domain, &n.Level, &n.Subject, &recipients, &n.Error, &n.At); err != nil {
+			return nil, fmt.Errorf("failed to read notification: %w", err)
+		}
+		n.Recipients = splitList(recipients)
+		notifications = append(notifications, n)
+	}
+	return notifications, rows.Err()
+}
+
+// Runs returns scheduler runs, newest first. The domain of the filter is ignored.
+func (s *Store) Runs(ctx context.Context, f Filter) ([]Run, error) {
+	clause, args := f.where("started_at", false)
+	rows, err := s.db.QueryContext(ctx, s.rebind(`SELECT id, run, trigger_name, status, error, started_at, finished_at,
+		checked, renewed, failed, skipped, summary FROM runs`+clause), args...)
+	if err != nil {
+		return nil, fmt.Errorf("failed to query runs: %w", err)
+	}
+	defer rows.Close()
+
+	runs := []Run{}
+	for rows.Next() {
+		var r Run
+		var summary string
+		if err := rows.Scan(&r.ID, &r.Run, &r.Trigger, &r.Status, &r.Error, &r.StartedAt, &r.FinishedAt,
+			&r.Checked, &r.Renewed, &r.Failed, &r.Skipped, &summary); err != nil {
+			return nil, fmt.Errorf("failed to read run: %w", err)
+		}
+		if summary != "" {
+			r.Summary = json.RawMessage(summary)
+		}
+		runs = append(runs, r)
+	}
+	return runs, rows.Err()
+}
+
+func splitList(value string) []string {
+	if value == "" {
+		return nil
+	}
+	return strings.Split(value, ",")
+}
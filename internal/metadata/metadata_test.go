@@ -0,0 +1,117 @@
+package metadata
+
+import (
+	"context"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	_ "modernc.org/sqlite"
+)
+
+func TestRebind(t *testing.T) {
+	query := `INSERT INTO events (domain, event) VALUES (?, ?)`
+
+	postgres := &Store{driver: config.DatabasePostgres}
+	if got := postgres.rebind(query); got != `INSERT INTO events (domain, event) VALUES ($1, $2)` {
+		t.Errorf("rebind(postgres) = %s", got)
+	}
+	sqlite := &Store{driver: config.DatabaseSQLite}
+	if got := sqlite.rebind(query); got != query {
+		t.Errorf("rebind(sqlite) = %s, want the query unchanged", got)
+	}
+}
+
+func TestFilterWhere(t *testing.T) {
+	since := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+
+	clause, args := Filter{Domain: "example.com", Since: since, Limit: 5}.where("at", true)
+	if clause != " WHERE domain = ? AND at >= ? ORDER BY at DESC LIMIT ?" {
+		t.Errorf("clause = %q", clause)
+	}
+	if !reflect.DeepEqual(args, []interface{}{"example.com", since, 5}) {
+		t.Errorf("args = %v", args)
+	}
+
+	// Runs have no domain, and every query is bounded
+	clause, args = Filter{Domain: "example.com"}.where("started_at", false)
+	if clause != " ORDER BY started_at DESC LIMIT ?" || !reflect.DeepEqual(args, []interface{}{defaultLimit}) {
+		t.Errorf("clause = %q with %v, want only the default limit", clause, args)
+	}
+}
+
+func TestMigrations(t *testing.T) {
+	for _, driver := range []string{config.DatabaseSQLite, config.DatabasePostgres} {
+		statements := strings.Join(migrations(driver)[0], "\n")
+		for _, table := range []string{"certificates", "events", "notifications", "runs"} {
+			if !strings.Contains(statements, "CREATE TABLE "+table+" ") {
+				t.Errorf("%s migrations don't create %s", driver, table)
+			}
+		}
+	}
+	if !strings.Contains(strings.Join(migrations(config.DatabasePostgres)[0], "\n"), "BIGSERIAL") {
+		t.Errorf("postgres migrations should use BIGSERIAL ids")
+	}
+}
+
+func TestOpen_MissingDriver(t *testing.T) {
+	// The default build registers no Postgres driver
+	_, err := Open(context.Background(), config.Database{Driver: config.DatabasePostgres}, "postgres://localhost/history", 0, nil)
+	if err == nil || !strings.Contains(err.Error(), "-tags postgres") {
+		t.Errorf("Open() = %v, want a hint to rebuild with the driver", err)
+	}
+}
+
+func TestStore_SQLite(t *testing.T) {
+	ctx := context.Background()
+	dsn := filepath.Join(t.TempDir(), "history.db")
+	store, err := Open(ctx, config.Database{Driver: config.DatabaseSQLite}, dsn, 0, nil)
+	if err != nil {
+		t.Fatalf("Open() = %v", err)
+	}
+
+	issued := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	cert := Certificate{
+		Domain:     "example.com",
+		Serial:     "01",
+		SANs:       []string{"example.com", "www.example.com"},
+		NotBefore:  issued,
+		NotAfter:   issued.Add(90 * 24 * time.Hour),
+		RecordedAt: issued,
+	}
+	for i := 0; i < 2; i++ {
+		if err := store.RecordCertificate(ctx, cert); err != nil {
+			t.Fatalf("RecordCertificate() = %v", err)
+		}
+	}
+	if err := store.RecordEvent(ctx, Event{Domain: "example.com", Event: "post_issue", Serial: "01", At: issued}); err != nil {
+		t.Fatalf("RecordEvent() = %v", err)
+	}
+
+	certificates, err := store.Certificates(ctx, Filter{Domain: "example.com"})
+	if err != nil {
+		t.Fatalf("Certificates() = %v", err)
+	}
+	if len(certificates) != 1 || !reflect.DeepEqual(certificates[0].SANs, cert.SANs) || !certificates[0].NotAfter.Equal(cert.NotAfter) {
+		t.Errorf("Certificates() = %+v, want the certificate recorded once", certificates)
+	}
+
+	events, err := store.Events(ctx, Filter{Since: issued})
+	if err != nil {
+		t.Fatalf("Events() = %v", err)
+	}
+	if len(events) != 1 || events[0].Event != "post_issue" || !events[0].At.Equal(issued) {
+		t.Errorf("Events() = %+v", events)
+	}
+
+	// Reopening the database applies no migration twice
+	store.Close()
+	store, err = Open(ctx, config.Database{Driver: config.DatabaseSQLite}, dsn, 0, nil)
+	if err != nil {
+		t.Fatalf("Open() again = %v", err)
+	}
+	store.Close()
+}
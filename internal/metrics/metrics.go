@@ -0,0 +1,326 @@
+package metrics
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// Registry holds a set of metrics and renders them in the Prometheus text format
+type Registry struct {
+	mu         sync.RWMutex
+	collectors []collector
+	names      map[string]bool
+}
+
+type collector interface {
+	name() string
+	write(w io.Writer)
+}
+
+// DefaultRegistry is the registry used by the package-level constructors
+var DefaultRegistry = NewRegistry()
+
+func NewRegistry() *Registry {
+	return &Registry{
+		names: make(map[string]bool),
+	}
+}
+
+func (r *Registry) register(c collector) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.names[c.name()] {
+		panic(fmt.Sprintf("metrics: duplicate metric %q", c.name()))
+	}
+	r.names[c.name()] = true
+	r.collectors = append(r.collectors, c)
+}
+
+// Write renders all registered metrics in the Prometheus text exposition format
+func (r *Registry) Write(w io.Writer) {
+	r.mu.RLock()
+	defer r.mu.RUnlock()
+
+	for _, c := range r.collectors {
+		c.write(w)
+	}
+}
+
+// Handler returns an HTTP handler serving the registry contents
+func (r *Registry) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, _ *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		r.Write(w)
+	})
+}
+
+// Handler returns an HTTP handler serving the default registry
+func Handler() http.Handler {
+	return DefaultRegistry.Handler()
+}
+
+// vec stores one value per distinct combination of label values
+type vec struct {
+	metricName string
+	help       string
+	typ        string
+	labels     []string
+	mu         sync.Mutex
+	values     map[string]float64
+	keys       map[string][]string
+}
+
+func newVec(name, help, typ string, labels []string) *vec {
+	return &vec{
+		metricName: name,
+		help:       help,
+		typ:        typ,
+		labels:     labels,
+		values:     make(map[string]float64),
+		keys:       make(map[string][]string),
+	}
+}
+
+func (v *vec) name() string {
+	return v.metricName
+}
+
+func (v *vec) update(labelValues []string, fn func(float64) float64) {
+	if len(labelValues) != len(v.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", v.metricName, len(v.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	if _, exists := v.keys[key]; !exists {
+		v.keys[key] = append([]string(nil), labelValues...)
+	}
+	v.values[key] = fn(v.values[key])
+}
+
+func (v *vec) write(w io.Writer) {
+	v.mu.Lock()
+	defer v.mu.Unlock()
+
+	writeHeader(w, v.metricName, v.help, v.typ)
+
+	keys := make([]string, 0, len(v.values))
+	for key := range v.values {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	for _, key := range keys {
+		fmt.Fprintf(w, "%s%s %s\n", v.metricName, formatLabels(v.labels, v.keys[key]), formatValue(v.values[key]))
+	}
+}
+
+// Counter is a monotonically increasing value
+type Counter struct {
+	*vec
+}
+
+func (r *Registry) NewCounter(name, help string, labels ...string) *Counter {
+	c := &Counter{newVec(name, help, "counter", labels)}
+	r.register(c)
+	return c
+}
+
+// NewCounter creates a counter in the default registry
+func NewCounter(name, help string, labels ...string) *Counter {
+	return DefaultRegistry.NewCounter(name, help, labels...)
+}
+
+func (c *Counter) Inc(labelValues ...string) {
+	c.Add(1, labelValues...)
+}
+
+func (c *Counter) Add(delta float64, labelValues ...string) {
+	if delta < 0 {
+		panic("metrics: counter cannot decrease")
+	}
+	c.update(labelValues, func(v float64) float64 { return v + delta })
+}
+
+// Gauge is a value that can go up and down
+type Gauge struct {
+	*vec
+}
+
+func (r *Registry) NewGauge(name, help string, labels ...string) *Gauge {
+	g := &Gauge{newVec(name, help, "gauge", labels)}
+	r.register(g)
+	return g
+}
+
+// NewGauge creates a gauge in the default registry
+func NewGauge(name, help string, labels ...string) *Gauge {
+	return DefaultRegistry.NewGauge(name, help, labels...)
+}
+
+func (g *Gauge) Set(value float64, labelValues ...string) {
+	g.update(labelValues, func(float64) float64 { return value })
+}
+
+func (g *Gauge) Add(delta float64, labelValues ...string) {
+	g.update(labelValues, func(v float64) float64 { return v + delta })
+}
+
+// GaugeFunc is a gauge whose value is computed on every scrape
+type GaugeFunc struct {
+	metricName string
+	help       string
+	fn         func() float64
+}
+
+func (r *Registry) NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	g := &GaugeFunc{metricName: name, help: help, fn: fn}
+	r.register(g)
+	return g
+}
+
+// NewGaugeFunc creates a gauge func in the default registry
+func NewGaugeFunc(name, help string, fn func() float64) *GaugeFunc {
+	return DefaultRegistry.NewGaugeFunc(name, help, fn)
+}
+
+func (g *GaugeFunc) name() string {
+	return g.metricName
+}
+
+func (g *GaugeFunc) write(w io.Writer) {
+	writeHeader(w, g.metricName, g.help, "gauge")
+	fmt.Fprintf(w, "%s %s\n", g.metricName, formatValue(g.fn()))
+}
+
+// DefBuckets are histogram buckets in seconds suited to network request latencies
+var DefBuckets = []float64{0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10, 30, 60}
+
+// Histogram counts observations into cumulative buckets
+type Histogram struct {
+	metricName string
+	help       string
+	labels     []string
+	buckets    []float64
+	mu         sync.Mutex
+	series     map[string]*histogramSeries
+}
+
+type histogramSeries struct {
+	labelValues []string
+	counts      []uint64 // per bucket, not cumulative
+	sum         float64
+	count       uint64
+}
+
+func (r *Registry) NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	if len(buckets) == 0 {
+		buckets = DefBuckets
+	}
+	sorted := append([]float64(nil), buckets...)
+	sort.Float64s(sorted)
+
+	h := &Histogram{
+		metricName: name,
+		help:       help,
+		labels:     labels,
+		buckets:    sorted,
+		series:     make(map[string]*histogramSeries),
+	}
+	r.register(h)
+	return h
+}
+
+// NewHistogram creates a histogram in the default registry
+func NewHistogram(name, help string, buckets []float64, labels ...string) *Histogram {
+	return DefaultRegistry.NewHistogram(name, help, buckets, labels...)
+}
+
+func (h *Histogram) name() string {
+	return h.metricName
+}
+
+func (h *Histogram) Observe(value float64, labelValues ...string) {
+	if len(labelValues) != len(h.labels) {
+		panic(fmt.Sprintf("metrics: %s expects %d label values, got %d", h.metricName, len(h.labels), len(labelValues)))
+	}
+
+	key := strings.Join(labelValues, "\xff")
+
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	s, exists := h.series[key]
+	if !exists {
+		s = &histogramSeries{
+			labelValues: append([]string(nil), labelValues...),
+			counts:      make([]uint64, len(h.buckets)),
+		}
+		h.series[key] = s
+	}
+
+	if i := sort.SearchFloat64s(h.buckets, value); i < len(h.buckets) {
+		s.counts[i]++
+	}
+	s.sum += value
+	s.count++
+}
+
+func (h *Histogram) write(w io.Writer) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	writeHeader(w, h.metricName, h.help, "histogram")
+
+	keys := make([]string, 0, len(h.series))
+	for key := range h.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	labels := append(append([]string(nil), h.labels...), "le")
+	for _, key := range keys {
+		s := h.series[key]
+
+		var cumulative uint64
+		for i, bound := range h.buckets {
+			cumulative += s.counts[i]
+			values := append(append([]string(nil), s.labelValues...), formatValue(bound))
+			fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(labels, values), cumulative)
+		}
+		values := append(append([]string(nil), s.labelValues...), "+Inf")
+		fmt.Fprintf(w, "%s_bucket%s %d\n", h.metricName, formatLabels(labels, values), s.count)
+
+		fmt.Fprintf(w, "%s_sum%s %s\n", h.metricName, formatLabels(h.labels, s.labelValues), formatValue(s.sum))
+		fmt.Fprintf(w, "%s_count%s %d\n", h.metricName, formatLabels(h.labels, s.labelValues), s.count)
+	}
+}
+
+func writeHeader(w io.Writer, name, help, typ string) {
+	fmt.Fprintf(w, "# HELP %s %s\n", name, help)
+	fmt.Fprintf(w, "# TYPE %s %s\n", name, typ)
+}
+
+func formatLabels(names, values []string) string {
+	if len(names) == 0 {
+		return ""
+	}
+
+	pairs := make([]string, len(names))
+	for i, name := range names {
+		pairs[i] = fmt.Sprintf("%s=%q", name, values[i])
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func formatValue(v float64) string {
+	return fmt.Sprintf("%g", v)
+}
@@ -0,0 +1,99 @@
+package metrics
+
+import (
+	"bytes"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestRegistry_Write(t *testing.T) {
+	r := NewRegistry()
+
+	counter := r.NewCounter("test_requests_total", "Total requests.", "domain")
+	counter.Inc("example.com")
+	counter.Add(2, "example.com")
+	counter.Inc("api.example.com")
+
+	gauge := r.NewGauge("test_free_bytes", "Free bytes.")
+	gauge.Set(1024)
+
+	r.NewGaugeFunc("test_up", "Whether the test is up.", func() float64 { return 1 })
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	output := buf.String()
+
+	expected := []string{
+		"# TYPE test_requests_total counter",
+		`test_requests_total{domain="api.example.com"} 1`,
+		`test_requests_total{domain="example.com"} 3`,
+		"# TYPE test_free_bytes gauge",
+		"test_free_bytes 1024",
+		"test_up 1",
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestHistogram_Write(t *testing.T) {
+	r := NewRegistry()
+
+	h := r.NewHistogram("test_duration_seconds", "Request duration.", []float64{1, 0.1}, "endpoint")
+	h.Observe(0.05, "new-order")
+	h.Observe(0.1, "new-order")
+	h.Observe(0.5, "new-order")
+	h.Observe(3, "new-order")
+
+	var buf bytes.Buffer
+	r.Write(&buf)
+	output := buf.String()
+
+	expected := []string{
+		"# TYPE test_duration_seconds histogram",
+		`test_duration_seconds_bucket{endpoint="new-order",le="0.1"} 2`,
+		`test_duration_seconds_bucket{endpoint="new-order",le="1"} 3`,
+		`test_duration_seconds_bucket{endpoint="new-order",le="+Inf"} 4`,
+		`test_duration_seconds_sum{endpoint="new-order"} 3.65`,
+		`test_duration_seconds_count{endpoint="new-order"} 4`,
+	}
+	for _, line := range expected {
+		if !strings.Contains(output, line) {
+			t.Errorf("Expected output to contain %q, got:\n%s", line, output)
+		}
+	}
+}
+
+func TestRegistry_DuplicateMetric(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_duplicate", "First.")
+
+	defer func() {
+		if recover() == nil {
+			t.Error("Expected panic when registering a duplicate metric")
+		}
+	}()
+	r.NewGauge("test_duplicate", "Second.")
+}
+
+func TestRegistry_Handler(t *testing.T) {
+	r := NewRegistry()
+	r.NewGauge("test_gauge", "A gauge.").Set(5)
+
+	rec := httptest.NewRecorder()
+	r.Handler().ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/metrics", nil))
+
+	if rec.Code != http.StatusOK {
+		t.Errorf("Expected status 200, got %d", rec.Code)
+	}
+	if !strings.HasPrefix(rec.Header().Get("Content-Type"), "text/plain") {
+		t.Errorf("Expected text/plain content type, got '%s'", rec.Header().Get("Content-Type"))
+	}
+	if !strings.Contains(rec.Body.String(), "test_gauge 5") {
+		t.Errorf("Expected gauge value in body, got:\n%s", rec.Body.String())
+	}
+}
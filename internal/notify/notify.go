@@ -0,0 +1,147 @@
+package notify
+
+import (
+	"fmt"
+	"log"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// Level describes the severity of a notification
+type Level string
+
+const (
+	LevelInfo     Level = "info"
+	LevelWarning  Level = "warning"
+	LevelCritical Level = "critical"
+	LevelPage     Level = "page"
+)
+
+// severity orders levels from info to page
+func (l Level) severity() int {
+	switch l {
+	case LevelWarning:
+		return 1
+	case LevelCritical:
+		return 2
+	case LevelPage:
+		return 3
+	}
+	return 0
+}
+
+// Events name what a notification reports, so templates can word each kind
+// of notice differently
+const (
+	EventExpiring         = "expiring"
+	EventExpired          = "expired"
+	EventFailure          = "failure"
+	EventDeployFailure    = "deploy_failure"
+	EventSwitchFailure    = "switch_failure"
+	EventChainExpiring    = "chain_expiring"
+	EventStorageLow       = "storage_low"
+	EventStorageRecovered = "storage_recovered"
+	EventDrift            = "drift"
+	EventOrphaned         = "orphaned"
+	EventPruned           = "pruned"
+	EventDigest           = "digest"
+	EventReport           = "report"
+	EventTest             = "test"
+)
+
+// Message is a single notification sent to operators
+type Message struct {
+	Level      Level
+	Event      string // one of the Event constants
+	Subject    string
+	Body       string
+	Domain     string
+	Key        string   // identifies the condition reported, for deduplication; empty uses Domain and Subject
+	Recipients []string // overrides the notifier's recipients
+}
+
+// Notifier delivers messages to operators
+type Notifier interface {
+	Send(msg Message) error
+}
+
+// EmailNotifier sends notifications over SMTP
+type EmailNotifier struct {
+	host       string
+	port       int
+	tlsMode    string
+	authMethod string
+	username   string
+	password   string
+	from       string
+	to         []string
+	templates  *emailTemplates
+	logger     *log.Logger
+}
+
+func NewEmailNotifier(cfg config.Notification, recipient string, logger *log.Logger) (*EmailNotifier, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Notify] ", log.LstdFlags)
+	}
+
+	templates, err := loadTemplates(cfg.SubjectTemplate, cfg.TextTemplate, cfg.HTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	return &EmailNotifier{
+		host:       cfg.SMTPHost,
+		port:       cfg.SMTPPort,
+		tlsMode:    cfg.TLS,
+		authMethod: cfg.Auth,
+		username:   cfg.Username,
+		password:   cfg.Password,
+		from:       cfg.From,
+		to:         []string{recipient},
+		templates:  templates,
+		logger:     logger,
+	}, nil
+}
+
+// WithRecipients returns a copy of the notifier that sends to the given addresses
+func (n *EmailNotifier) WithRecipients(to []string) *EmailNotifier {
+	c := *n
+	c.to = to
+	return &c
+}
+
+// Send delivers the message as an email with plain-text and HTML parts
+func (n *EmailNotifier) Send(msg Message) error {
+	if len(msg.Recipients) > 0 {
+		n = n.WithRecipients(msg.Recipients)
+	}
+
+	email, err := n.buildEmail(msg, time.Now())
+	if err != nil {
+		return err
+	}
+
+	if err := n.sendMail(n.to, email); err != nil {
+		return fmt.Errorf("failed to send email: %w", err)
+	}
+
+	n.logger.Printf("Sent %s notification to %s: %s", msg.Level, strings.Join(n.to, ", "), msg.Subject)
+	return nil
+}
+
+// DomainNotifier addresses messages about a domain to the recipients
+// configured for it, unless the message names its own
+type DomainNotifier struct {
+	Notifier
+	RecipientsFor func(domain string) []string
+}
+
+func (n *DomainNotifier) Send(msg Message) error {
+	if msg.Domain != "" && len(msg.Recipients) == 0 && n.RecipientsFor != nil {
+		msg.Recipients = n.RecipientsFor(msg.Domain)
+	}
+	return n.Notifier.Send(msg)
+}
@@ -0,0 +1,125 @@
+package notify
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/smtp"
+	"strconv"
+	"time"
+)
+
+// smtpTimeout bounds connecting to the SMTP server
+const smtpTimeout = 30 * time.Second
+
+// TLS modes of the SMTP connection
+const (
+	TLSStartTLS = "starttls" // upgrade a plain connection, failing if the server can't
+	TLSImplicit = "implicit" // TLS from the start, usually port 465
+	TLSNone     = "none"     // plaintext, for local relays
+)
+
+// sendMail delivers a message over SMTP using the notifier's TLS mode and authentication
+func (n *EmailNotifier) sendMail(to []string, message []byte) error {
+	addr := net.JoinHostPort(n.host, strconv.Itoa(n.port))
+	tlsConfig := &tls.Config{ServerName: n.host}
+
+	var conn net.Conn
+	var err error
+	dialer := &net.Dialer{Timeout: smtpTimeout}
+	if n.tlsMode == TLSImplicit {
+		conn, err = tls.DialWithDialer(dialer, "tcp", addr, tlsConfig)
+	} else {
+		conn, err = dialer.Dial("tcp", addr)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to connect to %s: %w", addr, err)
+	}
+
+	client, err := smtp.NewClient(conn, n.host)
+	if err != nil {
+		conn.Close()
+		return fmt.Errorf("failed to greet %s: %w", addr, err)
+	}
+	defer client.Close()
+
+	if n.tlsMode == TLSStartTLS || n.tlsMode == "" {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			return fmt.Errorf("%s does not support STARTTLS; set notification.tls to implicit or none", addr)
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			return fmt.Errorf("failed to start TLS: %w", err)
+		}
+	}
+
+	if n.username != "" {
+		if err := client.Auth(n.auth()); err != nil {
+			return fmt.Errorf("failed to authenticate: %w", err)
+		}
+	}
+
+	if err := client.Mail(n.from); err != nil {
+		return err
+	}
+	for _, rcpt := range to {
+		if err := client.Rcpt(rcpt); err != nil {
+			return err
+		}
+	}
+	w, err := client.Data()
+	if err != nil {
+		return err
+	}
+	if _, err := w.Write(message); err != nil {
+		return err
+	}
+	if err := w.Close(); err != nil {
+		return err
+	}
+	return client.Quit()
+}
+
+// auth returns the SMTP authentication mechanism configured
+func (n *EmailNotifier) auth() smtp.Auth {
+	switch n.authMethod {
+	case "login":
+		return &loginAuth{username: n.username, password: n.password, host: n.host}
+	case "cram-md5":
+		return smtp.CRAMMD5Auth(n.username, n.password)
+	}
+	return smtp.PlainAuth("", n.username, n.password, n.host)
+}
+
+// loginAuth implements the LOGIN mechanism, which some servers offer instead of PLAIN
+type loginAuth struct {
+	username, password, host string
+}
+
+func (a *loginAuth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+	// Like PlainAuth, refuse to send credentials in the clear to a remote server
+	if !server.TLS && !isLocalhost(server.Name) {
+		return "", nil, errors.New("unencrypted connection")
+	}
+	if server.Name != a.host {
+		return "", nil, errors.New("wrong host name")
+	}
+	return "LOGIN", nil, nil
+}
+
+func (a *loginAuth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if !more {
+		return nil, nil
+	}
+	switch string(fromServer) {
+	case "Username:", "User Name\x00":
+		return []byte(a.username), nil
+	case "Password:", "Password\x00":
+		return []byte(a.password), nil
+	}
+	return nil, fmt.Errorf("unexpected LOGIN challenge %q", fromServer)
+}
+
+func isLocalhost(name string) bool {
+	return name == "localhost" || name == "127.0.0.1" || name == "::1"
+}
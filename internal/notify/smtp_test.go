@@ -0,0 +1,185 @@
+package notify
+
+import (
+	"bufio"
+	"io"
+	"mime"
+	"mime/multipart"
+	"net"
+	"net/mail"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// fakeSMTP accepts one plaintext SMTP session without STARTTLS and returns
+// its address and a channel receiving the DATA it was sent
+func fakeSMTP(t *testing.T) (string, int, <-chan string) {
+	t.Helper()
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { ln.Close() })
+
+	received := make(chan string, 1)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		r := bufio.NewReader(conn)
+		reply := func(line string) { io.WriteString(conn, line+"\r\n") }
+
+		reply("220 fake ESMTP")
+		for {
+			line, err := r.ReadString('\n')
+			if err != nil {
+				return
+			}
+			switch cmd := strings.ToUpper(strings.TrimSpace(line)); {
+			case strings.HasPrefix(cmd, "EHLO"):
+				reply("250-fake")
+				reply("250 8BITMIME")
+			case cmd == "DATA":
+				reply("354 go ahead")
+				var data strings.Builder
+				for {
+					line, err := r.ReadString('\n')
+					if err != nil || line == ".\r\n" {
+						break
+					}
+					data.WriteString(line)
+				}
+				received <- data.String()
+				reply("250 queued")
+			case cmd == "QUIT":
+				reply("221 bye")
+				return
+			default:
+				reply("250 ok")
+			}
+		}
+	}()
+
+	host, port, _ := net.SplitHostPort(ln.Addr().String())
+	n, _ := strconv.Atoi(port)
+	return host, n, received
+}
+
+func TestEmailNotifier_SendPlaintext(t *testing.T) {
+	host, port, received := fakeSMTP(t)
+
+	textTemplate := filepath.Join(t.TempDir(), "text.tmpl")
+	if err := os.WriteFile(textTemplate, []byte("{{.Severity}} for {{.Domain}}: {{.Body}}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier, err := NewEmailNotifier(config.Notification{
+		SMTPHost: host, SMTPPort: port, TLS: TLSNone, From: "certs@example.com", TextTemplate: textTemplate,
+	}, "ops@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = notifier.Send(Message{Level: LevelWarning, Domain: "example.com", Subject: "Expiring", Body: "<renew soon>"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email, err := mail.ReadMessage(strings.NewReader(<-received))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := email.Header.Get("Subject"); got != "[WARNING] Expiring" {
+		t.Errorf("Subject = %q", got)
+	}
+	mediaType, params, err := mime.ParseMediaType(email.Header.Get("Content-Type"))
+	if err != nil || mediaType != "multipart/alternative" {
+		t.Fatalf("Content-Type = %q, want multipart/alternative", email.Header.Get("Content-Type"))
+	}
+
+	parts := multipart.NewReader(email.Body, params["boundary"])
+	var bodies []string
+	for {
+		part, err := parts.NextPart()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			t.Fatal(err)
+		}
+		body, _ := io.ReadAll(part)
+		bodies = append(bodies, string(body))
+	}
+	if len(bodies) != 2 {
+		t.Fatalf("got %d parts, want text and HTML", len(bodies))
+	}
+	if !strings.Contains(bodies[0], "WARNING for example.com: <renew soon>") {
+		t.Errorf("text part does not use the template:\n%s", bodies[0])
+	}
+	if !strings.Contains(bodies[1], "&lt;renew soon&gt;") {
+		t.Errorf("HTML part does not escape the body:\n%s", bodies[1])
+	}
+}
+
+func TestEmailNotifier_RequiresStartTLS(t *testing.T) {
+	host, port, _ := fakeSMTP(t)
+
+	notifier, err := NewEmailNotifier(config.Notification{SMTPHost: host, SMTPPort: port, TLS: TLSStartTLS}, "ops@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	err = notifier.Send(Message{Level: LevelInfo, Subject: "Test"})
+	if err == nil || !strings.Contains(err.Error(), "does not support STARTTLS") {
+		t.Errorf("err = %v, want a refusal to send without STARTTLS", err)
+	}
+}
+
+func TestNewEmailNotifier_InvalidTemplate(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "html.tmpl")
+	if err := os.WriteFile(path, []byte("{{.Subject"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	if _, err := NewEmailNotifier(config.Notification{HTMLTemplate: path}, "ops@example.com", nil); err == nil {
+		t.Error("expected an error for an unparsable template")
+	}
+}
+
+func TestEmailNotifier_RenderSubjectTemplate(t *testing.T) {
+	subjectTemplate := filepath.Join(t.TempDir(), "subject.tmpl")
+	source := "{{if eq .Event \"expired\"}}OUTAGE: {{end}}{{.Subject}}\n[{{.Severity}}]\n"
+	if err := os.WriteFile(subjectTemplate, []byte(source), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	notifier, err := NewEmailNotifier(config.Notification{SubjectTemplate: subjectTemplate}, "ops@example.com", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	email, err := notifier.Render(Message{Level: LevelPage, Event: EventExpired, Subject: "Certificate for example.com has expired"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	// Line breaks would end the header early
+	if email.Subject != "OUTAGE: Certificate for example.com has expired [PAGE]" {
+		t.Errorf("Subject = %q", email.Subject)
+	}
+
+	email, err = notifier.Render(Message{Level: LevelWarning, Event: EventExpiring, Subject: "Certificate for example.com expires in 10 days"}, time.Now())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if email.Subject != "Certificate for example.com expires in 10 days [WARNING]" {
+		t.Errorf("Subject = %q", email.Subject)
+	}
+}
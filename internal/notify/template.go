@@ -0,0 +1,171 @@
+package notify
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"mime"
+	"mime/multipart"
+	"net/textproto"
+	"os"
+	"strings"
+	texttemplate "text/template"
+	"time"
+)
+
+// defaultSubjectTemplate renders the subject of an email
+const defaultSubjectTemplate = `[{{.Severity}}] {{.Subject}}`
+
+// defaultTextTemplate renders the plain-text part of an email
+const defaultTextTemplate = `{{.Body}}
+{{if .Domain}}
+Domain: {{.Domain}}{{end}}
+Severity: {{.Severity}}
+Sent: {{.Date.Format "2006-01-02 15:04:05 MST"}}
+`
+
+// defaultHTMLTemplate renders the HTML part of an email
+const defaultHTMLTemplate = `<!DOCTYPE html>
+<html>
+<body style="font-family: sans-serif">
+<h2>{{.Subject}}</h2>
+<pre style="font-family: monospace; white-space: pre-wrap">{{.Body}}</pre>
+<table style="color: #555">
+{{if .Domain}}<tr><td>Domain</td><td>{{.Domain}}</td></tr>{{end}}
+<tr><td>Severity</td><td>{{.Severity}}</td></tr>
+<tr><td>Sent</td><td>{{.Date.Format "2006-01-02 15:04:05 MST"}}</td></tr>
+</table>
+</body>
+</html>
+`
+
+// templateData is what email templates are executed with
+type templateData struct {
+	Message
+	Severity string // the level in upper case
+	Date     time.Time
+}
+
+// emailTemplates render the subject and the two parts of an email
+type emailTemplates struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// Email is a message rendered with the email templates
+type Email struct {
+	Subject string
+	Text    string
+	HTML    string
+}
+
+// loadTemplates parses the template files, using the built-in templates for
+// empty paths
+func loadTemplates(subjectPath, textPath, htmlPath string) (*emailTemplates, error) {
+	subjectSource, err := readTemplate(subjectPath, defaultSubjectTemplate)
+	if err != nil {
+		return nil, err
+	}
+	textSource, err := readTemplate(textPath, defaultTextTemplate)
+	if err != nil {
+		return nil, err
+	}
+	htmlSource, err := readTemplate(htmlPath, defaultHTMLTemplate)
+	if err != nil {
+		return nil, err
+	}
+
+	subject, err := texttemplate.New("subject").Parse(subjectSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse subject template: %w", err)
+	}
+	text, err := texttemplate.New("text").Parse(textSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse text template: %w", err)
+	}
+	html, err := htmltemplate.New("html").Parse(htmlSource)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse HTML template: %w", err)
+	}
+	return &emailTemplates{subject: subject, text: text, html: html}, nil
+}
+
+func readTemplate(path, fallback string) (string, error) {
+	if path == "" {
+		return fallback, nil
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("failed to read template: %w", err)
+	}
+	return string(data), nil
+}
+
+// Render renders msg with the templates as if it was sent at now
+func (n *EmailNotifier) Render(msg Message, now time.Time) (*Email, error) {
+	data := templateData{Message: msg, Severity: strings.ToUpper(string(msg.Level)), Date: now}
+
+	var subject, text, html bytes.Buffer
+	if err := n.templates.subject.Execute(&subject, data); err != nil {
+		return nil, fmt.Errorf("failed to render subject template: %w", err)
+	}
+	if err := n.templates.text.Execute(&text, data); err != nil {
+		return nil, fmt.Errorf("failed to render text template: %w", err)
+	}
+	if err := n.templates.html.Execute(&html, data); err != nil {
+		return nil, fmt.Errorf("failed to render HTML template: %w", err)
+	}
+
+	// A header ends at a line break, so a subject must not contain any
+	return &Email{
+		Subject: strings.Join(strings.Fields(subject.String()), " "),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// buildEmail renders msg as a multipart/alternative email with a plain-text
+// and an HTML part
+func (n *EmailNotifier) buildEmail(msg Message, now time.Time) ([]byte, error) {
+	email, err := n.Render(msg, now)
+	if err != nil {
+		return nil, err
+	}
+
+	var body bytes.Buffer
+	parts := multipart.NewWriter(&body)
+	for _, part := range []struct {
+		contentType string
+		content     []byte
+	}{
+		{"text/plain; charset=utf-8", []byte(email.Text)},
+		{"text/html; charset=utf-8", []byte(email.HTML)},
+	} {
+		w, err := parts.CreatePart(textproto.MIMEHeader{
+			"Content-Type":              {part.contentType},
+			"Content-Transfer-Encoding": {"8bit"},
+		})
+		if err != nil {
+			return nil, err
+		}
+		w.Write(crlf(part.content))
+	}
+	parts.Close()
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "From: %s\r\n", n.from)
+	fmt.Fprintf(&b, "To: %s\r\n", strings.Join(n.to, ", "))
+	fmt.Fprintf(&b, "Subject: %s\r\n", mime.QEncoding.Encode("utf-8", email.Subject))
+	fmt.Fprintf(&b, "Date: %s\r\n", now.Format(time.RFC1123Z))
+	b.WriteString("MIME-Version: 1.0\r\n")
+	fmt.Fprintf(&b, "Content-Type: multipart/alternative; boundary=%q\r\n", parts.Boundary())
+	b.WriteString("\r\n")
+	b.Write(body.Bytes())
+	return []byte(b.String()), nil
+}
+
+// crlf normalizes line endings to the CRLF SMTP requires
+func crlf(content []byte) []byte {
+	return bytes.ReplaceAll(bytes.ReplaceAll(content, []byte("\r\n"), []byte("\n")), []byte("\n"), []byte("\r\n"))
+}
@@ -0,0 +1,226 @@
+package notify
+
+import (
+	"encoding/json"
+	"fmt"
+	"log"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// throttleFileName holds the deduplication and digest state, relative to the storage path
+const throttleFileName = ".notifications.json"
+
+// throttleState is what a Throttle persists across restarts
+type throttleState struct {
+	Sent       map[string]time.Time `json:"sent"`    // dedup key -> last sent
+	Pending    []Message            `json:"pending"` // waiting for the digest
+	LastDigest time.Time            `json:"last_digest"`
+}
+
+// Throttle wraps a notifier so that a notice repeated within the dedup window
+// is dropped unless its level rose, and in digest mode collects notices about
+// domains below page level into one message a day per set of recipients. Its
+// state is kept in a file, so restarts neither repeat nor lose notices.
+type Throttle struct {
+	next     Notifier
+	window   time.Duration
+	digest   bool
+	digestAt int // minutes after midnight, local time
+	path     string
+	now      func() time.Time
+	logger   *log.Logger
+	mu       sync.Mutex
+	loaded   bool
+	state    throttleState
+}
+
+func NewThrottle(next Notifier, window time.Duration, digest bool, digestAt int, storagePath string, logger *log.Logger) *Throttle {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Notify] ", log.LstdFlags)
+	}
+
+	return &Throttle{
+		next:     next,
+		window:   window,
+		digest:   digest,
+		digestAt: digestAt,
+		path:     filepath.Join(storagePath, throttleFileName),
+		now:      time.Now,
+		logger:   logger,
+		state:    throttleState{Sent: make(map[string]time.Time)},
+	}
+}
+
+// Send delivers, queues or drops msg
+func (t *Throttle) Send(msg Message) error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.load()
+	now := t.now()
+
+	key := dedupKey(msg)
+	if last, ok := t.state.Sent[key]; ok && t.window > 0 && now.Sub(last) < t.window {
+		t.logger.Printf("Suppressed repeated notification: %s", msg.Subject)
+		return nil
+	}
+
+	if t.digest && msg.Domain != "" && msg.Level.severity() < LevelPage.severity() {
+		t.state.Pending = append(t.state.Pending, msg)
+		t.state.Sent[key] = now
+		return t.save()
+	}
+
+	if err := t.next.Send(msg); err != nil {
+		return err
+	}
+	t.state.Sent[key] = now
+	t.prune(now)
+	return t.save()
+}
+
+// Flush sends the digest when one is due: notices are pending, the digest
+// time has passed today and no digest was sent since
+func (t *Throttle) Flush() error {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.load()
+	now := t.now()
+	if len(t.state.Pending) == 0 {
+		return nil
+	}
+
+	due := time.Date(now.Year(), now.Month(), now.Day(), t.digestAt/60, t.digestAt%60, 0, 0, now.Location())
+	if now.Before(due) || !t.state.LastDigest.Before(due) {
+		return nil
+	}
+
+	groups := make(map[string][]Message)
+	for _, msg := range t.state.Pending {
+		groups[strings.Join(msg.Recipients, ",")] = append(groups[strings.Join(msg.Recipients, ",")], msg)
+	}
+
+	var remaining []Message
+	var firstErr error
+	for _, messages := range groups {
+		if err := t.next.Send(buildDigest(messages, now)); err != nil {
+			remaining = append(remaining, messages...)
+			if firstErr == nil {
+				firstErr = fmt.Errorf("failed to send notification digest: %w", err)
+			}
+		}
+	}
+
+	t.state.Pending = remaining
+	if len(remaining) == 0 {
+		t.state.LastDigest = now
+	}
+	t.prune(now)
+	if err := t.save(); err != nil && firstErr == nil {
+		firstErr = err
+	}
+	return firstErr
+}
+
+// Pending returns how many notices are waiting for the digest
+func (t *Throttle) Pending() int {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	t.load()
+	return len(t.state.Pending)
+}
+
+// buildDigest summarizes messages to the same recipients, most severe first
+func buildDigest(messages []Message, now time.Time) Message {
+	sort.SliceStable(messages, func(i, j int) bool {
+		if messages[i].Level.severity() != messages[j].Level.severity() {
+			return messages[i].Level.severity() > messages[j].Level.severity()
+		}
+		return messages[i].Domain < messages[j].Domain
+	})
+
+	digest := Message{
+		Level:      messages[0].Level,
+		Event:      EventDigest,
+		Subject:    fmt.Sprintf("Certificate digest for %s: %d notices", now.Format("2006-01-02"), len(messages)),
+		Recipients: messages[0].Recipients,
+	}
+
+	var b strings.Builder
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "[%s] %s\n", strings.ToUpper(string(msg.Level)), msg.Subject)
+	}
+	for _, msg := range messages {
+		fmt.Fprintf(&b, "\n--- %s ---\n%s\n", msg.Subject, msg.Body)
+	}
+	digest.Body = b.String()
+	return digest
+}
+
+// dedupKey identifies a notice; a higher level is a new notice, so escalations
+// are always sent
+func dedupKey(msg Message) string {
+	key := msg.Key
+	if key == "" {
+		key = msg.Domain + "|" + msg.Subject
+	}
+	return key + "|" + string(msg.Level)
+}
+
+// prune forgets notices sent longer ago than the dedup window
+func (t *Throttle) prune(now time.Time) {
+	for key, sent := range t.state.Sent {
+		if now.Sub(sent) >= t.window {
+			delete(t.state.Sent, key)
+		}
+	}
+}
+
+func (t *Throttle) load() {
+	if t.loaded {
+		return
+	}
+	t.loaded = true
+
+	data, err := os.ReadFile(t.path)
+	if err != nil {
+		if !os.IsNotExist(err) {
+			t.logger.Printf("Warning: failed to read notification state: %v", err)
+		}
+		return
+	}
+
+	var state throttleState
+	if err := json.Unmarshal(data, &state); err != nil {
+		t.logger.Printf("Warning: ignoring corrupt notification state %s: %v", t.path, err)
+		return
+	}
+	if state.Sent == nil {
+		state.Sent = make(map[string]time.Time)
+	}
+	t.state = state
+}
+
+func (t *Throttle) save() error {
+	data, err := json.MarshalIndent(t.state, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode notification state: %w", err)
+	}
+
+	if err := os.MkdirAll(filepath.Dir(t.path), 0755); err != nil {
+		return fmt.Errorf("failed to create storage directory: %w", err)
+	}
+
+	tmp := t.path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write notification state: %w", err)
+	}
+	return os.Rename(tmp, t.path)
+}
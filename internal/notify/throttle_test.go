@@ -0,0 +1,111 @@
+package notify
+
+import (
+	"log"
+	"os"
+	"strings"
+	"testing"
+	"time"
+)
+
+type recorder struct {
+	messages []Message
+}
+
+func (r *recorder) Send(msg Message) error {
+	r.messages = append(r.messages, msg)
+	return nil
+}
+
+func newTestThrottle(t *testing.T, next Notifier, digest bool, now *time.Time) *Throttle {
+	t.Helper()
+	throttle := NewThrottle(next, 24*time.Hour, digest, 8*60, t.TempDir(), log.New(os.Stdout, "[TEST] ", log.LstdFlags))
+	throttle.now = func() time.Time { return *now }
+	return throttle
+}
+
+func TestThrottle_Deduplicates(t *testing.T) {
+	now := time.Date(2026, 3, 1, 12, 0, 0, 0, time.Local)
+	next := &recorder{}
+	throttle := newTestThrottle(t, next, false, &now)
+
+	warning := Message{Level: LevelWarning, Domain: "example.com", Key: "expiry:example.com", Subject: "expires in 14 days"}
+	for _, msg := range []Message{warning, warning} {
+		if err := throttle.Send(msg); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+	}
+	if len(next.messages) != 1 {
+		t.Fatalf("sent %d messages, want the repeat suppressed", len(next.messages))
+	}
+
+	// An escalation is a new notice, even within the window
+	critical := warning
+	critical.Level = LevelCritical
+	throttle.Send(critical)
+	if len(next.messages) != 2 {
+		t.Fatalf("sent %d messages, want the escalation delivered", len(next.messages))
+	}
+
+	now = now.Add(25 * time.Hour)
+	throttle.Send(warning)
+	if len(next.messages) != 3 {
+		t.Fatalf("sent %d messages, want a repeat once the window passed", len(next.messages))
+	}
+}
+
+func TestThrottle_Digest(t *testing.T) {
+	now := time.Date(2026, 3, 1, 6, 0, 0, 0, time.Local)
+	next := &recorder{}
+	throttle := newTestThrottle(t, next, true, &now)
+
+	throttle.Send(Message{Level: LevelWarning, Domain: "a.example.com", Subject: "a expires in 10 days"})
+	throttle.Send(Message{Level: LevelCritical, Domain: "b.example.com", Subject: "b expires in 2 days"})
+	throttle.Send(Message{Level: LevelPage, Domain: "c.example.com", Subject: "c expires in 1 day"})
+	throttle.Send(Message{Level: LevelCritical, Subject: "storage low"})
+
+	if len(next.messages) != 2 {
+		t.Fatalf("sent %d messages immediately, want only the page and the message without a domain", len(next.messages))
+	}
+	if throttle.Pending() != 2 {
+		t.Fatalf("Pending() = %d, want 2", throttle.Pending())
+	}
+
+	// Before the digest time nothing is sent
+	if err := throttle.Flush(); err != nil || len(next.messages) != 2 {
+		t.Fatalf("Flush() before digest time sent a digest (err %v)", err)
+	}
+
+	now = now.Add(3 * time.Hour)
+	if err := throttle.Flush(); err != nil {
+		t.Fatalf("Flush() error = %v", err)
+	}
+	if len(next.messages) != 3 {
+		t.Fatalf("sent %d messages, want one digest", len(next.messages))
+	}
+	digest := next.messages[2]
+	if digest.Level != LevelCritical || !strings.HasPrefix(digest.Body, "[CRITICAL] b expires in 2 days\n[WARNING] a expires") {
+		t.Errorf("digest = %+v, want the most severe notice first", digest)
+	}
+
+	// Only one digest a day
+	throttle.Send(Message{Level: LevelWarning, Domain: "d.example.com", Subject: "d expires in 9 days"})
+	now = now.Add(time.Hour)
+	throttle.Flush()
+	if len(next.messages) != 3 {
+		t.Errorf("sent a second digest on the same day")
+	}
+}
+
+func TestThrottle_PersistsState(t *testing.T) {
+	dir := t.TempDir()
+	next := &recorder{}
+	msg := Message{Level: LevelWarning, Domain: "example.com", Subject: "expires in 14 days"}
+
+	NewThrottle(next, time.Hour, false, 0, dir, nil).Send(msg)
+	NewThrottle(next, time.Hour, false, 0, dir, nil).Send(msg)
+
+	if len(next.messages) != 1 {
+		t.Errorf("sent %d messages, want the repeat after a restart suppressed", len(next.messages))
+	}
+}
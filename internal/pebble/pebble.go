@@ -0,0 +1,311 @@
+// Package pebble runs Pebble, Let's Encrypt's ACME test server, so issuance
+// and renewal can be exercised end to end in CI and local development without
+// touching Let's Encrypt staging. See https://github.com/letsencrypt/pebble.
+package pebble
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// DefaultDirURL is the directory of a Pebble running with its defaults,
+	// e.g. the ghcr.io/letsencrypt/pebble container
+	DefaultDirURL = "https://localhost:14000/dir"
+
+	// EnvDirURL and EnvCACert point the manager and the integration tests at
+	// a running Pebble: its directory and the PEM root of its HTTPS certificate
+	EnvDirURL = "PEBBLE_DIR_URL"
+	EnvCACert = "PEBBLE_CA_CERT"
+
+	// startTimeout bounds the wait for a started Pebble to answer
+	startTimeout = 30 * time.Second
+)
+
+// Options configure a Pebble started by Start
+type Options struct {
+	Binary           string // pebble executable; empty looks it up in PATH
+	Listen           string // address of the ACME API; empty picks a free port on localhost
+	ManagementListen string // address of the management API; empty picks a free port on localhost
+	AlwaysValid      bool   // accept every challenge without validating it
+	NonceReject      int    // percentage of requests failing with badNonce, to exercise retries
+	DNSServer        string // resolver challenges are validated with, e.g. pebble-challtestsrv's "127.0.0.1:8053"
+}
+
+// Instance is a running Pebble
+type Instance struct {
+	DirURL        string
+	ManagementURL string
+	CACert        string // PEM file of the root of Pebble's HTTPS certificate
+
+	dir    string
+	cmd    *exec.Cmd
+	exited chan struct{}
+	output *syncBuffer
+}
+
+// FromEnvironment returns the Pebble named by PEBBLE_DIR_URL and
+// PEBBLE_CA_CERT; ok is false when PEBBLE_DIR_URL isn't set
+func FromEnvironment() (dirURL, caCert string, ok bool) {
+	dirURL = os.Getenv(EnvDirURL)
+	return dirURL, os.Getenv(EnvCACert), dirURL != ""
+}
+
+// Start runs Pebble with a freshly generated HTTPS certificate and waits until
+// it answers. Close stops it and removes its files.
+func Start(ctx context.Context, opts Options) (*Instance, error) {
+	binary := opts.Binary
+	if binary == "" {
+		path, err := exec.LookPath("pebble")
+		if err != nil {
+			return nil, fmt.Errorf("pebble is not installed: %w", err)
+		}
+		binary = path
+	}
+
+	dir, err := os.MkdirTemp("", "pebble-*")
+	if err != nil {
+		return nil, fmt.Errorf("failed to create Pebble directory: %w", err)
+	}
+	instance, err := start(ctx, binary, dir, opts)
+	if err != nil {
+		os.RemoveAll(dir)
+		return nil, err
+	}
+	return instance, nil
+}
+
+func start(ctx context.Context, binary, dir string, opts Options) (*Instance, error) {
+	listen, err := listenAddress(opts.Listen)
+	if err != nil {
+		return nil, err
+	}
+	management, err := listenAddress(opts.ManagementListen)
+	if err != nil {
+		return nil, err
+	}
+	if err := writeTLS(dir); err != nil {
+		return nil, err
+	}
+	configFile := filepath.Join(dir, "pebble-config.json")
+	if err := writeConfig(configFile, dir, listen, management); err != nil {
+		return nil, err
+	}
+
+	args := []string{"-config", configFile}
+	if opts.DNSServer != "" {
+		args = append(args, "-dnsserver", opts.DNSServer)
+	}
+	cmd := exec.Command(binary, args...)
+	cmd.Env = append(os.Environ(),
+		"PEBBLE_VA_NOSLEEP=1",
+		"PEBBLE_WFE_NONCEREJECT="+strconv.Itoa(opts.NonceReject))
+	if opts.AlwaysValid {
+		cmd.Env = append(cmd.Env, "PEBBLE_VA_ALWAYS_VALID=1")
+	}
+	output := &syncBuffer{}
+	cmd.Stdout = output
+	cmd.Stderr = output
+	if err := cmd.Start(); err != nil {
+		return nil, fmt.Errorf("failed to start Pebble: %w", err)
+	}
+
+	instance := &Instance{
+		DirURL:        "https://" + clientAddress(listen) + "/dir",
+		ManagementURL: "https://" + clientAddress(management),
+		CACert:        filepath.Join(dir, caFileName),
+		dir:           dir,
+		cmd:           cmd,
+		exited:        make(chan struct{}),
+		output:        output,
+	}
+	go func() {
+		cmd.Wait()
+		close(instance.exited)
+	}()
+
+	if err := instance.waitReady(ctx); err != nil {
+		instance.stop()
+		return nil, err
+	}
+	return instance, nil
+}
+
+// waitReady polls the directory until Pebble answers
+func (i *Instance) waitReady(ctx context.Context) error {
+	client, err := i.Client()
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(ctx, startTimeout)
+	defer cancel()
+
+	for {
+		req, _ := http.NewRequestWithContext(ctx, http.MethodGet, i.DirURL, nil)
+		if resp, err := client.Do(req); err == nil {
+			resp.Body.Close()
+			if resp.StatusCode == http.StatusOK {
+				return nil
+			}
+		}
+
+		select {
+		case <-i.exited:
+			return fmt.Errorf("pebble exited: %s", strings.TrimSpace(i.output.String()))
+		case <-ctx.Done():
+			return fmt.Errorf("pebble did not answer at %s: %w", i.DirURL, ctx.Err())
+		case <-time.After(100 * time.Millisecond):
+		}
+	}
+}
+
+// Client returns an HTTP client trusting Pebble's HTTPS certificate
+func (i *Instance) Client() (*http.Client, error) {
+	pem, err := os.ReadFile(i.CACert)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read Pebble's CA certificate: %w", err)
+	}
+	roots := x509.NewCertPool()
+	if !roots.AppendCertsFromPEM(pem) {
+		return nil, fmt.Errorf("no certificates in %s", i.CACert)
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.TLSClientConfig = &tls.Config{RootCAs: roots}
+	return &http.Client{Transport: transport, Timeout: 10 * time.Second}, nil
+}
+
+// Root returns the PEM root Pebble signs certificates with. Pebble generates
+// it when it starts, so certificates of an earlier run don't chain to it.
+func (i *Instance) Root(ctx context.Context) ([]byte, error) {
+	client, err := i.Client()
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, i.ManagementURL+"/roots/0", nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to create root request: %w", err)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch Pebble's root: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("pebble returned %s for its root", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Output returns what Pebble logged so far
+func (i *Instance) Output() string {
+	return i.output.String()
+}
+
+// Close stops Pebble and removes its files
+func (i *Instance) Close() error {
+	i.stop()
+	return os.RemoveAll(i.dir)
+}
+
+func (i *Instance) stop() {
+	select {
+	case <-i.exited:
+		return
+	default:
+	}
+	i.cmd.Process.Kill()
+	<-i.exited
+}
+
+// listenAddress returns addr, or a free port on localhost when it's empty
+func listenAddress(addr string) (string, error) {
+	if addr != "" {
+		return addr, nil
+	}
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		return "", fmt.Errorf("failed to find a free port: %w", err)
+	}
+	defer l.Close()
+	return l.Addr().String(), nil
+}
+
+// clientAddress is where a client reaches a listen address. Pebble's
+// certificate is valid for localhost, so wildcard hosts are reached there.
+func clientAddress(listen string) string {
+	host, port, err := net.SplitHostPort(listen)
+	if err != nil {
+		return listen
+	}
+	if host == "" || host == "0.0.0.0" || host == "::" {
+		host = "localhost"
+	}
+	return net.JoinHostPort(host, port)
+}
+
+// config is Pebble's configuration file
+type config struct {
+	Pebble struct {
+		ListenAddress                  string `json:"listenAddress"`
+		ManagementListenAddress        string `json:"managementListenAddress"`
+		Certificate                    string `json:"certificate"`
+		PrivateKey                     string `json:"privateKey"`
+		HTTPPort                       int    `json:"httpPort"`
+		TLSPort                        int    `json:"tlsPort"`
+		OCSPResponderURL               string `json:"ocspResponderURL"`
+		ExternalAccountBindingRequired bool   `json:"externalAccountBindingRequired"`
+	} `json:"pebble"`
+}
+
+func writeConfig(path, dir, listen, management string) error {
+	var c config
+	c.Pebble.ListenAddress = listen
+	c.Pebble.ManagementListenAddress = management
+	c.Pebble.Certificate = filepath.Join(dir, certFileName)
+	c.Pebble.PrivateKey = filepath.Join(dir, keyFileName)
+	// The ports the manager answers challenges on
+	c.Pebble.HTTPPort = 5002
+	c.Pebble.TLSPort = 5001
+
+	data, err := json.MarshalIndent(c, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode Pebble configuration: %w", err)
+	}
+	if err := os.WriteFile(path, data, 0644); err != nil {
+		return fmt.Errorf("failed to write Pebble configuration: %w", err)
+	}
+	return nil
+}
+
+// syncBuffer collects the output of Pebble while it runs
+type syncBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *syncBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+func (b *syncBuffer) String() string {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
@@ -0,0 +1,67 @@
+package pebble
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestWriteTLS(t *testing.T) {
+	dir := t.TempDir()
+	if err := writeTLS(dir); err != nil {
+		t.Fatalf("writeTLS() error = %v", err)
+	}
+
+	cert, err := tls.LoadX509KeyPair(filepath.Join(dir, certFileName), filepath.Join(dir, keyFileName))
+	if err != nil {
+		t.Fatalf("failed to load Pebble's certificate: %v", err)
+	}
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = &tls.Config{Certificates: []tls.Certificate{cert}}
+	server.StartTLS()
+	defer server.Close()
+
+	// The certificate is valid for localhost and chains to the written root
+	client, err := (&Instance{CACert: filepath.Join(dir, caFileName)}).Client()
+	if err != nil {
+		t.Fatal(err)
+	}
+	resp, err := client.Get(strings.Replace(server.URL, "127.0.0.1", "localhost", 1))
+	if err != nil {
+		t.Fatalf("Pebble's certificate is not trusted: %v", err)
+	}
+	resp.Body.Close()
+}
+
+func TestStart_ReportsExit(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a shell script")
+	}
+	binary := filepath.Join(t.TempDir(), "pebble")
+	if err := os.WriteFile(binary, []byte("#!/bin/sh\necho 'listen tcp: address already in use'\nexit 1\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	_, err := Start(context.Background(), Options{Binary: binary})
+	if err == nil || !strings.Contains(err.Error(), "address already in use") {
+		t.Errorf("Start() error = %v, want Pebble's output", err)
+	}
+}
+
+func TestClientAddress(t *testing.T) {
+	for listen, want := range map[string]string{
+		"0.0.0.0:14000":   "localhost:14000",
+		":14000":          "localhost:14000",
+		"127.0.0.1:14000": "127.0.0.1:14000",
+	} {
+		if got := clientAddress(listen); got != want {
+			t.Errorf("clientAddress(%q) = %q, want %q", listen, got, want)
+		}
+	}
+}
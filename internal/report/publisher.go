@@ -0,0 +1,199 @@
+package report
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+const (
+	// stateFileName records in the storage path when the report was last sent
+	stateFileName = "report-state.json"
+	// webhookTimeout bounds the delivery to the webhook
+	webhookTimeout = 30 * time.Second
+)
+
+// Publisher delivers weekly reports on their day and time
+type Publisher struct {
+	cfg        config.Report
+	day        time.Weekday
+	at         int // minutes after midnight
+	email      notify.Notifier
+	statePath  string
+	httpClient *http.Client
+	logger     *log.Logger
+}
+
+type state struct {
+	LastSent time.Time `json:"last_sent"`
+}
+
+// NewPublisher creates a publisher sending reports on day at the given minutes
+// after midnight. email delivers the report when cfg.Email is set.
+func NewPublisher(cfg config.Report, day time.Weekday, at int, email notify.Notifier, storagePath string, logger *log.Logger) *Publisher {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Report] ", log.LstdFlags)
+	}
+
+	return &Publisher{
+		cfg:        cfg,
+		day:        day,
+		at:         at,
+		email:      email,
+		statePath:  filepath.Join(storagePath, stateFileName),
+		httpClient: &http.Client{Timeout: webhookTimeout},
+		logger:     logger,
+	}
+}
+
+// Scheduled returns the most recent time, at or before now, a report was due
+func (p *Publisher) Scheduled(now time.Time) time.Time {
+	back := (int(now.Weekday()) - int(p.day) + 7) % 7
+	date := now.AddDate(0, 0, -back)
+	scheduled := time.Date(date.Year(), date.Month(), date.Day(), p.at/60, p.at%60, 0, 0, now.Location())
+	if scheduled.After(now) {
+		scheduled = scheduled.AddDate(0, 0, -7)
+	}
+	return scheduled
+}
+
+// Due reports whether a report is due at now, which is the case when none was
+// sent since the last scheduled time. A report missed while the manager was
+// down is sent when it starts. The first time the publisher runs, nothing is
+// due until the next scheduled time.
+func (p *Publisher) Due(now time.Time) (bool, error) {
+	s, err := p.loadState()
+	if err != nil {
+		return false, err
+	}
+	if s == nil {
+		return false, p.saveState(state{LastSent: now})
+	}
+	return s.LastSent.Before(p.Scheduled(now)), nil
+}
+
+// Publish delivers the report to every configured destination. The report is
+// recorded as sent even when a destination fails, so it isn't sent again to
+// the others every minute; the errors are returned.
+func (p *Publisher) Publish(ctx context.Context, w *Weekly) error {
+	var errs []error
+	if p.cfg.Email && p.email != nil {
+		err := p.email.Send(notify.Message{
+			Level:      notify.LevelInfo,
+			Event:      notify.EventReport,
+			Subject:    w.Subject(),
+			Body:       w.Markdown(),
+			Key:        "weekly-report " + w.FileName(),
+			Recipients: p.cfg.Recipients,
+		})
+		if err != nil {
+			errs = append(errs, fmt.Errorf("failed to email weekly report: %w", err))
+		}
+	}
+	if p.cfg.WebhookURL != "" {
+		if err := p.post(ctx, w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	if p.cfg.Directory != "" {
+		if err := p.write(w); err != nil {
+			errs = append(errs, err)
+		}
+	}
+
+	if err := p.saveState(state{LastSent: w.To}); err != nil {
+		errs = append(errs, err)
+	}
+	if len(errs) == 0 {
+		p.logger.Printf("Published weekly report: %d renewed, %d expiring, %d failures",
+			len(w.Renewed), len(w.Upcoming), len(w.Failures))
+	}
+	return errors.Join(errs...)
+}
+
+func (p *Publisher) post(ctx context.Context, w *Weekly) error {
+	payload, err := json.Marshal(w)
+	if err != nil {
+		return fmt.Errorf("failed to encode weekly report: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.cfg.WebhookURL, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create weekly report request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := p.httpClient.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to post weekly report: %w", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("weekly report webhook returned %s: %s", resp.Status, strings.TrimSpace(string(body)))
+	}
+	return nil
+}
+
+func (p *Publisher) write(w *Weekly) error {
+	if err := os.MkdirAll(p.cfg.Directory, 0755); err != nil {
+		return fmt.Errorf("failed to create report directory: %w", err)
+	}
+
+	path := filepath.Join(p.cfg.Directory, w.FileName())
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(w.Markdown()), 0644); err != nil {
+		return fmt.Errorf("failed to write weekly report: %w", err)
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write weekly report: %w", err)
+	}
+	return nil
+}
+
+func (p *Publisher) loadState() (*state, error) {
+	data, err := os.ReadFile(p.statePath)
+	if os.IsNotExist(err) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to read report state: %w", err)
+	}
+
+	var s state
+	if err := json.Unmarshal(data, &s); err != nil {
+		return nil, fmt.Errorf("failed to parse report state: %w", err)
+	}
+	return &s, nil
+}
+
+func (p *Publisher) saveState(s state) error {
+	data, err := json.Marshal(s)
+	if err != nil {
+		return fmt.Errorf("failed to encode report state: %w", err)
+	}
+
+	tmp := p.statePath + ".tmp"
+	if err := os.WriteFile(tmp, data, 0644); err != nil {
+		return fmt.Errorf("failed to write report state: %w", err)
+	}
+	if err := os.Rename(tmp, p.statePath); err != nil {
+		os.Remove(tmp)
+		return fmt.Errorf("failed to write report state: %w", err)
+	}
+	return nil
+}
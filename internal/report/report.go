@@ -0,0 +1,176 @@
+// Package report builds the weekly summary of all certificates reviewed by
+// operations: what was renewed, what expires soon, what failed and how long
+// before expiry certificates were renewed, and delivers it by email, webhook
+// or as a markdown file.
+package report
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+)
+
+// Period is the time a weekly report covers
+const Period = 7 * 24 * time.Hour
+
+// Weekly is the summary of one week
+type Weekly struct {
+	From            time.Time `json:"from" yaml:"from"`
+	To              time.Time `json:"to" yaml:"to"`
+	Certificates    int       `json:"certificates" yaml:"certificates"` // managed at the end of the week
+	Runs            int       `json:"runs" yaml:"runs"`                 // scheduler runs during the week
+	Renewed         []Renewal `json:"renewed" yaml:"renewed"`
+	Upcoming        []Expiry  `json:"upcoming" yaml:"upcoming"`
+	UpcomingDays    int       `json:"upcoming_days" yaml:"upcoming_days"`
+	Failures        []Failure `json:"failures" yaml:"failures"`
+	AverageLeadDays float64   `json:"average_lead_days" yaml:"average_lead_days"` // 0 without renewals
+}
+
+// Renewal is a certificate renewed during the week
+type Renewal struct {
+	Domain   string    `json:"domain" yaml:"domain"`
+	At       time.Time `json:"at" yaml:"at"`
+	LeadDays float64   `json:"lead_days" yaml:"lead_days"` // days the previous certificate had left
+}
+
+// Expiry is a certificate expiring soon
+type Expiry struct {
+	Domain    string    `json:"domain" yaml:"domain"`
+	ExpiresAt time.Time `json:"expires_at" yaml:"expires_at"`
+	DaysLeft  int       `json:"days_left" yaml:"days_left"`
+}
+
+// Failure is a failed operation during the week
+type Failure struct {
+	Domain    string    `json:"domain" yaml:"domain"`
+	Operation string    `json:"operation" yaml:"operation"`
+	Error     string    `json:"error" yaml:"error"`
+	At        time.Time `json:"at" yaml:"at"`
+}
+
+// NewWeekly starts the report of the week ending at to, listing certificates
+// expiring within upcomingDays
+func NewWeekly(to time.Time, upcomingDays int) *Weekly {
+	return &Weekly{
+		From:         to.Add(-Period),
+		To:           to,
+		Renewed:      []Renewal{},
+		Upcoming:     []Expiry{},
+		UpcomingDays: upcomingDays,
+		Failures:     []Failure{},
+	}
+}
+
+// Covers reports whether t falls within the week
+func (w *Weekly) Covers(t time.Time) bool {
+	return !t.Before(w.From) && t.Before(w.To)
+}
+
+// AddRenewal records the renewal of domain at a time, replacing a certificate
+// that expired at expiresAt. Renewals outside the week are ignored.
+func (w *Weekly) AddRenewal(domain string, at, expiresAt time.Time) {
+	if !w.Covers(at) {
+		return
+	}
+	w.Renewed = append(w.Renewed, Renewal{Domain: domain, At: at, LeadDays: days(expiresAt.Sub(at))})
+}
+
+// AddCertificate counts a managed certificate and lists it when it expires
+// within the upcoming days
+func (w *Weekly) AddCertificate(domain string, notAfter time.Time) {
+	w.Certificates++
+	left := notAfter.Sub(w.To)
+	if left > time.Duration(w.UpcomingDays)*24*time.Hour {
+		return
+	}
+	w.Upcoming = append(w.Upcoming, Expiry{Domain: domain, ExpiresAt: notAfter, DaysLeft: int(math.Floor(left.Hours() / 24))})
+}
+
+// AddFailure records a failed operation. Failures outside the week are ignored.
+func (w *Weekly) AddFailure(f Failure) {
+	if !w.Covers(f.At) {
+		return
+	}
+	w.Failures = append(w.Failures, f)
+}
+
+// Finish sorts the report and computes the average renewal lead time
+func (w *Weekly) Finish() {
+	sort.Slice(w.Renewed, func(i, j int) bool { return w.Renewed[i].At.Before(w.Renewed[j].At) })
+	sort.Slice(w.Upcoming, func(i, j int) bool { return w.Upcoming[i].ExpiresAt.Before(w.Upcoming[j].ExpiresAt) })
+	sort.Slice(w.Failures, func(i, j int) bool { return w.Failures[i].At.Before(w.Failures[j].At) })
+
+	w.AverageLeadDays = 0
+	if len(w.Renewed) > 0 {
+		var total float64
+		for _, r := range w.Renewed {
+			total += r.LeadDays
+		}
+		w.AverageLeadDays = math.Round(total/float64(len(w.Renewed))*10) / 10
+	}
+}
+
+// Subject is the subject of the report's email
+func (w *Weekly) Subject() string {
+	return fmt.Sprintf("Weekly certificate report: %d renewed, %d expiring, %d failures",
+		len(w.Renewed), len(w.Upcoming), len(w.Failures))
+}
+
+// Markdown renders the report as a markdown document
+func (w *Weekly) Markdown() string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# Weekly certificate report\n\n")
+	fmt.Fprintf(&b, "%s to %s: %d certificates, %d scheduler runs.\n\n",
+		w.From.Format("2006-01-02 15:04"), w.To.Format("2006-01-02 15:04 MST"), w.Certificates, w.Runs)
+
+	fmt.Fprintf(&b, "## Renewed this week (%d)\n\n", len(w.Renewed))
+	if len(w.Renewed) == 0 {
+		b.WriteString("No certificates were renewed.\n\n")
+	} else {
+		b.WriteString("| Domain | Renewed | Lead time |\n|---|---|---|\n")
+		for _, r := range w.Renewed {
+			fmt.Fprintf(&b, "| %s | %s | %.1f days |\n", cell(r.Domain), r.At.Format("2006-01-02 15:04"), r.LeadDays)
+		}
+		fmt.Fprintf(&b, "\nAverage renewal lead time: %.1f days.\n\n", w.AverageLeadDays)
+	}
+
+	fmt.Fprintf(&b, "## Expiring within %d days (%d)\n\n", w.UpcomingDays, len(w.Upcoming))
+	if len(w.Upcoming) == 0 {
+		b.WriteString("No certificates expire soon.\n\n")
+	} else {
+		b.WriteString("| Domain | Expires | Days left |\n|---|---|---|\n")
+		for _, e := range w.Upcoming {
+			fmt.Fprintf(&b, "| %s | %s | %d |\n", cell(e.Domain), e.ExpiresAt.Format("2006-01-02 15:04"), e.DaysLeft)
+		}
+		b.WriteString("\n")
+	}
+
+	fmt.Fprintf(&b, "## Failures (%d)\n\n", len(w.Failures))
+	if len(w.Failures) == 0 {
+		b.WriteString("Nothing failed.\n")
+	} else {
+		b.WriteString("| Domain | Operation | Time | Error |\n|---|---|---|---|\n")
+		for _, f := range w.Failures {
+			fmt.Fprintf(&b, "| %s | %s | %s | %s |\n", cell(f.Domain), cell(f.Operation), f.At.Format("2006-01-02 15:04"), cell(f.Error))
+		}
+	}
+	return b.String()
+}
+
+// FileName is the name the report is written under in the report directory
+func (w *Weekly) FileName() string {
+	year, week := w.To.ISOWeek()
+	return fmt.Sprintf("weekly-%d-W%02d.md", year, week)
+}
+
+// cell keeps a value from breaking its markdown table row
+func cell(s string) string {
+	s = strings.ReplaceAll(s, "|", `\|`)
+	return strings.Join(strings.Fields(s), " ")
+}
+
+func days(d time.Duration) float64 {
+	return math.Round(d.Hours()/24*10) / 10
+}
@@ -0,0 +1,140 @@
+package report
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"github.com/O-tero/traefik-cert-manager/internal/notify"
+)
+
+func testLogger() *log.Logger {
+	return log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+}
+
+func TestWeekly(t *testing.T) {
+	to := time.Date(2030, 3, 11, 9, 0, 0, 0, time.UTC)
+	w := NewWeekly(to, 30)
+
+	w.AddRenewal("b.example.com", to.Add(-24*time.Hour), to.Add(29*24*time.Hour))
+	w.AddRenewal("a.example.com", to.Add(-48*time.Hour), to.Add(28*24*time.Hour))
+	w.AddRenewal("old.example.com", to.Add(-8*24*time.Hour), to)
+	w.AddCertificate("soon.example.com", to.Add(10*24*time.Hour+time.Hour))
+	w.AddCertificate("later.example.com", to.Add(60*24*time.Hour))
+	w.AddFailure(Failure{Domain: "c.example.com", Operation: "renew", Error: "rate | limited\nretry later", At: to.Add(-time.Hour)})
+	w.AddFailure(Failure{Domain: "c.example.com", Operation: "renew", Error: "outside", At: to.Add(time.Hour)})
+	w.Finish()
+
+	if len(w.Renewed) != 2 || w.Renewed[0].Domain != "a.example.com" {
+		t.Fatalf("Renewed = %+v, want a.example.com then b.example.com", w.Renewed)
+	}
+	if w.Renewed[0].LeadDays != 30 || w.Renewed[1].LeadDays != 30 || w.AverageLeadDays != 30 {
+		t.Errorf("lead times = %v, %v, average %v, want 30 days", w.Renewed[0].LeadDays, w.Renewed[1].LeadDays, w.AverageLeadDays)
+	}
+	if w.Certificates != 2 || len(w.Upcoming) != 1 || w.Upcoming[0].DaysLeft != 10 {
+		t.Errorf("Certificates = %d, Upcoming = %+v, want 2 certificates and soon.example.com in 10 days", w.Certificates, w.Upcoming)
+	}
+	if len(w.Failures) != 1 {
+		t.Errorf("Failures = %+v, want only the one within the week", w.Failures)
+	}
+
+	markdown := w.Markdown()
+	for _, want := range []string{
+		"## Renewed this week (2)",
+		"| a.example.com | 2030-03-09 09:00 | 30.0 days |",
+		"Average renewal lead time: 30.0 days.",
+		"## Expiring within 30 days (1)",
+		`| c.example.com | renew | 2030-03-11 08:00 | rate \| limited retry later |`,
+	} {
+		if !strings.Contains(markdown, want) {
+			t.Errorf("markdown is missing %q:\n%s", want, markdown)
+		}
+	}
+	if w.FileName() != "weekly-2030-W11.md" {
+		t.Errorf("FileName() = %q", w.FileName())
+	}
+}
+
+func TestPublisher_Due(t *testing.T) {
+	p := NewPublisher(config.Report{}, time.Monday, 9*60, nil, t.TempDir(), testLogger())
+
+	// Wednesday 2030-03-13
+	now := time.Date(2030, 3, 13, 12, 0, 0, 0, time.UTC)
+	if got, want := p.Scheduled(now), time.Date(2030, 3, 11, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Scheduled() = %v, want %v", got, want)
+	}
+	monday := time.Date(2030, 3, 18, 8, 59, 0, 0, time.UTC)
+	if got, want := p.Scheduled(monday), time.Date(2030, 3, 11, 9, 0, 0, 0, time.UTC); !got.Equal(want) {
+		t.Errorf("Scheduled() before the time = %v, want %v", got, want)
+	}
+
+	// The first run only records when the publisher started
+	if due, err := p.Due(now); err != nil || due {
+		t.Fatalf("Due() on first run = %v, %v, want false", due, err)
+	}
+	if due, _ := p.Due(monday); due {
+		t.Error("report due before its time")
+	}
+	if due, _ := p.Due(monday.Add(time.Minute)); !due {
+		t.Error("report not due at its time")
+	}
+	// Missed while down, sent when the manager starts again
+	if due, _ := p.Due(monday.Add(50 * time.Hour)); !due {
+		t.Error("missed report not due")
+	}
+}
+
+type recordingNotifier struct {
+	messages []notify.Message
+}
+
+func (n *recordingNotifier) Send(msg notify.Message) error {
+	n.messages = append(n.messages, msg)
+	return nil
+}
+
+func TestPublisher_Publish(t *testing.T) {
+	var posted Weekly
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if err := json.NewDecoder(r.Body).Decode(&posted); err != nil {
+			t.Errorf("failed to decode report: %v", err)
+		}
+	}))
+	defer server.Close()
+
+	storage := t.TempDir()
+	reports := filepath.Join(t.TempDir(), "reports")
+	email := &recordingNotifier{}
+	cfg := config.Report{Enabled: true, Email: true, Recipients: []string{"ops@example.com"}, WebhookURL: server.URL, Directory: reports}
+	p := NewPublisher(cfg, time.Monday, 9*60, email, storage, testLogger())
+
+	to := time.Date(2030, 3, 11, 9, 0, 0, 0, time.UTC)
+	w := NewWeekly(to, 30)
+	w.AddRenewal("a.example.com", to.Add(-time.Hour), to.Add(30*24*time.Hour))
+	w.Finish()
+
+	if err := p.Publish(context.Background(), w); err != nil {
+		t.Fatalf("Publish() error = %v", err)
+	}
+	if len(email.messages) != 1 || email.messages[0].Recipients[0] != "ops@example.com" || !strings.Contains(email.messages[0].Body, "a.example.com") {
+		t.Errorf("emailed %+v", email.messages)
+	}
+	if len(posted.Renewed) != 1 || posted.Renewed[0].Domain != "a.example.com" {
+		t.Errorf("posted %+v", posted)
+	}
+	data, err := os.ReadFile(filepath.Join(reports, "weekly-2030-W11.md"))
+	if err != nil || !strings.Contains(string(data), "a.example.com") {
+		t.Errorf("report file = %q, %v", data, err)
+	}
+	if due, _ := p.Due(to.Add(time.Hour)); due {
+		t.Error("report due again right after it was published")
+	}
+}
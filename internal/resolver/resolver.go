@@ -0,0 +1,197 @@
+package resolver
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// maxResponseSize bounds a DNS-over-HTTPS response body
+const maxResponseSize = 64 * 1024
+
+// Resolver is the subset of net.Resolver used for validation pre-checks and
+// propagation polling
+type Resolver interface {
+	LookupHost(ctx context.Context, host string) ([]string, error)
+	LookupTXT(ctx context.Context, name string) ([]string, error)
+}
+
+// New returns a DNS-over-HTTPS resolver when servers are configured, otherwise the
+// system resolver
+func New(cfg config.DNS) (Resolver, error) {
+	if len(cfg.DoHResolvers) == 0 {
+		return net.DefaultResolver, nil
+	}
+
+	timeout, err := time.ParseDuration(cfg.Timeout)
+	if err != nil {
+		return nil, fmt.Errorf("invalid DNS timeout: %w", err)
+	}
+	return NewDoH(cfg.DoHResolvers, timeout), nil
+}
+
+// DoH resolves names through RFC 8484 DNS-over-HTTPS servers, bypassing local
+// resolvers that are broken or rewrite answers inside containers
+type DoH struct {
+	servers    []string
+	httpClient *http.Client
+}
+
+func NewDoH(servers []string, timeout time.Duration) *DoH {
+	return &DoH{
+		servers:    servers,
+		httpClient: &http.Client{Timeout: timeout},
+	}
+}
+
+// LookupHost returns the IPv4 and IPv6 addresses of host
+func (d *DoH) LookupHost(ctx context.Context, host string) ([]string, error) {
+	var addrs []string
+	for _, qtype := range []dnsmessage.Type{dnsmessage.TypeA, dnsmessage.TypeAAAA} {
+		answers, err := d.query(ctx, host, qtype)
+		if err != nil {
+			return nil, err
+		}
+		for _, answer := range answers {
+			switch body := answer.Body.(type) {
+			case *dnsmessage.AResource:
+				addrs = append(addrs, net.IP(body.A[:]).String())
+			case *dnsmessage.AAAAResource:
+				addrs = append(addrs, net.IP(body.AAAA[:]).String())
+			}
+		}
+	}
+
+	if len(addrs) == 0 {
+		return nil, &net.DNSError{Err: "no addresses found", Name: host, IsNotFound: true}
+	}
+	return addrs, nil
+}
+
+// LookupTXT returns the TXT records of name, each record's strings joined
+func (d *DoH) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	answers, err := d.query(ctx, name, dnsmessage.TypeTXT)
+	if err != nil {
+		return nil, err
+	}
+
+	var records []string
+	for _, answer := range answers {
+		if body, ok := answer.Body.(*dnsmessage.TXTResource); ok {
+			records = append(records, strings.Join(body.TXT, ""))
+		}
+	}
+
+	if len(records) == 0 {
+		return nil, &net.DNSError{Err: "no TXT records found", Name: name, IsNotFound: true}
+	}
+	return records, nil
+}
+
+// query asks each server in turn until one answers
+func (d *DoH) query(ctx context.Context, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	var lastErr error
+	for _, server := range d.servers {
+		answers, err := d.exchange(ctx, server, name, qtype)
+		if err == nil {
+			return answers, nil
+		}
+		if dnsErr, ok := err.(*net.DNSError); ok && dnsErr.IsNotFound {
+			// An authoritative NXDOMAIN won't differ between servers
+			return nil, err
+		}
+		lastErr = err
+	}
+	return nil, lastErr
+}
+
+func (d *DoH) exchange(ctx context.Context, server, name string, qtype dnsmessage.Type) ([]dnsmessage.Resource, error) {
+	fqdn := name
+	if !strings.HasSuffix(fqdn, ".") {
+		fqdn += "."
+	}
+	qname, err := dnsmessage.NewName(fqdn)
+	if err != nil {
+		return nil, fmt.Errorf("invalid name %q: %w", name, err)
+	}
+
+	// RFC 8484 recommends ID 0 so responses are cacheable
+	query := dnsmessage.Message{
+		Header:    dnsmessage.Header{RecursionDesired: true},
+		Questions: []dnsmessage.Question{{Name: qname, Type: qtype, Class: dnsmessage.ClassINET}},
+	}
+	packed, err := query.Pack()
+	if err != nil {
+		return nil, fmt.Errorf("failed to encode DNS query: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, server, bytes.NewReader(packed))
+	if err != nil {
+		return nil, fmt.Errorf("failed to create DoH request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := d.httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DoH query to %s failed: %w", server, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DoH server %s returned status %d", server, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxResponseSize))
+	if err != nil {
+		return nil, fmt.Errorf("failed to read DoH response from %s: %w", server, err)
+	}
+
+	var msg dnsmessage.Message
+	if err := msg.Unpack(body); err != nil {
+		return nil, fmt.Errorf("invalid DoH response from %s: %w", server, err)
+	}
+
+	switch msg.RCode {
+	case dnsmessage.RCodeSuccess:
+		return msg.Answers, nil
+	case dnsmessage.RCodeNameError:
+		return nil, &net.DNSError{Err: "no such host", Name: name, Server: server, IsNotFound: true}
+	default:
+		return nil, &net.DNSError{Err: msg.RCode.String(), Name: name, Server: server, IsTemporary: true}
+	}
+}
+
+// WaitForTXT polls until name has a TXT record equal to value or ctx is done. It is
+// used to confirm a DNS-01 challenge record has propagated before asking the CA
+// to validate it.
+func WaitForTXT(ctx context.Context, r Resolver, name, value string, interval time.Duration) error {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		records, err := r.LookupTXT(ctx, name)
+		for _, record := range records {
+			if record == value {
+				return nil
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			if err != nil {
+				return fmt.Errorf("TXT record for %s did not propagate: %w", name, err)
+			}
+			return fmt.Errorf("TXT record for %s did not propagate: %w", name, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
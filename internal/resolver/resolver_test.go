@@ -0,0 +1,171 @@
+package resolver
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sort"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"golang.org/x/net/dns/dnsmessage"
+)
+
+// newDoHServer answers A, AAAA and TXT queries from records; unknown names get NXDOMAIN
+func newDoHServer(t *testing.T, records map[string][]dnsmessage.ResourceBody) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+
+		body, _ := io.ReadAll(r.Body)
+		var query dnsmessage.Message
+		if err := query.Unpack(body); err != nil || len(query.Questions) != 1 {
+			http.Error(w, "bad query", http.StatusBadRequest)
+			return
+		}
+		q := query.Questions[0]
+
+		resp := dnsmessage.Message{
+			Header:    dnsmessage.Header{ID: query.ID, Response: true, RecursionAvailable: true},
+			Questions: query.Questions,
+		}
+
+		bodies, exists := records[q.Name.String()]
+		if !exists {
+			resp.RCode = dnsmessage.RCodeNameError
+		}
+		for _, rb := range bodies {
+			if bodyType(rb) != q.Type {
+				continue
+			}
+			resp.Answers = append(resp.Answers, dnsmessage.Resource{
+				Header: dnsmessage.ResourceHeader{Name: q.Name, Type: q.Type, Class: dnsmessage.ClassINET, TTL: 60},
+				Body:   rb,
+			})
+		}
+
+		packed, err := resp.Pack()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(packed)
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func bodyType(rb dnsmessage.ResourceBody) dnsmessage.Type {
+	switch rb.(type) {
+	case *dnsmessage.AResource:
+		return dnsmessage.TypeA
+	case *dnsmessage.AAAAResource:
+		return dnsmessage.TypeAAAA
+	case *dnsmessage.TXTResource:
+		return dnsmessage.TypeTXT
+	}
+	return 0
+}
+
+func TestDoH_LookupHost(t *testing.T) {
+	server := newDoHServer(t, map[string][]dnsmessage.ResourceBody{
+		"example.com.": {
+			&dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}},
+			&dnsmessage.AAAAResource{AAAA: [16]byte{0x20, 0x01, 0x0d, 0xb8, 15: 1}},
+		},
+	})
+	r := NewDoH([]string{server.URL}, 5*time.Second)
+
+	addrs, err := r.LookupHost(context.Background(), "example.com")
+	if err != nil {
+		t.Fatalf("LookupHost failed: %v", err)
+	}
+	sort.Strings(addrs)
+	if len(addrs) != 2 || addrs[0] != "192.0.2.10" || addrs[1] != "2001:db8::1" {
+		t.Errorf("LookupHost = %v, want [192.0.2.10 2001:db8::1]", addrs)
+	}
+
+	_, err = r.LookupHost(context.Background(), "missing.example.com")
+	var dnsErr *net.DNSError
+	if !errors.As(err, &dnsErr) || !dnsErr.IsNotFound {
+		t.Errorf("expected not found error for missing domain, got %v", err)
+	}
+}
+
+func TestDoH_FallsBackToNextServer(t *testing.T) {
+	broken := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer broken.Close()
+
+	server := newDoHServer(t, map[string][]dnsmessage.ResourceBody{
+		"example.com.": {&dnsmessage.AResource{A: [4]byte{192, 0, 2, 10}}},
+	})
+	r := NewDoH([]string{broken.URL, server.URL}, 5*time.Second)
+
+	if _, err := r.LookupHost(context.Background(), "example.com"); err != nil {
+		t.Errorf("expected the second server to answer, got %v", err)
+	}
+}
+
+func TestDoH_LookupTXT(t *testing.T) {
+	server := newDoHServer(t, map[string][]dnsmessage.ResourceBody{
+		"_acme-challenge.example.com.": {&dnsmessage.TXTResource{TXT: []string{"part1", "part2"}}},
+	})
+	r := NewDoH([]string{server.URL}, 5*time.Second)
+
+	records, err := r.LookupTXT(context.Background(), "_acme-challenge.example.com")
+	if err != nil {
+		t.Fatalf("LookupTXT failed: %v", err)
+	}
+	if len(records) != 1 || records[0] != "part1part2" {
+		t.Errorf("LookupTXT = %v, want [part1part2]", records)
+	}
+}
+
+// flakyTXT returns the expected record only after a number of lookups
+type flakyTXT struct {
+	lookups atomic.Int32
+	after   int32
+}
+
+func (f *flakyTXT) LookupHost(ctx context.Context, host string) ([]string, error) {
+	return nil, errors.New("not implemented")
+}
+
+func (f *flakyTXT) LookupTXT(ctx context.Context, name string) ([]string, error) {
+	if f.lookups.Add(1) <= f.after {
+		return []string{"stale"}, nil
+	}
+	return []string{"token"}, nil
+}
+
+func TestWaitForTXT(t *testing.T) {
+	r := &flakyTXT{after: 2}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := WaitForTXT(ctx, r, "_acme-challenge.example.com", "token", 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitForTXT failed: %v", err)
+	}
+	if got := r.lookups.Load(); got != 3 {
+		t.Errorf("lookups = %d, want 3", got)
+	}
+
+	ctx, cancel = context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	if err := WaitForTXT(ctx, r, "_acme-challenge.example.com", "other", 10*time.Millisecond); err == nil {
+		t.Error("expected WaitForTXT to time out")
+	}
+}
@@ -0,0 +1,254 @@
+package sds
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"log"
+	"net"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	secretservice "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/types"
+	"github.com/envoyproxy/go-control-plane/pkg/cache/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/server/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+)
+
+// Secret is a certificate chain and its private key, served under Name
+type Secret struct {
+	Name             string
+	CertificateChain []byte // PEM leaf followed by intermediates
+	PrivateKey       []byte // PEM private key
+}
+
+// Source returns every secret to serve
+type Source func() []Secret
+
+// Server serves secrets to Envoy and other xDS clients over the secret
+// discovery service. Secrets are read from the source every refresh interval
+// and only those that changed are pushed to subscribed clients.
+type Server struct {
+	source   Source
+	address  string
+	interval time.Duration
+	logger   *log.Logger
+
+	cache    *cache.LinearCache
+	grpc     *grpc.Server
+	listener net.Listener
+	served   map[string][sha256.Size]byte // fingerprints of the secrets in the cache
+
+	cancel context.CancelFunc
+	done   chan struct{}
+}
+
+func NewServer(cfg config.SDS, source Source, logger *log.Logger) (*Server, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[SDS] ", log.LstdFlags)
+	}
+
+	interval, err := time.ParseDuration(cfg.RefreshInterval)
+	if err != nil || interval <= 0 {
+		interval = 10 * time.Second
+	}
+
+	var opts []grpc.ServerOption
+	if cfg.TLSCertFile != "" {
+		creds, err := serverCredentials(cfg)
+		if err != nil {
+			return nil, err
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	return &Server{
+		source:   source,
+		address:  cfg.ListenAddress,
+		interval: interval,
+		logger:   logger,
+		cache:    cache.NewLinearCache(resource.SecretType),
+		grpc:     grpc.NewServer(opts...),
+		served:   make(map[string][sha256.Size]byte),
+	}, nil
+}
+
+// serverCredentials loads the server certificate and, when configured, the CA
+// client certificates must be issued by
+func serverCredentials(cfg config.SDS) (credentials.TransportCredentials, error) {
+	cert, err := tls.LoadX509KeyPair(cfg.TLSCertFile, cfg.TLSKeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to load SDS server certificate: %w", err)
+	}
+	tlsConfig := &tls.Config{
+		Certificates: []tls.Certificate{cert},
+		MinVersion:   tls.VersionTLS12,
+	}
+
+	if cfg.ClientCAFile != "" {
+		data, err := os.ReadFile(cfg.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read SDS client CA: %w", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(data) {
+			return nil, fmt.Errorf("no certificates found in SDS client CA %s", cfg.ClientCAFile)
+		}
+		tlsConfig.ClientCAs = pool
+		tlsConfig.ClientAuth = tls.RequireAndVerifyClientCert
+	}
+	return credentials.NewTLS(tlsConfig), nil
+}
+
+// Start listens on the configured address, loads the secrets and serves them
+// in the background until Shutdown
+func (s *Server) Start() error {
+	network, address := "tcp", s.address
+	if path, ok := strings.CutPrefix(s.address, "unix:"); ok {
+		network, address = "unix", path
+		// A socket left behind by a previous run would fail the listen
+		if err := os.Remove(path); err != nil && !os.IsNotExist(err) {
+			return fmt.Errorf("failed to remove stale SDS socket: %w", err)
+		}
+	}
+	listener, err := net.Listen(network, address)
+	if err != nil {
+		return fmt.Errorf("failed to listen for SDS on %s: %w", s.address, err)
+	}
+	if network == "unix" {
+		// Only the owner and group, such as the proxy's, may fetch private keys
+		if err := os.Chmod(address, 0660); err != nil {
+			listener.Close()
+			return fmt.Errorf("failed to restrict SDS socket: %w", err)
+		}
+	}
+	s.listener = listener
+
+	ctx, cancel := context.WithCancel(context.Background())
+	s.cancel = cancel
+	s.done = make(chan struct{})
+
+	s.refresh()
+	secretservice.RegisterSecretDiscoveryServiceServer(s.grpc, server.NewServer(ctx, s.cache, nil))
+	s.logger.Printf("Serving %d certificates over SDS on %s", len(s.served), listener.Addr())
+
+	go s.refreshLoop(ctx)
+	go func() {
+		if err := s.grpc.Serve(listener); err != nil {
+			s.logger.Printf("SDS server failed: %v", err)
+		}
+	}()
+	return nil
+}
+
+// Addr returns the address the server listens on, once started
+func (s *Server) Addr() net.Addr {
+	if s.listener == nil {
+		return nil
+	}
+	return s.listener.Addr()
+}
+
+// Shutdown stops refreshing and waits for open streams to end until ctx is
+// done, then closes them
+func (s *Server) Shutdown(ctx context.Context) error {
+	if s.cancel == nil {
+		return nil
+	}
+	s.cancel()
+	<-s.done
+
+	stopped := make(chan struct{})
+	go func() {
+		s.grpc.GracefulStop()
+		close(stopped)
+	}()
+	select {
+	case <-stopped:
+		return nil
+	case <-ctx.Done():
+		// xDS streams stay open for as long as the proxy runs
+		s.grpc.Stop()
+		return nil
+	}
+}
+
+func (s *Server) refreshLoop(ctx context.Context) {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.refresh()
+		}
+	}
+}
+
+// refresh pushes the secrets that changed or disappeared since the last refresh
+func (s *Server) refresh() {
+	served := make(map[string][sha256.Size]byte)
+	updated := make(map[string]types.Resource)
+	for _, secret := range s.source() {
+		sum := fingerprint(secret)
+		served[secret.Name] = sum
+		if previous, ok := s.served[secret.Name]; !ok || previous != sum {
+			updated[secret.Name] = tlsSecret(secret)
+		}
+	}
+	var removed []string
+	for name := range s.served {
+		if _, ok := served[name]; !ok {
+			removed = append(removed, name)
+		}
+	}
+	if len(updated) == 0 && len(removed) == 0 {
+		return
+	}
+
+	if err := s.cache.UpdateResources(updated, removed); err != nil {
+		s.logger.Printf("Failed to update SDS secrets: %v", err)
+		return
+	}
+	s.served = served
+	s.logger.Printf("Updated %d and removed %d SDS secrets", len(updated), len(removed))
+}
+
+func fingerprint(secret Secret) [sha256.Size]byte {
+	h := sha256.New()
+	h.Write(secret.CertificateChain)
+	h.Write([]byte{0})
+	h.Write(secret.PrivateKey)
+	var sum [sha256.Size]byte
+	copy(sum[:], h.Sum(nil))
+	return sum
+}
+
+// tlsSecret is secret as an Envoy TLS certificate with inline contents
+func tlsSecret(secret Secret) *tlsv3.Secret {
+	return &tlsv3.Secret{
+		Name: secret.Name,
+		Type: &tlsv3.Secret_TlsCertificate{
+			TlsCertificate: &tlsv3.TlsCertificate{
+				CertificateChain: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineBytes{InlineBytes: secret.CertificateChain},
+				},
+				PrivateKey: &corev3.DataSource{
+					Specifier: &corev3.DataSource_InlineBytes{InlineBytes: secret.PrivateKey},
+				},
+			},
+		},
+	}
+}
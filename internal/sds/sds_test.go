@@ -0,0 +1,140 @@
+package sds
+
+import (
+	"context"
+	"log"
+	"os"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+	tlsv3 "github.com/envoyproxy/go-control-plane/envoy/extensions/transport_sockets/tls/v3"
+	discoveryv3 "github.com/envoyproxy/go-control-plane/envoy/service/discovery/v3"
+	secretservice "github.com/envoyproxy/go-control-plane/envoy/service/secret/v3"
+	"github.com/envoyproxy/go-control-plane/pkg/resource/v3"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials/insecure"
+)
+
+func TestServerPushesChangedSecrets(t *testing.T) {
+	var mu sync.Mutex
+	secrets := []Secret{
+		{Name: "example.com", CertificateChain: []byte("chain 1"), PrivateKey: []byte("key 1")},
+		{Name: "api.example.com", CertificateChain: []byte("api chain"), PrivateKey: []byte("api key")},
+	}
+	source := func() []Secret {
+		mu.Lock()
+		defer mu.Unlock()
+		return append([]Secret(nil), secrets...)
+	}
+
+	logger := log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+	// Refreshes are triggered by the test
+	cfg := config.SDS{Enabled: true, ListenAddress: "127.0.0.1:0", RefreshInterval: "1h"}
+	server, err := NewServer(cfg, source, logger)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+	if err := server.Start(); err != nil {
+		t.Fatalf("Start() error = %v", err)
+	}
+	defer func() {
+		ctx, cancel := context.WithTimeout(context.Background(), time.Second)
+		defer cancel()
+		server.Shutdown(ctx)
+	}()
+
+	conn, err := grpc.NewClient(server.Addr().String(), grpc.WithTransportCredentials(insecure.NewCredentials()))
+	if err != nil {
+		t.Fatalf("failed to dial SDS server: %v", err)
+	}
+	defer conn.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Second)
+	defer cancel()
+	stream, err := secretservice.NewSecretDiscoveryServiceClient(conn).StreamSecrets(ctx)
+	if err != nil {
+		t.Fatalf("StreamSecrets() error = %v", err)
+	}
+
+	request := &discoveryv3.DiscoveryRequest{TypeUrl: resource.SecretType, ResourceNames: []string{"example.com"}}
+	receive := func() []*tlsv3.Secret {
+		t.Helper()
+		if err := stream.Send(request); err != nil {
+			t.Fatalf("Send() error = %v", err)
+		}
+		response, err := stream.Recv()
+		if err != nil {
+			t.Fatalf("Recv() error = %v", err)
+		}
+		var got []*tlsv3.Secret
+		for _, any := range response.Resources {
+			var secret tlsv3.Secret
+			if err := any.UnmarshalTo(&secret); err != nil {
+				t.Fatalf("failed to decode secret: %v", err)
+			}
+			got = append(got, &secret)
+		}
+		// The next request acknowledges this response
+		request.VersionInfo, request.ResponseNonce = response.VersionInfo, response.Nonce
+		return got
+	}
+
+	got := receive()
+	if len(got) != 1 || got[0].Name != "example.com" {
+		t.Fatalf("got secrets %v, want only example.com", got)
+	}
+	chain := got[0].GetTlsCertificate().GetCertificateChain().GetInlineBytes()
+	key := got[0].GetTlsCertificate().GetPrivateKey().GetInlineBytes()
+	if string(chain) != "chain 1" || string(key) != "key 1" {
+		t.Errorf("got chain %q and key %q, want chain 1 and key 1", chain, key)
+	}
+
+	// A renewal is pushed on the next refresh
+	mu.Lock()
+	secrets[0] = Secret{Name: "example.com", CertificateChain: []byte("chain 2"), PrivateKey: []byte("key 2")}
+	mu.Unlock()
+	server.refresh()
+	got = receive()
+	if len(got) != 1 || string(got[0].GetTlsCertificate().GetCertificateChain().GetInlineBytes()) != "chain 2" {
+		t.Errorf("got secrets %v after renewal, want chain 2", got)
+	}
+
+	// A certificate no longer managed is withdrawn from new subscriptions
+	mu.Lock()
+	secrets = secrets[1:]
+	mu.Unlock()
+	server.refresh()
+	if _, ok := server.cache.GetResources()["example.com"]; ok {
+		t.Error("removed secret is still served")
+	}
+}
+
+func TestRefreshSkipsUnchangedSecrets(t *testing.T) {
+	secrets := []Secret{{Name: "example.com", CertificateChain: []byte("chain"), PrivateKey: []byte("key")}}
+	server, err := NewServer(config.SDS{RefreshInterval: "10s"}, func() []Secret { return secrets }, nil)
+	if err != nil {
+		t.Fatalf("NewServer() error = %v", err)
+	}
+
+	server.refresh()
+	pushed := server.cache.GetResources()["example.com"]
+	server.refresh()
+	if got := server.cache.GetResources()["example.com"]; got != pushed {
+		t.Error("unchanged secret was pushed again")
+	}
+
+	secrets[0].PrivateKey = []byte("new key")
+	server.refresh()
+	if got := server.cache.GetResources()["example.com"]; got == pushed {
+		t.Error("changed secret wasn't pushed")
+	}
+}
+
+func TestNewServerRejectsInvalidTLSFiles(t *testing.T) {
+	cfg := config.SDS{TLSCertFile: "missing.crt", TLSKeyFile: "missing.key"}
+	if _, err := NewServer(cfg, func() []Secret { return nil }, nil); err == nil {
+		t.Error("NewServer() with missing certificate files succeeded, want error")
+	}
+}
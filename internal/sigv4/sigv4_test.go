@@ -0,0 +1,62 @@
+package sigv4
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func testSigner(service string) *Signer {
+	return &Signer{
+		Service:   service,
+		Region:    "us-east-1",
+		AccessKey: "AKIDEXAMPLE",
+		SecretKey: "wJalrXUtnFEMI/K7MDENG+bPxRfiCYEXAMPLEKEY",
+		Now:       func() time.Time { return time.Date(2015, 8, 30, 12, 36, 0, 0, time.UTC) },
+	}
+}
+
+// TestSigner_Sign checks a signature against the get-vanilla case of the AWS
+// Signature Version 4 test suite
+func TestSigner_Sign(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "https://example.amazonaws.com/", nil)
+	testSigner("service").Sign(req, nil)
+
+	want := "AWS4-HMAC-SHA256 Credential=AKIDEXAMPLE/20150830/us-east-1/service/aws4_request, " +
+		"SignedHeaders=host;x-amz-date, Signature=5fa00fa31553b73ebf1942676e86291e8372ff2a2260956d9b8aae1d763fbf31"
+	if auth := req.Header.Get("Authorization"); auth != want {
+		t.Errorf("Authorization = %s, want %s", auth, want)
+	}
+	if req.Header.Get("X-Amz-Date") != "20150830T123600Z" {
+		t.Errorf("X-Amz-Date = %s", req.Header.Get("X-Amz-Date"))
+	}
+}
+
+func TestSigner_SignedHeaders(t *testing.T) {
+	signer := testSigner("kms")
+	signer.SessionToken = "token"
+
+	req := httptest.NewRequest(http.MethodPost, "https://kms.us-east-1.amazonaws.com/", strings.NewReader("{}"))
+	req.Header.Set("Content-Type", "application/x-amz-json-1.1")
+	req.Header.Set("X-Amz-Target", "TrentService.Encrypt")
+	req.Header.Set("User-Agent", "test")
+	signer.Sign(req, []byte("{}"))
+
+	auth := req.Header.Get("Authorization")
+	if !strings.Contains(auth, "/us-east-1/kms/aws4_request, SignedHeaders=content-type;host;x-amz-date;x-amz-security-token;x-amz-target, ") {
+		t.Errorf("unexpected Authorization header: %s", auth)
+	}
+	if req.Header.Get("X-Amz-Security-Token") != "token" {
+		t.Errorf("X-Amz-Security-Token = %s", req.Header.Get("X-Amz-Security-Token"))
+	}
+}
+
+func TestFromEnvironment(t *testing.T) {
+	t.Setenv("AWS_ACCESS_KEY_ID", "")
+	t.Setenv("AWS_SECRET_ACCESS_KEY", "")
+	if _, err := FromEnvironment("route53", "us-east-1"); err == nil {
+		t.Error("expected an error without AWS credentials")
+	}
+}
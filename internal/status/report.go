@@ -0,0 +1,317 @@
+// Package status defines the machine-readable certificate status shared by the
+// health and once commands and the management API. Its JSON form is versioned
+// and described by the JSON Schema in schema.json, so tooling at the edge can
+// rely on it.
+package status
+
+import (
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+)
+
+// SchemaVersion is bumped whenever a field of the report changes meaning or is
+// removed; adding fields keeps the version
+const SchemaVersion = 1
+
+// Exit codes of the health and once commands, also reported in the exit_code field
+const (
+	ExitHealthy      = 0
+	ExitNeedsRenewal = 1 // at least one service needs renewal or has expired
+	ExitRunFailed    = 2 // once hit errors processing or renewing domains, or any failure in strict mode
+	ExitRunFailedAll = 3 // once failed and issued no certificate at all
+)
+
+// Outcomes of a once run
+const (
+	OutcomeSucceeded = "succeeded"
+	OutcomePartial   = "partially_failed"
+	OutcomeFailed    = "failed"
+)
+
+// Report is the machine-readable certificate status
+type Report struct {
+	SchemaVersion int             `json:"schema_version" yaml:"schema_version"`
+	Mode          string          `json:"mode" yaml:"mode"` // health, once or status (served by the API)
+	GeneratedAt   time.Time       `json:"generated_at" yaml:"generated_at"`
+	ExitCode      int             `json:"exit_code" yaml:"exit_code"`
+	Summary       ReportSummary   `json:"summary" yaml:"summary"`
+	Services      []ServiceReport `json:"services" yaml:"services"`
+	Errors        []string        `json:"errors,omitempty" yaml:"errors,omitempty"`
+	Strict        bool            `json:"strict,omitempty" yaml:"strict,omitempty"`
+	Failures      []FailureReport `json:"failures,omitempty" yaml:"failures,omitempty"`
+	Run           *RunReport      `json:"run,omitempty" yaml:"run,omitempty"`                 // present in once mode
+	ConfigHash    string          `json:"config_hash,omitempty" yaml:"config_hash,omitempty"` // present in status mode
+}
+
+// RunReport tallies the certificates a once run issued and the domains it
+// failed for, so wrappers can tell a partly failed run from one that failed
+type RunReport struct {
+	Outcome string   `json:"outcome" yaml:"outcome"` // succeeded, partially_failed or failed
+	Issued  []string `json:"issued" yaml:"issued"`   // domains issued or renewed certificates
+	Failed  []string `json:"failed" yaml:"failed"`   // domains with failures
+}
+
+// FailureReport is an order or deployment that failed during the run
+type FailureReport struct {
+	Domain    string `json:"domain" yaml:"domain"`
+	Operation string `json:"operation" yaml:"operation"` // obtain, renew, reissue, companion, deploy, pre_switch or post_switch
+	Error     string `json:"error" yaml:"error"`
+	Code      string `json:"code,omitempty" yaml:"code,omitempty"` // class of the error, see certmanager.ErrorCode
+}
+
+// ReportSummary counts services by status
+type ReportSummary struct {
+	Services     int `json:"services" yaml:"services"`
+	Certificates int `json:"certificates" yaml:"certificates"`
+	Valid        int `json:"valid" yaml:"valid"`
+	NeedsRenewal int `json:"needs_renewal" yaml:"needs_renewal"`
+	Expired      int `json:"expired" yaml:"expired"`
+}
+
+// ServiceReport is one service with the certificates of its primary domain and aliases
+type ServiceReport struct {
+	Service string              `json:"service" yaml:"service"`
+	Domain  string              `json:"domain" yaml:"domain"`
+	Group   string              `json:"group,omitempty" yaml:"group,omitempty"`
+	Status  string              `json:"status" yaml:"status"` // valid, needs_renewal or expired
+	Primary *CertificateReport  `json:"primary,omitempty" yaml:"primary,omitempty"`
+	Aliases []CertificateReport `json:"aliases,omitempty" yaml:"aliases,omitempty"`
+}
+
+// CertificateReport is the state of one certificate
+type CertificateReport struct {
+	Domain          string      `json:"domain" yaml:"domain"`
+	Status          string      `json:"status" yaml:"status"`
+	IssuedAt        time.Time   `json:"issued_at" yaml:"issued_at"`
+	ExpiresAt       time.Time   `json:"expires_at" yaml:"expires_at"`
+	DaysUntilExpiry int         `json:"days_until_expiry" yaml:"days_until_expiry"`
+	NeedsRenewal    bool        `json:"needs_renewal" yaml:"needs_renewal"`
+	IsExpired       bool        `json:"is_expired" yaml:"is_expired"`
+	RenewAt         time.Time   `json:"renew_at" yaml:"renew_at"`
+	ChainExpiresAt  *time.Time  `json:"chain_expires_at,omitempty" yaml:"chain_expires_at,omitempty"`
+	ChainRoot       string      `json:"chain_root,omitempty" yaml:"chain_root,omitempty"`
+	Issuer          string      `json:"issuer,omitempty" yaml:"issuer,omitempty"`
+	Serial          string      `json:"serial,omitempty" yaml:"serial,omitempty"`
+	SANs            []string    `json:"sans,omitempty" yaml:"sans,omitempty"`
+	CA              string      `json:"ca,omitempty" yaml:"ca,omitempty"` // directory URL of the CA it was ordered from, or internal-ca
+	OrderURL        string      `json:"order_url,omitempty" yaml:"order_url,omitempty"`
+	Hold            *HoldReport `json:"hold,omitempty" yaml:"hold,omitempty"` // automation of the domain is paused
+
+	DuplicateLimit *DuplicateLimitReport `json:"duplicate_limit,omitempty" yaml:"duplicate_limit,omitempty"` // issuance is refused
+	Staple         *StapleReport         `json:"staple,omitempty" yaml:"staple,omitempty"`                   // present with OCSP stapling enabled
+}
+
+// StapleReport describes the OCSP response cached for stapling
+type StapleReport struct {
+	Status     string     `json:"status" yaml:"status"` // good, revoked, unknown, missing or unsupported
+	ThisUpdate *time.Time `json:"this_update,omitempty" yaml:"this_update,omitempty"`
+	NextUpdate *time.Time `json:"next_update,omitempty" yaml:"next_update,omitempty"`
+	Fresh      bool       `json:"fresh" yaml:"fresh"` // covers the certificate and hasn't passed its next update
+}
+
+// DuplicateLimitReport describes a certificate whose SAN set was issued as
+// often as the CA allows within a week
+type DuplicateLimitReport struct {
+	Issued     int       `json:"issued" yaml:"issued"`
+	Limit      int       `json:"limit" yaml:"limit"`
+	RetryAfter time.Time `json:"retry_after" yaml:"retry_after"`
+}
+
+// HoldReport describes why and until when automation of a domain is paused
+type HoldReport struct {
+	Reason string     `json:"reason,omitempty" yaml:"reason,omitempty"`
+	Since  time.Time  `json:"since" yaml:"since"`
+	Until  *time.Time `json:"until,omitempty" yaml:"until,omitempty"` // absent until released
+}
+
+// NewReport builds the report for the given service health. Services are
+// sorted by domain so the output is stable between runs.
+func NewReport(mode string, services []certmanager.ServiceHealth, errs []error) *Report {
+	report := &Report{
+		SchemaVersion: SchemaVersion,
+		Mode:          mode,
+		GeneratedAt:   time.Now().UTC(),
+		Services:      make([]ServiceReport, 0, len(services)),
+	}
+
+	for _, service := range services {
+		entry := ServiceReport{
+			Service: service.Service,
+			Domain:  service.Domain,
+			Group:   service.Group,
+			Status:  service.Status,
+		}
+		if service.Primary != nil {
+			primary := NewCertificateReport(*service.Primary)
+			entry.Primary = &primary
+			report.Summary.Certificates++
+		}
+		for _, alias := range service.Aliases {
+			entry.Aliases = append(entry.Aliases, NewCertificateReport(alias))
+			report.Summary.Certificates++
+		}
+
+		switch service.Status {
+		case "valid":
+			report.Summary.Valid++
+		case "needs_renewal":
+			report.Summary.NeedsRenewal++
+		case "expired":
+			report.Summary.Expired++
+		}
+		report.Services = append(report.Services, entry)
+	}
+	report.Summary.Services = len(report.Services)
+
+	sort.Slice(report.Services, func(i, j int) bool {
+		return report.Services[i].Domain < report.Services[j].Domain
+	})
+
+	for _, err := range errs {
+		report.Errors = append(report.Errors, err.Error())
+	}
+
+	switch {
+	case len(report.Errors) > 0:
+		report.ExitCode = ExitRunFailed
+	case report.Summary.NeedsRenewal > 0 || report.Summary.Expired > 0:
+		report.ExitCode = ExitNeedsRenewal
+	default:
+		report.ExitCode = ExitHealthy
+	}
+
+	return report
+}
+
+// AddFailures lists the failures of the run. In strict mode any of them fails
+// the run, also those that don't return an error, like failed deployments.
+func (r *Report) AddFailures(failures []certmanager.Failure, strict bool) {
+	r.Strict = strict
+	for _, failure := range failures {
+		r.Failures = append(r.Failures, FailureReport{
+			Domain:    failure.Domain,
+			Operation: failure.Operation,
+			Error:     failure.Error,
+			Code:      failure.Code,
+		})
+	}
+	if strict && len(r.Failures) > 0 {
+		r.ExitCode = ExitRunFailed
+	}
+}
+
+// AddRun tallies a once run from the domains it issued certificates for and
+// the failures added before. A failed run that issued nothing failed as a
+// whole rather than partly.
+func (r *Report) AddRun(issued []string) {
+	run := &RunReport{Outcome: OutcomeSucceeded, Issued: uniqueSorted(issued)}
+
+	var failed []string
+	for _, failure := range r.Failures {
+		failed = append(failed, failure.Domain)
+	}
+	run.Failed = uniqueSorted(failed)
+
+	if r.ExitCode == ExitRunFailed {
+		run.Outcome = OutcomePartial
+		if len(run.Issued) == 0 {
+			run.Outcome = OutcomeFailed
+			r.ExitCode = ExitRunFailedAll
+		}
+	}
+	r.Run = run
+}
+
+// uniqueSorted returns the distinct values sorted, never nil so JSON shows an
+// empty list
+func uniqueSorted(values []string) []string {
+	unique := []string{}
+	seen := make(map[string]bool)
+	for _, v := range values {
+		if !seen[v] {
+			seen[v] = true
+			unique = append(unique, v)
+		}
+	}
+	sort.Strings(unique)
+	return unique
+}
+
+// Certificate returns the report of the certificate serving host: the one
+// issued for it, or else a wildcard covering it
+func (r *Report) Certificate(host string) (CertificateReport, bool) {
+	host = strings.ToLower(strings.TrimSuffix(host, "."))
+	wildcard := ""
+	if i := strings.IndexByte(host, '.'); i > 0 {
+		wildcard = "*" + host[i:]
+	}
+
+	var covering *CertificateReport
+	for _, service := range r.Services {
+		certs := service.Aliases
+		if service.Primary != nil {
+			certs = append([]CertificateReport{*service.Primary}, certs...)
+		}
+		for i := range certs {
+			switch certs[i].Domain {
+			case host:
+				return certs[i], true
+			case wildcard:
+				covering = &certs[i]
+			}
+		}
+	}
+	if covering != nil {
+		return *covering, true
+	}
+	return CertificateReport{}, false
+}
+
+// NewCertificateReport converts the health of one certificate
+func NewCertificateReport(status certmanager.CertificateHealth) CertificateReport {
+	cert := CertificateReport{
+		Domain:          status.Domain,
+		Status:          status.Status,
+		IssuedAt:        status.IssuedAt.UTC(),
+		ExpiresAt:       status.ExpiresAt.UTC(),
+		DaysUntilExpiry: status.DaysUntilExpiry,
+		NeedsRenewal:    status.NeedsRenewal,
+		IsExpired:       status.IsExpired,
+		RenewAt:         status.RenewAt.UTC(),
+		ChainRoot:       status.ChainRoot,
+		Issuer:          status.Issuer,
+		Serial:          status.Serial,
+		SANs:            status.SANs,
+		CA:              status.CA,
+		OrderURL:        status.OrderURL,
+	}
+	if !status.ChainExpiresAt.IsZero() {
+		chainExpiresAt := status.ChainExpiresAt.UTC()
+		cert.ChainExpiresAt = &chainExpiresAt
+	}
+	if status.Hold != nil {
+		cert.Hold = &HoldReport{Reason: status.Hold.Reason, Since: status.Hold.Since.UTC()}
+		if !status.Hold.Until.IsZero() {
+			until := status.Hold.Until.UTC()
+			cert.Hold.Until = &until
+		}
+	}
+	if limit := status.DuplicateLimit; limit != nil {
+		cert.DuplicateLimit = &DuplicateLimitReport{Issued: limit.Issued, Limit: limit.Limit, RetryAfter: limit.RetryAfter.UTC()}
+	}
+	if staple := status.Staple; staple != nil {
+		cert.Staple = &StapleReport{Status: staple.Status, Fresh: staple.Fresh}
+		if !staple.ThisUpdate.IsZero() {
+			thisUpdate := staple.ThisUpdate.UTC()
+			cert.Staple.ThisUpdate = &thisUpdate
+		}
+		if !staple.NextUpdate.IsZero() {
+			nextUpdate := staple.NextUpdate.UTC()
+			cert.Staple.NextUpdate = &nextUpdate
+		}
+	}
+	return cert
+}
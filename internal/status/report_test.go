@@ -0,0 +1,218 @@
+package status
+
+import (
+	"encoding/json"
+	"errors"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/certmanager"
+	"github.com/O-tero/traefik-cert-manager/internal/switchhook"
+)
+
+func testServices() []certmanager.ServiceHealth {
+	expires := time.Date(2030, 1, 1, 0, 0, 0, 0, time.UTC)
+	return []certmanager.ServiceHealth{
+		{
+			Service: "web",
+			Domain:  "www.example.com",
+			Status:  "needs_renewal",
+			Primary: &certmanager.CertificateHealth{Domain: "www.example.com", Status: "valid", ExpiresAt: expires},
+			Aliases: []certmanager.CertificateHealth{
+				{Domain: "example.com", Status: "needs_renewal", NeedsRenewal: true, ExpiresAt: expires},
+			},
+		},
+		{
+			Service: "api",
+			Domain:  "api.example.com",
+			Status:  "valid",
+			Primary: &certmanager.CertificateHealth{Domain: "api.example.com", Status: "valid", ExpiresAt: expires},
+		},
+		{
+			Service: "apps",
+			Domain:  "*.apps.example.com",
+			Status:  "expired",
+			Primary: &certmanager.CertificateHealth{Domain: "*.apps.example.com", Status: "expired", IsExpired: true, ExpiresAt: expires},
+		},
+	}
+}
+
+func TestNewReport(t *testing.T) {
+	report := NewReport("health", testServices(), nil)
+
+	if report.SchemaVersion != SchemaVersion || report.Mode != "health" {
+		t.Errorf("report header = %d %q", report.SchemaVersion, report.Mode)
+	}
+	want := ReportSummary{Services: 3, Certificates: 4, Valid: 1, NeedsRenewal: 1, Expired: 1}
+	if report.Summary != want {
+		t.Errorf("summary = %+v, want %+v", report.Summary, want)
+	}
+	if report.Services[0].Domain != "*.apps.example.com" {
+		t.Errorf("services are not sorted by domain: %s first", report.Services[0].Domain)
+	}
+	if report.ExitCode != ExitNeedsRenewal {
+		t.Errorf("exit code = %d, want %d", report.ExitCode, ExitNeedsRenewal)
+	}
+
+	failed := NewReport("once", testServices(), []error{errors.New("CA unavailable")})
+	if failed.ExitCode != ExitRunFailed || len(failed.Errors) != 1 {
+		t.Errorf("failed run = exit %d with errors %v", failed.ExitCode, failed.Errors)
+	}
+}
+
+func TestReport_AddFailures(t *testing.T) {
+	failures := []certmanager.Failure{
+		{Domain: "www.example.com", Operation: "deploy", Error: "connection refused"},
+	}
+
+	lenient := NewReport("once", testServices(), nil)
+	lenient.AddFailures(failures, false)
+	if lenient.ExitCode != ExitNeedsRenewal || len(lenient.Failures) != 1 {
+		t.Errorf("failures outside strict mode = exit %d with failures %v", lenient.ExitCode, lenient.Failures)
+	}
+
+	strict := NewReport("once", testServices(), nil)
+	strict.AddFailures(failures, true)
+	if strict.ExitCode != ExitRunFailed {
+		t.Errorf("failures in strict mode = exit %d, want %d", strict.ExitCode, ExitRunFailed)
+	}
+	want := FailureReport{Domain: "www.example.com", Operation: "deploy", Error: "connection refused"}
+	if len(strict.Failures) != 1 || strict.Failures[0] != want {
+		t.Errorf("failures = %v, want %v", strict.Failures, want)
+	}
+
+	clean := NewReport("once", testServices(), nil)
+	clean.AddFailures(nil, true)
+	if clean.ExitCode != ExitNeedsRenewal {
+		t.Errorf("strict run without failures = exit %d, want %d", clean.ExitCode, ExitNeedsRenewal)
+	}
+}
+
+func TestReport_AddRun(t *testing.T) {
+	failures := []certmanager.Failure{
+		{Domain: "www.example.com", Operation: "renew", Error: "rate limited"},
+		{Domain: "www.example.com", Operation: "deploy", Error: "connection refused"},
+	}
+
+	tests := []struct {
+		name     string
+		issued   []string
+		failures []certmanager.Failure
+		outcome  string
+		exitCode int
+		counts   [2]int // issued and failed domains
+	}{
+		{"succeeded", []string{"api.example.com"}, nil, OutcomeSucceeded, ExitNeedsRenewal, [2]int{1, 0}},
+		{"partially failed", []string{"api.example.com", "api.example.com"}, failures, OutcomePartial, ExitRunFailed, [2]int{1, 1}},
+		{"failed", nil, failures, OutcomeFailed, ExitRunFailedAll, [2]int{0, 1}},
+	}
+	for _, tt := range tests {
+		report := NewReport("once", testServices(), nil)
+		report.AddFailures(tt.failures, true)
+		report.AddRun(tt.issued)
+
+		if report.Run.Outcome != tt.outcome || report.ExitCode != tt.exitCode {
+			t.Errorf("%s: outcome %q with exit %d, want %q with exit %d", tt.name, report.Run.Outcome, report.ExitCode, tt.outcome, tt.exitCode)
+		}
+		if counts := [2]int{len(report.Run.Issued), len(report.Run.Failed)}; counts != tt.counts {
+			t.Errorf("%s: run = %+v, want %v issued and failed", tt.name, report.Run, tt.counts)
+		}
+	}
+}
+
+func TestReport_Certificate(t *testing.T) {
+	report := NewReport("status", testServices(), nil)
+
+	tests := []struct {
+		host   string
+		domain string
+		found  bool
+	}{
+		{"www.example.com", "www.example.com", true},
+		{"Example.com.", "example.com", true},
+		{"shop.apps.example.com", "*.apps.example.com", true},
+		{"a.shop.apps.example.com", "", false},
+		{"unknown.example.org", "", false},
+	}
+	for _, tt := range tests {
+		cert, found := report.Certificate(tt.host)
+		if found != tt.found || cert.Domain != tt.domain {
+			t.Errorf("Certificate(%q) = %q, %v; want %q, %v", tt.host, cert.Domain, found, tt.domain, tt.found)
+		}
+	}
+}
+
+// TestSchema_DescribesReport keeps schema.json in step with the report: every
+// field of the report must be a property in the schema, and vice versa
+func TestSchema_DescribesReport(t *testing.T) {
+	var schema map[string]interface{}
+	if err := json.Unmarshal(Schema, &schema); err != nil {
+		t.Fatalf("schema.json does not parse: %v", err)
+	}
+
+	if version := schema["properties"].(map[string]interface{})["schema_version"].(map[string]interface{})["const"]; version != float64(SchemaVersion) {
+		t.Errorf("schema describes version %v, reports have %d", version, SchemaVersion)
+	}
+
+	defs := schema["$defs"].(map[string]interface{})
+	var compare func(path string, typ reflect.Type, node map[string]interface{})
+	compare = func(path string, typ reflect.Type, node map[string]interface{}) {
+		if ref, ok := node["$ref"].(string); ok {
+			node = defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+		}
+		for typ.Kind() == reflect.Ptr || typ.Kind() == reflect.Slice {
+			typ = typ.Elem()
+			if items, ok := node["items"].(map[string]interface{}); ok {
+				node = items
+				if ref, ok := node["$ref"].(string); ok {
+					node = defs[strings.TrimPrefix(ref, "#/$defs/")].(map[string]interface{})
+				}
+			}
+		}
+		if typ.Kind() != reflect.Struct || typ == reflect.TypeOf(time.Time{}) {
+			return
+		}
+
+		properties, _ := node["properties"].(map[string]interface{})
+		fields := make(map[string]bool)
+		for i := 0; i < typ.NumField(); i++ {
+			field := typ.Field(i)
+			name := strings.Split(field.Tag.Get("json"), ",")[0]
+			fields[name] = true
+
+			property, ok := properties[name].(map[string]interface{})
+			if !ok {
+				t.Errorf("schema.json does not describe %s%s", path, name)
+				continue
+			}
+			compare(path+name+".", field.Type, property)
+		}
+		for name := range properties {
+			if !fields[name] {
+				t.Errorf("schema.json describes %s%s, which reports don't have", path, name)
+			}
+		}
+	}
+	compare("", reflect.TypeOf(Report{}), schema)
+
+	// Every operation a failure is recorded for must be allowed by the schema,
+	// including the switch webhooks
+	failure := schema["properties"].(map[string]interface{})["failures"].(map[string]interface{})["items"].(map[string]interface{})
+	allowed := make(map[string]bool)
+	for _, operation := range failure["properties"].(map[string]interface{})["operation"].(map[string]interface{})["enum"].([]interface{}) {
+		allowed[operation.(string)] = true
+	}
+	report := NewReport("once", testServices(), nil)
+	report.AddFailures([]certmanager.Failure{
+		{Domain: "www.example.com", Operation: "renew", Error: "rate limited"},
+		{Domain: "www.example.com", Operation: switchhook.EventPreSwitch, Error: "connection refused"},
+		{Domain: "www.example.com", Operation: switchhook.EventPostSwitch, Error: "connection refused"},
+	}, false)
+	for _, f := range report.Failures {
+		if !allowed[f.Operation] {
+			t.Errorf("schema.json does not allow failure operation %q", f.Operation)
+		}
+	}
+}
@@ -0,0 +1,8 @@
+package status
+
+import _ "embed"
+
+// Schema is the JSON Schema of the current SchemaVersion of Report
+//
+//go:embed schema.json
+var Schema []byte
@@ -0,0 +1,103 @@
+// Package switchhook calls the webhooks a domain configures around the moment
+// Traefik is given its new certificate, e.g. to drain connections before the
+// switch and purge a CDN after it.
+package switchhook
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+// Events a webhook is called for
+const (
+	EventPreSwitch  = "pre_switch"
+	EventPostSwitch = "post_switch"
+)
+
+// defaultTimeout bounds a call when the domain's timeout is unset
+const defaultTimeout = 30 * time.Second
+
+// Payload is the JSON body posted to a webhook
+type Payload struct {
+	Event    string    `json:"event"` // pre_switch or post_switch
+	Domain   string    `json:"domain"`
+	Service  string    `json:"service,omitempty"`
+	Serial   string    `json:"serial"` // of the new certificate
+	NotAfter time.Time `json:"not_after"`
+}
+
+// Caller posts payloads to switch webhooks
+type Caller struct {
+	client *http.Client
+	logger *log.Logger
+}
+
+func NewCaller(logger *log.Logger) *Caller {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[SwitchHook] ", log.LstdFlags)
+	}
+
+	return &Caller{client: &http.Client{}, logger: logger}
+}
+
+// URL returns the webhook of an event, empty when none is configured
+func URL(webhooks config.SwitchWebhooks, event string) string {
+	switch event {
+	case EventPreSwitch:
+		return webhooks.PreSwitch
+	case EventPostSwitch:
+		return webhooks.PostSwitch
+	}
+	return ""
+}
+
+// Call posts p to the webhook of its event within the configured timeout. It
+// does nothing when the event has no webhook. Responses other than 2xx fail
+// the call.
+func (c *Caller) Call(ctx context.Context, webhooks config.SwitchWebhooks, p Payload) error {
+	url := URL(webhooks, p.Event)
+	if url == "" {
+		return nil
+	}
+
+	timeout, err := time.ParseDuration(webhooks.Timeout)
+	if err != nil || timeout <= 0 {
+		timeout = defaultTimeout
+	}
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	payload, err := json.Marshal(p)
+	if err != nil {
+		return fmt.Errorf("failed to encode %s payload: %w", p.Event, err)
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(payload))
+	if err != nil {
+		return fmt.Errorf("failed to create %s request: %w", p.Event, err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	started := time.Now()
+	resp, err := c.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("%s webhook failed: %w", p.Event, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		body, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("%s webhook returned %s: %s", p.Event, resp.Status, strings.TrimSpace(string(body)))
+	}
+	c.logger.Printf("Called %s webhook for %s in %v", p.Event, p.Domain, time.Since(started).Round(time.Millisecond))
+	return nil
+}
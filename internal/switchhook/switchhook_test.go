@@ -0,0 +1,86 @@
+package switchhook
+
+import (
+	"context"
+	"encoding/json"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func testLogger() *log.Logger {
+	return log.New(os.Stdout, "[TEST] ", log.LstdFlags)
+}
+
+func TestCaller_Call(t *testing.T) {
+	var received []Payload
+	status := http.StatusNoContent
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost || r.Header.Get("Content-Type") != "application/json" {
+			t.Errorf("got %s with Content-Type %q", r.Method, r.Header.Get("Content-Type"))
+		}
+		var p Payload
+		if err := json.NewDecoder(r.Body).Decode(&p); err != nil {
+			t.Errorf("failed to decode payload: %v", err)
+		}
+		received = append(received, p)
+		if status != http.StatusNoContent {
+			http.Error(w, "draining failed", status)
+			return
+		}
+		w.WriteHeader(status)
+	}))
+	defer server.Close()
+
+	caller := NewCaller(testLogger())
+	webhooks := config.SwitchWebhooks{PreSwitch: server.URL + "/pre", Timeout: "5s"}
+	notAfter := time.Date(2030, 4, 1, 0, 0, 0, 0, time.UTC)
+
+	err := caller.Call(context.Background(), webhooks, Payload{Event: EventPreSwitch, Domain: "example.com", Serial: "01", NotAfter: notAfter})
+	if err != nil {
+		t.Fatalf("Call() = %v", err)
+	}
+	if len(received) != 1 || received[0].Domain != "example.com" || received[0].Event != EventPreSwitch || !received[0].NotAfter.Equal(notAfter) {
+		t.Errorf("received %+v", received)
+	}
+
+	// An event without a webhook isn't called
+	if err := caller.Call(context.Background(), webhooks, Payload{Event: EventPostSwitch, Domain: "example.com"}); err != nil {
+		t.Errorf("Call() without a post_switch webhook = %v", err)
+	}
+	if len(received) != 1 {
+		t.Errorf("post_switch was called without a webhook")
+	}
+
+	status = http.StatusServiceUnavailable
+	err = caller.Call(context.Background(), webhooks, Payload{Event: EventPreSwitch, Domain: "example.com"})
+	if err == nil || !strings.Contains(err.Error(), "503") || !strings.Contains(err.Error(), "draining failed") {
+		t.Errorf("Call() = %v, want the 503 and its body", err)
+	}
+}
+
+func TestCaller_Timeout(t *testing.T) {
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		<-release
+	}))
+	defer server.Close()
+	defer close(release)
+
+	caller := NewCaller(testLogger())
+	webhooks := config.SwitchWebhooks{PostSwitch: server.URL, Timeout: "50ms"}
+	started := time.Now()
+	err := caller.Call(context.Background(), webhooks, Payload{Event: EventPostSwitch, Domain: "example.com"})
+	if err == nil {
+		t.Fatal("Call() succeeded past its timeout")
+	}
+	if elapsed := time.Since(started); elapsed > 5*time.Second {
+		t.Errorf("Call() took %v despite a 50ms timeout", elapsed)
+	}
+}
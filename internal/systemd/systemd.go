@@ -0,0 +1,128 @@
+// Package systemd implements the parts of the systemd service protocol the
+// daemon uses: readiness and status notifications, watchdog keep-alives and
+// sockets passed by socket activation. Outside systemd everything is a no-op.
+package systemd
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// listenFDsStart is the first file descriptor passed by socket activation
+const listenFDsStart = 3
+
+// Notify sends state, such as "READY=1" or "STATUS=...", to the service
+// manager. It reports false without error when not run by systemd with
+// Type=notify.
+func Notify(state string) (bool, error) {
+	socket := os.Getenv("NOTIFY_SOCKET")
+	if socket == "" {
+		return false, nil
+	}
+	// A leading @ denotes a socket in the abstract namespace
+	if socket[0] == '@' {
+		socket = "\x00" + socket[1:]
+	}
+
+	conn, err := net.DialUnix("unixgram", nil, &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		return false, fmt.Errorf("failed to connect to notify socket: %w", err)
+	}
+	defer conn.Close()
+
+	if _, err := conn.Write([]byte(state)); err != nil {
+		return false, fmt.Errorf("failed to notify service manager: %w", err)
+	}
+	return true, nil
+}
+
+// WatchdogInterval returns how often systemd expects a keep-alive, zero when
+// the watchdog is off or meant for another process
+func WatchdogInterval() time.Duration {
+	usec, err := strconv.ParseInt(os.Getenv("WATCHDOG_USEC"), 10, 64)
+	if err != nil || usec <= 0 {
+		return 0
+	}
+	if pid := os.Getenv("WATCHDOG_PID"); pid != "" && pid != strconv.Itoa(os.Getpid()) {
+		return 0
+	}
+	return time.Duration(usec) * time.Microsecond
+}
+
+// Watchdog sends keep-alives at half the watchdog interval until ctx is done,
+// as long as alive succeeds, so that systemd restarts a daemon that is up but
+// no longer working. It returns at once when the watchdog is off.
+func Watchdog(ctx context.Context, alive func() error, logf func(string, ...any)) {
+	interval := WatchdogInterval()
+	if interval == 0 {
+		return
+	}
+
+	ticker := time.NewTicker(interval / 2)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		if err := alive(); err != nil {
+			logf("Warning: withholding watchdog keep-alive: %v", err)
+			continue
+		}
+		if _, err := Notify("WATCHDOG=1"); err != nil {
+			logf("Warning: %v", err)
+		}
+	}
+}
+
+// Listeners returns the sockets passed by socket activation by their
+// FileDescriptorName=, "unknown" for unnamed ones. The environment is cleared
+// so that child processes, such as hooks, don't take them for their own.
+func Listeners() (map[string]net.Listener, error) {
+	defer func() {
+		os.Unsetenv("LISTEN_PID")
+		os.Unsetenv("LISTEN_FDS")
+		os.Unsetenv("LISTEN_FDNAMES")
+	}()
+
+	if os.Getenv("LISTEN_PID") != strconv.Itoa(os.Getpid()) {
+		return nil, nil
+	}
+	count, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || count <= 0 {
+		return nil, nil
+	}
+	names := listenerNames(os.Getenv("LISTEN_FDNAMES"), count)
+
+	listeners := make(map[string]net.Listener, count)
+	for i := 0; i < count; i++ {
+		f := os.NewFile(uintptr(listenFDsStart+i), names[i])
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			return nil, fmt.Errorf("failed to use socket %s passed by systemd: %w", names[i], err)
+		}
+		listeners[names[i]] = l
+	}
+	return listeners, nil
+}
+
+// listenerNames returns the names of count passed sockets from LISTEN_FDNAMES
+func listenerNames(env string, count int) []string {
+	names := make([]string, count)
+	given := strings.Split(env, ":")
+	for i := range names {
+		names[i] = "unknown"
+		if env != "" && i < len(given) && given[i] != "" {
+			names[i] = given[i]
+		}
+	}
+	return names
+}
@@ -0,0 +1,87 @@
+package systemd
+
+import (
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	t.Setenv("NOTIFY_SOCKET", "")
+	if sent, err := Notify("READY=1"); sent || err != nil {
+		t.Fatalf("Notify() outside systemd = %v, %v, want false, nil", sent, err)
+	}
+
+	path := filepath.Join(t.TempDir(), "notify.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: path, Net: "unixgram"})
+	if err != nil {
+		t.Skipf("unix datagram sockets unavailable: %v", err)
+	}
+	defer conn.Close()
+	t.Setenv("NOTIFY_SOCKET", path)
+
+	sent, err := Notify("READY=1\nSTATUS=Running")
+	if !sent || err != nil {
+		t.Fatalf("Notify() = %v, %v, want true, nil", sent, err)
+	}
+
+	conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+	buf := make([]byte, 256)
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatalf("failed to read notification: %v", err)
+	}
+	if got := string(buf[:n]); got != "READY=1\nSTATUS=Running" {
+		t.Errorf("notification = %q", got)
+	}
+}
+
+func TestWatchdogInterval(t *testing.T) {
+	tests := []struct {
+		name string
+		usec string
+		pid  string
+		want time.Duration
+	}{
+		{name: "off", want: 0},
+		{name: "enabled", usec: "30000000", want: 30 * time.Second},
+		{name: "this process", usec: "30000000", pid: strconv.Itoa(os.Getpid()), want: 30 * time.Second},
+		{name: "another process", usec: "30000000", pid: "1", want: 0},
+		{name: "invalid", usec: "soon", want: 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			t.Setenv("WATCHDOG_USEC", tt.usec)
+			t.Setenv("WATCHDOG_PID", tt.pid)
+			if got := WatchdogInterval(); got != tt.want {
+				t.Errorf("WatchdogInterval() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestListeners_OtherProcess(t *testing.T) {
+	t.Setenv("LISTEN_PID", "1")
+	t.Setenv("LISTEN_FDS", "1")
+
+	listeners, err := Listeners()
+	if err != nil || listeners != nil {
+		t.Fatalf("Listeners() = %v, %v, want nil, nil", listeners, err)
+	}
+	if _, ok := os.LookupEnv("LISTEN_FDS"); ok {
+		t.Error("LISTEN_FDS should be cleared")
+	}
+}
+
+func TestListenerNames(t *testing.T) {
+	got := listenerNames("api::metrics", 4)
+	want := []string{"api", "unknown", "metrics", "unknown"}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("listenerNames()[%d] = %q, want %q", i, got[i], want[i])
+		}
+	}
+}
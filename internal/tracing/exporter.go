@@ -0,0 +1,275 @@
+package tracing
+
+import (
+	"bytes"
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+const (
+	// exportInterval is how often queued spans are exported
+	exportInterval = 5 * time.Second
+	// exportBatch queues this many spans before exporting early
+	exportBatch = 512
+	// maxQueue bounds the spans waiting for export; more are dropped while
+	// the endpoint is unreachable
+	maxQueue = 2048
+	// exportTimeout bounds each export request
+	exportTimeout = 10 * time.Second
+)
+
+// Tracer batches finished spans and exports them to an OTLP/HTTP endpoint
+type Tracer struct {
+	url         string
+	headers     map[string]string
+	serviceName string
+	ratio       float64
+	client      *http.Client
+	logger      *log.Logger
+
+	mu      sync.Mutex
+	queue   []*Span
+	dropped int
+
+	wake chan struct{}
+	stop chan struct{}
+	done chan struct{}
+	once sync.Once
+}
+
+// NewTracer creates a tracer exporting to cfg.Endpoint and starts its export
+// loop. Shutdown flushes the remaining spans and stops it.
+func NewTracer(cfg config.Tracing, logger *log.Logger) (*Tracer, error) {
+	if logger == nil {
+		logger = log.New(os.Stdout, "[Tracing] ", log.LstdFlags)
+	}
+	if !cfg.Enabled() {
+		return nil, fmt.Errorf("no tracing endpoint is configured")
+	}
+
+	ratio := cfg.SampleRatio
+	if ratio == 0 {
+		ratio = 1
+	}
+	t := &Tracer{
+		url:         strings.TrimSuffix(cfg.Endpoint, "/") + "/v1/traces",
+		headers:     cfg.Headers,
+		serviceName: cfg.ServiceName,
+		ratio:       ratio,
+		client:      &http.Client{Timeout: exportTimeout},
+		logger:      logger,
+		wake:        make(chan struct{}, 1),
+		stop:        make(chan struct{}),
+		done:        make(chan struct{}),
+	}
+	go t.run()
+	return t, nil
+}
+
+func (t *Tracer) enqueue(span *Span) {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+
+	if len(t.queue) >= maxQueue {
+		t.dropped++
+		return
+	}
+	t.queue = append(t.queue, span)
+	if len(t.queue) >= exportBatch {
+		select {
+		case t.wake <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (t *Tracer) run() {
+	defer close(t.done)
+
+	ticker := time.NewTicker(exportInterval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ticker.C:
+		case <-t.wake:
+		case <-t.stop:
+			t.flush(context.Background())
+			return
+		}
+		t.flush(context.Background())
+	}
+}
+
+// flush exports the queued spans
+func (t *Tracer) flush(ctx context.Context) {
+	t.mu.Lock()
+	spans := t.queue
+	dropped := t.dropped
+	t.queue = nil
+	t.dropped = 0
+	t.mu.Unlock()
+
+	if dropped > 0 {
+		t.logger.Printf("Warning: dropped %d spans while the export queue was full", dropped)
+	}
+	if len(spans) == 0 {
+		return
+	}
+	if err := t.export(ctx, spans); err != nil {
+		t.logger.Printf("Warning: failed to export %d spans: %v", len(spans), err)
+	}
+}
+
+// Shutdown exports the spans still queued and stops the export loop
+func (t *Tracer) Shutdown(ctx context.Context) error {
+	t.once.Do(func() { close(t.stop) })
+	select {
+	case <-t.done:
+		return nil
+	case <-ctx.Done():
+		return fmt.Errorf("failed to flush spans: %w", ctx.Err())
+	}
+}
+
+func (t *Tracer) export(ctx context.Context, spans []*Span) error {
+	body, err := json.Marshal(t.encode(spans))
+	if err != nil {
+		return fmt.Errorf("failed to encode spans: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.url, bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("failed to create export request: %w", err)
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for name, value := range t.headers {
+		req.Header.Set(name, value)
+	}
+
+	resp, err := t.client.Do(req)
+	if err != nil {
+		return fmt.Errorf("failed to send spans: %w", err)
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		message, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return fmt.Errorf("collector answered %s: %s", resp.Status, strings.TrimSpace(string(message)))
+	}
+	return nil
+}
+
+// OTLP/JSON encoding of an export request, see
+// https://opentelemetry.io/docs/specs/otlp/#json-protobuf-encoding
+
+type otlpRequest struct {
+	ResourceSpans []otlpResourceSpans `json:"resourceSpans"`
+}
+
+type otlpResourceSpans struct {
+	Resource   otlpResource     `json:"resource"`
+	ScopeSpans []otlpScopeSpans `json:"scopeSpans"`
+}
+
+type otlpResource struct {
+	Attributes []otlpAttribute `json:"attributes"`
+}
+
+type otlpScopeSpans struct {
+	Scope otlpScope  `json:"scope"`
+	Spans []otlpSpan `json:"spans"`
+}
+
+type otlpScope struct {
+	Name string `json:"name"`
+}
+
+type otlpSpan struct {
+	TraceID           string          `json:"traceId"`
+	SpanID            string          `json:"spanId"`
+	ParentSpanID      string          `json:"parentSpanId,omitempty"`
+	Name              string          `json:"name"`
+	Kind              SpanKind        `json:"kind"`
+	StartTimeUnixNano string          `json:"startTimeUnixNano"`
+	EndTimeUnixNano   string          `json:"endTimeUnixNano"`
+	Attributes        []otlpAttribute `json:"attributes,omitempty"`
+	Status            otlpStatus      `json:"status"`
+}
+
+type otlpStatus struct {
+	Code    int    `json:"code"` // 1 ok, 2 error
+	Message string `json:"message,omitempty"`
+}
+
+type otlpAttribute struct {
+	Key   string    `json:"key"`
+	Value otlpValue `json:"value"`
+}
+
+type otlpValue struct {
+	StringValue *string `json:"stringValue,omitempty"`
+	IntValue    *string `json:"intValue,omitempty"` // 64-bit integers are strings in OTLP/JSON
+	BoolValue   *bool   `json:"boolValue,omitempty"`
+}
+
+func (t *Tracer) encode(spans []*Span) otlpRequest {
+	encoded := make([]otlpSpan, 0, len(spans))
+	for _, span := range spans {
+		span.mu.Lock()
+		s := otlpSpan{
+			TraceID:           hex.EncodeToString(span.traceID[:]),
+			SpanID:            hex.EncodeToString(span.spanID[:]),
+			Name:              span.name,
+			Kind:              span.kind,
+			StartTimeUnixNano: strconv.FormatInt(span.start.UnixNano(), 10),
+			EndTimeUnixNano:   strconv.FormatInt(span.end.UnixNano(), 10),
+			Attributes:        encodeAttributes(span.attrs),
+			Status:            otlpStatus{Code: 1},
+		}
+		if span.err != "" {
+			s.Status = otlpStatus{Code: 2, Message: span.err}
+		}
+		span.mu.Unlock()
+		if span.parentID != [8]byte{} {
+			s.ParentSpanID = hex.EncodeToString(span.parentID[:])
+		}
+		encoded = append(encoded, s)
+	}
+
+	return otlpRequest{ResourceSpans: []otlpResourceSpans{{
+		Resource:   otlpResource{Attributes: encodeAttributes([]Attribute{String("service.name", t.serviceName)})},
+		ScopeSpans: []otlpScopeSpans{{Scope: otlpScope{Name: "github.com/O-tero/traefik-cert-manager"}, Spans: encoded}},
+	}}}
+}
+
+func encodeAttributes(attrs []Attribute) []otlpAttribute {
+	encoded := make([]otlpAttribute, 0, len(attrs))
+	for _, attr := range attrs {
+		var value otlpValue
+		switch v := attr.Value.(type) {
+		case string:
+			value.StringValue = &v
+		case int64:
+			n := strconv.FormatInt(v, 10)
+			value.IntValue = &n
+		case bool:
+			value.BoolValue = &v
+		default:
+			s := fmt.Sprint(v)
+			value.StringValue = &s
+		}
+		encoded = append(encoded, otlpAttribute{Key: attr.Key, Value: value})
+	}
+	return encoded
+}
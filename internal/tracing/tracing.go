@@ -0,0 +1,200 @@
+// Package tracing records OpenTelemetry spans of renewal runs and exports them
+// over OTLP/HTTP, so slow renewals can be followed end to end in Jaeger, Tempo
+// or any other OpenTelemetry backend.
+package tracing
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"math"
+	"math/big"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// Attribute is a key and value recorded on a span
+type Attribute struct {
+	Key   string
+	Value interface{} // string, int64 or bool
+}
+
+// String returns a string attribute
+func String(key, value string) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// Int returns an integer attribute
+func Int(key string, value int) Attribute {
+	return Attribute{Key: key, Value: int64(value)}
+}
+
+// Bool returns a boolean attribute
+func Bool(key string, value bool) Attribute {
+	return Attribute{Key: key, Value: value}
+}
+
+// SpanKind is the OTLP kind of a span
+type SpanKind int
+
+const (
+	KindInternal SpanKind = 1
+	KindClient   SpanKind = 3
+)
+
+// Span is an operation within a trace. All methods are safe to call on a nil
+// span, which is what Start returns while tracing is disabled.
+type Span struct {
+	tracer   *Tracer
+	traceID  [16]byte
+	spanID   [8]byte
+	parentID [8]byte
+	name     string
+	kind     SpanKind
+	start    time.Time
+	sampled  bool
+
+	mu    sync.Mutex
+	end   time.Time
+	attrs []Attribute
+	err   string
+	ended bool
+}
+
+// SetAttributes records attributes on the span
+func (s *Span) SetAttributes(attrs ...Attribute) {
+	if s == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.attrs = append(s.attrs, attrs...)
+}
+
+// RecordError marks the span as failed with err; a nil err is ignored
+func (s *Span) RecordError(err error) {
+	if s == nil || err == nil || !s.sampled {
+		return
+	}
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	s.err = err.Error()
+}
+
+// End completes the span and queues it for export. Only the first call has an
+// effect.
+func (s *Span) End() {
+	if s == nil {
+		return
+	}
+	s.mu.Lock()
+	if s.ended {
+		s.mu.Unlock()
+		return
+	}
+	s.ended = true
+	s.end = time.Now()
+	s.mu.Unlock()
+
+	if s.sampled {
+		s.tracer.enqueue(s)
+	}
+}
+
+// TraceID returns the hex-encoded ID of the span's trace
+func (s *Span) TraceID() string {
+	if s == nil {
+		return ""
+	}
+	return hex.EncodeToString(s.traceID[:])
+}
+
+// traceparent returns the W3C Trace Context header value identifying the span
+func (s *Span) traceparent() string {
+	flags := "00"
+	if s.sampled {
+		flags = "01"
+	}
+	return "00-" + hex.EncodeToString(s.traceID[:]) + "-" + hex.EncodeToString(s.spanID[:]) + "-" + flags
+}
+
+type spanKey struct{}
+
+// SpanFromContext returns the span started by ctx, nil when there is none
+func SpanFromContext(ctx context.Context) *Span {
+	span, _ := ctx.Value(spanKey{}).(*Span)
+	return span
+}
+
+// ContextWithSpan returns a copy of ctx carrying span, so the spans started
+// from it become its children. A nil span leaves ctx unchanged.
+func ContextWithSpan(ctx context.Context, span *Span) context.Context {
+	if span == nil {
+		return ctx
+	}
+	return context.WithValue(ctx, spanKey{}, span)
+}
+
+// global is the tracer used by Start, nil while tracing is disabled
+var global atomic.Pointer[Tracer]
+
+// SetTracer makes t the tracer of Start; nil disables tracing
+func SetTracer(t *Tracer) {
+	global.Store(t)
+}
+
+// Start begins a span named name as a child of the span in ctx, or as the root
+// of a new trace. The returned context carries the span; callers End it.
+func Start(ctx context.Context, name string, attrs ...Attribute) (context.Context, *Span) {
+	return start(ctx, name, KindInternal, attrs)
+}
+
+func start(ctx context.Context, name string, kind SpanKind, attrs []Attribute) (context.Context, *Span) {
+	t := global.Load()
+	if t == nil {
+		return ctx, nil
+	}
+
+	span := &Span{tracer: t, name: name, kind: kind, start: time.Now()}
+	if parent := SpanFromContext(ctx); parent != nil && parent.tracer == t {
+		// Children follow the sampling decision of their trace
+		span.traceID = parent.traceID
+		span.parentID = parent.spanID
+		span.sampled = parent.sampled
+	} else {
+		randomID(span.traceID[:])
+		span.sampled = t.sample()
+	}
+	randomID(span.spanID[:])
+	if span.sampled {
+		span.attrs = append(span.attrs, attrs...)
+	}
+	return context.WithValue(ctx, spanKey{}, span), span
+}
+
+func randomID(id []byte) {
+	for {
+		_, _ = rand.Read(id)
+		for _, b := range id {
+			if b != 0 {
+				return
+			}
+		}
+	}
+}
+
+// sampleBound is the scale sampling ratios are compared on
+const sampleBound = math.MaxInt32
+
+// sample decides whether a new trace is exported
+func (t *Tracer) sample() bool {
+	if t.ratio >= 1 {
+		return true
+	}
+	n, err := rand.Int(rand.Reader, big.NewInt(sampleBound))
+	if err != nil {
+		return false
+	}
+	return float64(n.Int64()) < t.ratio*sampleBound
+}
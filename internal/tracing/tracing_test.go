@@ -0,0 +1,139 @@
+package tracing
+
+import (
+	"context"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/config"
+)
+
+func TestStart_Disabled(t *testing.T) {
+	SetTracer(nil)
+	ctx, span := Start(context.Background(), "scheduler.run")
+	if span != nil || SpanFromContext(ctx) != nil {
+		t.Fatalf("Start() returned a span while tracing is disabled")
+	}
+	// Every method is safe on the nil span
+	span.SetAttributes(String("domain", "example.com"))
+	span.RecordError(errors.New("failed"))
+	span.End()
+}
+
+func TestTracer_Export(t *testing.T) {
+	requests := make(chan otlpRequest, 1)
+	collector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/v1/traces" || r.Header.Get("Authorization") != "Bearer secret" {
+			t.Errorf("export to %s with Authorization %q", r.URL.Path, r.Header.Get("Authorization"))
+		}
+		var req otlpRequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			t.Errorf("failed to decode export: %v", err)
+		}
+		requests <- req
+	}))
+	defer collector.Close()
+
+	tracer, err := NewTracer(config.Tracing{
+		Endpoint:    collector.URL + "/",
+		Headers:     map[string]string{"Authorization": "Bearer secret"},
+		ServiceName: "certs",
+	}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("NewTracer() error = %v", err)
+	}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, run := Start(context.Background(), "scheduler.run", Int("run.number", 3))
+	_, renew := Start(ctx, "certificate.renew", String("domain", "example.com"))
+	renew.RecordError(errors.New("rate limited"))
+	renew.End()
+	run.End()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := tracer.Shutdown(ctx); err != nil {
+		t.Fatalf("Shutdown() error = %v", err)
+	}
+
+	req := <-requests
+	resource := req.ResourceSpans[0]
+	if got := *resource.Resource.Attributes[0].Value.StringValue; got != "certs" {
+		t.Errorf("service.name = %s, want certs", got)
+	}
+	spans := resource.ScopeSpans[0].Spans
+	if len(spans) != 2 {
+		t.Fatalf("exported %d spans, want 2", len(spans))
+	}
+	child, parent := spans[0], spans[1]
+	if child.TraceID != parent.TraceID || child.ParentSpanID != parent.SpanID || parent.ParentSpanID != "" {
+		t.Errorf("certificate.renew is not a child of scheduler.run: %+v, %+v", child, parent)
+	}
+	if child.Status.Code != 2 || child.Status.Message != "rate limited" || parent.Status.Code != 1 {
+		t.Errorf("statuses = %+v and %+v", child.Status, parent.Status)
+	}
+	if got := *parent.Attributes[0].Value.IntValue; got != "3" {
+		t.Errorf("run.number = %s, want 3", got)
+	}
+}
+
+func TestTracer_SampleRatio(t *testing.T) {
+	if !(&Tracer{ratio: 1}).sample() {
+		t.Errorf("a ratio of 1 should sample every trace")
+	}
+	for i := 0; i < 100; i++ {
+		if (&Tracer{ratio: 0}).sample() {
+			t.Fatalf("a ratio of 0 sampled a trace")
+		}
+	}
+}
+
+func TestTransport(t *testing.T) {
+	var traceparent string
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		traceparent = r.Header.Get("traceparent")
+		w.WriteHeader(http.StatusTooManyRequests)
+	}))
+	defer server.Close()
+
+	tracer := &Tracer{ratio: 1, logger: log.New(io.Discard, "", 0)}
+	SetTracer(tracer)
+	defer SetTracer(nil)
+
+	ctx, order := Start(context.Background(), "acme.order")
+	req, _ := http.NewRequestWithContext(ctx, http.MethodPost, server.URL+"/acme/new-order?token=secret", nil)
+	resp, err := (&http.Client{Transport: Transport(nil)}).Do(req)
+	if err != nil {
+		t.Fatalf("request failed: %v", err)
+	}
+	resp.Body.Close()
+
+	if req.Header.Get("traceparent") != "" {
+		t.Errorf("the caller's request was modified")
+	}
+	if len(tracer.queue) != 1 {
+		t.Fatalf("queued %d spans, want the client span", len(tracer.queue))
+	}
+	span := tracer.queue[0]
+	parts := strings.Split(traceparent, "-")
+	if len(parts) != 4 || parts[1] != order.TraceID() || parts[2] != hex.EncodeToString(span.spanID[:]) || parts[3] != "01" {
+		t.Errorf("traceparent = %q", traceparent)
+	}
+	if span.parentID != order.spanID || span.kind != KindClient || span.err != "429 Too Many Requests" {
+		t.Errorf("client span = %+v", span)
+	}
+	for _, attr := range span.attrs {
+		if attr.Key == "url.full" && attr.Value != server.URL+"/acme/new-order" {
+			t.Errorf("url.full = %v, want it without the query", attr.Value)
+		}
+	}
+}
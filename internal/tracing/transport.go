@@ -0,0 +1,63 @@
+package tracing
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// Transport returns a round tripper recording a client span for every request
+// sent through next. A nil next uses http.DefaultTransport.
+func Transport(next http.RoundTripper) http.RoundTripper {
+	if next == nil {
+		next = http.DefaultTransport
+	}
+	return &transport{next: next}
+}
+
+type transport struct {
+	next http.RoundTripper
+}
+
+func (t *transport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req, span := StartRequest(req)
+	resp, err := t.next.RoundTrip(req)
+	EndRequest(span, resp, err)
+	return resp, err
+}
+
+// StartRequest begins a client span for req as a child of the span in its
+// context, and returns a copy of req propagating the trace to the server in a
+// W3C traceparent header. While tracing is disabled req is returned as is.
+func StartRequest(req *http.Request) (*http.Request, *Span) {
+	ctx, span := start(req.Context(), "HTTP "+req.Method, KindClient, []Attribute{
+		String("http.request.method", req.Method),
+		String("server.address", req.URL.Host),
+		// The query may hold credentials
+		String("url.full", req.URL.Scheme+"://"+req.URL.Host+req.URL.Path),
+	})
+	if span == nil {
+		return req, nil
+	}
+
+	// The caller's request must not be modified
+	req = req.Clone(ctx)
+	req.Header.Set("traceparent", span.traceparent())
+	return req, span
+}
+
+// EndRequest records the response to a request started with StartRequest on
+// its span and ends it
+func EndRequest(span *Span, resp *http.Response, err error) {
+	if span == nil {
+		return
+	}
+	if err != nil {
+		span.RecordError(err)
+	} else {
+		span.SetAttributes(Int("http.response.status_code", resp.StatusCode))
+		if resp.StatusCode >= 400 {
+			span.RecordError(fmt.Errorf("%s", resp.Status))
+		}
+	}
+	span.End()
+}
@@ -3,11 +3,15 @@ package traefik
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"regexp"
 	"strings"
 	"time"
+
+	"github.com/O-tero/traefik-cert-manager/internal/tracing"
 )
 
 // Service represents a Traefik service
@@ -22,9 +26,11 @@ type Service struct {
 
 type Router struct {
 	Name        string   `json:"name"`
+	Provider    string   `json:"provider,omitempty"`
 	Status      string   `json:"status"`
 	Using       []string `json:"using"`
 	Rule        string   `json:"rule"`
+	RuleSyntax  string   `json:"ruleSyntax,omitempty"` // v3 only
 	Priority    int      `json:"priority"`
 	EntryPoints []string `json:"entryPoints"`
 	Service     string   `json:"service"`
@@ -32,9 +38,26 @@ type Router struct {
 }
 
 type TLS struct {
-	Passthrough bool `json:"passthrough"`
+	Passthrough  bool        `json:"passthrough"`
+	CertResolver string      `json:"certResolver,omitempty"`
+	Options      string      `json:"options,omitempty"`
+	Domains      []TLSDomain `json:"domains,omitempty"`
+}
+
+// TLSDomain is an explicit main/SANs pair attached to a router's TLS section
+type TLSDomain struct {
+	Main string   `json:"main"`
+	SANs []string `json:"sans,omitempty"`
 }
 
+// Protocol identifies which Traefik router family a router belongs to
+type Protocol string
+
+const (
+	ProtocolHTTP Protocol = "http"
+	ProtocolTCP  Protocol = "tcp"
+)
+
 // APIClient handles communication with Traefik API
 type APIClient struct {
 	baseURL    string
@@ -51,6 +74,17 @@ func NewAPIClient(baseURL string, timeout time.Duration) *APIClient {
 	}
 }
 
+// do sends req within a client span of the trace in its context
+func (c *APIClient) do(req *http.Request) (*http.Response, error) {
+	req, span := tracing.StartRequest(req)
+	resp, err := c.httpClient.Do(req)
+	tracing.EndRequest(span, resp, err)
+	if err != nil && req.Context().Err() == nil {
+		return nil, &unreachableError{err: err}
+	}
+	return resp, err
+}
+
 // GetServices retrieves all services from Traefik API
 func (c *APIClient) GetServices(ctx context.Context) ([]string, error) {
 	services, err := c.getServicesDetailed(ctx)
@@ -68,73 +102,151 @@ func (c *APIClient) GetServices(ctx context.Context) ([]string, error) {
 
 // GetServicesDetailed retrieves detailed service information from Traefik API
 func (c *APIClient) getServicesDetailed(ctx context.Context) ([]Service, error) {
-	url := fmt.Sprintf("%s/http/services", c.baseURL)
-	
-	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
-	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+	var services []Service
+	if err := c.getJSON(ctx, "/http/services", &services); err != nil {
+		return nil, err
 	}
 
-	resp, err := c.httpClient.Do(req)
-	if err != nil {
-		return nil, fmt.Errorf("failed to call Traefik API: %w", err)
+	return services, nil
+}
+
+// GetRouters retrieves all routers from Traefik API
+func (c *APIClient) GetRouters(ctx context.Context) ([]Router, error) {
+	var routers []Router
+	if err := c.getJSON(ctx, "/http/routers", &routers); err != nil {
+		return nil, err
 	}
-	defer resp.Body.Close()
 
-	if resp.StatusCode != http.StatusOK {
-		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+	return routers, nil
+}
+
+// GetTCPRouters retrieves all TCP routers from Traefik API
+func (c *APIClient) GetTCPRouters(ctx context.Context) ([]Router, error) {
+	var routers []Router
+	if err := c.getJSON(ctx, "/tcp/routers", &routers); err != nil {
+		if isNotFound(err) {
+			// TCP routing is unavailable on this Traefik instance
+			return nil, nil
+		}
+		return nil, err
 	}
 
+	return routers, nil
+}
+
+// GetTCPServices retrieves all TCP services from Traefik API
+func (c *APIClient) GetTCPServices(ctx context.Context) ([]Service, error) {
 	var services []Service
-	if err := json.NewDecoder(resp.Body).Decode(&services); err != nil {
-		return nil, fmt.Errorf("failed to decode services response: %w", err)
+	if err := c.getJSON(ctx, "/tcp/services", &services); err != nil {
+		if isNotFound(err) {
+			return nil, nil
+		}
+		return nil, err
 	}
 
 	return services, nil
 }
 
-// GetRouters retrieves all routers from Traefik API
-func (c *APIClient) GetRouters(ctx context.Context) ([]Router, error) {
-	url := fmt.Sprintf("%s/http/routers", c.baseURL)
-	
+// getAllRouters returns HTTP and TCP routers keyed by protocol
+func (c *APIClient) getAllRouters(ctx context.Context) (map[Protocol][]Router, error) {
+	httpRouters, err := c.GetRouters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	tcpRouters, err := c.GetTCPRouters(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	return map[Protocol][]Router{
+		ProtocolHTTP: httpRouters,
+		ProtocolTCP:  tcpRouters,
+	}, nil
+}
+
+// ErrUnreachable matches requests that didn't reach the Traefik API, or that
+// a proxy in front of it answered as unavailable
+var ErrUnreachable = errors.New("Traefik API unreachable")
+
+// unreachableError is a request that didn't reach the Traefik API
+type unreachableError struct {
+	err error
+}
+
+func (e *unreachableError) Error() string {
+	return e.err.Error()
+}
+
+func (e *unreachableError) Unwrap() error {
+	return e.err
+}
+
+func (e *unreachableError) Is(target error) bool {
+	return target == ErrUnreachable
+}
+
+// statusError is returned when the Traefik API answers with a non-200 status
+type statusError struct {
+	code int
+	body string
+}
+
+func (e *statusError) Error() string {
+	return fmt.Sprintf("API returned status %d: %s", e.code, e.body)
+}
+
+func (e *statusError) Is(target error) bool {
+	return target == ErrUnreachable &&
+		(e.code == http.StatusBadGateway || e.code == http.StatusServiceUnavailable || e.code == http.StatusGatewayTimeout)
+}
+
+func isNotFound(err error) bool {
+	var se *statusError
+	return errors.As(err, &se) && se.code == http.StatusNotFound
+}
+
+func (c *APIClient) getJSON(ctx context.Context, path string, v interface{}) error {
+	url := c.baseURL + path
+
 	req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
 	if err != nil {
-		return nil, fmt.Errorf("failed to create request: %w", err)
+		return fmt.Errorf("failed to create request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
-		return nil, fmt.Errorf("failed to call Traefik API: %w", err)
+		return fmt.Errorf("failed to call Traefik API: %w", err)
 	}
 	defer resp.Body.Close()
 
 	if resp.StatusCode != http.StatusOK {
 		body, _ := io.ReadAll(resp.Body)
-		return nil, fmt.Errorf("API returned status %d: %s", resp.StatusCode, string(body))
+		return &statusError{code: resp.StatusCode, body: string(body)}
 	}
 
-	var routers []Router
-	if err := json.NewDecoder(resp.Body).Decode(&routers); err != nil {
-		return nil, fmt.Errorf("failed to decode routers response: %w", err)
+	if err := json.NewDecoder(resp.Body).Decode(v); err != nil {
+		return fmt.Errorf("failed to decode %s response: %w", path, err)
 	}
 
-	return routers, nil
+	return nil
 }
 
 // GetServicesByDomain returns services that handle specific domains
 func (c *APIClient) GetServicesByDomain(ctx context.Context, domains []string) (map[string][]string, error) {
-	routers, err := c.GetRouters(ctx)
+	routers, err := c.getAllRouters(ctx)
 	if err != nil {
 		return nil, fmt.Errorf("failed to get routers: %w", err)
 	}
 
 	domainToServices := make(map[string][]string)
-	
-	for _, router := range routers {
-		for _, domain := range domains {
-			if c.routerMatchesDomain(router, domain) {
-				domainToServices[domain] = append(domainToServices[domain], router.Service)
+
+	for _, protocol := range []Protocol{ProtocolHTTP, ProtocolTCP} {
+		for _, router := range routers[protocol] {
+			for _, domain := range domains {
+				if c.routerMatchesDomain(router, domain) {
+					domainToServices[domain] = append(domainToServices[domain], router.Service)
+				}
 			}
 		}
 	}
@@ -142,24 +254,110 @@ func (c *APIClient) GetServicesByDomain(ctx context.Context, domains []string) (
 	return domainToServices, nil
 }
 
-func (c *APIClient) routerMatchesDomain(router Router, domain string) bool {
-	//  Reminder: do more sophisticated rule parsing
-	rule := strings.ToLower(router.Rule)
-	domain = strings.ToLower(domain)
-	
-	if strings.Contains(rule, fmt.Sprintf("host(`%s`)", domain)) {
-		return true
+// RouterDomain is a hostname served by a Traefik router
+type RouterDomain struct {
+	Domain   string
+	Router   string
+	Service  string
+	Protocol Protocol
+	TLS      bool // the router terminates TLS, so the domain needs a certificate
+}
+
+// DiscoverDomains returns hostnames from Host and HostSNI rules of HTTP and TCP routers
+func (c *APIClient) DiscoverDomains(ctx context.Context) ([]RouterDomain, error) {
+	routers, err := c.getAllRouters(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get routers: %w", err)
 	}
-	
-	if strings.Contains(rule, fmt.Sprintf("hostregexp(`%s`)", domain)) {
-		return true
+
+	var discovered []RouterDomain
+	for _, protocol := range []Protocol{ProtocolHTTP, ProtocolTCP} {
+		for _, router := range routers[protocol] {
+			for _, host := range ParseRuleHosts(router.Rule) {
+				discovered = append(discovered, RouterDomain{
+					Domain:   host,
+					Router:   router.Name,
+					Service:  router.Service,
+					Protocol: protocol,
+					TLS:      router.TLS != nil && !router.TLS.Passthrough,
+				})
+			}
+		}
 	}
-	
-	// Check for domain in Host rule with multiple domains
-	if strings.Contains(rule, "host(") && strings.Contains(rule, domain) {
-		return true
+
+	return discovered, nil
+}
+
+var (
+	hostMatcherRe = regexp.MustCompile(`(?i)\b(host|hostsni|hostregexp)\s*\(([^)]*)\)`)
+	ruleArgRe     = regexp.MustCompile("`([^`]*)`|\"([^\"]*)\"")
+)
+
+type ruleMatcher struct {
+	name string
+	args []string
+}
+
+func parseRuleMatchers(rule string) []ruleMatcher {
+	var matchers []ruleMatcher
+	for _, m := range hostMatcherRe.FindAllStringSubmatch(rule, -1) {
+		matcher := ruleMatcher{name: strings.ToLower(m[1])}
+		for _, arg := range ruleArgRe.FindAllStringSubmatch(m[2], -1) {
+			value := arg[1]
+			if value == "" {
+				value = arg[2]
+			}
+			matcher.args = append(matcher.args, strings.ToLower(strings.TrimSpace(value)))
+		}
+		matchers = append(matchers, matcher)
 	}
-	
+	return matchers
+}
+
+// ParseRuleHosts extracts literal hostnames from Host and HostSNI matchers in a router rule.
+// Both the v2 multi-argument form and the v3 single-argument form are supported;
+// HostRegexp patterns and the HostSNI(`*`) catch-all are ignored.
+func ParseRuleHosts(rule string) []string {
+	var hosts []string
+	seen := make(map[string]bool)
+
+	for _, matcher := range parseRuleMatchers(rule) {
+		if matcher.name == "hostregexp" {
+			continue
+		}
+		for _, host := range matcher.args {
+			if host == "" || host == "*" || seen[host] {
+				continue
+			}
+			seen[host] = true
+			hosts = append(hosts, host)
+		}
+	}
+
+	return hosts
+}
+
+func (c *APIClient) routerMatchesDomain(router Router, domain string) bool {
+	domain = strings.ToLower(domain)
+
+	for _, matcher := range parseRuleMatchers(router.Rule) {
+		for _, arg := range matcher.args {
+			if matcher.name != "hostregexp" {
+				if arg == domain {
+					return true
+				}
+				continue
+			}
+
+			if arg == domain {
+				return true
+			}
+			if re, err := regexp.Compile("^(?:" + arg + ")$"); err == nil && re.MatchString(domain) {
+				return true
+			}
+		}
+	}
+
 	return false
 }
 
@@ -172,7 +370,7 @@ func (c *APIClient) IsHealthy(ctx context.Context) error {
 		return fmt.Errorf("failed to create health check request: %w", err)
 	}
 
-	resp, err := c.httpClient.Do(req)
+	resp, err := c.do(req)
 	if err != nil {
 		return fmt.Errorf("failed to perform health check: %w", err)
 	}
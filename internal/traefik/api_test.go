@@ -3,6 +3,7 @@ package traefik
 import (
 	"context"
 	"encoding/json"
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"strings"
@@ -199,6 +200,146 @@ func TestAPIClient_GetServicesByDomain(t *testing.T) {
 	}
 }
 
+func TestAPIClient_GetTCPRouters(t *testing.T) {
+	mockRouters := []Router{
+		{
+			Name:        "postgres@docker",
+			Status:      "enabled",
+			Rule:        "HostSNI(`db.example.com`)",
+			EntryPoints: []string{"postgres"},
+			Service:     "postgres@docker",
+			TLS:         &TLS{Passthrough: true},
+		},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/tcp/routers" {
+			w.Header().Set("Content-Type", "application/json")
+			json.NewEncoder(w).Encode(mockRouters)
+		} else {
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, 30*time.Second)
+
+	routers, err := client.GetTCPRouters(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to get TCP routers: %v", err)
+	}
+
+	if len(routers) != 1 {
+		t.Fatalf("Expected 1 TCP router, got %d", len(routers))
+	}
+
+	if routers[0].TLS == nil || !routers[0].TLS.Passthrough {
+		t.Errorf("Expected TCP router to have TLS passthrough enabled")
+	}
+}
+
+func TestAPIClient_GetTCPRouters_NotSupported(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, 30*time.Second)
+
+	routers, err := client.GetTCPRouters(context.Background())
+	if err != nil {
+		t.Fatalf("Expected missing TCP endpoint to be tolerated, got: %v", err)
+	}
+
+	if len(routers) != 0 {
+		t.Errorf("Expected no TCP routers, got %d", len(routers))
+	}
+}
+
+func TestAPIClient_DiscoverDomains(t *testing.T) {
+	httpRouters := []Router{
+		{Name: "web@docker", Rule: "Host(`example.com`) || Host(`www.example.com`)", Service: "web@docker", TLS: &TLS{}},
+		{Name: "wildcard@docker", Rule: "HostRegexp(`^.+\\.example\\.com$`)", Service: "web@docker"},
+	}
+	tcpRouters := []Router{
+		{Name: "db@docker", Rule: "HostSNI(`db.example.com`)", Service: "db@docker", TLS: &TLS{Passthrough: true}},
+		{Name: "catchall@docker", Rule: "HostSNI(`*`)", Service: "raw@docker"},
+	}
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/http/routers":
+			json.NewEncoder(w).Encode(httpRouters)
+		case "/tcp/routers":
+			json.NewEncoder(w).Encode(tcpRouters)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	client := NewAPIClient(server.URL, 30*time.Second)
+
+	discovered, err := client.DiscoverDomains(context.Background())
+	if err != nil {
+		t.Fatalf("Failed to discover domains: %v", err)
+	}
+
+	expected := map[string]Protocol{
+		"example.com":     ProtocolHTTP,
+		"www.example.com": ProtocolHTTP,
+		"db.example.com":  ProtocolTCP,
+	}
+
+	if len(discovered) != len(expected) {
+		t.Fatalf("Expected %d discovered domains, got %d: %v", len(expected), len(discovered), discovered)
+	}
+
+	for _, d := range discovered {
+		protocol, ok := expected[d.Domain]
+		if !ok {
+			t.Errorf("Unexpected discovered domain '%s'", d.Domain)
+			continue
+		}
+		if d.Protocol != protocol {
+			t.Errorf("Expected protocol '%s' for %s, got '%s'", protocol, d.Domain, d.Protocol)
+		}
+		// Passthrough routers leave TLS to the service
+		if d.TLS != (protocol == ProtocolHTTP) {
+			t.Errorf("Expected TLS %v for %s, got %v", protocol == ProtocolHTTP, d.Domain, d.TLS)
+		}
+	}
+}
+
+func TestParseRuleHosts(t *testing.T) {
+	tests := []struct {
+		rule     string
+		expected []string
+	}{
+		{"Host(`example.com`)", []string{"example.com"}},
+		{"Host(`example.com`, `www.example.com`)", []string{"example.com", "www.example.com"}},
+		{"Host(`a.example.com`) || Host(`b.example.com`) && PathPrefix(`/api`)", []string{"a.example.com", "b.example.com"}},
+		{"HostSNI(`db.example.com`)", []string{"db.example.com"}},
+		{"HostSNI(`*`)", nil},
+		{"Host(\"EXAMPLE.com\")", []string{"example.com"}},
+		{"PathPrefix(`/`)", nil},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.rule, func(t *testing.T) {
+			hosts := ParseRuleHosts(tt.rule)
+			if len(hosts) != len(tt.expected) {
+				t.Fatalf("Expected hosts %v, got %v", tt.expected, hosts)
+			}
+			for i := range hosts {
+				if hosts[i] != tt.expected[i] {
+					t.Errorf("Expected host '%s', got '%s'", tt.expected[i], hosts[i])
+				}
+			}
+		})
+	}
+}
+
 func TestAPIClient_IsHealthy(t *testing.T) {
 	// Create mock server
 	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
@@ -330,6 +471,18 @@ func TestRouterMatchesDomain(t *testing.T) {
 			domain:   "example.com",
 			expected: false,
 		},
+		{
+			name:     "subdomain is not a match",
+			router:   Router{Rule: "Host(`api.example.com`)"},
+			domain:   "example.com",
+			expected: false,
+		},
+		{
+			name:     "hostsni match",
+			router:   Router{Rule: "HostSNI(`example.com`)"},
+			domain:   "example.com",
+			expected: true,
+		},
 		{
 			name:     "case insensitive match",
 			router:   Router{Rule: "Host(`EXAMPLE.COM`)"},
@@ -432,4 +585,31 @@ func TestGetServices_LegacyFunction(t *testing.T) {
 			t.Errorf("Expected service '%s', got '%s'", expectedServices[i], service)
 		}
 	}
-}
\ No newline at end of file
+}
+
+func TestAPIClient_Unreachable(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	client := NewAPIClient(server.URL, 30*time.Second)
+
+	// A proxy in front of Traefik that can't reach it
+	if _, err := client.GetRouters(context.Background()); !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected a 502 to be unreachable, got: %v", err)
+	}
+
+	server.Close()
+	_, err := client.GetRouters(context.Background())
+	if !errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected a closed server to be unreachable, got: %v", err)
+	}
+	if !strings.Contains(err.Error(), "failed to call Traefik API") {
+		t.Errorf("Expected the message to be kept, got: %v", err)
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := client.GetRouters(ctx); errors.Is(err, ErrUnreachable) {
+		t.Errorf("Expected a cancelled request not to be unreachable, got: %v", err)
+	}
+}
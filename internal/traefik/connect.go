@@ -0,0 +1,61 @@
+package traefik
+
+import (
+	"context"
+	"log"
+	"time"
+)
+
+// WaitHealthy checks the API up to attempts times, doubling backoff between tries.
+// It returns the last error if the API never answered.
+func (c *APIClient) WaitHealthy(ctx context.Context, attempts int, backoff time.Duration, logger *log.Logger) error {
+	if attempts < 1 {
+		attempts = 1
+	}
+
+	var err error
+	for attempt := 1; attempt <= attempts; attempt++ {
+		if err = c.IsHealthy(ctx); err == nil {
+			return nil
+		}
+		if attempt == attempts {
+			break
+		}
+
+		if logger != nil {
+			logger.Printf("Traefik API not reachable (attempt %d/%d), retrying in %v: %v", attempt, attempts, backoff, err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return err
+		case <-time.After(backoff):
+		}
+		backoff *= 2
+	}
+
+	return err
+}
+
+// Reconnect polls the API every interval until it answers, then calls onConnect.
+// It returns early without calling onConnect when ctx is cancelled.
+func (c *APIClient) Reconnect(ctx context.Context, interval time.Duration, onConnect func()) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		checkCtx, cancel := context.WithTimeout(ctx, interval)
+		err := c.IsHealthy(checkCtx)
+		cancel()
+		if err == nil {
+			onConnect()
+			return
+		}
+	}
+}
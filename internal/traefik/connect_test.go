@@ -0,0 +1,65 @@
+package traefik
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+// newFlakyPingServer fails /ping until it has been called failures times
+func newFlakyPingServer(t *testing.T, failures int32) (*httptest.Server, *atomic.Int32) {
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) <= failures {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		w.WriteHeader(http.StatusOK)
+	}))
+	t.Cleanup(server.Close)
+	return server, &calls
+}
+
+func TestAPIClient_WaitHealthy(t *testing.T) {
+	server, calls := newFlakyPingServer(t, 2)
+	client := NewAPIClient(server.URL, 5*time.Second)
+
+	if err := client.WaitHealthy(context.Background(), 3, time.Millisecond, nil); err != nil {
+		t.Errorf("Expected API to become healthy on the third attempt, got: %v", err)
+	}
+	if got := calls.Load(); got != 3 {
+		t.Errorf("Expected 3 health checks, got %d", got)
+	}
+}
+
+func TestAPIClient_WaitHealthy_GivesUp(t *testing.T) {
+	server, calls := newFlakyPingServer(t, 10)
+	client := NewAPIClient(server.URL, 5*time.Second)
+
+	if err := client.WaitHealthy(context.Background(), 2, time.Millisecond, nil); err == nil {
+		t.Error("Expected WaitHealthy to fail")
+	}
+	if got := calls.Load(); got != 2 {
+		t.Errorf("Expected 2 health checks, got %d", got)
+	}
+}
+
+func TestAPIClient_Reconnect(t *testing.T) {
+	server, _ := newFlakyPingServer(t, 2)
+	client := NewAPIClient(server.URL, 5*time.Second)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	connected := make(chan struct{})
+	go client.Reconnect(ctx, 10*time.Millisecond, func() { close(connected) })
+
+	select {
+	case <-connected:
+	case <-ctx.Done():
+		t.Fatal("Expected Reconnect to call onConnect once the API answered")
+	}
+}